@@ -0,0 +1,98 @@
+/*
+	Package barcode draws barcodes as vector graphics in PDF Form
+	XObjects, which can be placed on pages with Page.AddXObject() and
+	the Do operator.  Code 128 symbols are encoded by Code128(); two
+	dimensional symbols such as QR codes are drawn from a module
+	matrix produced by any encoder.
+*/
+package barcode
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/mawicks/PDFiG/pdf" )
+
+// Quiet zones, in modules, required around linear and matrix symbols.
+const (
+	LinearQuietZone = 10
+	MatrixQuietZone = 4 )
+
+// precision is the number of digits after the decimal point used for
+// coordinates.
+const precision = 3
+
+// LinearForm() returns a Form XObject drawing a linear barcode whose
+// modules, from left to right, are bars where modules is true.  Each
+// module is moduleWidth wide, and bars are height tall.  The form's
+// origin is the lower-left corner of the quiet zone, which is
+// included in its bounding box.
+func LinearForm(modules []bool, moduleWidth, height float64) pdf.Stream {
+	var b bytes.Buffer
+	b.WriteString("0 g\n")
+	for _,run := range runs(modules) {
+		fmt.Fprintf(&b, "%s 0 %s %s re\n",
+			pdf.FormatReal(float64(LinearQuietZone+run[0])*moduleWidth, precision),
+			pdf.FormatReal(float64(run[1])*moduleWidth, precision),
+			pdf.FormatReal(height, precision))
+	}
+	b.WriteString("f\n")
+	return form(b.Bytes(), float64(len(modules)+2*LinearQuietZone)*moduleWidth, height)
+}
+
+// MatrixForm() returns a Form XObject drawing a two-dimensional
+// barcode, such as a QR code, whose modules are dark where matrix is
+// true.  matrix[0] is the top row.  Each module is a square
+// moduleSize on a side.  The form's origin is the lower-left corner of
+// the quiet zone, which is included in its bounding box.
+func MatrixForm(matrix [][]bool, moduleSize float64) pdf.Stream {
+	columns := 0
+	for _,row := range matrix {
+		if len(row) > columns {
+			columns = len(row)
+		}
+	}
+
+	var b bytes.Buffer
+	b.WriteString("0 g\n")
+	for i,row := range matrix {
+		y := float64(len(matrix) - 1 - i + MatrixQuietZone) * moduleSize
+		for _,run := range runs(row) {
+			fmt.Fprintf(&b, "%s %s %s %s re\n",
+				pdf.FormatReal(float64(MatrixQuietZone+run[0])*moduleSize, precision),
+				pdf.FormatReal(y, precision),
+				pdf.FormatReal(float64(run[1])*moduleSize, precision),
+				pdf.FormatReal(moduleSize, precision))
+		}
+	}
+	b.WriteString("f\n")
+	return form(b.Bytes(),
+		float64(columns+2*MatrixQuietZone)*moduleSize,
+		float64(len(matrix)+2*MatrixQuietZone)*moduleSize)
+}
+
+// runs() returns the start and length of each run of true values, so
+// that adjacent modules are drawn as a single rectangle.
+func runs(modules []bool) [][2]int {
+	var result [][2]int
+	for i:=0; i<len(modules); {
+		if !modules[i] {
+			i++
+			continue
+		}
+		start := i
+		for i < len(modules) && modules[i] {
+			i++
+		}
+		result = append(result, [2]int{start, i-start})
+	}
+	return result
+}
+
+func form(contents []byte, width, height float64) pdf.Stream {
+	stream := pdf.NewStream()
+	stream.Add("Type", pdf.NewName("XObject"))
+	stream.Add("Subtype", pdf.NewName("Form"))
+	stream.Add("BBox", pdf.NewRectangle(0, 0, width, height))
+	stream.Write(contents)
+	return stream
+}
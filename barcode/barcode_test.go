@@ -0,0 +1,135 @@
+package barcode
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"github.com/mawicks/PDFiG/pdf" )
+
+func TestCode128Patterns(t *testing.T) {
+	for i,pattern := range code128Patterns {
+		sum := 0
+		for _,width := range pattern {
+			sum += int(width - '0')
+		}
+		expected := 11
+		if i == stop {
+			expected = 13
+		}
+		if sum != expected {
+			t.Errorf(`Pattern %d ("%s") has %d modules`, i, pattern, sum)
+		}
+	}
+}
+
+// symbols() returns the values of the symbol characters in modules.
+func symbols(t *testing.T, modules []bool) []int {
+	var values []int
+	for len(modules) > 13 {
+		var pattern []byte
+		for i:=0; len(pattern)<6; {
+			j := i
+			for j < len(modules) && modules[j] == modules[i] {
+				j++
+			}
+			pattern = append(pattern, byte('0'+j-i))
+			i = j
+		}
+		value := -1
+		for v,p := range code128Patterns {
+			if p == string(pattern) {
+				value = v
+			}
+		}
+		if value < 0 {
+			t.Fatalf(`Unrecognized pattern %s`, pattern)
+		}
+		values = append(values, value)
+		modules = modules[11:]
+	}
+	return values
+}
+
+func TestCode128(t *testing.T) {
+	tests := []struct {
+		data string
+		values []int
+	}{
+		// Start B, "P", "J", "J", "1", "2", "3", "C", checksum
+		{"PJJ123C", []int{104, 48, 42, 42, 17, 18, 19, 35, 55}},
+		// Start C, 12, 34, 56, checksum
+		{"123456", []int{105, 12, 34, 56, 44}},
+		// Start B, "A", Code C, 12, 34, Code B, "5", checksum
+		{"A12345", []int{104, 33, 99, 12, 34, 100, 21, 0}},
+	}
+	for _,test := range tests {
+		modules,err := Code128(test.data)
+		if err != nil {
+			t.Errorf(`Code128("%s") failed: %v`, test.data, err)
+			continue
+		}
+		if len(modules) != 11*len(test.values)+13 {
+			t.Errorf(`Code128("%s") has %d modules`, test.data, len(modules))
+		}
+		if values := symbols(t, modules); !equal(values, test.values) {
+			t.Errorf(`Code128("%s") encoded %v; expected %v`, test.data, values, test.values)
+		}
+	}
+
+	if _,err := Code128("tab\t"); err == nil {
+		t.Error(`Code128() accepted a control character`)
+	}
+	if _,err := Code128(""); err == nil {
+		t.Error(`Code128() accepted empty data`)
+	}
+}
+
+func equal(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestForms(t *testing.T) {
+	linear := LinearForm([]bool{true, true, false, true}, 0.5, 20)
+	contents,_ := ioutil.ReadAll(linear.Reader())
+	if s := string(contents); s != "0 g\n5 0 1 20 re\n6.5 0 0.5 20 re\nf\n" {
+		t.Errorf(`LinearForm() has contents "%s"`, s)
+	}
+	if bbox,_ := linear.Dictionary().GetArray("BBox").Floats(); len(bbox) != 4 || bbox[2] != 12 || bbox[3] != 20 {
+		t.Errorf(`LinearForm() has bounding box %v`, bbox)
+	}
+
+	matrix := MatrixForm([][]bool{{true, false}, {true, true}}, 2)
+	contents,_ = ioutil.ReadAll(matrix.Reader())
+	if s := string(contents); s != "0 g\n8 10 2 2 re\n8 8 4 2 re\nf\n" {
+		t.Errorf(`MatrixForm() has contents "%s"`, s)
+	}
+	if bbox,_ := matrix.Dictionary().GetArray("BBox").Floats(); len(bbox) != 4 || bbox[2] != 20 || bbox[3] != 20 {
+		t.Errorf(`MatrixForm() has bounding box %v`, bbox)
+	}
+
+	// A barcode drawn on a page.
+	filename := "/tmp/test-barcode.pdf"
+	os.Remove(filename)
+	modules,_ := Code128("PDFiG-0042")
+	doc := pdf.OpenDocument(filename, os.O_RDWR|os.O_CREATE)
+	page := doc.NewPage()
+	name := page.AddXObject(doc.WriteObject(LinearForm(modules, 1, 36)))
+	page.Write([]byte("q 1 0 0 1 72 700 cm /" + name + " Do Q"))
+	doc.Close()
+
+	doc = pdf.OpenDocument(filename, os.O_RDWR)
+	defer doc.Close()
+	contents,_ = ioutil.ReadAll(doc.Page(0).Reader())
+	if !strings.Contains(string(contents), "/"+name+" Do") {
+		t.Errorf(`Page contents are "%s"`, contents)
+	}
+}
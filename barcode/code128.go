@@ -0,0 +1,94 @@
+package barcode
+
+import (
+	"errors"
+	"fmt" )
+
+// code128Patterns lists the widths of the alternating bars and spaces
+// of each Code 128 symbol character, beginning with a bar.
+var code128Patterns = [...]string{
+	"212222", "222122", "222221", "121223", "121322", "131222", "122213", "122312", "132212", "221213",
+	"221312", "231212", "112232", "122132", "122231", "113222", "123122", "123221", "223211", "221132",
+	"221231", "213212", "223112", "312131", "311222", "321122", "321221", "312212", "322112", "322211",
+	"212123", "212321", "232121", "111323", "131123", "131321", "112313", "132113", "132311", "211313",
+	"231113", "231311", "112133", "112331", "132131", "113123", "113321", "133121", "313121", "211331",
+	"231131", "213113", "213311", "213131", "311123", "311321", "331121", "312113", "312311", "332111",
+	"314111", "221411", "431111", "111224", "111422", "121124", "121421", "141122", "141221", "112214",
+	"112412", "122114", "122411", "142112", "142211", "241211", "221114", "413111", "241112", "134111",
+	"111242", "121142", "121241", "114212", "124112", "124211", "411212", "421112", "421211", "212141",
+	"214121", "412121", "111143", "111341", "131141", "114113", "114311", "411113", "411311", "113141",
+	"114131", "311141", "411131", "211412", "211214", "211232", "2331112" }
+
+// Code 128 function characters.
+const (
+	codeC = 99
+	codeB = 100
+	startB = 104
+	startC = 105
+	stop = 106 )
+
+var emptyCode128 = errors.New(`Code 128 data is empty`)
+
+// Code128() encodes data, which must be printable ASCII, as a Code 128
+// symbol and returns its modules, which are bars where true, without
+// quiet zones.  Runs of four or more digits are encoded two to a
+// symbol character using code set C; other characters use code set B.
+func Code128(data string) ([]bool, error) {
+	if len(data) == 0 {
+		return nil, emptyCode128
+	}
+	for i:=0; i<len(data); i++ {
+		if data[i] < 32 || data[i] > 126 {
+			return nil, fmt.Errorf(`Code 128 cannot encode %q`, data[i])
+		}
+	}
+
+	var values []int
+	inC := false
+	for i:=0; i<len(data); {
+		digits := 0
+		for i+digits < len(data) && data[i+digits] >= '0' && data[i+digits] <= '9' {
+			digits++
+		}
+		switch {
+		case digits >= 4 || inC && digits >= 2:
+			if !inC {
+				if i == 0 {
+					values = append(values, startC)
+				} else {
+					values = append(values, codeC)
+				}
+				inC = true
+			}
+			for ; digits >= 2; digits -= 2 {
+				values = append(values, int(data[i]-'0')*10 + int(data[i+1]-'0'))
+				i += 2
+			}
+		default:
+			if i == 0 {
+				values = append(values, startB)
+			} else if inC {
+				values = append(values, codeB)
+			}
+			inC = false
+			values = append(values, int(data[i]) - 32)
+			i++
+		}
+	}
+
+	checksum := values[0]
+	for i,v := range values[1:] {
+		checksum += (i+1) * v
+	}
+	values = append(values, checksum % 103, stop)
+
+	var modules []bool
+	for _,v := range values {
+		for i,width := range code128Patterns[v] {
+			for j:=0; j<int(width-'0'); j++ {
+				modules = append(modules, i%2 == 0)
+			}
+		}
+	}
+	return modules, nil
+}
@@ -0,0 +1,339 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"image/png"
+	"os"
+	"strings"
+	"github.com/mawicks/PDFiG/pdf" )
+
+var (
+	missingOutput = usageError("-o is required")
+	notValid = errors.New(`File is not valid`) )
+
+func merge(env *environment, flags *flag.FlagSet, args []string) error {
+	output := flags.String("o", "", "output `file`")
+	password := flags.String("password", "", "`password` of the inputs")
+	inputs,err := parse(flags, args, 1)
+	if err != nil {
+		return err
+	}
+	if *output == "" {
+		return missingOutput
+	}
+	var sources []*pdf.Document
+	defer func() {
+		for _,source := range sources {
+			source.Discard()
+		}
+	}()
+	for _,input := range inputs {
+		source,err := openDocument(input, *password)
+		if err != nil {
+			return err
+		}
+		sources = append(sources, source)
+	}
+	return pdf.Merge(*output, sources...).Close()
+}
+
+func split(env *environment, flags *flag.FlagSet, args []string) error {
+	pattern := flags.String("o", "", "output `pattern`, in which %d is replaced by the page number; without %d, the pages are written to one file (default input-%d.pdf)")
+	list := flags.String("pages", "", "`list` of pages, such as 1-3,5,8- (default all)")
+	password := flags.String("password", "", "`password` of the input")
+	inputs,err := parse(flags, args, 1)
+	if err != nil {
+		return err
+	}
+	if *pattern == "" {
+		*pattern = baseName(inputs[0]) + "-%d.pdf"
+	}
+	if n := strings.Count(*pattern, "%"); n > 1 || n == 1 && !strings.Contains(*pattern, "%d") {
+		return usageError("-o may contain only one %d")
+	}
+
+	doc,err := openDocument(inputs[0], *password)
+	if err != nil {
+		return err
+	}
+	defer doc.Discard()
+	pages,err := parsePages(*list, doc.PageCount())
+	if err != nil {
+		return err
+	}
+	if !strings.Contains(*pattern, "%d") {
+		return doc.ExtractPages(*pattern, pages).Close()
+	}
+	for _,n := range pages {
+		if err := doc.ExtractPages(fmt.Sprintf(*pattern, n+1), []uint{n}).Close(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func info(env *environment, flags *flag.FlagSet, args []string) error {
+	dump := flags.Bool("dump", false, "show the object graph rather than the summary")
+	audit := flags.Bool("audit", false, "show how the bytes of the file are spent rather than the summary")
+	password := flags.String("password", "", "`password` of the input")
+	inputs,err := parse(flags, args, 1)
+	if err != nil {
+		return err
+	}
+	doc,err := openDocument(inputs[0], *password)
+	if err != nil {
+		return err
+	}
+	defer doc.Discard()
+	if *dump {
+		return doc.Dump(env.stdout)
+	}
+	if *audit {
+		report,err := doc.Audit()
+		if err != nil {
+			return err
+		}
+		_,err = fmt.Fprint(env.stdout, report)
+		return err
+	}
+	_,err = fmt.Fprint(env.stdout, doc.Summary())
+	return err
+}
+
+func extractText(env *environment, flags *flag.FlagSet, args []string) error {
+	list := flags.String("pages", "", "`list` of pages, such as 1-3,5,8- (default all)")
+	password := flags.String("password", "", "`password` of the input")
+	inputs,err := parse(flags, args, 1)
+	if err != nil {
+		return err
+	}
+	doc,err := openDocument(inputs[0], *password)
+	if err != nil {
+		return err
+	}
+	defer doc.Discard()
+	pages,err := parsePages(*list, doc.PageCount())
+	if err != nil {
+		return err
+	}
+	// As with pdftotext, each page ends with a form feed.
+	for _,n := range pages {
+		if _,err := fmt.Fprintf(env.stdout, "%s\n\f", doc.Page(n).Text()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func extractImages(env *environment, flags *flag.FlagSet, args []string) error {
+	prefix := flags.String("o", "", "`prefix` of the image files, which are named prefix-page-n.png (default input)")
+	list := flags.String("pages", "", "`list` of pages, such as 1-3,5,8- (default all)")
+	password := flags.String("password", "", "`password` of the input")
+	inputs,err := parse(flags, args, 1)
+	if err != nil {
+		return err
+	}
+	if *prefix == "" {
+		*prefix = baseName(inputs[0])
+	}
+	doc,err := openDocument(inputs[0], *password)
+	if err != nil {
+		return err
+	}
+	defer doc.Discard()
+	pages,err := parsePages(*list, doc.PageCount())
+	if err != nil {
+		return err
+	}
+	for _,n := range pages {
+		for i,pageImage := range doc.Page(n).Images() {
+			// Images that can't be decoded are skipped with a
+			// warning rather than ending the extraction.
+			img,err := pageImage.Image()
+			if err != nil {
+				fmt.Fprintf(env.stderr, "pdfig extract-images: page %d image %d: %v\n", n+1, i+1, err)
+				continue
+			}
+			filename := fmt.Sprintf("%s-%d-%d.png", *prefix, n+1, i+1)
+			f,err := os.Create(filename)
+			if err != nil {
+				return err
+			}
+			err = png.Encode(f, img)
+			if closeErr := f.Close(); err == nil {
+				err = closeErr
+			}
+			if err != nil {
+				return err
+			}
+			fmt.Fprintln(env.stdout, filename)
+		}
+	}
+	return nil
+}
+
+// parsePermissions() converts a comma separated list of permission
+// names, "all", or "none" to Permissions.
+func parsePermissions(list string) (pdf.Permissions, error) {
+	var p pdf.Permissions
+	switch list {
+	case "all":
+		return pdf.AllPermissions(), nil
+	case "none", "":
+		return p, nil
+	}
+	for _,name := range strings.Split(list, ",") {
+		switch name {
+		case "print":
+			p.Print = true
+		case "print-high":
+			p.HighResolutionPrint = true
+		case "modify":
+			p.Modify = true
+		case "copy":
+			p.Copy = true
+		case "annotate":
+			p.Annotate = true
+		case "fill-forms":
+			p.FillForms = true
+		case "accessibility":
+			p.ExtractForAccessibility = true
+		case "assemble":
+			p.Assemble = true
+		default:
+			return p, usageError(fmt.Sprintf("unknown permission \"%s\"", name))
+		}
+	}
+	return p, nil
+}
+
+func encrypt(env *environment, flags *flag.FlagSet, args []string) error {
+	output := flags.String("o", "", "output `file`")
+	user := flags.String("user", "", "user `password`, which may be empty")
+	owner := flags.String("owner", "", "owner `password` (default the user password)")
+	allow := flags.String("allow", "all", "permissions granted with the user password: all, none, or a `list` of print, print-high, modify, copy, annotate, fill-forms, accessibility, and assemble")
+	inputs,err := parse(flags, args, 1)
+	if err != nil {
+		return err
+	}
+	if *output == "" {
+		return missingOutput
+	}
+	permissions,err := parsePermissions(*allow)
+	if err != nil {
+		return err
+	}
+	return pdf.Encrypt(inputs[0], *output, *user, *owner, permissions)
+}
+
+func decrypt(env *environment, flags *flag.FlagSet, args []string) error {
+	output := flags.String("o", "", "output `file`")
+	password := flags.String("password", "", "user or owner `password` of the input")
+	inputs,err := parse(flags, args, 1)
+	if err != nil {
+		return err
+	}
+	if *output == "" {
+		return missingOutput
+	}
+	return pdf.Decrypt(inputs[0], *output, passwordOptions(*password)...)
+}
+
+func linearize(env *environment, flags *flag.FlagSet, args []string) error {
+	output := flags.String("o", "", "output `file`")
+	password := flags.String("password", "", "`password` of the input")
+	inputs,err := parse(flags, args, 1)
+	if err != nil {
+		return err
+	}
+	if *output == "" {
+		return missingOutput
+	}
+	return pdf.Linearize(inputs[0], *output, passwordOptions(*password)...)
+}
+
+func optimize(env *environment, flags *flag.FlagSet, args []string) error {
+	output := flags.String("o", "", "output `file`")
+	dpi := flags.Float64("dpi", 0, "target `resolution` of images in pixels per inch (default 150)")
+	quality := flags.Int("quality", 0, "JPEG `quality` from 1 to 100 (default 75)")
+	fonts := flags.Bool("fonts", false, "also subset embedded fonts to the glyphs shown")
+	inputs,err := parse(flags, args, 1)
+	if err != nil {
+		return err
+	}
+	if *output == "" {
+		return missingOutput
+	}
+	if err = pdf.OptimizeImages(inputs[0], *output, pdf.ImageOptimization{TargetDPI: *dpi, Quality: *quality}); err != nil {
+		return err
+	}
+	if *fonts {
+		return pdf.SubsetFonts(*output, *output)
+	}
+	return nil
+}
+
+func convertJSON(env *environment, flags *flag.FlagSet, args []string) error {
+	output := flags.String("o", "", "output `file` (default standard output)")
+	importing := flags.Bool("import", false, "read JSON and write PDF, rather than the reverse")
+	password := flags.String("password", "", "`password` of the input")
+	inputs,err := parse(flags, args, 1)
+	if err != nil {
+		return err
+	}
+	if *importing {
+		if *output == "" {
+			return missingOutput
+		}
+		input,err := os.Open(inputs[0])
+		if err != nil {
+			return err
+		}
+		defer input.Close()
+		return pdf.ImportJSON(input, *output)
+	}
+	if *output == "" {
+		return pdf.ExportJSON(inputs[0], env.stdout, passwordOptions(*password)...)
+	}
+	out,err := os.Create(*output)
+	if err != nil {
+		return err
+	}
+	if err = pdf.ExportJSON(inputs[0], out, passwordOptions(*password)...); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+var profiles = map[string]pdf.ValidationProfile{
+	"syntactic": pdf.SyntacticProfile,
+	"pdfa-2b": pdf.PDFA2BProfile,
+	"pdfx-4": pdf.PDFX4Profile }
+
+func validate(env *environment, flags *flag.FlagSet, args []string) error {
+	name := flags.String("profile", "syntactic", "`profile` to check: syntactic, pdfa-2b, or pdfx-4")
+	password := flags.String("password", "", "`password` of the input")
+	inputs,err := parse(flags, args, 1)
+	if err != nil {
+		return err
+	}
+	profile,ok := profiles[*name]
+	if !ok {
+		return usageError(fmt.Sprintf("unknown profile \"%s\"", *name))
+	}
+	report,err := pdf.Validate(inputs[0], profile, passwordOptions(*password)...)
+	if err != nil {
+		return err
+	}
+	if len(report.Problems) > 0 {
+		fmt.Fprintln(env.stdout, report)
+	}
+	if !report.Valid() {
+		return notValid
+	}
+	fmt.Fprintf(env.stdout, "%s is valid (%s)\n", inputs[0], profile)
+	return nil
+}
@@ -0,0 +1,209 @@
+/*
+	Pdfig is a command line tool for inspecting and transforming PDF
+	files with the pdf package.
+
+	Usage:
+
+		pdfig <command> [options] <arguments>
+
+	Run "pdfig help" for the list of commands and "pdfig <command>
+	-h" for the options of one of them.  Page numbers given to
+	-pages start at 1.
+*/
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"github.com/mawicks/PDFiG/pdf" )
+
+// environment holds the streams a command writes to.
+type environment struct {
+	stdout, stderr io.Writer
+}
+
+type command struct {
+	name string
+	arguments string
+	description string
+	// run registers the command's flags with flags, parses args,
+	// and carries out the command.
+	run func(env *environment, flags *flag.FlagSet, args []string) error
+}
+
+// commands lists the commands in the order in which "pdfig help"
+// shows them.  It is filled in by init() since the help command
+// refers to it.
+var commands []command
+
+func init() {
+	commands = []command{
+		{"merge", "-o output input...", "concatenate the pages of the inputs", merge},
+		{"split", "[-o pattern] [-pages list] input", "write pages to separate files", split},
+		{"info", "[-dump | -audit] input", "show the document summary or object graph", info},
+		{"extract-text", "[-pages list] input", "write the text of pages to standard output", extractText},
+		{"extract-images", "[-o prefix] [-pages list] input", "write the images of pages as PNG files", extractImages},
+		{"encrypt", "-o output [-user password] [-owner password] [-allow list] input", "encrypt with AES-256", encrypt},
+		{"decrypt", "-o output input", "remove encryption", decrypt},
+		{"linearize", "-o output input", "rewrite for Fast Web View", linearize},
+		{"optimize", "-o output [-dpi resolution] [-quality n] [-fonts] input", "downsample images and subset fonts", optimize},
+		{"json", "[-o output] input | -import -o output input", "convert to or from JSON", convertJSON},
+		{"validate", "[-profile name] input", "check syntax and conformance", validate},
+		{"help", "", "show this list", help} }
+}
+
+// usageError reports a mistake in the command line, for which the
+// usage is shown and the exit status is 2.
+type usageError string
+
+func (e usageError) Error() string {
+	return string(e)
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], &environment{os.Stdout, os.Stderr}))
+}
+
+// run() runs the command named by args[0] and returns the exit status:
+// 0 for success, 1 for failure, and 2 for a usage error.
+func run(args []string, env *environment) (status int) {
+	if len(args) == 0 {
+		help(env, nil, nil)
+		return 2
+	}
+	for _,c := range commands {
+		if c.name != args[0] {
+			continue
+		}
+		flags := flag.NewFlagSet("pdfig " + c.name, flag.ContinueOnError)
+		flags.SetOutput(env.stderr)
+		flags.Usage = func() {
+			fmt.Fprintf(env.stderr, "Usage: pdfig %s %s\n", c.name, c.arguments)
+			flags.PrintDefaults()
+		}
+		// The package reports many problems with damaged files
+		// by panicking.
+		defer func() {
+			if r := recover(); r != nil {
+				fmt.Fprintf(env.stderr, "pdfig %s: %v\n", c.name, r)
+				status = 1
+			}
+		}()
+		err := c.run(env, flags, args[1:])
+		if _,ok := err.(usageError); ok {
+			fmt.Fprintf(env.stderr, "pdfig %s: %v\n", c.name, err)
+			flags.Usage()
+			return 2
+		}
+		switch err {
+		case nil, flag.ErrHelp:
+			return 0
+		}
+		fmt.Fprintf(env.stderr, "pdfig %s: %v\n", c.name, err)
+		return 1
+	}
+	fmt.Fprintf(env.stderr, "pdfig: unknown command \"%s\"\n", args[0])
+	help(env, nil, nil)
+	return 2
+}
+
+func help(env *environment, flags *flag.FlagSet, args []string) error {
+	fmt.Fprintln(env.stderr, "Usage: pdfig <command> [options] <arguments>\n\nCommands:")
+	for _,c := range commands {
+		fmt.Fprintf(env.stderr, "  %-15s %s\n", c.name, c.description)
+	}
+	return nil
+}
+
+// parse() parses the flags of a command, which must be followed by at
+// least minimum arguments, and returns the arguments.
+func parse(flags *flag.FlagSet, args []string, minimum int) ([]string, error) {
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+	if flags.NArg() < minimum {
+		return nil, usageError("missing arguments")
+	}
+	return flags.Args(), nil
+}
+
+// passwordOptions() returns the options that open an input with
+// password, which may be empty.
+func passwordOptions(password string) []pdf.FileOption {
+	if password == "" {
+		return nil
+	}
+	return []pdf.FileOption{pdf.WithPasswordCallback(func(attempt int) (string, bool) {
+		return password, attempt == 1
+	})}
+}
+
+// openDocument() opens a document to be read.  It must be closed with
+// Discard().
+func openDocument(filename, password string) (*pdf.Document, error) {
+	f,_,err := pdf.OpenFile(filename, os.O_RDONLY, passwordOptions(password)...)
+	if err != nil {
+		return nil, err
+	}
+	return pdf.NewDocumentFromFile(f), nil
+}
+
+var badPageList = errors.New(`Page lists look like "1-3,5,8-"`)
+
+// parsePages() converts a list of page ranges, such as "1-3,5,8-",
+// to page numbers starting at 0.  An empty list selects every page.
+func parsePages(list string, count uint) ([]uint, error) {
+	var pages []uint
+	if list == "" {
+		for n:=uint(0); n<count; n++ {
+			pages = append(pages, n)
+		}
+		return pages, nil
+	}
+	number := func(s string, missing uint) (uint, error) {
+		if s == "" {
+			return missing, nil
+		}
+		n,err := strconv.ParseUint(s, 10, 32)
+		if err != nil || n == 0 {
+			return 0, badPageList
+		}
+		return uint(n), nil
+	}
+	for _,r := range strings.Split(list, ",") {
+		if r == "" {
+			return nil, badPageList
+		}
+		first, last := r, r
+		if dash := strings.IndexByte(r, '-'); dash >= 0 {
+			first, last = r[:dash], r[dash+1:]
+		}
+		from,err := number(first, 1)
+		if err != nil {
+			return nil, err
+		}
+		to,err := number(last, count)
+		if err != nil {
+			return nil, err
+		}
+		if from > to || to > count {
+			return nil, fmt.Errorf(`Pages %s are not among the %d pages of the document`, r, count)
+		}
+		for n:=from; n<=to; n++ {
+			pages = append(pages, n-1)
+		}
+	}
+	return pages, nil
+}
+
+// baseName() returns filename without its directory or extension.
+func baseName(filename string) string {
+	base := filepath.Base(filename)
+	return strings.TrimSuffix(base, filepath.Ext(base))
+}
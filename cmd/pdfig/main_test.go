@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"github.com/mawicks/PDFiG/pdf" )
+
+func TestParsePages(t *testing.T) {
+	for _,c := range []struct {
+		list string
+		pages []uint
+	}{
+		{"", []uint{0, 1, 2, 3, 4}},
+		{"2", []uint{1}},
+		{"1-3,5", []uint{0, 1, 2, 4}},
+		{"4-", []uint{3, 4}},
+		{"-2", []uint{0, 1}} } {
+		if pages,err := parsePages(c.list, 5); err != nil || !reflect.DeepEqual(pages, c.pages) {
+			t.Errorf(`parsePages(%q) returned %v, %v; expected %v`, c.list, pages, err, c.pages)
+		}
+	}
+	for _,list := range []string{"0", "6", "3-2", "1,,2", "a"} {
+		if _,err := parsePages(list, 5); err == nil {
+			t.Errorf(`parsePages(%q) succeeded`, list)
+		}
+	}
+}
+
+func TestCommands(t *testing.T) {
+	directory,err := ioutil.TempDir("", "pdfig")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(directory)
+	path := func(name string) string {
+		return filepath.Join(directory, name)
+	}
+	doc := pdf.OpenDocument(path("input.pdf"), os.O_RDWR|os.O_CREATE)
+	for i:=0; i<3; i++ {
+		page := doc.NewPage()
+		fmt.Fprintf(page, "BT /%s 12 Tf (Page %d) Tj ET", page.AddFont(pdf.NewStandardFont(pdf.Helvetica)), i+1)
+	}
+	doc.Close()
+
+	pdfig := func(expected int, args ...string) string {
+		var stdout, stderr bytes.Buffer
+		if status := run(args, &environment{&stdout, &stderr}); status != expected {
+			t.Errorf(`pdfig %s exited with %d; expected %d: %s`, strings.Join(args, " "), status, expected, stderr.String())
+		}
+		return stdout.String()
+	}
+	pdfig(0, "merge", "-o", path("merged.pdf"), path("input.pdf"), path("input.pdf"))
+	if out := pdfig(0, "info", path("merged.pdf")); !strings.Contains(out, "Pages:          6\n") {
+		t.Errorf(`pdfig info reported:\n%s`, out)
+	}
+	if out := pdfig(0, "info", "-audit", path("input.pdf")); !strings.Contains(out, "Content streams:") {
+		t.Errorf(`pdfig info -audit reported:\n%s`, out)
+	}
+	pdfig(0, "split", "-pages", "2-", "-o", path("page-%d.pdf"), path("input.pdf"))
+	if out := pdfig(0, "extract-text", path("page-3.pdf")); !strings.Contains(out, "Page 3") {
+		t.Errorf(`pdfig extract-text printed %q`, out)
+	}
+	if _,err := os.Stat(path("page-1.pdf")); err == nil {
+		t.Errorf(`pdfig split wrote a page that wasn't selected`)
+	}
+
+	pdfig(0, "encrypt", "-o", path("encrypted.pdf"), "-user", "secret", "-owner", "boss", "-allow", "print,copy", path("input.pdf"))
+	pdfig(1, "info", path("encrypted.pdf"))
+	if out := pdfig(0, "info", "-password", "secret", path("encrypted.pdf")); !strings.Contains(out, "print:yes copy:yes change:no") {
+		t.Errorf(`pdfig info reported:\n%s`, out)
+	}
+	pdfig(0, "decrypt", "-o", path("decrypted.pdf"), "-password", "secret", path("encrypted.pdf"))
+	pdfig(0, "linearize", "-o", path("linearized.pdf"), path("decrypted.pdf"))
+	pdfig(0, "validate", path("linearized.pdf"))
+	pdfig(0, "optimize", "-o", path("optimized.pdf"), "-dpi", "72", "-fonts", path("input.pdf"))
+	pdfig(0, "validate", path("optimized.pdf"))
+	pdfig(0, "json", "-o", path("input.json"), path("input.pdf"))
+	pdfig(0, "json", "-import", "-o", path("imported.pdf"), path("input.json"))
+	if out := pdfig(0, "extract-text", path("imported.pdf")); !strings.Contains(out, "Page 2") {
+		t.Errorf(`pdfig extract-text of an imported file printed %q`, out)
+	}
+	if out := pdfig(0, "json", path("input.pdf")); !strings.Contains(out, `"/Type": "/Catalog"`) {
+		t.Errorf(`pdfig json printed:\n%s`, out)
+	}
+
+	pdfig(2, "merge", path("input.pdf"))
+	pdfig(2, "json", "-import", path("input.json"))
+	pdfig(2, "encrypt", "-o", path("x.pdf"), "-allow", "everything", path("input.pdf"))
+	pdfig(2, "validate", "-profile", "pdfz", path("input.pdf"))
+	pdfig(2, "frobnicate")
+	pdfig(0, "info", "-h")
+}
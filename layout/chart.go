@@ -0,0 +1,571 @@
+package layout
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"github.com/mawicks/PDFiG/pdf" )
+
+var (
+	noChartData = errors.New(`Chart has no data`)
+	chartTooSmall = errors.New(`Chart is too small for its labels`) )
+
+// Color is an RGB color with components from 0 to 1.
+type Color struct {
+	R, G, B float64
+}
+
+// DefaultPalette holds the colors given, in turn, to series and pie
+// slices that don't have their own.
+var DefaultPalette = []Color{
+	{0.12, 0.47, 0.71},
+	{1, 0.5, 0.05},
+	{0.17, 0.63, 0.17},
+	{0.84, 0.15, 0.16},
+	{0.58, 0.4, 0.74},
+	{0.55, 0.34, 0.29},
+	{0.89, 0.47, 0.76},
+	{0.5, 0.5, 0.5} }
+
+// Series is a named sequence of values plotted in a chart.
+type Series struct {
+	Name string
+	Values []float64
+	// Color is the color of the series, or nil for the next color
+	// of the chart's palette.
+	Color *Color
+}
+
+// ChartStyle holds the settings shared by the kinds of chart.
+type ChartStyle struct {
+	// Font and FontSize are used for labels and the legend.
+	Font Font
+	FontSize float64
+	// Palette holds the colors of series without their own.
+	Palette []Color
+	// LineWidth is the width of axes and of the lines of line
+	// charts.
+	LineWidth float64
+	// Legend is true if the names of the series, or the labels of
+	// a pie chart's slices, are listed below the chart.
+	Legend bool
+	// GridLines is true if a light line is drawn across the chart
+	// at each value labeled on the axis.
+	GridLines bool
+}
+
+// DefaultChartStyle() returns a style using 8 point Helvetica, the
+// default palette, one point lines, a legend, and grid lines.
+func DefaultChartStyle() ChartStyle {
+	return ChartStyle{
+		Font: StandardFont(pdf.Helvetica),
+		FontSize: 8,
+		Palette: DefaultPalette,
+		LineWidth: 1,
+		Legend: true,
+		GridLines: true }
+}
+
+// color() returns the color of the i'th series or slice.
+func (s *ChartStyle) color(i int, c *Color) Color {
+	if c != nil {
+		return *c
+	}
+	palette := s.Palette
+	if len(palette) == 0 {
+		palette = DefaultPalette
+	}
+	return palette[i % len(palette)]
+}
+
+// BarChart shows series of values as groups of vertical bars, one
+// group per category.
+type BarChart struct {
+	ChartStyle
+	// Width and Height are the size of the chart, including its
+	// labels and legend.
+	Width, Height float64
+	// Categories holds the label of each group of bars.
+	Categories []string
+	Series []Series
+}
+
+// NewBarChart() constructs a BarChart of the given size using the
+// default style.
+func NewBarChart(width, height float64, categories []string, series ...Series) *BarChart {
+	return &BarChart{DefaultChartStyle(), width, height, categories, series}
+}
+
+// Draw() draws the chart at the left edge of frame, starting a new
+// page if it doesn't fit on the current one, and advances the frame
+// past it.
+func (c *BarChart) Draw(frame *Frame) error {
+	return drawChart(frame, c.Height, func() error {
+		_,err := newPlotArea(&c.ChartStyle, 0, 0, c.Width, c.Height, c.Categories, c.Series, true)
+		return err
+	}, c.DrawAt)
+}
+
+// DrawAt() draws the chart on page with its lower left corner at
+// (x, y).  Bars of negative values extend down from zero.  Missing
+// values, beyond the end of a series, are left out.
+func (c *BarChart) DrawAt(page *pdf.Page, x, y float64) error {
+	plot,err := newPlotArea(&c.ChartStyle, x, y, c.Width, c.Height, c.Categories, c.Series, true)
+	if err != nil {
+		return err
+	}
+	io.WriteString(page, "q\n")
+	plot.draw(page)
+	slot := plot.slot()
+	// The bars fill 80% of each category's slot.
+	barWidth := 0.8 * slot / float64(len(c.Series))
+	zero := plot.y(0)
+	for i,series := range c.Series {
+		setFill(page, c.color(i, series.Color))
+		for j,v := range series.Values {
+			if j >= len(c.Categories) {
+				break
+			}
+			left := plot.left + float64(j)*slot + 0.1*slot + float64(i)*barWidth
+			fmt.Fprintf(page, "%s re\n", formatReals(left, math.Min(zero, plot.y(v)), barWidth, math.Abs(plot.y(v)-zero)))
+		}
+		io.WriteString(page, "f\n")
+	}
+	plot.drawZero(page)
+	io.WriteString(page, "Q\n")
+	return nil
+}
+
+// LineChart shows series of values as lines joining a point for each
+// category.
+type LineChart struct {
+	ChartStyle
+	Width, Height float64
+	// Categories holds the labels along the horizontal axis.
+	Categories []string
+	Series []Series
+	// Markers is true if each point is marked with a square.
+	Markers bool
+}
+
+// NewLineChart() constructs a LineChart of the given size using the
+// default style, with markers.
+func NewLineChart(width, height float64, categories []string, series ...Series) *LineChart {
+	return &LineChart{DefaultChartStyle(), width, height, categories, series, true}
+}
+
+// Draw() draws the chart at the left edge of frame, starting a new
+// page if it doesn't fit on the current one, and advances the frame
+// past it.
+func (c *LineChart) Draw(frame *Frame) error {
+	return drawChart(frame, c.Height, func() error {
+		_,err := newPlotArea(&c.ChartStyle, 0, 0, c.Width, c.Height, c.Categories, c.Series, false)
+		return err
+	}, c.DrawAt)
+}
+
+// DrawAt() draws the chart on page with its lower left corner at
+// (x, y).  Unlike a bar chart's, the value axis need not include
+// zero.
+func (c *LineChart) DrawAt(page *pdf.Page, x, y float64) error {
+	plot,err := newPlotArea(&c.ChartStyle, x, y, c.Width, c.Height, c.Categories, c.Series, false)
+	if err != nil {
+		return err
+	}
+	io.WriteString(page, "q\n")
+	plot.draw(page)
+	slot := plot.slot()
+	marker := 1.5 * c.LineWidth + 1
+	for i,series := range c.Series {
+		color := c.color(i, series.Color)
+		values := series.Values
+		if len(values) > len(c.Categories) {
+			values = values[:len(c.Categories)]
+		}
+		fmt.Fprintf(page, "%s RG %s w 1 j\n", formatReals(color.R, color.G, color.B), formatReals(c.LineWidth))
+		for j,v := range values {
+			operator := "l"
+			if j == 0 {
+				operator = "m"
+			}
+			fmt.Fprintf(page, "%s %s\n", formatReals(plot.left + (float64(j)+0.5)*slot, plot.y(v)), operator)
+		}
+		io.WriteString(page, "S\n")
+		if c.Markers {
+			setFill(page, color)
+			for j,v := range values {
+				fmt.Fprintf(page, "%s re\n", formatReals(plot.left + (float64(j)+0.5)*slot - marker, plot.y(v) - marker, 2*marker, 2*marker))
+			}
+			io.WriteString(page, "f\n")
+		}
+	}
+	io.WriteString(page, "Q\n")
+	return nil
+}
+
+// PieChart shows the shares of a total as the slices of a circle.
+type PieChart struct {
+	ChartStyle
+	Width, Height float64
+	// Labels holds the label of each slice, shown in the legend.
+	Labels []string
+	Values []float64
+	// Colors holds the color of each slice, or nil for the next
+	// color of the palette.
+	Colors []*Color
+}
+
+// NewPieChart() constructs a PieChart of the given size using the
+// default style.
+func NewPieChart(width, height float64, labels []string, values []float64) *PieChart {
+	return &PieChart{ChartStyle: DefaultChartStyle(), Width: width, Height: height, Labels: labels, Values: values}
+}
+
+// Draw() draws the chart at the left edge of frame, starting a new
+// page if it doesn't fit on the current one, and advances the frame
+// past it.
+func (c *PieChart) Draw(frame *Frame) error {
+	return drawChart(frame, c.Height, func() error {
+		_,err := c.layout(0, 0)
+		return err
+	}, c.DrawAt)
+}
+
+// DrawAt() draws the chart on page with its lower left corner at
+// (x, y).  The slices run clockwise from the top, and each label in
+// the legend is followed by its slice's percentage of the total.
+// Values that aren't positive are left out.
+func (c *PieChart) DrawAt(page *pdf.Page, x, y float64) error {
+	pie,err := c.layout(x, y)
+	if err != nil {
+		return err
+	}
+	io.WriteString(page, "q\n")
+	if pie.legend != nil {
+		pie.legend.draw(page)
+	}
+	// Slices are outlined in white so that they stand apart.
+	fmt.Fprintf(page, "1 1 1 RG %s w 1 j\n", formatReals(c.LineWidth))
+	cx, cy, radius := pie.cx, pie.cy, pie.radius
+	angle := math.Pi / 2
+	slice := 0
+	for _,v := range c.Values {
+		if v <= 0 {
+			continue
+		}
+		sweep := 2 * math.Pi * v / pie.total
+		setFill(page, pie.colors[slice])
+		// A slice that is the whole pie is a circle.
+		operator := "m"
+		if sweep < 2*math.Pi {
+			fmt.Fprintf(page, "%s m\n", formatReals(cx, cy))
+			operator = "l"
+		}
+		fmt.Fprintf(page, "%s %s\n", formatReals(cx + radius*math.Cos(angle), cy + radius*math.Sin(angle)), operator)
+		arc(page, cx, cy, radius, angle, angle - sweep)
+		io.WriteString(page, "h B\n")
+		angle -= sweep
+		slice++
+	}
+	io.WriteString(page, "Q\n")
+	return nil
+}
+
+// pieLayout is the position of a pie chart's circle and legend.
+type pieLayout struct {
+	total float64
+	colors []Color
+	legend *legend
+	cx, cy, radius float64
+}
+
+// layout() lays out the chart with its lower left corner at (x, y).
+func (c *PieChart) layout(x, y float64) (*pieLayout, error) {
+	pie := &pieLayout{}
+	for _,v := range c.Values {
+		if v > 0 {
+			pie.total += v
+		}
+	}
+	if pie.total == 0 {
+		return nil, noChartData
+	}
+
+	var names []string
+	for i,v := range c.Values {
+		if v <= 0 {
+			continue
+		}
+		label := ""
+		if i < len(c.Labels) {
+			label = c.Labels[i] + " "
+		}
+		var color *Color
+		if i < len(c.Colors) {
+			color = c.Colors[i]
+		}
+		names = append(names, label + strconv.FormatFloat(100*v/pie.total, 'f', 1, 64) + "%")
+		pie.colors = append(pie.colors, c.color(i, color))
+	}
+	bottom := y
+	if c.Legend {
+		pie.legend = newLegend(&c.ChartStyle, x, y, c.Width, names, pie.colors)
+		bottom += pie.legend.height()
+	}
+	pie.radius = math.Min(c.Width, y + c.Height - bottom) / 2
+	if pie.radius <= 0 {
+		return nil, chartTooSmall
+	}
+	pie.cx, pie.cy = x + c.Width/2, (bottom + y + c.Height) / 2
+	return pie, nil
+}
+
+// drawChart() draws a chart of the given height in frame with draw().
+// The layout of a chart doesn't depend on its position, so check()
+// lays it out at the origin to find errors before a new page is
+// started for it.
+func drawChart(frame *Frame, height float64, check func() error, draw func(page *pdf.Page, x, y float64) error) error {
+	if err := check(); err != nil {
+		return err
+	}
+	frame.Reserve(height)
+	if err := draw(frame.Page(), frame.Left(), frame.Y() - height); err != nil {
+		return err
+	}
+	frame.Advance(height)
+	return nil
+}
+
+// arc() adds curves approximating the arc of a circle from angle
+// start to angle end, in radians, to the current path, which must end
+// at the start of the arc.
+func arc(w io.Writer, cx, cy, radius, start, end float64) {
+	segments := int(math.Ceil(math.Abs(end-start) / (math.Pi/2)))
+	step := (end - start) / float64(segments)
+	k := 4.0 / 3 * math.Tan(step/4) * radius
+	for i:=0; i<segments; i++ {
+		a1 := start + float64(i)*step
+		a2 := a1 + step
+		fmt.Fprintf(w, "%s c\n", formatReals(
+			cx + radius*math.Cos(a1) - k*math.Sin(a1), cy + radius*math.Sin(a1) + k*math.Cos(a1),
+			cx + radius*math.Cos(a2) + k*math.Sin(a2), cy + radius*math.Sin(a2) - k*math.Cos(a2),
+			cx + radius*math.Cos(a2), cy + radius*math.Sin(a2)))
+	}
+}
+
+// plotArea is the rectangle of an axis chart within which values are
+// plotted, together with the range of values of its vertical axis and
+// the labels and legend around it.
+type plotArea struct {
+	left, bottom, width, height float64
+	low, high float64
+	style *ChartStyle
+	ticks []float64
+	tickLabels []string
+	categories []string
+	// labelBaseline is the baseline of the category labels.
+	labelBaseline float64
+	legend *legend
+}
+
+// newPlotArea() lays out the axes, labels, and legend of an axis chart
+// with its lower left corner at (x, y).  Nothing is drawn, so that an
+// error is returned before the chart is started.
+func newPlotArea(style *ChartStyle, x, y, width, height float64, categories []string, series []Series, includeZero bool) (*plotArea, error) {
+	low, high := math.Inf(1), math.Inf(-1)
+	for _,s := range series {
+		for j,v := range s.Values {
+			if j < len(categories) {
+				low, high = math.Min(low, v), math.Max(high, v)
+			}
+		}
+	}
+	if len(categories) == 0 || math.IsInf(low, 1) {
+		return nil, noChartData
+	}
+	if includeZero {
+		low, high = math.Min(low, 0), math.Max(high, 0)
+	}
+	ticks, decimals := axisTicks(low, high)
+
+	labels := make([]string, len(ticks))
+	labelWidth := 0.0
+	for i,v := range ticks {
+		labels[i] = strconv.FormatFloat(v, 'f', decimals, 64)
+		labelWidth = math.Max(labelWidth, style.Font.Width(labels[i], style.FontSize))
+	}
+
+	bottom := y
+	var l *legend
+	if style.Legend {
+		names := make([]string, len(series))
+		colors := make([]Color, len(series))
+		for i,s := range series {
+			names[i], colors[i] = s.Name, style.color(i, s.Color)
+		}
+		l = newLegend(style, x, y, width, names, colors)
+		bottom += l.height()
+	}
+	ascent := style.Font.Ascent(style.FontSize)
+	plot := &plotArea{
+		left: x + labelWidth + style.FontSize/2,
+		// Room is left for the category labels below and half
+		// of the top value's label above.
+		bottom: bottom + ascent + style.FontSize/2,
+		low: ticks[0],
+		high: ticks[len(ticks)-1],
+		style: style,
+		ticks: ticks,
+		tickLabels: labels,
+		categories: categories,
+		labelBaseline: bottom,
+		legend: l }
+	plot.width = x + width - plot.left
+	plot.height = y + height - ascent/2 - plot.bottom
+	if plot.width <= 0 || plot.height <= 0 {
+		return nil, chartTooSmall
+	}
+	return plot, nil
+}
+
+// y() returns the vertical position of v.
+func (p *plotArea) y(v float64) float64 {
+	return p.bottom + (v - p.low) / (p.high - p.low) * p.height
+}
+
+// slot() returns the width of the space given to each category.
+func (p *plotArea) slot() float64 {
+	return p.width / float64(len(p.categories))
+}
+
+// draw() draws the legend, the axes, and their labels.
+func (p *plotArea) draw(page *pdf.Page) {
+	style := p.style
+	if p.legend != nil {
+		p.legend.draw(page)
+	}
+	io.WriteString(page, "0 g\n")
+	ascent := style.Font.Ascent(style.FontSize)
+	for i,v := range p.ticks {
+		ty := p.y(v)
+		showText(page, style.Font, style.FontSize, p.left - style.FontSize/2 - style.Font.Width(p.tickLabels[i], style.FontSize),
+			ty - ascent/2, p.tickLabels[i])
+		if style.GridLines && i > 0 {
+			fmt.Fprintf(page, "0.85 G %s w %s m %s l S\n", formatReals(style.LineWidth/2),
+				formatReals(p.left, ty), formatReals(p.left + p.width, ty))
+		}
+	}
+	for i,label := range p.categories {
+		center := p.left + (float64(i)+0.5)*p.slot()
+		showText(page, style.Font, style.FontSize, center - style.Font.Width(label, style.FontSize)/2, p.labelBaseline, label)
+	}
+	fmt.Fprintf(page, "0 G %s w %s m %s l %s l S\n", formatReals(style.LineWidth),
+		formatReals(p.left, p.bottom + p.height), formatReals(p.left, p.bottom),
+		formatReals(p.left + p.width, p.bottom))
+}
+
+// drawZero() draws a line across the plot area at zero, if it is
+// within the range of the axis.
+func (p *plotArea) drawZero(page *pdf.Page) {
+	if p.low <= 0 && p.high >= 0 {
+		fmt.Fprintf(page, "0 G %s w %s m %s l S\n", formatReals(p.style.LineWidth),
+			formatReals(p.left, p.y(0)), formatReals(p.left + p.width, p.y(0)))
+	}
+}
+
+// axisTicks() returns evenly spaced values, at multiples of 1, 2, or
+// 5 times a power of ten, whose range includes low through high, and
+// the number of decimals needed to show them.
+func axisTicks(low, high float64) ([]float64, int) {
+	if low == high {
+		low, high = low - 1, high + 1
+	}
+	// About five intervals are wanted.
+	raw := (high - low) / 5
+	magnitude := math.Pow(10, math.Floor(math.Log10(raw)))
+	step := 10 * magnitude
+	for _,m := range []float64{1, 2, 5} {
+		if m*magnitude >= raw {
+			step = m * magnitude
+			break
+		}
+	}
+	decimals := int(math.Max(0, -math.Floor(math.Log10(step) + 1e-9)))
+	scale := math.Pow(10, float64(decimals))
+	var ticks []float64
+	for i := math.Floor(low/step); ; i++ {
+		// Rounding removes the error of multiples such as 3*0.1.
+		v := math.Round(i*step*scale) / scale
+		ticks = append(ticks, v)
+		if v >= high {
+			break
+		}
+	}
+	return ticks, decimals
+}
+
+// legend lists names, each after a swatch of its color, in rows
+// along the bottom of a chart.
+type legend struct {
+	style *ChartStyle
+	y float64
+	names []string
+	colors []Color
+	// x and row hold the position of each item.
+	x []float64
+	row []int
+	rows int
+}
+
+// newLegend() lays out a legend at the bottom of the chart with its
+// lower left corner at (x, y).
+func newLegend(style *ChartStyle, x, y, width float64, names []string, colors []Color) *legend {
+	l := &legend{style: style, y: y, names: names, colors: colors}
+	size := style.FontSize
+	itemX, row := x, 0
+	for _,name := range names {
+		itemWidth := 1.5*size + style.Font.Width(name, size)
+		if itemX > x && itemX + itemWidth > x + width {
+			itemX, row = x, row+1
+		}
+		l.x = append(l.x, itemX)
+		l.row = append(l.row, row)
+		itemX += itemWidth + size
+	}
+	l.rows = row + 1
+	return l
+}
+
+// height() returns the height of the legend, including the space
+// separating it from the chart.
+func (l *legend) height() float64 {
+	return float64(l.rows)*1.5*l.style.FontSize + l.style.FontSize/2
+}
+
+func (l *legend) draw(page *pdf.Page) {
+	size := l.style.FontSize
+	for i,name := range l.names {
+		baseline := l.y + float64(l.rows - 1 - l.row[i])*1.5*size + size/2
+		setFill(page, l.colors[i])
+		fmt.Fprintf(page, "%s re f\n", formatReals(l.x[i], baseline, 0.8*size, 0.8*size))
+		io.WriteString(page, "0 g\n")
+		showText(page, l.style.Font, size, l.x[i] + 1.2*size, baseline, name)
+	}
+}
+
+func setFill(w io.Writer, c Color) {
+	fmt.Fprintf(w, "%s rg\n", formatReals(c.R, c.G, c.B))
+}
+
+// formatReals() formats values for a content stream, separated by
+// spaces.
+func formatReals(values ...float64) string {
+	formatted := make([]string, len(values))
+	for i,v := range values {
+		formatted[i] = pdf.FormatReal(v, precision)
+	}
+	return strings.Join(formatted, " ")
+}
@@ -0,0 +1,101 @@
+package layout
+
+import (
+	"github.com/mawicks/PDFiG/pdf" )
+
+// Frame is the rectangle of each page within which content flows from
+// top to bottom.  When content doesn't fit in the rest of the frame, a
+// new page is started.
+type Frame struct {
+	document *pdf.Document
+	left, bottom, right, top float64
+	page *pdf.Page
+	pageCount int
+	// y is the top of the space remaining on the current page.
+	y float64
+	// OnNewPage, if not nil, is called with each page the frame
+	// starts and its number within the frame, counting from 1,
+	// before any content is added to it.
+	OnNewPage func(page *pdf.Page, number int)
+}
+
+// NewFrame() constructs a Frame bounded by the given coordinates on
+// each page of document.  The first page is started when content is
+// first added.
+func NewFrame(document *pdf.Document, left, bottom, right, top float64) *Frame {
+	return &Frame{document: document, left: left, bottom: bottom, right: right, top: top}
+}
+
+// Page() returns the current page, starting the first page if
+// necessary.
+func (f *Frame) Page() *pdf.Page {
+	if f.page == nil {
+		f.NewPage()
+	}
+	return f.page
+}
+
+// NewPage() starts a new page.
+func (f *Frame) NewPage() {
+	f.page = f.document.NewPage()
+	f.pageCount++
+	f.y = f.top
+	if f.OnNewPage != nil {
+		f.OnNewPage(f.page, f.pageCount)
+	}
+}
+
+// PageCount() returns the number of pages the frame has started.
+func (f *Frame) PageCount() int {
+	return f.pageCount
+}
+
+// Left() and Right() return the horizontal bounds of the frame.
+func (f *Frame) Left() float64 {
+	return f.left
+}
+
+func (f *Frame) Right() float64 {
+	return f.right
+}
+
+// Width() returns the width of the frame.
+func (f *Frame) Width() float64 {
+	return f.right - f.left
+}
+
+// Height() returns the height of the frame on an empty page.
+func (f *Frame) Height() float64 {
+	return f.top - f.bottom
+}
+
+// Y() returns the top of the space remaining on the current page.
+func (f *Frame) Y() float64 {
+	f.Page()
+	return f.y
+}
+
+// Remaining() returns the height of the space remaining on the
+// current page.
+func (f *Frame) Remaining() float64 {
+	return f.Y() - f.bottom
+}
+
+// AtTop() returns true if nothing has been added to the current page.
+func (f *Frame) AtTop() bool {
+	return f.Y() == f.top
+}
+
+// Advance() moves down by height after content has been added.
+func (f *Frame) Advance(height float64) {
+	f.Page()
+	f.y -= height
+}
+
+// Reserve() starts a new page if height doesn't fit in the space
+// remaining on the current page, unless the page is empty.
+func (f *Frame) Reserve(height float64) {
+	if height > f.Remaining() && !f.AtTop() {
+		f.NewPage()
+	}
+}
@@ -0,0 +1,71 @@
+package layout
+
+import (
+	"fmt"
+	"image"
+	"github.com/mawicks/PDFiG/pdf" )
+
+// Image is a raster image drawn in a Frame.
+type Image struct {
+	xobject pdf.Stream
+	// references holds the image XObject written to each
+	// document, so that it is written once however often it is
+	// drawn.
+	references map[*pdf.Document]pdf.Indirect
+	// Width and Height are the size at which the image is drawn,
+	// which is reduced if necessary to fit the frame.
+	Width, Height float64
+}
+
+// NewImage() constructs an Image from img, drawn at 72 pixels per
+// inch.  Transparency is ignored.
+func NewImage(img image.Image) *Image {
+	bounds := img.Bounds()
+	samples := make([]byte, 0, 3*bounds.Dx()*bounds.Dy())
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			r,g,b,_ := img.At(x, y).RGBA()
+			samples = append(samples, byte(r>>8), byte(g>>8), byte(b>>8))
+		}
+	}
+
+	xobject := pdf.NewStream()
+	xobject.Add("Type", pdf.NewName("XObject"))
+	xobject.Add("Subtype", pdf.NewName("Image"))
+	xobject.Add("Width", pdf.NewIntNumeric(bounds.Dx()))
+	xobject.Add("Height", pdf.NewIntNumeric(bounds.Dy()))
+	xobject.Add("ColorSpace", pdf.NewName("DeviceRGB"))
+	xobject.Add("BitsPerComponent", pdf.NewIntNumeric(8))
+	xobject.AddFilter(new(pdf.FlateFilter))
+	xobject.Write(samples)
+	return &Image{
+		xobject: xobject,
+		references: make(map[*pdf.Document]pdf.Indirect),
+		Width: float64(bounds.Dx()),
+		Height: float64(bounds.Dy()) }
+}
+
+// Draw() draws the image at the left edge of frame, starting a new
+// page if it doesn't fit on the current one, and advances the frame
+// past it.  An image wider or taller than the frame is scaled down.
+func (i *Image) Draw(frame *Frame) {
+	width, height := i.Width, i.Height
+	if width > frame.Width() {
+		width, height = frame.Width(), height*frame.Width()/width
+	}
+	if height > frame.Height() {
+		width, height = width*frame.Height()/height, frame.Height()
+	}
+	frame.Reserve(height)
+	reference,exists := i.references[frame.document]
+	if !exists {
+		reference = frame.document.WriteObject(i.xobject)
+		i.references[frame.document] = reference
+	}
+	page := frame.Page()
+	fmt.Fprintf(page, "q %s 0 0 %s %s %s cm /%s Do Q\n",
+		pdf.FormatReal(width, precision), pdf.FormatReal(height, precision),
+		pdf.FormatReal(frame.Left(), precision), pdf.FormatReal(frame.Y()-height, precision),
+		page.AddXObject(reference))
+	frame.Advance(height)
+}
@@ -0,0 +1,125 @@
+/*
+	Package layout arranges text, tables, images, and charts on the
+	pages of a pdf.Document.  Content flows through a Frame, which
+	starts new pages as they fill.
+*/
+package layout
+
+import (
+	"fmt"
+	"io"
+	"github.com/mawicks/PDFiG/pdf" )
+
+// precision is the number of digits after the decimal point used for
+// coordinates.
+const precision = 3
+
+// Alignment is the horizontal alignment of text within its box.
+type Alignment int
+
+const (
+	AlignLeft Alignment = iota
+	AlignCenter
+	AlignRight
+	// AlignJustify stretches the spaces of each line but the last
+	// of a paragraph to fill the line.  It applies to paragraphs;
+	// elsewhere, justified text is aligned left.
+	AlignJustify )
+
+// Font is a font together with the metrics needed to lay out text in
+// it.
+type Font interface {
+	// Resource() returns the font to add to a page's resources.
+	Resource() pdf.Font
+	// Width() returns the width of text in text space.
+	Width(text string, size float64) float64
+	// Show() returns an operation that shows text.
+	Show(text string, size float64) pdf.Operation
+	// Ascent() returns the height of the font's ascenders above
+	// the baseline.
+	Ascent(size float64) float64
+}
+
+type standardFont struct {
+	font pdf.Font
+	metrics *pdf.FontMetrics
+}
+
+// StandardFont() returns one of the standard 14 fonts.  Characters
+// outside the font's built-in encoding are shown as question marks.
+func StandardFont(font pdf.StandardFont) Font {
+	return &standardFont{pdf.NewStandardFont(font), pdf.StandardFontMetrics(font)}
+}
+
+func (f *standardFont) Resource() pdf.Font {
+	return f.font
+}
+
+func (f *standardFont) Width(text string, size float64) float64 {
+	return f.metrics.StringWidth(f.encodable(text), size)
+}
+
+func (f *standardFont) Show(text string, size float64) pdf.Operation {
+	op,_,_ := f.metrics.ShowText(f.encodable(text), size, true)
+	return op
+}
+
+func (f *standardFont) Ascent(size float64) float64 {
+	return f.metrics.Ascender * size / 1000
+}
+
+// encodable() replaces the characters the font can't show.
+func (f *standardFont) encodable(text string) string {
+	result := []rune(text)
+	for i,r := range result {
+		if _,_,err := f.metrics.ShowText(string(r), 1, false); err != nil {
+			result[i] = '?'
+		}
+	}
+	return string(result)
+}
+
+type openTypeFont struct {
+	*pdf.OpenTypeFont
+}
+
+// OpenTypeFont() returns an embedded OpenType font.
+func OpenTypeFont(font *pdf.OpenTypeFont) Font {
+	return openTypeFont{font}
+}
+
+func (f openTypeFont) Resource() pdf.Font {
+	// The OpenTypeFont itself is returned so that the document
+	// finishes it when it is closed.
+	return f.OpenTypeFont
+}
+
+func (f openTypeFont) Width(text string, size float64) float64 {
+	return f.StringWidth(text, size)
+}
+
+func (f openTypeFont) Show(text string, size float64) pdf.Operation {
+	op,_ := f.ShowText(text, size)
+	return op
+}
+
+// showText() writes a text object showing text with its baseline
+// starting at (x, y).
+func showText(page *pdf.Page, font Font, size float64, x, y float64, text string) {
+	fmt.Fprintf(page, "BT /%s %s Tf %s %s Td ", page.AddFont(font.Resource()),
+		pdf.FormatReal(size, precision), pdf.FormatReal(x, precision), pdf.FormatReal(y, precision))
+	pdf.NewContentSerializer(page).Write(font.Show(text, size))
+	io.WriteString(page, " ET\n")
+}
+
+// alignedX() returns the position at which text of the given width
+// starts when aligned within the box from left to right.
+func alignedX(alignment Alignment, left, right, width float64) float64 {
+	switch alignment {
+	case AlignCenter:
+		return (left + right - width) / 2
+	case AlignRight:
+		return right - width
+	}
+	return left
+}
@@ -0,0 +1,282 @@
+package layout
+
+import (
+	"errors"
+	"image"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"github.com/mawicks/PDFiG/pdf" )
+
+func TestWrapText(t *testing.T) {
+	font := StandardFont(pdf.Helvetica)
+	// At size 10, "quick brown" is 52.8 points wide.
+	lines := wrapText(font, 10, "The  quick brown fox\n\njumps", 55)
+	expected := []string{"The quick", "brown fox", "", "jumps"}
+	if strings.Join(lines, "|") != strings.Join(expected, "|") {
+		t.Errorf(`wrapText() returned %q; expected %q`, lines, expected)
+	}
+
+	for _,line := range wrapText(font, 10, "abcdefghijklmnopqrstuvwxyz", 30) {
+		if font.Width(line, 10) > 30 || line == "" {
+			t.Errorf(`wrapText() broke a word into %q`, line)
+		}
+	}
+	if lines := wrapText(font, 10, "WW", 1); len(lines) != 2 {
+		t.Errorf(`wrapText() returned %q for characters wider than the line`, lines)
+	}
+}
+
+func TestTable(t *testing.T) {
+	filename := "/tmp/test-table.pdf"
+	os.Remove(filename)
+
+	doc := pdf.OpenDocument(filename, os.O_RDWR|os.O_CREATE)
+	frame := NewFrame(doc, 72, 72, 540, 720)
+	table := NewTable(StandardFont(pdf.Helvetica), 10, 100, 268, 100)
+	table.HeaderFont = StandardFont(pdf.HelveticaBold)
+	table.HeaderRows = 1
+	table.Alignments = []Alignment{AlignLeft, AlignLeft, AlignRight}
+	table.AddRow("Item", "Description", "Amount")
+	for i:=1; i<=60; i++ {
+		description := "Widget"
+		if i%10 == 0 {
+			description = "A widget with a description long enough to wrap onto a second line of the cell"
+		}
+		table.AddRow("Item "+strconv.Itoa(i), description, strconv.Itoa(i*100))
+	}
+	if err := table.Draw(frame); err != nil {
+		t.Fatalf(`Draw() failed: %v`, err)
+	}
+	pages := frame.PageCount()
+	if pages < 2 {
+		t.Errorf(`Table occupies %d pages; expected it to break`, pages)
+	}
+
+	tall := NewTable(StandardFont(pdf.Helvetica), 10, 20)
+	tall.AddRow(strings.Repeat("x ", 500))
+	if err := tall.Draw(frame); err == nil {
+		t.Error(`Draw() accepted a row taller than the frame`)
+	}
+	doc.Close()
+
+	doc = pdf.OpenDocument(filename, os.O_RDWR)
+	defer doc.Close()
+	for n:=0; n<pages; n++ {
+		text := doc.Page(uint(n)).Text()
+		if len(text.Lines) == 0 || !strings.HasPrefix(text.Lines[0].String(), "Item Description Amount") {
+			t.Errorf(`Page %d doesn't begin with the header: %q`, n, text.String())
+		}
+	}
+	if !strings.Contains(doc.Page(uint(pages-1)).Text().String(), "Item 60") {
+		t.Error(`Last row is missing from the last page`)
+	}
+}
+
+func TestParagraph(t *testing.T) {
+	filename := "/tmp/test-paragraph.pdf"
+	os.Remove(filename)
+
+	regular := StandardFont(pdf.Helvetica)
+	bold := StandardFont(pdf.HelveticaBold)
+	// A span change within a word doesn't break the word.
+	p := NewParagraph().Add("This is ", regular, 10).Add("bold", bold, 10).Add("ly set.\nNext", regular, 10)
+	lines := p.lines(500)
+	if len(lines) != 2 || len(lines[0].words) != 4 || len(lines[0].words[2].fragments) != 2 || !lines[0].last {
+		t.Errorf(`lines() returned %+v`, lines)
+	}
+
+	doc := pdf.OpenDocument(filename, os.O_RDWR|os.O_CREATE)
+	frame := NewFrame(doc, 72, 600, 272, 720)
+	justified := NewParagraph()
+	justified.Alignment = AlignJustify
+	justified.Add(strings.Repeat("The quick brown fox jumps over the lazy dog. ", 20), regular, 10)
+	justified.Draw(frame)
+	if frame.PageCount() < 2 {
+		t.Errorf(`Paragraph occupies %d pages; expected it to break`, frame.PageCount())
+	}
+	doc.Close()
+
+	doc = pdf.OpenDocument(filename, os.O_RDWR)
+	defer doc.Close()
+	// Extracted widths are estimates for fonts without /Widths,
+	// so the ends of lines are found from the font's metrics.
+	all := ""
+	for n:=0; n<frame.PageCount(); n++ {
+		text := doc.Page(uint(n)).Text()
+		all += text.String() + "\n"
+		for i,line := range text.Lines {
+			last := line.Words[len(line.Words)-1]
+			final := n == frame.PageCount()-1 && i == len(text.Lines)-1
+			if right := last.X + regular.Width(last.Text, 10); !final && math.Abs(right-272) > 0.01 {
+				t.Errorf(`Justified line %q on page %d ends at %g`, line.String(), n, right)
+			}
+		}
+	}
+	if count := strings.Count(all, "quick"); count != 20 {
+		t.Errorf(`Paragraph has %d sentences; expected 20`, count)
+	}
+}
+
+func TestMarkupSpans(t *testing.T) {
+	r := &markupRenderer{style: DefaultMarkupStyle()}
+	tests := []struct {
+		text string
+		expected []string
+	}{
+		{"plain **bold** and *italic*", []string{"plain |R", "bold|B", " and |R", "italic|I"}},
+		{"***both*** `a*b` snake_case_name", []string{"both|BI", " |R", "a*b|C", " snake_case_name|R"}},
+		{"2 * 3 * 4 and \\*not\\* unclosed **", []string{"2 * 3 * 4 and *not* unclosed **|R"}},
+	}
+	names := map[Font]string{r.style.Regular: "R", r.style.Bold: "B", r.style.Italic: "I", r.style.BoldItalic: "BI", r.style.Code: "C"}
+	for _,test := range tests {
+		var spans []string
+		for _,span := range r.spans(test.text, 10, false) {
+			spans = append(spans, span.Text+"|"+names[span.Font])
+		}
+		if strings.Join(spans, ",") != strings.Join(test.expected, ",") {
+			t.Errorf(`spans(%q) returned %q; expected %q`, test.text, spans, test.expected)
+		}
+	}
+}
+
+func TestRenderMarkup(t *testing.T) {
+	filename := "/tmp/test-markup.pdf"
+	os.Remove(filename)
+
+	style := DefaultMarkupStyle()
+	style.LoadImage = func(source string) (image.Image, error) {
+		if source != "logo.png" {
+			return nil, errors.New("no such image")
+		}
+		return image.NewRGBA(image.Rect(0, 0, 40, 20)), nil
+	}
+	source := `# Quarterly Report
+
+This quarter was **very** good,
+with *strong* growth.
+
+- First item
+- Second item
+  continued
+  1. Nested
+
+| Region | Sales |
+|--------|------:|
+| North  | 1,200 |
+| South  | 900   |
+
+![Logo](logo.png)
+`
+	doc := pdf.OpenDocument(filename, os.O_RDWR|os.O_CREATE)
+	frame := NewFrame(doc, 72, 72, 540, 720)
+	if err := RenderMarkup(frame, source, style); err != nil {
+		t.Fatalf(`RenderMarkup() failed: %v`, err)
+	}
+	if err := RenderMarkup(frame, "![Missing](missing.png)", style); err == nil {
+		t.Error(`RenderMarkup() ignored a missing image`)
+	}
+	doc.Close()
+
+	doc = pdf.OpenDocument(filename, os.O_RDWR)
+	defer doc.Close()
+	page := doc.Page(0)
+	// Extraction estimates the widths of standard fonts, so the
+	// gaps between words aren't reliable.
+	text := strings.Replace(page.Text().String(), " ", "", -1)
+	for _,expected := range []string{"Quarterly Report", "This quarter was very good, with strong growth.",
+		"- First item", "- Second item continued", "1. Nested", "Region Sales", "North 1,200"} {
+		if !strings.Contains(text, strings.Replace(expected, " ", "", -1)) {
+			t.Errorf(`Rendered text doesn't contain %q:\n%s`, expected, text)
+		}
+	}
+	if images := page.Images(); len(images) != 1 || images[0].Width() != 40 {
+		t.Errorf(`Rendered page has images %v`, images)
+	}
+}
+
+func TestAxisTicks(t *testing.T) {
+	tests := []struct {
+		low, high float64
+		expected string
+		decimals int
+	}{
+		{0, 97, "0 20 40 60 80 100", 0},
+		{-3, 12, "-5 0 5 10 15", 0},
+		{0.12, 0.57, "0.1 0.2 0.3 0.4 0.5 0.6", 1},
+		{5, 5, "4 4.5 5 5.5 6", 1},
+	}
+	for _,test := range tests {
+		ticks,decimals := axisTicks(test.low, test.high)
+		var labels []string
+		for _,v := range ticks {
+			labels = append(labels, strconv.FormatFloat(v, 'f', -1, 64))
+		}
+		if s := strings.Join(labels, " "); s != test.expected || decimals != test.decimals {
+			t.Errorf(`axisTicks(%g, %g) returned "%s" with %d decimals; expected "%s" with %d`,
+				test.low, test.high, s, decimals, test.expected, test.decimals)
+		}
+	}
+}
+
+func TestCharts(t *testing.T) {
+	filename := "/tmp/test-charts.pdf"
+	os.Remove(filename)
+
+	doc := pdf.OpenDocument(filename, os.O_RDWR|os.O_CREATE)
+	frame := NewFrame(doc, 72, 72, 540, 720)
+	quarters := []string{"Q1", "Q2", "Q3", "Q4"}
+	red := &Color{1, 0, 0}
+	bars := NewBarChart(300, 200, quarters,
+		Series{Name: "North", Values: []float64{12, 19, -4, 25}},
+		Series{Name: "South", Values: []float64{8, 11, 15}, Color: red})
+	if err := bars.Draw(frame); err != nil {
+		t.Fatalf(`BarChart.Draw() failed: %v`, err)
+	}
+	lines := NewLineChart(300, 200, quarters, Series{Name: "Revenue", Values: []float64{1.5, 1.75, 1.6, 2.1}})
+	if err := lines.Draw(frame); err != nil {
+		t.Fatalf(`LineChart.Draw() failed: %v`, err)
+	}
+	if frame.PageCount() != 1 {
+		t.Errorf(`Charts occupy %d pages; expected 1`, frame.PageCount())
+	}
+	pie := NewPieChart(200, 300, []string{"Rent", "Food", "Other"}, []float64{50, 30, 20})
+	if err := pie.Draw(frame); err != nil {
+		t.Fatalf(`PieChart.Draw() failed: %v`, err)
+	}
+	if frame.PageCount() != 2 {
+		t.Errorf(`Pie chart didn't start a new page`)
+	}
+
+	y := frame.Y()
+	if err := NewBarChart(300, 100, nil).Draw(frame); err != noChartData {
+		t.Errorf(`Empty bar chart returned %v`, err)
+	}
+	if err := NewPieChart(100, 100, nil, []float64{0, -1}).Draw(frame); err != noChartData {
+		t.Errorf(`Empty pie chart returned %v`, err)
+	}
+	if err := NewLineChart(20, 20, quarters, Series{Values: []float64{1000000}}).Draw(frame); err != chartTooSmall {
+		t.Errorf(`Tiny line chart returned %v`, err)
+	}
+	if frame.Y() != y {
+		t.Errorf(`Charts that failed advanced the frame`)
+	}
+	doc.Close()
+
+	doc = pdf.OpenDocument(filename, os.O_RDWR)
+	defer doc.Close()
+	text := strings.Replace(doc.Page(0).Text().String(), " ", "", -1)
+	for _,label := range []string{"Q1", "Q4", "North", "South", "Revenue", "-10", "30", "2.2"} {
+		if !strings.Contains(text, label) {
+			t.Errorf(`First page lacks label "%s": %q`, label, text)
+		}
+	}
+	text = strings.Replace(doc.Page(1).Text().String(), " ", "", -1)
+	for _,label := range []string{"Rent50.0%", "Food30.0%", "Other20.0%"} {
+		if !strings.Contains(text, label) {
+			t.Errorf(`Second page lacks legend "%s": %q`, label, text)
+		}
+	}
+}
@@ -0,0 +1,327 @@
+package layout
+
+import (
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+	"os"
+	"regexp"
+	"strings"
+	"github.com/mawicks/PDFiG/pdf" )
+
+// MarkupStyle holds the fonts and sizes used by RenderMarkup().
+type MarkupStyle struct {
+	Regular, Bold, Italic, BoldItalic Font
+	// Code is the font for `code` spans.
+	Code Font
+	// Size is the size of body text.  Headings are larger.
+	Size float64
+	// Bullet marks the items of unordered lists.
+	Bullet string
+	// ListIndent is the indentation of each level of list.
+	ListIndent float64
+	// LoadImage() returns the image for the source given in an
+	// image reference.  If it is nil, the source is a file name.
+	LoadImage func(source string) (image.Image, error)
+}
+
+// headingScales are the sizes of headings of levels 1 through 6
+// relative to body text.
+var headingScales = []float64{2, 1.5, 1.25, 1.1, 1, 0.9}
+
+// DefaultMarkupStyle() returns a style using the Helvetica family and
+// Courier at 11 points.
+func DefaultMarkupStyle() *MarkupStyle {
+	return &MarkupStyle{
+		Regular: StandardFont(pdf.Helvetica),
+		Bold: StandardFont(pdf.HelveticaBold),
+		Italic: StandardFont(pdf.HelveticaOblique),
+		BoldItalic: StandardFont(pdf.HelveticaBoldOblique),
+		Code: StandardFont(pdf.Courier),
+		Size: 11,
+		// The standard fonts' metrics don't include a bullet
+		// character.
+		Bullet: "-",
+		ListIndent: 18 }
+}
+
+var (
+	headingPattern = regexp.MustCompile(`^(#{1,6})\s+(.*?)\s*#*\s*$`)
+	imagePattern = regexp.MustCompile(`^!\[([^\]]*)\]\(([^)\s]+)\)\s*$`)
+	listItemPattern = regexp.MustCompile(`^(\s*)([-*+]|\d+[.)])\s+(.*)$`)
+	tableSeparatorPattern = regexp.MustCompile(`^\s*\|?\s*:?-+:?\s*(\|\s*:?-+:?\s*)*\|?\s*$`) )
+
+// RenderMarkup() draws a document written in a subset of Markdown in
+// frame.  The subset comprises:
+//
+//	# Headings, with one to six #'s
+//	Paragraphs, separated by blank lines
+//	**bold**, *italic* or _italic_, ***bold italic***, and `code`
+//	- Unordered and 1. ordered list items, nested by indentation
+//	| Simple | tables |, whose second line is a |---|:--:| separator
+//	![Images](source), on a line by themselves
+//
+// Other Markdown is shown as plain text.  Formatting within table
+// cells is removed.  If style is nil, DefaultMarkupStyle() is used.
+func RenderMarkup(frame *Frame, source string, style *MarkupStyle) error {
+	if style == nil {
+		style = DefaultMarkupStyle()
+	}
+	r := &markupRenderer{frame: frame, style: style}
+	lines := strings.Split(strings.Replace(source, "\r\n", "\n", -1), "\n")
+	for i:=0; i<len(lines); {
+		line := lines[i]
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case trimmed == "":
+			r.flushParagraph()
+			i++
+		case headingPattern.MatchString(trimmed):
+			r.flushParagraph()
+			m := headingPattern.FindStringSubmatch(trimmed)
+			r.heading(len(m[1]), m[2])
+			i++
+		case imagePattern.MatchString(trimmed):
+			r.flushParagraph()
+			if err := r.image(imagePattern.FindStringSubmatch(trimmed)[2]); err != nil {
+				return err
+			}
+			i++
+		case strings.HasPrefix(trimmed, "|") && i+1 < len(lines) && tableSeparatorPattern.MatchString(lines[i+1]):
+			r.flushParagraph()
+			end := i + 2
+			for end < len(lines) && strings.HasPrefix(strings.TrimSpace(lines[end]), "|") {
+				end++
+			}
+			if err := r.table(lines[i], lines[i+1], lines[i+2:end]); err != nil {
+				return err
+			}
+			i = end
+		case listItemPattern.MatchString(line):
+			r.flushParagraph()
+			m := listItemPattern.FindStringSubmatch(line)
+			text := m[3]
+			// Indented lines that don't start items continue
+			// the item.
+			i++
+			for i < len(lines) && strings.TrimSpace(lines[i]) != "" && !listItemPattern.MatchString(lines[i]) &&
+				(lines[i][0] == ' ' || lines[i][0] == '\t') {
+				text += " " + strings.TrimSpace(lines[i])
+				i++
+			}
+			r.listItem(listLevel(m[1]), m[2], text)
+		default:
+			r.paragraph = append(r.paragraph, trimmed)
+			i++
+		}
+	}
+	r.flushParagraph()
+	return nil
+}
+
+// listLevel() returns the nesting level of a list item indented by
+// indentation, counting a tab as four spaces and two spaces per level.
+func listLevel(indentation string) int {
+	return len(strings.Replace(indentation, "\t", "    ", -1)) / 2
+}
+
+type markupRenderer struct {
+	frame *Frame
+	style *MarkupStyle
+	// paragraph holds the lines of the paragraph being read.
+	paragraph []string
+}
+
+func (r *markupRenderer) flushParagraph() {
+	if len(r.paragraph) == 0 {
+		return
+	}
+	p := NewParagraph(r.spans(strings.Join(r.paragraph, " "), r.style.Size, false)...)
+	p.SpaceAfter = 0.6 * r.style.Size
+	p.Draw(r.frame)
+	r.paragraph = nil
+}
+
+func (r *markupRenderer) heading(level int, text string) {
+	size := headingScales[level-1] * r.style.Size
+	p := NewParagraph(r.spans(text, size, true)...)
+	p.SpaceBefore = 0.8 * size
+	p.SpaceAfter = 0.4 * size
+	p.Draw(r.frame)
+}
+
+func (r *markupRenderer) listItem(level int, marker, text string) {
+	if marker == "-" || marker == "*" || marker == "+" {
+		marker = r.style.Bullet
+	}
+	marker += " "
+	// The marker hangs to the left of the item's text.
+	hang := r.style.Regular.Width(marker, r.style.Size)
+	p := NewParagraph(Span{marker, r.style.Regular, r.style.Size})
+	p.Spans = append(p.Spans, r.spans(text, r.style.Size, false)...)
+	p.Indent = float64(level+1) * r.style.ListIndent
+	p.FirstLineIndent = -hang
+	p.SpaceAfter = 0.2 * r.style.Size
+	p.Draw(r.frame)
+}
+
+func (r *markupRenderer) image(source string) error {
+	load := r.style.LoadImage
+	if load == nil {
+		load = loadImageFile
+	}
+	img,err := load(source)
+	if err != nil {
+		return err
+	}
+	NewImage(img).Draw(r.frame)
+	r.frame.Advance(0.6 * r.style.Size)
+	return nil
+}
+
+func loadImageFile(name string) (image.Image, error) {
+	f,err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	img,_,err := image.Decode(f)
+	return img, err
+}
+
+func (r *markupRenderer) table(header, separator string, rows []string) error {
+	headerCells := tableCells(header)
+	columns := len(headerCells)
+	widths := make([]float64, columns)
+	for i := range widths {
+		widths[i] = r.frame.Width() / float64(columns)
+	}
+	table := NewTable(r.style.Regular, r.style.Size, widths...)
+	table.HeaderFont = r.style.Bold
+	table.HeaderRows = 1
+	for i,cell := range tableCells(separator) {
+		if i >= columns {
+			break
+		}
+		switch {
+		case strings.HasPrefix(cell, ":") && strings.HasSuffix(cell, ":"):
+			table.Alignments = append(table.Alignments, AlignCenter)
+		case strings.HasSuffix(cell, ":"):
+			table.Alignments = append(table.Alignments, AlignRight)
+		default:
+			table.Alignments = append(table.Alignments, AlignLeft)
+		}
+	}
+	for _,row := range append([]string{header}, rows...) {
+		cells := tableCells(row)
+		if len(cells) > columns {
+			cells = cells[:columns]
+		}
+		for i,cell := range cells {
+			cells[i] = r.plainText(cell)
+		}
+		table.AddRow(cells...)
+	}
+	if err := table.Draw(r.frame); err != nil {
+		return err
+	}
+	r.frame.Advance(0.6 * r.style.Size)
+	return nil
+}
+
+// tableCells() splits a table row into trimmed cells.
+func tableCells(row string) []string {
+	row = strings.TrimSpace(row)
+	row = strings.TrimPrefix(row, "|")
+	row = strings.TrimSuffix(row, "|")
+	cells := strings.Split(row, "|")
+	for i := range cells {
+		cells[i] = strings.TrimSpace(cells[i])
+	}
+	return cells
+}
+
+// plainText() removes inline formatting from text.
+func (r *markupRenderer) plainText(text string) string {
+	var result string
+	for _,span := range r.spans(text, r.style.Size, false) {
+		result += span.Text
+	}
+	return result
+}
+
+// spans() splits text into spans at changes of inline formatting.  A
+// marker without a matching closing marker is shown as text.
+func (r *markupRenderer) spans(text string, size float64, bold bool) []Span {
+	var (
+		spans []Span
+		current []byte
+		italic, code bool )
+	font := func() Font {
+		switch {
+		case code:
+			return r.style.Code
+		case bold && italic:
+			return r.style.BoldItalic
+		case bold:
+			return r.style.Bold
+		case italic:
+			return r.style.Italic
+		}
+		return r.style.Regular
+	}
+	flush := func() {
+		if len(current) > 0 {
+			spans = append(spans, Span{string(current), font(), size})
+			current = nil
+		}
+	}
+	// closes() returns true if marker appears later in text.
+	closes := func(i int, marker string) bool {
+		return strings.Contains(text[i+len(marker):], marker)
+	}
+	// opensItalic() returns true if the * or _ at i starts italic
+	// text.  It must be followed by a non-space other than another
+	// marker and be closed, and an underscore must not be within a
+	// word, as in snake_case.
+	opensItalic := func(i int) bool {
+		if i+1 >= len(text) || text[i+1] == ' ' || text[i+1] == text[i] || !closes(i, text[i:i+1]) {
+			return false
+		}
+		return text[i] == '*' || i == 0 || !isWordByte(text[i-1])
+	}
+
+	for i:=0; i<len(text); {
+		c := text[i]
+		switch {
+		case c == '\\' && i+1 < len(text) && strings.IndexByte("\\`*_#|![]()", text[i+1]) >= 0:
+			current = append(current, text[i+1])
+			i += 2
+			continue
+		case c == '`' && (code || closes(i, "`")):
+			flush()
+			code = !code
+			i++
+			continue
+		case code:
+		case strings.HasPrefix(text[i:], "**") && (bold || closes(i, "**")):
+			flush()
+			bold = !bold
+			i += 2
+			continue
+		case (c == '*' || c == '_') && (italic || opensItalic(i)):
+			flush()
+			italic = !italic
+			i++
+			continue
+		}
+		current = append(current, c)
+		i++
+	}
+	flush()
+	return spans
+}
+
+func isWordByte(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c >= 0x80
+}
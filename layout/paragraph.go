@@ -0,0 +1,235 @@
+package layout
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"github.com/mawicks/PDFiG/pdf" )
+
+// Span is a run of text in a single font and size within a Paragraph.
+type Span struct {
+	Text string
+	Font Font
+	Size float64
+}
+
+// Paragraph is text, possibly in several fonts, that is wrapped to the
+// width of a Frame.  Lines break at spaces and at newlines within the
+// text.  A paragraph may continue on new pages.
+type Paragraph struct {
+	Spans []Span
+	Alignment Alignment
+	// Leading is the distance between baselines as a multiple of
+	// the largest font size on each line.
+	Leading float64
+	// Indent is the distance of the paragraph from the left edge of
+	// the frame, and FirstLineIndent the additional indentation of
+	// the first line, which may be negative for a hanging indent.
+	Indent float64
+	FirstLineIndent float64
+	// SpaceBefore and SpaceAfter are the space above and below the
+	// paragraph.  SpaceBefore is omitted at the top of a page.
+	SpaceBefore float64
+	SpaceAfter float64
+}
+
+// NewParagraph() constructs a left-aligned Paragraph with a leading
+// of 1.2.
+func NewParagraph(spans ...Span) *Paragraph {
+	return &Paragraph{Spans: spans, Leading: 1.2}
+}
+
+// Add() appends text in the given font and size and returns the
+// paragraph.
+func (p *Paragraph) Add(text string, font Font, size float64) *Paragraph {
+	p.Spans = append(p.Spans, Span{text, font, size})
+	return p
+}
+
+// fragment is part of a word set in a single font.
+type fragment struct {
+	text string
+	font Font
+	size float64
+	width float64
+}
+
+// word is text between spaces, which may change font.
+type word struct {
+	fragments []fragment
+	width float64
+	// lineBreak is true for the empty word that marks a newline.
+	lineBreak bool
+}
+
+// paragraphLine is a line of a laid-out paragraph.
+type paragraphLine struct {
+	words []word
+	// width is the natural width of the words and the spaces
+	// between them.
+	width float64
+	// spaces holds the width of the space before each word after
+	// the first.
+	spaces []float64
+	// size and ascent are the largest font size and ascent on
+	// the line.
+	size, ascent float64
+	// last is true for a line that ends the paragraph or a
+	// newline, which isn't justified.
+	last bool
+}
+
+// words() splits the spans into words.
+func (p *Paragraph) words() []word {
+	var (
+		words []word
+		current word
+		inWord bool )
+	finish := func() {
+		if inWord {
+			words = append(words, current)
+		}
+		current, inWord = word{}, false
+	}
+	for _,span := range p.Spans {
+		for i,line := range strings.Split(span.Text, "\n") {
+			if i > 0 {
+				finish()
+				words = append(words, word{lineBreak: true})
+			}
+			// Text is split at spaces, keeping empty pieces
+			// so that text adjoining the end of a span stays
+			// in the same word.
+			for j,piece := range strings.Split(line, " ") {
+				if j > 0 {
+					finish()
+				}
+				if piece == "" {
+					continue
+				}
+				width := span.Font.Width(piece, span.Size)
+				current.fragments = append(current.fragments, fragment{piece, span.Font, span.Size, width})
+				current.width += width
+				inWord = true
+			}
+		}
+	}
+	finish()
+	return words
+}
+
+// lines() breaks the paragraph into lines no wider than width.  A word
+// wider than a line is put on a line of its own.
+func (p *Paragraph) lines(width float64) []paragraphLine {
+	var (
+		lines []paragraphLine
+		line paragraphLine )
+	finish := func(last bool) {
+		line.last = last
+		if len(line.words) > 0 || last {
+			lines = append(lines, line)
+		}
+		line = paragraphLine{}
+	}
+	lineWidth := func() float64 {
+		if len(lines) == 0 {
+			return width - p.FirstLineIndent
+		}
+		return width
+	}
+	for _,w := range p.words() {
+		if w.lineBreak {
+			finish(true)
+			continue
+		}
+		space := 0.0
+		if len(line.words) > 0 {
+			first := w.fragments[0]
+			space = first.font.Width(" ", first.size)
+			if line.width + space + w.width > lineWidth() {
+				finish(false)
+				space = 0
+			}
+		}
+		if len(line.words) > 0 {
+			line.spaces = append(line.spaces, space)
+		}
+		line.words = append(line.words, w)
+		line.width += space + w.width
+		for _,f := range w.fragments {
+			if f.size > line.size {
+				line.size = f.size
+			}
+			if ascent := f.font.Ascent(f.size); ascent > line.ascent {
+				line.ascent = ascent
+			}
+		}
+	}
+	finish(true)
+
+	// An empty line, such as one between consecutive newlines, has
+	// the size of the paragraph's first span.
+	for i := range lines {
+		if lines[i].size == 0 && len(p.Spans) > 0 {
+			lines[i].size = p.Spans[0].Size
+			lines[i].ascent = p.Spans[0].Font.Ascent(p.Spans[0].Size)
+		}
+	}
+	return lines
+}
+
+// Draw() draws the paragraph at the current position of frame,
+// starting new pages as necessary, and advances the frame past it.
+func (p *Paragraph) Draw(frame *Frame) {
+	if !frame.AtTop() {
+		frame.Advance(p.SpaceBefore)
+	}
+	left := frame.Left() + p.Indent
+	width := frame.Right() - left
+	for i,line := range p.lines(width) {
+		height := p.Leading * line.size
+		frame.Reserve(height)
+		lineLeft := left
+		if i == 0 {
+			lineLeft += p.FirstLineIndent
+		}
+		baseline := frame.Y() - (p.Leading-1)*line.size/2 - line.ascent
+		p.drawLine(frame.Page(), line, lineLeft, frame.Right(), baseline)
+		frame.Advance(height)
+	}
+	frame.Advance(p.SpaceAfter)
+}
+
+// drawLine() draws a line of the paragraph between left and right.
+func (p *Paragraph) drawLine(page *pdf.Page, line paragraphLine, left, right, baseline float64) {
+	if len(line.words) == 0 {
+		return
+	}
+	x := alignedX(p.Alignment, left, right, line.width)
+	extra := 0.0
+	if p.Alignment == AlignJustify && !line.last && len(line.words) > 1 {
+		extra = (right - left - line.width) / float64(len(line.words)-1)
+	}
+
+	io.WriteString(page, "BT ")
+	serializer := pdf.NewContentSerializer(page)
+	var (
+		currentFont Font
+		currentSize float64 )
+	for i,w := range line.words {
+		if i > 0 {
+			x += line.spaces[i-1] + extra
+		}
+		for _,f := range w.fragments {
+			if f.font != currentFont || f.size != currentSize {
+				fmt.Fprintf(page, "/%s %s Tf ", page.AddFont(f.font.Resource()), pdf.FormatReal(f.size, precision))
+				currentFont, currentSize = f.font, f.size
+			}
+			fmt.Fprintf(page, "1 0 0 1 %s %s Tm ", pdf.FormatReal(x, precision), pdf.FormatReal(baseline, precision))
+			serializer.Write(f.font.Show(f.text, f.size))
+			io.WriteString(page, " ")
+			x += f.width
+		}
+	}
+	io.WriteString(page, "ET\n")
+}
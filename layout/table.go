@@ -0,0 +1,178 @@
+package layout
+
+import (
+	"errors"
+	"fmt"
+	"github.com/mawicks/PDFiG/pdf" )
+
+var rowTooTall = errors.New(`Table row is taller than the frame`)
+
+// Table is a grid of text cells drawn in a Frame.  Text wraps within
+// cells, and rows are kept together: a row that doesn't fit on the
+// current page starts a new page, where the header rows are repeated.
+type Table struct {
+	// Columns holds the width of each column.
+	Columns []float64
+	// Alignments holds the alignment of each column; columns
+	// without an entry are aligned left.
+	Alignments []Alignment
+	Font Font
+	// HeaderFont is used for the header rows, or Font if it is
+	// nil.
+	HeaderFont Font
+	FontSize float64
+	// Leading is the distance between baselines as a multiple of
+	// the font size.
+	Leading float64
+	// Padding is the space between the cell borders and the text.
+	Padding float64
+	// BorderWidth is the width of the lines drawn around each cell,
+	// or zero for no borders.
+	BorderWidth float64
+	// HeaderRows is the number of rows, at the top of the table,
+	// that are repeated at the top of each page.
+	HeaderRows int
+	rows [][]string
+}
+
+// NewTable() constructs a Table with the given column widths, using a
+// 1.2 leading, 4 points of padding, and half-point borders.
+func NewTable(font Font, size float64, columns ...float64) *Table {
+	return &Table{
+		Columns: columns,
+		Font: font,
+		FontSize: size,
+		Leading: 1.2,
+		Padding: 4,
+		BorderWidth: 0.5 }
+}
+
+// AddRow() adds a row of cells.  Cells missing at the end of the row
+// are empty.
+func (t *Table) AddRow(cells ...string) {
+	if len(cells) > len(t.Columns) {
+		panic(fmt.Sprintf("Table row has %d cells but the table has %d columns", len(cells), len(t.Columns)))
+	}
+	t.rows = append(t.rows, cells)
+}
+
+// Width() returns the width of the table.
+func (t *Table) Width() float64 {
+	total := 0.0
+	for _,width := range t.Columns {
+		total += width
+	}
+	return total
+}
+
+// Draw() draws the table at the left edge of frame, starting at the
+// current position, and advances the frame past it.  An error is
+// returned, before anything is drawn, if a row, together with the
+// header rows, doesn't fit in the frame.
+func (t *Table) Draw(frame *Frame) error {
+	headerCount := t.HeaderRows
+	if headerCount > len(t.rows) {
+		headerCount = len(t.rows)
+	}
+
+	lines := make([][][]string, len(t.rows))
+	heights := make([]float64, len(t.rows))
+	headerHeight := 0.0
+	for i,row := range t.rows {
+		lines[i], heights[i] = t.layoutRow(row, t.fontFor(i))
+		if i < headerCount {
+			headerHeight += heights[i]
+		}
+	}
+	if headerHeight > frame.Height() {
+		return rowTooTall
+	}
+	for i := headerCount; i < len(t.rows); i++ {
+		if headerHeight + heights[i] > frame.Height() {
+			return rowTooTall
+		}
+	}
+
+	drawHeader := func() {
+		for i:=0; i<headerCount; i++ {
+			t.drawRow(frame, lines[i], heights[i], t.fontFor(i))
+		}
+	}
+	frame.Reserve(headerHeight + t.firstBodyHeight(heights, headerCount))
+	drawHeader()
+	for i := headerCount; i < len(t.rows); i++ {
+		if heights[i] > frame.Remaining() {
+			frame.NewPage()
+			drawHeader()
+		}
+		t.drawRow(frame, lines[i], heights[i], t.fontFor(i))
+	}
+	return nil
+}
+
+// firstBodyHeight() returns the height of the first row after the
+// header rows, or zero if there is none, so that the header isn't
+// left alone at the bottom of a page.
+func (t *Table) firstBodyHeight(heights []float64, headerCount int) float64 {
+	if headerCount < len(heights) {
+		return heights[headerCount]
+	}
+	return 0
+}
+
+func (t *Table) fontFor(row int) Font {
+	if row < t.HeaderRows && t.HeaderFont != nil {
+		return t.HeaderFont
+	}
+	return t.Font
+}
+
+// layoutRow() wraps the text of each cell of row and returns the
+// lines and the height of the row.
+func (t *Table) layoutRow(row []string, font Font) ([][]string, float64) {
+	lines := make([][]string, len(t.Columns))
+	maxLines := 1
+	for i,width := range t.Columns {
+		text := ""
+		if i < len(row) {
+			text = row[i]
+		}
+		lines[i] = wrapText(font, t.FontSize, text, width - 2*t.Padding)
+		if len(lines[i]) > maxLines {
+			maxLines = len(lines[i])
+		}
+	}
+	return lines, float64(maxLines)*t.Leading*t.FontSize + 2*t.Padding
+}
+
+// drawRow() draws a row at the current position of frame and advances
+// the frame past it.
+func (t *Table) drawRow(frame *Frame, lines [][]string, height float64, font Font) {
+	page := frame.Page()
+	top := frame.Y()
+	x := frame.Left()
+	for i,width := range t.Columns {
+		alignment := AlignLeft
+		if i < len(t.Alignments) {
+			alignment = t.Alignments[i]
+		}
+		// The first baseline is placed so that the ascenders
+		// of a line set solid touch the padding, with the
+		// extra leading split above and below the text.
+		baseline := top - t.Padding - (t.Leading-1)*t.FontSize/2 - font.Ascent(t.FontSize)
+		for _,line := range lines[i] {
+			if line != "" {
+				lineX := alignedX(alignment, x+t.Padding, x+width-t.Padding, font.Width(line, t.FontSize))
+				showText(page, font, t.FontSize, lineX, baseline, line)
+			}
+			baseline -= t.Leading * t.FontSize
+		}
+		if t.BorderWidth > 0 {
+			fmt.Fprintf(page, "%s w %s %s %s %s re S\n", pdf.FormatReal(t.BorderWidth, precision),
+				pdf.FormatReal(x, precision), pdf.FormatReal(top-height, precision),
+				pdf.FormatReal(width, precision), pdf.FormatReal(height, precision))
+		}
+		x += width
+	}
+	frame.Advance(height)
+}
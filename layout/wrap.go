@@ -0,0 +1,59 @@
+package layout
+
+import (
+	"strings"
+	"unicode/utf8" )
+
+// wrapText() breaks text into lines no wider than width, breaking at
+// spaces where possible and at newlines always.  Runs of spaces are
+// collapsed.  A word wider than width is broken between characters.
+func wrapText(font Font, size float64, text string, width float64) []string {
+	var lines []string
+	for _,paragraph := range strings.Split(text, "\n") {
+		line := ""
+		for _,word := range strings.Fields(paragraph) {
+			candidate := word
+			if line != "" {
+				candidate = line + " " + word
+			}
+			if font.Width(candidate, size) <= width {
+				line = candidate
+				continue
+			}
+			if line != "" {
+				lines = append(lines, line)
+			}
+			line = word
+			for font.Width(line, size) > width {
+				head := breakWord(font, size, line, width)
+				if head == line {
+					break
+				}
+				lines = append(lines, head)
+				line = line[len(head):]
+			}
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// breakWord() returns the longest prefix of word, of at least one
+// character, that fits in width.  It is called only for words wider
+// than width.
+func breakWord(font Font, size float64, word string, width float64) string {
+	end := 0
+	for i := range word {
+		if i > 0 {
+			if font.Width(word[:i], size) > width {
+				break
+			}
+			end = i
+		}
+	}
+	if end == 0 {
+		// Even the first character is too wide.
+		_,end = utf8.DecodeRuneInString(word)
+	}
+	return word[:end]
+}
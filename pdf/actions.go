@@ -0,0 +1,377 @@
+package pdf
+
+import (
+	"errors"
+	"fmt" )
+
+// An Action is something a viewer does when a link annotation or
+// outline item is activated or when the document is opened.  Actions
+// are converted to action dictionaries by NewActionDictionary(),
+// which validates them first.  Use an ActionSequence to perform
+// several actions in turn.
+type Action interface {
+	// Validate() returns an error describing the first problem
+	// found with the action, or nil if it may be written.
+	Validate() error
+	// entries() adds the action's /S entry and the entries
+	// specific to its type to dictionary.
+	entries(dictionary Dictionary)
+}
+
+// DestinationFit determines how a destination's page is displayed.
+// Its value is the name used in destination arrays.
+type DestinationFit string
+
+const (
+	// FitPage displays the entire page.  This is the default.
+	FitPage DestinationFit = "Fit"
+	// FitWidth displays the page's width with Top at the top of
+	// the window.
+	FitWidth DestinationFit = "FitH"
+	// FitHeight displays the page's height with Left at the left
+	// of the window.
+	FitHeight DestinationFit = "FitV"
+	// FitRectangle displays the rectangle bounded by Left,
+	// Bottom, Right, and Top.
+	FitRectangle DestinationFit = "FitR"
+	// FitBoundingBox, FitBoundingBoxWidth, and
+	// FitBoundingBoxHeight are like FitPage, FitWidth, and
+	// FitHeight but fit the bounding box of the page's contents.
+	FitBoundingBox DestinationFit = "FitB"
+	FitBoundingBoxWidth DestinationFit = "FitBH"
+	FitBoundingBoxHeight DestinationFit = "FitBV"
+	// ExplicitZoom places (Left, Top) at the upper-left corner of
+	// the window, magnified by Zoom.
+	ExplicitZoom DestinationFit = "XYZ" )
+
+// A Destination is a view of a page.
+type Destination struct {
+	// Name is the name of a destination defined in the document's
+	// /Dests name tree.  If it is set, the other fields are
+	// ignored.
+	Name string
+	// Page is a page in the document; see Page.Reference() and
+	// ExistingPage.Reference().  Destinations in other documents
+	// use PageNumber, numbered from 0, instead.
+	Page Indirect
+	PageNumber int
+	Fit DestinationFit
+	// The coordinates used by Fit, in default user space.
+	Left, Bottom, Right, Top float64
+	// Zoom is the magnification for ExplicitZoom, where 1 is
+	// 100%.  Zero leaves the magnification unchanged.
+	Zoom float64
+}
+
+// object() returns the destination as a name string or destination
+// array.  For a remote destination, the page is identified by its
+// number.
+func (dest Destination) object(remote bool) (Object, error) {
+	if dest.Name != "" {
+		return NewTextString(dest.Name), nil
+	}
+
+	array := NewArray()
+	if remote {
+		if dest.Page != nil {
+			return nil, errors.New(`Destination in another document identifies its page by reference`)
+		}
+		if dest.PageNumber < 0 {
+			return nil, fmt.Errorf(`Invalid destination page number %d`, dest.PageNumber)
+		}
+		array.Add(NewIntNumeric(dest.PageNumber))
+	} else {
+		if dest.Page == nil {
+			return nil, errors.New(`Destination has no page`)
+		}
+		array.Add(dest.Page)
+	}
+
+	fit := dest.Fit
+	if fit == "" {
+		fit = FitPage
+	}
+	array.Add(NewName(string(fit)))
+	switch fit {
+	case FitPage, FitBoundingBox:
+	case FitWidth, FitBoundingBoxWidth:
+		array.Add(NewNumeric(dest.Top))
+	case FitHeight, FitBoundingBoxHeight:
+		array.Add(NewNumeric(dest.Left))
+	case FitRectangle:
+		if dest.Left >= dest.Right || dest.Bottom >= dest.Top {
+			return nil, errors.New(`Destination rectangle is empty`)
+		}
+		array.Add(NewNumeric(dest.Left))
+		array.Add(NewNumeric(dest.Bottom))
+		array.Add(NewNumeric(dest.Right))
+		array.Add(NewNumeric(dest.Top))
+	case ExplicitZoom:
+		if dest.Zoom < 0 {
+			return nil, fmt.Errorf(`Invalid destination zoom %v`, dest.Zoom)
+		}
+		array.Add(NewNumeric(dest.Left))
+		array.Add(NewNumeric(dest.Top))
+		array.Add(NewNumeric(dest.Zoom))
+	default:
+		return nil, fmt.Errorf(`Unknown destination fit %q`, fit)
+	}
+	return array, nil
+}
+
+// GoToAction displays a destination in the document.
+type GoToAction struct {
+	Destination Destination
+}
+
+func (a GoToAction) Validate() error {
+	_,err := a.Destination.object(false)
+	return err
+}
+
+func (a GoToAction) entries(dictionary Dictionary) {
+	dest,_ := a.Destination.object(false)
+	dictionary.Add("S", NewName("GoTo"))
+	dictionary.Add("D", dest)
+}
+
+// GoToRemoteAction displays a destination in another PDF file.
+type GoToRemoteAction struct {
+	// File is the path of the other file, relative to this one
+	// if it isn't absolute.
+	File string
+	Destination Destination
+	// NewWindow opens the file in a new window.
+	NewWindow bool
+}
+
+func (a GoToRemoteAction) Validate() error {
+	if a.File == "" {
+		return errors.New(`GoToR action has no file`)
+	}
+	_,err := a.Destination.object(true)
+	return err
+}
+
+func (a GoToRemoteAction) entries(dictionary Dictionary) {
+	dest,_ := a.Destination.object(true)
+	dictionary.Add("S", NewName("GoToR"))
+	dictionary.Add("F", NewTextString(a.File))
+	dictionary.Add("D", dest)
+	if a.NewWindow {
+		dictionary.Add("NewWindow", NewBoolean(true))
+	}
+}
+
+// LaunchAction opens a file, usually a document, with the
+// application registered for it.
+type LaunchAction struct {
+	File string
+	NewWindow bool
+}
+
+func (a LaunchAction) Validate() error {
+	if a.File == "" {
+		return errors.New(`Launch action has no file`)
+	}
+	return nil
+}
+
+func (a LaunchAction) entries(dictionary Dictionary) {
+	dictionary.Add("S", NewName("Launch"))
+	dictionary.Add("F", NewTextString(a.File))
+	if a.NewWindow {
+		dictionary.Add("NewWindow", NewBoolean(true))
+	}
+}
+
+// The names of the navigation actions every viewer supports.  Viewers
+// may support others, such as "Print".
+const (
+	NextPageAction = "NextPage"
+	PrevPageAction = "PrevPage"
+	FirstPageAction = "FirstPage"
+	LastPageAction = "LastPage" )
+
+// NamedAction performs an action defined by the viewer.
+type NamedAction struct {
+	Name string
+}
+
+func (a NamedAction) Validate() error {
+	if a.Name == "" {
+		return errors.New(`Named action has no name`)
+	}
+	return nil
+}
+
+func (a NamedAction) entries(dictionary Dictionary) {
+	dictionary.Add("S", NewName("Named"))
+	dictionary.Add("N", NewName(a.Name))
+}
+
+// JavaScriptAction runs a script.  Documents that conform to PDF/A
+// must not contain JavaScript.
+type JavaScriptAction struct {
+	Script string
+}
+
+func (a JavaScriptAction) Validate() error {
+	if a.Script == "" {
+		return errors.New(`JavaScript action has no script`)
+	}
+	return nil
+}
+
+func (a JavaScriptAction) entries(dictionary Dictionary) {
+	dictionary.Add("S", NewName("JavaScript"))
+	dictionary.Add("JS", NewTextString(a.Script))
+}
+
+// SubmitFormFlags are the /Flags of a SubmitFormAction.
+type SubmitFormFlags int
+
+const (
+	// SubmitExclude submits every field except those listed.
+	SubmitExclude SubmitFormFlags = 1 << iota
+	SubmitIncludeNoValueFields
+	// SubmitHTML submits the fields as an HTML form rather than
+	// as FDF.
+	SubmitHTML
+	// SubmitGetMethod uses an HTTP GET rather than a POST.  It
+	// requires SubmitHTML.
+	SubmitGetMethod
+	SubmitCoordinates
+	// SubmitXFDF submits the fields as XFDF.
+	SubmitXFDF
+	SubmitIncludeAppendSaves
+	SubmitIncludeAnnotations
+	// SubmitPDF submits the entire document.
+	SubmitPDF
+	SubmitCanonicalFormat
+	SubmitExcludeNonUserAnnotations
+	SubmitExcludeFKey
+	_
+	SubmitEmbedForm )
+
+// SubmitFormAction sends the values of the form's fields to a URL.
+type SubmitFormAction struct {
+	URL string
+	// Fields are the fully qualified names of the fields to
+	// submit, or to omit if Flags includes SubmitExclude.  If
+	// there are none, every field is submitted.
+	Fields []string
+	Flags SubmitFormFlags
+}
+
+func (a SubmitFormAction) Validate() error {
+	if a.URL == "" {
+		return errors.New(`SubmitForm action has no URL`)
+	}
+	formats := 0
+	for _,format := range []SubmitFormFlags{SubmitHTML, SubmitXFDF, SubmitPDF} {
+		if a.Flags & format != 0 {
+			formats += 1
+		}
+	}
+	if formats > 1 {
+		return errors.New(`SubmitForm action specifies more than one format`)
+	}
+	if a.Flags & SubmitGetMethod != 0 && a.Flags & SubmitHTML == 0 {
+		return errors.New(`SubmitForm action uses GET without HTML format`)
+	}
+	return nil
+}
+
+func (a SubmitFormAction) entries(dictionary Dictionary) {
+	fileSpec := NewDictionary()
+	fileSpec.Add("FS", NewName("URL"))
+	fileSpec.Add("F", NewTextString(a.URL))
+	dictionary.Add("S", NewName("SubmitForm"))
+	dictionary.Add("F", fileSpec)
+	if len(a.Fields) > 0 {
+		dictionary.Add("Fields", NewStringArray(a.Fields))
+	}
+	if a.Flags != 0 {
+		dictionary.Add("Flags", NewIntNumeric(int(a.Flags)))
+	}
+}
+
+// ActionSequence performs its actions in turn, chaining them with
+// /Next.
+type ActionSequence []Action
+
+func (s ActionSequence) Validate() error {
+	if len(s) == 0 {
+		return errors.New(`Action sequence is empty`)
+	}
+	for _,action := range s {
+		if action == nil {
+			return errors.New(`Action sequence contains a nil action`)
+		}
+		if err := action.Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s ActionSequence) entries(dictionary Dictionary) {
+	s[0].entries(dictionary)
+	switch len(s) {
+	case 1:
+	case 2:
+		dictionary.Add("Next", actionDictionary(s[1]))
+	default:
+		next := NewArray()
+		for _,action := range s[1:] {
+			next.Add(actionDictionary(action))
+		}
+		dictionary.Add("Next", next)
+	}
+}
+
+// NewActionDictionary() validates action and returns its action
+// dictionary.
+func NewActionDictionary(action Action) (Dictionary, error) {
+	if action == nil {
+		return nil, errors.New(`Action is nil`)
+	}
+	if err := action.Validate(); err != nil {
+		return nil, err
+	}
+	return actionDictionary(action), nil
+}
+
+func actionDictionary(action Action) Dictionary {
+	dictionary := NewDictionary()
+	dictionary.Add("Type", NewName("Action"))
+	action.entries(dictionary)
+	return dictionary
+}
+
+// SetAction() sets the /A entry of a link annotation or outline item
+// dictionary to the dictionary of action, replacing any destination.
+func SetAction(item Dictionary, action Action) error {
+	dictionary,err := NewActionDictionary(action)
+	if err != nil {
+		return err
+	}
+	item.Remove("Dest")
+	item.Add("A", dictionary)
+	return nil
+}
+
+// AddLink() adds a link annotation to the page that performs action
+// when the rectangle with lower-left corner (llx, lly) and
+// upper-right corner (urx, ury) is clicked.  The link has no border.
+func (p *Page) AddLink(llx, lly, urx, ury float64, action Action) (Indirect, error) {
+	annotation := NewDictionary()
+	annotation.Add("Subtype", NewName("Link"))
+	annotation.Add("Rect", NewRectangle(llx, lly, urx, ury))
+	annotation.Add("Border", NewIntArray([]int{0, 0, 0}))
+	if err := SetAction(annotation, action); err != nil {
+		return nil, err
+	}
+	return p.AddAnnotation(annotation), nil
+}
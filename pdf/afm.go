@@ -0,0 +1,299 @@
+package pdf
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings" )
+
+// FontMetrics holds the metrics of a Type 1 font as given by an Adobe
+// Font Metrics (AFM) file.  Lengths are in glyph space, 1000 units per
+// em; multiply by the font size and divide by 1000 for text space.
+type FontMetrics struct {
+	FontName string
+	FamilyName string
+	Weight string
+	// FontBBox is the union of the glyph bounding boxes.
+	FontBBox [4]float64
+	ItalicAngle float64
+	IsFixedPitch bool
+	// Symbolic is true for fonts, such as Symbol, whose glyphs are
+	// outside the standard Latin character set, as indicated by an
+	// /EncodingScheme of FontSpecific.
+	Symbolic bool
+	CapHeight float64
+	XHeight float64
+	Ascender float64
+	Descender float64
+	// StemV is the dominant vertical stem width (StdVW).
+	StemV float64
+	// Widths maps glyph names to advance widths.
+	Widths map[string]float64
+	// Codes maps glyph names to their codes in the font's built-in
+	// encoding.  Glyphs that are not encoded are omitted.
+	Codes map[string]int
+	// Kerning maps pairs of glyph names to the adjustment of the
+	// distance between them, which is usually negative.
+	Kerning map[[2]string]float64
+}
+
+var notAFM = errors.New(`Not an AFM file`)
+
+// ParseAFM() reads font metrics from an AFM file.  Composite font
+// information and vertical metrics are ignored.
+func ParseAFM(r io.Reader) (*FontMetrics, error) {
+	metrics := &FontMetrics{
+		Widths: make(map[string]float64),
+		Codes: make(map[string]int),
+		Kerning: make(map[[2]string]float64) }
+
+	scanner := bufio.NewScanner(r)
+	started := false
+	section := ""
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		fields := strings.Fields(line)
+		if len(fields) == 0 || fields[0] == "Comment" {
+			continue
+		}
+		if !started {
+			if fields[0] != "StartFontMetrics" {
+				return nil, notAFM
+			}
+			started = true
+			continue
+		}
+
+		key, values := fields[0], fields[1:]
+		switch {
+		case key == "EndFontMetrics":
+			return metrics, nil
+		case strings.HasPrefix(key, "Start"):
+			section = key
+			continue
+		case strings.HasPrefix(key, "End"):
+			section = ""
+			continue
+		}
+
+		switch section {
+		case "StartCharMetrics":
+			metrics.readCharMetrics(line)
+		case "StartKernPairs", "StartKernPairs0":
+			if (key == "KPX" || key == "KP") && len(values) >= 3 {
+				if x,err := strconv.ParseFloat(values[2], 64); err == nil {
+					metrics.Kerning[[2]string{values[0], values[1]}] = x
+				}
+			}
+		case "":
+			metrics.readHeader(key, values)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if !started {
+		return nil, notAFM
+	}
+	return metrics, nil
+}
+
+// readHeader() reads a global font information entry.
+func (metrics *FontMetrics) readHeader(key string, values []string) {
+	number := func(dst *float64) {
+		if len(values) > 0 {
+			if v,err := strconv.ParseFloat(values[0], 64); err == nil {
+				*dst = v
+			}
+		}
+	}
+
+	switch key {
+	case "FontName":
+		metrics.FontName = strings.Join(values, " ")
+	case "FamilyName":
+		metrics.FamilyName = strings.Join(values, " ")
+	case "Weight":
+		metrics.Weight = strings.Join(values, " ")
+	case "FontBBox":
+		if len(values) == 4 {
+			for i,v := range values {
+				metrics.FontBBox[i],_ = strconv.ParseFloat(v, 64)
+			}
+		}
+	case "ItalicAngle":
+		number(&metrics.ItalicAngle)
+	case "IsFixedPitch":
+		metrics.IsFixedPitch = len(values) > 0 && values[0] == "true"
+	case "EncodingScheme":
+		metrics.Symbolic = len(values) > 0 && values[0] == "FontSpecific"
+	case "CapHeight":
+		number(&metrics.CapHeight)
+	case "XHeight":
+		number(&metrics.XHeight)
+	case "Ascender":
+		number(&metrics.Ascender)
+	case "Descender":
+		number(&metrics.Descender)
+	case "StdVW":
+		number(&metrics.StemV)
+	}
+}
+
+// readCharMetrics() reads a line of the form
+// "C 32 ; WX 278 ; N space ; B 0 0 0 0 ;".
+func (metrics *FontMetrics) readCharMetrics(line string) {
+	code := -1
+	width := 0.0
+	name := ""
+	for _,entry := range strings.Split(line, ";") {
+		fields := strings.Fields(entry)
+		if len(fields) < 2 {
+			continue
+		}
+		switch fields[0] {
+		case "C":
+			code,_ = strconv.Atoi(fields[1])
+		case "CH":
+			if v,err := strconv.ParseInt(strings.Trim(fields[1], "<>"), 16, 32); err == nil {
+				code = int(v)
+			}
+		case "WX", "W0X":
+			width,_ = strconv.ParseFloat(fields[1], 64)
+		case "N":
+			name = fields[1]
+		}
+	}
+	if name == "" {
+		return
+	}
+	metrics.Widths[name] = width
+	if code >= 0 {
+		metrics.Codes[name] = code
+	}
+}
+
+// Width() returns the advance width of the named glyph.
+func (metrics *FontMetrics) Width(glyph string) (float64, bool) {
+	width,exists := metrics.Widths[glyph]
+	return width, exists
+}
+
+// Kern() returns the kerning adjustment between the named glyphs, or
+// zero if the pair isn't kerned.
+func (metrics *FontMetrics) Kern(left, right string) float64 {
+	return metrics.Kerning[[2]string{left, right}]
+}
+
+// StringWidth() returns the width in text space of text set in the
+// font at the given size, without kerning.  Characters are mapped to
+// glyphs by their standard glyph names; characters without glyphs
+// have no width.
+func (metrics *FontMetrics) StringWidth(text string, size float64) float64 {
+	total := 0.0
+	for _,r := range text {
+		total += metrics.Widths[glyphNameForRune(r)]
+	}
+	return total * size / 1000
+}
+
+// ShowText() returns an operation that shows text in the font at the
+// given size, along with the width of the text in text space.  Text
+// is encoded with the font's built-in encoding, as used by
+// NewStandardFont().  If kerning is true, pairs in Kerning are kerned
+// by a TJ operation; otherwise, and if no pairs are kerned, the
+// operation is Tj.  Kerning pairs can come from an AFM file or be
+// added from another source, such as a TrueType kern table.  An
+// error is returned if text contains a character the font can't
+// encode.
+func (metrics *FontMetrics) ShowText(text string, size float64, kerning bool) (Operation, float64, error) {
+	var (
+		segment []byte
+		previous string
+		total float64 )
+	adjustments := NewArray()
+	for _,r := range text {
+		name := glyphNameForRune(r)
+		code,exists := metrics.Codes[name]
+		if !exists || code > 255 {
+			return Operation{}, 0, fmt.Errorf(`%s cannot encode %q`, metrics.FontName, r)
+		}
+		if kerning && previous != "" {
+			if kern := metrics.Kern(previous, name); kern != 0 {
+				// TJ adjustments are subtracted from the
+				// position.
+				adjustments.Add(NewBinaryString(segment))
+				adjustments.Add(NewNumeric(-kern))
+				segment = nil
+				total += kern
+			}
+		}
+		segment = append(segment, byte(code))
+		total += metrics.Widths[name]
+		previous = name
+	}
+
+	width := total * size / 1000
+	if adjustments.Size() == 0 {
+		return Operation{"Tj", []Object{NewBinaryString(segment)}}, width, nil
+	}
+	adjustments.Add(NewBinaryString(segment))
+	return Operation{"TJ", []Object{adjustments}}, width, nil
+}
+
+// FontDescriptor() returns a font descriptor for the font.  The caller
+// adds the font program, if it is embedded.
+func (metrics *FontMetrics) FontDescriptor() Dictionary {
+	const (
+		fixedPitchFlag = 1 << 0
+		symbolicFlag = 1 << 2
+		italicFlag = 1 << 6
+		nonsymbolicFlag = 1 << 5 )
+	flags := nonsymbolicFlag
+	if metrics.Symbolic {
+		flags = symbolicFlag
+	}
+	if metrics.IsFixedPitch {
+		flags |= fixedPitchFlag
+	}
+	if metrics.ItalicAngle != 0 {
+		flags |= italicFlag
+	}
+
+	descriptor := NewDictionary()
+	descriptor.Add("Type", NewName("FontDescriptor"))
+	descriptor.Add("FontName", NewName(metrics.FontName))
+	if metrics.FamilyName != "" {
+		descriptor.Add("FontFamily", NewTextString(metrics.FamilyName))
+	}
+	descriptor.Add("Flags", NewIntNumeric(flags))
+	descriptor.Add("FontBBox", NewFloatArray(metrics.FontBBox[:]))
+	descriptor.Add("ItalicAngle", NewNumeric(metrics.ItalicAngle))
+	descriptor.Add("Ascent", NewNumeric(metrics.Ascender))
+	descriptor.Add("Descent", NewNumeric(metrics.Descender))
+	descriptor.Add("CapHeight", NewNumeric(metrics.CapHeight))
+	if metrics.XHeight != 0 {
+		descriptor.Add("XHeight", NewNumeric(metrics.XHeight))
+	}
+	descriptor.Add("StemV", NewNumeric(metrics.StemV))
+	return descriptor
+}
+
+// glyphNameForRune() returns the standard name of the glyph for r, or
+// "" if it has none.
+func glyphNameForRune(r rune) string {
+	if r < 0x80 && (r >= 'A' && r <= 'Z' || r >= 'a' && r <= 'z') {
+		return string(r)
+	}
+	return glyphNamesByRune[r]
+}
+
+var glyphNamesByRune = func() map[rune]string {
+	result := make(map[rune]string, len(glyphNames))
+	for name,r := range glyphNames {
+		result[r] = name
+	}
+	return result
+}()
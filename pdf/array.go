@@ -1,11 +1,20 @@
 package pdf
 
-import "github.com/mawicks/PDFiG/containers"
+import (
+	"fmt"
+	"github.com/mawicks/PDFiG/containers" )
 
 type ProtectedArray interface {
 	Object
 	Size() int
 	At(i int) Object
+	// Floats(), Ints(), and Strings() return the contents of an
+	// array of numbers, integers, or text strings, dereferencing
+	// elements as necessary.  The boolean return value is false
+	// if any element is of a different type.
+	Floats() ([]float64, bool)
+	Ints() ([]int, bool)
+	Strings() ([]string, bool)
 }
 
 type Array interface {
@@ -16,6 +25,13 @@ type Array interface {
 	Add(o Object)
 	PushFront(o Object)
 	Append(op ProtectedArray)
+	// Insert() inserts o before the element at index i, which
+	// may equal Size() to add o at the end.
+	Insert(i int, o Object)
+	// Remove() removes the element at index i.
+	Remove(i int)
+	// Set() replaces the element at index i with o.
+	Set(i int, o Object)
 }
 
 type array struct {
@@ -24,7 +40,36 @@ type array struct {
 
 // Constructor for standard implementation of Array
 func NewArray() Array {
-	return &array{containers.StackArrayDecorator{containers.NewDynamicArray(4)}}
+	return &array{containers.StackArrayDecorator{Array: containers.NewDynamicArray(4)}}
+}
+
+// NewFloatArray() constructs an Array of numbers, as used for
+// rectangles, matrices, and dash patterns.  Integral values are
+// written as integers.
+func NewFloatArray(values []float64) Array {
+	result := NewArray()
+	for _,v := range values {
+		result.Add(NewNumeric(v))
+	}
+	return result
+}
+
+// NewIntArray() constructs an Array of integers, as used for widths.
+func NewIntArray(values []int) Array {
+	result := NewArray()
+	for _,v := range values {
+		result.Add(NewIntNumeric(v))
+	}
+	return result
+}
+
+// NewStringArray() constructs an Array of text strings.
+func NewStringArray(values []string) Array {
+	result := NewArray()
+	for _,v := range values {
+		result.Add(NewTextString(v))
+	}
+	return result
 }
 
 // Return value of Clone() can safely be cast to Array.
@@ -77,6 +122,84 @@ func (a *array) Append(op ProtectedArray) {
 	}
 }
 
+func (a *array) checkIndex(i, size int) {
+	if i < 0 || i >= size {
+		panic(fmt.Sprintf("Array index %d out of range [0,%d)", i, size))
+	}
+}
+
+func (a *array) Insert(i int, o Object) {
+	size := a.Size()
+	a.checkIndex(i, size+1)
+	a.array.SetSize(uint(size+1))
+	for j := size; j > i; j-- {
+		*a.array.At(uint(j)) = *a.array.At(uint(j-1))
+	}
+	*a.array.At(uint(i)) = o.Unprotect()
+}
+
+func (a *array) Remove(i int) {
+	size := a.Size()
+	a.checkIndex(i, size)
+	for j := i; j < size-1; j++ {
+		*a.array.At(uint(j)) = *a.array.At(uint(j+1))
+	}
+	a.array.SetSize(uint(size-1))
+}
+
+func (a *array) Set(i int, o Object) {
+	a.checkIndex(i, a.Size())
+	*a.array.At(uint(i)) = o.Unprotect()
+}
+
+func (a *array) Floats() ([]float64, bool) {
+	return arrayFloats(a)
+}
+
+func (a *array) Ints() ([]int, bool) {
+	return arrayInts(a)
+}
+
+func (a *array) Strings() ([]string, bool) {
+	return arrayStrings(a)
+}
+
+func arrayFloats(a ProtectedArray) ([]float64, bool) {
+	result := make([]float64, a.Size())
+	for i := range result {
+		v,ok := numericValue(a.At(i))
+		if !ok {
+			return nil, false
+		}
+		result[i] = v
+	}
+	return result, true
+}
+
+func arrayInts(a ProtectedArray) ([]int, bool) {
+	result := make([]int, a.Size())
+	for i := range result {
+		n,ok := a.At(i).Dereference().(*IntNumeric)
+		if !ok {
+			return nil, false
+		}
+		result[i] = n.Value()
+	}
+	return result, true
+}
+
+func arrayStrings(a ProtectedArray) ([]string, bool) {
+	result := make([]string, a.Size())
+	for i := range result {
+		s,ok := a.At(i).Dereference().(ProtectString)
+		if !ok {
+			return nil, false
+		}
+		result[i] = DecodeTextString(s.Bytes())
+	}
+	return result, true
+}
+
 func (a *array) Serialize(w Writer, file ...File) {
 	w.WriteByte('[')
 	size := a.Size()
@@ -131,6 +254,18 @@ func (pa protectedArray) At(i int) Object {
 	return pa.a.At(i).Protect()
 }
 
+func (pa protectedArray) Floats() ([]float64, bool) {
+	return pa.a.Floats()
+}
+
+func (pa protectedArray) Ints() ([]int, bool) {
+	return pa.a.Ints()
+}
+
+func (pa protectedArray) Strings() ([]string, bool) {
+	return pa.a.Strings()
+}
+
 func (pa protectedArray) Serialize(w Writer, file ...File) {
 	pa.a.Serialize(w, file...)
 }
@@ -0,0 +1,158 @@
+package pdf
+
+import (
+	"crypto/md5"
+	"io/ioutil"
+	"time" )
+
+// An Attachment is a file embedded in a document.
+type Attachment struct {
+	// Name is the file name of the attachment.  It is also the
+	// key under which the attachment is stored in the document's
+	// /EmbeddedFiles name tree.
+	Name string
+	Description string
+	// MIMEType is the optional MIME media type of the contents,
+	// e.g., "text/csv".
+	MIMEType string
+	CreationDate time.Time
+	ModDate time.Time
+	Contents []byte
+}
+
+// embeddedFileStream() constructs the embedded file stream for the
+// attachment.
+func (a *Attachment) embeddedFileStream(sf *StreamFactory) Stream {
+	checksum := md5.Sum(a.Contents)
+
+	params := NewDictionary()
+	params.Add("Size", NewIntNumeric(len(a.Contents)))
+	params.Add("CheckSum", NewBinaryString(checksum[:]))
+	if !a.CreationDate.IsZero() {
+		params.Add("CreationDate", NewDate(a.CreationDate))
+	}
+	if !a.ModDate.IsZero() {
+		params.Add("ModDate", NewDate(a.ModDate))
+	}
+
+	stream := sf.New()
+	stream.Add("Type", NewName("EmbeddedFile"))
+	if a.MIMEType != "" {
+		stream.Add("Subtype", NewName(a.MIMEType))
+	}
+	stream.Add("Params", params)
+	stream.Write(a.Contents)
+	return stream
+}
+
+// fileSpecification() constructs a file specification dictionary
+// for the attachment whose embedded file stream is ef.
+func (a *Attachment) fileSpecification(ef Indirect) Dictionary {
+	efDictionary := NewDictionary()
+	efDictionary.Add("F", ef)
+	efDictionary.Add("UF", ef)
+
+	fileSpec := NewDictionary()
+	fileSpec.Add("Type", NewName("Filespec"))
+	fileSpec.Add("F", NewTextString(a.Name))
+	fileSpec.Add("UF", NewTextString(a.Name))
+	if a.Description != "" {
+		fileSpec.Add("Desc", NewTextString(a.Description))
+	}
+	fileSpec.Add("EF", efDictionary)
+	return fileSpec
+}
+
+// write() writes the embedded file stream and file specification
+// of the attachment to the files and returns a reference to the file
+// specification.
+func (a *Attachment) write(sf *StreamFactory, file ...File) Indirect {
+	ef := NewIndirect(file...).Write(a.embeddedFileStream(sf))
+	return NewIndirect(file...).Write(a.fileSpecification(ef))
+}
+
+// parseFileSpecification() constructs an Attachment from a file
+// specification dictionary.  The boolean return value is false if
+// the file specification does not contain an embedded file.
+func parseFileSpecification(fileSpec ProtectedDictionary) (Attachment, bool) {
+	var a Attachment
+
+	ef := fileSpec.GetDictionary("EF")
+	if ef == nil {
+		return a, false
+	}
+	stream := ef.GetStream("UF")
+	if stream == nil {
+		stream = ef.GetStream("F")
+	}
+	if stream == nil {
+		return a, false
+	}
+
+	if a.Name = textString(fileSpec, "UF"); a.Name == "" {
+		a.Name = textString(fileSpec, "F")
+	}
+	a.Description = textString(fileSpec, "Desc")
+
+	dictionary := stream.Dictionary()
+	a.MIMEType,_ = dictionary.GetName("Subtype")
+	if params := dictionary.GetDictionary("Params"); params != nil {
+		if b,ok := params.GetString("CreationDate"); ok {
+			a.CreationDate,_ = ParseDate(string(b))
+		}
+		if b,ok := params.GetString("ModDate"); ok {
+			a.ModDate,_ = ParseDate(string(b))
+		}
+	}
+
+	if reader := stream.Reader(); reader != nil {
+		a.Contents,_ = ioutil.ReadAll(reader)
+	}
+	return a, true
+}
+
+// loadEmbeddedFiles() initializes d.embeddedFiles from the catalog.
+func (d *Document) loadEmbeddedFiles() {
+	if d.embeddedFiles != nil {
+		return
+	}
+	var tree ProtectedDictionary
+	if names := d.catalog.GetDictionary("Names"); names != nil {
+		tree = names.GetDictionary("EmbeddedFiles")
+	}
+	d.embeddedFiles = ReadNameTree(tree)
+}
+
+// Attach() embeds a file in the document.  An existing attachment
+// with the same name is replaced.  Attach() returns a reference to
+// the file specification, which may also be used by annotations.
+func (d *Document) Attach(a Attachment) Indirect {
+	d.loadEmbeddedFiles()
+
+	fileSpec := a.write(d.streamFactory, d.file)
+	d.embeddedFiles.Add(string(NewTextString(a.Name).Bytes()), fileSpec)
+	d.embeddedFilesChanged = true
+	return fileSpec
+}
+
+// Attachments() returns the files embedded in the document, in the
+// order of the /EmbeddedFiles name tree.
+func (d *Document) Attachments() []Attachment {
+	d.loadEmbeddedFiles()
+
+	result := make([]Attachment, 0, d.embeddedFiles.Size())
+	d.embeddedFiles.ForEach(func(key string, value Object) {
+		if fileSpec,ok := value.Dereference().(ProtectedDictionary); ok {
+			if a,ok := parseFileSpecification(fileSpec); ok {
+				result = append(result, a)
+			}
+		}
+	})
+	return result
+}
+
+func (d *Document) finishEmbeddedFiles() {
+	if d.embeddedFilesChanged {
+		d.catalogDictionary("Names").Add("EmbeddedFiles", d.embeddedFiles.Build(d.file))
+	}
+}
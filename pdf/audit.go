@@ -0,0 +1,294 @@
+package pdf
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort")
+
+var auditUnsupported = errors.New(`Audit() requires a document read from a file or a MemoryFile`)
+
+// AuditCategory classifies the objects counted by Document.Audit().
+type AuditCategory int
+
+const (
+	ImageObjects AuditCategory = iota
+	FontObjects
+	ContentObjects
+	MetadataObjects
+	OtherObjects
+	// UnusedObjects are not reachable from the trailer, so
+	// Compact() would remove them.
+	UnusedObjects )
+
+func (c AuditCategory) String() string {
+	switch c {
+	case ImageObjects:
+		return "Images"
+	case FontObjects:
+		return "Fonts"
+	case ContentObjects:
+		return "Content streams"
+	case MetadataObjects:
+		return "Metadata"
+	case OtherObjects:
+		return "Other"
+	case UnusedObjects:
+		return "Unused"
+	}
+	return fmt.Sprintf("AuditCategory(%d)", int(c))
+}
+
+// maxLargestObjects is the number of objects listed in
+// AuditReport.Largest.
+const maxLargestObjects = 10
+
+// AuditTotal is the number of objects in a group and the number of
+// bytes they occupy in the file.
+type AuditTotal struct {
+	Objects int
+	Bytes int64
+}
+
+// AuditedObject describes one object counted by Document.Audit().
+type AuditedObject struct {
+	Number ObjectNumber
+	Category AuditCategory
+	// Type is the object's /Type, followed by its /Subtype for
+	// XObjects, or "stream", "dictionary", "array", or "other" if
+	// it has none.
+	Type string
+	Bytes int64
+}
+
+// AuditReport is the result of Document.Audit().
+type AuditReport struct {
+	// FileSize is the size of the file when it was opened.
+	FileSize int64
+	// Categories and Types total the objects by category and by
+	// type.
+	Categories map[AuditCategory]AuditTotal
+	Types map[string]AuditTotal
+	// Largest lists the largest objects, largest first.
+	Largest []AuditedObject
+}
+
+// Overhead() returns the number of bytes of the file outside the
+// objects counted: headers, xref tables, and trailers, and the
+// objects of earlier revisions that later ones replaced or deleted.
+// Compact() removes the latter, along with UnusedObjects.
+func (r *AuditReport) Overhead() int64 {
+	overhead := r.FileSize
+	for _,total := range r.Categories {
+		overhead -= total.Bytes
+	}
+	return overhead
+}
+
+func (r *AuditReport) String() string {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "File size: %d bytes\n", r.FileSize)
+	for c:=ImageObjects; c<=UnusedObjects; c++ {
+		total := r.Categories[c]
+		fmt.Fprintf(&b, "%-16s %6d objects %12d bytes\n", c.String() + ":", total.Objects, total.Bytes)
+	}
+	fmt.Fprintf(&b, "%-16s %27d bytes\n", "Overhead:", r.Overhead())
+	types := make([]string, 0, len(r.Types))
+	for t := range r.Types {
+		types = append(types, t)
+	}
+	sort.Slice(types, func(i, j int) bool {
+		return r.Types[types[i]].Bytes > r.Types[types[j]].Bytes
+	})
+	b.WriteString("By type:\n")
+	for _,t := range types {
+		fmt.Fprintf(&b, "  %-22s %6d objects %12d bytes\n", t, r.Types[t].Objects, r.Types[t].Bytes)
+	}
+	b.WriteString("Largest objects:\n")
+	for _,o := range r.Largest {
+		fmt.Fprintf(&b, "  %-12v %-22s %-16s %12d bytes\n", o.Number, o.Type, o.Category, o.Bytes)
+	}
+	return b.String()
+}
+
+// Audit() reports how the bytes of the document's file are spent: the
+// size of each object of the file as it was opened, totalled by
+// category and by type, so that one can see what Compact() or
+// downsampling images would save.  Objects are classified by what
+// refers to them: the streams and dictionaries of fonts are
+// FontObjects, page contents and form XObjects are ContentObjects,
+// and objects not reachable from the trailer are UnusedObjects.
+// Objects written since the document was opened are not included.
+func (d *Document) Audit() (*AuditReport, error) {
+	var f *file
+	switch t := d.file.(type) {
+	case *file:
+		f = t
+	case *MemoryFile:
+		f = t.file
+	default:
+		return nil, auditUnsupported
+	}
+	a := &auditor{
+		f: f,
+		report: &AuditReport{
+			FileSize: f.originalSize,
+			Categories: make(map[AuditCategory]AuditTotal),
+			Types: make(map[string]AuditTotal) },
+		categories: make(map[ObjectNumber]AuditCategory) }
+	a.walk()
+	for i:=uint(1); i<f.originalXrefSize; i++ {
+		if err := f.canceled(); err != nil {
+			return nil, err
+		}
+		<-f.semaphore
+		entry := (*f.xref.At(i)).(*xrefEntry)
+		inUse,byteOffset,generation := entry.inUse,entry.byteOffset,entry.generation
+		f.semaphore<-true
+		if !inUse || byteOffset >= uint64(f.originalSize) {
+			continue
+		}
+		a.count(ObjectNumber{uint32(i), generation}, byteOffset)
+	}
+	sort.Slice(a.report.Largest, func(i, j int) bool {
+		return a.report.Largest[i].Bytes > a.report.Largest[j].Bytes
+	})
+	return a.report, nil
+}
+
+type auditor struct {
+	f *file
+	report *AuditReport
+	// categories holds the category of each object reachable from
+	// the trailer.
+	categories map[ObjectNumber]AuditCategory
+	queue []auditedReference
+}
+
+// auditedReference is an object reached from the trailer whose
+// contents are still to be walked.
+type auditedReference struct {
+	object Object
+	category AuditCategory
+}
+
+// walk() classifies the objects reachable from the trailer.
+func (a *auditor) walk() {
+	a.visit(a.f.Trailer(), OtherObjects, "", false)
+	for len(a.queue) > 0 {
+		r := a.queue[0]
+		a.queue = a.queue[1:]
+		a.visit(r.object, r.category, "", false)
+	}
+}
+
+// visit() classifies the objects to which object refers.  Category is
+// that of the object containing it, which it reached under key, in a
+// page dictionary if onPage is true.
+func (a *auditor) visit(object Object, category AuditCategory, key string, onPage bool) {
+	switch t := object.(type) {
+	case ProtectedIndirect:
+		o := t.ObjectNumber(a.f)
+		if _,seen := a.categories[o]; seen {
+			return
+		}
+		resolved,err := a.f.Object(o)
+		if err != nil {
+			a.categories[o] = category
+			return
+		}
+		c := classifyObject(resolved, category, key, onPage)
+		a.categories[o] = c
+		a.queue = append(a.queue, auditedReference{resolved, c})
+	case ProtectedStream:
+		a.visit(t.Dictionary(), category, key, onPage)
+	case ProtectedArray:
+		for i:=0; i<t.Size(); i++ {
+			a.visit(t.At(i), category, key, onPage)
+		}
+	case ProtectedDictionary:
+		isPage := t.CheckNameValue("Type", "Page")
+		t.ForEach(func(key string, value Object) {
+			a.visit(value, category, key, isPage)
+		})
+	}
+}
+
+// classifyObject() returns the category of object, which was reached
+// under key from an object of category inherited, in a page
+// dictionary if onPage is true.
+func classifyObject(object Object, inherited AuditCategory, key string, onPage bool) AuditCategory {
+	d,_ := dictionaryOf(object)
+	_,isStream := object.(ProtectedStream)
+	switch {
+	case d != nil && isStream && d.CheckNameValue("Subtype", "Image"):
+		return ImageObjects
+	case d != nil && isStream && d.CheckNameValue("Type", "Metadata"):
+		return MetadataObjects
+	case d != nil && (d.CheckNameValue("Type", "Font") || d.CheckNameValue("Type", "FontDescriptor") || d.CheckNameValue("Type", "CMap")):
+		return FontObjects
+	case inherited == FontObjects, key == "FontFile", key == "FontFile2", key == "FontFile3":
+		return FontObjects
+	case d != nil && isStream && d.CheckNameValue("Subtype", "Form"), onPage && key == "Contents":
+		return ContentObjects
+	}
+	return OtherObjects
+}
+
+// auditedType() returns the type of object as described for
+// AuditedObject.Type.
+func auditedType(object Object) string {
+	if d,ok := dictionaryOf(object); ok {
+		if t,ok := d.GetName("Type"); ok {
+			if subtype,ok := d.GetName("Subtype"); ok && t == "XObject" {
+				return t + "/" + subtype
+			}
+			return t
+		}
+		if _,isStream := object.(ProtectedStream); isStream {
+			return "stream"
+		}
+		return "dictionary"
+	}
+	if _,ok := object.(ProtectedArray); ok {
+		return "array"
+	}
+	return "other"
+}
+
+// count() adds object o, at byteOffset, to the report.
+func (a *auditor) count(o ObjectNumber, byteOffset uint64) {
+	parser := a.f.newObjectParser(byteOffset, 0)
+	defer parser.release()
+	object,err := parser.ScanIndirect(o, a.f)
+	if err != nil {
+		return
+	}
+	size := parser.scanner.Position()
+	a.f.bytesRead(size)
+
+	category,reachable := a.categories[o]
+	if !reachable {
+		category = UnusedObjects
+	}
+	audited := AuditedObject{o, category, auditedType(object), size}
+	total := a.report.Categories[category]
+	a.report.Categories[category] = AuditTotal{total.Objects + 1, total.Bytes + size}
+	total = a.report.Types[audited.Type]
+	a.report.Types[audited.Type] = AuditTotal{total.Objects + 1, total.Bytes + size}
+
+	largest := a.report.Largest
+	if len(largest) < maxLargestObjects {
+		a.report.Largest = append(largest, audited)
+		return
+	}
+	smallest := 0
+	for i := range largest {
+		if largest[i].Bytes < largest[smallest].Bytes {
+			smallest = i
+		}
+	}
+	if size > largest[smallest].Bytes {
+		largest[smallest] = audited
+	}
+}
@@ -0,0 +1,146 @@
+package pdf_test
+
+// These benchmarks measure the paths on which the cost of generating
+// and reading large documents depends, so that changes to them can be
+// compared with
+//	go test -run XXX -bench . -benchmem ./pdf > old.txt
+// before and after the change, using benchstat.  BenchmarkParse reads
+// and decodes every object of the file named by $PDFIG_BENCHMARK_FILE,
+// if it is set, and otherwise of a generated document of 10,000
+// pages.
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/mawicks/PDFiG/pdf"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing" )
+
+// benchmarkPages is the number of pages of the generated documents.
+const benchmarkPages = 10000
+
+// benchmarkDictionary() returns a dictionary resembling a page, with
+// names, numbers, strings, arrays, and nested dictionaries.
+func benchmarkDictionary() pdf.Dictionary {
+	font := pdf.NewDictionary()
+	font.Add("Type", pdf.NewName("Font"))
+	font.Add("Subtype", pdf.NewName("Type1"))
+	font.Add("BaseFont", pdf.NewName("Helvetica"))
+	fonts := pdf.NewDictionary()
+	fonts.Add("F1", font)
+	resources := pdf.NewDictionary()
+	resources.Add("Font", fonts)
+
+	mediaBox := pdf.NewArray()
+	for _,v := range []float64{0, 0, 612, 792} {
+		mediaBox.Add(pdf.NewNumeric(v))
+	}
+	d := pdf.NewDictionary()
+	d.Add("Type", pdf.NewName("Page"))
+	d.Add("MediaBox", mediaBox)
+	d.Add("Resources", resources)
+	d.Add("Rotate", pdf.NewIntNumeric(90))
+	d.Add("UserUnit", pdf.NewNumeric(1.5))
+	d.Add("Note", pdf.NewTextString("Benchmark (page) dictionary"))
+	return d
+}
+
+func BenchmarkSerialize(b *testing.B) {
+	d := benchmarkDictionary()
+	f := pdf.NewMockFile(1, 0)
+	var buffer bytes.Buffer
+	b.ReportAllocs()
+	for i:=0; i<b.N; i++ {
+		buffer.Reset()
+		d.Serialize(&buffer, f)
+	}
+}
+
+func BenchmarkDictionaryGet(b *testing.B) {
+	d := benchmarkDictionary()
+	for i:=0; i<20; i++ {
+		d.Add(fmt.Sprintf("Key%d", i), pdf.NewIntNumeric(i))
+	}
+	b.ReportAllocs()
+	for i:=0; i<b.N; i++ {
+		d.GetName("Type")
+		d.GetInt("Key19")
+		d.GetDictionary("Resources")
+		d.Get("Missing")
+	}
+}
+
+func BenchmarkStreamCompression(b *testing.B) {
+	var contents strings.Builder
+	for i:=0; i<1000; i++ {
+		fmt.Fprintf(&contents, "BT /F1 12 Tf %d %d Td (Line %d of the page) Tj ET\n", 72, 720-i%60*12, i)
+	}
+	f := pdf.NewMockFile(1, 0)
+	var buffer bytes.Buffer
+	b.SetBytes(int64(contents.Len()))
+	b.ReportAllocs()
+	for i:=0; i<b.N; i++ {
+		s := pdf.NewStream()
+		flate := new(pdf.FlateFilter)
+		flate.SetCompressionLevel(9)
+		s.AddFilter(flate)
+		s.Write([]byte(contents.String()))
+		buffer.Reset()
+		s.Serialize(&buffer, f)
+	}
+}
+
+// writeBenchmarkDocument() writes a document of benchmarkPages pages,
+// each with a short content stream, to f.
+func writeBenchmarkDocument(f pdf.File) {
+	doc := pdf.NewDocumentFromFile(f)
+	for i:=0; i<benchmarkPages; i++ {
+		fmt.Fprintf(doc.NewPage(), "BT /F1 12 Tf 72 720 Td (Page %d) Tj ET", i+1)
+	}
+	doc.Close()
+}
+
+func BenchmarkWriteDocument(b *testing.B) {
+	b.ReportAllocs()
+	for i:=0; i<b.N; i++ {
+		f,_ := pdf.NewFileFromWriter(ioutil.Discard)
+		writeBenchmarkDocument(f)
+	}
+}
+
+func BenchmarkParse(b *testing.B) {
+	filename := os.Getenv("PDFIG_BENCHMARK_FILE")
+	if filename == "" {
+		filename = "/tmp/test-benchmark-parse.pdf"
+		os.Remove(filename)
+		f,_,err := pdf.OpenFile(filename, os.O_RDWR|os.O_CREATE)
+		if err != nil {
+			b.Fatalf(`OpenFile() failed: %v`, err)
+		}
+		writeBenchmarkDocument(f)
+	}
+	contents,err := ioutil.ReadFile(filename)
+	if err != nil {
+		b.Fatalf(`Unable to read %s: %v`, filename, err)
+	}
+	b.SetBytes(int64(len(contents)))
+	b.ResetTimer()
+	b.ReportAllocs()
+	for i:=0; i<b.N; i++ {
+		f,err := pdf.NewFileFromReader(bytes.NewReader(contents))
+		if err != nil {
+			b.Fatalf(`NewFileFromReader() failed: %v`, err)
+		}
+		size,_ := f.Trailer().GetInt("Size")
+		for n:=1; n<size; n++ {
+			if object,_ := f.Object(pdf.NewObjectNumber(uint32(n), 0)); object != nil {
+				if s,ok := object.(pdf.ProtectedStream); ok {
+					ioutil.ReadAll(s.Reader())
+				}
+			}
+		}
+		f.Close()
+	}
+}
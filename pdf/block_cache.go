@@ -0,0 +1,102 @@
+package pdf
+
+import (
+	"container/list"
+	"fmt"
+	"io"
+	"sync" )
+
+// blockCache is an io.ReaderAt that keeps the most recently read
+// blocks of another io.ReaderAt in memory, so that parsing objects
+// that lie near one another reads the file once rather than once for
+// each object.  Only complete blocks are kept, since the last block of
+// a file that is being appended to may still grow.
+type blockCache struct {
+	mutex sync.Mutex
+	r io.ReaderAt
+	blockSize int64
+	capacity int
+	blocks map[int64]*list.Element
+	// order has the most recently used block at the front.
+	order *list.List
+}
+
+type cachedBlock struct {
+	index int64
+	data []byte
+}
+
+func newBlockCache(r io.ReaderAt, blockSize int64, capacity int) *blockCache {
+	return &blockCache{
+		r: r,
+		blockSize: blockSize,
+		capacity: capacity,
+		blocks: make(map[int64]*list.Element),
+		order: list.New()}
+}
+
+// WithBlockCache() returns a FileOption that reads the objects of a
+// pre-existing file through a cache of the most recently read blocks
+// of blockSize bytes, holding at most blocks of them.  Without it,
+// each object is parsed from a separate read of the file, which is
+// slow when the file is on a network file system or is an
+// io.ReaderAt that makes a request for each read.  The contents of
+// long streams are read directly, bypassing the cache.
+func WithBlockCache(blockSize, blocks int) FileOption {
+	if blockSize <= 0 || blocks <= 0 {
+		panic(fmt.Sprintf("Invalid block cache of %d blocks of %d bytes", blocks, blockSize))
+	}
+	return func(f *file) {
+		f.blockCache = newBlockCache(f.file, int64(blockSize), blocks)
+	}
+}
+
+// readerAt() returns the io.ReaderAt from which objects are parsed.
+func (f *file) readerAt() io.ReaderAt {
+	if f.blockCache != nil {
+		return f.blockCache
+	}
+	return f.file
+}
+
+func (c *blockCache) ReadAt(p []byte, offset int64) (int, error) {
+	n := 0
+	for n < len(p) {
+		position := offset + int64(n)
+		index := position / c.blockSize
+		block,err := c.block(index)
+		start := position - index*c.blockSize
+		if start < int64(len(block)) {
+			n += copy(p[n:], block[start:])
+		}
+		if n < len(p) && int64(len(block)) < c.blockSize {
+			if err == nil {
+				err = io.EOF
+			}
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+// block() returns the block numbered index, which is shorter than the
+// block size only at the end of the file or if an error occurred.
+func (c *blockCache) block(index int64) ([]byte, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if element,exists := c.blocks[index]; exists {
+		c.order.MoveToFront(element)
+		return element.Value.(*cachedBlock).data, nil
+	}
+	data := make([]byte, c.blockSize)
+	n,err := c.r.ReadAt(data, index*c.blockSize)
+	if int64(n) < c.blockSize {
+		return data[:n], err
+	}
+	c.blocks[index] = c.order.PushFront(&cachedBlock{index, data})
+	if c.order.Len() > c.capacity {
+		oldest := c.order.Remove(c.order.Back()).(*cachedBlock)
+		delete(c.blocks, oldest.index)
+	}
+	return data, nil
+}
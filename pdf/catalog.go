@@ -0,0 +1,119 @@
+package pdf
+
+import (
+	"errors"
+	"reflect")
+
+var (
+	missingCatalog = errors.New(`File has no catalog`)
+	missingPages = errors.New(`Catalog has no /Pages`)
+	invalidCatalogVersion = errors.New(`Catalog /Version isn't a PDF version`))
+
+// Catalog is a typed view of a document catalog.  The entries other
+// than those of its fields, such as /StructTreeRoot or /ViewerPreferences,
+// are kept and written along with the fields.  Pages is required.
+// The other fields are nil, or empty, if the catalog lacks them.
+type Catalog struct {
+	// Version is the version of the file, such as "1.7", if the
+	// catalog overrides the version in the header.
+	Version string `pdf:",name,omitempty"`
+	// Pages refers to the root of the page tree.
+	Pages ProtectedIndirect
+	// PageLabels is the number tree of page labels.
+	PageLabels Object
+	// Names is the name dictionary.
+	Names Object
+	// Outlines is the outline dictionary.
+	Outlines Object
+	// OpenAction is the destination or action of the document
+	// when it is opened.
+	OpenAction Object
+	// AcroForm is the interactive form dictionary.
+	AcroForm Object
+	// Metadata refers to the XMP metadata stream.
+	Metadata ProtectedIndirect
+
+	// entries holds the catalog from which the Catalog was read.
+	entries ProtectedDictionary
+}
+
+// ReadCatalog() returns the catalog of f.
+func ReadCatalog(f File) (*Catalog, error) {
+	dictionary := f.Catalog()
+	if dictionary == nil {
+		return nil, missingCatalog
+	}
+	return catalogFromDictionary(dictionary)
+}
+
+func catalogFromDictionary(dictionary ProtectedDictionary) (*Catalog, error) {
+	c := &Catalog{entries: dictionary}
+	if err := Unmarshal(dictionary, c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Dictionary() returns the catalog dictionary that c represents, or
+// an error if c lacks a required entry.
+func (c *Catalog) Dictionary() (Dictionary, error) {
+	if c.Pages == nil {
+		return nil, missingPages
+	}
+	if _,ok := parseVersion(c.Version); c.Version != "" && !ok {
+		return nil, invalidCatalogVersion
+	}
+	typed,err := Marshal(c)
+	if err != nil {
+		return nil, err
+	}
+
+	result := NewDictionary()
+	if c.entries != nil {
+		result = c.entries.Clone().(Dictionary)
+	}
+	catalogType := reflect.TypeOf(*c)
+	for i:=0; i<catalogType.NumField(); i++ {
+		if tag,ok := parseTag(catalogType.Field(i)); ok {
+			result.Remove(tag.key)
+		}
+	}
+	result.Add("Type", NewName("Catalog"))
+	typed.ForEach(func(key string, value Object) {
+		result.Add(key, value)
+	})
+	return result, nil
+}
+
+// WriteCatalog() replaces the catalog of f with the one c represents.
+func WriteCatalog(f File, c *Catalog) error {
+	dictionary,err := c.Dictionary()
+	if err != nil {
+		return err
+	}
+	f.SetCatalog(dictionary)
+	return nil
+}
+
+// Catalog() returns the document's catalog as it will be written when
+// the document is closed.
+func (d *Document) Catalog() (*Catalog, error) {
+	c,err := catalogFromDictionary(d.catalog)
+	if err != nil {
+		return nil, err
+	}
+	c.Pages = d.pageTreeRootIndirect
+	return c, nil
+}
+
+// SetCatalog() replaces the entries of the document's catalog with
+// those of c.  The document's page tree takes the place of c.Pages
+// when the catalog is written.
+func (d *Document) SetCatalog(c *Catalog) error {
+	dictionary,err := c.Dictionary()
+	if err != nil {
+		return err
+	}
+	d.catalog = dictionary
+	return nil
+}
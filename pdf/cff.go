@@ -0,0 +1,534 @@
+package pdf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt" )
+
+// CFF (Compact Font Format) operators used when subsetting.  Two-byte
+// operators are 1200 plus the second byte.
+const (
+	cffCharset = 15
+	cffEncoding = 16
+	cffCharStrings = 17
+	cffPrivate = 18
+	cffSubrs = 19
+	cffROS = 1230
+	cffFDArray = 1236
+	cffFDSelect = 1237 )
+
+// cffEndchar is a Type 2 charstring that ends a glyph without drawing
+// anything.  It replaces the charstrings of glyphs omitted from a
+// subset.
+var cffEndchar = []byte{14}
+
+var invalidCFF = errors.New(`Invalid CFF font data`)
+
+// cffDictEntry is an operator of a CFF DICT with its operands, which
+// are kept in their encoded form.
+type cffDictEntry struct {
+	op int
+	operands [][]byte
+}
+
+type cffDict []cffDictEntry
+
+// cffFont is a parsed CFF font program.  Only the structures needed to
+// subset the font are interpreted; the rest are copied.
+type cffFont struct {
+	data []byte
+	header []byte
+	names []byte
+	strings []byte
+	globalSubrs []byte
+	top cffDict
+	charStrings [][]byte
+	// charset maps glyph IDs to SIDs, or to CIDs in a CID-keyed
+	// font.  It is nil for the predefined charsets.
+	charset []uint16
+	cidKeyed bool
+}
+
+// parseCFF() parses a CFF font program containing a single font.
+func parseCFF(data []byte) (font *cffFont, err error) {
+	defer func() {
+		// Slicing past the end of truncated data panics.
+		if r := recover(); r != nil {
+			font, err = nil, invalidCFF
+		}
+	}()
+
+	if len(data) < 4 || data[0] != 1 {
+		return nil, invalidCFF
+	}
+	font = &cffFont{data: data}
+	offset := int(data[2])
+	font.header = data[:offset]
+
+	var topDicts [][]byte
+	font.names, _, offset = cffIndex(data, offset)
+	_, topDicts, offset = cffIndex(data, offset)
+	font.strings, _, offset = cffIndex(data, offset)
+	font.globalSubrs, _, offset = cffIndex(data, offset)
+	if len(topDicts) != 1 {
+		return nil, fmt.Errorf(`CFF font set contains %d fonts`, len(topDicts))
+	}
+	if font.top, err = parseCFFDict(topDicts[0]); err != nil {
+		return nil, err
+	}
+
+	charStrings,ok := font.top.integer(cffCharStrings, 0)
+	if !ok {
+		return nil, invalidCFF
+	}
+	_, font.charStrings, _ = cffIndex(data, charStrings)
+	font.cidKeyed = font.top.get(cffROS) != nil
+
+	if offset,ok := font.top.integer(cffCharset, 0); ok && offset > 2 {
+		font.charset = parseCFFCharset(data[offset:], len(font.charStrings))
+	}
+	return font, nil
+}
+
+// cffIndex() reads the INDEX at offset, returning the whole INDEX, its
+// elements, and the offset following it.
+func cffIndex(data []byte, offset int) ([]byte, [][]byte, int) {
+	count := int(binary.BigEndian.Uint16(data[offset:]))
+	if count == 0 {
+		return data[offset:offset+2], nil, offset+2
+	}
+	offSize := int(data[offset+2])
+	if offSize < 1 || offSize > 4 {
+		panic(invalidCFF)
+	}
+	offsets := make([]int, count+1)
+	for i := range offsets {
+		for _,b := range data[offset+3+i*offSize:offset+3+(i+1)*offSize] {
+			offsets[i] = offsets[i]<<8 | int(b)
+		}
+	}
+	// Offsets are relative to the byte preceding the data.
+	base := offset + 2 + (count+1)*offSize
+	elements := make([][]byte, count)
+	for i := range elements {
+		elements[i] = data[base+offsets[i]:base+offsets[i+1]]
+	}
+	end := base + offsets[count]
+	return data[offset:end], elements, end
+}
+
+// writeCFFIndex() writes elements as an INDEX.
+func writeCFFIndex(b *bytes.Buffer, elements [][]byte) {
+	binary.Write(b, binary.BigEndian, uint16(len(elements)))
+	if len(elements) == 0 {
+		return
+	}
+	total := 1
+	for _,element := range elements {
+		total += len(element)
+	}
+	offSize := 1
+	for total >= 1<<(8*uint(offSize)) {
+		offSize++
+	}
+	b.WriteByte(byte(offSize))
+	offset := 1
+	for i:=0; i<=len(elements); i++ {
+		for shift:=8*(offSize-1); shift>=0; shift-=8 {
+			b.WriteByte(byte(offset >> uint(shift)))
+		}
+		if i < len(elements) {
+			offset += len(elements[i])
+		}
+	}
+	for _,element := range elements {
+		b.Write(element)
+	}
+}
+
+// parseCFFDict() splits a DICT into operators and operands.
+func parseCFFDict(data []byte) (cffDict, error) {
+	var (
+		dict cffDict
+		operands [][]byte )
+	for i:=0; i<len(data); {
+		b0 := data[i]
+		size := 0
+		switch {
+		case b0 == 12:
+			if i+1 >= len(data) {
+				return nil, invalidCFF
+			}
+			dict = append(dict, cffDictEntry{1200 + int(data[i+1]), operands})
+			operands = nil
+			i += 2
+			continue
+		case b0 <= 21:
+			dict = append(dict, cffDictEntry{int(b0), operands})
+			operands = nil
+			i++
+			continue
+		case b0 == 28:
+			size = 3
+		case b0 == 29:
+			size = 5
+		case b0 == 30:
+			// A real number ends with a 0xf nibble.
+			size = 1
+			for i+size < len(data) && data[i+size]&0x0f != 0x0f && data[i+size]&0xf0 != 0xf0 {
+				size++
+			}
+			size++
+		case b0 >= 32 && b0 <= 246:
+			size = 1
+		case b0 >= 247 && b0 <= 254:
+			size = 2
+		default:
+			return nil, invalidCFF
+		}
+		if i+size > len(data) {
+			return nil, invalidCFF
+		}
+		operands = append(operands, data[i:i+size])
+		i += size
+	}
+	return dict, nil
+}
+
+// get() returns the operands of op, or nil if op is absent.
+func (dict cffDict) get(op int) [][]byte {
+	for _,entry := range dict {
+		if entry.op == op {
+			return entry.operands
+		}
+	}
+	return nil
+}
+
+// integer() returns the integer operand of op at index.
+func (dict cffDict) integer(op, index int) (int, bool) {
+	operands := dict.get(op)
+	if index >= len(operands) {
+		return 0, false
+	}
+	return cffInteger(operands[index])
+}
+
+// set() replaces the operands of op, adding op if it is absent.
+func (dict cffDict) set(op int, operands ...[]byte) cffDict {
+	for i := range dict {
+		if dict[i].op == op {
+			dict[i].operands = operands
+			return dict
+		}
+	}
+	return append(dict, cffDictEntry{op, operands})
+}
+
+// bytes() encodes the DICT.  The ROS operator must come first.
+func (dict cffDict) bytes() []byte {
+	var b bytes.Buffer
+	for _,entry := range dict {
+		if entry.op != cffROS {
+			continue
+		}
+		for _,operand := range entry.operands {
+			b.Write(operand)
+		}
+		b.Write([]byte{12, cffROS - 1200})
+	}
+	for _,entry := range dict {
+		if entry.op == cffROS {
+			continue
+		}
+		for _,operand := range entry.operands {
+			b.Write(operand)
+		}
+		if entry.op >= 1200 {
+			b.Write([]byte{12, byte(entry.op - 1200)})
+		} else {
+			b.WriteByte(byte(entry.op))
+		}
+	}
+	return b.Bytes()
+}
+
+// cffInteger() decodes an integer operand.
+func cffInteger(operand []byte) (int, bool) {
+	b0 := int(operand[0])
+	switch {
+	case b0 == 28:
+		return int(int16(binary.BigEndian.Uint16(operand[1:]))), true
+	case b0 == 29:
+		return int(int32(binary.BigEndian.Uint32(operand[1:]))), true
+	case b0 >= 32 && b0 <= 246:
+		return b0 - 139, true
+	case b0 >= 247 && b0 <= 250:
+		return (b0-247)*256 + int(operand[1]) + 108, true
+	case b0 >= 251 && b0 <= 254:
+		return -(b0-251)*256 - int(operand[1]) - 108, true
+	}
+	return 0, false
+}
+
+// cffOffset() encodes an integer in five bytes, so that the size of a
+// DICT doesn't depend on the offsets it contains.
+func cffOffset(n int) []byte {
+	operand := []byte{29, 0, 0, 0, 0}
+	binary.BigEndian.PutUint32(operand[1:], uint32(n))
+	return operand
+}
+
+// parseCFFCharset() reads a charset for numGlyphs glyphs.
+func parseCFFCharset(data []byte, numGlyphs int) []uint16 {
+	charset := make([]uint16, 1, numGlyphs)
+	switch data[0] {
+	case 0:
+		for i:=1; i<numGlyphs; i++ {
+			charset = append(charset, binary.BigEndian.Uint16(data[2*i-1:]))
+		}
+	case 1, 2:
+		for p:=1; len(charset) < numGlyphs; {
+			first := int(binary.BigEndian.Uint16(data[p:]))
+			var left int
+			if data[0] == 1 {
+				left = int(data[p+2])
+				p += 3
+			} else {
+				left = int(binary.BigEndian.Uint16(data[p+2:]))
+				p += 4
+			}
+			for i:=0; i<=left && len(charset) < numGlyphs; i++ {
+				charset = append(charset, uint16(first+i))
+			}
+		}
+	default:
+		panic(invalidCFF)
+	}
+	return charset
+}
+
+// cffCharsetSize() returns the length of the charset at data.
+func cffCharsetSize(data []byte, numGlyphs int) int {
+	switch data[0] {
+	case 0:
+		return 1 + 2*(numGlyphs-1)
+	case 1, 2:
+		p := 1
+		for covered:=1; covered < numGlyphs; {
+			if data[0] == 1 {
+				covered += 1 + int(data[p+2])
+				p += 3
+			} else {
+				covered += 1 + int(binary.BigEndian.Uint16(data[p+2:]))
+				p += 4
+			}
+		}
+		return p
+	}
+	panic(invalidCFF)
+}
+
+// cffEncodingSize() returns the length of the encoding at data.
+func cffEncodingSize(data []byte) int {
+	var size int
+	switch data[0] & 0x7f {
+	case 0:
+		size = 2 + int(data[1])
+	case 1:
+		size = 2 + 2*int(data[1])
+	default:
+		panic(invalidCFF)
+	}
+	if data[0] & 0x80 != 0 {
+		size += 1 + 3*int(data[size])
+	}
+	return size
+}
+
+// cffFDSelectSize() returns the length of the FDSelect at data.
+func cffFDSelectSize(data []byte, numGlyphs int) int {
+	switch data[0] {
+	case 0:
+		return 1 + numGlyphs
+	case 3:
+		return 5 + 3*int(binary.BigEndian.Uint16(data[1:]))
+	}
+	panic(invalidCFF)
+}
+
+// cid() returns the CID of a glyph in a CID-keyed font.
+func (font *cffFont) cid(gid uint16) uint16 {
+	if !font.cidKeyed || font.charset == nil || int(gid) >= len(font.charset) {
+		return gid
+	}
+	return font.charset[gid]
+}
+
+// subset() returns the font program with the charstrings of the glyphs
+// not in used replaced by an empty glyph.  Glyph IDs are unchanged,
+// so that the font's other tables and the codes already written in
+// content streams remain valid.  Subroutines are kept.
+func (font *cffFont) subset(used map[uint16]bool) (result []byte, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			result, err = nil, invalidCFF
+		}
+	}()
+
+	charStrings := make([][]byte, len(font.charStrings))
+	for gid,charString := range font.charStrings {
+		if gid == 0 || used[uint16(gid)] {
+			charStrings[gid] = charString
+		} else {
+			charStrings[gid] = cffEndchar
+		}
+	}
+	numGlyphs := len(charStrings)
+
+	// Tables that are copied unchanged, keyed by the Top DICT
+	// operator that locates them.
+	copied := make(map[int][]byte)
+	if offset,ok := font.top.integer(cffCharset, 0); ok && offset > 2 {
+		copied[cffCharset] = font.data[offset:offset+cffCharsetSize(font.data[offset:], numGlyphs)]
+	}
+	if offset,ok := font.top.integer(cffEncoding, 0); ok && offset > 1 {
+		copied[cffEncoding] = font.data[offset:offset+cffEncodingSize(font.data[offset:])]
+	}
+	if offset,ok := font.top.integer(cffFDSelect, 0); ok {
+		copied[cffFDSelect] = font.data[offset:offset+cffFDSelectSize(font.data[offset:], numGlyphs)]
+	}
+
+	// Private DICTs with their local subroutines, and the Font
+	// DICTs of a CID-keyed font, which locate them.
+	var (
+		fontDicts []cffDict
+		privates [][]byte
+		subrs [][]byte )
+	addPrivate := func(dict cffDict) error {
+		size,ok1 := dict.integer(cffPrivate, 0)
+		offset,ok2 := dict.integer(cffPrivate, 1)
+		if !ok1 || !ok2 {
+			privates = append(privates, nil)
+			subrs = append(subrs, nil)
+			return nil
+		}
+		private,err := parseCFFDict(font.data[offset:offset+size])
+		if err != nil {
+			return err
+		}
+		var local []byte
+		if subrsOffset,ok := private.integer(cffSubrs, 0); ok {
+			local,_,_ = cffIndex(font.data, offset+subrsOffset)
+			private = private.set(cffSubrs, cffOffset(0))
+		}
+		privates = append(privates, private.bytes())
+		subrs = append(subrs, local)
+		return nil
+	}
+	if offset,ok := font.top.integer(cffFDArray, 0); ok {
+		_,elements,_ := cffIndex(font.data, offset)
+		for _,element := range elements {
+			dict,err := parseCFFDict(element)
+			if err != nil {
+				return nil, err
+			}
+			fontDicts = append(fontDicts, dict)
+			if err := addPrivate(dict); err != nil {
+				return nil, err
+			}
+		}
+	} else if err := addPrivate(font.top); err != nil {
+		return nil, err
+	}
+
+	// Replace every offset with a five-byte placeholder so that
+	// the layout can be computed before the offsets are known.
+	top := append(cffDict(nil), font.top...)
+	for op := range copied {
+		top = top.set(op, cffOffset(0))
+	}
+	top = top.set(cffCharStrings, cffOffset(0))
+	if fontDicts != nil {
+		top = top.set(cffFDArray, cffOffset(0))
+	} else if privates[0] != nil {
+		top = top.set(cffPrivate, cffOffset(0), cffOffset(0))
+	}
+	for i,dict := range fontDicts {
+		if dict.get(cffPrivate) != nil {
+			fontDicts[i] = dict.set(cffPrivate, cffOffset(0), cffOffset(0))
+		}
+	}
+
+	layout := func(topDictSize int) (cffDict, []byte) {
+		var b bytes.Buffer
+		start := len(font.header) + len(font.names) + topDictSize + len(font.strings) + len(font.globalSubrs)
+		for _,op := range []int{cffCharset, cffEncoding, cffFDSelect} {
+			if table,exists := copied[op]; exists {
+				top = top.set(op, cffOffset(start+b.Len()))
+				b.Write(table)
+			}
+		}
+		top = top.set(cffCharStrings, cffOffset(start+b.Len()))
+		writeCFFIndex(&b, charStrings)
+
+		// Private DICTs follow the Font DICTs, each followed
+		// by its subroutines.
+		var fdArray bytes.Buffer
+		if fontDicts != nil {
+			placeholder := make([][]byte, len(fontDicts))
+			for i,dict := range fontDicts {
+				placeholder[i] = dict.bytes()
+			}
+			writeCFFIndex(&fdArray, placeholder)
+		}
+		privateStart := start + b.Len() + fdArray.Len()
+		var privateData bytes.Buffer
+		for i,private := range privates {
+			if private == nil {
+				continue
+			}
+			offset := privateStart + privateData.Len()
+			if subrs[i] != nil {
+				dict,_ := parseCFFDict(private)
+				private = dict.set(cffSubrs, cffOffset(len(private))).bytes()
+			}
+			privateData.Write(private)
+			privateData.Write(subrs[i])
+			if fontDicts != nil {
+				fontDicts[i] = fontDicts[i].set(cffPrivate, cffOffset(len(private)), cffOffset(offset))
+			} else {
+				top = top.set(cffPrivate, cffOffset(len(private)), cffOffset(offset))
+			}
+		}
+		if fontDicts != nil {
+			top = top.set(cffFDArray, cffOffset(start+b.Len()))
+			elements := make([][]byte, len(fontDicts))
+			for i,dict := range fontDicts {
+				elements[i] = dict.bytes()
+			}
+			writeCFFIndex(&b, elements)
+		}
+		b.Write(privateData.Bytes())
+		return top, b.Bytes()
+	}
+
+	// The Top DICT's size is fixed once all of its offsets are
+	// five-byte placeholders, so it can be measured before the
+	// offsets are filled in.
+	var topIndex bytes.Buffer
+	writeCFFIndex(&topIndex, [][]byte{top.bytes()})
+	top, tail := layout(topIndex.Len())
+	topIndex.Reset()
+	writeCFFIndex(&topIndex, [][]byte{top.bytes()})
+
+	var b bytes.Buffer
+	b.Write(font.header)
+	b.Write(font.names)
+	b.Write(topIndex.Bytes())
+	b.Write(font.strings)
+	b.Write(font.globalSubrs)
+	b.Write(tail)
+	return b.Bytes(), nil
+}
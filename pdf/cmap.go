@@ -0,0 +1,391 @@
+package pdf
+
+import (
+	"errors"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"unicode/utf16"
+	"unicode/utf8" )
+
+// A CharCode is a character code read from a string shown with a
+// composite font.  Codes are one to four bytes long, and codes of
+// different lengths are distinct even if their values are equal.
+type CharCode struct {
+	Code uint32
+	Length int
+}
+
+// A CMap maps the character codes of a composite (Type0) font to CIDs,
+// which select glyphs, and may also map codes to Unicode text, as
+// /ToUnicode CMaps do.  CMaps are read by ParseCMap(); the predefined
+// CMaps named by fonts are obtained from PredefinedCMap().
+type CMap struct {
+	// Name is the /CMapName.
+	Name string
+	// Vertical is true for CMaps, such as Identity-V, whose
+	// /WMode is 1.
+	Vertical bool
+
+	codespaces []codespaceRange
+	// cidRanges is sorted by code length and then by first code.
+	cidRanges []cidRange
+	cids map[CharCode]uint32
+	unicode map[uint32]string
+	// unicodeEncoding, if not empty, is the Unicode encoding
+	// ("UCS2", "UTF16", "UTF8", or "UTF32") of the codes of a
+	// predefined Unicode-based CMap, for which the code is its
+	// own text.
+	unicodeEncoding string
+	// parent is the CMap named by usecmap, which supplies
+	// mappings this CMap lacks.
+	parent *CMap
+}
+
+type codespaceRange struct {
+	low, high []byte
+}
+
+type cidRange struct {
+	first, last uint32
+	length int
+	cid uint32
+}
+
+var (
+	cmapMutex sync.RWMutex
+	registeredCMaps = make(map[string]*CMap)
+	invalidCMap = errors.New(`CMap contains no mappings`) )
+
+// ParseCMap() reads a CMap from r, such as the contents of a font's
+// /Encoding or /ToUnicode stream.  A CMap that refers with usecmap to
+// a predefined CMap that PredefinedCMap() doesn't know inherits no
+// mappings from it.
+func ParseCMap(r io.Reader) (*CMap, error) {
+	cmap := &CMap{cids: make(map[CharCode]uint32), unicode: make(map[uint32]string)}
+	parser := NewContentParser(r)
+	var err error
+	for {
+		var op Operation
+		op,err = parser.Next()
+		if err != nil {
+			break
+		}
+		cmap.interpret(op)
+	}
+	sort.Slice(cmap.cidRanges, func(i, j int) bool {
+		a,b := cmap.cidRanges[i], cmap.cidRanges[j]
+		return a.length < b.length || (a.length == b.length && a.first < b.first)
+	})
+	if err != io.EOF && cmap.empty() {
+		return nil, err
+	}
+	if cmap.empty() && cmap.parent == nil {
+		return nil, invalidCMap
+	}
+	return cmap, nil
+}
+
+func (cmap *CMap) empty() bool {
+	return len(cmap.codespaces) == 0 && len(cmap.cidRanges) == 0 && len(cmap.cids) == 0 && len(cmap.unicode) == 0
+}
+
+// interpret() applies a single operation of a CMap program.
+// Operations that don't affect the mappings are ignored.
+func (cmap *CMap) interpret(op Operation) {
+	operands := op.Operands
+	switch op.Operator {
+	case "def":
+		if len(operands) != 2 {
+			return
+		}
+		key,_ := operands[0].(Name)
+		if key == nil {
+			return
+		}
+		switch key.String() {
+		case "CMapName":
+			if name,ok := operands[1].(Name); ok {
+				cmap.Name = name.String()
+			}
+		case "WMode":
+			if mode,ok := numericValue(operands[1]); ok {
+				cmap.Vertical = mode == 1
+			}
+		}
+	case "usecmap":
+		if len(operands) == 1 {
+			if name,ok := operands[0].(Name); ok {
+				cmap.parent = PredefinedCMap(name.String())
+			}
+		}
+	case "endcodespacerange":
+		for i:=0; i+1<len(operands); i+=2 {
+			low,ok1 := operands[i].(String)
+			high,ok2 := operands[i+1].(String)
+			if ok1 && ok2 && len(low.Bytes()) == len(high.Bytes()) && len(low.Bytes()) > 0 && len(low.Bytes()) <= 4 {
+				cmap.codespaces = append(cmap.codespaces, codespaceRange{low.Bytes(), high.Bytes()})
+			}
+		}
+	case "endcidrange":
+		for i:=0; i+2<len(operands); i+=3 {
+			low,ok1 := operands[i].(String)
+			high,ok2 := operands[i+1].(String)
+			cid,ok3 := numericValue(operands[i+2])
+			if !ok1 || !ok2 || !ok3 || len(low.Bytes()) != len(high.Bytes()) {
+				continue
+			}
+			first,last := codeValue(low.Bytes()), codeValue(high.Bytes())
+			if last >= first {
+				cmap.cidRanges = append(cmap.cidRanges, cidRange{first, last, len(low.Bytes()), uint32(cid)})
+			}
+		}
+	case "endcidchar":
+		for i:=0; i+1<len(operands); i+=2 {
+			code,ok1 := operands[i].(String)
+			cid,ok2 := numericValue(operands[i+1])
+			if ok1 && ok2 {
+				cmap.cids[CharCode{codeValue(code.Bytes()), len(code.Bytes())}] = uint32(cid)
+			}
+		}
+	case "endbfchar":
+		for i:=0; i+1<len(operands); i+=2 {
+			code,ok1 := operands[i].(String)
+			destination,ok2 := operands[i+1].(String)
+			if ok1 && ok2 {
+				cmap.unicode[codeValue(code.Bytes())] = decodeUTF16(destination.Bytes())
+			}
+		}
+	case "endbfrange":
+		for i:=0; i+2<len(operands); i+=3 {
+			low,ok1 := operands[i].(String)
+			high,ok2 := operands[i+1].(String)
+			if !ok1 || !ok2 {
+				continue
+			}
+			first, last := codeValue(low.Bytes()), codeValue(high.Bytes())
+			if last < first || last-first > 0xffff {
+				continue
+			}
+			switch destination := operands[i+2].(type) {
+			case String:
+				units := utf16Units(destination.Bytes())
+				if len(units) == 0 {
+					continue
+				}
+				for c:=first; c<=last; c++ {
+					// The last code unit is incremented
+					// for each code in the range.
+					shifted := append([]uint16(nil), units...)
+					shifted[len(shifted)-1] += uint16(c-first)
+					cmap.unicode[c] = string(utf16.Decode(shifted))
+				}
+			case Array:
+				for c:=first; c<=last && int(c-first)<destination.Size(); c++ {
+					if s,ok := destination.At(int(c-first)).(String); ok {
+						cmap.unicode[c] = decodeUTF16(s.Bytes())
+					}
+				}
+			}
+		}
+	}
+}
+
+// Codes() splits s into character codes using the CMap's codespace
+// ranges.  Bytes that begin no code in the codespace are read as
+// codes of the shortest length the CMap uses, which map to CID 0.
+func (cmap *CMap) Codes(s []byte) []CharCode {
+	codespaces := cmap.codespaceRanges()
+	shortest := 4
+	for _,r := range codespaces {
+		if len(r.low) < shortest {
+			shortest = len(r.low)
+		}
+	}
+	if len(codespaces) == 0 {
+		shortest = 2
+	}
+
+	codes := make([]CharCode, 0, len(s)/shortest)
+	for i:=0; i<len(s); {
+		length := 0
+		for _,r := range codespaces {
+			if r.contains(s[i:]) && (length == 0 || len(r.low) < length) {
+				length = len(r.low)
+			}
+		}
+		if length == 0 {
+			length = shortest
+		}
+		if i+length > len(s) {
+			break
+		}
+		codes = append(codes, CharCode{codeValue(s[i:i+length]), length})
+		i += length
+	}
+	return codes
+}
+
+func (cmap *CMap) codespaceRanges() []codespaceRange {
+	for c := cmap; c != nil; c = c.parent {
+		if len(c.codespaces) > 0 {
+			return c.codespaces
+		}
+	}
+	return nil
+}
+
+// contains() returns true if s begins with a code in the range.  Each
+// byte of the code must lie between the corresponding bytes of low
+// and high.
+func (r codespaceRange) contains(s []byte) bool {
+	if len(s) < len(r.low) {
+		return false
+	}
+	for i := range r.low {
+		if s[i] < r.low[i] || s[i] > r.high[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// CID() returns the CID to which code is mapped, or 0, the CID of the
+// .notdef glyph, if it isn't mapped.
+func (cmap *CMap) CID(code CharCode) uint32 {
+	for c := cmap; c != nil; c = c.parent {
+		if cid,exists := c.cids[code]; exists {
+			return cid
+		}
+		ranges := c.cidRanges
+		i := sort.Search(len(ranges), func(i int) bool {
+			return ranges[i].length > code.Length || (ranges[i].length == code.Length && ranges[i].first > code.Code)
+		})
+		if i > 0 {
+			if r := ranges[i-1]; r.length == code.Length && code.Code <= r.last {
+				return r.cid + code.Code - r.first
+			}
+		}
+	}
+	return 0
+}
+
+// Unicode() returns the text to which code is mapped by bfchar or
+// bfrange mappings or, for a predefined Unicode-based CMap such as
+// UniJIS-UTF16-H, the text the code encodes.
+func (cmap *CMap) Unicode(code CharCode) (string, bool) {
+	for c := cmap; c != nil; c = c.parent {
+		if text,exists := c.unicode[code.Code]; exists {
+			return text, true
+		}
+		if c.unicodeEncoding != "" {
+			return decodeUnicodeCode(code, c.unicodeEncoding)
+		}
+	}
+	return "", false
+}
+
+// decodeUnicodeCode() returns the text of a code of a Unicode-based
+// CMap.
+func decodeUnicodeCode(code CharCode, encoding string) (string, bool) {
+	b := make([]byte, code.Length)
+	for i:=code.Length-1; i>=0; i-- {
+		b[i] = byte(code.Code >> (8*uint(code.Length-1-i)))
+	}
+	switch encoding {
+	case "UTF8":
+		if r,_ := utf8.DecodeRune(b); r != utf8.RuneError {
+			return string(r), true
+		}
+		return "", false
+	case "UTF32":
+		return string(rune(code.Code)), true
+	}
+	return decodeUTF16(b), true
+}
+
+// RegisterCMap() makes cmap available under name to PredefinedCMap(),
+// and so to text extraction.  Applications can register predefined
+// CMaps that are not built in, such as 90ms-RKSJ-H, and the
+// CID-to-Unicode CMaps, such as Adobe-Japan1-UCS2, that map the CIDs
+// of fonts with a given /CIDSystemInfo to text.  Adobe publishes both
+// kinds.
+func RegisterCMap(name string, cmap *CMap) {
+	cmapMutex.Lock()
+	defer cmapMutex.Unlock()
+	registeredCMaps[name] = cmap
+}
+
+// PredefinedCMap() returns the named predefined CMap, or nil if it is
+// neither built in nor registered with RegisterCMap().  Identity-H,
+// Identity-V, and the Unicode-based CMaps, such as UniGB-UCS2-H and
+// UniJIS-UTF16-H, are built in, although the latter map codes only to
+// text and not to CIDs.
+func PredefinedCMap(name string) *CMap {
+	cmapMutex.RLock()
+	cmap,exists := registeredCMaps[name]
+	cmapMutex.RUnlock()
+	if exists {
+		return cmap
+	}
+
+	cmap = &CMap{Name: name, Vertical: strings.HasSuffix(name, "-V")}
+	switch {
+	case name == "Identity-H" || name == "Identity-V":
+		cmap.codespaces = []codespaceRange{{[]byte{0, 0}, []byte{0xff, 0xff}}}
+		cmap.cidRanges = []cidRange{{0, 0xffff, 2, 0}}
+		return cmap
+	case strings.HasPrefix(name, "Uni"):
+		for _,encoding := range []string{"UCS2", "UTF16", "UTF8", "UTF32"} {
+			if strings.Contains(name, "-"+encoding+"-") {
+				cmap.unicodeEncoding = encoding
+				cmap.codespaces = unicodeCodespaces[encoding]
+				return cmap
+			}
+		}
+	}
+	return nil
+}
+
+// unicodeCodespaces are the codespace ranges of the Unicode encodings
+// used by predefined CMaps.
+var unicodeCodespaces = map[string][]codespaceRange {
+	"UCS2": {{[]byte{0, 0}, []byte{0xff, 0xff}}},
+	"UTF16": {
+		{[]byte{0, 0}, []byte{0xd7, 0xff}},
+		{[]byte{0xd8, 0, 0xdc, 0}, []byte{0xdb, 0xff, 0xdf, 0xff}},
+		{[]byte{0xe0, 0}, []byte{0xff, 0xff}} },
+	"UTF8": {
+		{[]byte{0}, []byte{0x7f}},
+		{[]byte{0xc0, 0x80}, []byte{0xdf, 0xbf}},
+		{[]byte{0xe0, 0x80, 0x80}, []byte{0xef, 0xbf, 0xbf}},
+		{[]byte{0xf0, 0x80, 0x80, 0x80}, []byte{0xf7, 0xbf, 0xbf, 0xbf}} },
+	"UTF32": {{[]byte{0, 0, 0, 0}, []byte{0, 0x10, 0xff, 0xff}}} }
+
+// fontCMap() returns the CMap named or contained by a Type0 font's
+// /Encoding, or nil if it is unknown.  The /UseCMap entry of an
+// embedded CMap names or contains the CMap it extends.
+func fontCMap(encoding Object, depth int) *CMap {
+	if encoding == nil || depth > 2 {
+		return nil
+	}
+	switch e := encoding.Dereference().(type) {
+	case Name:
+		return PredefinedCMap(e.String())
+	case ProtectedStream:
+		r := e.Reader()
+		if r == nil {
+			return nil
+		}
+		cmap,err := ParseCMap(r)
+		if err != nil {
+			return nil
+		}
+		if cmap.parent == nil {
+			cmap.parent = fontCMap(e.Dictionary().Get("UseCMap"), depth+1)
+		}
+		return cmap
+	}
+	return nil
+}
@@ -0,0 +1,215 @@
+package pdf
+
+import (
+	"bytes"
+	"container/list"
+	"errors"
+	"fmt"
+	"io" )
+
+var unregisteredCodec = errors.New(`No codec is registered under that name`)
+
+// A Codec compresses stream data with an algorithm that PDF doesn't
+// define, such as Brotli or Zstandard, for programs that use this
+// package as an intermediate representation rather than to produce
+// final PDF files.  Once registered with RegisterCodec(), a codec acts
+// as a stream filter whose name appears in /Filter while the contents
+// are held encoded with it.  Since other readers don't understand such
+// a filter, streams held with a codec are decoded and recompressed
+// with FlateDecode when they are written to a file, unless the file
+// was opened with WithCodecOutput().  This is an experimental,
+// off-spec extension.
+type Codec interface {
+	// Name() is the name of the filter, which should not be one
+	// defined by PDF.  A name beginning with "X-" is suggested.
+	Name() string
+	NewEncoder(io.WriteCloser) io.WriteCloser
+	NewDecoder(io.Reader) io.Reader
+}
+
+var registeredCodecs map[string]Codec
+
+// RegisterCodec() registers codec under its name, replacing any codec
+// previously registered under that name.  It panics if the name is
+// that of a filter registered with RegisterFilterFactoryFactory().
+func RegisterCodec(codec Codec) {
+	name := codec.Name()
+	if _,ok := registeredCodecs[name]; !ok && FilterFactory(name, nil) != nil {
+		panic(fmt.Sprintf("%s is the name of a stream filter", name))
+	}
+	if registeredCodecs == nil {
+		registeredCodecs = make(map[string]Codec)
+	}
+	registeredCodecs[name] = codec
+	RegisterFilterFactoryFactory(name, func(ProtectedDictionary) StreamFilterFactory { return codecFilter{codec} })
+}
+
+// codecFilter adapts a Codec to StreamFilterFactory.
+type codecFilter struct {
+	Codec
+}
+
+func (c codecFilter) DecodeParms(file ...File) Object {
+	return NewNull()
+}
+
+// EncodeWithCodec() returns a copy of s whose contents are held in
+// memory encoded with the codec registered under name.  The filters
+// of s that this package can decode are undone first, so a stream
+// compressed with FlateDecode is recompressed with the codec, while
+// the codec is applied on top of filters such as DCTDecode, which are
+// kept.  Reader() and the functions that read images decode the copy
+// as they would s.
+func EncodeWithCodec(s ProtectedStream, name string) (Stream, error) {
+	codec,ok := registeredCodecs[name]
+	if !ok {
+		return nil, unregisteredCodec
+	}
+	dictionary := s.Dictionary().Clone().(Dictionary)
+	filters,parameters := streamFilters(dictionary)
+	k := 0
+	for k < len(filters) && FilterFactory(filters[k], parameters[k]) != nil {
+		k++
+	}
+	data,err := encodedData(s)
+	if err != nil {
+		return nil, err
+	}
+	r := limitDecoded(s, decodeFilters(bytes.NewReader(data), filters[:k], parameters[:k]))
+
+	buffer := NewBufferCloser()
+	w := codec.NewEncoder(buffer)
+	_,err = io.Copy(w, r)
+	if closeErr := w.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	setStreamFilters(dictionary, append([]string{name}, filters[k:]...), append([]ProtectedDictionary{nil}, parameters[k:]...))
+	dictionary.Remove("Length")
+	result := &stream{dictionary, buffer.Buffer, nil, nil, 0}
+	if original,ok := s.(protectedStream); ok {
+		s = original.s
+	}
+	if original,ok := s.(*stream); ok {
+		result.filterList = copyFilterList(original.filterList)
+		result.maxDecodedSize = original.maxDecodedSize
+	}
+	return result, nil
+}
+
+// setStreamFilters() sets the /Filter and /DecodeParms entries of a
+// stream dictionary to the passed filters and parameters, removing
+// them if there are no filters.
+func setStreamFilters(dictionary Dictionary, filters []string, parameters []ProtectedDictionary) {
+	dictionary.Remove("Filter")
+	dictionary.Remove("DecodeParms")
+	needDecodeParameters := false
+	for _,p := range parameters {
+		if p != nil {
+			needDecodeParameters = true
+		}
+	}
+	switch len(filters) {
+	case 0:
+	case 1:
+		dictionary.Add("Filter", NewName(filters[0]))
+		if needDecodeParameters {
+			dictionary.Add("DecodeParms", parameters[0].Clone())
+		}
+	default:
+		names := NewArray()
+		decodeParameters := NewArray()
+		for i,name := range filters {
+			names.Add(NewName(name))
+			if parameters[i] != nil {
+				decodeParameters.Add(parameters[i].Clone())
+			} else {
+				decodeParameters.Add(NewNull())
+			}
+		}
+		dictionary.Add("Filter", names)
+		if needDecodeParameters {
+			dictionary.Add("DecodeParms", decodeParameters)
+		}
+	}
+}
+
+// WithCodecOutput() returns a FileOption that writes streams held
+// with a registered Codec still encoded with it, rather than
+// recompressing them with FlateDecode.  The file can then be read only
+// by programs that have the same codecs registered.
+func WithCodecOutput() FileOption {
+	return func(f *file) {
+		f.codecOutput = true
+	}
+}
+
+func (f *file) keepsCodecs() bool {
+	return f.codecOutput
+}
+
+// keepsCodecs() returns true if the file was opened with
+// WithCodecOutput().
+func keepsCodecs(file ...File) bool {
+	if len(file) > 0 {
+		if k,ok := file[0].(interface{ keepsCodecs() bool }); ok {
+			return k.keepsCodecs()
+		}
+	}
+	return false
+}
+
+// transcoded() returns s with any filters up to the last registered
+// Codec undone, so that it can be written to file as a standard PDF
+// stream.  If no filters remain, the contents are compressed with
+// FlateDecode.  It returns s if s uses no codec, if file keeps codecs,
+// or if a filter preceding the codec can't be decoded.
+func (s *stream) transcoded(file ...File) *stream {
+	if len(registeredCodecs) == 0 || keepsCodecs(file...) {
+		return s
+	}
+	filters,parameters := streamFilters(s.dictionary)
+	last := -1
+	for i,name := range filters {
+		if _,ok := registeredCodecs[name]; ok {
+			last = i
+		}
+	}
+	if last < 0 {
+		return s
+	}
+	for i:=0; i<last; i++ {
+		if FilterFactory(filters[i], parameters[i]) == nil {
+			return s
+		}
+	}
+
+	dictionary := s.dictionary.Clone().(Dictionary)
+	setStreamFilters(dictionary, filters[last+1:], parameters[last+1:])
+	filterList := s.filterList
+	if last == len(filters)-1 && !hasFlateFilter(filterList) {
+		filterList = copyFilterList(s.filterList)
+		if filterList == nil {
+			filterList = list.New()
+		}
+		filterList.PushBack(&FlateFilter{compressionLevel: 9})
+	}
+	return &stream{dictionary, bytes.Buffer{}, filterList,
+		func() io.Reader {
+			return decodeFilters(s.contents(), filters[:last+1], parameters[:last+1])
+		}, s.maxDecodedSize}
+}
+
+func hasFlateFilter(filters *list.List) bool {
+	if filters != nil {
+		for item:=filters.Front(); item != nil; item = item.Next() {
+			if _,isFlate := item.Value.(*FlateFilter); isFlate {
+				return true
+			}
+		}
+	}
+	return false
+}
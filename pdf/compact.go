@@ -0,0 +1,175 @@
+package pdf
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort" )
+
+var (
+	cannotCompactEncrypted = errors.New(`Encrypted files cannot be compacted`)
+	cannotCompactStorage = errors.New(`Only documents opened by name or in memory can be compacted`) )
+
+// Compact() reads the PDF file named input and writes to output only
+// the objects reachable from its trailer, renumbered densely from 1
+// with a single xref section.  Objects superseded or deleted by
+// incremental updates, and any others that nothing refers to, are
+// dropped, which shrinks documents that have been updated many
+// times.  Identical objects are merged as by
+// ObjectCopier.Deduplicate().  If an object can't be read, the error
+// is returned and output is left untouched.  Input and output may
+// name the same file.  Options are passed to OpenFile() when reading
+// input.
+func Compact(input, output string, options ...FileOption) error {
+	return rewriteFile(input, output, options, nil, compact)
+}
+
+// rewriteFile() reads the PDF file named input and has rewrite copy
+// it to a new file that then replaces output.  sourceOptions are
+// passed to OpenFile() when reading input and destinationOptions when
+// creating the new file.
+func rewriteFile(input, output string, sourceOptions, destinationOptions []FileOption, rewrite func(destination *file, source File) error) error {
+	source,_,err := OpenFile(input, os.O_RDONLY, sourceOptions...)
+	if err != nil {
+		return err
+	}
+	// Nothing is written to source, and Close() would add a
+	// catalog if it has none.
+	defer source.abandon()
+
+	// The output is written to a temporary file that replaces
+	// output once it is complete, since output may be input.
+	temporary,err := ioutil.TempFile(filepath.Dir(output), ".rewrite-")
+	if err != nil {
+		return err
+	}
+	temporary.Close()
+	if info,err := os.Stat(input); err == nil {
+		os.Chmod(temporary.Name(), info.Mode())
+	}
+	destination,_,err := OpenFile(temporary.Name(), os.O_RDWR|os.O_TRUNC, destinationOptions...)
+	if err != nil {
+		os.Remove(temporary.Name())
+		return err
+	}
+	if err = rewrite(destination, source); err != nil {
+		os.Remove(temporary.Name())
+		return err
+	}
+	return os.Rename(temporary.Name(), output)
+}
+
+// compact() copies the objects reachable from the trailer of source
+// to destination, which must be new, and closes destination.
+// destination is abandoned if an error occurs.
+func compact(destination *file, source File) error {
+	if source.Trailer().Get("Encrypt") != nil {
+		destination.abandon()
+		return cannotCompactEncrypted
+	}
+	return copyReachable(destination, source, true, nil)
+}
+
+// copyReachable() copies the objects reachable from the trailer of
+// source, which must not be encrypted, to destination, merging
+// identical objects if deduplicate is true, and closes destination.
+// The source objects numbered by the keys of replacements are
+// replaced by the corresponding values.  destination is abandoned if
+// an error occurs.
+func copyReachable(destination *file, source File, deduplicate bool, replacements map[ObjectNumber]Object) error {
+	trailer := source.Trailer()
+	root,ok := trailer.Get("Root").(ProtectedIndirect)
+	if !ok {
+		destination.abandon()
+		return missingRoot
+	}
+
+	destination.requireVersion(versionOf(source))
+	copier := NewObjectCopier(destination, source)
+	if deduplicate {
+		copier.Deduplicate()
+	}
+	replaced := make([]ObjectNumber, 0, len(replacements))
+	for o := range replacements {
+		replaced = append(replaced, o)
+	}
+	sort.Slice(replaced, func(i, j int) bool { return replaced[i].Less(replaced[j]) })
+	references := make([]Indirect, len(replaced))
+	for n,o := range replaced {
+		references[n] = NewIndirect()
+		copier.Translate(o, references[n])
+	}
+	destination.trailerDictionary.Add("Root", copier.CopyReference(root.ObjectNumber(source)))
+	if info,ok := trailer.Get("Info").(ProtectedIndirect); ok {
+		destination.trailerDictionary.Add("Info", copier.CopyReference(info.ObjectNumber(source)))
+	}
+	if id := trailer.Get("ID"); id != nil {
+		destination.trailerDictionary.Add("ID", id.Clone())
+	}
+	// Replacements are written only if something copied refers
+	// to them, which may be another replacement.
+	written := make([]bool, len(replaced))
+	for progress := true; progress; {
+		progress = false
+		for n,o := range replaced {
+			if !written[n] && references[n].BoundToFile(destination) {
+				references[n].Write(copier.Copy(replacements[o]))
+				written[n], progress = true, true
+			}
+		}
+	}
+	if err := destination.canceled(); err != nil {
+		destination.abandon()
+		return err
+	}
+	// An object that can't be read would be lost, and the input
+	// may be replaced by the output.
+	if err := copier.Err(); err != nil {
+		destination.abandon()
+		return err
+	}
+	destination.Close()
+	return nil
+}
+
+// Compact() closes the document and rewrites its file as Compact()
+// does, dropping the objects that are no longer used.  The document
+// must have been opened by name, as by OpenDocument(), or be held in a
+// MemoryFile.
+func (d *Document) Compact() error {
+	var (
+		filename string
+		memory *memoryStorage )
+	switch f := d.file.(type) {
+	case *file:
+		if named,ok := f.file.(*os.File); ok {
+			filename = named.Name()
+		}
+	case *MemoryFile:
+		memory = f.storage
+	}
+	err := d.Close()
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case memory != nil:
+		source,err := NewFileFromReader(bytes.NewReader(memory.data))
+		if err != nil {
+			return err
+		}
+		defer source.abandon()
+		destination := NewMemoryFile()
+		if err = compact(destination.file, source); err != nil {
+			return err
+		}
+		memory.data = destination.Bytes()
+		return nil
+	case filename != "":
+		return Compact(filename, filename)
+	}
+	return cannotCompactStorage
+}
@@ -0,0 +1,104 @@
+package pdf
+
+import (
+	"compress/zlib"
+	"container/list"
+	"fmt" )
+
+// StreamCategory classifies the streams written to a file for
+// WithCompression().
+type StreamCategory int
+
+const (
+	// ContentStreams are page contents and forms, along with every
+	// stream not in another category, such as fonts and embedded
+	// files.
+	ContentStreams StreamCategory = iota
+	// ImageStreams are image XObjects.
+	ImageStreams
+	// MetadataStreams are XMP metadata streams.
+	MetadataStreams
+	// XRefStreams are cross-reference streams.  Since this package
+	// writes cross-reference tables, these are only streams with
+	// /Type /XRef written by the caller.
+	XRefStreams )
+
+// StoreOnly is the level passed to WithCompression() to write streams
+// without FlateDecode.
+const StoreOnly = -3
+
+// WithCompression() returns a FileOption that sets the level at which
+// the streams of category that are compressed with FlateDecode are
+// compressed when written to the file.  Level is from zlib.NoCompression
+// (0), which still wraps the data in FlateDecode, to
+// zlib.BestCompression (9), zlib.DefaultCompression, zlib.HuffmanOnly,
+// which uses only Huffman coding, or StoreOnly, which omits the filter
+// altogether.  Lower levels trade size for speed; streams created by
+// this package are otherwise compressed at level 9.  Streams without
+// FlateDecode among their filters, such as JPEG images, and streams
+// copied from another file with their data still encoded are
+// unaffected.  The option may be passed once for each category.
+func WithCompression(category StreamCategory, level int) FileOption {
+	if category < ContentStreams || category > XRefStreams {
+		panic(fmt.Sprintf("Invalid stream category %d", category))
+	}
+	if level < StoreOnly || level > zlib.BestCompression {
+		panic(fmt.Sprintf("Invalid compression level %d", level))
+	}
+	return func(f *file) {
+		if f.compression == nil {
+			f.compression = make(map[StreamCategory]int)
+		}
+		f.compression[category] = level
+	}
+}
+
+func (f *file) compressionLevel(category StreamCategory) (int, bool) {
+	level,ok := f.compression[category]
+	return level, ok
+}
+
+// compressionLevel() returns the level set by WithCompression() for
+// category in the first of the files, and false if none was set.
+func compressionLevel(category StreamCategory, file ...File) (int, bool) {
+	if len(file) > 0 {
+		if c,ok := file[0].(interface{ compressionLevel(StreamCategory) (int, bool) }); ok {
+			return c.compressionLevel(category)
+		}
+	}
+	return 0, false
+}
+
+// streamCategory() returns the category of the stream with the passed
+// dictionary.
+func streamCategory(d ProtectedDictionary) StreamCategory {
+	switch {
+	case d.CheckNameValue("Subtype", "Image"):
+		return ImageStreams
+	case d.CheckNameValue("Type", "Metadata"):
+		return MetadataStreams
+	case d.CheckNameValue("Type", "XRef"):
+		return XRefStreams
+	}
+	return ContentStreams
+}
+
+// withFlateLevel() returns a copy of filters in which each FlateFilter
+// compresses at level, or is omitted if level is StoreOnly.
+func withFlateLevel(filters *list.List, level int) *list.List {
+	if filters == nil {
+		return nil
+	}
+	result := list.New()
+	for item:=filters.Front(); item != nil; item = item.Next() {
+		filter := item.Value.(StreamFilterFactory)
+		if _,isFlate := filter.(*FlateFilter); isFlate {
+			if level == StoreOnly {
+				continue
+			}
+			filter = &FlateFilter{compressionLevel: level}
+		}
+		result.PushBack(filter)
+	}
+	return result
+}
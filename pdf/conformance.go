@@ -0,0 +1,172 @@
+package pdf
+
+import (
+	"fmt"
+	"strings" )
+
+// Conformance identifies a standard, such as PDF/A, to which a
+// Document must conform.
+type Conformance int
+
+const (
+	NoConformance Conformance = iota
+	// PDFA2B is PDF/A-2 level B (ISO 19005-2), which guarantees
+	// reliable reproduction of the visual appearance of a
+	// document.
+	PDFA2B )
+
+func (c Conformance) String() string {
+	switch c {
+	case NoConformance:
+		return "none"
+	case PDFA2B:
+		return "PDF/A-2b"
+	}
+	return fmt.Sprintf("Conformance(%d)", int(c))
+}
+
+// ConformanceError is returned by Document.Close() when a document
+// does not conform to the level requested by SetConformance().
+type ConformanceError struct {
+	Conformance Conformance
+	Violations []string
+}
+
+func (e *ConformanceError) Error() string {
+	return fmt.Sprintf("Document does not conform to %v: %s",
+		e.Conformance, strings.Join(e.Violations, "; "))
+}
+
+// SetConformance() requests that the document conform to the
+// specified standard.  When the document is closed, metadata
+// required by the standard (such as XMP identification) is
+// generated, and the document is checked for violations that cannot
+// be corrected automatically.
+func (d *Document) SetConformance(c Conformance) {
+	d.conformance = c
+}
+
+// Conformance() returns the conformance level set by SetConformance().
+func (d *Document) Conformance() Conformance {
+	return d.conformance
+}
+
+// ConformanceViolations() returns the violations of the requested
+// conformance level that would be reported if the document were
+// closed now.
+func (d *Document) ConformanceViolations() []string {
+	switch d.conformance {
+	case PDFA2B:
+		return d.pdfaViolations()
+	}
+	return nil
+}
+
+func (d *Document) pdfaViolations() (violations []string) {
+	embedded := true
+	for font := range d.fonts {
+		embedded = embedded && font.Embedded()
+	}
+	if d.currentPage != nil {
+		for font := range d.currentPage.fontMap {
+			embedded = embedded && font.Embedded()
+		}
+	}
+	if !embedded {
+		violations = append(violations, "Font is not embedded")
+	}
+
+	if d.file.Trailer().Get("Encrypt") != nil {
+		violations = append(violations, "Document is encrypted")
+	}
+
+	if !hasPDFAOutputIntent(d.catalog) {
+		violations = append(violations, `No /OutputIntents entry with subtype /GTS_PDFA1 and an ICC profile`)
+	}
+
+	// Pages added to the document are in the page tree only once
+	// the document is closed.
+	if containsJavaScript(d.catalog, d.file) || (d.pages != nil && containsJavaScript(d.pages, d.file)) {
+		violations = append(violations, "Document contains JavaScript")
+	}
+	return violations
+}
+
+func hasPDFAOutputIntent(catalog ProtectedDictionary) bool {
+	intents := catalog.GetArray("OutputIntents")
+	if intents == nil {
+		return false
+	}
+	for i:=0; i<intents.Size(); i++ {
+		if intent,ok := intents.At(i).Dereference().(ProtectedDictionary); ok {
+			if intent.CheckNameValue("S", "GTS_PDFA1") && intent.Get("DestOutputProfile") != nil {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// containsJavaScript() searches o, and the objects of file to which
+// it refers, for JavaScript actions or a JavaScript name tree.
+// References to objects that haven't been written or can't be read
+// aren't followed.
+func containsJavaScript(o Object, file File) bool {
+	return searchJavaScript(o, file, make(map[ObjectNumber]bool))
+}
+
+// searchJavaScript() is containsJavaScript() skipping the objects in
+// visited, to which it adds the objects it reads.
+func searchJavaScript(o Object, file File, visited map[ObjectNumber]bool) bool {
+	switch t := o.Protect().(type) {
+	case ProtectedIndirect:
+		if !t.BoundToFile(file) {
+			return false
+		}
+		n := t.ObjectNumber(file)
+		if visited[n] {
+			return false
+		}
+		visited[n] = true
+		object,err := file.Object(n)
+		return err == nil && object != nil && searchJavaScript(object, file, visited)
+	case ProtectedStream:
+		return searchJavaScript(t.Dictionary(), file, visited)
+	case ProtectedDictionary:
+		if t.CheckNameValue("S", "JavaScript") || t.Get("JS") != nil {
+			return true
+		}
+		for _,key := range t.Keys() {
+			if key == "JavaScript" || searchJavaScript(t.Get(key), file, visited) {
+				return true
+			}
+		}
+	case ProtectedArray:
+		for i:=0; i<t.Size(); i++ {
+			if searchJavaScript(t.At(i), file, visited) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// finishConformance() writes any metadata required by the requested
+// conformance level and returns a *ConformanceError if the document
+// does not conform.
+func (d *Document) finishConformance() error {
+	if d.conformance == NoConformance {
+		return nil
+	}
+
+	metadata := NewStream()
+	metadata.Add("Type", NewName("Metadata"))
+	metadata.Add("Subtype", NewName("XML"))
+	metadata.Write(xmpMetadata(&d.DocumentInfo, d.conformance))
+	d.catalog.Add("Metadata", d.WriteObject(metadata))
+
+	if violations := d.ConformanceViolations(); len(violations) != 0 {
+		return &ConformanceError{d.conformance, violations}
+	}
+	return nil
+}
@@ -0,0 +1,402 @@
+package pdf
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io" )
+
+// An Operation is an operator from a content stream together with
+// the operands that precede it.
+type Operation struct {
+	Operator string
+	Operands []Object
+}
+
+// A ContentParser splits a decoded content stream into operations.
+// Content streams use the same syntax for operands as the rest of
+// the file, except that they may not contain indirect references, so
+// ContentParser does not use Parser, whose look-ahead for "n g R"
+// would misread sequences like "1 0 0 RG".
+type ContentParser struct {
+	scanner *bufio.Reader
+	limits ParserLimits
+	// depth is the nesting of the array or dictionary being
+	// scanned.
+	depth int
+	// keepComments is set by KeepComments().  comments holds the
+	// comments read but not yet returned.
+	keepComments bool
+	comments []Operation
+}
+
+var (
+	unexpectedKeyword = errors.New(`Unexpected keyword in content stream operand`)
+	unterminatedInlineImage = errors.New(`Inline image has no EI operator`) )
+
+// NewContentParser() constructs a ContentParser that reads the
+// decoded content stream r, e.g., from ExistingPage.Reader().
+func NewContentParser(r io.Reader) *ContentParser {
+	return &ContentParser{bufio.NewReader(r), defaultParserLimits, 0, false, nil}
+}
+
+// SetLimits() sets the limits on nesting and on the length of tokens.
+// The default is the zero ParserLimits.
+func (cp *ContentParser) SetLimits(limits ParserLimits) {
+	cp.limits = limits.withDefaults()
+}
+
+// KeepComments() makes Next() return the comments between operations,
+// which are otherwise skipped, so that a stream can be rewritten
+// without losing them.  A comment is returned as an operation with
+// the operator "%" and the text of the comment, without the "%" and
+// the end of line, as a string operand.  ContentSerializer writes
+// such operations as comments.  A comment among the operands of an
+// operation is returned after the operation, and one within an array
+// or dictionary operand is discarded.
+func (cp *ContentParser) KeepComments() {
+	cp.keepComments = true
+}
+
+// ParseContent() returns all of the operations in the decoded content
+// stream r.
+func ParseContent(r io.Reader) ([]Operation, error) {
+	cp := NewContentParser(r)
+	operations := make([]Operation, 0, 64)
+	for {
+		op,err := cp.Next()
+		if err == io.EOF {
+			return operations, nil
+		}
+		if err != nil {
+			return operations, err
+		}
+		operations = append(operations, op)
+	}
+}
+
+// Next() returns the next operation in the stream.  At the end of the
+// stream, it returns io.EOF.  Operands that are not followed by an
+// operator are discarded.  An inline image (BI ... ID ... EI) is
+// returned as a single operation with the operator "BI" and two
+// operands: a dictionary containing the image parameters and a string
+// containing the image data.
+func (cp *ContentParser) Next() (op Operation, err error) {
+	defer func() {
+		if x := recover(); x != nil {
+			if err,_ = x.(error); err == nil {
+				err = parsingError
+			}
+		}
+	} ()
+
+	cp.depth = 0
+	if len(cp.comments) > 0 {
+		return cp.nextComment(), nil
+	}
+	for {
+		b,err := cp.nextNonWhiteByte()
+		if len(cp.comments) > 0 && (len(op.Operands) == 0 || err != nil) {
+			if err == nil {
+				cp.scanner.UnreadByte()
+			}
+			return cp.nextComment(), nil
+		}
+		if err != nil {
+			return op, err
+		}
+		if startsKeyword(b) {
+			keyword := scanContentKeyword(cp.scanner, b, cp.limits.MaxTokenLength)
+			if object := keywordObject(keyword); object != nil {
+				op.Operands = append(op.Operands, object)
+				continue
+			}
+			op.Operator = keyword
+			if keyword == "BI" {
+				op.Operands = cp.scanInlineImage()
+			}
+			return op, nil
+		}
+		cp.scanner.UnreadByte()
+		op.Operands = append(op.Operands, cp.scanOperand())
+	}
+}
+
+// nextNonWhiteByte() skips white space and comments as the function
+// of the same name does, saving the comments if they are being kept.
+func (cp *ContentParser) nextNonWhiteByte() (byte, error) {
+	if !cp.keepComments {
+		return nextNonWhiteByte(cp.scanner)
+	}
+	for {
+		b,err := cp.scanner.ReadByte()
+		if err != nil || (!IsWhiteSpace(b) && b != '%') {
+			return b, err
+		}
+		if b == '%' {
+			cp.comments = append(cp.comments, Operation{"%", []Object{NewBinaryString(cp.scanComment())}})
+		}
+	}
+}
+
+// scanComment() returns the rest of a comment.
+func (cp *ContentParser) scanComment() []byte {
+	var buffer []byte
+	b,err := cp.scanner.ReadByte()
+	for ; err == nil && b != '\r' && b != '\n'; b,err = cp.scanner.ReadByte() {
+		checkLength(buffer, cp.limits.MaxStringLength, "MaxStringLength")
+		buffer = append(buffer, b)
+	}
+	return buffer
+}
+
+func (cp *ContentParser) nextComment() Operation {
+	comment := cp.comments[0]
+	cp.comments = cp.comments[1:]
+	return comment
+}
+
+// startsKeyword() returns true if b can begin an operator or one of
+// the keywords "true", "false", and "null".
+func startsKeyword(b byte) bool {
+	return IsRegular(b) && !IsDigit(b) && b != '+' && b != '-' && b != '.'
+}
+
+func scanContentKeyword(scanner Scanner, b byte, maxLength int) string {
+	buffer := []byte{b}
+	b,err := scanner.ReadByte()
+	for ; err == nil && IsRegular(b); b,err = scanner.ReadByte() {
+		checkLength(buffer, maxLength, "MaxTokenLength")
+		buffer = append(buffer, b)
+	}
+	if err == nil {
+		scanner.UnreadByte()
+	}
+	return string(buffer)
+}
+
+// keywordObject() returns the object represented by one of the
+// keywords "true", "false", and "null", or nil for other keywords.
+func keywordObject(keyword string) Object {
+	switch keyword {
+	case "true":
+		return NewBoolean(true)
+	case "false":
+		return NewBoolean(false)
+	case "null":
+		return NewNull()
+	}
+	return nil
+}
+
+func (cp *ContentParser) scanOperand() Object {
+	b,err := nextNonWhiteByte(cp.scanner)
+	if err != nil {
+		panic(unexpectedEnd)
+	}
+	switch {
+	case IsDigit(b), b=='.', b=='+', b=='-':
+		return scanNumeric(cp.scanner, b, cp.limits.MaxTokenLength)
+	case b == '/':
+		return scanName(cp.scanner, cp.limits.MaxTokenLength)
+	case b == '(':
+		return scanNormalString(cp.scanner, cp.limits.MaxStringLength)
+	case b == '<':
+		b,err = nextNonWhiteByte(cp.scanner)
+		if b == '<' {
+			return cp.scanDictionary(">>")
+		}
+		return scanHexString(cp.scanner, b, cp.limits.MaxStringLength)
+	case b == '[':
+		return cp.scanArray()
+	case startsKeyword(b):
+		if object := keywordObject(scanContentKeyword(cp.scanner, b, cp.limits.MaxTokenLength)); object != nil {
+			return object
+		}
+		panic(unexpectedKeyword)
+	}
+	panic(unexpectedInput)
+}
+
+// enter() and leave() track nesting as Parser's do.
+func (cp *ContentParser) enter() {
+	cp.depth++
+	if cp.depth > cp.limits.MaxDepth {
+		panic(&SyntaxError{Err: &LimitError{"MaxDepth", int64(cp.limits.MaxDepth)}})
+	}
+}
+
+func (cp *ContentParser) leave() {
+	cp.depth--
+}
+
+func (cp *ContentParser) scanArray() Array {
+	array := NewArray()
+	cp.enter()
+	b,err := nextNonWhiteByte(cp.scanner)
+	for ; err == nil && b != ']'; b,err = nextNonWhiteByte(cp.scanner) {
+		cp.scanner.UnreadByte()
+		array.Add(cp.scanOperand())
+	}
+	if err != nil {
+		panic(unexpectedEnd)
+	}
+	cp.leave()
+	return array
+}
+
+// scanDictionary() scans name/value pairs up to the terminator, which
+// is ">>" for an ordinary dictionary and the keyword "ID" for the
+// parameters of an inline image.
+func (cp *ContentParser) scanDictionary(terminator string) Dictionary {
+	d := NewDictionary()
+	cp.enter()
+	for {
+		b,err := nextNonWhiteByte(cp.scanner)
+		if err != nil {
+			panic(unexpectedEnd)
+		}
+		switch {
+		case b == '>' && terminator == ">>":
+			if b,_ = cp.scanner.ReadByte(); b != '>' {
+				panic(expectedGreaterThan)
+			}
+			cp.leave()
+			return d
+		case startsKeyword(b) && terminator != ">>":
+			if scanContentKeyword(cp.scanner, b, cp.limits.MaxTokenLength) == terminator {
+				cp.leave()
+				return d
+			}
+			panic(unexpectedKeyword)
+		}
+		cp.scanner.UnreadByte()
+		name,ok := cp.scanOperand().(Name)
+		if !ok {
+			panic(expectingName)
+		}
+		d.Add(name.String(), cp.scanOperand())
+	}
+}
+
+// scanInlineImage() scans the remainder of an inline image following
+// the BI operator.  When the length of the data is known, either from
+// an /L entry or from the dimensions of an unfiltered image, and the
+// data is followed by "EI", that length is used.  Otherwise the data
+// ends at the first "EI" that is preceded by white space, followed by
+// white space or a delimiter, and followed in turn by text that could
+// be content stream operators rather than more binary data.  If no
+// "EI" passes that test, the first one that would have ended the
+// image is used.
+func (cp *ContentParser) scanInlineImage() []Object {
+	parameters := cp.scanDictionary("ID")
+
+	// A single white-space character follows ID.
+	cp.scanner.ReadByte()
+
+	data := make([]byte, 0, 256)
+	if length := inlineImageLength(parameters); length >= 0 {
+		data = readUpTo(cp.scanner, int64(length))
+		if len(data) == length && cp.skipEI() {
+			return []Object{parameters, NewBinaryString(data)}
+		}
+	}
+
+	firstEnd := -1
+	for {
+		b,err := cp.scanner.ReadByte()
+		if err != nil {
+			break
+		}
+		data = append(data, b)
+		n := len(data)
+		if n >= 3 && data[n-2] == 'E' && data[n-1] == 'I' && IsWhiteSpace(data[n-3]) {
+			following,err := cp.scanner.Peek(1)
+			if err == io.EOF || (err == nil && (IsWhiteSpace(following[0]) || IsDelimiter(following[0]))) {
+				if cp.operatorsFollow() {
+					return []Object{parameters, NewBinaryString(data[:n-3])}
+				}
+				if firstEnd < 0 {
+					firstEnd = n-3
+				}
+			}
+		}
+	}
+
+	if firstEnd < 0 {
+		panic(unterminatedInlineImage)
+	}
+	// Resume parsing after the first EI.
+	cp.scanner = bufio.NewReader(bytes.NewReader(data[firstEnd+3:]))
+	return []Object{parameters, NewBinaryString(data[:firstEnd])}
+}
+
+// skipEI() consumes optional white space and the EI operator if they
+// are next in the stream, returning true if it did so.
+func (cp *ContentParser) skipEI() bool {
+	const maxWhiteSpace = 8
+	peeked,_ := cp.scanner.Peek(maxWhiteSpace+3)
+	i := 0
+	for i < len(peeked) && i < maxWhiteSpace && IsWhiteSpace(peeked[i]) {
+		i++
+	}
+	if i+2 > len(peeked) || peeked[i] != 'E' || peeked[i+1] != 'I' {
+		return false
+	}
+	if i+2 < len(peeked) && !IsWhiteSpace(peeked[i+2]) && !IsDelimiter(peeked[i+2]) {
+		return false
+	}
+	cp.scanner.Discard(i+2)
+	return true
+}
+
+// operatorsFollow() returns true if the bytes following a candidate EI
+// operator look like content stream text: printable ASCII or white
+// space.  Binary image data almost always contains other bytes within
+// a short distance.
+func (cp *ContentParser) operatorsFollow() bool {
+	const window = 32
+	peeked,_ := cp.scanner.Peek(window)
+	for _,b := range peeked {
+		if (b < 0x20 || b > 0x7e) && !IsWhiteSpace(b) {
+			return false
+		}
+	}
+	return true
+}
+
+// inlineImageLength() returns the length of the data of the inline
+// image with the given parameters, or -1 if it is not known.  The
+// length is given by /L or can be computed for unfiltered images
+// whose color space is not a named resource.
+func inlineImageLength(parameters ProtectedDictionary) int {
+	expanded := expandInlineImageParameters(parameters)
+	if length,ok := expanded.GetInt("Length"); ok && length >= 0 {
+		return length
+	}
+	if filter := expanded.Get("Filter"); filter != nil {
+		if filters,ok := filter.(ProtectedArray); !ok || filters.Size() > 0 {
+			return -1
+		}
+	}
+
+	width,ok1 := expanded.GetInt("Width")
+	height,ok2 := expanded.GetInt("Height")
+	if !ok1 || !ok2 || width <= 0 || height <= 0 {
+		return -1
+	}
+	bits,components := 0,0
+	if mask,_ := expanded.GetBoolean("ImageMask"); mask {
+		bits,components = 1,1
+	} else {
+		bits,_ = expanded.GetInt("BitsPerComponent")
+		// Without resources, color spaces named in them are unknown.
+		if cs := (PageImage{}).colorSpace(expanded.Get("ColorSpace"), 0); cs != nil {
+			components = cs.components
+		}
+	}
+	if bits <= 0 || components == 0 {
+		return -1
+	}
+	return height*((width*bits*components+7)/8)
+}
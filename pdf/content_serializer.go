@@ -0,0 +1,103 @@
+package pdf
+
+import (
+	"bytes"
+	"io" )
+
+// A ContentSerializer writes operations to a content stream in the
+// form read by ContentParser.
+type ContentSerializer struct {
+	w io.Writer
+	buffer bytes.Buffer
+}
+
+// NewContentSerializer() constructs a ContentSerializer that writes
+// to w, which is typically a Stream or a Page.
+func NewContentSerializer(w io.Writer) *ContentSerializer {
+	return &ContentSerializer{w: w}
+}
+
+// Write() writes a single operation followed by a newline.  A comment
+// returned by a ContentParser that keeps them is written as one.  Inline
+// images are written in the form returned by ContentParser.Next(),
+// with their keys and the names of their color spaces and filters
+// abbreviated.  If the image data contains something that could be
+// mistaken for the EI operator, /L gives the length of the data.
+func (cs *ContentSerializer) Write(op Operation) error {
+	cs.buffer.Reset()
+	if op.Operator == "%" && len(op.Operands) == 1 {
+		if text,ok := op.Operands[0].(ProtectString); ok {
+			cs.buffer.WriteByte('%')
+			cs.buffer.Write(text.Bytes())
+			cs.buffer.WriteByte('\n')
+			_,err := cs.w.Write(cs.buffer.Bytes())
+			return err
+		}
+	}
+	if op.Operator == "BI" && len(op.Operands) == 2 {
+		parameters,ok1 := op.Operands[0].(ProtectedDictionary)
+		data,ok2 := op.Operands[1].(ProtectString)
+		if ok1 && ok2 {
+			parameters := abbreviateInlineImageParameters(parameters)
+			if parameters.Get("L") == nil && containsEI(data.Bytes()) {
+				parameters.Add("L", NewIntNumeric(len(data.Bytes())))
+			}
+			cs.buffer.WriteString("BI")
+			for _,key := range parameters.Keys() {
+				cs.buffer.WriteByte(' ')
+				NewName(key).Serialize(&cs.buffer)
+				cs.buffer.WriteByte(' ')
+				parameters.Get(key).Serialize(&cs.buffer)
+			}
+			cs.buffer.WriteString(" ID ")
+			cs.buffer.Write(data.Bytes())
+			cs.buffer.WriteString("\nEI\n")
+			_,err := cs.w.Write(cs.buffer.Bytes())
+			return err
+		}
+	}
+
+	for _,operand := range op.Operands {
+		operand.Serialize(&cs.buffer)
+		cs.buffer.WriteByte(' ')
+	}
+	cs.buffer.WriteString(op.Operator)
+	cs.buffer.WriteByte('\n')
+	_,err := cs.w.Write(cs.buffer.Bytes())
+	return err
+}
+
+// containsEI() returns true if data contains "EI" preceded by white
+// space.
+func containsEI(data []byte) bool {
+	for i:=1; i+1<len(data); i++ {
+		if data[i] == 'E' && data[i+1] == 'I' && IsWhiteSpace(data[i-1]) {
+			return true
+		}
+	}
+	return false
+}
+
+// TransformContent() copies the operations of the decoded content
+// stream r to w, replacing each with the operations returned by
+// transform.  Returning the operation unchanged keeps it; returning
+// nil deletes it.  Filters such as redaction or color conversion can
+// be written as transforms.
+func TransformContent(r io.Reader, w io.Writer, transform func(Operation) []Operation) error {
+	cp := NewContentParser(r)
+	cs := NewContentSerializer(w)
+	for {
+		op,err := cp.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		for _,replacement := range transform(op) {
+			if err := cs.Write(replacement); err != nil {
+				return err
+			}
+		}
+	}
+}
@@ -0,0 +1,251 @@
+package pdf
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"io"
+	"sort" )
+
+// An ObjectCopier copies objects from one file to another.  Indirect
+// references to objects in the source file are replaced by
+// references to copies in the destination file, which are made
+// recursively.  The copier remembers every object it has copied, so
+// each source object is copied at most once no matter how many times
+// it is referenced: shared objects remain shared and cycles
+// terminate.  Use the same ObjectCopier for all objects copied from
+// one file to another to avoid duplicates.
+type ObjectCopier struct {
+	dst, src File
+	// translation maps source object numbers to references in
+	// the destination file.  Entries may be added before copying
+	// begins to redirect or suppress references.
+	translation map[ObjectNumber]Indirect
+	// digests is nil unless the copier deduplicates.  It maps
+	// the digests of the copies written to their references.
+	digests map[objectDigest]Indirect
+	// err is the first error reading a source object.
+	err error
+}
+
+// objectDigest is the SHA-256 hash of the canonical serialization of
+// an object written by writeCanonical().
+type objectDigest [sha256.Size]byte
+
+func NewObjectCopier(dst, src File) *ObjectCopier {
+	return &ObjectCopier{dst, src, make(map[ObjectNumber]Indirect), nil, nil}
+}
+
+// CopyObject() copies the object numbered o in src, along with every
+// object it refers to, to dst and returns a reference to the copy.
+// To copy several objects that may share references, use an
+// ObjectCopier instead.
+func CopyObject(dst File, src File, o ObjectNumber) Indirect {
+	return NewObjectCopier(dst, src).CopyReference(o)
+}
+
+// Translate() declares that references to the source object numbered
+// o are to be replaced by i rather than by a copy.  It must be called
+// before the object is first copied.  It can be used to redirect
+// references to objects that have already been copied by other means
+// or to suppress copying by redirecting them to a null object.
+func (c *ObjectCopier) Translate(o ObjectNumber, i Indirect) {
+	c.translation[o] = i
+}
+
+// Translation() returns the reference in the destination file that
+// replaces the source object numbered o, if o has been copied or
+// translated.
+func (c *ObjectCopier) Translation(o ObjectNumber) (Indirect, bool) {
+	i,exists := c.translation[o]
+	return i,exists
+}
+
+// Err() returns the first error encountered reading an object from
+// the source file, or nil if every object copied has been read.  An
+// object that can't be read is copied as null so that references to
+// it remain valid, so the copies must not be relied upon unless Err()
+// returns nil.
+func (c *ObjectCopier) Err() error {
+	return c.err
+}
+
+// read() reads the source object numbered o, recording any error.
+func (c *ObjectCopier) read(o ObjectNumber) Object {
+	object,err := c.src.Object(o)
+	if err != nil && c.err == nil {
+		c.err = err
+	}
+	if err != nil || object == nil {
+		object = NewNull()
+	}
+	return object
+}
+
+// CopyReference() returns a reference to the copy in the destination
+// file of the source object numbered o, copying it if necessary.  An
+// error reading the object is reported by Err().
+func (c *ObjectCopier) CopyReference(o ObjectNumber) Indirect {
+	if i,exists := c.translation[o]; exists {
+		return i
+	}
+	if c.digests != nil {
+		return c.copyUnique(o)
+	}
+	i := NewIndirect(c.dst)
+	// Enter the translation before copying so that references to
+	// o from within the object terminate.
+	c.translation[o] = i
+
+	i.Write(c.Copy(c.read(o)))
+	return i
+}
+
+// Deduplicate() makes the copier write only one copy of objects
+// whose serializations are identical, such as a font or an image
+// that is embedded repeatedly, and refer to it in place of the
+// others.  Objects that refer back to themselves through a cycle are
+// always copied.  Since the number of a copy is reserved only after
+// the objects it refers to have been copied, copies are not numbered
+// in the order in which they are reached.
+func (c *ObjectCopier) Deduplicate() {
+	c.deduplicate(make(map[objectDigest]Indirect))
+}
+
+// deduplicate() makes the copier deduplicate using digests, which may
+// be shared with other copiers to the same destination.
+func (c *ObjectCopier) deduplicate(digests map[objectDigest]Indirect) {
+	c.digests = digests
+}
+
+// copyUnique() is CopyReference() for a copier that deduplicates.
+func (c *ObjectCopier) copyUnique(o ObjectNumber) Indirect {
+	i := NewIndirect()
+	c.translation[o] = i
+
+	result := c.Copy(c.read(o))
+	// If anything copied so far refers to i, it has been bound
+	// to the destination and must be written.  An object whose
+	// stream contents can't be read has no reliable digest, so it
+	// is never merged with another, and is copied as null.
+	if !i.BoundToFile(c.dst) {
+		digest,err := digestObject(result, c.dst)
+		if err != nil {
+			if c.err == nil {
+				c.err = err
+			}
+			result = NewNull()
+		} else if existing,found := c.digests[digest]; found {
+			c.translation[o] = existing
+			return existing
+		} else {
+			c.digests[digest] = i
+		}
+	}
+	i.ObjectNumber(c.dst)
+	i.Write(result)
+	return i
+}
+
+// digestObject() returns the digest of the canonical serialization of
+// object when written to file, or the error from writeCanonical().
+func digestObject(object Object, file File) (digest objectDigest, err error) {
+	hash := sha256.New()
+	w := bufio.NewWriter(hash)
+	err = writeCanonical(w, object, file)
+	w.Flush()
+	copy(digest[:], hash.Sum(nil))
+	return digest, err
+}
+
+// writeCanonical() writes a serialization of object in which the
+// keys of dictionaries are sorted, so that equal objects produce the
+// same output.  Stream contents are written encoded but without
+// /Length.  An error is returned if the contents of a stream can't be
+// read or encoded.
+func writeCanonical(w Writer, object Object, file File) error {
+	switch t := object.(type) {
+	case *stream:
+		t = t.transcoded(file)
+		dictionary,encoder := t.encoder(file)
+		writeCanonical(w, dictionary, file)
+		w.WriteString("stream\n")
+		encoded := encoder(nopWriteCloser{w})
+		_,err := io.Copy(encoded, t.contents())
+		if closeErr := encoded.Close(); err == nil {
+			err = closeErr
+		}
+		return err
+	case protectedStream:
+		return writeCanonical(w, t.s, file)
+	case ProtectedDictionary:
+		keys := t.Keys()
+		sort.Strings(keys)
+		w.WriteString("<<")
+		for _,key := range keys {
+			NewName(key).Serialize(w, file)
+			w.WriteByte(' ')
+			if err := writeCanonical(w, t.Get(key), file); err != nil {
+				return err
+			}
+			w.WriteByte(' ')
+		}
+		w.WriteString(">>")
+	case ProtectedArray:
+		w.WriteString("[")
+		for i:=0; i<t.Size(); i++ {
+			if err := writeCanonical(w, t.At(i), file); err != nil {
+				return err
+			}
+			w.WriteByte(' ')
+		}
+		w.WriteString("]")
+	default:
+		object.Serialize(w, file)
+	}
+	return nil
+}
+
+// Copy() returns a copy of object suitable for writing to the
+// destination file.  Direct objects are copied and any indirect
+// references they contain are replaced as by CopyReference().
+func (c *ObjectCopier) Copy(object Object) Object {
+	switch t := object.(type) {
+	case ProtectedIndirect:
+		if t.BoundToFile(c.src) {
+			return c.CopyReference(t.ObjectNumber(c.src))
+		}
+		return t
+	case *stream:
+		result := t.Clone().(*stream)
+		// The /Length is recomputed when the copy is written.
+		result.dictionary = c.copyDictionary(t.dictionary, "Length")
+		return result
+	case protectedStream:
+		return c.Copy(t.s)
+	case ProtectedDictionary:
+		return c.copyDictionary(t)
+	case ProtectedArray:
+		result := NewArray()
+		for i:=0; i<t.Size(); i++ {
+			result.Add(c.Copy(t.At(i)))
+		}
+		return result
+	}
+	return object.Clone()
+}
+
+// copyDictionary() returns a copy of d without the entries named in
+// omit.
+func (c *ObjectCopier) copyDictionary(d ProtectedDictionary, omit ...string) Dictionary {
+	result := NewDictionary()
+keys:
+	for _,key := range d.Keys() {
+		for _,omitted := range omit {
+			if key == omitted {
+				continue keys
+			}
+		}
+		result.Add(key, c.Copy(d.Get(key)))
+	}
+	return result
+}
@@ -0,0 +1,89 @@
+package pdf
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time" )
+
+var invalidDate = errors.New(`Invalid date string`)
+
+// FormatDate() formats t as a PDF date string, D:YYYYMMDDHHmmSSOHH'mm',
+// preserving t's time zone offset.
+func FormatDate(t time.Time) string {
+	_,offset := t.Zone()
+	result := t.Format("D:20060102150405")
+	switch {
+	case offset == 0:
+		return result + "Z"
+	case offset < 0:
+		result += "-"
+		offset = -offset
+	default:
+		result += "+"
+	}
+	return result + fmt.Sprintf("%02d'%02d'", offset/3600, (offset%3600)/60)
+}
+
+// NewDate() returns a String object containing t formatted as a PDF
+// date.
+func NewDate(t time.Time) String {
+	return NewBinaryString([]byte(FormatDate(t)))
+}
+
+// ParseDate() parses a PDF date string.  As permitted by the PDF
+// specification, every field after the year is optional, and the
+// "D:" prefix and the apostrophes in the time zone offset are
+// tolerated if missing.  A date without a time zone is interpreted as
+// UTC.
+func ParseDate(s string) (time.Time, error) {
+	if len(s) >= 2 && s[:2] == "D:" {
+		s = s[2:]
+	}
+
+	// Fields and their default values: year, month, day, hour,
+	// minute, second
+	fields := []int{0, 1, 1, 0, 0, 0}
+	widths := []int{4, 2, 2, 2, 2, 2}
+	position := 0
+	for i,width := range widths {
+		if position+width > len(s) || !IsDigit(s[position]) {
+			if i == 0 {
+				return time.Time{}, invalidDate
+			}
+			break
+		}
+		v,err := strconv.Atoi(s[position:position+width])
+		if err != nil {
+			return time.Time{}, invalidDate
+		}
+		fields[i] = v
+		position += width
+	}
+
+	location := time.UTC
+	if position < len(s) {
+		switch sign := s[position]; sign {
+		case 'Z':
+		case '+', '-':
+			var hours, minutes int
+			rest := s[position+1:]
+			n,_ := fmt.Sscanf(rest, "%02d'%02d", &hours, &minutes)
+			if n == 0 {
+				if n,_ = fmt.Sscanf(rest, "%02d%02d", &hours, &minutes); n == 0 {
+					return time.Time{}, invalidDate
+				}
+			}
+			offset := hours*3600 + minutes*60
+			if sign == '-' {
+				offset = -offset
+			}
+			location = time.FixedZone("", offset)
+		default:
+			return time.Time{}, invalidDate
+		}
+	}
+
+	return time.Date(fields[0], time.Month(fields[1]), fields[2],
+		fields[3], fields[4], fields[5], 0, location), nil
+}
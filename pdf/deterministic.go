@@ -0,0 +1,63 @@
+package pdf
+
+import (
+	"time" )
+
+// WithDeterministicOutput() returns a FileOption that makes the file
+// depend only on what is written to it, so that generating the same
+// document twice produces identical bytes, as golden-file tests and
+// reproducible builds require.  timestamp replaces the current time
+// wherever it would be recorded, as in the creation date of a new
+// document; if it is zero, the Unix epoch is used.  The file
+// identifier of a new file is computed from timestamp and the
+// contents of the file when it is closed, rather than from the
+// current time and the filename, and dictionary keys are written in
+// sorted order rather than the order in which they were added.
+// Encrypted files are not deterministic, since their salts and
+// initialization vectors must be random.
+func WithDeterministicOutput(timestamp time.Time) FileOption {
+	if timestamp.IsZero() {
+		timestamp = time.Unix(0, 0).UTC()
+	}
+	return func(f *file) {
+		f.deterministic = true
+		f.timestamp = timestamp
+	}
+}
+
+// now() returns the time to record as the current time.
+func (f *file) now() time.Time {
+	if f.deterministic {
+		return f.timestamp
+	}
+	return time.Now()
+}
+
+// clock is implemented by Files that accept WithDeterministicOutput().
+type clock interface {
+	now() time.Time
+}
+
+// now() returns the time to record as the current time in the
+// document.
+func (d *Document) now() time.Time {
+	if c,ok := d.file.(clock); ok {
+		return c.now()
+	}
+	return time.Now()
+}
+
+func (f *file) sortsKeys() bool {
+	return f.deterministic
+}
+
+// sortsKeys() returns true if dictionaries serialized for file should
+// have their keys sorted.
+func sortsKeys(file ...File) bool {
+	if len(file) == 1 {
+		if s,ok := file[0].(interface{ sortsKeys() bool }); ok {
+			return s.sortsKeys()
+		}
+	}
+	return false
+}
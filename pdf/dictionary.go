@@ -1,5 +1,9 @@
 package pdf
 
+import (
+	"sort"
+	"strings" )
+
 // Implements the pdf.Object interface
 
 type ProtectedDictionary interface {
@@ -88,9 +92,17 @@ type ProtectedDictionary interface {
 	// Size() returns the number of key-value pairs
 	Size() int
 
-	// Keys() returns a slice of strings representing the 
-	// names in the dictionary
+	// Keys() returns a slice of strings representing the
+	// names in the dictionary in the order in which they were
+	// first added.  Serialize() writes the entries in the same
+	// order, so a dictionary built the same way always has the
+	// same serialization.
 	Keys() []string
+
+	// ForEach() calls f for each entry of the dictionary in the
+	// order of Keys().  The values passed to f have the same
+	// protection as those returned by Get().
+	ForEach(f func(key string, value Object))
 }
 
 type Dictionary interface {
@@ -106,18 +118,20 @@ type Dictionary interface {
 
 type dictionary struct {
 	dictionary map[string]Object
+	// keys holds the keys of the dictionary in the order in
+	// which they were added.
+	keys []string
 }
 
 // Constructor for Dictionary object
 func NewDictionary() Dictionary {
-	return &dictionary{make(map[string]Object, 16)}
-	
+	return &dictionary{make(map[string]Object, 16), nil}
 }
 
 func (d *dictionary) Clone() Object {
 	newDictionary := NewDictionary().(*dictionary)
-	for key,value := range d.dictionary {
-		newDictionary.dictionary[key] = value.Clone()
+	for _,key := range d.keys {
+		newDictionary.Add(key, d.dictionary[key].Clone())
 	}
 	return newDictionary
 }
@@ -135,6 +149,9 @@ func (d *dictionary) Unprotect() Object {
 }
 
 func (d *dictionary) Add(key string, o Object) {
+	if _,exists := d.dictionary[key]; !exists {
+		d.keys = append(d.keys, key)
+	}
 	d.dictionary[key] = o
 }
 
@@ -272,17 +289,52 @@ func (d *dictionary) GetStream(key string) ProtectedStream {
 }
 
 func (d *dictionary) Remove(key string) {
+	if _,exists := d.dictionary[key]; !exists {
+		return
+	}
 	delete(d.dictionary, key)
+	for i,k := range d.keys {
+		if k == key {
+			d.keys = append(d.keys[:i], d.keys[i+1:]...)
+			break
+		}
+	}
 }
 
 func (d *dictionary) Serialize(w Writer, file ...File) {
 	w.WriteString("<<")
 	haveAny := false
-	for key, value := range d.dictionary {
+	keys := d.keys
+	if sortsKeys(file...) {
+		keys = d.Keys()
+		sort.Strings(keys)
+	}
+	if forInspection(file...) && len(keys) > 0 {
+		// One key per line, indented by the nesting depth,
+		// which is tracked by the writer passed to the
+		// values.
+		iw,ok := w.(*indentingWriter)
+		if !ok {
+			iw = &indentingWriter{w, 0}
+		}
+		iw.depth++
+		for _,key := range keys {
+			iw.newline()
+			NewName(key).Serialize(iw, file...)
+			iw.WriteByte(' ')
+			d.dictionary[key].Serialize(iw, file...)
+		}
+		iw.depth--
+		iw.newline()
+		iw.WriteString(">>")
+		return
+	}
+	for _,key := range keys {
+		value := d.dictionary[key]
 		if haveAny {
 			w.WriteByte(' ')
 		}
-		NewName(key).Serialize(w, file...)
+		writeName(w, key)
 		w.WriteByte(' ')
 		value.Serialize(w, file...)
 		haveAny = true
@@ -290,19 +342,31 @@ func (d *dictionary) Serialize(w Writer, file ...File) {
 	w.WriteString(">>")
 }
 
+// indentingWriter tracks the nesting of dictionaries serialized for
+// inspection.
+type indentingWriter struct {
+	Writer
+	depth int
+}
+
+func (iw *indentingWriter) newline() {
+	iw.WriteByte('\n')
+	iw.WriteString(strings.Repeat("  ", iw.depth))
+}
+
 // Size() returns the number of key-value pairs
 func (d *dictionary) Size() int {
 	return len(d.dictionary)
 }
 
 func (d *dictionary) Keys() []string {
-	keys := make([]string, len(d.dictionary))
-	var i int = 0
-	for k,_ := range d.dictionary {
-		keys[i] = k
-		i += 1
+	return append([]string(nil), d.keys...)
+}
+
+func (d *dictionary) ForEach(f func(key string, value Object)) {
+	for _,key := range d.Keys() {
+		f(key, d.dictionary[key])
 	}
-	return keys;
 }
 
 func (d *dictionary) CheckNameValue (key string, expected string, file... File) bool {
@@ -345,7 +409,7 @@ func (pd protectedDictionary) Protect() Object {
 func (pd protectedDictionary) Unprotect() Object {
 	newDictionary := NewDictionary().(*dictionary)
 	for _,key := range pd.d.Keys() {
-		newDictionary.dictionary[key] = pd.d.Get(key).Protect()
+		newDictionary.Add(key, pd.d.Get(key).Protect())
 	}
 	return newDictionary
 }
@@ -355,7 +419,10 @@ func (pd protectedDictionary) Serialize(w Writer, file... File) {
 }
 
 func (pd protectedDictionary) Get(key string) Object {
-	return pd.d.Get(key).Protect()
+	if value := pd.d.Get(key); value != nil {
+		return value.Protect()
+	}
+	return nil
 }
 
 func (pd protectedDictionary) GetArray(key string) ProtectedArray {
@@ -414,6 +481,12 @@ func (pd protectedDictionary) Keys() []string {
 	return pd.d.Keys()
 }
 
+func (pd protectedDictionary) ForEach(f func(key string, value Object)) {
+	pd.d.ForEach(func(key string, value Object) {
+		f(key, value.Protect())
+	})
+}
+
 func (pd protectedDictionary) CheckNameValue(key string, expected string, file... File) bool {
 	return pd.d.CheckNameValue(key,expected,file...)
 }
@@ -0,0 +1,334 @@
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"strings" )
+
+// DifferenceKind classifies a Difference.
+type DifferenceKind int
+
+const (
+	// ObjectAdded means that the object at the path is present
+	// only in the second file.
+	ObjectAdded DifferenceKind = iota
+	// ObjectRemoved means that the object at the path is present
+	// only in the first file.
+	ObjectRemoved
+	// ObjectChanged means that the object at the path has
+	// different values in the two files.
+	ObjectChanged )
+
+func (k DifferenceKind) String() string {
+	switch k {
+	case ObjectAdded:
+		return "added"
+	case ObjectRemoved:
+		return "removed"
+	case ObjectChanged:
+		return "changed"
+	}
+	return fmt.Sprintf("DifferenceKind(%d)", int(k))
+}
+
+// Difference describes one difference found by Diff().
+type Difference struct {
+	Kind DifferenceKind
+	// Path locates the object by how it is reached from the
+	// document rather than by object number, e.g., "Page 3 →
+	// Resources → Font → F1 → BaseFont".  Paths begin with "Page
+	// n" (numbered from 1), "Catalog", or "Info".
+	Path string
+	// Message describes the object that was added or removed, or
+	// the old and new values of the object that changed.
+	Message string
+}
+
+func (d Difference) String() string {
+	return fmt.Sprintf("%s %s: %s", d.Kind, d.Path, d.Message)
+}
+
+// DiffReport is the result of Diff().
+type DiffReport struct {
+	Differences []Difference
+}
+
+// Equal() returns true if no differences were found.
+func (r *DiffReport) Equal() bool {
+	return len(r.Differences) == 0
+}
+
+// String() returns the differences, one per line.
+func (r *DiffReport) String() string {
+	var b bytes.Buffer
+	for _,d := range r.Differences {
+		b.WriteString(d.String())
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// Diff() compares the objects reachable from the pages, catalogs, and
+// information dictionaries of a and b, as a regression test of a
+// document generator might, and reports the objects that were added,
+// removed, or changed.  Objects are matched by where they are reached
+// rather than by object number, so renumbering isn't a difference,
+// nor are the encoding and /Length of streams, whose decoded contents
+// are compared instead.  Pages are compared in order with their
+// inherited attributes, and the page tree's structure is otherwise
+// ignored.  Objects that can't be read are reported as changed, and
+// unreachable objects are ignored.
+func Diff(a, b File) *DiffReport {
+	d := &differ{a: a, b: b, report: new(DiffReport), visited: make(map[[2]ObjectNumber]bool)}
+
+	// Pages are compared first so that references to them from
+	// elsewhere, such as destinations, aren't followed.
+	pagesA, pagesB := diffPages(a), diffPages(b)
+	for i:=0; i<len(pagesA) || i<len(pagesB); i++ {
+		path := fmt.Sprintf("Page %d", i+1)
+		switch {
+		case i >= len(pagesA):
+			d.add(ObjectAdded, path, "page")
+		case i >= len(pagesB):
+			d.add(ObjectRemoved, path, "page")
+		default:
+			if pagesA[i].reference != nil && pagesB[i].reference != nil {
+				d.visited[[2]ObjectNumber{pagesA[i].reference.ObjectNumber(a), pagesB[i].reference.ObjectNumber(b)}] = true
+			}
+			d.compareDictionaries(pagesA[i].dictionary, pagesB[i].dictionary, path, "Parent")
+		}
+	}
+	if catalogA, catalogB := a.Catalog(), b.Catalog(); catalogA != nil && catalogB != nil {
+		d.compareDictionaries(catalogA, catalogB, "Catalog", "Pages")
+	} else {
+		d.compare(catalogA, catalogB, "Catalog")
+	}
+	d.compare(a.Trailer().Get("Info"), b.Trailer().Get("Info"), "Info")
+	return d.report
+}
+
+type differ struct {
+	a, b File
+	report *DiffReport
+	// visited contains the pairs of objects already compared.
+	visited map[[2]ObjectNumber]bool
+}
+
+func (d *differ) add(kind DifferenceKind, path, format string, args ...interface{}) {
+	d.report.Differences = append(d.report.Differences, Difference{kind, path, fmt.Sprintf(format, args...)})
+}
+
+// resolve() returns the object to which o refers in f, or o itself if
+// it isn't a reference.
+func resolve(f File, o Object) (Object, error) {
+	if reference,ok := o.(ProtectedIndirect); ok {
+		return f.Object(reference.ObjectNumber(f))
+	}
+	return o, nil
+}
+
+// compare() compares x, from a, with y, from b.
+func (d *differ) compare(x, y Object, path string) {
+	switch {
+	case x == nil && y == nil:
+		return
+	case x == nil:
+		d.add(ObjectAdded, path, "%s", describe(d.b, y))
+		return
+	case y == nil:
+		d.add(ObjectRemoved, path, "%s", describe(d.a, x))
+		return
+	}
+	rx,okx := x.(ProtectedIndirect)
+	ry,oky := y.(ProtectedIndirect)
+	if okx && oky {
+		pair := [2]ObjectNumber{rx.ObjectNumber(d.a), ry.ObjectNumber(d.b)}
+		if d.visited[pair] {
+			return
+		}
+		d.visited[pair] = true
+	}
+	x,errx := resolve(d.a, x)
+	y,erry := resolve(d.b, y)
+	switch {
+	case errx != nil && erry != nil:
+		return
+	case errx != nil:
+		d.add(ObjectChanged, path, "unreadable (%v) → %s", errx, describe(d.b, y))
+		return
+	case erry != nil:
+		d.add(ObjectChanged, path, "%s → unreadable (%v)", describe(d.a, x), erry)
+		return
+	}
+
+	switch tx := x.(type) {
+	case ProtectedStream:
+		if ty,ok := y.(ProtectedStream); ok {
+			d.compareDictionaries(tx.Dictionary(), ty.Dictionary(), path, "Length", "Filter", "DecodeParms")
+			cx,cy := streamContents(tx), streamContents(ty)
+			if !bytes.Equal(cx, cy) {
+				d.add(ObjectChanged, path, "stream contents differ (%d bytes → %d bytes)", len(cx), len(cy))
+			}
+			return
+		}
+	case ProtectedDictionary:
+		if ty,ok := y.(ProtectedDictionary); ok {
+			d.compareDictionaries(tx, ty, path)
+			return
+		}
+	case ProtectedArray:
+		if ty,ok := y.(ProtectedArray); ok {
+			for i:=0; i<tx.Size() || i<ty.Size(); i++ {
+				elementPath := fmt.Sprintf("%s[%d]", path, i)
+				switch {
+				case i >= tx.Size():
+					d.add(ObjectAdded, elementPath, "%s", describe(d.b, ty.At(i)))
+				case i >= ty.Size():
+					d.add(ObjectRemoved, elementPath, "%s", describe(d.a, tx.At(i)))
+				default:
+					d.compare(tx.At(i), ty.At(i), elementPath)
+				}
+			}
+			return
+		}
+	default:
+		if vx,ok := numericValue(x); ok {
+			if vy,ok := numericValue(y); ok && vx == vy {
+				return
+			}
+		}
+		if describe(d.a, x) == describe(d.b, y) {
+			return
+		}
+	}
+	d.add(ObjectChanged, path, "%s → %s", describe(d.a, x), describe(d.b, y))
+}
+
+// compareDictionaries() compares the entries of x and y other than
+// those with the keys in ignore.
+func (d *differ) compareDictionaries(x, y ProtectedDictionary, path string, ignore ...string) {
+	ignored := func(key string) bool {
+		for _,k := range ignore {
+			if k == key {
+				return true
+			}
+		}
+		return false
+	}
+	for _,key := range x.Keys() {
+		if ignored(key) {
+			continue
+		}
+		d.compare(x.Get(key), y.Get(key), path + " → " + key)
+	}
+	for _,key := range y.Keys() {
+		if !ignored(key) && x.Get(key) == nil {
+			d.compare(nil, y.Get(key), path + " → " + key)
+		}
+	}
+}
+
+func streamContents(s ProtectedStream) []byte {
+	reader := s.Reader()
+	if reader == nil {
+		return nil
+	}
+	contents,_ := ioutil.ReadAll(reader)
+	return contents
+}
+
+// maxDescriptionLength limits the length of the values shown in
+// Difference messages.
+const maxDescriptionLength = 60
+
+// describe() returns a short description of o, which is in f: the
+// serialization of a simple object, or the type of a container.
+func describe(f File, o Object) string {
+	if reference,ok := o.(ProtectedIndirect); ok {
+		on := reference.ObjectNumber(f)
+		if resolved,err := f.Object(on); err == nil {
+			o = resolved
+		} else {
+			return on.String()
+		}
+	}
+	switch t := o.(type) {
+	case ProtectedStream:
+		return "stream"
+	case ProtectedDictionary:
+		if name,ok := t.GetName("Type"); ok {
+			return fmt.Sprintf("/%s dictionary", name)
+		}
+		return "dictionary"
+	case ProtectedArray:
+		return fmt.Sprintf("array of %d", t.Size())
+	case ProtectString:
+		if b := t.Bytes(); !printable(b) {
+			return fmt.Sprintf("<%x>", b)
+		}
+	case nil:
+		return "null"
+	}
+	s := ObjectStringDecorator{o}.String(f)
+	if len(s) > maxDescriptionLength {
+		s = s[:maxDescriptionLength] + "..."
+	}
+	return strings.TrimSpace(s)
+}
+
+type diffPage struct {
+	reference ProtectedIndirect
+	// dictionary is the page dictionary with the attributes
+	// inherited from the page tree added.
+	dictionary ProtectedDictionary
+}
+
+// inheritedPageAttributes are the page attributes that may be
+// inherited from the page tree.
+var inheritedPageAttributes = []string{"Resources", "MediaBox", "CropBox", "Rotate"}
+
+// diffPages() returns the pages of f in order.  Unlike
+// pageReferences(), it skips nodes that can't be read rather than
+// panicking.
+func diffPages(f File) []diffPage {
+	var result []diffPage
+	visited := make(map[ObjectNumber]bool)
+	var walk func(node Object, inherited Dictionary, depth int)
+	walk = func(node Object, inherited Dictionary, depth int) {
+		if reference,ok := node.(ProtectedIndirect); ok {
+			on := reference.ObjectNumber(f)
+			if visited[on] {
+				return
+			}
+			visited[on] = true
+		}
+		resolved,err := resolve(f, node)
+		dictionary,ok := resolved.(ProtectedDictionary)
+		if err != nil || !ok || depth > maxTreeDepth {
+			return
+		}
+		attributes := inherited.Clone().(Dictionary)
+		for _,key := range inheritedPageAttributes {
+			if value := dictionary.Get(key); value != nil {
+				attributes.Add(key, value)
+			}
+		}
+		kids := dictionary.GetArray("Kids")
+		if kids == nil || dictionary.CheckNameValue("Type", "Page") {
+			reference,_ := node.(ProtectedIndirect)
+			dictionary.ForEach(attributes.Add)
+			result = append(result, diffPage{reference, attributes})
+			return
+		}
+		for i:=0; i<kids.Size(); i++ {
+			walk(kids.At(i), attributes, depth+1)
+		}
+	}
+	if catalog := f.Catalog(); catalog != nil {
+		if pages := catalog.Get("Pages"); pages != nil {
+			walk(pages, NewDictionary(), 0)
+		}
+	}
+	return result
+}
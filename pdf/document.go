@@ -1,9 +1,5 @@
 package pdf
 
-import ("bufio"
-	"fmt"
-	"os")
-
 type Document struct {
 	file File
 	// existing is true if the xref and trailer were read from an
@@ -50,10 +46,76 @@ type Document struct {
 	// pageCount is initialized with the pre-existing page count.
 	pageCount uint
 
-	// DocumentInfo is initialized from a pre-existing documents
+	// DocumentInfo is initialized from a pre-existing document's
 	// document info dictionary.  Otherwise it is initialized to
-	// an empty dictionary.  It is not nil.
+	// an empty DocumentInfo.  originalInfo is a copy of its
+	// initial value, used to determine whether it has changed.
 	DocumentInfo
+	originalInfo DocumentInfo
+
+	// catalog holds the entries of the document catalog.  For a
+	// pre-existing document it is initialized with the existing
+	// catalog's entries so they are preserved when the catalog is
+	// rewritten.  The /Type and /Pages entries are set by
+	// finishCatalog().  It is not nil.
+	catalog Dictionary
+
+	// fonts is the set of fonts used on pages created with
+	// NewPage().
+	fonts map[Font]bool
+
+	// pageDecorator, if not nil, draws on each page created with
+	// NewPage() when the page is finished.
+	pageDecorator func(page *Page, number uint)
+
+	// pageCountForms holds the forms returned by PageCountForm(),
+	// which are written by Close().
+	pageCountForms map[pageCountFormKey]Indirect
+
+	// conformance is the conformance level enforced by Close().
+	conformance Conformance
+
+	// structureTree is nil unless StructureTree() has been called.
+	structureTree *StructureTree
+
+	// pageLabels maps the first page of each page label range to
+	// its label.  It is nil until page labels are first used.
+	pageLabels map[uint]PageLabel
+	pageLabelsChanged bool
+
+	// embeddedFiles is the /EmbeddedFiles name tree, which maps
+	// names to file specifications.  It is nil until attachments
+	// are first used.
+	embeddedFiles *NameTree
+	embeddedFilesChanged bool
+
+	// layers is nil until layers are first used.  newLayers
+	// contains the layers added by NewLayer().
+	layers []*Layer
+	newLayers []*Layer
+	layersChanged bool
+
+	// threads contains the threads added by NewThread().
+	threads []*Thread
+
+	// openPage is nil unless OpenAtPage() has been called.
+	openPage *openPage
+
+	// outlineSections is nil unless Append() has been called.
+	outlineSections []outlineSection
+
+	// copiers holds the ObjectCopier used by
+	// ImportPageAsXObject() for each source file.
+	copiers map[File]*ObjectCopier
+
+	// digests is shared by the copiers used by Append() so that
+	// objects common to several merged documents are written
+	// once.  It is nil until Append() is first called.
+	digests map[objectDigest]Indirect
+
+	// copyError is the first error reading an object copied from
+	// another document, which Close() returns.
+	copyError error
 }
 
 var (
@@ -100,11 +162,27 @@ func (d *Document) makeNewPageTree() {
 	d.SetMediaBox(0, 0, 612, 792)
 }
 
-// OpenDocument() constructs a document object from either a new or a pre-existing filename.
-func OpenDocument(filename string, mode int) *Document {
+// OpenDocument() constructs a document object from either a new or a
+// pre-existing filename.  Any options are passed to OpenFile().
+func OpenDocument(filename string, mode int, options ...FileOption) *Document {
+	f,existing,_ := OpenFile(filename, mode, options...)
+	return newDocument(f, existing)
+}
+
+// NewDocumentFromFile() constructs a document object from a File
+// obtained some other way than from a filename, such as from
+// NewFileFromReader() or NewFileFromWriter().  The document is
+// pre-existing if the file has a catalog.
+func NewDocumentFromFile(f File) *Document {
+	return newDocument(f, f.Catalog() != nil)
+}
+
+func newDocument(f File, existing bool) *Document {
 	d := new(Document)
 
-	d.file,d.existing,_ = OpenFile(filename, mode)
+	d.file,d.existing = f,existing
+	d.catalog = NewDictionary()
+	d.fonts = make(map[Font]bool, 14)
 
 	if !d.existing {
 		d.DocumentInfo = NewDocumentInfo()
@@ -114,27 +192,31 @@ func OpenDocument(filename string, mode int) *Document {
 		if existingInfo == nil {
 			d.DocumentInfo = NewDocumentInfo()
 		} else {
-			d.DocumentInfo = DocumentInfo{Dictionary: existingInfo, dirty: false}
+			d.DocumentInfo = ParseDocumentInfo(existingInfo)
+		}
+		d.originalInfo = d.DocumentInfo
+		if catalog := d.file.Catalog(); catalog != nil {
+			d.catalog = catalog.Unprotect().(Dictionary)
 		}
-		
+
 
 		existingPageTree := existingPageTree(d.file)
 		d.pageTreeRoot = existingPageTree.root
 		d.pageTreeRootIndirect = existingPageTree.rootReference
 		d.pageCount = existingPageTree.pageCount
-		out := bufio.NewWriter(os.Stdout)
-		out.WriteString("Pre-existing page tree root: ")
-		d.pageTreeRoot.Serialize(out,d.file)
-		out.WriteString("\n")
-		out.Flush()
 	}
 
 	d.streamFactory = defaultStreamFactory
 	d.pageFactory = NewPageFactory()
 	d.pageFactory.SetStreamFactory(d.streamFactory)
 
-	// Set a default producer field.  Clients calls to SetProducer() override this.
-	d.SetProducer("PDFiG")
+	// Set default producer and creation date fields for new
+	// documents.  Clients calls to SetProducer() and
+	// SetCreationDate() override these.
+	if !d.existing {
+		d.SetProducer("PDFiG")
+		d.SetCreationDate(d.now())
+	}
 
 	return d
 }
@@ -149,23 +231,60 @@ func (d *Document) release() {
 
 func (d *Document) finishCatalog() {
 	if d.pageTreeRootIndirect != nil {
-		catalog := NewDictionary()
-		catalog.Add("Type", NewName("Catalog"))
-		catalog.Add("Pages", d.pageTreeRootIndirect)
-		d.file.SetCatalog(catalog)
+		d.catalog.Add("Type", NewName("Catalog"))
+		d.catalog.Add("Pages", d.pageTreeRootIndirect)
+		d.file.SetCatalog(d.catalog)
+	}
+}
+
+// catalogArray() returns the array stored in the catalog under key,
+// creating an empty one if necessary.  If the catalog contains an
+// indirect reference to an array, it is replaced by a direct copy so
+// that additions to the returned array are written with the catalog.
+func (d *Document) catalogArray(key string) Array {
+	if a,ok := d.catalog.Get(key).(Array); ok {
+		return a
+	}
+	a := NewArray()
+	if existing := d.catalog.GetArray(key); existing != nil {
+		a.Append(existing)
+	}
+	d.catalog.Add(key, a)
+	return a
+}
+
+// catalogDictionary() returns the dictionary stored in the catalog
+// under key, creating an empty one if necessary.  As with
+// catalogArray(), an indirect reference is replaced by a direct copy.
+func (d *Document) catalogDictionary(key string) Dictionary {
+	if dictionary,ok := d.catalog.Get(key).(Dictionary); ok {
+		return dictionary
 	}
+	dictionary := NewDictionary()
+	if existing := d.catalog.GetDictionary(key); existing != nil {
+		dictionary = existing.Unprotect().(Dictionary)
+	}
+	d.catalog.Add(key, dictionary)
+	return dictionary
 }
 
 func (d *Document) finishCurrentPage() {
 	if d.currentPage != nil {
+		if d.pageDecorator != nil {
+			d.pageDecorator(d.currentPage, d.pageCount+1)
+		}
+		for font := range d.currentPage.fontMap {
+			d.fonts[font] = true
+		}
 		d.pages.Add(d.currentPage.Finish())
 		d.pageCount += 1
 		d.pageTreeRoot.Add("Count", NewIntNumeric(int(d.pageCount)))
+		d.currentPage = nil
 	}
 }
 
 func (d *Document) finishDocumentInfo() {
-	if d.DocumentInfo.IsDirty() {
+	if !d.DocumentInfo.equal(&d.originalInfo) {
 		d.file.SetInfo (d.DocumentInfo)
 	}
 }
@@ -176,6 +295,16 @@ func (d *Document) finishPageTree() {
 	}
 }
 
+// finishFonts() writes the fonts, such as OpenTypeFont, whose
+// dictionaries depend on the text shown in them.
+func (d *Document) finishFonts() {
+	for font := range d.fonts {
+		if f,ok := font.(interface{ Finish() }); ok {
+			f.Finish()
+		}
+	}
+}
+
 func (d *Document) finishProcSet() {
 	// Procset is option for PDF versions >= 1.4
 	// The following full set is recommended, however, for maximal compatibility.
@@ -208,16 +337,100 @@ func (d *Document) NewPage() *Page {
 	return d.currentPage
 }
 
-func (d *Document) Close() {
+// Close() finishes writing the document and closes the underlying
+// file.  If a conformance level has been set with SetConformance()
+// and the document does not conform, the document is written anyway
+// and the returned error is a *ConformanceError listing the
+// violations.  If an object copied from another document by
+// Append(), ExtractPages(), or ImportPageAsXObject() couldn't be
+// read, it is written as null and the error reading it is returned.
+func (d *Document) Close() error {
+	if err := d.canceled(); err != nil {
+		d.file.Close()
+		d.release()
+		return err
+	}
 	d.finishCurrentPage()
+	d.finishPageCountForms()
+	d.finishFonts()
 	d.finishProcSet()
 	d.finishPageTree()
+	d.finishOpenAction()
+	d.finishThreads()
+	d.finishStructureTree()
+	d.finishPageLabels()
+	d.finishEmbeddedFiles()
+	d.finishLayers()
+	d.finishOutline()
+	err := d.finishConformance()
+	if d.copyError != nil {
+		err = d.copyError
+	}
 	d.finishCatalog()
 	d.finishDocumentInfo()
 
 	d.file.Close()
 
 	d.release()
+	return err
+}
+
+// Discard() closes the document without writing anything to its file,
+// discarding any changes.  It is the way to close a document that was
+// opened only to be read, since Close() writes an update, which fails
+// if the file was opened read-only.
+func (d *Document) Discard() {
+	if f,ok := d.file.(interface{ abandon() }); ok {
+		f.abandon()
+	}
+	d.release()
+}
+
+// canceler is implemented by Files that accept WithContext().
+type canceler interface {
+	canceled() error
+}
+
+// canceled() returns the error of the context of the document's file
+// if the context is done, and nil otherwise.
+func (d *Document) canceled() error {
+	if c,ok := d.file.(canceler); ok {
+		return c.canceled()
+	}
+	return nil
+}
+
+// recoverCanceled() is deferred by operations that copy from one
+// document to another.  Reading from a file whose context is done
+// fails, which often causes a panic.  recoverCanceled() stops such
+// a panic if any of the documents has been cancelled, leaving the
+// error to be reported by Document.Close().
+func recoverCanceled(documents ...*Document) {
+	if r := recover(); r != nil {
+		for _,d := range documents {
+			if d.canceled() != nil {
+				return
+			}
+		}
+		panic(r)
+	}
+}
+
+// noteCopyError() records the error, if any, of a copier from another
+// document so that Close() returns it.
+func (d *Document) noteCopyError(copier *ObjectCopier) {
+	if d.copyError == nil {
+		d.copyError = copier.Err()
+	}
+}
+
+// PageCount() returns the number of pages in the document, including
+// any added by NewPage().
+func (d *Document) PageCount() uint {
+	if d.currentPage != nil {
+		return d.pageCount + 1
+	}
+	return d.pageCount
 }
 
 // Page(n) returns the ExistingPage (which contains a PageDictionary
@@ -226,14 +439,11 @@ func (d *Document) Close() {
 // while descending the page tree are copied into the dictionary, so
 // the dictionary may not exactly match the one in the file.
 func (d *Document) Page(n uint) *ExistingPage {
-	writer := bufio.NewWriter(os.Stdout)
-
-	fmt.Fprintf (writer, "Page(%d) called with page root: ", n)
-	d.pageTreeRoot.Serialize(writer, d.file)
-	writer.WriteString("\n")
-	writer.Flush()
-
-	return pageFromTree(d.pageTreeRoot, n)
+	page := pageFromTree(d.pageTreeRoot, n)
+	if page != nil {
+		page.document = d
+	}
+	return page
 }
 
 // SetStreamFactory() sets the StreamFactory used by the document for
@@ -252,34 +462,44 @@ func (d *Document) SetMediaBox(llx, lly, urx, ury float64) {
 	d.pageTreeRoot.Add("MediaBox", NewRectangle(llx, lly, urx, ury))
 }
 
+// SetCropBox(), SetBleedBox(), SetTrimBox(), and SetArtBox() set the
+// boxes of new pages that don't set their own.  They panic if the
+// box doesn't lie within the media box set by SetMediaBox().
 func (d *Document) SetCropBox(llx, lly, urx, ury float64) {
-	if !d.readyForNewPages {
-		d.makeNewPageTree()
-	}
-	d.pageTreeRoot.Add("CropBox", NewRectangle(llx, lly, urx, ury))
+	d.setBoundaryBox("CropBox", llx, lly, urx, ury)
 }
 
 func (d *Document) SetBleedBox(llx, lly, urx, ury float64) {
-	if !d.readyForNewPages {
-		d.makeNewPageTree()
-	}
-	d.pageTreeRoot.Add("BleedBox", NewRectangle(llx, lly, urx, ury))
+	d.setBoundaryBox("BleedBox", llx, lly, urx, ury)
 }
 
 func (d *Document) SetTrimBox(llx, lly, urx, ury float64) {
-	if !d.readyForNewPages {
-		d.makeNewPageTree()
-	}
-	d.pageTreeRoot.Add("TrimBox", NewRectangle(llx, lly, urx, ury))
+	d.setBoundaryBox("TrimBox", llx, lly, urx, ury)
 }
 
 func (d *Document) SetArtBox(llx, lly, urx, ury float64) {
+	d.setBoundaryBox("ArtBox", llx, lly, urx, ury)
+}
+
+func (d *Document) setBoundaryBox(boxname string, llx, lly, urx, ury float64) {
 	if !d.readyForNewPages {
 		d.makeNewPageTree()
 	}
-	d.pageTreeRoot.Add("ArtBox", NewRectangle(llx, lly, urx, ury))
+	box := normalizeBox([4]float64{llx, lly, urx, ury})
+	if array := d.pageTreeRoot.GetArray("MediaBox"); array != nil {
+		if mediaBox,ok := array.Floats(); ok && len(mediaBox) == 4 {
+			checkBoxWithin(boxname, box, [4]float64{mediaBox[0], mediaBox[1], mediaBox[2], mediaBox[3]})
+		}
+	}
+	d.pageTreeRoot.Add(boxname, NewRectangle(box[0], box[1], box[2], box[3]))
 }
 
 func (d *Document) WriteObject(object Object) Indirect {
 	return NewIndirect(d.file).Write(object)
 }
+
+// Permissions() returns the operations permitted by the document's
+// security handler.
+func (d *Document) Permissions() Permissions {
+	return d.file.Permissions()
+}
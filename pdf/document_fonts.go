@@ -0,0 +1,176 @@
+package pdf
+
+import (
+	"errors"
+	"io"
+	"sort" )
+
+// FontInfo describes a font used by a document.
+type FontInfo struct {
+	// BaseFont is the PostScript name of the font, including any
+	// subset prefix (e.g., "ABCDEF+Helvetica").
+	BaseFont string
+	// Subtype is the font type (e.g., "Type1", "TrueType",
+	// "Type0", "Type3").
+	Subtype string
+	// Embedded is true if the font program is embedded in the
+	// file.  Type3 fonts, whose glyphs are content streams, are
+	// always embedded.
+	Embedded bool
+	// Encoding is the name of the font's encoding, "Custom" for an
+	// encoding dictionary, "Embedded" for an embedded CMap, or ""
+	// if the font uses its built-in encoding.
+	Encoding string
+	// Pages lists the pages (numbered from 0) whose contents use
+	// the font, directly or through form XObjects.
+	Pages []uint
+	// Dictionary is the font dictionary.
+	Dictionary ProtectedDictionary
+}
+
+var fontNotEmbedded = errors.New(`Font program is not embedded`)
+
+// Fonts() returns the fonts in the resources of the document's pages
+// and of the form XObjects they draw, in order of first use.  A font
+// dictionary used by several pages is listed once.
+func (d *Document) Fonts() []FontInfo {
+	d.finishCurrentPage()
+
+	var fonts []FontInfo
+	index := make(map[ObjectNumber]int)
+	for n:=uint(0); n<d.pageCount; n++ {
+		page := d.Page(n)
+		if page == nil {
+			continue
+		}
+		visited := make(map[ObjectNumber]bool)
+		d.collectFonts(page.GetDictionary("Resources"), n, &fonts, index, visited, 0)
+	}
+	return fonts
+}
+
+// collectFonts() adds the fonts in resources, and in the resources of
+// the form XObjects they contain, to fonts.
+func (d *Document) collectFonts(resources ProtectedDictionary, page uint, fonts *[]FontInfo, index map[ObjectNumber]int, visited map[ObjectNumber]bool, depth int) {
+	if resources == nil || depth > maxFormDepth {
+		return
+	}
+
+	if fontResources := resources.GetDictionary("Font"); fontResources != nil {
+		// Keys are sorted so that the order is repeatable.
+		names := fontResources.Keys()
+		sort.Strings(names)
+		for _,name := range names {
+			dictionary := fontResources.GetDictionary(name)
+			if dictionary == nil {
+				continue
+			}
+			reference,isIndirect := fontResources.Get(name).(ProtectedIndirect)
+			if isIndirect {
+				o := reference.ObjectNumber(d.file)
+				if i,exists := index[o]; exists {
+					if pages := (*fonts)[i].Pages; pages[len(pages)-1] != page {
+						(*fonts)[i].Pages = append(pages, page)
+					}
+					continue
+				}
+				index[o] = len(*fonts)
+			}
+			info := newFontInfo(dictionary)
+			info.Pages = []uint{page}
+			*fonts = append(*fonts, info)
+		}
+	}
+
+	if xobjects := resources.GetDictionary("XObject"); xobjects != nil {
+		for _,name := range xobjects.Keys() {
+			if reference,ok := xobjects.Get(name).(ProtectedIndirect); ok {
+				o := reference.ObjectNumber(d.file)
+				if visited[o] {
+					continue
+				}
+				visited[o] = true
+			}
+			if form := xobjects.GetStream(name); form != nil {
+				if subtype,_ := form.Dictionary().GetName("Subtype"); subtype == "Form" {
+					d.collectFonts(form.Dictionary().GetDictionary("Resources"), page, fonts, index, visited, depth+1)
+				}
+			}
+		}
+	}
+}
+
+func newFontInfo(dictionary ProtectedDictionary) FontInfo {
+	info := FontInfo{Dictionary: dictionary}
+	info.BaseFont,_ = dictionary.GetName("BaseFont")
+	info.Subtype,_ = dictionary.GetName("Subtype")
+	if name,ok := dictionary.GetName("Encoding"); ok {
+		info.Encoding = name
+	} else if encoding := dictionary.Get("Encoding"); encoding != nil {
+		if _,isStream := encoding.Dereference().(ProtectedStream); isStream {
+			info.Encoding = "Embedded"
+		} else {
+			info.Encoding = "Custom"
+		}
+	}
+	program,_ := info.fontFile()
+	info.Embedded = info.Subtype == "Type3" || program != nil
+	return info
+}
+
+// fontDescriptor() returns the font descriptor, which for a Type0
+// font is that of its descendant font.
+func (fi FontInfo) fontDescriptor() ProtectedDictionary {
+	if fi.Subtype == "Type0" {
+		if descendants := fi.Dictionary.GetArray("DescendantFonts"); descendants != nil && descendants.Size() > 0 {
+			if descendant,ok := descendants.At(0).Dereference().(ProtectedDictionary); ok {
+				return descendant.GetDictionary("FontDescriptor")
+			}
+		}
+		return nil
+	}
+	return fi.Dictionary.GetDictionary("FontDescriptor")
+}
+
+// fontFile() returns the embedded font program and the key under
+// which it appears in the font descriptor, or nil.
+func (fi FontInfo) fontFile() (ProtectedStream, string) {
+	if descriptor := fi.fontDescriptor(); descriptor != nil {
+		for _,key := range []string{"FontFile", "FontFile2", "FontFile3"} {
+			if program := descriptor.GetStream(key); program != nil {
+				return program, key
+			}
+		}
+	}
+	return nil, ""
+}
+
+// Export() writes the embedded font program to w and returns the
+// format of the program: "pfa" for a Type 1 font (FontFile), "ttf"
+// for a TrueType font (FontFile2), "cff" for a compact font format
+// program, or "otf" for an OpenType font (FontFile3).  It returns an
+// error if the font is not embedded or its program cannot be decoded.
+func (fi FontInfo) Export(w io.Writer) (string, error) {
+	program,key := fi.fontFile()
+	var format string
+	switch key {
+	case "FontFile":
+		format = "pfa"
+	case "FontFile2":
+		format = "ttf"
+	case "FontFile3":
+		format = "cff"
+		if subtype,_ := program.Dictionary().GetName("Subtype"); subtype == "OpenType" {
+			format = "otf"
+		}
+	default:
+		return "", fontNotEmbedded
+	}
+
+	r := program.Reader()
+	if r == nil {
+		return "", unsupportedFilter
+	}
+	_,err := io.Copy(w, r)
+	return format, err
+}
@@ -1,44 +1,147 @@
 package pdf
 
+import "time"
+
+// DocumentInfo represents the contents of a document information
+// dictionary.  The standard entries are available as strongly typed
+// fields.  Any other entries found in a pre-existing information
+// dictionary are preserved and written back unchanged.  Empty strings
+// and zero times represent missing entries.
 type DocumentInfo struct {
-	Dictionary
+	Title string
+	Author string
+	Subject string
+	Keywords string
+	Creator string
+	Producer string
+	CreationDate time.Time
+	ModDate time.Time
+
+	// other contains entries other than the standard ones.  It
+	// may be nil.
+	other ProtectedDictionary
 	dirty bool
 }
 
 func NewDocumentInfo() DocumentInfo {
-	return DocumentInfo{NewDictionary(), false}
+	return DocumentInfo{}
+}
+
+// ParseDocumentInfo() constructs a DocumentInfo from an existing
+// document information dictionary.  Entries that are missing or have
+// the wrong type are left empty.
+func ParseDocumentInfo(d ProtectedDictionary) DocumentInfo {
+	var info DocumentInfo
+	date := func(key string) time.Time {
+		if b,ok := d.GetString(key); ok {
+			if t,err := ParseDate(string(b)); err == nil {
+				return t
+			}
+		}
+		return time.Time{}
+	}
+
+	info.Title = textString(d, "Title")
+	info.Author = textString(d, "Author")
+	info.Subject = textString(d, "Subject")
+	info.Keywords = textString(d, "Keywords")
+	info.Creator = textString(d, "Creator")
+	info.Producer = textString(d, "Producer")
+	info.CreationDate = date("CreationDate")
+	info.ModDate = date("ModDate")
+	info.other = d
+	return info
+}
+
+// Dictionary() returns a new document information dictionary
+// representing the contents of the DocumentInfo.
+func (d *DocumentInfo) Dictionary() Dictionary {
+	var result Dictionary
+	if d.other != nil {
+		result = d.other.Unprotect().(Dictionary)
+	} else {
+		result = NewDictionary()
+	}
+
+	textString := func(key, value string) {
+		if value != "" {
+			result.Add(key, NewTextString(value))
+		} else {
+			result.Remove(key)
+		}
+	}
+	date := func(key string, value time.Time) {
+		if !value.IsZero() {
+			result.Add(key, NewDate(value))
+		} else {
+			result.Remove(key)
+		}
+	}
+
+	textString("Title", d.Title)
+	textString("Author", d.Author)
+	textString("Subject", d.Subject)
+	textString("Keywords", d.Keywords)
+	textString("Creator", d.Creator)
+	textString("Producer", d.Producer)
+	date("CreationDate", d.CreationDate)
+	date("ModDate", d.ModDate)
+	return result
 }
 
-func (d DocumentInfo) IsDirty() bool {
+// IsDirty() returns true if any of the setters has been called.
+func (d *DocumentInfo) IsDirty() bool {
 	return d.dirty
 }
 
-func (d DocumentInfo) SetTitle(s string) {
+// equal() returns true if d and other have the same standard entries.
+func (d *DocumentInfo) equal(other *DocumentInfo) bool {
+	return d.Title == other.Title &&
+		d.Author == other.Author &&
+		d.Subject == other.Subject &&
+		d.Keywords == other.Keywords &&
+		d.Creator == other.Creator &&
+		d.Producer == other.Producer &&
+		d.CreationDate.Equal(other.CreationDate) &&
+		d.ModDate.Equal(other.ModDate)
+}
+
+func (d *DocumentInfo) SetTitle(s string) {
+	d.dirty = true
+	d.Title = s
+}
+
+func (d *DocumentInfo) SetAuthor(s string) {
+	d.dirty = true
+	d.Author = s
+}
+
+func (d *DocumentInfo) SetSubject(s string) {
 	d.dirty = true
-	d.Add("Title", NewTextString(s))
+	d.Subject = s
 }
 
-func (d DocumentInfo) SetAuthor(s string) {
+func (d *DocumentInfo) SetKeywords(s string) {
 	d.dirty = true
-	d.Add("Author", NewTextString(s))
+	d.Keywords = s
 }
 
-func (d DocumentInfo) SetSubject(s string) {
+func (d *DocumentInfo) SetCreator(s string) {
 	d.dirty = true
-	d.Add("Subject", NewTextString(s))
+	d.Creator = s
 }
 
-func (d DocumentInfo) SetKeywords(s string) {
+func (d *DocumentInfo) SetProducer(s string) {
 	d.dirty = true
-	d.Add("Keywords", NewTextString(s))
+	d.Producer = s
 }
 
-func (d DocumentInfo) SetCreator(s string) {
+func (d *DocumentInfo) SetCreationDate(t time.Time) {
 	d.dirty = true
-	d.Add("Creator", NewTextString(s))
+	d.CreationDate = t
 }
 
-func (d DocumentInfo) SetProducer(s string) {
+func (d *DocumentInfo) SetModDate(t time.Time) {
 	d.dirty = true
-	d.Add("Producer", NewTextString(s))
+	d.ModDate = t
 }
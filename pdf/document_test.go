@@ -1,8 +1,24 @@
 package pdf_test
 
 import (
+	"bytes"
+	"compress/zlib"
+	"context"
+	"encoding/binary"
+	"errors"
 	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"io/ioutil"
+	"math"
 	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
 	"github.com/mawicks/PDFiG/pdf" )
 
 func ExampleDocument() {
@@ -28,3 +44,2742 @@ func ExampleDocument() {
 
 	doc.Close()
 }
+
+func TestDates(t *testing.T) {
+	date := time.Date(2014, time.March, 7, 13, 45, 30, 0, time.FixedZone("", -(5*3600+30*60)))
+	if s := pdf.FormatDate(date); s != "D:20140307134530-05'30'" {
+		t.Errorf(`FormatDate() produced "%s"`, s)
+	}
+	if s := pdf.FormatDate(date.UTC()); s != "D:20140307191530Z" {
+		t.Errorf(`FormatDate() produced "%s"`, s)
+	}
+
+	for _,test := range []struct {
+		s string
+		expected time.Time
+	} {
+		{"D:20140307134530-05'30'", date},
+		{"D:20140307134530-05'30", date},
+		{"20140307191530Z", date},
+		{"D:2014", time.Date(2014, time.January, 1, 0, 0, 0, 0, time.UTC)},
+		{"D:201403071915", time.Date(2014, time.March, 7, 19, 15, 0, 0, time.UTC)}} {
+		if parsed,err := pdf.ParseDate(test.s); err != nil || !parsed.Equal(test.expected) {
+			t.Errorf(`ParseDate("%s") returned %v (err=%v); expected %v`, test.s, parsed, err, test.expected)
+		}
+	}
+
+	if _,err := pdf.ParseDate("D:garbage"); err == nil {
+		t.Error(`ParseDate() accepted an invalid date`)
+	}
+}
+
+func TestDocumentInfo(t *testing.T) {
+	created := time.Date(2014, time.March, 7, 13, 45, 30, 0, time.UTC)
+	info := pdf.NewDocumentInfo()
+	info.Title = "Résumé ∑"
+	info.Author = "Nobody"
+	info.CreationDate = created
+
+	d := info.Dictionary()
+	d.Add("Custom", pdf.NewTextString("preserved"))
+
+	parsed := pdf.ParseDocumentInfo(d)
+	if parsed.Title != info.Title || parsed.Author != info.Author || !parsed.CreationDate.Equal(created) {
+		t.Errorf(`ParseDocumentInfo() produced %+v; expected %+v`, parsed, info)
+	}
+	if parsed.Subject != "" || !parsed.ModDate.IsZero() {
+		t.Error(`ParseDocumentInfo() produced values for missing entries`)
+	}
+
+	parsed.Author = ""
+	rewritten := parsed.Dictionary()
+	if rewritten.Get("Author") != nil {
+		t.Error(`Emptied field was not removed from dictionary`)
+	}
+	if custom,_ := rewritten.GetString("Custom"); string(custom) != "preserved" {
+		t.Error(`Non-standard entry was not preserved`)
+	}
+}
+
+func TestConformance(t *testing.T) {
+	filename := "/tmp/test-conformance.pdf"
+	os.Remove(filename)
+	doc := pdf.OpenDocument(filename, os.O_RDWR|os.O_CREATE)
+	doc.SetConformance(pdf.PDFA2B)
+	page := doc.NewPage()
+	fmt.Fprintf(page, "BT /%s 24 Tf 250 528 Td (Hello World!) Tj ET", page.AddFont(pdf.NewStandardFont(pdf.Helvetica)))
+
+	if violations := doc.ConformanceViolations(); len(violations) != 2 {
+		t.Errorf(`Conformance violations %q; expected unembedded font and missing output intent`, violations)
+	}
+
+	// A minimal ICC profile header
+	profile := make([]byte, 128)
+	copy(profile[16:], "RGB ")
+	copy(profile[36:], "acsp")
+	if err := doc.AddOutputIntent(pdf.OutputIntent{
+		Subtype: pdf.OutputIntentPDFA,
+		OutputConditionIdentifier: "sRGB IEC61966-2.1",
+		Profile: profile}); err != nil {
+		t.Errorf(`AddOutputIntent() failed: %v`, err)
+	}
+	if err := doc.AddOutputIntent(pdf.OutputIntent{Profile: []byte("not a profile")}); err == nil {
+		t.Errorf(`AddOutputIntent() accepted an invalid profile`)
+	}
+
+	err := doc.Close()
+	conformanceError,ok := err.(*pdf.ConformanceError)
+	if !ok {
+		t.Fatalf(`Close() returned %v; expected a ConformanceError`, err)
+	}
+	if len(conformanceError.Violations) != 1 {
+		t.Errorf(`Close() reported violations %q; expected only an unembedded font`,
+			conformanceError.Violations)
+	}
+
+	// JavaScript is found behind indirect references, here an
+	// action of an annotation of a page.
+	os.Remove(filename)
+	doc = pdf.OpenDocument(filename, os.O_RDWR|os.O_CREATE)
+	doc.SetConformance(pdf.PDFA2B)
+	action := pdf.NewDictionary()
+	action.Add("S", pdf.NewName("JavaScript"))
+	action.Add("JS", pdf.NewTextString("app.alert('Hello')"))
+	annotation := pdf.NewDictionary()
+	annotation.Add("Type", pdf.NewName("Annot"))
+	annotation.Add("Subtype", pdf.NewName("Link"))
+	annotation.Add("Rect", pdf.NewRectangle(0, 0, 100, 100))
+	annotation.Add("A", doc.WriteObject(action))
+	doc.NewPage().AddAnnotation(annotation)
+	doc.NewPage()
+	containsJavaScript := func(violations []string) bool {
+		for _,violation := range violations {
+			if violation == "Document contains JavaScript" {
+				return true
+			}
+		}
+		return false
+	}
+	if violations := doc.ConformanceViolations(); !containsJavaScript(violations) {
+		t.Errorf(`Conformance violations %q don't include JavaScript behind references`, violations)
+	}
+	err = doc.Close()
+	if conformanceError,ok := err.(*pdf.ConformanceError); !ok || !containsJavaScript(conformanceError.Violations) {
+		t.Errorf(`Close() of a document with JavaScript behind references returned %v`, err)
+	}
+}
+
+func TestStructureTree(t *testing.T) {
+	filename := "/tmp/test-structure.pdf"
+	os.Remove(filename)
+
+	doc := pdf.OpenDocument(filename, os.O_RDWR|os.O_CREATE)
+	tree := doc.StructureTree()
+	tree.MapRole("Heading", pdf.TagH1)
+	root := tree.NewElement(pdf.TagDocument)
+	heading := root.NewElement("Heading")
+	figure := root.NewElement(pdf.TagFigure)
+	figure.SetAlt("Two diagonal lines")
+
+	page := doc.NewPage()
+	name := page.AddFont(pdf.NewStandardFont(pdf.Helvetica))
+	page.BeginMarkedContent(heading)
+	fmt.Fprintf(page, "BT /%s 24 Tf 72 700 Td (Heading) Tj ET", name)
+	page.EndMarkedContent()
+	page.BeginMarkedContent(figure)
+	fmt.Fprintf(page, "0 0 m 612 792 l s")
+	page.EndMarkedContent()
+
+	page = doc.NewPage()
+	page.BeginMarkedContent(figure)
+	fmt.Fprintf(page, "0 792 m 612 0 l s")
+	page.EndMarkedContent()
+	doc.Close()
+
+	doc = pdf.OpenDocument(filename, os.O_RDWR)
+	defer doc.Close()
+
+	elements := doc.LogicalStructure()
+	if len(elements) != 1 || elements[0].Tag != pdf.TagDocument || len(elements[0].Kids) != 2 {
+		t.Fatalf(`LogicalStructure() returned %+v`, elements)
+	}
+	h := elements[0].Kids[0]
+	if h.Tag != "Heading" || h.Role != pdf.TagH1 || h.Parent != elements[0] {
+		t.Errorf(`Heading element read as %+v`, h)
+	}
+	if len(h.Content) != 1 || h.Content[0].PageIndex != 0 || h.Content[0].MCID != 0 {
+		t.Errorf(`Heading content read as %+v`, h.Content)
+	}
+	f := elements[0].Kids[1]
+	if f.Role != pdf.TagFigure || f.Alt != "Two diagonal lines" {
+		t.Errorf(`Figure element read as %+v`, f)
+	}
+	if len(f.Content) != 2 || f.Content[0].PageIndex != 0 || f.Content[0].MCID != 1 ||
+		f.Content[1].PageIndex != 1 || f.Content[1].MCID != 0 {
+		t.Errorf(`Figure content read as %+v`, f.Content)
+	}
+}
+
+func TestStructureTreeObjects(t *testing.T) {
+	filename := "/tmp/test-structure-objects.pdf"
+	os.Remove(filename)
+
+	doc := pdf.OpenDocument(filename, os.O_RDWR|os.O_CREATE)
+	root := doc.StructureTree().NewElement(pdf.TagDocument)
+	heading := root.NewElement(pdf.TagH1)
+	figure := root.NewElement(pdf.TagFigure)
+
+	page := doc.NewPage()
+	page.BeginMarkedContent(heading)
+	page.EndMarkedContent()
+	page.BeginMarkedContent(figure)
+	page.EndMarkedContent()
+	page = doc.NewPage()
+	page.BeginArtifact()
+	page.EndMarkedContent()
+	page.BeginMarkedContent(figure)
+	page.EndMarkedContent()
+	doc.Close()
+
+	r,_,_ := pdf.OpenFile(filename, os.O_RDONLY)
+	if marked,_ := r.Catalog().GetDictionary("MarkInfo").GetBoolean("Marked"); !marked {
+		t.Errorf(`Catalog has /MarkInfo %v`, r.Catalog().Get("MarkInfo"))
+	}
+	treeRoot := r.Catalog().GetDictionary("StructTreeRoot")
+	if treeRoot == nil || !treeRoot.CheckNameValue("Type", "StructTreeRoot") {
+		t.Fatalf(`Catalog has /StructTreeRoot %v`, r.Catalog().Get("StructTreeRoot"))
+	}
+	if next,_ := treeRoot.GetInt("ParentTreeNextKey"); next != 2 {
+		t.Errorf(`/ParentTreeNextKey is %d; expected 2`, next)
+	}
+	document := treeRoot.GetArray("K").At(0).Dereference().(pdf.ProtectedDictionary)
+	elements := document.GetArray("K")
+	kids := r.Catalog().GetDictionary("Pages").GetArray("Kids")
+
+	// Each page's /StructParents key selects the array of the
+	// elements owning its marked-content sequences, indexed by MCID.
+	parentTree := pdf.ReadNumberTree(treeRoot.GetDictionary("ParentTree"))
+	expected := [][]int{{0, 1}, {1}}
+	for n,owners := range expected {
+		page := kids.At(n).Dereference().(pdf.ProtectedDictionary)
+		key,ok := page.GetInt("StructParents")
+		if !ok {
+			t.Errorf(`Page %d has no /StructParents`, n)
+			continue
+		}
+		parents,_ := parentTree.Get(key).Dereference().(pdf.ProtectedArray)
+		if parents == nil || parents.Size() != len(owners) {
+			t.Errorf(`/ParentTree entry %d for page %d is %v`, key, n, parentTree.Get(key))
+			continue
+		}
+		for mcid,owner := range owners {
+			if toString(parents.At(mcid), r) != toString(elements.At(owner), r) {
+				t.Errorf(`MCID %d on page %d has parent %s`, mcid, n, toString(parents.At(mcid), r))
+			}
+		}
+	}
+
+	// The heading's content is on its /Pg; the figure's second
+	// sequence is on another page, so it needs a marked-content
+	// reference.
+	h := elements.At(0).Dereference().(pdf.ProtectedDictionary)
+	if toString(h.Get("Pg"), r) != toString(kids.At(0), r) || toString(h.Get("K"), r) != "[0]" {
+		t.Errorf(`Heading element has /Pg %v and /K %v`, h.Get("Pg"), h.Get("K"))
+	}
+	f := elements.At(1).Dereference().(pdf.ProtectedDictionary)
+	if k := toString(f.Get("K"), r); k != "[1 <</Type /MCR /Pg " + toString(kids.At(1), r) + " /MCID 0>>]" {
+		t.Errorf(`Figure element has /K %s`, k)
+	}
+	r.Close()
+
+	doc = pdf.OpenDocument(filename, os.O_RDONLY)
+	defer doc.Discard()
+	for n,sequences := range []string{
+		"/H1 <</MCID 0>> BDC  EMC  /Figure <</MCID 1>> BDC  EMC",
+		"/Artifact BMC  EMC  /Figure <</MCID 0>> BDC  EMC"} {
+		if contents,_ := ioutil.ReadAll(doc.Page(uint(n)).Reader()); !bytes.Contains(contents, []byte(sequences)) {
+			t.Errorf(`Page %d has contents %q`, n, contents)
+		}
+	}
+}
+
+func TestPageLabels(t *testing.T) {
+	filename := "/tmp/test-page-labels.pdf"
+	os.Remove(filename)
+
+	doc := pdf.OpenDocument(filename, os.O_RDWR|os.O_CREATE)
+	for i:=0; i<8; i++ {
+		doc.NewPage()
+	}
+	if label := doc.PageLabel(4); label != "5" {
+		t.Errorf(`PageLabel() returned "%s" for an unlabeled document`, label)
+	}
+	doc.SetPageLabel(0, pdf.PageLabel{Style: pdf.LowerRoman})
+	doc.SetPageLabel(4, pdf.PageLabel{Style: pdf.Decimal})
+	doc.SetPageLabel(6, pdf.PageLabel{Style: pdf.UpperAlpha, Prefix: "App-", Start: 27})
+	doc.Close()
+
+	doc = pdf.OpenDocument(filename, os.O_RDWR)
+	defer doc.Close()
+	for i,expected := range []string{"i", "ii", "iii", "iv", "1", "2", "App-AA", "App-BB"} {
+		if label := doc.PageLabel(uint(i)); label != expected {
+			t.Errorf(`PageLabel(%d) returned "%s"; expected "%s"`, i, label, expected)
+		}
+	}
+}
+
+func TestAttachments(t *testing.T) {
+	filename := "/tmp/test-attachments.pdf"
+	os.Remove(filename)
+
+	modified := time.Date(2014, time.March, 7, 13, 45, 30, 0, time.UTC)
+	attachments := []pdf.Attachment{
+		{Name: "data.csv", MIMEType: "text/csv", ModDate: modified, Contents: []byte("a,b\n1,2\n")},
+		{Name: "notes.txt", Description: "Notes", Contents: []byte("Nothing to see here")}}
+
+	doc := pdf.OpenDocument(filename, os.O_RDWR|os.O_CREATE)
+	page := doc.NewPage()
+	page.AttachFile(pdf.Attachment{Name: "pinned.txt", Contents: []byte("Pinned")}, pdf.PaperclipIcon, 72, 700, 86, 720)
+	doc.Attach(attachments[1])
+	doc.Attach(attachments[0])
+	doc.Close()
+
+	doc = pdf.OpenDocument(filename, os.O_RDWR)
+	defer doc.Close()
+	if annotations := doc.Page(0).GetArray("Annots"); annotations == nil || annotations.Size() != 1 {
+		t.Errorf(`Page has annotations %v; expected one file attachment annotation`, annotations)
+	}
+	read := doc.Attachments()
+	if len(read) != len(attachments) {
+		t.Fatalf(`Attachments() returned %d attachments; expected %d`, len(read), len(attachments))
+	}
+	for i,a := range attachments {
+		r := read[i]
+		if r.Name != a.Name || r.Description != a.Description || r.MIMEType != a.MIMEType ||
+			!r.ModDate.Equal(a.ModDate) || !bytes.Equal(r.Contents, a.Contents) {
+			t.Errorf(`Attachment read as %+v; expected %+v`, r, a)
+		}
+	}
+}
+
+func TestLayers(t *testing.T) {
+	filename := "/tmp/test-layers.pdf"
+	os.Remove(filename)
+
+	doc := pdf.OpenDocument(filename, os.O_RDWR|os.O_CREATE)
+	streets := doc.NewLayer("Streets", true)
+	labels := doc.NewLayer("Labels", false)
+	page := doc.NewPage()
+	page.BeginLayer(streets)
+	fmt.Fprintf(page, "0 0 m 612 792 l s")
+	page.EndMarkedContent()
+	page.BeginLayer(labels)
+	fmt.Fprintf(page, "BT /%s 12 Tf 72 72 Td (Main Street) Tj ET", page.AddFont(pdf.NewStandardFont(pdf.Helvetica)))
+	page.EndMarkedContent()
+	doc.Close()
+
+	doc = pdf.OpenDocument(filename, os.O_RDWR)
+	layers := doc.Layers()
+	if len(layers) != 2 || layers[0].Name() != "Streets" || !layers[0].Visible() ||
+		layers[1].Name() != "Labels" || layers[1].Visible() {
+		t.Fatalf(`Layers() returned %v`, layers)
+	}
+	layers[0].SetVisible(false)
+	doc.NewLayer("Grid", true)
+	doc.Close()
+
+	doc = pdf.OpenDocument(filename, os.O_RDWR)
+	defer doc.Close()
+	layers = doc.Layers()
+	if len(layers) != 3 || layers[0].Visible() || layers[1].Visible() || !layers[2].Visible() {
+		t.Errorf(`Layers() returned %v after update`, layers)
+	}
+}
+
+func TestWatermark(t *testing.T) {
+	filename := "/tmp/test-watermark.pdf"
+	os.Remove(filename)
+
+	doc := pdf.OpenDocument(filename, os.O_RDWR|os.O_CREATE)
+	for i:=0; i<3; i++ {
+		fmt.Fprintf(doc.NewPage(), "0 0 m 612 792 l s")
+	}
+	doc.Close()
+
+	doc = pdf.OpenDocument(filename, os.O_RDWR)
+	doc.TextWatermark("DRAFT", 72, pdf.WatermarkOptions{Opacity: 0.3, Rotation: 45, Pages: []uint{0, 2}})
+	doc.Close()
+
+	doc = pdf.OpenDocument(filename, os.O_RDWR)
+	defer doc.Close()
+	for n,expected := range []int{4, 1, 4} {
+		page := doc.Page(uint(n))
+		contents := page.GetArray("Contents")
+		size := 1
+		if contents != nil {
+			size = contents.Size()
+		}
+		if size != expected {
+			t.Errorf(`Page %d has %d content streams; expected %d`, n, size, expected)
+		}
+		if xobjects := page.GetDictionary("Resources").GetDictionary("XObject"); (xobjects != nil) != (expected != 1) {
+			t.Errorf(`Page %d has XObject resources %v`, n, xobjects)
+		}
+	}
+}
+
+func TestTwoWatermarks(t *testing.T) {
+	filename := "/tmp/test-watermarks.pdf"
+	os.Remove(filename)
+
+	doc := pdf.OpenDocument(filename, os.O_RDWR|os.O_CREATE)
+	fmt.Fprintf(doc.NewPage(), "0 0 m 612 792 l s")
+	doc.TextWatermark("DRAFT", 72, pdf.WatermarkOptions{})
+	doc.TextWatermark("SECRET", 36, pdf.WatermarkOptions{Under: true})
+	doc.Close()
+
+	doc = pdf.OpenDocument(filename, os.O_RDWR)
+	defer doc.Close()
+	page := doc.Page(0)
+	xobjects := page.GetDictionary("Resources").GetDictionary("XObject")
+	if xobjects == nil || xobjects.Size() != 2 {
+		t.Fatalf(`Watermarked page has XObject resources %v; expected two forms`, xobjects)
+	}
+	// Each stamp draws its own form.
+	contents,_ := ioutil.ReadAll(page.Reader())
+	forms := make(map[string]bool)
+	fields := strings.Fields(string(contents))
+	for i,field := range fields {
+		if i+1 < len(fields) && fields[i+1] == "Do" {
+			if name := strings.TrimPrefix(field, "/"); xobjects.Get(name) != nil {
+				forms[name] = true
+			}
+		}
+	}
+	if len(forms) != 2 {
+		t.Errorf(`Watermarked page with contents "%s" draws %d distinct forms; expected 2`, contents, len(forms))
+	}
+}
+
+func TestExtractPages(t *testing.T) {
+	filename := "/tmp/test-extract-source.pdf"
+	extracted := "/tmp/test-extract.pdf"
+	os.Remove(filename)
+
+	doc := pdf.OpenDocument(filename, os.O_RDWR|os.O_CREATE)
+	font := pdf.NewStandardFont(pdf.Helvetica)
+	for i:=0; i<3; i++ {
+		page := doc.NewPage()
+		fmt.Fprintf(page, "BT /%s 24 Tf 72 72 Td (Page %d) Tj ET", page.AddFont(font), i+1)
+	}
+	doc.Close()
+
+	doc = pdf.OpenDocument(filename, os.O_RDWR)
+	out := doc.ExtractPages(extracted, []uint{2, 0})
+	out.Close()
+	doc.Close()
+
+	out = pdf.OpenDocument(extracted, os.O_RDWR)
+	defer out.Close()
+	for n,expected := range []string{"Page 3", "Page 1"} {
+		contents,_ := ioutil.ReadAll(out.Page(uint(n)).Reader())
+		if !bytes.Contains(contents, []byte(expected)) {
+			t.Errorf(`Extracted page %d has contents "%s"; expected "%s"`, n, contents, expected)
+		}
+	}
+	if out.Page(2) != nil {
+		t.Error(`Extracted document has more than two pages`)
+	}
+
+	contents,_ := ioutil.ReadFile(extracted)
+	if n := bytes.Count(contents, []byte("/Helvetica")); n != 1 {
+		t.Errorf(`Shared font was copied %d times; expected once`, n)
+	}
+}
+
+func TestImportPageAsXObject(t *testing.T) {
+	filename := "/tmp/test-import-source.pdf"
+	imported := "/tmp/test-import.pdf"
+	os.Remove(filename)
+	os.Remove(imported)
+
+	doc := pdf.OpenDocument(filename, os.O_RDWR|os.O_CREATE)
+	font := pdf.NewStandardFont(pdf.Helvetica)
+	for i:=0; i<2; i++ {
+		page := doc.NewPage()
+		fmt.Fprintf(page, "BT /%s 24 Tf 72 72 Td (Page %d) Tj ET", page.AddFont(font), i+1)
+	}
+	doc.Close()
+
+	doc = pdf.OpenDocument(filename, os.O_RDWR)
+	out := pdf.OpenDocument(imported, os.O_RDWR|os.O_CREATE)
+	first := out.ImportPageAsXObject(doc, 0)
+	second := out.ImportPageAsXObject(doc, 1)
+	if out.ImportPageAsXObject(doc, 2) != nil {
+		t.Error(`ImportPageAsXObject() returned a form for a nonexistent page`)
+	}
+	if first.Width <= 0 || first.Height <= 0 {
+		t.Errorf(`Imported page has dimensions %g x %g`, first.Width, first.Height)
+	}
+	page := out.NewPage()
+	page.DrawImportedPage(first, 0, 0, 0.5)
+	page.DrawImportedPage(second, first.Width/2, 0, 0.5)
+	page.DrawImportedPage(first, 0, first.Height/2, 0.5)
+	out.Close()
+	doc.Close()
+
+	out = pdf.OpenDocument(imported, os.O_RDWR)
+	contents,_ := ioutil.ReadAll(out.Page(0).Reader())
+	out.Close()
+	if bytes.Count(contents, []byte("/X1 Do")) != 2 || bytes.Count(contents, []byte("/X2 Do")) != 1 {
+		t.Errorf(`Page has contents "%s"; expected two forms drawn three times`, contents)
+	}
+
+	contents,_ = ioutil.ReadFile(imported)
+	if n := bytes.Count(contents, []byte("/Helvetica")); n != 1 {
+		t.Errorf(`Shared font was copied %d times; expected once`, n)
+	}
+}
+
+func TestScaleContent(t *testing.T) {
+	filename := "/tmp/test-scale.pdf"
+	os.Remove(filename)
+
+	doc := pdf.OpenDocument(filename, os.O_RDWR|os.O_CREATE)
+	page := doc.NewPage()
+	page.SetMediaBox(0, 0, 500, 700)
+	fmt.Fprintf(page, "0 0 m 500 700 l s")
+	doc.Close()
+
+	doc = pdf.OpenDocument(filename, os.O_RDWR)
+	existing := doc.Page(0)
+	existing.ScaleContent(2)
+	existing.SetRotate(-90)
+	existing.Rewrite()
+	doc.Close()
+
+	doc = pdf.OpenDocument(filename, os.O_RDWR)
+	defer doc.Close()
+	existing = doc.Page(0)
+	if rotate,_ := existing.GetInt("Rotate"); rotate != 270 {
+		t.Errorf(`Page has /Rotate %d; expected 270`, rotate)
+	}
+	box := existing.GetArray("MediaBox")
+	var top float64
+	switch n := box.At(3).(type) {
+	case *pdf.IntNumeric:
+		top = float64(n.Value())
+	case *pdf.RealNumeric:
+		top = float64(n.Value())
+	}
+	if top != 1400 {
+		t.Errorf(`Page has media box with top %g; expected 1400`, top)
+	}
+	contents,_ := ioutil.ReadAll(existing.Reader())
+	if !bytes.HasPrefix(contents, []byte("q 2 0 0 2 0 0 cm")) || !bytes.Contains(contents, []byte("Q")) {
+		t.Errorf(`Page has contents "%s"; expected scaled contents`, contents)
+	}
+}
+
+func TestPageBoxes(t *testing.T) {
+	filename := "/tmp/test-page-boxes.pdf"
+	os.Remove(filename)
+
+	doc := pdf.OpenDocument(filename, os.O_RDWR|os.O_CREATE)
+	doc.SetMediaBox(0, 0, 630, 810)
+	doc.SetTrimBox(9, 9, 621, 801)
+	doc.NewPage()
+	page := doc.NewPage()
+	page.SetMediaBox(0, 0, 500, 700)
+	page.SetBleedBox(495, 695, 5, 5)
+	page.SetCropBox(10, 10, 490, 690)
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error(`SetArtBox() outside the media box did not panic`)
+			}
+		}()
+		page.SetArtBox(0, 0, 501, 700)
+	}()
+	doc.Close()
+
+	doc = pdf.OpenDocument(filename, os.O_RDWR)
+	defer doc.Close()
+	inherited := doc.Page(0)
+	if box,ok := inherited.MediaBox(); !ok || box != [4]float64{0, 0, 630, 810} {
+		t.Errorf(`Page 0 has media box %v`, box)
+	}
+	if box,ok := inherited.CropBox(); !ok || box != [4]float64{0, 0, 630, 810} {
+		t.Errorf(`Page 0 has crop box %v; expected the media box`, box)
+	}
+
+	existing := doc.Page(1)
+	for _,test := range []struct {
+		name string
+		get func() ([4]float64, bool)
+		expect [4]float64
+	} {
+		{"media", existing.MediaBox, [4]float64{0, 0, 500, 700}},
+		{"crop", existing.CropBox, [4]float64{10, 10, 490, 690}},
+		{"bleed", existing.BleedBox, [4]float64{5, 5, 495, 695}},
+		{"trim", existing.TrimBox, [4]float64{10, 10, 490, 690}},
+		{"art", existing.ArtBox, [4]float64{10, 10, 490, 690}} } {
+		if box,ok := test.get(); !ok || box != test.expect {
+			t.Errorf(`Page 1 has %s box %v; expected %v`, test.name, box, test.expect)
+		}
+	}
+}
+
+func TestConsolidateContents(t *testing.T) {
+	filename := "/tmp/test-consolidate.pdf"
+	os.Remove(filename)
+
+	doc := pdf.OpenDocument(filename, os.O_RDWR|os.O_CREATE)
+	fmt.Fprintf(doc.NewPage(), "0 0 m 500 700 l s")
+	doc.Close()
+
+	doc = pdf.OpenDocument(filename, os.O_RDWR)
+	existing := doc.Page(0)
+	existing.ScaleContent(2)
+	expected,_ := ioutil.ReadAll(existing.Reader())
+	if err := existing.ConsolidateContents(); err != nil {
+		t.Fatalf(`ConsolidateContents() failed: %v`, err)
+	}
+	existing.Rewrite()
+	doc.Close()
+
+	doc = pdf.OpenDocument(filename, os.O_RDWR)
+	defer doc.Close()
+	existing = doc.Page(0)
+	if existing.GetStream("Contents") == nil {
+		t.Fatalf(`Page has /Contents %v; expected a single stream`, existing.Get("Contents"))
+	}
+	if contents,_ := ioutil.ReadAll(existing.Reader()); !bytes.Equal(contents, expected) {
+		t.Errorf(`Page has contents %q; expected %q`, contents, expected)
+	}
+	if err := existing.ConsolidateContents(); err != nil {
+		t.Errorf(`ConsolidateContents() of a single stream failed: %v`, err)
+	}
+}
+
+func TestImpose(t *testing.T) {
+	filename := "/tmp/test-impose-source.pdf"
+	os.Remove(filename)
+
+	doc := pdf.OpenDocument(filename, os.O_RDWR|os.O_CREATE)
+	for i:=0; i<5; i++ {
+		fmt.Fprintf(doc.NewPage(), "%% Page %d", i+1)
+	}
+	doc.Close()
+
+	doc = pdf.OpenDocument(filename, os.O_RDWR)
+	defer doc.Close()
+	for _,test := range []struct {
+		layout pdf.ImpositionLayout
+		forms []int
+	} {
+		{pdf.TwoUp, []int{2, 2, 1}},
+		{pdf.FourUp, []int{4, 1}},
+		// Pages 6-8 of the booklet are blank.
+		{pdf.Booklet, []int{1, 1, 1, 2}}} {
+		imposed := fmt.Sprintf("/tmp/test-impose-%d.pdf", test.layout)
+		out := pdf.Impose(imposed, doc, pdf.ImpositionOptions{Layout: test.layout, Margin: 18, Gutter: 9})
+		out.Close()
+
+		out = pdf.OpenDocument(imposed, os.O_RDWR)
+		for n,expected := range test.forms {
+			page := out.Page(uint(n))
+			if page == nil {
+				t.Errorf(`Layout %d produced fewer than %d sheets`, test.layout, len(test.forms))
+				break
+			}
+			contents,_ := ioutil.ReadAll(page.Reader())
+			if count := bytes.Count(contents, []byte(" Do ")); count != expected {
+				t.Errorf(`Layout %d sheet %d has %d pages; expected %d`, test.layout, n, count, expected)
+			}
+		}
+		if out.Page(uint(len(test.forms))) != nil {
+			t.Errorf(`Layout %d produced more than %d sheets`, test.layout, len(test.forms))
+		}
+		out.Close()
+	}
+}
+
+func TestText(t *testing.T) {
+	filename := "/tmp/test-text.pdf"
+	os.Remove(filename)
+
+	doc := pdf.OpenDocument(filename, os.O_RDWR|os.O_CREATE)
+	page := doc.NewPage()
+	name := page.AddFont(pdf.NewStandardFont(pdf.Helvetica))
+	// The last line is drawn first, and the first line is drawn
+	// in two pieces, the second positioned by a TJ adjustment.
+	fmt.Fprintf(page, "BT /%s 12 Tf 72 660 Td (Third line) Tj ET ", name)
+	fmt.Fprintf(page, "BT /%s 12 Tf 14 TL 72 700 Td [(Hello)-1000(World)] TJ T* (Second) Tj ET", name)
+	doc.Close()
+
+	doc = pdf.OpenDocument(filename, os.O_RDWR)
+	defer doc.Close()
+	text := doc.Page(0).Text()
+	if s := text.String(); s != "Hello World\nSecond\nThird line" {
+		t.Errorf(`Text() returned "%s"`, s)
+	}
+	if len(text.Lines) > 0 {
+		if words := text.Lines[0].Words; len(words) != 2 || words[0].X != 72 || words[0].Y != 700 || words[0].Size != 12 {
+			t.Errorf(`First line has words %+v`, words)
+		}
+	}
+}
+
+func TestImages(t *testing.T) {
+	filename := "/tmp/test-images.pdf"
+	os.Remove(filename)
+
+	newImage := func(width, height int, colorSpace string, data []byte) pdf.Stream {
+		image := pdf.NewStream()
+		image.Add("Type", pdf.NewName("XObject"))
+		image.Add("Subtype", pdf.NewName("Image"))
+		image.Add("Width", pdf.NewIntNumeric(width))
+		image.Add("Height", pdf.NewIntNumeric(height))
+		image.Add("ColorSpace", pdf.NewName(colorSpace))
+		image.Add("BitsPerComponent", pdf.NewIntNumeric(8))
+		image.Write(data)
+		return image
+	}
+
+	// An RGB image compressed with a PNG "Up" predictor
+	var compressed bytes.Buffer
+	w := zlib.NewWriter(&compressed)
+	w.Write([]byte{2, 255, 0, 0, 0, 255, 0, 2, 0, 0, 0, 0, 0, 255})
+	w.Close()
+	predicted := newImage(2, 2, "DeviceRGB", compressed.Bytes())
+	predicted.Add("Filter", pdf.NewName("FlateDecode"))
+	parameters := pdf.NewDictionary()
+	parameters.Add("Predictor", pdf.NewIntNumeric(12))
+	parameters.Add("Colors", pdf.NewIntNumeric(3))
+	parameters.Add("Columns", pdf.NewIntNumeric(2))
+	predicted.Add("DecodeParms", parameters)
+
+	var encoded bytes.Buffer
+	jpeg.Encode(&encoded, image.NewGray(image.Rect(0, 0, 8, 8)), nil)
+	photo := newImage(8, 8, "DeviceGray", encoded.Bytes())
+	photo.Add("Filter", pdf.NewName("DCTDecode"))
+
+	doc := pdf.OpenDocument(filename, os.O_RDWR|os.O_CREATE)
+	page := doc.NewPage()
+	fmt.Fprintf(page, "q 100 0 0 50 72 72 cm /%s Do Q ", page.AddXObject(doc.WriteObject(predicted)))
+	fmt.Fprintf(page, "q 10 0 0 10 0 0 cm /%s Do Q ", page.AddXObject(doc.WriteObject(photo)))
+	fmt.Fprintf(page, "BI /W 2 /H 1 /BPC 8 /CS /G ID \x00\xff EI")
+	doc.Close()
+
+	doc = pdf.OpenDocument(filename, os.O_RDWR)
+	defer doc.Close()
+	images := doc.Page(0).Images()
+	if len(images) != 3 {
+		t.Fatalf(`Images() returned %d images; expected 3`, len(images))
+	}
+	if images[0].Matrix != [6]float64{100, 0, 0, 50, 72, 72} || images[0].Width() != 2 {
+		t.Errorf(`Image drawn with matrix %v and width %d`, images[0].Matrix, images[0].Width())
+	}
+
+	for i,expected := range []string{"png", "jpeg", "png"} {
+		var output bytes.Buffer
+		if format,err := images[i].Export(&output); format != expected || err != nil {
+			t.Errorf(`Image %d exported as "%s" (err=%v); expected "%s"`, i, format, err, expected)
+		}
+	}
+
+	decoded,err := images[0].Image()
+	if err != nil {
+		t.Fatalf(`Image() failed: %v`, err)
+	}
+	for _,test := range []struct {
+		x, y int
+		expected color.RGBA
+	} {
+		{0, 0, color.RGBA{255, 0, 0, 255}},
+		{1, 1, color.RGBA{0, 255, 255, 255}}} {
+		if c := color.RGBAModel.Convert(decoded.At(test.x, test.y)); c != test.expected {
+			t.Errorf(`Pixel (%d,%d) is %v; expected %v`, test.x, test.y, c, test.expected)
+		}
+	}
+	if inline,err := images[2].Image(); err != nil || inline.At(1, 0) != (color.Gray{255}) || !images[2].Inline {
+		t.Errorf(`Inline image decoded as %v (err=%v)`, inline, err)
+	}
+}
+
+// embeddedFont is a Font whose dictionary is supplied by the test.
+type embeddedFont struct {
+	dictionary pdf.Dictionary
+}
+
+func (f embeddedFont) Indirect(file pdf.File) pdf.Indirect {
+	return file.WriteObject(f.dictionary)
+}
+
+func (f embeddedFont) Embedded() bool {
+	return true
+}
+
+func TestFonts(t *testing.T) {
+	filename := "/tmp/test-fonts.pdf"
+	os.Remove(filename)
+
+	program := pdf.NewStream()
+	program.Write([]byte("Not really a TrueType font"))
+	descriptor := pdf.NewDictionary()
+	descriptor.Add("Type", pdf.NewName("FontDescriptor"))
+	descriptor.Add("FontName", pdf.NewName("ABCDEF+Custom"))
+	font := embeddedFont{pdf.NewDictionary()}
+	font.dictionary.Add("Type", pdf.NewName("Font"))
+	font.dictionary.Add("Subtype", pdf.NewName("TrueType"))
+	font.dictionary.Add("BaseFont", pdf.NewName("ABCDEF+Custom"))
+	font.dictionary.Add("Encoding", pdf.NewName("WinAnsiEncoding"))
+
+	doc := pdf.OpenDocument(filename, os.O_RDWR|os.O_CREATE)
+	descriptor.Add("FontFile2", doc.WriteObject(program))
+	font.dictionary.Add("FontDescriptor", doc.WriteObject(descriptor))
+	helvetica := pdf.NewStandardFont(pdf.Helvetica)
+	page := doc.NewPage()
+	fmt.Fprintf(page, "BT /%s 12 Tf (A) Tj ET", page.AddFont(helvetica))
+	page = doc.NewPage()
+	fmt.Fprintf(page, "BT /%s 12 Tf (B) Tj ", page.AddFont(helvetica))
+	fmt.Fprintf(page, "/%s 12 Tf (C) Tj ET", page.AddFont(font))
+	doc.Close()
+
+	doc = pdf.OpenDocument(filename, os.O_RDWR)
+	defer doc.Close()
+	fonts := doc.Fonts()
+	if len(fonts) != 2 {
+		t.Fatalf(`Fonts() returned %+v; expected two fonts`, fonts)
+	}
+	if f := fonts[0]; f.BaseFont != "Helvetica" || f.Embedded || len(f.Pages) != 2 {
+		t.Errorf(`First font is %+v`, f)
+	}
+	if f := fonts[1]; f.BaseFont != "ABCDEF+Custom" || !f.Embedded || f.Encoding != "WinAnsiEncoding" || len(f.Pages) != 1 {
+		t.Errorf(`Second font is %+v`, f)
+	}
+
+	var output bytes.Buffer
+	if format,err := fonts[1].Export(&output); format != "ttf" || err != nil || output.String() != "Not really a TrueType font" {
+		t.Errorf(`Export() returned "%s" (err=%v) and wrote "%s"`, format, err, output.String())
+	}
+	if _,err := fonts[0].Export(&output); err == nil {
+		t.Error(`Export() succeeded for a font that isn't embedded`)
+	}
+}
+
+func TestType3Font(t *testing.T) {
+	filename := "/tmp/test-type3.pdf"
+	os.Remove(filename)
+
+	// Glyph space is 100 units per em.
+	font := pdf.NewType3Font([6]float64{0.01, 0, 0, 0.01, 0, 0}, [4]float64{0, 0, 100, 100})
+	font.AddGlyph('A', pdf.Type3Glyph{Name: "check", Width: 50, Unicode: "\u2713",
+		Procedure: []byte("50 0 0 0 50 100 d1 0 50 m 20 0 l 50 100 l S")})
+	font.AddGlyph('C', pdf.Type3Glyph{Name: "cross", Width: 100, Unicode: "\u2717",
+		Procedure: []byte("100 0 0 0 100 100 d1 0 0 m 100 100 l 0 100 m 100 0 l S")})
+
+	doc := pdf.OpenDocument(filename, os.O_RDWR|os.O_CREATE)
+	page := doc.NewPage()
+	fmt.Fprintf(page, "BT /%s 10 Tf 72 700 Td (AC) Tj ET", page.AddFont(font))
+	doc.Close()
+
+	doc = pdf.OpenDocument(filename, os.O_RDWR)
+	defer doc.Close()
+	text := doc.Page(0).Text()
+	if s := text.String(); s != "\u2713\u2717" {
+		t.Errorf(`Text() returned "%s"`, s)
+	}
+	if len(text.Lines) == 1 && len(text.Lines[0].Words) == 1 {
+		if word := text.Lines[0].Words[0]; math.Abs(word.Size-10) > 1e-3 || math.Abs(word.Width-15) > 1e-3 {
+			t.Errorf(`Type3 text is %+v`, word)
+		}
+	}
+
+	fonts := doc.Fonts()
+	if len(fonts) != 1 || fonts[0].Subtype != "Type3" || !fonts[0].Embedded {
+		t.Fatalf(`Fonts() returned %+v`, fonts)
+	}
+	dictionary := fonts[0].Dictionary
+	if widths := dictionary.GetArray("Widths"); widths == nil || widths.Size() != 3 {
+		t.Errorf(`Type3 font has widths %v`, widths)
+	}
+	if differences := dictionary.GetDictionary("Encoding").GetArray("Differences"); differences == nil || differences.Size() != 4 {
+		t.Errorf(`Type3 font has differences %v`, differences)
+	}
+	procedure := dictionary.GetDictionary("CharProcs").GetStream("cross")
+	if contents,_ := ioutil.ReadAll(procedure.Reader()); !bytes.HasPrefix(contents, []byte("100 0 0 0 100 100 d1")) {
+		t.Errorf(`Glyph procedure is "%s"`, contents)
+	}
+}
+
+// cffIndex() returns a CFF INDEX with one-byte offsets.
+func cffIndex(elements ...[]byte) []byte {
+	index := []byte{0, byte(len(elements))}
+	if len(elements) == 0 {
+		return index
+	}
+	index = append(index, 1, 1)
+	offset := 1
+	for _,element := range elements {
+		offset += len(element)
+		index = append(index, byte(offset))
+	}
+	for _,element := range elements {
+		index = append(index, element...)
+	}
+	return index
+}
+
+// cffNumber() encodes n as a five-byte CFF DICT operand.
+func cffNumber(n int) []byte {
+	b := []byte{29, 0, 0, 0, 0}
+	binary.BigEndian.PutUint32(b[1:], uint32(n))
+	return b
+}
+
+// testOpenTypeFont() returns an OpenType font with CFF outlines for
+// the glyphs .notdef, "A", and "B", 1000 units per em.  The
+// charstrings of "A" and "B" contain markerA and markerB.
+var (
+	markerA = []byte{0xf7, 0x41, 0xf7, 0x41, 21, 14}
+	markerB = []byte{0xf7, 0x42, 0xf7, 0x42, 21, 14} )
+
+func testOpenTypeFont() []byte {
+	return testOpenTypeFontWith(markerB)
+}
+
+// testOpenTypeFontWith() returns testOpenTypeFont() with the passed
+// charstring for "B", which must be shorter than 240 bytes.
+func testOpenTypeFontWith(glyphB []byte) []byte {
+	// The CFF font has a Private DICT with local subroutines.
+	header := []byte{1, 0, 4, 4}
+	names := cffIndex([]byte("TestFont"))
+	stringIndex := cffIndex()
+	globalSubrs := cffIndex()
+	charset := []byte{0, 0, 34, 0, 35}
+	charStrings := cffIndex([]byte{14}, markerA, glyphB)
+	private := append(append(cffNumber(500), 20), append(cffNumber(12), 19)...)
+	subrs := cffIndex([]byte{11})
+	topSize := len(cffIndex(make([]byte, 23)))
+	start := len(header) + len(names) + topSize + len(stringIndex) + len(globalSubrs)
+	var top []byte
+	top = append(append(top, cffNumber(start)...), 15)
+	top = append(append(top, cffNumber(start+len(charset))...), 17)
+	top = append(append(append(top, cffNumber(len(private))...), cffNumber(start+len(charset)+len(charStrings))...), 18)
+	var cff []byte
+	for _,part := range [][]byte{header, names, cffIndex(top), stringIndex, globalSubrs, charset, charStrings, private, subrs} {
+		cff = append(cff, part...)
+	}
+
+	u16 := func(values ...int) []byte {
+		b := make([]byte, 2*len(values))
+		for i,v := range values {
+			binary.BigEndian.PutUint16(b[2*i:], uint16(v))
+		}
+		return b
+	}
+	head := make([]byte, 54)
+	copy(head[18:], u16(1000))
+	copy(head[36:], u16(0, -200, 800, 700))
+	hhea := make([]byte, 36)
+	copy(hhea[4:], u16(800, -200))
+	copy(hhea[34:], u16(3))
+	maxp := u16(0, 0x5000, 3)
+	hmtx := u16(500, 0, 600, 0, 700, 0)
+	cmap := append(u16(0, 1, 3, 1, 0, 12), u16(4, 32, 0, 4, 4, 1, 0, 0x42, 0xffff, 0, 0x41, 0xffff, 1-0x41, 1, 0, 0)...)
+	name := append(u16(0, 1, 18, 1, 0, 0, 6, 8, 0), []byte("TestFont")...)
+
+	tables := map[string][]byte{"CFF ": cff, "cmap": cmap, "head": head, "hhea": hhea, "hmtx": hmtx, "maxp": maxp, "name": name}
+	tags := []string{"CFF ", "cmap", "head", "hhea", "hmtx", "maxp", "name"}
+	font := append([]byte("OTTO"), u16(len(tags), 0, 0, 0)...)
+	offset := 12 + 16*len(tags)
+	var data []byte
+	for _,tag := range tags {
+		font = append(font, tag...)
+		font = append(font, 0, 0, 0, 0)
+		font = append(font, u16(offset>>16, offset, len(tables[tag])>>16, len(tables[tag]))...)
+		data = append(data, tables[tag]...)
+		offset += len(tables[tag])
+	}
+	return append(font, data...)
+}
+
+func TestOpenTypeFont(t *testing.T) {
+	filename := "/tmp/test-opentype.pdf"
+	os.Remove(filename)
+
+	font,err := pdf.LoadOpenTypeFont(testOpenTypeFont())
+	if err != nil {
+		t.Fatalf(`LoadOpenTypeFont() failed: %v`, err)
+	}
+	if name := font.PostScriptName(); name != "TestFont" {
+		t.Errorf(`PostScriptName() returned %q`, name)
+	}
+	if _,err := pdf.LoadOpenTypeFont([]byte("not a font")); err == nil {
+		t.Error(`LoadOpenTypeFont() accepted invalid data`)
+	}
+
+	doc := pdf.OpenDocument(filename, os.O_RDWR|os.O_CREATE)
+	page := doc.NewPage()
+	show,width := font.ShowText("AA", 10)
+	if math.Abs(width-12) > 1e-9 {
+		t.Errorf(`ShowText() returned width %g; expected 12`, width)
+	}
+	fmt.Fprintf(page, "BT /%s 10 Tf 72 700 Td ", page.AddFont(font))
+	pdf.NewContentSerializer(page).Write(show)
+	fmt.Fprintf(page, " ET")
+	doc.Close()
+
+	doc = pdf.OpenDocument(filename, os.O_RDWR)
+	defer doc.Close()
+	if s := doc.Page(0).Text().String(); s != "AA" {
+		t.Errorf(`Text() returned %q`, s)
+	}
+	fonts := doc.Fonts()
+	if len(fonts) != 1 || fonts[0].Subtype != "Type0" || !fonts[0].Embedded ||
+		!strings.HasSuffix(fonts[0].BaseFont, "+TestFont") || len(fonts[0].BaseFont) != 15 {
+		t.Fatalf(`Fonts() returned %+v`, fonts)
+	}
+
+	// The embedded program is a valid font containing only the
+	// glyph that was shown.
+	var program bytes.Buffer
+	if format,err := fonts[0].Export(&program); err != nil || format != "otf" {
+		t.Fatalf(`Export() returned %q, %v`, format, err)
+	}
+	if _,err := pdf.LoadOpenTypeFont(program.Bytes()); err != nil {
+		t.Errorf(`Embedded font program is invalid: %v`, err)
+	}
+	if !bytes.Contains(program.Bytes(), markerA) || bytes.Contains(program.Bytes(), markerB) {
+		t.Error(`Embedded font program isn't subset`)
+	}
+
+	r,_,_ := pdf.OpenFile(filename, os.O_RDONLY)
+	defer r.Close()
+	if r.Version() < pdf.PDF16 {
+		t.Errorf(`Version() returned %v; expected at least 1.6 for FontFile3/OpenType`, r.Version())
+	}
+}
+
+// testTrueTypeFont() returns a TrueType font with the glyphs
+// .notdef, "A", "B", and "C", mapped from those characters by its
+// cmap.  "A" is a composite glyph drawing "C".  The outlines of "B"
+// and "C" contain markerTB and markerTC.
+var (
+	markerTB = []byte("glyph B outline")
+	markerTC = []byte("glyph C outline") )
+
+func testTrueTypeFont() []byte {
+	u16 := func(values ...int) []byte {
+		b := make([]byte, 2*len(values))
+		for i,v := range values {
+			binary.BigEndian.PutUint16(b[2*i:], uint16(v))
+		}
+		return b
+	}
+	simple := func(outline []byte) []byte {
+		glyph := append(u16(1, 0, 0, 500, 700), outline...)
+		return append(glyph, make([]byte, 200)...)
+	}
+	glyphs := [][]byte{nil, append(u16(0xffff, 0, 0, 500, 700), u16(0, 3, 0)...), simple(markerTB), simple(markerTC)}
+	var glyf, loca []byte
+	for _,glyph := range glyphs {
+		loca = append(loca, u16(len(glyf)/2)...)
+		glyf = append(glyf, glyph...)
+	}
+	loca = append(loca, u16(len(glyf)/2)...)
+
+	head := make([]byte, 54)
+	copy(head[18:], u16(1000))
+	hhea := make([]byte, 36)
+	copy(hhea[34:], u16(4))
+	maxp := u16(0, 0x5000, len(glyphs))
+	hmtx := u16(500, 0, 600, 0, 700, 0, 800, 0)
+	cmap := append(u16(0, 1, 3, 1, 0, 12), u16(4, 32, 0, 4, 4, 1, 0, 0x43, 0xffff, 0, 0x41, 0xffff, 1-0x41, 1, 0, 0)...)
+
+	tables := map[string][]byte{"cmap": cmap, "glyf": glyf, "head": head, "hhea": hhea, "hmtx": hmtx, "loca": loca, "maxp": maxp}
+	tags := []string{"cmap", "glyf", "head", "hhea", "hmtx", "loca", "maxp"}
+	font := append([]byte{0, 1, 0, 0}, u16(len(tags), 0, 0, 0)...)
+	offset := 12 + 16*len(tags)
+	var data []byte
+	for _,tag := range tags {
+		font = append(font, tag...)
+		font = append(font, 0, 0, 0, 0)
+		font = append(font, u16(offset>>16, offset, len(tables[tag])>>16, len(tables[tag]))...)
+		data = append(data, tables[tag]...)
+		offset += len(tables[tag])
+	}
+	return append(font, data...)
+}
+
+// embeddedTestFont is a font whose dictionary is written as given.
+type embeddedTestFont struct {
+	dictionary pdf.Dictionary
+	indirect pdf.Indirect
+}
+
+func (font *embeddedTestFont) Indirect(f pdf.File) pdf.Indirect {
+	if font.indirect == nil {
+		font.indirect = f.WriteObject(font.dictionary)
+	}
+	return font.indirect
+}
+
+func (font *embeddedTestFont) Embedded() bool {
+	return true
+}
+
+func TestSubsetFonts(t *testing.T) {
+	input, output := "/tmp/test-subset-fonts-input.pdf", "/tmp/test-subset-fonts.pdf"
+	os.Remove(input)
+	doc := pdf.OpenDocument(input, os.O_RDWR|os.O_CREATE)
+	descriptor := func(key string, data []byte) pdf.Dictionary {
+		stream := pdf.NewStream()
+		if key == "FontFile2" {
+			stream.Add("Length1", pdf.NewIntNumeric(len(data)))
+		} else {
+			stream.Add("Subtype", pdf.NewName("OpenType"))
+		}
+		stream.Write(data)
+		result := pdf.NewDictionary()
+		result.Add("Type", pdf.NewName("FontDescriptor"))
+		result.Add("FontName", pdf.NewName("TestFont"))
+		result.Add("Flags", pdf.NewIntNumeric(32))
+		result.Add(key, doc.WriteObject(stream))
+		return result
+	}
+
+	// An OpenType font used with Identity-H, whose "B" is large
+	// enough that removing it saves space.
+	otf := testOpenTypeFontWith(append(bytes.Repeat([]byte{0x8b}, 200), markerB...))
+	descendant := pdf.NewDictionary()
+	descendant.Add("Type", pdf.NewName("Font"))
+	descendant.Add("Subtype", pdf.NewName("CIDFontType0"))
+	descendant.Add("BaseFont", pdf.NewName("TestFont"))
+	descendant.Add("FontDescriptor", doc.WriteObject(descriptor("FontFile3", otf)))
+	descendants := pdf.NewArray()
+	descendants.Add(doc.WriteObject(descendant))
+	type0 := pdf.NewDictionary()
+	type0.Add("Type", pdf.NewName("Font"))
+	type0.Add("Subtype", pdf.NewName("Type0"))
+	type0.Add("BaseFont", pdf.NewName("TestFont"))
+	type0.Add("Encoding", pdf.NewName("Identity-H"))
+	type0.Add("DescendantFonts", descendants)
+
+	trueType := pdf.NewDictionary()
+	trueType.Add("Type", pdf.NewName("Font"))
+	trueType.Add("Subtype", pdf.NewName("TrueType"))
+	trueType.Add("BaseFont", pdf.NewName("TestTrueType"))
+	trueType.Add("Encoding", pdf.NewName("WinAnsiEncoding"))
+	trueType.Add("FontDescriptor", doc.WriteObject(descriptor("FontFile2", testTrueTypeFont())))
+
+	page := doc.NewPage()
+	fmt.Fprintf(page, "BT /%s 10 Tf 72 700 Td <0001> Tj /%s 10 Tf (A) Tj ET",
+		page.AddFont(&embeddedTestFont{dictionary: type0}), page.AddFont(&embeddedTestFont{dictionary: trueType}))
+	doc.Close()
+
+	if err := pdf.SubsetFonts(input, output); err != nil {
+		t.Fatalf(`SubsetFonts() failed: %v`, err)
+	}
+	doc = pdf.OpenDocument(output, os.O_RDWR)
+	defer doc.Close()
+	fonts := doc.Fonts()
+	if len(fonts) != 2 {
+		t.Fatalf(`Fonts() returned %d fonts; expected 2`, len(fonts))
+	}
+	for _,font := range fonts {
+		if len(font.BaseFont) < 7 || font.BaseFont[6] != '+' {
+			t.Errorf(`Subset font is named %q; expected a subset tag`, font.BaseFont)
+		}
+		var exported bytes.Buffer
+		format,err := font.Export(&exported)
+		if err != nil {
+			t.Fatalf(`Export() failed: %v`, err)
+		}
+		switch format {
+		case "otf":
+			if _,err := pdf.LoadOpenTypeFont(exported.Bytes()); err != nil {
+				t.Errorf(`Subset OpenType program is invalid: %v`, err)
+			}
+			if !bytes.Contains(exported.Bytes(), markerA) || bytes.Contains(exported.Bytes(), markerB) {
+				t.Errorf(`OpenType program wasn't subset to the glyph shown`)
+			}
+		case "ttf":
+			// "A" draws "C", so both are kept.
+			if !bytes.Contains(exported.Bytes(), markerTC) || bytes.Contains(exported.Bytes(), markerTB) {
+				t.Errorf(`TrueType program wasn't subset to the glyphs shown`)
+			}
+			if length,_ := font.Dictionary.GetDictionary("FontDescriptor").GetStream("FontFile2").Dictionary().GetInt("Length1"); length != exported.Len() {
+				t.Errorf(`Subset TrueType program has /Length1 %d; expected %d`, length, exported.Len())
+			}
+		default:
+			t.Errorf(`Export() returned format %q`, format)
+		}
+	}
+}
+
+func TestWOFFFont(t *testing.T) {
+	// Convert the test font to WOFF, compressing each table.
+	otf := testOpenTypeFont()
+	numTables := int(binary.BigEndian.Uint16(otf[4:]))
+	woff := make([]byte, 44+20*numTables)
+	copy(woff, "wOFF")
+	copy(woff[4:], otf[:4])
+	binary.BigEndian.PutUint16(woff[12:], uint16(numTables))
+	for i:=0; i<numTables; i++ {
+		record := otf[12+16*i:]
+		offset := binary.BigEndian.Uint32(record[8:])
+		length := binary.BigEndian.Uint32(record[12:])
+		var compressed bytes.Buffer
+		w := zlib.NewWriter(&compressed)
+		w.Write(otf[offset:offset+length])
+		w.Close()
+		entry := woff[44+20*i:]
+		copy(entry, record[:4])
+		binary.BigEndian.PutUint32(entry[4:], uint32(len(woff)))
+		binary.BigEndian.PutUint32(entry[12:], length)
+		if uint32(compressed.Len()) < length {
+			binary.BigEndian.PutUint32(entry[8:], uint32(compressed.Len()))
+			woff = append(woff, compressed.Bytes()...)
+		} else {
+			binary.BigEndian.PutUint32(entry[8:], length)
+			woff = append(woff, otf[offset:offset+length]...)
+		}
+	}
+	binary.BigEndian.PutUint32(woff[8:], uint32(len(woff)))
+
+	font,err := pdf.LoadOpenTypeFont(woff)
+	if err != nil {
+		t.Fatalf(`LoadOpenTypeFont() failed for WOFF: %v`, err)
+	}
+	if name := font.PostScriptName(); name != "TestFont" {
+		t.Errorf(`PostScriptName() returned %q`, name)
+	}
+	if _,width := font.ShowText("AB", 10); math.Abs(width-13) > 1e-9 {
+		t.Errorf(`ShowText() returned width %g; expected 13`, width)
+	}
+
+	woff2 := append([]byte("wOF2"), woff[4:]...)
+	if _,err := pdf.LoadOpenTypeFont(woff2); err == nil || !strings.Contains(err.Error(), "WOFF2") {
+		t.Errorf(`LoadOpenTypeFont() returned %v for WOFF2`, err)
+	}
+}
+
+func TestPageDecorator(t *testing.T) {
+	filename := "/tmp/test-page-decorator.pdf"
+	os.Remove(filename)
+
+	doc := pdf.OpenDocument(filename, os.O_RDWR|os.O_CREATE)
+	font := pdf.NewStandardFont(pdf.Helvetica)
+	total := doc.PageCountForm(font, 10)
+	doc.SetPageDecorator(func(page *pdf.Page, number uint) {
+		fmt.Fprintf(page, " BT /%s 10 Tf 72 36 Td (Page %d of) Tj ET q 1 0 0 1 140 36 cm /%s Do Q",
+			page.AddFont(font), number, page.AddXObject(total))
+	})
+	for i:=0; i<3; i++ {
+		page := doc.NewPage()
+		fmt.Fprintf(page, "BT /%s 12 Tf 72 700 Td (Body) Tj ET", page.AddFont(font))
+	}
+	doc.Close()
+
+	doc = pdf.OpenDocument(filename, os.O_RDWR)
+	defer doc.Close()
+	for n:=uint(0); n<3; n++ {
+		text := doc.Page(n).Text()
+		if len(text.Lines) != 2 || text.Lines[0].String() != "Body" || text.Lines[1].String() != fmt.Sprintf("Page %d of 3", n+1) {
+			t.Errorf(`Page %d has text %q`, n, text.String())
+		}
+	}
+}
+
+func TestCIDFontText(t *testing.T) {
+	filename := "/tmp/test-cid-text.pdf"
+	os.Remove(filename)
+
+	// CIDs 10 and 11 are mapped to text by a registered CMap for
+	// the font's character collection.
+	ucs2,err := pdf.ParseCMap(strings.NewReader("1 begincodespacerange <0000> <ffff> endcodespacerange " +
+		"1 beginbfrange <000a> <000b> <3042> endbfrange"))
+	if err != nil {
+		t.Fatalf(`ParseCMap() failed: %v`, err)
+	}
+	pdf.RegisterCMap("Test-Kana-UCS2", ucs2)
+
+	encoding := pdf.NewStream()
+	encoding.Add("Type", pdf.NewName("CMap"))
+	encoding.Write([]byte("1 begincodespacerange <8140> <9ffc> endcodespacerange " +
+		"1 begincidrange <8140> <8141> 10 endcidrange"))
+	info := pdf.NewDictionary()
+	info.Add("Registry", pdf.NewTextString("Test"))
+	info.Add("Ordering", pdf.NewTextString("Kana"))
+	info.Add("Supplement", pdf.NewIntNumeric(0))
+	descendant := pdf.NewDictionary()
+	descendant.Add("Type", pdf.NewName("Font"))
+	descendant.Add("Subtype", pdf.NewName("CIDFontType0"))
+	descendant.Add("BaseFont", pdf.NewName("Kana"))
+	descendant.Add("CIDSystemInfo", info)
+	descendant.Add("W", pdf.NewIntArray([]int{10, 11, 500}))
+	font := embeddedFont{pdf.NewDictionary()}
+	font.dictionary.Add("Type", pdf.NewName("Font"))
+	font.dictionary.Add("Subtype", pdf.NewName("Type0"))
+	font.dictionary.Add("BaseFont", pdf.NewName("Kana"))
+
+	doc := pdf.OpenDocument(filename, os.O_RDWR|os.O_CREATE)
+	font.dictionary.Add("Encoding", doc.WriteObject(encoding))
+	descendants := pdf.NewArray()
+	descendants.Add(descendant)
+	font.dictionary.Add("DescendantFonts", descendants)
+	page := doc.NewPage()
+	fmt.Fprintf(page, "BT /%s 10 Tf 72 700 Td <81408141> Tj ET", page.AddFont(font))
+	doc.Close()
+
+	doc = pdf.OpenDocument(filename, os.O_RDWR)
+	defer doc.Close()
+	text := doc.Page(0).Text()
+	if s := text.String(); s != "\u3042\u3043" {
+		t.Errorf(`Text() returned "%s"`, s)
+	}
+	if len(text.Lines) == 1 && len(text.Lines[0].Words) == 1 {
+		if word := text.Lines[0].Words[0]; math.Abs(word.Width-10) > 1e-3 {
+			t.Errorf(`CID font text is %+v`, word)
+		}
+	}
+}
+
+func TestDifferences(t *testing.T) {
+	filename := "/tmp/test-differences.pdf"
+	os.Remove(filename)
+
+	// The differences replace 'A' and 'B' and map 'C' to a name
+	// that has no known text.
+	font := embeddedFont{pdf.NewDictionary()}
+	font.dictionary.Add("Type", pdf.NewName("Font"))
+	font.dictionary.Add("Subtype", pdf.NewName("Type1"))
+	font.dictionary.Add("BaseFont", pdf.NewName("Custom"))
+	encoding,_ := pdf.NewEncodingDictionary("MacRomanEncoding", map[byte]string{'A': "uni2713", 'B': "f_f.alt", 'C': "g123"})
+	font.dictionary.Add("Encoding", encoding)
+
+	doc := pdf.OpenDocument(filename, os.O_RDWR|os.O_CREATE)
+	page := doc.NewPage()
+	fmt.Fprintf(page, "BT /%s 12 Tf 72 700 Td (ABCD\\212) Tj ET", page.AddFont(font))
+	doc.Close()
+
+	doc = pdf.OpenDocument(filename, os.O_RDWR)
+	defer doc.Close()
+	if s := doc.Page(0).Text().String(); s != "\u2713ff\ufffdD\u00e4" {
+		t.Errorf(`Text() returned %q`, s)
+	}
+}
+
+func TestLinearize(t *testing.T) {
+	filename := "/tmp/test-linearize-source.pdf"
+	linearized := "/tmp/test-linearize.pdf"
+	os.Remove(filename)
+
+	doc := pdf.OpenDocument(filename, os.O_RDWR|os.O_CREATE)
+	font := pdf.NewStandardFont(pdf.Helvetica)
+	for i:=0; i<3; i++ {
+		page := doc.NewPage()
+		fmt.Fprintf(page, "BT /%s 24 Tf 72 72 Td (Page %d) Tj ET", page.AddFont(font), i+1)
+	}
+	doc.Close()
+
+	if err := pdf.Linearize(filename, linearized); err != nil {
+		t.Fatalf(`Linearize() failed: %v`, err)
+	}
+	contents,_ := ioutil.ReadFile(linearized)
+	parameters := regexp.MustCompile(`^%PDF-1\.\d\n%[^\n]*\n(\d+) 0 obj\n<< /Linearized 1 /L +(\d+) /H \[ +(\d+) +(\d+) \] /O +(\d+) /E +(\d+) /N +(\d+) /T +(\d+) >>`).FindSubmatch(contents)
+	if parameters == nil {
+		t.Fatal(`Linearized file doesn't start with a linearization parameter dictionary`)
+	}
+	value := func(i int) int {
+		v,_ := strconv.Atoi(string(parameters[i]))
+		return v
+	}
+	if value(2) != len(contents) {
+		t.Errorf(`/L is %d; file length is %d`, value(2), len(contents))
+	}
+	if value(7) != 3 {
+		t.Errorf(`/N is %d; expected 3`, value(7))
+	}
+	hints := contents[value(3):value(3)+value(4)]
+	if !regexp.MustCompile(`^\d+ 0 obj\n<< /Length \d+ /S \d+ >>\nstream\n`).Match(hints) || !bytes.HasSuffix(hints, []byte("endobj\n")) {
+		t.Error(`/H doesn't locate the hint stream`)
+	}
+	firstPage := bytes.Index(contents, []byte(fmt.Sprintf("\n%d 0 obj\n<<", value(5))))
+	if firstPage < 0 || firstPage > value(6) {
+		t.Errorf(`First page object %d is not before the end of the first page section`, value(5))
+	}
+	if !bytes.HasPrefix(contents[value(8):], []byte("0000000000 65535 f")) {
+		t.Error(`/T doesn't locate the first entry of the main xref`)
+	}
+
+	out := pdf.OpenDocument(linearized, os.O_RDWR)
+	for n:=uint(0); n<3; n++ {
+		text,_ := ioutil.ReadAll(out.Page(n).Reader())
+		if expected := fmt.Sprintf("Page %d", n+1); !bytes.Contains(text, []byte(expected)) {
+			t.Errorf(`Linearized page %d has contents "%s"; expected "%s"`, n, text, expected)
+		}
+	}
+	out.Close()
+
+	os.Remove(filename)
+	doc = pdf.OpenDocument(filename, os.O_RDWR|os.O_CREATE, pdf.WithLinearization())
+	doc.NewPage()
+	doc.Close()
+	contents,_ = ioutil.ReadFile(filename)
+	if !bytes.Contains(contents[:1024], []byte("/Linearized 1")) {
+		t.Error(`WithLinearization() didn't linearize the file`)
+	}
+
+	// A file with an object that can't be read is left as it is,
+	// including when it is linearized in place as it is closed.
+	damaged := "/tmp/test-linearize-damaged.pdf"
+	writeDamagedDocument(t, damaged)
+	before,_ := ioutil.ReadFile(damaged)
+	if err := pdf.Linearize(damaged, damaged); !errors.Is(err, &pdf.SyntaxError{}) {
+		t.Errorf(`Linearize() of a damaged file returned %v rather than a SyntaxError`, err)
+	}
+	if after,_ := ioutil.ReadFile(damaged); !bytes.Equal(after, before) {
+		t.Error(`Linearize() of a damaged file changed it`)
+	}
+	doc = pdf.OpenDocument(damaged, os.O_RDWR, pdf.WithLinearization())
+	doc.SetTitle("Updated")
+	doc.Close()
+	if after,_ := ioutil.ReadFile(damaged); !bytes.HasPrefix(after, before) || bytes.Contains(after, []byte("/Linearized")) {
+		t.Error(`WithLinearization() rewrote a damaged file`)
+	}
+}
+
+func TestRepair(t *testing.T) {
+	filename := "/tmp/test-repair.pdf"
+	os.Remove(filename)
+
+	doc := pdf.OpenDocument(filename, os.O_RDWR|os.O_CREATE)
+	font := pdf.NewStandardFont(pdf.Helvetica)
+	for i:=0; i<2; i++ {
+		page := doc.NewPage()
+		fmt.Fprintf(page, "BT /%s 24 Tf 72 72 Td (Page %d) Tj ET", page.AddFont(font), i+1)
+	}
+	doc.Close()
+	original,_ := ioutil.ReadFile(filename)
+
+	check := func(description string, contents []byte, trailerFound bool, duplicates int) {
+		ioutil.WriteFile(filename, contents, 0666)
+		doc := pdf.OpenDocument(filename, os.O_RDWR)
+		defer doc.Close()
+		report := doc.RepairReport()
+		if report == nil {
+			t.Errorf(`%s: file wasn't repaired`, description)
+			return
+		}
+		if report.TrailerFound != trailerFound || len(report.Duplicates) != duplicates || report.Objects == 0 {
+			t.Errorf(`%s: unexpected repair report %+v`, description, report)
+		}
+		for n:=uint(0); n<2; n++ {
+			text,_ := ioutil.ReadAll(doc.Page(n).Reader())
+			if expected := fmt.Sprintf("Page %d", n+1); !bytes.Contains(text, []byte(expected)) {
+				t.Errorf(`%s: page %d has contents "%s"; expected "%s"`, description, n, text, expected)
+			}
+		}
+	}
+
+	// startxref points to the wrong place
+	broken := regexp.MustCompile(`startxref\s+\d+`).ReplaceAll(original, []byte("startxref\n17"))
+	check("Bad startxref", broken, true, 0)
+	ioutil.WriteFile(filename, broken, 0666)
+	if _,_,err := pdf.OpenFile(filename, os.O_RDONLY, pdf.WithParsingMode(pdf.StrictParsing)); !errors.Is(err, &pdf.XrefError{}) {
+		t.Errorf(`Damaged file opened with StrictParsing returned %v rather than an XrefError`, err)
+	}
+
+	// No xref or trailer, and the first object is defined twice
+	truncated := append([]byte{}, original[:bytes.LastIndex(original, []byte("\nxref"))+1]...)
+	first := regexp.MustCompile(`(?s)\n\d+ \d+ obj\n.*?endobj\n`).Find(original)
+	truncated = append(truncated, first...)
+	check("Missing xref", truncated, false, 1)
+
+	ioutil.WriteFile(filename, original, 0666)
+	doc = pdf.OpenDocument(filename, os.O_RDWR)
+	if doc.RepairReport() != nil {
+		t.Error(`Intact file reported as repaired`)
+	}
+	doc.Close()
+}
+
+func TestMerge(t *testing.T) {
+	var sources []*pdf.Document
+	for i,title := range []string{"First", "Second"} {
+		filename := fmt.Sprintf("/tmp/test-merge-%d.pdf", i)
+		os.Remove(filename)
+		doc := pdf.OpenDocument(filename, os.O_RDWR|os.O_CREATE)
+		doc.SetTitle(title)
+		for j:=0; j<2; j++ {
+			fmt.Fprintf(doc.NewPage(), "%% %s page %d", title, j+1)
+		}
+		doc.Attach(pdf.Attachment{Name: "common.txt", Contents: []byte(title)})
+		doc.Attach(pdf.Attachment{Name: title + ".txt", Contents: []byte(title)})
+		doc.Close()
+		sources = append(sources, pdf.OpenDocument(filename, os.O_RDWR))
+	}
+
+	filename := "/tmp/test-merge.pdf"
+	pdf.Merge(filename, sources...).Close()
+	for _,source := range sources {
+		source.Close()
+	}
+
+	doc := pdf.OpenDocument(filename, os.O_RDWR)
+	defer doc.Close()
+	for n,expected := range []string{"First page 1", "First page 2", "Second page 1", "Second page 2"} {
+		contents,_ := ioutil.ReadAll(doc.Page(uint(n)).Reader())
+		if !bytes.Contains(contents, []byte(expected)) {
+			t.Errorf(`Merged page %d has contents "%s"; expected "%s"`, n, contents, expected)
+		}
+	}
+
+	attachments := doc.Attachments()
+	if len(attachments) != 3 || attachments[2].Name != "common.txt" || string(attachments[2].Contents) != "First" {
+		t.Errorf(`Merged document has attachments %v`, attachments)
+	}
+
+	contents,_ := ioutil.ReadFile(filename)
+	for _,title := range []string{"(First)", "(Second)", "/Outlines"} {
+		if !bytes.Contains(contents, []byte(title)) {
+			t.Errorf(`Merged document has no outline entry %s`, title)
+		}
+	}
+}
+
+// writeDamagedDocument() writes to filename a one-page document
+// whose page has an annotation that can't be parsed.
+func writeDamagedDocument(t *testing.T, filename string) {
+	os.Remove(filename)
+	doc := pdf.OpenDocument(filename, os.O_RDWR|os.O_CREATE)
+	annotation := pdf.NewDictionary()
+	annotation.Add("Type", pdf.NewName("Annot"))
+	annotation.Add("Subtype", pdf.NewName("Text"))
+	annotation.Add("Marker", pdf.NewIntNumeric(1))
+	page := doc.NewPage()
+	page.AddAnnotation(annotation)
+	fmt.Fprint(page, "% damaged")
+	doc.Close()
+	contents,_ := ioutil.ReadFile(filename)
+	if !bytes.Contains(contents, []byte("/Marker 1")) {
+		t.Fatalf(`Document to be damaged doesn't contain "/Marker 1"`)
+	}
+	ioutil.WriteFile(filename, bytes.Replace(contents, []byte("/Marker 1"), []byte("/Marker ]"), 1), 0666)
+}
+
+func TestCopyUnreadableObjects(t *testing.T) {
+	input := "/tmp/test-copy-damaged.pdf"
+	writeDamagedDocument(t, input)
+
+	source := pdf.OpenDocument(input, os.O_RDONLY)
+	merged := pdf.OpenDocument("/tmp/test-copy-damaged-merge.pdf", os.O_RDWR|os.O_CREATE|os.O_TRUNC)
+	merged.Append(source)
+	if err := merged.Close(); !errors.Is(err, &pdf.SyntaxError{}) {
+		t.Errorf(`Close() after Append() of a damaged document returned %v rather than a SyntaxError`, err)
+	}
+	extracted := source.ExtractPages("/tmp/test-copy-damaged-extract.pdf", []uint{0})
+	if err := extracted.Close(); !errors.Is(err, &pdf.SyntaxError{}) {
+		t.Errorf(`Close() of pages extracted from a damaged document returned %v rather than a SyntaxError`, err)
+	}
+	source.Discard()
+}
+
+func TestMergeFormFields(t *testing.T) {
+	var sources []*pdf.Document
+	for i:=0; i<2; i++ {
+		filename := fmt.Sprintf("/tmp/test-merge-fields-%d.pdf", i)
+		os.Remove(filename)
+		doc := pdf.OpenDocument(filename, os.O_RDWR|os.O_CREATE)
+		// The field is also the widget annotation of the page.
+		widget := pdf.NewDictionary()
+		widget.Add("Subtype", pdf.NewName("Widget"))
+		widget.Add("FT", pdf.NewName("Tx"))
+		widget.Add("T", pdf.NewTextString("name"))
+		widget.Add("Rect", pdf.NewRectangle(72, 72, 216, 96))
+		field := doc.NewPage().AddAnnotation(widget)
+		fields := pdf.NewArray()
+		fields.Add(field)
+		form := pdf.NewDictionary()
+		form.Add("Fields", fields)
+		catalog,_ := doc.Catalog()
+		catalog.AcroForm = form
+		doc.SetCatalog(catalog)
+		doc.Close()
+		sources = append(sources, pdf.OpenDocument(filename, os.O_RDWR))
+	}
+
+	filename := "/tmp/test-merge-fields.pdf"
+	pdf.Merge(filename, sources...).Close()
+	for _,source := range sources {
+		source.Close()
+	}
+
+	contents,_ := ioutil.ReadFile(filename)
+	for _,name := range []string{"(name)", "(name_2)"} {
+		if n := bytes.Count(contents, []byte(name)); n != 1 {
+			t.Errorf(`Merged document contains %d fields named %s; expected 1`, n, name)
+		}
+	}
+
+	doc := pdf.OpenDocument(filename, os.O_RDWR)
+	defer doc.Close()
+	catalog,_ := doc.Catalog()
+	fields := catalog.AcroForm.Dereference().(pdf.ProtectedDictionary).GetArray("Fields")
+	if fields == nil || fields.Size() != 2 {
+		t.Fatalf(`Merged document has fields %v; expected 2`, fields)
+	}
+	for i,expected := range []string{"name", "name_2"} {
+		field := fields.At(i).(pdf.ProtectedIndirect)
+		if name,_ := field.Dereference().(pdf.ProtectedDictionary).GetString("T"); string(name) != expected {
+			t.Errorf(`Merged field %d is named "%s"; expected "%s"`, i, name, expected)
+		}
+		// The page's widget is the renamed field.
+		annotation := doc.Page(uint(i)).GetArray("Annots").At(0).Dereference().(pdf.ProtectedDictionary)
+		if name,_ := annotation.GetString("T"); string(name) != expected {
+			t.Errorf(`Widget on page %d is named "%s"; expected "%s"`, i, name, expected)
+		}
+	}
+}
+
+func TestForEachPage(t *testing.T) {
+	filename := "/tmp/test-for-each-page.pdf"
+	os.Remove(filename)
+
+	doc := pdf.OpenDocument(filename, os.O_RDWR|os.O_CREATE)
+	for i:=0; i<20; i++ {
+		page := doc.NewPage()
+		name := page.AddFont(pdf.NewStandardFont(pdf.Helvetica))
+		fmt.Fprintf(page, "BT /%s 12 Tf 72 700 Td (Page %d) Tj ET", name, i)
+	}
+	doc.Close()
+
+	doc = pdf.OpenDocument(filename, os.O_RDWR)
+	defer doc.Close()
+	var mutex sync.Mutex
+	text := make(map[uint]string)
+	err := doc.ForEachPage(context.Background(), 4, func(n uint, page *pdf.ExistingPage) error {
+		s := page.Text().String()
+		mutex.Lock()
+		text[n] = s
+		mutex.Unlock()
+		return nil
+	})
+	if err != nil {
+		t.Errorf(`ForEachPage() failed: %v`, err)
+	}
+	for n:=uint(0); n<20; n++ {
+		if expected := fmt.Sprintf("Page %d", n); text[n] != expected {
+			t.Errorf(`Page %d has text "%s"; expected "%s"`, n, text[n], expected)
+		}
+	}
+
+	// The first error stops processing.
+	stop := errors.New("stop")
+	count := 0
+	err = doc.ForEachPage(context.Background(), 2, func(n uint, page *pdf.ExistingPage) error {
+		mutex.Lock()
+		count += 1
+		mutex.Unlock()
+		if n == 3 {
+			return stop
+		}
+		return nil
+	})
+	if err != stop || count >= 20 {
+		t.Errorf(`ForEachPage() returned %v after %d pages; expected "stop"`, err, count)
+	}
+
+	ctx,cancel := context.WithCancel(context.Background())
+	cancel()
+	if err = doc.ForEachPage(ctx, 2, func(uint, *pdf.ExistingPage) error { return nil }); err != context.Canceled {
+		t.Errorf(`ForEachPage() with cancelled context returned %v`, err)
+	}
+}
+
+func TestContext(t *testing.T) {
+	filename := "/tmp/test-context.pdf"
+	os.Remove(filename)
+	doc := pdf.OpenDocument(filename, os.O_RDWR|os.O_CREATE)
+	for i:=0; i<3; i++ {
+		fmt.Fprintf(doc.NewPage(), "%% page %d", i+1)
+	}
+	doc.Close()
+
+	ctx,cancel := context.WithCancel(context.Background())
+	cancel()
+	if _,_,err := pdf.OpenFile(filename, os.O_RDONLY, pdf.WithContext(ctx)); err != context.Canceled {
+		t.Errorf(`OpenFile() with cancelled context returned %v`, err)
+	}
+	if err := pdf.Linearize(filename, "/tmp/test-context-linearized.pdf", pdf.WithContext(ctx)); err != context.Canceled {
+		t.Errorf(`Linearize() with cancelled context returned %v`, err)
+	}
+
+	// Work stops once the context is cancelled.
+	ctx,cancel = context.WithCancel(context.Background())
+	f := pdf.NewMemoryFile(pdf.WithContext(ctx))
+	o := f.WriteObject(pdf.NewIntNumeric(1)).ObjectNumber(f)
+	cancel()
+	if _,err := f.Object(o); err != context.Canceled {
+		t.Errorf(`Object() after cancellation returned %v`, err)
+	}
+	f.WriteObject(pdf.NewIntNumeric(2))
+	f.Close()
+	if bytes.Contains(f.Bytes(), []byte("trailer")) {
+		t.Errorf(`Cancelled file has a trailer`)
+	}
+
+	source := pdf.OpenDocument(filename, os.O_RDWR)
+	ctx,cancel = context.WithCancel(context.Background())
+	merged := "/tmp/test-context-merged.pdf"
+	out := pdf.OpenDocument(merged, os.O_RDWR|os.O_CREATE|os.O_TRUNC, pdf.WithContext(ctx))
+	cancel()
+	out.Append(source)
+	if err := out.Close(); err != context.Canceled {
+		t.Errorf(`Close() of cancelled document returned %v`, err)
+	}
+	source.Close()
+}
+
+type recordingObserver struct {
+	pdf.NoProgress
+	mutex sync.Mutex
+	objects int
+	pages []uint
+	bytes int64
+}
+
+func (r *recordingObserver) OnObjectWritten(o pdf.ObjectNumber, size int64) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if size > 0 {
+		r.objects += 1
+	}
+}
+
+func (r *recordingObserver) OnPageProcessed(done, total uint) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.pages = append(r.pages, done, total)
+}
+
+func (r *recordingObserver) OnBytesRead(done, total int64) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.bytes = done
+}
+
+func TestProgress(t *testing.T) {
+	filename := "/tmp/test-progress.pdf"
+	os.Remove(filename)
+
+	writing := new(recordingObserver)
+	doc := pdf.OpenDocument(filename, os.O_RDWR|os.O_CREATE, pdf.WithProgress(writing))
+	for i:=0; i<3; i++ {
+		fmt.Fprintf(doc.NewPage(), "%% page %d", i+1)
+	}
+	doc.Close()
+	if writing.objects < 3 {
+		t.Errorf(`OnObjectWritten() called %d times`, writing.objects)
+	}
+
+	reading := new(recordingObserver)
+	doc = pdf.OpenDocument(filename, os.O_RDWR, pdf.WithProgress(reading))
+	extracting := new(recordingObserver)
+	doc.ExtractPages("/tmp/test-progress-extract.pdf", []uint{2, 0}, pdf.WithProgress(extracting)).Close()
+	doc.Close()
+	if reading.bytes == 0 {
+		t.Errorf(`OnBytesRead() not called`)
+	}
+	if expected := []uint{1, 2, 2, 2}; fmt.Sprint(extracting.pages) != fmt.Sprint(expected) {
+		t.Errorf(`OnPageProcessed() reported %v; expected %v`, extracting.pages, expected)
+	}
+
+	linearizing := new(recordingObserver)
+	pdf.Linearize(filename, "/tmp/test-progress-linearized.pdf", pdf.WithProgress(linearizing))
+	if expected := []uint{1, 3, 2, 3, 3, 3}; fmt.Sprint(linearizing.pages) != fmt.Sprint(expected) {
+		t.Errorf(`OnPageProcessed() reported %v during linearization; expected %v`, linearizing.pages, expected)
+	}
+}
+
+// stampingObserver adds /Stamp to each page, vetoes dictionaries
+// with /Secret, and records the objects written.
+type stampingObserver struct {
+	mutex sync.Mutex
+	before, after []pdf.ObjectNumber
+}
+
+func (s *stampingObserver) BeforeWrite(o pdf.ObjectNumber, object pdf.Object) pdf.Object {
+	s.mutex.Lock()
+	s.before = append(s.before, o)
+	s.mutex.Unlock()
+	if d,ok := object.(pdf.Dictionary); ok {
+		if d.Get("Secret") != nil {
+			return nil
+		}
+		if d.CheckNameValue("Type", "Page") {
+			d = d.Clone().(pdf.Dictionary)
+			d.Add("Stamp", pdf.NewName("Observed"))
+			return d
+		}
+	}
+	return object
+}
+
+func (s *stampingObserver) AfterWrite(o pdf.ObjectNumber, object pdf.Object) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.after = append(s.after, o)
+}
+
+func TestWriteObserver(t *testing.T) {
+	filename := "/tmp/test-write-observer.pdf"
+	os.Remove(filename)
+	observer := new(stampingObserver)
+	counter := new(stampingObserver)
+	doc := pdf.OpenDocument(filename, os.O_RDWR|os.O_CREATE, pdf.WithWriteObserver(observer), pdf.WithWriteObserver(counter))
+	doc.NewPage()
+	doc.NewPage()
+	secret := pdf.NewDictionary()
+	secret.Add("Secret", pdf.NewTextString("password"))
+	doc.WriteObject(secret)
+	doc.Close()
+
+	if len(observer.before) < 4 || fmt.Sprint(observer.before) != fmt.Sprint(observer.after) ||
+		fmt.Sprint(counter.before) != fmt.Sprint(observer.before) {
+		t.Errorf(`Observers saw %v before and %v after; the second saw %v`, observer.before, observer.after, counter.before)
+	}
+	contents,_ := ioutil.ReadFile(filename)
+	if n := bytes.Count(contents, []byte("/Stamp /Observed")); n != 2 {
+		t.Errorf(`Observer stamped %d pages; expected 2`, n)
+	}
+	if bytes.Contains(contents, []byte("password")) {
+		t.Errorf(`Vetoed object was written`)
+	}
+	doc = pdf.OpenDocument(filename, os.O_RDONLY)
+	defer doc.Discard()
+	if n := doc.PageCount(); n != 2 {
+		t.Errorf(`Observed document has %d pages; expected 2`, n)
+	}
+}
+
+func TestCompact(t *testing.T) {
+	filename := "/tmp/test-compact.pdf"
+	os.Remove(filename)
+	doc := pdf.OpenDocument(filename, os.O_RDWR|os.O_CREATE)
+	for i:=0; i<3; i++ {
+		fmt.Fprintf(doc.NewPage(), "%% page %d", i+1)
+	}
+	doc.Close()
+
+	// Each update rewrites the page tree and catalog, leaving the
+	// old copies behind.
+	for i:=0; i<5; i++ {
+		doc = pdf.OpenDocument(filename, os.O_RDWR)
+		doc.SetTitle(fmt.Sprintf("Revision %d", i))
+		doc.Close()
+	}
+	before,_ := ioutil.ReadFile(filename)
+
+	doc = pdf.OpenDocument(filename, os.O_RDWR)
+	if err := doc.Compact(); err != nil {
+		t.Fatalf(`Compact() failed: %v`, err)
+	}
+	after,_ := ioutil.ReadFile(filename)
+	if len(after) >= len(before) {
+		t.Errorf(`Compact() didn't shrink the file: %d bytes before and %d after`, len(before), len(after))
+	}
+	if n := bytes.Count(after, []byte("startxref")); n != 1 {
+		t.Errorf(`Compacted file has %d xref sections`, n)
+	}
+
+	f,_,_ := pdf.OpenFile(filename, os.O_RDONLY)
+	size,_ := f.Trailer().GetInt("Size")
+	if size != bytes.Count(after, []byte(" obj\n")) + 1 {
+		t.Errorf(`Compacted file has /Size %d`, size)
+	}
+	f.Close()
+
+	doc = pdf.OpenDocument(filename, os.O_RDWR)
+	if doc.Title != "Revision 4" {
+		t.Errorf(`Compacted document has title "%s"`, doc.Title)
+	}
+	for n:=uint(0); n<3; n++ {
+		contents,_ := ioutil.ReadAll(doc.Page(n).Reader())
+		if expected := fmt.Sprintf("%% page %d", n+1); string(contents) != expected {
+			t.Errorf(`Page %d of compacted document contains "%s"`, n, contents)
+		}
+	}
+	doc.Close()
+
+	m := pdf.NewMemoryFile()
+	doc = pdf.NewDocumentFromFile(m)
+	doc.NewPage()
+	if err := doc.Compact(); err != nil || !bytes.HasPrefix(m.Bytes(), []byte("%PDF-")) {
+		t.Errorf(`Compact() of a MemoryFile failed: %v`, err)
+	}
+
+	// A file with an object that can't be read is left as it is.
+	damaged := "/tmp/test-compact-damaged.pdf"
+	writeDamagedDocument(t, damaged)
+	before,_ = ioutil.ReadFile(damaged)
+	if err := pdf.Compact(damaged, damaged); !errors.Is(err, &pdf.SyntaxError{}) {
+		t.Errorf(`Compact() of a damaged file returned %v rather than a SyntaxError`, err)
+	}
+	if after,_ := ioutil.ReadFile(damaged); !bytes.Equal(after, before) {
+		t.Error(`Compact() of a damaged file changed it`)
+	}
+}
+
+func TestRenumberObjects(t *testing.T) {
+	input := "/tmp/test-renumber-input.pdf"
+	output := "/tmp/test-renumber.pdf"
+	os.Remove(input)
+	doc := pdf.OpenDocument(input, os.O_RDWR|os.O_CREATE)
+	for i:=0; i<3; i++ {
+		fmt.Fprintf(doc.NewPage(), "%% page %d", i+1)
+	}
+	doc.SetTitle("Renumbered")
+	doc.Close()
+
+	f,_,_ := pdf.OpenFile(input, os.O_RDONLY)
+	info := f.Trailer().Get("Info").(pdf.ProtectedIndirect).ObjectNumber(f)
+	f.Close()
+
+	if err := pdf.RenumberObjects(input, output, map[pdf.ObjectNumber]uint32{info: 20}); err != nil {
+		t.Fatalf(`RenumberObjects() failed: %v`, err)
+	}
+	f,_,_ = pdf.OpenFile(output, os.O_RDONLY)
+	if root := f.Trailer().Get("Root").(pdf.ProtectedIndirect).ObjectNumber(f); root != pdf.NewObjectNumber(1, 0) {
+		t.Errorf(`Renumbered catalog is %v`, root)
+	}
+	if info := f.Trailer().Get("Info").(pdf.ProtectedIndirect).ObjectNumber(f); info != pdf.NewObjectNumber(20, 0) {
+		t.Errorf(`Renumbered info dictionary is %v`, info)
+	}
+	for n:=uint32(2); n<=4; n++ {
+		page,_ := f.Object(pdf.NewObjectNumber(n, 0))
+		if d,ok := page.(pdf.ProtectedDictionary); !ok || !d.CheckNameValue("Type", "Page", f) {
+			t.Errorf(`Object %d of renumbered file is %s rather than a page`, n, toString(page))
+		}
+	}
+	if size,_ := f.Trailer().GetInt("Size"); size != 21 {
+		t.Errorf(`Renumbered file has /Size %d`, size)
+	}
+	f.Close()
+
+	doc = pdf.OpenDocument(output, os.O_RDWR)
+	if doc.Title != "Renumbered" {
+		t.Errorf(`Renumbered document has title "%s"`, doc.Title)
+	}
+	for n:=uint(0); n<3; n++ {
+		contents,_ := ioutil.ReadAll(doc.Page(n).Reader())
+		if expected := fmt.Sprintf("%% page %d", n+1); string(contents) != expected {
+			t.Errorf(`Page %d of renumbered document contains "%s"`, n, contents)
+		}
+	}
+	doc.Close()
+
+	if err := pdf.RenumberObjects(input, output, map[pdf.ObjectNumber]uint32{info: 0}); err == nil {
+		t.Error(`RenumberObjects() accepted object number 0`)
+	}
+	for _,n := range []uint32{8388608, 1<<28, 1<<32-1} {
+		if err := pdf.RenumberObjects(input, output, map[pdf.ObjectNumber]uint32{info: n}); err == nil {
+			t.Errorf(`RenumberObjects() accepted object number %d`, n)
+		}
+	}
+	if err := pdf.RenumberObjects(input, output, map[pdf.ObjectNumber]uint32{info: 100},
+		pdf.WithLimits(pdf.Limits{MaxObjects: 50})); err == nil {
+		t.Error(`RenumberObjects() accepted an object number beyond Limits.MaxObjects`)
+	}
+
+	damaged := "/tmp/test-renumber-damaged.pdf"
+	writeDamagedDocument(t, damaged)
+	before,_ := ioutil.ReadFile(damaged)
+	if err := pdf.RenumberObjects(damaged, damaged, nil); !errors.Is(err, &pdf.SyntaxError{}) {
+		t.Errorf(`RenumberObjects() of a damaged file returned %v rather than a SyntaxError`, err)
+	}
+	if after,_ := ioutil.ReadFile(damaged); !bytes.Equal(after, before) {
+		t.Error(`RenumberObjects() of a damaged file changed it`)
+	}
+}
+
+func TestDeduplication(t *testing.T) {
+	var sources []*pdf.Document
+	for i:=0; i<2; i++ {
+		filename := fmt.Sprintf("/tmp/test-deduplication-%d.pdf", i)
+		os.Remove(filename)
+		doc := pdf.OpenDocument(filename, os.O_RDWR|os.O_CREATE)
+		page := doc.NewPage()
+		name := page.AddFont(pdf.NewStandardFont(pdf.Helvetica))
+		fmt.Fprintf(page, "BT /%s 12 Tf 72 700 Td (Document %d) Tj ET", name, i)
+		doc.Close()
+		sources = append(sources, pdf.OpenDocument(filename, os.O_RDWR))
+	}
+
+	filename := "/tmp/test-deduplication.pdf"
+	pdf.Merge(filename, sources...).Close()
+	for _,source := range sources {
+		source.Close()
+	}
+	contents,_ := ioutil.ReadFile(filename)
+	if n := bytes.Count(contents, []byte("/Helvetica")); n != 1 {
+		t.Errorf(`Merged document contains %d copies of the font`, n)
+	}
+
+	doc := pdf.OpenDocument(filename, os.O_RDWR)
+	defer doc.Close()
+	for n:=uint(0); n<2; n++ {
+		if text,expected := doc.Page(n).Text().String(), fmt.Sprintf("Document %d", n); text != expected {
+			t.Errorf(`Page %d has text "%s"; expected "%s"`, n, text, expected)
+		}
+	}
+}
+
+// deterministicDocument() generates a small document with a structure
+// tree, page labels, and a font in memory.
+func deterministicDocument(options ...pdf.FileOption) []byte {
+	f := pdf.NewMemoryFile(options...)
+	doc := pdf.NewDocumentFromFile(f)
+	doc.SetTitle("Deterministic")
+	tree := doc.StructureTree()
+	root := tree.NewElement(pdf.TagDocument)
+	for i:=0; i<5; i++ {
+		element := root.NewElement(pdf.TagP)
+		page := doc.NewPage()
+		name := page.AddFont(pdf.NewStandardFont(pdf.Helvetica))
+		page.BeginMarkedContent(element)
+		fmt.Fprintf(page, "BT /%s 12 Tf 72 700 Td (Page %d) Tj ET", name, i)
+		page.EndMarkedContent()
+	}
+	doc.SetPageLabel(0, pdf.PageLabel{Style: pdf.LowerRoman})
+	doc.Close()
+	return f.Bytes()
+}
+
+func TestDeterministicOutput(t *testing.T) {
+	timestamp := time.Date(2020, 2, 29, 12, 0, 0, 0, time.UTC)
+	first := deterministicDocument(pdf.WithDeterministicOutput(timestamp))
+	second := deterministicDocument(pdf.WithDeterministicOutput(timestamp))
+	if !bytes.Equal(first, second) {
+		t.Errorf(`Deterministic output differs between runs`)
+	}
+	if !bytes.Contains(first, []byte(pdf.FormatDate(timestamp))) {
+		t.Errorf(`Creation date is not the timestamp`)
+	}
+	if !bytes.Contains(first, []byte("<</Count 5 /Kids")) {
+		t.Errorf(`Dictionary keys are not sorted`)
+	}
+
+	// The identifier depends on the timestamp and contents.
+	r,_ := pdf.NewFileFromReader(bytes.NewReader(first))
+	id,_ := r.ID()
+	r.Close()
+	later := deterministicDocument(pdf.WithDeterministicOutput(timestamp.Add(time.Second)))
+	r,_ = pdf.NewFileFromReader(bytes.NewReader(later))
+	if laterID,_ := r.ID(); bytes.Equal(id, laterID) {
+		t.Errorf(`Identifier doesn't depend on the timestamp`)
+	}
+	r.Close()
+
+	if bytes.Equal(deterministicDocument(), deterministicDocument()) {
+		t.Errorf(`Output without WithDeterministicOutput() should have unique identifiers`)
+	}
+}
+
+func TestViewerPreferences(t *testing.T) {
+	filename := "/tmp/test-viewer-preferences.pdf"
+	os.Remove(filename)
+
+	doc := pdf.OpenDocument(filename, os.O_RDWR|os.O_CREATE)
+	if doc.PageLayout() != pdf.SinglePageLayout || doc.PageMode() != pdf.UseNoneMode {
+		t.Errorf(`New document has layout %q and mode %q`, doc.PageLayout(), doc.PageMode())
+	}
+	if p := doc.ViewerPreferences(); p != (pdf.ViewerPreferences{PrintScaling: pdf.AppDefaultScaling}) {
+		t.Errorf(`New document has viewer preferences %+v`, p)
+	}
+	preferences := pdf.ViewerPreferences{
+		HideToolbar: true,
+		FitWindow: true,
+		Duplex: pdf.DuplexFlipLongEdge,
+		PrintScaling: pdf.NoScaling }
+	doc.SetPageLayout(pdf.TwoPageLeftLayout)
+	doc.SetPageMode(pdf.FullScreenMode)
+	doc.SetViewerPreferences(preferences)
+	doc.NewPage()
+	doc.Close()
+
+	doc = pdf.OpenDocument(filename, os.O_RDWR)
+	if doc.PageLayout() != pdf.TwoPageLeftLayout || doc.PageMode() != pdf.FullScreenMode {
+		t.Errorf(`Document has layout %q and mode %q`, doc.PageLayout(), doc.PageMode())
+	}
+	if p := doc.ViewerPreferences(); p != preferences {
+		t.Errorf(`Document has viewer preferences %+v; expected %+v`, p, preferences)
+	}
+	doc.SetViewerPreferences(pdf.ViewerPreferences{})
+	doc.Close()
+
+	r,_,_ := pdf.OpenFile(filename, os.O_RDONLY)
+	defer r.Close()
+	if r.Catalog().Get("ViewerPreferences") != nil {
+		t.Error(`Default viewer preferences were written`)
+	}
+	if r.Version() != pdf.PDF17 {
+		t.Errorf(`Version() returned %v; expected 1.7 for /Duplex`, r.Version())
+	}
+}
+
+func TestActions(t *testing.T) {
+	for _,invalid := range []pdf.Action{
+		pdf.GoToAction{},
+		pdf.GoToAction{pdf.Destination{Page: pdf.NewIndirect(), Fit: pdf.FitRectangle}},
+		pdf.GoToRemoteAction{File: "other.pdf", Destination: pdf.Destination{Page: pdf.NewIndirect()}},
+		pdf.NamedAction{},
+		pdf.SubmitFormAction{URL: "https://example.com/", Flags: pdf.SubmitGetMethod},
+		pdf.ActionSequence{pdf.NamedAction{pdf.NextPageAction}, pdf.LaunchAction{}} } {
+		if _,err := pdf.NewActionDictionary(invalid); err == nil {
+			t.Errorf(`NewActionDictionary(%#v) did not fail`, invalid)
+		}
+	}
+
+	submit,err := pdf.NewActionDictionary(pdf.SubmitFormAction{
+		URL: "https://example.com/",
+		Fields: []string{"name"},
+		Flags: pdf.SubmitHTML|pdf.SubmitGetMethod})
+	if err != nil {
+		t.Fatalf(`NewActionDictionary() failed: %v`, err)
+	}
+	checkObject(t, "SubmitForm action", submit, nil,
+		"<</Type /Action /S /SubmitForm /F <</FS /URL /F (https://example.com/)>> /Fields [(name)] /Flags 12>>")
+
+	filename := "/tmp/test-actions.pdf"
+	os.Remove(filename)
+	doc := pdf.OpenDocument(filename, os.O_RDWR|os.O_CREATE)
+	first := doc.NewPage()
+	second := doc.NewPage()
+	_,err = second.AddLink(0, 0, 100, 20, pdf.ActionSequence{
+		pdf.GoToAction{pdf.Destination{Page: first.Reference(), Fit: pdf.FitWidth, Top: 792}},
+		pdf.GoToRemoteAction{File: "other.pdf", Destination: pdf.Destination{PageNumber: 2}},
+		pdf.JavaScriptAction{"app.alert('Hello');"} })
+	if err != nil {
+		t.Fatalf(`AddLink() failed: %v`, err)
+	}
+	doc.Close()
+
+	r,_,_ := pdf.OpenFile(filename, os.O_RDONLY)
+	defer r.Close()
+	kids := r.Catalog().GetDictionary("Pages").GetArray("Kids")
+	page := kids.At(1).Dereference().(pdf.ProtectedDictionary)
+	link := page.GetArray("Annots").At(0).Dereference().(pdf.ProtectedDictionary)
+	action := link.GetDictionary("A")
+	if action == nil || !action.CheckNameValue("S", "GoTo") {
+		t.Fatalf(`Link has action %v`, action)
+	}
+	dest := action.GetArray("D")
+	if dest == nil || toString(dest.At(0), r) != toString(kids.At(0), r) {
+		t.Errorf(`GoTo action has destination %v`, dest)
+	}
+	next := action.GetArray("Next")
+	if next == nil || next.Size() != 2 {
+		t.Fatalf(`GoTo action has /Next %v`, next)
+	}
+	if s,_ := next.At(1).(pdf.ProtectedDictionary).GetString("JS"); string(s) != "app.alert('Hello');" {
+		t.Errorf(`JavaScript action has script %q`, s)
+	}
+}
+
+func TestOpenAction(t *testing.T) {
+	filename := "/tmp/test-open-action.pdf"
+	os.Remove(filename)
+
+	doc := pdf.OpenDocument(filename, os.O_RDWR|os.O_CREATE)
+	doc.OpenAtPage(1, pdf.FitWidth)
+	for i:=0; i<3; i++ {
+		doc.NewPage().SetCropBox(0, 0, 612, 700)
+	}
+	doc.Close()
+
+	r,_,_ := pdf.OpenFile(filename, os.O_RDONLY)
+	kids := r.Catalog().GetDictionary("Pages").GetArray("Kids")
+	dest := r.Catalog().GetArray("OpenAction")
+	if dest == nil || toString(dest, r) != "[" + toString(kids.At(1), r) + " /FitH 700]" {
+		t.Errorf(`Catalog has /OpenAction %v`, r.Catalog().Get("OpenAction"))
+	}
+	r.Close()
+
+	doc = pdf.OpenDocument(filename, os.O_RDWR)
+	if err := doc.SetOpenAction(pdf.NamedAction{}); err == nil {
+		t.Error(`SetOpenAction() accepted an invalid action`)
+	}
+	if err := doc.SetOpenAction(pdf.NamedAction{pdf.LastPageAction}); err != nil {
+		t.Errorf(`SetOpenAction() failed: %v`, err)
+	}
+	doc.Close()
+
+	r,_,_ = pdf.OpenFile(filename, os.O_RDONLY)
+	defer r.Close()
+	if action := r.Catalog().GetDictionary("OpenAction"); action == nil || !action.CheckNameValue("N", "LastPage") {
+		t.Errorf(`Catalog has /OpenAction %v`, r.Catalog().Get("OpenAction"))
+	}
+}
+
+func TestThreads(t *testing.T) {
+	filename := "/tmp/test-threads.pdf"
+	os.Remove(filename)
+
+	doc := pdf.OpenDocument(filename, os.O_RDWR|os.O_CREATE)
+	news := doc.NewThread("News")
+	sports := doc.NewThread("Sports")
+	doc.NewThread("Empty")
+	first := doc.NewPage()
+	first.AddBead(news, 36, 400, 300, 756)
+	first.AddBead(news, 312, 400, 576, 756)
+	first.AddBead(sports, 36, 36, 576, 390)
+	doc.NewPage().AddBead(news, 576, 36, 36, 756)
+	doc.Close()
+
+	doc = pdf.OpenDocument(filename, os.O_RDWR)
+	defer doc.Close()
+	threads := doc.Threads()
+	if len(threads) != 2 {
+		t.Fatalf(`Threads() returned %d threads; expected 2`, len(threads))
+	}
+	expected := []pdf.ExistingBead{
+		{0, [4]float64{36, 400, 300, 756}},
+		{0, [4]float64{312, 400, 576, 756}},
+		{1, [4]float64{36, 36, 576, 756}} }
+	if threads[0].Title != "News" || len(threads[0].Beads) != len(expected) {
+		t.Fatalf(`First thread is %+v`, threads[0])
+	}
+	for i,bead := range threads[0].Beads {
+		if bead != expected[i] {
+			t.Errorf(`Bead %d is %+v; expected %+v`, i, bead, expected[i])
+		}
+	}
+	if threads[1].Title != "Sports" || len(threads[1].Beads) != 1 {
+		t.Errorf(`Second thread is %+v`, threads[1])
+	}
+	if beads := doc.Page(0).GetArray("B"); beads == nil || beads.Size() != 3 {
+		t.Errorf(`First page has /B %v`, beads)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	filename := "/tmp/test-validate.pdf"
+	os.Remove(filename)
+	doc := pdf.OpenDocument(filename, os.O_RDWR|os.O_CREATE)
+	first := doc.NewPage()
+	fmt.Fprintf(first, "BT /%s 24 Tf 72 72 Td (Hello) Tj ET", first.AddFont(pdf.NewStandardFont(pdf.Helvetica)))
+	second := doc.NewPage()
+	second.AddLink(0, 0, 100, 20, pdf.GoToAction{pdf.Destination{Page: first.Reference()}})
+	doc.Close()
+
+	report,err := pdf.Validate(filename, pdf.SyntacticProfile)
+	if err != nil {
+		t.Fatalf(`Validate() failed: %v`, err)
+	}
+	if !report.Valid() || len(report.Problems) != 1 || len(report.Category(pdf.FontCategory)) != 1 {
+		t.Errorf(`Validate() reported:\n%v\nexpected only an unembedded font warning`, report)
+	}
+	report,_ = pdf.Validate(filename, pdf.PDFA2BProfile)
+	if report.Valid() || len(report.Category(pdf.ConformanceCategory)) != 2 {
+		t.Errorf(`PDF/A validation reported:\n%v\nexpected a font, output intent, and metadata`, report)
+	}
+	report,_ = pdf.Validate(filename, pdf.PDFX4Profile)
+	if !strings.Contains(report.String(), "Page 2 has neither a /TrimBox nor an /ArtBox") {
+		t.Errorf(`PDF/X validation reported:\n%v`, report)
+	}
+	if _,err := pdf.Validate("/tmp/no-such-file.pdf", pdf.SyntacticProfile); err == nil {
+		t.Error(`Validate() succeeded for a missing file`)
+	}
+
+	// Damage that keeps the offsets of objects intact.
+	original,_ := ioutil.ReadFile(filename)
+	damaged := bytes.Replace(original, []byte("/Count 2"), []byte("/Count 3"), 1)
+	damaged = regexp.MustCompile(`/Length \d`).ReplaceAllFunc(damaged, func(b []byte) []byte {
+		return []byte(fmt.Sprintf("/Length %c", '1' + (b[len(b)-1]-'0'+1)%9))
+	})
+	ioutil.WriteFile(filename, damaged, 0666)
+	report,_ = pdf.Validate(filename, pdf.SyntacticProfile)
+	if len(report.Category(pdf.PageTreeCategory)) != 1 || len(report.Category(pdf.SyntaxCategory)) == 0 {
+		t.Errorf(`Validate() reported:\n%v\nexpected a wrong /Count and stream /Length`, report)
+	}
+	broken := regexp.MustCompile(`startxref\s+\d+`).ReplaceAll(original, []byte("startxref\n17"))
+	ioutil.WriteFile(filename, broken, 0666)
+	report,_ = pdf.Validate(filename, pdf.SyntacticProfile)
+	if len(report.Category(pdf.XrefCategory)) == 0 {
+		t.Errorf(`Validate() reported:\n%v\nexpected a rebuilt xref`, report)
+	}
+
+	// A link to an undefined named destination and a PDF/A
+	// document that conforms.
+	os.Remove(filename)
+	doc = pdf.OpenDocument(filename, os.O_RDWR|os.O_CREATE)
+	doc.SetConformance(pdf.PDFA2B)
+	profile := make([]byte, 128)
+	copy(profile[16:], "RGB ")
+	copy(profile[36:], "acsp")
+	doc.AddOutputIntent(pdf.OutputIntent{Subtype: pdf.OutputIntentPDFA, OutputConditionIdentifier: "sRGB", Profile: profile})
+	doc.NewPage().AddLink(0, 0, 100, 20, pdf.GoToAction{pdf.Destination{Name: "nowhere"}})
+	if err := doc.Close(); err != nil {
+		t.Fatalf(`Close() failed: %v`, err)
+	}
+	report,_ = pdf.Validate(filename, pdf.PDFA2BProfile)
+	if len(report.Problems) != 1 || len(report.Category(pdf.DestinationCategory)) != 1 {
+		t.Errorf(`Validate() reported:\n%v\nexpected only an undefined destination`, report)
+	}
+}
+
+func TestSummaryAndDump(t *testing.T) {
+	filename := "/tmp/test-summary.pdf"
+	os.Remove(filename)
+	doc := pdf.OpenDocument(filename, os.O_RDWR|os.O_CREATE,
+		pdf.WithEncryption("", "owner", pdf.Permissions{Print: true}))
+	doc.Title = "Summary"
+	doc.Producer = "PDFiG"
+	page := doc.NewPage()
+	fmt.Fprintf(page, "BT /%s 12 Tf (A) Tj ET", page.AddFont(pdf.NewStandardFont(pdf.Helvetica)))
+	page = doc.NewPage()
+	page.SetMediaBox(0, 0, 842, 595)
+	page.SetRotate(90)
+	doc.Close()
+
+	doc = pdf.OpenDocument(filename, os.O_RDONLY)
+	summary := doc.Summary()
+	if len(summary.Pages) != 2 || summary.Pages[1].MediaBox != [4]float64{0, 0, 842, 595} || summary.Pages[1].Rotate != 90 {
+		t.Errorf(`Summary() returned pages %+v`, summary.Pages)
+	}
+	if !summary.Encrypted || summary.Permissions.Copy || !summary.Permissions.Print || len(summary.Fonts) != 1 {
+		t.Errorf(`Summary() returned %+v`, summary)
+	}
+	s := summary.String()
+	for _,expected := range []string{"Title:          Summary\n", "Producer:       PDFiG\n", "Pages:          2\n",
+		"Page    2 size: 842 x 595 pts (rotated 90 degrees)\n", "Encrypted:      yes (Standard V", "print:yes copy:no",
+		"Font:           Helvetica (Type1, not embedded)\n"} {
+		if !strings.Contains(s, expected) {
+			t.Errorf(`Summary() formatted as:\n%s\nexpected %q`, s, expected)
+		}
+	}
+
+	var b bytes.Buffer
+	if err := doc.Dump(&b); err != nil {
+		t.Fatalf(`Dump() failed: %v`, err)
+	}
+	dump := b.String()
+	for _,pattern := range []string{`(?m)^trailer <<$`, `(?m)^  /Root \d+ 0 R => <<$`, `(?m)^    /Type /Catalog$`,
+		`/Parent \d+ 0 R \(see above\)`, `/Contents \d+ 0 R => stream <<`, `>> \(\d+ bytes\)`, `/MediaBox \[0 0 842 595\]`, `/ID \[<[0-9a-f]{32}> <[0-9a-f]{32}>\]`} {
+		if !regexp.MustCompile(pattern).MatchString(dump) {
+			t.Errorf(`Dump() wrote:\n%s\nexpected a match for %s`, dump, pattern)
+		}
+	}
+}
+
+func TestDiff(t *testing.T) {
+	timestamp := time.Date(2020, 2, 29, 12, 0, 0, 0, time.UTC)
+	generate := func(filename string, changed bool) pdf.File {
+		os.Remove(filename)
+		doc := pdf.OpenDocument(filename, os.O_RDWR|os.O_CREATE, pdf.WithDeterministicOutput(timestamp))
+		doc.SetTitle("Diff")
+		font := pdf.NewStandardFont(pdf.Helvetica)
+		if changed {
+			// An unreachable object renumbers everything else.
+			doc.WriteObject(pdf.NewDictionary())
+		}
+		for i:=0; i<2; i++ {
+			page := doc.NewPage()
+			if changed && i == 1 {
+				font = pdf.NewStandardFont(pdf.TimesRoman)
+				page.SetMediaBox(0, 0, 612, 1008)
+			}
+			fmt.Fprintf(page, "BT /%s 12 Tf (Page %d) Tj ET", page.AddFont(font), i)
+		}
+		doc.Close()
+		f,_,err := pdf.OpenFile(filename, os.O_RDONLY)
+		if err != nil {
+			t.Fatalf(`OpenFile() failed: %v`, err)
+		}
+		return f
+	}
+	a := generate("/tmp/test-diff-a.pdf", false)
+	if report := pdf.Diff(a, generate("/tmp/test-diff-b.pdf", false)); !report.Equal() {
+		t.Errorf(`Diff() of identical documents reported:\n%v`, report)
+	}
+
+	report := pdf.Diff(a, generate("/tmp/test-diff-b.pdf", true))
+	expected := []string{
+		"changed Page 2 → MediaBox[3]: 792 → 1008",
+		"changed Page 2 → Resources → Font → F1 → BaseFont: /Helvetica → /Times-Roman"}
+	if len(report.Differences) != len(expected) {
+		t.Fatalf(`Diff() reported:\n%v\nexpected:\n%s`, report, strings.Join(expected, "\n"))
+	}
+	for i,d := range report.Differences {
+		if d.String() != expected[i] {
+			t.Errorf(`Diff() reported %q; expected %q`, d, expected[i])
+		}
+	}
+}
+
+func TestUncompress(t *testing.T) {
+	input, output := "/tmp/test-uncompress-input.pdf", "/tmp/test-uncompress.pdf"
+	os.Remove(input)
+	var encoded bytes.Buffer
+	jpeg.Encode(&encoded, image.NewGray(image.Rect(0, 0, 8, 8)), nil)
+	photo := pdf.NewStream()
+	photo.Add("Type", pdf.NewName("XObject"))
+	photo.Add("Subtype", pdf.NewName("Image"))
+	photo.Add("Width", pdf.NewIntNumeric(8))
+	photo.Add("Height", pdf.NewIntNumeric(8))
+	photo.Add("ColorSpace", pdf.NewName("DeviceGray"))
+	photo.Add("BitsPerComponent", pdf.NewIntNumeric(8))
+	photo.Add("Filter", pdf.NewName("DCTDecode"))
+	photo.Write(encoded.Bytes())
+
+	doc := pdf.OpenDocument(input, os.O_RDWR|os.O_CREATE)
+	page := doc.NewPage()
+	contents := fmt.Sprintf("BT /%s 12 Tf (Inspect me) Tj ET q 8 0 0 8 0 0 cm /%s Do Q",
+		page.AddFont(pdf.NewStandardFont(pdf.Helvetica)), page.AddXObject(doc.WriteObject(photo)))
+	fmt.Fprint(page, contents)
+	doc.Close()
+
+	if err := pdf.Uncompress(input, output); err != nil {
+		t.Fatalf(`Uncompress() failed: %v`, err)
+	}
+	result,_ := ioutil.ReadFile(output)
+	if !bytes.Contains(result, []byte(contents)) || bytes.Contains(result, []byte("FlateDecode")) {
+		t.Errorf(`Uncompress() didn't decompress the page contents:\n%s`, result)
+	}
+	if !bytes.Contains(result, []byte("/Filter /DCTDecode")) || !bytes.Contains(result, encoded.Bytes()) {
+		t.Errorf(`Uncompress() changed the image`)
+	}
+	if !regexp.MustCompile(`(?m)^<<\n  /Type /Catalog\n  /Pages \d+ 0 R\n>>$`).Match(result) {
+		t.Errorf(`Uncompress() didn't write one key per line:\n%s`, result)
+	}
+
+	doc = pdf.OpenDocument(output, os.O_RDWR)
+	defer doc.Close()
+	if read,_ := ioutil.ReadAll(doc.Page(0).Reader()); string(read) != contents {
+		t.Errorf(`Uncompressed page contents are %q; expected %q`, read, contents)
+	}
+}
+
+func TestOptimizeImages(t *testing.T) {
+	input, output := "/tmp/test-optimize-input.pdf", "/tmp/test-optimize.pdf"
+	os.Remove(input)
+	newImage := func(width, height, components int) pdf.Stream {
+		image := pdf.NewStream()
+		image.Add("Type", pdf.NewName("XObject"))
+		image.Add("Subtype", pdf.NewName("Image"))
+		image.Add("Width", pdf.NewIntNumeric(width))
+		image.Add("Height", pdf.NewIntNumeric(height))
+		if components == 1 {
+			image.Add("ColorSpace", pdf.NewName("DeviceGray"))
+		} else {
+			image.Add("ColorSpace", pdf.NewName("DeviceRGB"))
+		}
+		image.Add("BitsPerComponent", pdf.NewIntNumeric(8))
+		// Noise, which doesn't compress.
+		samples := make([]byte, width*height*components)
+		seed := uint32(1)
+		for i := range samples {
+			seed = seed*1664525 + 1013904223
+			samples[i] = byte(seed >> 24)
+		}
+		image.Write(samples)
+		return image
+	}
+
+	doc := pdf.OpenDocument(input, os.O_RDWR|os.O_CREATE)
+	page := doc.NewPage()
+	// The large image is drawn at 1 and 2 inches, or 600 and 300
+	// pixels per inch, and the small one at 16.
+	large := page.AddXObject(doc.WriteObject(newImage(600, 600, 3)))
+	small := page.AddXObject(doc.WriteObject(newImage(16, 16, 1)))
+	fmt.Fprintf(page, "q 72 0 0 72 0 0 cm /%s Do Q q 144 0 0 144 100 100 cm /%s Do Q q 72 0 0 72 300 300 cm /%s Do Q", large, large, small)
+	doc.Close()
+
+	if err := pdf.OptimizeImages(input, output, pdf.ImageOptimization{}); err != nil {
+		t.Fatalf(`OptimizeImages() failed: %v`, err)
+	}
+	before,_ := os.Stat(input)
+	after,_ := os.Stat(output)
+	if after.Size() >= before.Size() {
+		t.Errorf(`OptimizeImages() grew the file from %d to %d bytes`, before.Size(), after.Size())
+	}
+
+	doc = pdf.OpenDocument(output, os.O_RDWR)
+	defer doc.Close()
+	images := doc.Page(0).Images()
+	if len(images) != 3 {
+		t.Fatalf(`Optimized page draws %d images; expected 3`, len(images))
+	}
+	for i,expected := range []int{300, 300, 16} {
+		if width,height := images[i].Width(), images[i].Height(); width != expected || height != expected {
+			t.Errorf(`Optimized image %d is %dx%d; expected %dx%d`, i, width, height, expected, expected)
+		}
+	}
+	if filter,_ := images[0].Stream.Dictionary().GetName("Filter"); filter != "DCTDecode" {
+		t.Errorf(`Downsampled image has /Filter %q; expected "DCTDecode"`, filter)
+	}
+	if _,err := images[0].Image(); err != nil {
+		t.Errorf(`Downsampled image can't be decoded: %v`, err)
+	}
+	if filter,_ := images[2].Stream.Dictionary().GetName("Filter"); filter == "DCTDecode" {
+		t.Errorf(`OptimizeImages() recompressed an image below the target resolution`)
+	}
+}
+
+func TestEncryptAndDecrypt(t *testing.T) {
+	plain, encrypted, decrypted := "/tmp/test-encrypt-input.pdf", "/tmp/test-encrypt.pdf", "/tmp/test-decrypt.pdf"
+	os.Remove(plain)
+	doc := pdf.OpenDocument(plain, os.O_RDWR|os.O_CREATE)
+	for i:=0; i<2; i++ {
+		page := doc.NewPage()
+		fmt.Fprintf(page, "BT /%s 12 Tf (Page %d) Tj ET", page.AddFont(pdf.NewStandardFont(pdf.Helvetica)), i+1)
+	}
+	if doc.PageCount() != 2 {
+		t.Errorf(`PageCount() returned %d; expected 2`, doc.PageCount())
+	}
+	doc.Close()
+
+	if err := pdf.Encrypt(plain, encrypted, "user", "owner", pdf.Permissions{Print: true}); err != nil {
+		t.Fatalf(`Encrypt() failed: %v`, err)
+	}
+	if err := pdf.Encrypt(encrypted, decrypted, "user", "owner", pdf.AllPermissions(),
+		pdf.WithPasswordCallback(func(int) (string, bool) { return "owner", true })); err == nil {
+		t.Errorf(`Encrypt() succeeded with an encrypted input`)
+	}
+	if _,_,err := pdf.OpenFile(encrypted, os.O_RDONLY); err == nil {
+		t.Errorf(`Encrypted file was opened without a password`)
+	}
+
+	password := func(p string) pdf.FileOption {
+		return pdf.WithPasswordCallback(func(attempt int) (string, bool) {
+			return p, attempt == 1
+		})
+	}
+	if err := pdf.Decrypt(encrypted, decrypted, password("owner")); err != nil {
+		t.Fatalf(`Decrypt() failed: %v`, err)
+	}
+	f,_,err := pdf.OpenFile(decrypted, os.O_RDONLY)
+	if err != nil {
+		t.Fatalf(`Decrypted file couldn't be opened: %v`, err)
+	}
+	doc = pdf.NewDocumentFromFile(f)
+	defer doc.Discard()
+	if doc.PageCount() != 2 {
+		t.Errorf(`Decrypted document has %d pages; expected 2`, doc.PageCount())
+	}
+	if text := doc.Page(1).Text().String(); !strings.Contains(text, "Page 2") {
+		t.Errorf(`Decrypted page text is %q`, text)
+	}
+}
+
+func TestExtractRevision(t *testing.T) {
+	filename := "/tmp/test-revisions.pdf"
+	os.Remove(filename)
+	doc := pdf.OpenDocument(filename, os.O_RDWR|os.O_CREATE)
+	doc.NewPage()
+	doc.Close()
+	original,_ := ioutil.ReadFile(filename)
+	doc = pdf.OpenDocument(filename, os.O_RDWR)
+	doc.NewPage()
+	doc.Close()
+
+	doc = pdf.OpenDocument(filename, os.O_RDONLY)
+	defer doc.Discard()
+	if n := len(doc.Revisions()); n != 2 {
+		t.Fatalf(`Document updated once has %d revisions; expected 2`, n)
+	}
+	extracted := "/tmp/test-revision-0.pdf"
+	if err := doc.ExtractRevision(extracted, 0); err != nil {
+		t.Fatalf(`ExtractRevision() failed: %v`, err)
+	}
+	if contents,_ := ioutil.ReadFile(extracted); !bytes.Equal(contents, original) {
+		t.Errorf(`ExtractRevision() didn't reproduce the original file`)
+	}
+	old := pdf.OpenDocument(extracted, os.O_RDONLY)
+	if n := old.PageCount(); n != 1 {
+		t.Errorf(`The first revision has %d pages; expected 1`, n)
+	}
+	old.Discard()
+	if err := doc.ExtractRevision(extracted, 2); err == nil {
+		t.Errorf(`ExtractRevision() of a revision that doesn't exist succeeded`)
+	}
+}
+
+func TestRoundTripFidelity(t *testing.T) {
+	filename := "/tmp/test-fidelity.pdf"
+	os.Remove(filename)
+	doc := pdf.OpenDocument(filename, os.O_RDWR|os.O_CREATE)
+	doc.NewPage()
+	doc.NewPage()
+	doc.SetTitle("Before")
+	doc.Close()
+	original,_ := ioutil.ReadFile(filename)
+
+	doc = pdf.OpenDocument(filename, os.O_RDWR, pdf.WithRoundTripFidelity())
+	if n := doc.PageCount(); n != 2 {
+		t.Errorf(`Document has %d pages; expected 2`, n)
+	}
+	doc.Close()
+	if contents,_ := ioutil.ReadFile(filename); !bytes.Equal(contents, original) {
+		t.Errorf(`Opening and closing a document changed it`)
+	}
+
+	doc = pdf.OpenDocument(filename, os.O_RDWR, pdf.WithRoundTripFidelity())
+	doc.SetTitle("After")
+	doc.Close()
+	contents,_ := ioutil.ReadFile(filename)
+	if !bytes.HasPrefix(contents, original) {
+		t.Fatalf(`Update didn't preserve the original bytes`)
+	}
+	update := contents[len(original):]
+	if n := bytes.Count(update, []byte(" obj")); n != 1 {
+		t.Errorf(`Update changing only the title wrote %d objects; expected 1:\n%s`, n, update)
+	}
+	if !bytes.Contains(update, []byte("(After)")) {
+		t.Errorf(`Update doesn't contain the new title:\n%s`, update)
+	}
+
+	doc = pdf.OpenDocument(filename, os.O_RDONLY)
+	defer doc.Discard()
+	if title := doc.Title; title != "After" {
+		t.Errorf(`Title is %q; expected "After"`, title)
+	}
+}
+
+func TestJSON(t *testing.T) {
+	input,output := "/tmp/test-json-input.pdf", "/tmp/test-json.pdf"
+	os.Remove(input)
+	doc := pdf.OpenDocument(input, os.O_RDWR|os.O_CREATE)
+	doc.SetTitle("JSON")
+	page := doc.NewPage()
+	fmt.Fprintf(page, "BT /%s 12 Tf (Exported) Tj ET", page.AddFont(pdf.NewStandardFont(pdf.Helvetica)))
+	doc.NewPage()
+	doc.Close()
+
+	var exported bytes.Buffer
+	if err := pdf.ExportJSON(input, &exported); err != nil {
+		t.Fatalf(`ExportJSON() failed: %v`, err)
+	}
+	if !strings.Contains(exported.String(), `"/Title": "u:JSON"`) {
+		t.Errorf(`ExportJSON() didn't include the title:\n%s`, exported.String())
+	}
+	if err := pdf.ImportJSON(bytes.NewReader(exported.Bytes()), output); err != nil {
+		t.Fatalf(`ImportJSON() failed: %v`, err)
+	}
+
+	a,_,_ := pdf.OpenFile(input, os.O_RDONLY)
+	b,_,_ := pdf.OpenFile(output, os.O_RDONLY)
+	defer a.Close()
+	defer b.Close()
+	if report := pdf.Diff(a, b); !report.Equal() {
+		t.Errorf(`Imported file differs from the original:\n%s`, report)
+	}
+
+	for _,data := range []string{`{}`, `{"trailer": {"/Root": "1 0 R"}, "objects": {"1 0 R": "text"}}`, `{"objects": {"one": 1}}`} {
+		if err := pdf.ImportJSON(strings.NewReader(data), output); err == nil {
+			t.Errorf(`ImportJSON(%s) succeeded`, data)
+		}
+	}
+}
+
+func TestCatalog(t *testing.T) {
+	filename := "/tmp/test-catalog.pdf"
+	os.Remove(filename)
+	doc := pdf.OpenDocument(filename, os.O_RDWR|os.O_CREATE)
+	doc.NewPage()
+	doc.NewPage()
+	catalog,err := doc.Catalog()
+	if err != nil || catalog.Pages == nil {
+		t.Fatalf(`Catalog() returned %+v, %v`, catalog, err)
+	}
+	labels := pdf.NewDictionary()
+	labels.Add("Nums", pdf.NewArray())
+	catalog.PageLabels = labels
+	catalog.OpenAction = pdf.NewArray()
+	catalog.Version = "1.7"
+	if err := doc.SetCatalog(catalog); err != nil {
+		t.Fatalf(`SetCatalog() failed: %v`, err)
+	}
+	doc.Close()
+
+	f,_,err := pdf.OpenFile(filename, os.O_RDONLY)
+	if err != nil {
+		t.Fatalf(`OpenFile() failed: %v`, err)
+	}
+	defer f.Close()
+	catalog,err = pdf.ReadCatalog(f)
+	if err != nil {
+		t.Fatalf(`ReadCatalog() failed: %v`, err)
+	}
+	if catalog.Version != "1.7" || catalog.PageLabels == nil || catalog.OpenAction == nil || catalog.Outlines != nil {
+		t.Errorf(`ReadCatalog() returned %+v`, catalog)
+	}
+	if pages,ok := catalog.Pages.Dereference().(pdf.ProtectedDictionary); !ok || !pages.CheckNameValue("Type", "Pages") {
+		t.Errorf(`Catalog /Pages doesn't refer to the page tree`)
+	}
+
+	catalog.Pages = nil
+	if _,err := catalog.Dictionary(); err == nil {
+		t.Errorf(`Dictionary() of a catalog without /Pages succeeded`)
+	}
+	catalog = &pdf.Catalog{Pages: f.Catalog().GetIndirect("Pages"), Version: "one"}
+	if _,err := catalog.Dictionary(); err == nil {
+		t.Errorf(`Dictionary() of a catalog with an invalid /Version succeeded`)
+	}
+}
+
+func TestAudit(t *testing.T) {
+	filename := "/tmp/test-audit.pdf"
+	os.Remove(filename)
+	var encoded bytes.Buffer
+	jpeg.Encode(&encoded, image.NewGray(image.Rect(0, 0, 200, 200)), nil)
+	photo := pdf.NewStream()
+	photo.Add("Type", pdf.NewName("XObject"))
+	photo.Add("Subtype", pdf.NewName("Image"))
+	photo.Add("Width", pdf.NewIntNumeric(200))
+	photo.Add("Height", pdf.NewIntNumeric(200))
+	photo.Add("ColorSpace", pdf.NewName("DeviceGray"))
+	photo.Add("BitsPerComponent", pdf.NewIntNumeric(8))
+	photo.Add("Filter", pdf.NewName("DCTDecode"))
+	photo.Write(encoded.Bytes())
+
+	doc := pdf.OpenDocument(filename, os.O_RDWR|os.O_CREATE)
+	page := doc.NewPage()
+	fmt.Fprintf(page, "BT /%s 12 Tf (Audited) Tj ET /%s Do",
+		page.AddFont(pdf.NewStandardFont(pdf.Helvetica)), page.AddXObject(doc.WriteObject(photo)))
+	doc.WriteObject(pdf.NewTextString("Nothing refers to this"))
+	doc.Close()
+
+	doc = pdf.OpenDocument(filename, os.O_RDONLY)
+	defer doc.Discard()
+	report,err := doc.Audit()
+	if err != nil {
+		t.Fatalf(`Audit() failed: %v`, err)
+	}
+	if info,_ := os.Stat(filename); report.FileSize != info.Size() {
+		t.Errorf(`Audit() reported a file size of %d; expected %d`, report.FileSize, info.Size())
+	}
+	images := report.Categories[pdf.ImageObjects]
+	if images.Objects != 1 || images.Bytes < int64(encoded.Len()) || images.Bytes > int64(encoded.Len()) + 300 {
+		t.Errorf(`Audit() reported %+v for images of %d bytes`, images, encoded.Len())
+	}
+	for _,c := range []pdf.AuditCategory{pdf.FontObjects, pdf.ContentObjects, pdf.UnusedObjects} {
+		if report.Categories[c].Objects != 1 {
+			t.Errorf(`Audit() reported %+v for %v; expected 1 object`, report.Categories[c], c)
+		}
+	}
+	if report.Types["XObject/Image"] != images || report.Types["Catalog"].Objects != 1 {
+		t.Errorf(`Audit() reported types %v`, report.Types)
+	}
+	if len(report.Largest) == 0 || report.Largest[0].Category != pdf.ImageObjects {
+		t.Errorf(`Audit() reported %v as the largest objects`, report.Largest)
+	}
+	if overhead := report.Overhead(); overhead <= 0 || overhead > report.FileSize/2 {
+		t.Errorf(`Audit() reported an overhead of %d bytes`, overhead)
+	}
+}
@@ -0,0 +1,275 @@
+package pdf
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings" )
+
+// PageSummary describes the size and orientation of a page.
+type PageSummary struct {
+	// MediaBox and CropBox are given as llx, lly, urx, ury.
+	MediaBox, CropBox [4]float64
+	// Rotate is the page's rotation in degrees (0, 90, 180, or
+	// 270).
+	Rotate int
+}
+
+// DocumentSummary describes a document in the manner of pdfinfo.
+type DocumentSummary struct {
+	Version Version
+	Info DocumentInfo
+	// Encrypted is true if the file has an /Encrypt dictionary,
+	// in which case Encryption describes it (e.g., "Standard V2
+	// R3 128-bit") and Permissions are the user's permissions.
+	Encrypted bool
+	Encryption string
+	Permissions Permissions
+	// Tagged is true if the catalog's /MarkInfo declares the
+	// document to be tagged.
+	Tagged bool
+	Pages []PageSummary
+	Fonts []FontInfo
+}
+
+// Summary() returns the document's version, information dictionary,
+// encryption, page sizes, and fonts.  Pages with no valid media box
+// are reported with a zero MediaBox.
+func (d *Document) Summary() DocumentSummary {
+	d.finishCurrentPage()
+
+	summary := DocumentSummary{
+		Version: versionOf(d.file),
+		Info: d.DocumentInfo,
+		Permissions: d.Permissions() }
+	if encrypt := d.file.Trailer().GetDictionary("Encrypt"); encrypt != nil {
+		summary.Encrypted = true
+		summary.Encryption = describeEncryption(encrypt)
+	}
+	if markInfo := d.file.Catalog().GetDictionary("MarkInfo"); markInfo != nil {
+		summary.Tagged,_ = markInfo.GetBoolean("Marked")
+	}
+	for n:=uint(0); n<d.pageCount; n++ {
+		var ps PageSummary
+		if page := d.Page(n); page != nil {
+			ps.MediaBox,_ = page.MediaBox()
+			ps.CropBox,_ = page.CropBox()
+			ps.Rotate,_ = page.GetInt("Rotate")
+			ps.Rotate = ((ps.Rotate % 360) + 360) % 360
+		}
+		summary.Pages = append(summary.Pages, ps)
+	}
+	summary.Fonts = d.Fonts()
+	return summary
+}
+
+// describeEncryption() returns the security handler, version,
+// revision, and key length of an /Encrypt dictionary.
+func describeEncryption(encrypt ProtectedDictionary) string {
+	filter,_ := encrypt.GetName("Filter")
+	v,_ := encrypt.GetInt("V")
+	r,_ := encrypt.GetInt("R")
+	bits,ok := encrypt.GetInt("Length")
+	switch {
+	case v >= 5:
+		bits = 256
+	case !ok:
+		bits = 40
+	}
+	return fmt.Sprintf("%s V%d R%d %d-bit", filter, v, r, bits)
+}
+
+// String() formats the summary as pdfinfo does, one "Key: value"
+// line per item, with the size of each page and the fonts following.
+func (s DocumentSummary) String() string {
+	var b bytes.Buffer
+	line := func(key, value string) {
+		fmt.Fprintf(&b, "%-15s %s\n", key+":", value)
+	}
+	yesNo := func(v bool) string {
+		if v {
+			return "yes"
+		}
+		return "no"
+	}
+	for _,item := range []struct{ key, value string }{
+		{"Title", s.Info.Title},
+		{"Subject", s.Info.Subject},
+		{"Keywords", s.Info.Keywords},
+		{"Author", s.Info.Author},
+		{"Creator", s.Info.Creator},
+		{"Producer", s.Info.Producer} } {
+		if item.value != "" {
+			line(item.key, item.value)
+		}
+	}
+	if !s.Info.CreationDate.IsZero() {
+		line("CreationDate", s.Info.CreationDate.Format("Mon Jan 2 15:04:05 2006 MST"))
+	}
+	if !s.Info.ModDate.IsZero() {
+		line("ModDate", s.Info.ModDate.Format("Mon Jan 2 15:04:05 2006 MST"))
+	}
+	line("Tagged", yesNo(s.Tagged))
+	if s.Encrypted {
+		p := s.Permissions
+		line("Encrypted", fmt.Sprintf("yes (%s; print:%s copy:%s change:%s addNotes:%s)", s.Encryption,
+			yesNo(p.Print), yesNo(p.Copy), yesNo(p.Modify), yesNo(p.Annotate)))
+	} else {
+		line("Encrypted", "no")
+	}
+	line("Pages", fmt.Sprint(len(s.Pages)))
+	for i,page := range s.Pages {
+		box := page.CropBox
+		line(fmt.Sprintf("Page %4d size", i+1), fmt.Sprintf("%s x %s pts (rotated %d degrees)",
+			FormatReal(box[2]-box[0], 2), FormatReal(box[3]-box[1], 2), page.Rotate))
+	}
+	line("PDF version", s.Version.String())
+	for _,font := range s.Fonts {
+		line("Font", fmt.Sprintf("%s (%s, %s)", font.BaseFont, font.Subtype,
+			map[bool]string{true: "embedded", false: "not embedded"}[font.Embedded]))
+	}
+	return b.String()
+}
+
+// maxDumpDepth limits the nesting of Dump()'s output so that long
+// chains of references, such as outline items linked by /Next, can't
+// exhaust the stack.
+const maxDumpDepth = 64
+
+// Dump() writes the document's object graph to w in an indented,
+// readable form for troubleshooting.  It starts at the trailer and
+// follows indirect references, showing each referenced object after
+// the first reference to it; later references are marked "(see
+// above)".  Streams are shown as their dictionaries followed by the
+// length of their decoded contents.  Objects that can't be read are
+// reported in place and don't stop the dump, but an error writing to
+// w does.
+func (d *Document) Dump(w io.Writer) error {
+	d.finishCurrentPage()
+
+	dumper := &objectDumper{
+		file: d.file,
+		writer: bufio.NewWriter(w),
+		visited: make(map[ObjectNumber]bool) }
+	dumper.writer.WriteString("trailer ")
+	dumper.dump(d.file.Trailer(), 0)
+	dumper.writer.WriteByte('\n')
+	return dumper.writer.Flush()
+}
+
+type objectDumper struct {
+	file File
+	writer *bufio.Writer
+	visited map[ObjectNumber]bool
+}
+
+func (od *objectDumper) newline(depth int) {
+	od.writer.WriteByte('\n')
+	od.writer.WriteString(strings.Repeat("  ", depth))
+}
+
+// dump() writes object, which begins on the current line, indented
+// by depth levels.
+func (od *objectDumper) dump(object Object, depth int) {
+	if depth > maxDumpDepth {
+		od.writer.WriteString("...")
+		return
+	}
+	switch t := object.(type) {
+	case ProtectedIndirect:
+		on := t.ObjectNumber(od.file)
+		od.writer.WriteString(on.String())
+		if od.visited[on] {
+			od.writer.WriteString(" (see above)")
+			return
+		}
+		od.visited[on] = true
+		referenced,err := od.file.Object(on)
+		if err != nil {
+			fmt.Fprintf(od.writer, " (unreadable: %v)", err)
+			return
+		}
+		od.writer.WriteString(" => ")
+		od.dump(referenced, depth)
+	case ProtectedStream:
+		od.writer.WriteString("stream ")
+		od.dump(t.Dictionary(), depth)
+		if reader := t.Reader(); reader != nil {
+			n,err := io.Copy(ioutil.Discard, reader)
+			if err != nil {
+				fmt.Fprintf(od.writer, " (%d bytes, then %v)", n, err)
+			} else {
+				fmt.Fprintf(od.writer, " (%d bytes)", n)
+			}
+		}
+	case ProtectedDictionary:
+		if t.Size() == 0 {
+			od.writer.WriteString("<< >>")
+			return
+		}
+		od.writer.WriteString("<<")
+		for _,key := range t.Keys() {
+			od.newline(depth+1)
+			NewName(key).Serialize(od.writer, od.file)
+			od.writer.WriteByte(' ')
+			od.dump(t.Get(key), depth+1)
+		}
+		od.newline(depth)
+		od.writer.WriteString(">>")
+	case ProtectedArray:
+		if simpleArray(t) {
+			od.writer.WriteString("[")
+			for i:=0; i<t.Size(); i++ {
+				if i > 0 {
+					od.writer.WriteByte(' ')
+				}
+				od.dump(t.At(i), depth+1)
+			}
+			od.writer.WriteString("]")
+			return
+		}
+		od.writer.WriteString("[")
+		for i:=0; i<t.Size(); i++ {
+			od.newline(depth+1)
+			od.dump(t.At(i), depth+1)
+		}
+		od.newline(depth)
+		od.writer.WriteString("]")
+	case ProtectString:
+		// Binary strings, such as file identifiers and encrypted
+		// passwords, are shown in hexadecimal.
+		if b := t.Bytes(); !printable(b) {
+			fmt.Fprintf(od.writer, "<%x>", b)
+			return
+		}
+		t.Serialize(od.writer, od.file)
+	case nil:
+		od.writer.WriteString("null")
+	default:
+		t.Serialize(od.writer, od.file)
+	}
+}
+
+// printable() returns true if b is printable ASCII text.
+func printable(b []byte) bool {
+	for _,c := range b {
+		if (c < ' ' || c > '~') && c != '\n' && c != '\r' && c != '\t' {
+			return false
+		}
+	}
+	return true
+}
+
+// simpleArray() returns true if a contains no containers or
+// references, so that it can be shown on one line.
+func simpleArray(a ProtectedArray) bool {
+	for i:=0; i<a.Size(); i++ {
+		switch a.At(i).(type) {
+		case ProtectedIndirect, ProtectedDictionary, ProtectedArray, ProtectedStream:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,36 @@
+package pdf
+
+import "errors"
+
+var alreadyEncrypted = errors.New(`File is already encrypted`)
+
+// Encrypt() reads the unencrypted PDF file named input and writes to
+// output the objects reachable from its trailer, encrypted as
+// WithEncryption() describes.  Objects are renumbered as by
+// Compact().  Input and output may name the same file.  Options are
+// passed to OpenFile() when reading input.
+func Encrypt(input, output, userPassword, ownerPassword string, permissions Permissions, options ...FileOption) error {
+	return rewriteFile(input, output, options,
+		[]FileOption{WithEncryption(userPassword, ownerPassword, permissions)},
+		func(destination *file, source File) error {
+			if source.Trailer().Get("Encrypt") != nil {
+				destination.abandon()
+				return alreadyEncrypted
+			}
+			return copyReachable(destination, source, false, nil)
+		})
+}
+
+// Decrypt() reads the PDF file named input and writes to output the
+// objects reachable from its trailer without encryption.  Objects are
+// renumbered as by Compact().  Input and output may name the same
+// file.  Options, which should include WithPasswordCallback() unless
+// the user password is empty, are passed to OpenFile() when reading
+// input.  Decrypting with the user password removes restrictions
+// that Permissions() would report, which the owner of the document
+// may not intend; the caller is responsible for honoring them.
+func Decrypt(input, output string, options ...FileOption) error {
+	return rewriteFile(input, output, options, nil, func(destination *file, source File) error {
+		return copyReachable(destination, source, false, nil)
+	})
+}
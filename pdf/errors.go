@@ -0,0 +1,148 @@
+package pdf
+
+import (
+	"fmt"
+	"io" )
+
+// The error types below let callers distinguish the kinds of failure
+// that occur when reading a file.  Each wraps an underlying error,
+// which is available through errors.Unwrap().  A zero value of each
+// type matches any error of that type with errors.Is(), so, for
+// example, errors.Is(err, &pdf.SyntaxError{}) reports whether err was
+// caused by malformed input.
+
+// SyntaxError reports input that could not be parsed.
+type SyntaxError struct {
+	// Offset is the byte offset at which the error was detected.
+	// It is relative to the beginning of the file for objects
+	// read from a File and to the beginning of the input
+	// otherwise.
+	Offset int64
+	// Expected and Found describe the input, if known.
+	Expected, Found string
+	Err error
+}
+
+func (e *SyntaxError) Error() string {
+	message := fmt.Sprintf("Syntax error at offset %d: %v", e.Offset, e.Err)
+	if e.Expected != "" {
+		message += fmt.Sprintf(" (expected %s, found %q)", e.Expected, e.Found)
+	}
+	return message
+}
+
+func (e *SyntaxError) Unwrap() error {
+	return e.Err
+}
+
+func (e *SyntaxError) Is(target error) bool {
+	t,ok := target.(*SyntaxError)
+	return ok && t.Err == nil
+}
+
+// XrefError reports a missing or damaged cross-reference table.
+type XrefError struct {
+	// Offset is the location of the xref section being read, or
+	// 0 if its location is unknown.
+	Offset int64
+	Err error
+}
+
+func (e *XrefError) Error() string {
+	return fmt.Sprintf("Damaged xref at offset %d: %v", e.Offset, e.Err)
+}
+
+func (e *XrefError) Unwrap() error {
+	return e.Err
+}
+
+func (e *XrefError) Is(target error) bool {
+	t,ok := target.(*XrefError)
+	return ok && t.Err == nil
+}
+
+// FilterError reports stream data that could not be decoded.
+type FilterError struct {
+	// Filter is the name of the filter (e.g., "FlateDecode").
+	Filter string
+	Err error
+}
+
+func (e *FilterError) Error() string {
+	return fmt.Sprintf("Unable to decode %s data: %v", e.Filter, e.Err)
+}
+
+func (e *FilterError) Unwrap() error {
+	return e.Err
+}
+
+func (e *FilterError) Is(target error) bool {
+	t,ok := target.(*FilterError)
+	return ok && t.Err == nil
+}
+
+// EncryptionError reports a problem with the security handler of an
+// encrypted file.  ErrIncorrectPassword is an EncryptionError.
+type EncryptionError struct {
+	Err error
+}
+
+func (e *EncryptionError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *EncryptionError) Unwrap() error {
+	return e.Err
+}
+
+func (e *EncryptionError) Is(target error) bool {
+	t,ok := target.(*EncryptionError)
+	return ok && t.Err == nil
+}
+
+// LimitError reports input that exceeds one of the Limits of a file
+// or the ParserLimits of a parser.  Such input is usually malicious,
+// such as a decompression bomb, or badly damaged.
+type LimitError struct {
+	// Limit is the name of the field of Limits or ParserLimits
+	// that was exceeded (e.g., "MaxStreamSize").
+	Limit string
+	// Value is the value of the limit.
+	Value int64
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("Limit exceeded: %s is %d", e.Limit, e.Value)
+}
+
+func (e *LimitError) Is(target error) bool {
+	t,ok := target.(*LimitError)
+	return ok && t.Limit == ""
+}
+
+// filterErrorReader converts the errors returned by a decoder into
+// FilterErrors.
+type filterErrorReader struct {
+	r io.Reader
+	filter string
+}
+
+func (fr filterErrorReader) Read(p []byte) (int, error) {
+	n,err := fr.r.Read(p)
+	if err != nil && err != io.EOF {
+		if _,ok := err.(*FilterError); !ok {
+			err = &FilterError{fr.filter, err}
+		}
+	}
+	return n, err
+}
+
+// errorReader is an io.Reader for a decoder that could not be
+// constructed.  Read() always returns err.
+type errorReader struct {
+	err error
+}
+
+func (er errorReader) Read(p []byte) (int, error) {
+	return 0, er.err
+}
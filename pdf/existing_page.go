@@ -1,11 +1,63 @@
 package pdf
 
+import (
+	"errors"
+	"fmt"
+	"io" )
+
 type ExistingPage struct {
 	*PageDictionary
 	reference Indirect
+	// document is the document containing the page, to which
+	// new content streams are written.
+	document *Document
+}
+
+// Reference() returns the reference to the page dictionary, for use
+// in destinations.
+func (ep *ExistingPage) Reference() Indirect {
+	return ep.reference
 }
 
 func (ep *ExistingPage) Rewrite() {
 	ep.PageDictionary.Write(ep.reference)
 }
 
+// ScaleContent() scales the page's contents and its boundary boxes by
+// factor, e.g., to resize a scanned page to a standard paper size.
+// The existing contents are wrapped in content streams that save the
+// graphics state and establish the scaling transformation.  As with
+// the other page changes, the result takes effect when Rewrite() is
+// called.  Annotations are not scaled.
+func (ep *ExistingPage) ScaleContent(factor float64) {
+	if factor <= 0 {
+		panic (fmt.Sprintf("Invalid scale factor %g", factor))
+	}
+	f := FormatReal(factor, realPrecision(ep.document.file))
+	ep.PrependContents(ep.document.writeContents(fmt.Sprintf("q %s 0 0 %s 0 0 cm ", f, f)))
+	ep.AppendContents(ep.document.writeContents(" Q "))
+	ep.scaleBoxes(factor)
+}
+
+// ConsolidateContents() replaces the page's contents, if they are an
+// array of streams, with a single stream containing their
+// concatenation as read by Reader(), compressed as directed by the
+// document's stream factory.  Pages whose contents were extended
+// several times, e.g., by ScaleContent() and Watermark(), are
+// smaller and faster to read once consolidated.  As with the other
+// page changes, the result takes effect when Rewrite() is called.
+func (ep *ExistingPage) ConsolidateContents() error {
+	if ep.GetArray("Contents") == nil {
+		return nil
+	}
+	reader := ep.Reader()
+	if reader == nil {
+		return errors.New(`Unable to read page contents`)
+	}
+	stream := ep.document.streamFactory.New()
+	if _,err := io.Copy(stream, reader); err != nil {
+		return err
+	}
+	ep.SetContents(ep.document.WriteObject(stream))
+	return nil
+}
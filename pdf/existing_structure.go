@@ -0,0 +1,214 @@
+package pdf
+
+// An ExistingStructureElement is a node in the structure tree of a
+// pre-existing tagged document, as returned by
+// Document.LogicalStructure().
+type ExistingStructureElement struct {
+	// Tag is the structure type as written in the file.
+	Tag string
+	// Role is the standard structure type to which Tag is mapped
+	// by the structure tree's role map.  It equals Tag if Tag is
+	// not mapped.
+	Role string
+
+	Alt string
+	ActualText string
+	Title string
+	Language string
+
+	// Attributes contains the attribute objects of the element.
+	Attributes []ProtectedDictionary
+
+	Parent *ExistingStructureElement
+	Kids []*ExistingStructureElement
+
+	// Content lists, in order, the marked-content sequences and
+	// objects belonging directly to the element.
+	Content []MarkedContent
+
+	// Dictionary is the element's structure element dictionary.
+	Dictionary ProtectedDictionary
+}
+
+// A MarkedContent identifies content belonging to a structure
+// element: either a marked-content sequence in a content stream or
+// an entire object such as an annotation.
+type MarkedContent struct {
+	// Page is a reference to the page dictionary on which the
+	// content appears.  PageIndex is its position in the document,
+	// numbered from 0, or -1 if the page is not in the page tree.
+	Page ProtectedIndirect
+	PageIndex int
+
+	// MCID is the marked-content identifier of the sequence, or
+	// -1 if the content is an object reference.
+	MCID int
+
+	// Stream is a reference to the content stream containing the
+	// sequence if it is not the page's content stream (for
+	// example, a form XObject).  It is otherwise nil.
+	Stream ProtectedIndirect
+
+	// Object is the referenced object when MCID is -1.
+	Object ProtectedIndirect
+}
+
+// structureReader holds the state used to construct the
+// ExistingStructureElement tree.
+type structureReader struct {
+	file File
+	roleMap ProtectedDictionary
+	pageIndex map[ObjectNumber]int
+	visited map[ObjectNumber]bool
+}
+
+// LogicalStructure() returns the top-level elements of the structure
+// tree of a pre-existing document, or nil if the document is not
+// tagged.
+func (d *Document) LogicalStructure() []*ExistingStructureElement {
+	if !d.existing {
+		return nil
+	}
+	catalog := d.file.Catalog()
+	if catalog == nil {
+		return nil
+	}
+	root := catalog.GetDictionary("StructTreeRoot")
+	if root == nil {
+		return nil
+	}
+
+	r := &structureReader{
+		file: d.file,
+		roleMap: root.GetDictionary("RoleMap"),
+		pageIndex: make(map[ObjectNumber]int),
+		visited: make(map[ObjectNumber]bool)}
+
+	if pages := catalog.GetDictionary("Pages"); pages != nil {
+		for i,reference := range pageReferences(d.file, pages, make(map[ObjectNumber]bool)) {
+			r.pageIndex[reference.ObjectNumber(d.file)] = i
+		}
+	}
+
+	result := new(ExistingStructureElement)
+	r.readKids(result, root.Get("K"), nil)
+	for _,kid := range result.Kids {
+		kid.Parent = nil
+	}
+	return result.Kids
+}
+
+// role() maps tag to a standard structure type by following the
+// role map.
+func (r *structureReader) role(tag string) string {
+	if r.roleMap == nil {
+		return tag
+	}
+	// Role maps may be chained, but they must not be circular.
+	// Limit the number of steps in case they are.
+	for i:=0; i<r.roleMap.Size(); i++ {
+		mapped,ok := r.roleMap.GetName(tag)
+		if !ok || mapped == tag {
+			break
+		}
+		tag = mapped
+	}
+	return tag
+}
+
+func (r *structureReader) markedContent(page ProtectedIndirect, mcid int) MarkedContent {
+	content := MarkedContent{Page: page, PageIndex: -1, MCID: mcid}
+	if page != nil {
+		if index,ok := r.pageIndex[page.ObjectNumber(r.file)]; ok {
+			content.PageIndex = index
+		}
+	}
+	return content
+}
+
+// readKids() adds the structure elements and content found in the
+// /K entry kids to parent.  page is the page inherited from parent's
+// /Pg entry.
+func (r *structureReader) readKids(parent *ExistingStructureElement, kids Object, page ProtectedIndirect) {
+	switch t := kids.(type) {
+	case nil:
+		return
+	case ProtectedArray:
+		for i:=0; i<t.Size(); i++ {
+			r.readKids(parent, t.At(i), page)
+		}
+		return
+	case *IntNumeric:
+		parent.Content = append(parent.Content, r.markedContent(page, t.Value()))
+		return
+	case ProtectedIndirect:
+		objectNumber := t.ObjectNumber(r.file)
+		if r.visited[objectNumber] {
+			return
+		}
+		r.visited[objectNumber] = true
+		if array,ok := t.Dereference().(ProtectedArray); ok {
+			r.readKids(parent, array, page)
+			return
+		}
+	}
+
+	dictionary,ok := kids.Dereference().(ProtectedDictionary)
+	if !ok {
+		return
+	}
+	if pg := dictionary.GetIndirect("Pg"); pg != nil {
+		page = pg
+	}
+
+	switch {
+	case dictionary.CheckNameValue("Type", "MCR"):
+		if mcid,ok := dictionary.GetInt("MCID"); ok {
+			content := r.markedContent(page, mcid)
+			content.Stream = dictionary.GetIndirect("Stm")
+			parent.Content = append(parent.Content, content)
+		}
+	case dictionary.CheckNameValue("Type", "OBJR"):
+		content := r.markedContent(page, -1)
+		content.Object = dictionary.GetIndirect("Obj")
+		parent.Content = append(parent.Content, content)
+	default:
+		parent.Kids = append(parent.Kids, r.readElement(parent, dictionary, page))
+	}
+}
+
+func (r *structureReader) readElement(parent *ExistingStructureElement, dictionary ProtectedDictionary, page ProtectedIndirect) *ExistingStructureElement {
+	e := &ExistingStructureElement{Parent: parent, Dictionary: dictionary}
+	e.Tag,_ = dictionary.GetName("S")
+	e.Role = r.role(e.Tag)
+	e.Alt = textString(dictionary, "Alt")
+	e.ActualText = textString(dictionary, "ActualText")
+	e.Title = textString(dictionary, "T")
+	e.Language = textString(dictionary, "Lang")
+
+	switch a := dictionary.Get("A").(type) {
+	case ProtectedArray:
+		// Attribute objects may be followed by revision numbers.
+		for i:=0; i<a.Size(); i++ {
+			if attributes,ok := a.At(i).Dereference().(ProtectedDictionary); ok {
+				e.Attributes = append(e.Attributes, attributes)
+			}
+		}
+	case Object:
+		if attributes,ok := a.Dereference().(ProtectedDictionary); ok {
+			e.Attributes = append(e.Attributes, attributes)
+		}
+	}
+
+	r.readKids(e, dictionary.Get("K"), page)
+	return e
+}
+
+// textString() returns the text string entry key of d decoded as a
+// Go string, or "" if the entry is missing.
+func textString(d ProtectedDictionary, key string) string {
+	if s,ok := d.GetString(key); ok {
+		return DecodeTextString(s)
+	}
+	return ""
+}
@@ -0,0 +1,107 @@
+package pdf
+
+import "os"
+
+// ExtractPages() creates a new document named filename containing
+// copies of the listed pages (numbered from 0) of d, in the order
+// listed.  Each page's resources, fonts, annotations, and other
+// referenced objects are copied.  Objects shared by several pages
+// are copied only once.  The new document is returned open so that
+// the client may modify it before calling Close(), which returns any
+// error reading an object of d.  Any options are passed to
+// OpenFile().
+func (d *Document) ExtractPages(filename string, pages []uint, options ...FileOption) *Document {
+	d.finishCurrentPage()
+
+	out := OpenDocument(filename, os.O_RDWR|os.O_CREATE|os.O_TRUNC, options...)
+	out.Title = d.Title
+	out.Author = d.Author
+	out.Subject = d.Subject
+	out.Keywords = d.Keywords
+	out.Creator = d.Creator
+
+	func() {
+		defer recoverCanceled(d, out)
+		copier := NewObjectCopier(out.file, d.file)
+		out.importPages(d, pages, copier)
+		out.noteCopyError(copier)
+	}()
+	return out
+}
+
+// importPages() appends copies of the listed pages of src to d using
+// the passed copier.  References to pages of src that are not being
+// imported (e.g., from link annotations) are replaced with null so
+// that the rest of src is not copied along with them.
+func (d *Document) importPages(src *Document, pages []uint, copier *ObjectCopier) {
+	if !d.readyForNewPages {
+		d.makeNewPageTree()
+	}
+
+	var allPages []ProtectedIndirect
+	if catalog := src.file.Catalog(); catalog != nil {
+		if root := catalog.GetDictionary("Pages"); root != nil {
+			allPages = pageReferences(src.file, root, make(map[ObjectNumber]bool))
+		}
+	}
+
+	selected := make(map[uint]bool, len(pages))
+	for _,n := range pages {
+		selected[n] = true
+	}
+
+	var null Indirect
+	for n,reference := range allPages {
+		objectNumber := reference.ObjectNumber(src.file)
+		if _,exists := copier.Translation(objectNumber); exists {
+			continue
+		}
+		if selected[uint(n)] {
+			copier.Translate(objectNumber, NewIndirect(d.file))
+		} else {
+			if null == nil {
+				null = NewIndirect(d.file).Write(NewNull())
+			}
+			copier.Translate(objectNumber, null)
+		}
+	}
+
+	used := make(map[uint]bool, len(pages))
+	progress := progressOf(d.file)
+	for i,n := range pages {
+		if d.canceled() != nil || src.canceled() != nil {
+			return
+		}
+		if i > 0 {
+			// The previous page is finished.
+			progress.OnPageProcessed(uint(i), uint(len(pages)))
+		}
+		page := src.Page(n)
+		if page == nil {
+			continue
+		}
+
+		// Inherited attributes have been copied into the page
+		// dictionary by Page().  The parent and structure
+		// entries refer to trees that are not copied.
+		dictionary := copier.copyDictionary(page.PageDictionary, "Parent", "StructParents", "B")
+		dictionary.Add("Parent", d.pageTreeRootIndirect)
+
+		// A page listed more than once needs a distinct object
+		// for each occurrence.
+		var reference Indirect
+		if !used[n] {
+			reference,_ = copier.Translation(page.reference.ObjectNumber(src.file))
+			used[n] = true
+		}
+		if reference == nil {
+			reference = NewIndirect(d.file)
+		}
+		d.pages.Add(reference.Write(dictionary))
+		d.pageCount += 1
+	}
+	if len(pages) > 0 {
+		progress.OnPageProcessed(uint(len(pages)), uint(len(pages)))
+	}
+	d.pageTreeRoot.Add("Count", NewIntNumeric(int(d.pageCount)))
+}
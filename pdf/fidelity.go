@@ -0,0 +1,62 @@
+package pdf
+
+import "bytes"
+
+// WithRoundTripFidelity() returns a FileOption under which objects
+// read from the file keep their original bytes unless they change.
+// Writing an object back to the number it was read from leaves the
+// file alone when the object serializes as the original did, and
+// the catalog and info dictionaries keep their object numbers, so
+// that rewriting a file changes as few bytes as possible.  Opening a
+// file and closing it again without changing anything leaves it
+// byte for byte as it was.
+func WithRoundTripFidelity() FileOption {
+	return func(f *file) {
+		f.fidelity = true
+	}
+}
+
+// unchanged() returns true if f was opened WithRoundTripFidelity(),
+// object o is still the one read from the file, and object
+// serializes as that one does.  The original is parsed again, rather
+// than taken from the cache, since a cached object may be the very
+// one that has since been modified.
+func (f *file) unchanged(o ObjectNumber, entry *xrefEntry, object Object) bool {
+	if !f.fidelity {
+		return false
+	}
+	<-f.semaphore
+	original := entry.inUse && !entry.dirty && entry.serialization == nil
+	byteOffset := entry.byteOffset
+	f.semaphore<-true
+	if !original {
+		return false
+	}
+
+	parser := f.newObjectParser(byteOffset, 0)
+	defer parser.release()
+	existing,err := parser.ScanIndirect(o, f)
+	if err == nil && f.security != nil && o != f.encryptObjectNumber {
+		err = f.security.decryptObject(o, existing)
+	}
+	if err != nil {
+		return false
+	}
+
+	before,after := new(bytes.Buffer),new(bytes.Buffer)
+	existing.Serialize(before, f)
+	object.Serialize(after, f)
+	return bytes.Equal(before.Bytes(), after.Bytes())
+}
+
+// trailerReference() returns the object number to which the
+// trailer's name entry refers, if f was opened
+// WithRoundTripFidelity() and the entry is a reference in f.
+func (f *file) trailerReference(name string) (ObjectNumber, bool) {
+	if f.fidelity {
+		if reference,ok := f.trailerDictionary.Get(name).(Indirect); ok && reference.BoundToFile(f) {
+			return reference.ObjectNumber(f),true
+		}
+	}
+	return ObjectNumber{},false
+}
@@ -3,12 +3,16 @@ package pdf
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"io"
+	"math"
 	"os"
 	"regexp"
 	"strconv"
+	"strings"
+	"time"
 	"github.com/mawicks/PDFiG/containers"
 	"github.com/mawicks/PDFiG/readers" )
 
@@ -18,6 +22,10 @@ type xrefEntry struct {
 	generation uint16
 	inUse      bool
 
+	// "reserved" is true from the time ReserveObjectNumber()
+	// assigns the entry until its object is written.
+	reserved bool
+
 	// "dirty" is true when the in-memory version of the xref entry doesn't match
 	// the "file" copy.
 	dirty bool
@@ -41,48 +49,97 @@ type xrefEntry struct {
 
 type writeQueueEntry struct {
 	index uint32
+	// generation is the generation of the object when it was
+	// queued.  The write is skipped if the object is deleted
+	// before it is written.
+	generation uint16
 	xrefEntry *xrefEntry
+	// stream is not nil for a stream whose contents are copied
+	// from a reader.  The serialization contains only its
+	// dictionary and the "stream" keyword.
+	stream *streamWrite
+}
+
+// streamWrite describes the contents of a stream written by
+// file.writeStream().
+type streamWrite struct {
+	contents io.Reader
+	encoder func(io.WriteCloser) io.WriteCloser
+	// length is the object to which /Length refers.
+	length ObjectNumber
+	err error
+	done chan bool
 }
 
 // Write xrefEntry to output stream using Writer.
 func (entry *xrefEntry) Serialize(w Writer) {
-	fmt.Fprintf(w,
-		"%010d %05d %c \n",
-		entry.byteOffset,
-		entry.generation,
-		func(inuse bool) (result rune) {
-			if inuse {
-				result = 'n'
-			} else {
-				result = 'f'
-			}
-			return result
-		}(entry.inUse))
+	line := appendPadded(scratch(w), entry.byteOffset, 10)
+	line = append(line, ' ')
+	line = appendPadded(line, uint64(entry.generation), 5)
+	if entry.inUse {
+		line = append(line, " n \n"...)
+	} else {
+		line = append(line, " f \n"...)
+	}
+	w.Write(line)
 }
 
+// clear() marks the entry free, linking it to nextFree.  If the
+// entry was assigned to an object, whether or not the object has been
+// written yet, its generation is incremented so that stale references
+// and pending writes don't match it.
 func (entry *xrefEntry) clear (nextFree uint64) {
-	if entry.inUse && entry.generation < 65535 {
+	if (entry.inUse || entry.reserved) && entry.generation < 65535 {
 		entry.generation += 1
 	}
 	entry.byteOffset = nextFree
 	entry.inUse = false
+	entry.reserved = false
 	entry.dirty = true
 }
 
 func (entry *xrefEntry) setInUse (location uint64) {
 	entry.byteOffset = location
 	entry.inUse = true
+	entry.reserved = false
 	entry.dirty = true
 }
 
 type file struct {
-	pdfVersion uint
-	file *os.File
-	mode int
+	// pdfVersion is the version requested by WithVersion() or
+	// required by the features used, and headerVersion is the
+	// version in the file's header.
+	pdfVersion Version
+	headerVersion Version
+	file storage
 	originalSize int64
 	// Location of xref for pre-existing files.
 	xrefLocation int64
 	xref containers.ArrayStack
+	// originalXrefSize is the size of the xref when the file was
+	// opened.  Entries beyond it were added by this update.
+	originalXrefSize uint
+	reusePolicy ReusePolicy
+
+	// deterministic is set by WithDeterministicOutput(), and
+	// timestamp is then used in place of the current time.
+	deterministic bool
+	timestamp time.Time
+
+	// inspection is set by WithInspectableOutput().
+	inspection bool
+
+	// fidelity is set by WithRoundTripFidelity().
+	fidelity bool
+
+	// realPrecision is the number of digits after the decimal
+	// point to which reals are rounded, or -1 for all of them.
+	realPrecision int
+
+	// compression holds the levels set by WithCompression().
+	compression map[StreamCategory]int
+	// codecOutput is set by WithCodecOutput().
+	codecOutput bool
 
 	// trailerDictionary is never nil
 	// It is initialized from a pre-existing trailer
@@ -101,27 +158,145 @@ type file struct {
 
 	writeQueue chan writeQueueEntry
 	writingFinished chan bool
-	readNesting int
 
-	// semaphore protects access to "file" so that reads and
-	// writes are properly interleaved.
+	// semaphore protects the xref entries and the object cache,
+	// which are shared by readers and gowriter().
 	semaphore chan bool
 	closed bool
+
+	// passwordCallback is used to obtain a password if the file
+	// is encrypted and the empty password fails.  It may be nil.
+	passwordCallback PasswordCallback
+
+	// newEncryption is non-nil when WithEncryption() has been
+	// used to request that a new file be encrypted.
+	newEncryption *encryptionRequest
+
+	// security is nil unless the file is encrypted, in which
+	// case it decrypts strings and streams as objects are read.
+	// encryptObjectNumber is the object number of the /Encrypt
+	// dictionary, which itself is never encrypted.
+	security *standardSecurityHandler
+	encryptObjectNumber ObjectNumber
+
+	// linearize is set by WithLinearization().
+	linearize bool
+
+	// streaming is set by WithStreamingOutput().
+	streaming bool
+
+	// blockCache is set by WithBlockCache().  It is nil if
+	// objects are read directly from the file.
+	blockCache *blockCache
+
+	// parsingMode is set by WithParsingMode().
+	parsingMode ParsingMode
+
+	// limits is set by WithLimits().
+	limits Limits
+
+	// cache holds objects parsed by Object().  It is nil if
+	// caching is disabled.
+	cache *objectCache
+
+	// repairReport is non-nil if the xref of a pre-existing file
+	// was damaged and had to be rebuilt.
+	repairReport *RepairReport
+
+	// revisions lists the revisions of a pre-existing file, oldest
+	// first.
+	revisions []Revision
+
+	// ctx is set by WithContext().  It is nil if the file can't
+	// be cancelled.
+	ctx context.Context
+
+	// progress is set by WithProgress().  totalBytesRead is the
+	// number of bytes reported to it by bytesRead().
+	progress ProgressObserver
+	totalBytesRead int64
+
+	// writeObservers are added by WithWriteObserver().
+	writeObservers []WriteObserver
+}
+
+type encryptionRequest struct {
+	userPassword, ownerPassword string
+	permissions Permissions
+}
+
+// FileOption values configure optional behavior of a File.  They
+// may be passed to OpenFile() and OpenDocument().
+type FileOption func(*file)
+
+// WithPasswordCallback() returns a FileOption that uses callback to
+// obtain the password for an encrypted file.  Without it, only
+// files whose user password is empty can be read.
+func WithPasswordCallback(callback PasswordCallback) FileOption {
+	return func(f *file) {
+		f.passwordCallback = callback
+	}
+}
+
+// WithParsingMode() returns a FileOption that parses the objects of
+// a pre-existing file in the specified mode.  With StrictParsing, a
+// file whose xref is damaged is rejected rather than repaired.  With
+// LenientParsing, objects that aren't at their xref offsets are
+// sought nearby.
+func WithParsingMode(mode ParsingMode) FileOption {
+	return func(f *file) {
+		f.parsingMode = mode
+	}
+}
+
+// WithContext() returns a FileOption that lets ctx cancel work on
+// the file.  Once ctx is done, OpenFile() and Object() return
+// ctx.Err(), objects written are discarded, and Close() leaves the
+// file without an xref or trailer, so a new file is incomplete and
+// an update to a pre-existing file is ignored by readers that use
+// the xref.  Documents opened with the option stop merging or
+// extracting pages, and Document.Close() returns ctx.Err().
+func WithContext(ctx context.Context) FileOption {
+	return func(f *file) {
+		f.ctx = ctx
+	}
+}
+
+// canceled() returns the error of the file's context if the context
+// is done, and nil otherwise.
+func (f *file) canceled() error {
+	if f.ctx == nil {
+		return nil
+	}
+	return f.ctx.Err()
 }
 
 // OpenFile() construct a File object from either a new or a pre-existing filename.
-func OpenFile(filename string, mode int) (result *file,exists bool,err error) {
+// If a pre-existing file is encrypted, a password is obtained using
+// the callback provided by WithPasswordCallback(), and err is
+// ErrIncorrectPassword if no password authenticates.
+func OpenFile(filename string, mode int, options ...FileOption) (result *file,exists bool,err error) {
 	var f *os.File
 	f,err = os.OpenFile(filename, mode, 0666)
 	if err != nil {
 		return
 	}
+	return openStorage(f, options...)
+}
 
+// openStorage() constructs a File from a new (empty) or pre-existing
+// PDF in f.  It is used by OpenFile() and the other constructors.
+func openStorage(f storage, options ...FileOption) (result *file,exists bool,err error) {
 	result = new(file)
 	result.file = f
-	result.mode = mode
+	result.cache = newObjectCache(defaultObjectCacheBudget)
+	result.realPrecision = -1
+	result.limits = defaultLimits
+	for _,option := range options {
+		option(result)
+	}
 
-	result.xref = &containers.StackArrayDecorator{containers.NewDynamicArray(1024)}
+	result.xref = &containers.StackArrayDecorator{Array: containers.NewDynamicArray(1024)}
 	result.originalSize,_ = f.Seek(0, os.SEEK_END)
 
 	if (result.originalSize == 0) {
@@ -136,27 +311,48 @@ func OpenFile(filename string, mode int) (result *file,exists bool,err error) {
 		result.dirty = true
 	} else {
 		exists = true
-		// For pre-existing files, read the xref
-		result.xrefLocation = findXrefLocation(f)
-		var nextXref int
-		nextXref,result.trailerDictionary = readOneXrefSection(result, result.xrefLocation)
-		for ; nextXref != 0; {
-			nextXref,_ = readOneXrefSection(result, int64(nextXref))
+		// For pre-existing files, read the xref, rebuilding it
+		// if it is damaged.
+		if problem := result.readXref(); problem != nil {
+			// A file that exceeds the limits isn't repaired,
+			// since repairing it would defeat them.
+			if result.parsingMode == StrictParsing || errors.Is(problem, &LimitError{}) {
+				f.Close()
+				return nil,exists,problem
+			}
+			if err = result.canceled(); err == nil {
+				result.reconstructXref(problem.Error())
+			}
+		}
+		if err = result.canceled(); err != nil {
+			f.Close()
+			return nil,exists,err
 		}
 	}
+	result.originalXrefSize = result.xref.Size()
+
 	// If no pre-existing trailer was parsed, create a new dictionary.
 	if result.trailerDictionary == nil {
 		result.trailerDictionary = NewDictionary()
 	}
 
-	// Link the new current trailer to the most recent pre-existing xref.
+	// Link the new current trailer to the most recent pre-existing
+	// xref.  A hybrid file's /XRefStm describes only the section
+	// in whose trailer it appears, so it isn't carried forward.
 	if (result.xrefLocation != 0) {
 		result.trailerDictionary.Add ("Prev", NewIntNumeric(int(result.xrefLocation)))
+		result.trailerDictionary.Remove("XRefStm")
 	}
 
 	result.writer = bufio.NewWriter(f)
 	if (result.originalSize == 0) {
-		writeHeader(result.writer)
+		if result.pdfVersion == 0 {
+			result.pdfVersion = DefaultVersion
+		}
+		result.headerVersion = result.pdfVersion
+		writeHeader(result.writer, result.headerVersion)
+	} else {
+		result.headerVersion = result.readHeaderVersion()
 	}
 	result.Seek(0,os.SEEK_END)
 
@@ -167,9 +363,111 @@ func OpenFile(filename string, mode int) (result *file,exists bool,err error) {
 
 	go result.gowriter()
 
+	if exists {
+		if err = result.initializeSecurity(); err != nil {
+			result.abandon()
+			return nil,exists,err
+		}
+	}
+	result.initializeID()
+	if !exists && result.newEncryption != nil {
+		result.initializeEncryption(result.newEncryption)
+	}
+
 	return
 }
 
+// WithEncryption() returns a FileOption that encrypts a new file with
+// the standard security handler using AES-256.  Opening the file
+// with the user password grants the specified permissions.  Opening
+// it with the owner password grants all permissions.  If the owner
+// password is empty, the user password is used for both.  The option
+// has no effect on pre-existing files.
+func WithEncryption(userPassword, ownerPassword string, permissions Permissions) FileOption {
+	return func(f *file) {
+		f.newEncryption = &encryptionRequest{userPassword, ownerPassword, permissions}
+	}
+}
+
+// initializeEncryption() writes the /Encrypt dictionary for a new
+// file that is to be encrypted.  Every object
+// subsequently written to the file is encrypted.
+func (f *file) initializeEncryption(request *encryptionRequest) {
+	handler, encrypt := newAES256SecurityHandler(request.userPassword, request.ownerPassword, request.permissions)
+	// Revision 6 of the standard security handler is part of PDF 2.0.
+	f.requireVersion(PDF20)
+
+	// The /Encrypt dictionary itself must not be encrypted.
+	encryptIndirect := NewIndirect(f)
+	f.encryptObjectNumber = encryptIndirect.ObjectNumber(f)
+	encryptIndirect.Write(encrypt)
+	f.trailerDictionary.Add("Encrypt", encryptIndirect)
+
+	f.security = handler
+}
+
+// initializeSecurity() reads the /Encrypt dictionary of a
+// pre-existing file, if there is one, and authenticates using the
+// empty password and then any passwords provided by the password
+// callback.
+func (f *file) initializeSecurity() error {
+	encryptValue := f.trailerDictionary.Get("Encrypt")
+	if encryptValue == nil {
+		return nil
+	}
+
+	var encrypt ProtectedDictionary
+	if reference,ok := encryptValue.(Indirect); ok {
+		objectNumber := reference.ObjectNumber(f)
+		object,err := f.Object(objectNumber)
+		if err != nil {
+			return err
+		}
+		encrypt,_ = object.(ProtectedDictionary)
+		f.encryptObjectNumber = objectNumber
+	} else {
+		encrypt,_ = encryptValue.(ProtectedDictionary)
+	}
+	if encrypt == nil {
+		return invalidEncryptDictionary
+	}
+
+	var id0 []byte
+	if id := f.trailerDictionary.GetArray("ID"); id != nil && id.Size() > 0 {
+		if s,ok := id.At(0).(ProtectString); ok {
+			id0 = s.Bytes()
+		}
+	}
+
+	handler,err := newStandardSecurityHandler(encrypt, id0)
+	if err != nil {
+		return err
+	}
+
+	authenticated := handler.authenticate("")
+	for attempt:=1; !authenticated && f.passwordCallback != nil; attempt++ {
+		password,ok := f.passwordCallback(attempt)
+		if !ok {
+			break
+		}
+		authenticated = handler.authenticate(password)
+	}
+	if !authenticated {
+		return ErrIncorrectPassword
+	}
+
+	f.security = handler
+	return nil
+}
+
+// abandon() closes a file without writing anything to it.
+func (f *file) abandon() {
+	close(f.writeQueue)
+	<- f.writingFinished
+	f.file.Close()
+	f.release()
+}
+
 // Implements WriteObject() in File interface
 func (f *file) WriteObject(object Object) Indirect {
 	return NewIndirect(f).Write(object)
@@ -182,18 +480,15 @@ func (f *file) DeleteObject(indirect Indirect) {
 	if objectNumber.generation != entry.generation {
 		panic("Generation number mismatch")
 	}
-
-	if entry.generation < 65535 {
-		// Increment the generation count for the next use
-		// and link into free list.
-		freeHead := (*f.xref.At(0)).(*xrefEntry)
-		entry.clear(freeHead.byteOffset)
-		freeHead.clear(uint64(objectNumber.number))
-	} else {
-		// Don't link into free list.  Just set byte offset to 0
-		entry.clear(0)
+	<-f.semaphore
+	defer func() { f.semaphore<-true }()
+	if !entry.inUse && !entry.reserved {
+		panic(fmt.Sprintf("Object %d has already been deleted", objectNumber.number))
 	}
-
+	if f.cache != nil {
+		f.cache.remove(objectNumber)
+	}
+	f.freeEntry(objectNumber.number)
 	f.dirty = true
 }
 
@@ -224,52 +519,111 @@ func (f *file) Indirect(o ObjectNumber) Indirect {
 // Object() retrieves an object that already exists (or is in the
 // process of being written to) a PDF file.  Each call causes a new
 // object to be unserialized from the file or a buffer so the caller
-// has exclusive ownership of the returned object.
+// has exclusive ownership of the returned object.  Object() may be
+// called concurrently from several goroutines.  The file is read
+// with ReadAt() so that readers neither disturb one another nor the
+// position at which gowriter() writes.
 func (f *file) Object(o ObjectNumber) (object Object,err error) {
-	entry := (*f.xref.At(uint(o.number))).(*xrefEntry)
-	var r Scanner
-
-	// Reads can trigger additional reads, so this routine is
-	// recursive (For example, read a stream dictionary containing
-	// an indirect reference to the stream length; read the length
-	// from another part of the file, then return to the original
-	// position to read the stream data.  All reads occur in main
-	// goroutine so there should never be more than one goroutine
-	// manipulating f.readNesting.
-	if f.readNesting == 0 {
-		// Block writes
-		<-f.semaphore
-	}
-	f.readNesting += 1
-
-	if entry.serialization == nil {
-		// Save file position before moving for later restore
-		// so that f.Writer is unaware of the move.
-		position,_ := f.file.Seek(0, os.SEEK_CUR)
-		f.file.Seek(int64(entry.byteOffset),os.SEEK_SET)
+	return f.object(o, 0)
+}
 
-		r = bufio.NewReader(f.file)
-		object,err = NewParser(r).ScanIndirect(o, f)
+// object() reads object o, which is being read in order to read
+// depth other objects, as described for Limits.MaxResolutionDepth.
+func (f *file) object(o ObjectNumber, depth int) (object Object,err error) {
+	if err = f.canceled(); err != nil {
+		return nil,err
+	}
+	if depth > f.limits.MaxResolutionDepth {
+		return nil,&LimitError{"MaxResolutionDepth", int64(f.limits.MaxResolutionDepth)}
+	}
 
-		// Restore position
-		f.file.Seek(position,os.SEEK_SET)
+	// Reads can trigger additional reads (For example, read a
+	// stream dictionary containing an indirect reference to the
+	// stream length; read the length from another part of the
+	// file, then return to read the stream data), so the
+	// semaphore is held only while the xref entry and the cache
+	// are accessed, never while parsing.
+	<-f.semaphore
+	entry := f.entry(uint(o.number))
+	if entry == nil {
+		f.semaphore<-true
+		// A reference to an object that doesn't exist is
+		// treated as a reference to the null object.
+		return NewNull(),nil
+	}
+	byteOffset,serialization := entry.byteOffset,entry.serialization
+	if serialization != nil {
+		// The writer returns the serialization's memory to
+		// serializationBuffers once it has been written.
+		serialization = append([]byte(nil), serialization...)
+	}
+	if f.cache != nil && serialization == nil {
+		if object,cached := f.cache.get(o); cached {
+			f.semaphore<-true
+			return object,nil
+		}
+	}
+	f.semaphore<-true
+
+	if serialization == nil {
+		parser := f.newObjectParser(byteOffset, depth)
+		object,err = parser.ScanIndirect(o, f)
+		if err != nil && f.parsingMode == LenientParsing {
+			if offset,found := f.findNearbyObject(o, byteOffset); found {
+				<-f.semaphore
+				entry.byteOffset = offset
+				f.semaphore<-true
+				parser.release()
+				parser = f.newObjectParser(offset, depth)
+				object,err = parser.ScanIndirect(o, f)
+			}
+		}
+		if err == nil && f.security != nil && o != f.encryptObjectNumber {
+			err = f.security.decryptObject(o, object)
+		}
+		if s,ok := object.(*stream); ok {
+			s.maxDecodedSize = f.limits.MaxStreamSize
+		}
+		if err == nil && f.cache != nil {
+			<-f.semaphore
+			f.cache.put(o, object, parser.scanner.Position())
+			f.semaphore<-true
+		}
+		f.bytesRead(parser.scanner.Position())
+		parser.release()
 	} else {
-		r = bytes.NewReader(entry.serialization)
 		// Cached entry does not contain "obj" header and "endobj" trailer
 		// so use Parser.Scan() rather than Parser.ScanIndirect().
-		object,err = NewParser(r).Scan(f)
-		fmt.Fprintf(logger, "Object pulled from cache: \"%v\"\n", string(entry.serialization))
-	}
-
-	f.readNesting -= 1
-	if f.readNesting == 0 {
-		// Allow writes
-		f.semaphore<-true
+		object,err = NewParser(bytes.NewReader(serialization)).Scan(f)
+		if err == nil && f.security != nil && o != f.encryptObjectNumber {
+			err = f.security.decryptObject(o, object)
+		}
+		fmt.Fprintf(logger, "Object pulled from cache: \"%v\"\n", string(serialization))
 	}
 
 	return object,err
 }
 
+// newObjectParser() returns a parser for the object at offset that
+// reads the file with ReadAt().  Depth is as described for object().
+// The parser's reader is taken from a pool, to which release() should
+// return it once the object has been scanned.
+func (f *file) newObjectParser(offset uint64, depth int) *Parser {
+	reader := getObjectReader(io.NewSectionReader(f.readerAt(), int64(offset), math.MaxInt64-int64(offset)))
+	parser := NewParser(reader)
+	parser.pooled = reader
+	parser.SetMode(f.parsingMode)
+	parser.SetOffset(int64(offset))
+	parser.SetLimits(ParserLimits{MaxDepth: f.limits.MaxDepth})
+	parser.resolve = func(reference Indirect) (Object, error) {
+		return f.object(reference.ObjectNumber(f), depth+1)
+	}
+	if f.security == nil {
+		parser.SetStreamSource(f.file)
+	}
+	return parser
+}
+
 // Implements ReserveObjectNumber() in File interface
 func (f *file) ReserveObjectNumber(indirect Indirect) ObjectNumber {
 	var (
@@ -277,9 +631,10 @@ func (f *file) ReserveObjectNumber(indirect Indirect) ObjectNumber {
 		generation uint16
 	)
 
-	// Find an unused node if possible taken from beginning of
-	// free list.
-	newNumber = uint32((*f.xref.At(0)).(*xrefEntry).byteOffset)
+	<-f.semaphore
+	// Find an unused entry on the free list if the policy allows
+	// its reuse.
+	newNumber = f.takeFreeEntry()
 	if newNumber == 0 {
 		// Create a new xref entry
 		newNumber = uint32(f.xref.Size())
@@ -287,53 +642,97 @@ func (f *file) ReserveObjectNumber(indirect Indirect) ObjectNumber {
 			byteOffset: 0,
 			generation: 0,
 			inUse: false,
+			reserved: true,
 			dirty: true,
 			serialization: nil,
 			indirect: indirect})
 	} else {
-		// Adjust link in head of free list
-		freeHead := (*f.xref.At(0)).(*xrefEntry)
-		entry := (*f.xref.At(uint(newNumber))).(*xrefEntry)
-		freeHead.clear(entry.byteOffset)
-
-		entry.clear(0)
+		entry := f.entry(uint(newNumber))
+		entry.reserved = true
+		entry.serialization = nil
+		entry.indirect = indirect
 		generation = entry.generation
 	}
+	f.semaphore<-true
 	f.dirty = true
 	result := ObjectNumber{newNumber, generation}
 	return result
 }
 
+// reserveObjectNumbers() adds to a new file an entry numbered n for
+// each element n of indirects other than the first, reserving it with
+// generation 0 for indirects[n] or leaving it free if indirects[n] is
+// nil.
+func (f *file) reserveObjectNumbers(indirects []Indirect) {
+	<-f.semaphore
+	for n:=f.xref.Size(); n < uint(len(indirects)); n++ {
+		f.xref.PushBack(&xrefEntry{
+			dirty: true,
+			reserved: indirects[n] != nil,
+			indirect: indirects[n]})
+	}
+	f.semaphore<-true
+	f.dirty = true
+}
+
 // Implements Close() in File interface
 func (f *file) Close() {
-	if f.trailerDictionary.Get("Root") == nil {
-		f.SetCatalog(NewDictionary())
-		fmt.Fprintf(logger, "Warning: No document catalog has been specified.  Creating empty dictionary.  Use File.SetCatalog() to set one.\n")
+	if f.canceled() != nil {
+		f.abandon()
+		return
 	}
+	f.validateTrailer()
+	f.recordVersion()
 
 	close(f.writeQueue)
 	<- f.writingFinished
 
 	if f.dirty {
+		f.rewriteHeader()
 //	 	dumpXref(f.xref)
 
 		xrefPosition,_ := f.Seek(0, os.SEEK_END)
+		f.updateID()
+		f.truncateFreeEntries()
+		f.linkFreeList()
 		f.writeXref()
-
-		f.trailerDictionary.Add("Size", NewIntNumeric(int(f.xref.Size())))
 		f.writeTrailer(xrefPosition)
 	}
 
 	f.writer.Flush()
 	f.file.Close()
 
+	linearize := f.linearize && f.dirty
+	var filename string
+	if named,ok := f.file.(*os.File); ok {
+		filename = named.Name()
+	} else if linearize {
+		fmt.Fprintf(logger, "Warning: Only files opened by name can be linearized\n")
+		linearize = false
+	}
 	f.release()
+
+	if linearize {
+		if err := Linearize(filename, filename); err != nil {
+			fmt.Fprintf(logger, "Warning: Unable to linearize %s: %v\n", filename, err)
+		}
+	}
 }
 
 func (f *file) Closed() bool {
 	return f.closed
 }
 
+// Permissions() returns the permissions granted to the user of an
+// encrypted file.  All permissions are granted for a file that isn't
+// encrypted or that was opened with the owner password.
+func (f *file) Permissions() Permissions {
+	if f.security == nil {
+		return AllPermissions()
+	}
+	return f.security.permissions()
+}
+
 // ReadLine() reads a line from a PDF file interpreting end-of-line
 // characters according to the PDF specification.  In contexts where
 // you would be likely to use pdf.ReadLine() are where the line
@@ -361,6 +760,23 @@ func ReadLine(r io.ByteScanner) (result string, err error) {
 	return
 }
 
+// readKeywordLine() reads a line as ReadLine() does with any comment
+// and surrounding white space removed.  Lines containing only a
+// comment are skipped.  It reads the lines on which a keyword such as
+// "endobj" or "trailer" is expected, which may end with a comment.
+func readKeywordLine(r io.ByteScanner) (line string, err error) {
+	for {
+		line,err = ReadLine(r)
+		i := strings.IndexByte(line, '%')
+		if i >= 0 {
+			line = line[:i]
+		}
+		line = strings.Trim(line, whiteSpaceCharacters)
+		if line != "" || i < 0 || err != nil {
+			return
+		}
+	}
+}
 
 func (f *file) dictionaryFromTrailer(name string) Dictionary {
 	if infoValue := f.trailerDictionary.Get(name); infoValue != nil {
@@ -375,6 +791,10 @@ func (f *file) dictionaryFromTrailer(name string) Dictionary {
 }
 
 func (f *file) dictionaryToTrailer(name string, d Dictionary) {
+	if objectNumber,ok := f.trailerReference(name); ok {
+		f.WriteObjectAt(objectNumber, d)
+		return
+	}
 	f.trailerDictionary.Add(name,NewIndirect(f).Write(d))
 }
 
@@ -396,7 +816,7 @@ func (f *file) Info() Dictionary {
 }
 
 func (f *file) SetInfo(info DocumentInfo) {
-	f.dictionaryToTrailer("Info", info.Dictionary)
+	f.dictionaryToTrailer("Info", info.Dictionary())
 }
 
 // Trailer() returns the current trailer, which is never nil
@@ -422,10 +842,10 @@ func (f *file) Tell() int64 {
 
 // Scan the file for the xref location, returning with the original
 // file position unchanged.
-func findXrefLocation(f *os.File) (result int64) {
+func findXrefLocation(f io.ReadSeeker) (result int64) {
 	save,_ := f.Seek(0,os.SEEK_END)
 	regexp,_ := regexp.Compile (`\s*FOE%%\s*(\d+)(\s*ferxtrats)`)
-	reader := bufio.NewReader(&io.LimitedReader{readers.NewReverseReader(f),512})
+	reader := bufio.NewReader(&io.LimitedReader{R: readers.NewReverseReader(f), N: 512})
 	indexes := regexp.FindReaderSubmatchIndex(reader)
 
 	if (indexes != nil) {
@@ -441,13 +861,16 @@ func findXrefLocation(f *os.File) (result int64) {
 	return result
 }
 
-func readXrefSubsection(xref containers.Array, r *bufio.Reader, start, count uint) {
+func readXrefSubsection(xref containers.Array, r *bufio.Reader, start, count, maxObjects uint) {
 	var (
 		position uint64
 		generation uint16
 		useChar rune
 	)
 
+	if start+count > maxObjects || start+count < start {
+		panic(&LimitError{"MaxObjects", int64(maxObjects)})
+	}
 	// Make sure xref is large enough for the section about to be read.
 	if xref.Size() < start+count {
 		xref.SetSize(start+count)
@@ -478,15 +901,19 @@ func readXrefSubsection(xref containers.Array, r *bufio.Reader, start, count uin
 	}
 }
 
-func readTrailer(subsectionHeader string, r *bufio.Reader, f *file) (Dictionary,error) {
+// readTrailer() reads the trailer dictionary that follows an xref
+// section, resolving references with f.
+func readTrailer(subsectionHeader string, r *bufio.Reader, mode ParsingMode, limits Limits, f File) (Dictionary,error) {
 	var err error
 	tries := 0
 	const maxTries = 4
 	for tries=0; err == nil && subsectionHeader != "trailer" && tries < maxTries; tries += 1 {
-		subsectionHeader,err = ReadLine(r)
+		subsectionHeader,err = readKeywordLine(r)
 	}
 	if (err == nil && tries < maxTries) {
 		parser := NewParser (r)
+		parser.SetMode(mode)
+		parser.SetLimits(ParserLimits{MaxDepth: limits.MaxDepth})
 		object, err := parser.Scan(f)
 		if err != nil {
 			errmsg := fmt.Sprintf("%s\nLast data read before error: \"%s\"",
@@ -502,35 +929,46 @@ func readTrailer(subsectionHeader string, r *bufio.Reader, f *file) (Dictionary,
 	return nil,err
 }
 
-func readOneXrefSection (f *file, location int64) (prevXref int, trailer Dictionary) {
-
-	if _,err := f.file.Seek (location, os.SEEK_SET); err != nil {
-		panic ("Seeking to xref position failed")
-	}
-
-	r := bufio.NewReader(f.file)
- 	if header,_ := ReadLine(r); header != "xref" {
+// scanXrefSection() reads an xref section, from the "xref" keyword
+// through the trailer dictionary, into xref and returns the trailer.
+// It panics if the section is malformed or exceeds limits.
+func scanXrefSection(r *bufio.Reader, xref containers.Array, mode ParsingMode, limits Limits, f File) Dictionary {
+ 	if header,_ := readKeywordLine(r); header != "xref" {
 		panic (`"xref" not found at expected position`)
 	}
 
 	subsectionHeader := ""
 	for {
-		subsectionHeader,_ = ReadLine(r)
+		subsectionHeader,_ = readKeywordLine(r)
 		start,count := uint(0),uint(0)
 		n,err := fmt.Sscanf (subsectionHeader, "%d %d", &start, &count)
 		if (err != nil || n != 2) {
 			break;
 		}
-		readXrefSubsection(f.xref, r, start, count)
+		readXrefSubsection(xref, r, start, count, uint(limits.MaxObjects))
 	}
 
-	var err error
-	trailer,err = readTrailer (subsectionHeader, r, f)
+	trailer,err := readTrailer (subsectionHeader, r, mode, limits, f)
 	if err != nil {
 		panic (err)
-	} else if prevReference,ok := trailer.Get("Prev").(*IntNumeric); ok {
+	}
+	return trailer
+}
+
+func readOneXrefSection (f *file, location int64) (prevXref int, trailer Dictionary) {
+
+	if _,err := f.file.Seek (location, os.SEEK_SET); err != nil {
+		panic ("Seeking to xref position failed")
+	}
+
+	r := bufio.NewReader(f.file)
+	trailer = scanXrefSection(r, f.xref, f.parsingMode, f.limits, f)
+	if prevReference,ok := trailer.Get("Prev").(*IntNumeric); ok {
 		prevXref = prevReference.Value()
 	}
+	if position,err := f.file.Seek(0, os.SEEK_CUR); err == nil {
+		f.bytesRead(position - location - int64(r.Buffered()))
+	}
 	return
 }
 
@@ -539,6 +977,7 @@ func (f *file) release() {
 	f.xref.SetSize(0)
 	f.xref = nil
 	f.trailerDictionary = nil
+	f.revisions = nil
 	f.writer = nil
 	f.writeQueue = nil
 	f.writingFinished = nil
@@ -549,47 +988,218 @@ func (f *file) release() {
 func (f* file) gowriter () {
 	for entry := range f.writeQueue {
 		position,_ := f.Seek(0, os.SEEK_CUR)
+		<-f.semaphore
+		if entry.xrefEntry.generation != entry.generation || (!entry.xrefEntry.inUse && !entry.xrefEntry.reserved) {
+			// The object was deleted after it was queued.
+			if !entry.xrefEntry.inUse && !entry.xrefEntry.reserved {
+				putSerialization(entry.xrefEntry.serialization)
+				entry.xrefEntry.serialization = nil
+			}
+			f.semaphore<-true
+			if entry.stream != nil {
+				entry.stream.done <- true
+			}
+			continue
+		}
+		// An object rewritten while it was queued is queued
+		// twice, and the first entry writes the latest
+		// serialization, which leaves none for the second.
+		serialization := entry.xrefEntry.serialization
+		if serialization == nil && entry.stream == nil {
+			f.semaphore<-true
+			continue
+		}
 		entry.xrefEntry.setInUse(uint64(position))
+		f.semaphore<-true
 
-		<-f.semaphore
-		fmt.Fprintf(f.writer, "%d %d obj\n", entry.index, entry.xrefEntry.generation)
+		f.writeObjectHeader(ObjectNumber{entry.index, entry.xrefEntry.generation})
 
-		_,err := f.writer.Write(entry.xrefEntry.serialization)
+		_,err := f.writer.Write(serialization)
 		if err != nil {
 			panic(errors.New("Unable to write serialized object in file.writeObject()"))
 		}
-		f.writer.WriteString("\nendobj\n")
+		if entry.stream != nil {
+			f.writeStreamContents(entry.stream)
+		} else {
+			f.writer.WriteString("\nendobj\n")
+		}
 
 		// Make sure writer is flushed so the object can be
 		// read before serialization is nulled.
 		f.writer.Flush()
-		f.semaphore<-true
+		if f.progress != nil {
+			end,_ := f.file.Seek(0, os.SEEK_CUR)
+			f.progress.OnObjectWritten(ObjectNumber{entry.index, entry.xrefEntry.generation}, end-position)
+		}
 
-		entry.xrefEntry.serialization = nil
-		f.dirty = true
+		// If the object was rewritten while it was being
+		// written, the new serialization is left for the
+		// entry queued by the rewrite.
+		<-f.semaphore
+		if sameSerialization(entry.xrefEntry.serialization, serialization) {
+			putSerialization(serialization)
+			entry.xrefEntry.serialization = nil
+		}
+		f.semaphore<-true
+		if entry.stream != nil {
+			entry.stream.done <- true
+		}
 	}
 	f.writingFinished <- true
 }
 
+// sameSerialization() returns true if a and b share the same memory.
+func sameSerialization(a, b []byte) bool {
+	return len(a) == len(b) && (len(a) == 0 || &a[0] == &b[0])
+}
+
+// writeStreamContents() copies the contents of a stream through its
+// filters to the file, followed by the object holding its length.
+// It is called by gowriter().
+func (f *file) writeStreamContents(sw *streamWrite) {
+	counter := &countingWriter{w: f.writer}
+	w := sw.encoder(nopWriteCloser{counter})
+	_,sw.err = io.Copy(w, sw.contents)
+	if err := w.Close(); sw.err == nil {
+		sw.err = err
+	}
+	f.writer.WriteString("\nendstream\nendobj\n")
+
+	f.writer.Flush()
+	position,_ := f.file.Seek(0, os.SEEK_CUR)
+	<-f.semaphore
+	lengthEntry := (*f.xref.At(uint(sw.length.number))).(*xrefEntry)
+	lengthEntry.setInUse(uint64(position))
+	f.semaphore<-true
+	f.writeObjectHeader(sw.length)
+	writeInt(f.writer, counter.n)
+	f.writer.WriteString("\nendobj\n")
+}
+
+// writeObjectHeader() writes the line that begins object o.
+func (f *file) writeObjectHeader(o ObjectNumber) {
+	writeInt(f.writer, int64(o.number))
+	f.writer.WriteByte(' ')
+	writeInt(f.writer, int64(o.generation))
+	f.writer.WriteString(" obj\n")
+}
+
+// writeStream() writes a stream whose contents come from a reader
+// without holding them in memory.  It returns once the stream has been
+// written, so the reader may then be closed.
+func (f *file) writeStream(objectNumber ObjectNumber, entry *xrefEntry, s *stream) {
+	length := NewIndirect(f)
+	s = s.transcoded(f)
+	dictionary,encoder := s.encoder(f)
+	if f.security != nil {
+		dictionary,encoder = f.security.streamEncrypter(objectNumber, dictionary, encoder, f)
+	}
+	dictionary.Add("Length", length)
+	buffer := getSerializationBuffer()
+	dictionary.Serialize(buffer, f)
+	buffer.WriteString("\nstream\n")
+	entry.serialization = buffer.Bytes()
+
+	sw := &streamWrite{
+		contents: s.contents(),
+		encoder: encoder,
+		length: length.ObjectNumber(f),
+		done: make(chan bool)}
+	f.writeQueue<-writeQueueEntry{objectNumber.number, objectNumber.generation, entry, sw}
+	<-sw.done
+	if sw.err != nil {
+		panic(fmt.Sprintf("Unable to write contents of stream %d: %v", objectNumber.number, sw.err))
+	}
+}
+
+// streamWrittenByReference() returns the stream underlying object if
+// it is to be written by writeStream(), with its contents copied
+// through its filters to the file and its /Length written afterward:
+// either its contents come from a reader or the file was opened with
+// WithStreamingOutput().
+func (f *file) streamWrittenByReference(object Object) (*stream, bool) {
+	s,ok := streamWithSource(object)
+	if s != nil && f.streaming && !f.inspection {
+		ok = true
+	}
+	return s, ok
+}
+
+// streamWithSource() returns the stream underlying object if its
+// contents come from a reader.
+func streamWithSource(object Object) (*stream, bool) {
+	switch t := object.(type) {
+	case *stream:
+		return t, t.source != nil
+	case protectedStream:
+		return streamWithSource(t.s)
+	}
+	return nil, false
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n,err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error {
+	return nil
+}
+
 // Implements WriteObjectAt() in File interface
 func (f *file) WriteObjectAt(objectNumber ObjectNumber, object Object) {
+	if f.canceled() != nil {
+		return
+	}
 	xrefEntry := (*f.xref.At(uint(objectNumber.number))).(*xrefEntry)
 	if xrefEntry.generation != objectNumber.generation {
 		panic(fmt.Sprintf("Generation number mismatch: object %d current generation is %d but attempted to write %d",
 			objectNumber.number, xrefEntry.generation, objectNumber.generation))
 	}
-	buffer := new(bytes.Buffer)
+	object = f.beforeWrite(objectNumber, object)
+	if f.unchanged(objectNumber, xrefEntry, object) {
+		return
+	}
+	if f.writeObservers != nil {
+		defer f.afterWrite(objectNumber, object)
+	}
+	if f.cache != nil {
+		<-f.semaphore
+		f.cache.remove(objectNumber)
+		f.semaphore<-true
+	}
+	f.dirty = true
+	if s,ok := f.streamWrittenByReference(object); ok && objectNumber != f.encryptObjectNumber {
+		f.writeStream(objectNumber, xrefEntry, s)
+		return
+	}
+	if f.security != nil && objectNumber != f.encryptObjectNumber {
+		object = f.security.encryptObject(objectNumber, object, f)
+	}
+	buffer := getSerializationBuffer()
 	object.Serialize(buffer, f)
+	<-f.semaphore
 	xrefEntry.serialization = buffer.Bytes()
-	f.writeQueue<-writeQueueEntry{objectNumber.number,xrefEntry}
+	f.semaphore<-true
+	f.writeQueue<-writeQueueEntry{objectNumber.number,objectNumber.generation,xrefEntry,nil}
 }
 
 func (f *file) parseExistingFile() {
 	panic("Not implemented")
 }
 
-func writeHeader(w *bufio.Writer) {
-	_,err := w.WriteString("%PDF-1.4\n")
+func writeHeader(w *bufio.Writer, version Version) {
+	_,err := w.WriteString(header(version))
 	if (err != nil) {
 		panic("Unable to write PDF header")
 	}
@@ -626,21 +1236,65 @@ func (f *file) writeXref() {
 	f.writer.WriteString("xref\n")
 
 	for s, l := nextSegment(f.xref, 0); s < f.xref.Size(); s, l = nextSegment(f.xref, s+l) {
-		fmt.Fprintf(f.writer, "%d %d\n", s, l)
+		writeInt(f.writer, int64(s))
+		f.writer.WriteByte(' ')
+		writeInt(f.writer, int64(l))
+		f.writer.WriteByte('\n')
 		for i := s; i < s+l; i++ {
 			entry := (*f.xref.At(uint(i))).(*xrefEntry)
-			if entry.byteOffset == 0 && entry.generation != 65535 {
-				fmt.Fprintf(logger, "Warning: Object %d reserved but never written\n", i)
-			}
 			entry.Serialize(f.writer)
 		}
 	}
 }
 
+// validateTrailer() makes sure that the /Root and /Info entries of
+// the trailer refer to objects in the file before it is closed.  A
+// missing or invalid catalog is replaced by an empty one, and an
+// invalid /Info entry is removed.
+func (f *file) validateTrailer() {
+	if f.trailerDictionary.Get("Root") == nil {
+		f.SetCatalog(NewDictionary())
+		fmt.Fprintf(logger, "Warning: No document catalog has been specified.  Creating empty dictionary.  Use File.SetCatalog() to set one.\n")
+	} else if !f.validReference("Root") {
+		f.SetCatalog(NewDictionary())
+		fmt.Fprintf(logger, "Warning: The document catalog is not in the file.  Creating empty dictionary.\n")
+	}
+	if f.trailerDictionary.Get("Info") != nil && !f.validReference("Info") {
+		f.trailerDictionary.Remove("Info")
+		fmt.Fprintf(logger, "Warning: The document information dictionary is not in the file.  Removing it from the trailer.\n")
+	}
+}
+
+// validReference() returns true if the trailer entry named key is a
+// reference to an object that is in use, or is about to be written.
+func (f *file) validReference(key string) bool {
+	indirect,ok := f.trailerDictionary.Get(key).(Indirect)
+	if !ok || !indirect.BoundToFile(f) {
+		return false
+	}
+	o := indirect.ObjectNumber(f)
+	<-f.semaphore
+	defer func() { f.semaphore<-true }()
+	entry := f.entry(uint(o.number))
+	return entry != nil && entry.generation == o.generation && (entry.inUse || entry.reserved)
+}
+
+// writeTrailer() writes the trailer for the xref written at
+// xrefPosition, followed by the startxref pointer and the end-of-file
+// marker.  /Size is one greater than the highest object number, which
+// may not be less than in the previous trailer of a pre-existing
+// file.
 func (f *file) writeTrailer(xrefPosition int64) {
+	size := int(f.xref.Size())
+	if previous,ok := f.trailerDictionary.GetInt("Size"); ok && previous > size && f.originalSize != 0 {
+		size = previous
+	}
+	f.trailerDictionary.Add("Size", NewIntNumeric(size))
+
 	f.writer.WriteString("trailer\n")
 	f.trailerDictionary.Serialize(f.writer, f)
 	f.writer.WriteString("\nstartxref\n")
-	fmt.Fprintf(f.writer, "%d\n", xrefPosition)
+	writeInt(f.writer, xrefPosition)
+	f.writer.WriteByte('\n')
 	f.writer.WriteString("%%EOF\n")
 }
@@ -0,0 +1,74 @@
+package pdf
+
+import "fmt"
+
+// FileAttachmentIcon is the icon displayed for a file attachment
+// annotation.  Its value is the /Name used in the annotation
+// dictionary.
+type FileAttachmentIcon string
+
+const (
+	PushPinIcon FileAttachmentIcon = "PushPin"
+	PaperclipIcon FileAttachmentIcon = "Paperclip"
+	GraphIcon FileAttachmentIcon = "Graph"
+	TagIcon FileAttachmentIcon = "Tag" )
+
+// Icon drawings in a unit square.  The appearance stream scales them
+// to the annotation rectangle.
+var fileAttachmentIconPaths = map[FileAttachmentIcon]string {
+	PushPinIcon: "0.3 0.55 m 0.7 0.55 l 0.6 0.65 l 0.6 0.85 l 0.7 0.95 l 0.3 0.95 l 0.4 0.85 l 0.4 0.65 l h B " +
+		"0.5 0.55 m 0.5 0.05 l S",
+	PaperclipIcon: "0.4 0.25 m 0.4 0.75 l 0.4 0.88 0.65 0.88 0.65 0.75 c 0.65 0.15 l " +
+		"0.65 0 0.25 0 0.25 0.15 c 0.25 0.8 l 0.25 1 0.8 1 0.8 0.8 c 0.8 0.3 l S",
+	GraphIcon: "0.1 0.95 m 0.1 0.1 l 0.95 0.1 l S " +
+		"0.2 0.1 0.15 0.3 re 0.45 0.1 0.15 0.6 re 0.7 0.1 0.15 0.45 re B",
+	TagIcon: "0.05 0.5 m 0.35 0.85 l 0.95 0.85 l 0.95 0.15 l 0.35 0.15 l h B " +
+		"0.3 0.5 m 0.2 0.5 l S"}
+
+// appearance() constructs a form XObject that draws the icon in a
+// box of the passed width and height, which are written with the
+// passed precision.
+func (icon FileAttachmentIcon) appearance(sf *StreamFactory, width, height float64, precision int) Stream {
+	path,ok := fileAttachmentIconPaths[icon]
+	if !ok {
+		path = fileAttachmentIconPaths[PushPinIcon]
+	}
+
+	form := sf.New()
+	form.Add("Type", NewName("XObject"))
+	form.Add("Subtype", NewName("Form"))
+	form.Add("BBox", NewRectangle(0, 0, width, height))
+	fmt.Fprintf(form, "q %s 0 0 %s 0 0 cm 0.04 w 0.85 g 0 G %s Q", FormatReal(width, precision), FormatReal(height, precision), path)
+	return form
+}
+
+// AttachFile() embeds a file in the document and adds a file
+// attachment annotation for it to the page.  The annotation displays
+// the icon in the rectangle with lower-left corner (llx, lly) and
+// upper-right corner (urx, ury).  Unlike Document.Attach(), the
+// attachment is not added to the document's list of attachments.
+func (p *Page) AttachFile(a Attachment, icon FileAttachmentIcon, llx, lly, urx, ury float64) Indirect {
+	if p.dictionary == nil {
+		panic ("AttachFile() called on closed page")
+	}
+	fileSpec := a.write(p.streamFactory, p.fileList...)
+
+	appearance := NewDictionary()
+	appearance.Add("N", NewIndirect(p.fileList...).Write(icon.appearance(p.streamFactory, urx-llx, ury-lly, realPrecision(p.fileList...))))
+
+	contents := a.Description
+	if contents == "" {
+		contents = a.Name
+	}
+
+	annotation := NewDictionary()
+	annotation.Add("Subtype", NewName("FileAttachment"))
+	annotation.Add("Rect", NewRectangle(llx, lly, urx, ury))
+	annotation.Add("FS", fileSpec)
+	annotation.Add("Name", NewName(string(icon)))
+	annotation.Add("Contents", NewTextString(contents))
+	// Print flag
+	annotation.Add("F", NewIntNumeric(4))
+	annotation.Add("AP", appearance)
+	return p.AddAnnotation(annotation)
+}
@@ -0,0 +1,85 @@
+package pdf
+
+import (
+	"bytes"
+	"crypto/md5"
+	"fmt"
+	"io"
+	"os" )
+
+// ID() returns the two elements of the file identifier in the
+// trailer's /ID array.  The permanent identifier is assigned when the
+// file is first written and is preserved by incremental updates; the
+// changing identifier is replaced whenever the file is updated.  Both
+// are nil if the file has no identifier.
+func (f *file) ID() (permanent, changing []byte) {
+	id := f.trailerDictionary.GetArray("ID")
+	if id == nil || id.Size() != 2 {
+		return nil, nil
+	}
+	first,ok1 := id.At(0).(ProtectString)
+	second,ok2 := id.At(1).(ProtectString)
+	if !ok1 || !ok2 {
+		return nil, nil
+	}
+	return first.Bytes(), second.Bytes()
+}
+
+// initializeID() gives a file that has no /ID array, whether new or
+// pre-existing, one whose elements are both a newly generated
+// identifier.
+func (f *file) initializeID() {
+	if permanent,_ := f.ID(); permanent != nil {
+		return
+	}
+	id := f.newIdentifier()
+	f.setID(id, id)
+}
+
+// updateID() replaces the changing identifier of a pre-existing file
+// as it is updated, keeping the permanent one.  The identifier of a
+// new file is recomputed from its contents if its output is
+// deterministic.
+func (f *file) updateID() {
+	if f.originalSize == 0 {
+		if f.deterministic {
+			id := f.newIdentifier()
+			f.setID(id, id)
+		}
+		return
+	}
+	permanent,_ := f.ID()
+	f.setID(permanent, f.newIdentifier())
+}
+
+func (f *file) setID(permanent, changing []byte) {
+	idArray := NewArray()
+	for _,id := range [][]byte{permanent, changing} {
+		s := NewBinaryString(id)
+		s.SetSerializer(HexStringSerializer)
+		idArray.Add(s)
+	}
+	f.trailerDictionary.Add("ID", idArray)
+}
+
+// newIdentifier() computes an identifier as suggested by the PDF
+// specification: an MD5 digest of the current time, the file's name,
+// its size, and the contents of the trailer, which refers to the
+// document information dictionary.  If the output is deterministic,
+// the name is omitted in favor of the contents of the file, where
+// they can be read.
+func (f *file) newIdentifier() []byte {
+	digest := md5.New()
+	fmt.Fprintf(digest, "%d", f.now().UnixNano())
+	size := f.Tell()
+	if f.deterministic {
+		io.Copy(digest, io.NewSectionReader(f.file, 0, size))
+	} else if named,ok := f.file.(*os.File); ok {
+		digest.Write([]byte(named.Name()))
+	}
+	fmt.Fprintf(digest, "%d", size)
+	trailer := new(bytes.Buffer)
+	f.trailerDictionary.Serialize(trailer, f)
+	digest.Write(trailer.Bytes())
+	return digest.Sum(nil)
+}
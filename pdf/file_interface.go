@@ -1,14 +1,5 @@
 package pdf
 
-type ObjectNumber struct {
-	number     uint32
-	generation uint16
-}
-
-func NewObjectNumber(number uint32, generation uint16) ObjectNumber {
-	return ObjectNumber{number,generation}
-}
-
 type File interface {
 	// WriteObject() adds the passed object to the File.  The
 	// returned indirect reference may be used for backward
@@ -44,9 +35,10 @@ type File interface {
 	// info dictionary
 	Info() Dictionary
 
-	// Catalog() returns a copy of the Info dictionary.  Caller
-	// may modify the copy and use SetCatalog() to replace the
-	// file's info dictionary
+	// Catalog() returns a copy of the catalog dictionary.
+	// Caller may modify the copy and use SetCatalog() to replace
+	// the file's catalog.  ReadCatalog() and WriteCatalog() do
+	// the same with a typed Catalog.
 	Catalog() ProtectedDictionary
 
 	// SetCatalog() sets the catalog dictionary
@@ -69,4 +61,17 @@ type File interface {
 
 	// Closed() returns true if the file has been closed.
 	Closed() bool
+
+	// Permissions() returns the operations permitted by the
+	// file's security handler.  Unencrypted files permit
+	// everything.
+	Permissions() Permissions
+
+	// ID() returns the permanent and changing elements of the
+	// file identifier, or nil if the file has none.
+	ID() (permanent, changing []byte)
+
+	// Revisions() returns the revisions of a pre-existing file,
+	// the original document followed by each incremental update.
+	Revisions() []Revision
 }
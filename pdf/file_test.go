@@ -1,9 +1,19 @@
 package pdf_test
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
 	"os"
+	"regexp"
+	"strconv"
 	"strings"
+	"sync"
 	"testing"
+	"testing/fstest"
+	"testing/iotest"
 	"github.com/mawicks/PDFiG/pdf" )
 
 func ExampleFile_creation() {
@@ -61,3 +71,1126 @@ func TestPDFReadLine (t *testing.T) {
 	}
 }
 
+
+func TestEncryption(t *testing.T) {
+	filename := "/tmp/test-encrypted-file.pdf"
+	os.Remove(filename)
+
+	permissions := pdf.Permissions{Print: true, ExtractForAccessibility: true}
+	f,_,err := pdf.OpenFile(filename, os.O_RDWR|os.O_CREATE,
+		pdf.WithEncryption("user", "owner", permissions))
+	if err != nil {
+		t.Fatalf(`OpenFile() failed: %v`, err)
+	}
+	d := pdf.NewDictionary()
+	d.Add("Secret", pdf.NewTextString("The quick brown fox"))
+	s := pdf.NewStream()
+	s.Write([]byte("BT (Hidden text) Tj ET"))
+	d.Add("Stream", f.WriteObject(s))
+	objectNumber := f.WriteObject(d).ObjectNumber(f)
+	f.Close()
+
+	// Without a password, the file cannot be opened.
+	if _,_,err = pdf.OpenFile(filename, os.O_RDONLY); err != pdf.ErrIncorrectPassword {
+		t.Errorf(`OpenFile() without a password returned %v; expected ErrIncorrectPassword`, err)
+	}
+
+	check := func(password string, expected pdf.Permissions) {
+		attempts := 0
+		f,_,err := pdf.OpenFile(filename, os.O_RDONLY,
+			pdf.WithPasswordCallback(func(attempt int) (string, bool) {
+				attempts = attempt
+				if attempt == 1 {
+					return "wrong", true
+				}
+				return password, attempt == 2
+			}))
+		if err != nil {
+			t.Fatalf(`OpenFile() with password "%s" failed: %v`, password, err)
+		}
+		if attempts != 2 {
+			t.Errorf(`Password callback called %d times; expected 2`, attempts)
+		}
+		if p := f.Permissions(); p != expected {
+			t.Errorf(`Permissions() with password "%s" returned %+v; expected %+v`, password, p, expected)
+		}
+		o,err := f.Object(objectNumber)
+		if err != nil {
+			t.Fatalf(`Object() failed: %v`, err)
+		}
+		d := o.(pdf.Dictionary)
+		if secret,_ := d.GetString("Secret"); string(secret) != "The quick brown fox" {
+			t.Errorf(`Decrypted string is "%s"`, pdf.AsciiFromBytes(secret))
+		}
+		contents := new(bytes.Buffer)
+		contents.ReadFrom(d.GetStream("Stream").Reader())
+		if contents.String() != "BT (Hidden text) Tj ET" {
+			t.Errorf(`Decrypted stream is "%s"`, pdf.AsciiFromBytes(contents.Bytes()))
+		}
+		f.Close()
+	}
+	check("user", permissions)
+	check("owner", pdf.AllPermissions())
+}
+
+// TestStandardSecurityHandler reads files encrypted by
+// testdata/encrypted/generate.py, an implementation of the standard
+// security handler written independently of this package.
+func TestStandardSecurityHandler(t *testing.T) {
+	permissions := pdf.Permissions{Print: true, ExtractForAccessibility: true}
+	for _,name := range []string{"r2-rc4-40", "r3-rc4-40", "r3-rc4-128", "r4-rc4-128", "r4-aesv2", "r6-aesv3"} {
+		filename := "testdata/encrypted/" + name + ".pdf"
+		if _,_,err := pdf.OpenFile(filename, os.O_RDONLY); err != pdf.ErrIncorrectPassword {
+			t.Errorf(`OpenFile("%s") without a password returned %v; expected ErrIncorrectPassword`, filename, err)
+		}
+		for password,expected := range map[string]pdf.Permissions{"user": permissions, "owner": pdf.AllPermissions()} {
+			f,_,err := pdf.OpenFile(filename, os.O_RDONLY,
+				pdf.WithPasswordCallback(func(attempt int) (string, bool) {
+					return password, attempt == 1
+				}))
+			if err != nil {
+				t.Errorf(`OpenFile("%s") with password "%s" failed: %v`, filename, password, err)
+				continue
+			}
+			if p := f.Permissions(); p != expected {
+				t.Errorf(`%s: Permissions() with password "%s" returned %+v; expected %+v`, name, password, p, expected)
+			}
+			info,_ := f.Trailer().GetDictionary("Info").Dereference().(pdf.ProtectedDictionary)
+			if title,_ := info.GetString("Title"); string(title) != "Known answer" {
+				t.Errorf(`%s: Decrypted /Title is "%s"`, name, pdf.AsciiFromBytes(title))
+			}
+			o,err := f.Object(pdf.NewObjectNumber(4, 0))
+			if err != nil {
+				t.Errorf(`%s: Object(4) failed: %v`, name, err)
+			} else if s,ok := o.(pdf.ProtectedStream); !ok {
+				t.Errorf(`%s: Object(4) is %v; expected a stream`, name, o)
+			} else {
+				contents,err := ioutil.ReadAll(s.Reader())
+				if err != nil || string(contents) != "BT /F1 24 Tf 72 720 Td (Encrypted by an independent implementation) Tj ET" {
+					t.Errorf(`%s: Decrypted stream is "%s": %v`, name, pdf.AsciiFromBytes(contents), err)
+				}
+			}
+			f.Close()
+		}
+	}
+}
+
+func TestCopyObject(t *testing.T) {
+	src,_,_ := pdf.OpenFile("/tmp/test-copy-source.pdf", os.O_RDWR|os.O_CREATE|os.O_TRUNC)
+	dst,_,_ := pdf.OpenFile("/tmp/test-copy.pdf", os.O_RDWR|os.O_CREATE|os.O_TRUNC)
+
+	// Two dictionaries that refer to each other and share an array.
+	shared := src.WriteObject(pdf.NewArray())
+	a := pdf.NewIndirect(src)
+	b := pdf.NewIndirect(src)
+	da := pdf.NewDictionary()
+	da.Add("Other", b)
+	da.Add("Shared", shared)
+	db := pdf.NewDictionary()
+	db.Add("Other", a)
+	db.Add("Shared", shared)
+	a.Write(da)
+	b.Write(db)
+
+	copier := pdf.NewObjectCopier(dst, src)
+	copyA := copier.CopyReference(a.ObjectNumber(src))
+	if again := copier.CopyReference(a.ObjectNumber(src)); again != copyA {
+		t.Error(`CopyReference() copied the same object twice`)
+	}
+	copyB,exists := copier.Translation(b.ObjectNumber(src))
+	if !exists {
+		t.Fatal(`CopyReference() did not copy a referenced object`)
+	}
+
+	copiedA := copyA.Dereference().(pdf.Dictionary)
+	copiedB := copyB.Dereference().(pdf.Dictionary)
+	if copiedA.GetIndirect("Other").ObjectNumber(dst) != copyB.ObjectNumber(dst) ||
+		copiedB.GetIndirect("Other").ObjectNumber(dst) != copyA.ObjectNumber(dst) {
+		t.Error(`Copied references do not refer to the copies`)
+	}
+	if copiedA.GetIndirect("Shared").ObjectNumber(dst) != copiedB.GetIndirect("Shared").ObjectNumber(dst) {
+		t.Error(`Shared object was copied more than once`)
+	}
+
+	src.Close()
+	dst.Close()
+}
+
+// failingStreamFile is a File in which the object numbered failing is
+// a stream whose contents can't be read in full.
+type failingStreamFile struct {
+	pdf.File
+	failing pdf.ObjectNumber
+}
+
+func (f failingStreamFile) Object(o pdf.ObjectNumber) (pdf.Object, error) {
+	if o == f.failing {
+		return pdf.NewStreamFromReader(iotest.TimeoutReader(strings.NewReader("contents"))), nil
+	}
+	return f.File.Object(o)
+}
+
+func TestDeduplicateUnreadableStream(t *testing.T) {
+	src := pdf.NewMemoryFile()
+	s := pdf.NewStream()
+	s.Write([]byte("contents"))
+	readable := src.WriteObject(s).ObjectNumber(src)
+	failing := src.WriteObject(pdf.NewNull()).ObjectNumber(src)
+
+	dst := pdf.NewMemoryFile()
+	copier := pdf.NewObjectCopier(dst, failingStreamFile{src, failing})
+	copier.Deduplicate()
+	copied := copier.CopyReference(readable)
+	if copier.CopyReference(failing) == copied {
+		t.Error(`A stream that couldn't be read was merged with another`)
+	}
+	if err := copier.Err(); err != iotest.ErrTimeout {
+		t.Errorf(`Err() returned %v; expected the error reading the stream`, err)
+	}
+	dst.Close()
+	src.Close()
+}
+
+func TestObjectCache(t *testing.T) {
+	filename := "/tmp/test-object-cache.pdf"
+	os.Remove(filename)
+
+	f,_,_ := pdf.OpenFile(filename, os.O_RDWR|os.O_CREATE)
+	d := pdf.NewDictionary()
+	d.Add("Value", pdf.NewIntNumeric(1))
+	small := f.WriteObject(d).ObjectNumber(f)
+	s := pdf.NewStream()
+	s.Write(bytes.Repeat([]byte("x"), 1000))
+	large := f.WriteObject(s).ObjectNumber(f)
+	f.Close()
+
+	f,_,_ = pdf.OpenFile(filename, os.O_RDWR, pdf.WithObjectCache(2000))
+	for i:=0; i<3; i++ {
+		o,_ := f.Object(small)
+		if v,_ := o.(pdf.Dictionary).GetInt("Value"); v != 1 {
+			t.Errorf(`Read %d of cached object returned %d; expected 1`, i, v)
+		}
+		// Changes to the returned object must not affect the cache.
+		o.(pdf.Dictionary).Add("Value", pdf.NewIntNumeric(2))
+		f.Object(large)
+	}
+	statistics := f.CacheStatistics()
+	if statistics.Hits != 2 || statistics.Size == 0 || statistics.Size > 500 {
+		t.Errorf(`Unexpected cache statistics %+v`, statistics)
+	}
+
+	// Rewriting an object evicts it.
+	f.WriteObjectAt(small, pdf.NewIntNumeric(3))
+	if o,_ := f.Object(small); o == nil || o.(*pdf.IntNumeric).Value() != 3 {
+		t.Errorf(`Rewritten object read as %v`, o)
+	}
+	f.Close()
+}
+
+func TestStreamFromReader(t *testing.T) {
+	filename := "/tmp/test-stream-from-reader.pdf"
+	copyname := "/tmp/test-stream-from-reader-copy.pdf"
+	os.Remove(filename)
+	os.Remove(copyname)
+
+	contents := bytes.Repeat([]byte("0123456789abcdef"), 200000)
+	f,_,_ := pdf.OpenFile(filename, os.O_RDWR|os.O_CREATE)
+	s := pdf.NewStreamFromReader(bytes.NewReader(contents))
+	s.AddFilter(new(pdf.FlateFilter))
+	if _,err := s.Write([]byte("x")); err == nil {
+		t.Errorf(`Write() to stream from reader succeeded`)
+	}
+	plain := f.WriteObject(pdf.NewStreamFromReader(bytes.NewReader(contents))).ObjectNumber(f)
+	compressed := f.WriteObject(s).ObjectNumber(f)
+	f.Close()
+
+	f,_,_ = pdf.OpenFile(filename, os.O_RDONLY)
+	g,_,_ := pdf.OpenFile(copyname, os.O_RDWR|os.O_CREATE)
+	var copies []pdf.ObjectNumber
+	for _,o := range []pdf.ObjectNumber{plain, compressed} {
+		object,_ := f.Object(o)
+		stream,ok := object.(pdf.Stream)
+		if !ok {
+			t.Fatalf(`Object %v read as %v; expected a stream`, o, object)
+		}
+		if _,ok := stream.Dictionary().Get("Length").(pdf.ProtectedIndirect); !ok {
+			t.Errorf(`/Length of stream %v is not an indirect reference`, o)
+		}
+		var buffer bytes.Buffer
+		buffer.ReadFrom(stream.Reader())
+		if !bytes.Equal(buffer.Bytes(), contents) {
+			t.Errorf(`Contents of stream %v read back incorrectly`, o)
+		}
+		copies = append(copies, g.WriteObject(stream).ObjectNumber(g))
+	}
+	g.Close()
+	f.Close()
+
+	// A large stream read from a file is copied without decoding it.
+	g,_,_ = pdf.OpenFile(copyname, os.O_RDONLY)
+	object,_ := g.Object(copies[1])
+	var buffer bytes.Buffer
+	buffer.ReadFrom(object.(pdf.Stream).Reader())
+	if !bytes.Equal(buffer.Bytes(), contents) {
+		t.Errorf(`Contents of copied stream read back incorrectly`)
+	}
+	g.Close()
+
+	// A reader that fails isn't taken for the end of the contents.
+	failing := pdf.NewStreamFromReader(iotest.TimeoutReader(bytes.NewReader(contents)))
+	if _,err := pdf.ObjectToJSON(failing); err != iotest.ErrTimeout {
+		t.Errorf(`ObjectToJSON() of a stream whose reader fails returned %v`, err)
+	}
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error(`Serialize() of a stream whose reader fails didn't panic`)
+			}
+		}()
+		failing = pdf.NewStreamFromReader(iotest.TimeoutReader(bytes.NewReader(contents)))
+		failing.Serialize(&buffer)
+	}()
+}
+
+func TestConcurrentObject(t *testing.T) {
+	filename := "/tmp/test-concurrent-object.pdf"
+	os.Remove(filename)
+
+	f,_,_ := pdf.OpenFile(filename, os.O_RDWR|os.O_CREATE)
+	var objects []pdf.ObjectNumber
+	for i:=0; i<200; i++ {
+		d := pdf.NewDictionary()
+		d.Add("Value", pdf.NewIntNumeric(i))
+		objects = append(objects, f.WriteObject(d).ObjectNumber(f))
+	}
+	f.Close()
+
+	f,_,_ = pdf.OpenFile(filename, os.O_RDONLY, pdf.WithObjectCache(2000))
+	var wg sync.WaitGroup
+	for g:=0; g<8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i:=range objects {
+				i = (i + 25*g) % len(objects)
+				o,err := f.Object(objects[i])
+				if err != nil {
+					t.Errorf(`Object(%v) failed: %v`, objects[i], err)
+					continue
+				}
+				if v,_ := o.(pdf.Dictionary).GetInt("Value"); v != i {
+					t.Errorf(`Object(%v) has value %d; expected %d`, objects[i], v, i)
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	// A reference past the end of the xref is to the null object
+	// and mustn't keep later reads from proceeding.
+	if o,err := f.Object(pdf.NewObjectNumber(90000,0)); err != nil || o != pdf.NewNull() {
+		t.Errorf(`Object() of a missing object returned %v, %v; expected null`, o, err)
+	}
+	if _,err := f.Object(objects[0]); err != nil {
+		t.Errorf(`Object(%v) after reading a missing object failed: %v`, objects[0], err)
+	}
+	f.Close()
+}
+
+func TestFileFromStreams(t *testing.T) {
+	// A new file can be written without seeking.
+	var buffer bytes.Buffer
+	f,err := pdf.NewFileFromWriter(&buffer)
+	if err != nil {
+		t.Fatalf(`NewFileFromWriter() failed: %v`, err)
+	}
+	d := pdf.NewDictionary()
+	d.Add("Value", pdf.NewIntNumeric(42))
+	o := f.WriteObject(d).ObjectNumber(f)
+	catalog := pdf.NewDictionary()
+	catalog.Add("Type", pdf.NewName("Catalog"))
+	f.SetCatalog(catalog)
+	f.Close()
+	contents := buffer.Bytes()
+
+	check := func(description string, f pdf.File) {
+		if object,err := f.Object(o); err != nil {
+			t.Errorf(`%s: Object() failed: %v`, description, err)
+		} else if v,_ := object.(pdf.Dictionary).GetInt("Value"); v != 42 {
+			t.Errorf(`%s: Object() returned %v`, description, object)
+		}
+		if catalog := f.Catalog(); catalog == nil || !catalog.CheckNameValue("Type", "Catalog") {
+			t.Errorf(`%s: catalog not found`, description)
+		}
+	}
+
+	r,err := pdf.NewFileFromReader(bytes.NewReader(contents))
+	if err != nil {
+		t.Fatalf(`NewFileFromReader() failed: %v`, err)
+	}
+	check("bytes.Reader", r)
+	r.Close()
+
+	// A reader without ReadAt() is shared by seeking.
+	r,_ = pdf.NewFileFromReader(struct{io.ReadSeeker}{bytes.NewReader(contents)})
+	check("io.ReadSeeker", r)
+	r.Close()
+
+	if _,err = pdf.NewFileFromReader(bytes.NewReader(nil)); err == nil {
+		t.Errorf(`NewFileFromReader() of empty reader succeeded`)
+	}
+
+	fsys := fstest.MapFS{"test.pdf": &fstest.MapFile{Data: contents}}
+	r,err = pdf.OpenFileFS(fsys, "test.pdf")
+	if err != nil {
+		t.Fatalf(`OpenFileFS() failed: %v`, err)
+	}
+	check("fs.FS", r)
+	r.Close()
+
+	// Changes are appended to a pre-existing file.
+	filename := "/tmp/test-file-from-streams.pdf"
+	os.WriteFile(filename, contents, 0666)
+	osFile,_ := os.OpenFile(filename, os.O_RDWR, 0666)
+	rws,exists,err := pdf.NewFileFromReadWriteSeeker(struct{io.ReadWriteSeeker}{osFile})
+	if err != nil || !exists {
+		t.Fatalf(`NewFileFromReadWriteSeeker() returned %v, %v`, exists, err)
+	}
+	check("io.ReadWriteSeeker", rws)
+	o2 := rws.WriteObject(pdf.NewIntNumeric(7)).ObjectNumber(rws)
+	rws.Close()
+	osFile.Close()
+
+	updated,_ := os.ReadFile(filename)
+	if !bytes.HasPrefix(updated, contents) {
+		t.Errorf(`Update was not appended`)
+	}
+	r,_ = pdf.NewFileFromReader(bytes.NewReader(updated))
+	check("updated file", r)
+	if object,_ := r.Object(o2); object == nil || object.(*pdf.IntNumeric).Value() != 7 {
+		t.Errorf(`Appended object read as %v`, object)
+	}
+	r.Close()
+}
+
+// manyObjectsFile() returns a PDF file holding n small dictionaries,
+// numbered from 1, and a catalog.
+func manyObjectsFile(n int) []byte {
+	f := pdf.NewMemoryFile()
+	for i:=0; i<n; i++ {
+		d := pdf.NewDictionary()
+		d.Add("Index", pdf.NewIntNumeric(i))
+		d.Add("Name", pdf.NewTextString(fmt.Sprintf("Object number %d", i+1)))
+		f.WriteObject(d)
+	}
+	f.SetCatalog(pdf.NewDictionary())
+	f.Close()
+	return f.Bytes()
+}
+
+func TestBlockCache(t *testing.T) {
+	contents := manyObjectsFile(200)
+	direct,_ := pdf.NewFileFromReader(bytes.NewReader(contents), pdf.WithObjectCache(0))
+	defer direct.Close()
+	// Blocks much smaller than objects are read repeatedly and
+	// evicted, and every object spans several of them.
+	cached,_ := pdf.NewFileFromReader(bytes.NewReader(contents), pdf.WithObjectCache(0), pdf.WithBlockCache(16, 3))
+	defer cached.Close()
+	for i:=uint32(1); i<=200; i++ {
+		o := pdf.NewObjectNumber(i, 0)
+		expected,_ := direct.Object(o)
+		object,err := cached.Object(o)
+		if err != nil || toString(object) != toString(expected) {
+			t.Errorf(`Object %d read through block cache as %s (%v) rather than %s`, i, toString(object), err, toString(expected))
+		}
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error(`WithBlockCache() accepted a block size of 0`)
+		}
+	}()
+	pdf.WithBlockCache(0, 16)
+}
+
+// BenchmarkObject compares reading the objects of a file on disk with
+// ReadAt(), by seeking the file, and through a block cache.
+func BenchmarkObject(b *testing.B) {
+	filename := "/tmp/test-benchmark-object.pdf"
+	os.WriteFile(filename, manyObjectsFile(1000), 0666)
+	for _,c := range []struct {
+		name string
+		open func() pdf.File
+	}{
+		{"ReadAt", func() pdf.File {
+			f,_,_ := pdf.OpenFile(filename, os.O_RDONLY, pdf.WithObjectCache(0))
+			return f
+		}},
+		{"Seek", func() pdf.File {
+			osFile,_ := os.Open(filename)
+			f,_ := pdf.NewFileFromReader(struct{io.ReadSeeker}{osFile}, pdf.WithObjectCache(0))
+			return f
+		}},
+		{"BlockCache", func() pdf.File {
+			f,_,_ := pdf.OpenFile(filename, os.O_RDONLY, pdf.WithObjectCache(0), pdf.WithBlockCache(4096, 64))
+			return f
+		}}} {
+		b.Run(c.name, func(b *testing.B) {
+			f := c.open()
+			defer f.Close()
+			b.ResetTimer()
+			for i:=0; i<b.N; i++ {
+				f.Object(pdf.NewObjectNumber(uint32(i%1000+1), 0))
+			}
+		})
+	}
+}
+
+// seeklessWriter fails the test if it is used as an io.Seeker.
+type seeklessWriter struct {
+	bytes.Buffer
+	t *testing.T
+}
+
+func (w *seeklessWriter) Seek(offset int64, whence int) (int64, error) {
+	w.t.Error(`Seek() called on streaming output`)
+	return 0, errors.New(`Seek() not supported`)
+}
+
+func TestStreamingOutput(t *testing.T) {
+	w := &seeklessWriter{t: t}
+	f,err := pdf.NewFileFromWriter(w, pdf.WithStreamingOutput())
+	if err != nil {
+		t.Fatalf(`NewFileFromWriter() failed: %v`, err)
+	}
+	doc := pdf.NewDocumentFromFile(f)
+	page := doc.NewPage()
+	fmt.Fprint(page, strings.Repeat("0 0 m 1 1 l S ", 100))
+	doc.Close()
+	contents := w.Bytes()
+
+	// Each stream is followed by the object holding its length.
+	if !regexp.MustCompile(`/Length \d+ 0 R>>\nstream\n`).Match(contents) {
+		t.Errorf(`Streaming output has no indirect /Length`)
+	}
+	if regexp.MustCompile(`/Length \d+>>`).Match(contents) {
+		t.Errorf(`Streaming output has a direct /Length`)
+	}
+
+	r,err := pdf.NewFileFromReader(bytes.NewReader(contents))
+	if err != nil {
+		t.Fatalf(`NewFileFromReader() failed: %v`, err)
+	}
+	defer r.Close()
+	read := pdf.NewDocumentFromFile(r)
+	if read.PageCount() != 1 {
+		t.Fatalf(`Streaming output has %d pages`, read.PageCount())
+	}
+	if data,_ := ioutil.ReadAll(read.Page(0).Reader()); string(data) != strings.Repeat("0 0 m 1 1 l S ", 100) {
+		t.Errorf(`Page of streaming output contains %q`, data)
+	}
+}
+
+func ExampleNewFileFromWriter() {
+	var buffer bytes.Buffer
+	f,_ := pdf.NewFileFromWriter(&buffer)
+	doc := pdf.NewDocumentFromFile(f)
+	doc.NewPage()
+	doc.Close()
+
+	f,_ = pdf.NewFileFromReader(bytes.NewReader(buffer.Bytes()))
+	fmt.Println(f.Catalog().GetDictionary("Pages").GetInt("Count"))
+	f.Close()
+	// Output: 1 true
+}
+
+func TestEncryptedStreamFromReader(t *testing.T) {
+	f := pdf.NewMemoryFile(pdf.WithEncryption("", "owner", pdf.AllPermissions()))
+	var contents [][]byte
+	var objects []pdf.ObjectNumber
+	for _,n := range []int{0, 17, 32, 100000} {
+		b := bytes.Repeat([]byte("0123456789abcdef"), n/16+1)[:n]
+		s := pdf.NewStreamFromReader(bytes.NewReader(b))
+		if n > 1000 {
+			s.AddFilter(new(pdf.FlateFilter))
+		}
+		contents = append(contents, b)
+		objects = append(objects, f.WriteObject(s).ObjectNumber(f))
+	}
+	f.Close()
+
+	r,err := pdf.NewFileFromReader(bytes.NewReader(f.Bytes()))
+	if err != nil {
+		t.Fatalf(`NewFileFromReader() failed: %v`, err)
+	}
+	defer r.Close()
+	if bytes.Contains(f.Bytes(), []byte("0123456789abcdef")) {
+		t.Errorf(`Stream contents were written unencrypted`)
+	}
+	for i,o := range objects {
+		object,err := r.Object(o)
+		if err != nil {
+			t.Fatalf(`Object(%v) failed: %v`, o, err)
+		}
+		stream,ok := object.(pdf.ProtectedStream)
+		if !ok {
+			t.Fatalf(`Object %v read as %v; expected a stream`, o, object)
+		}
+		if _,ok := stream.Dictionary().Get("Length").(pdf.ProtectedIndirect); !ok {
+			t.Errorf(`/Length of stream %v is not an indirect reference`, o)
+		}
+		data,err := ioutil.ReadAll(stream.Reader())
+		if err != nil {
+			t.Errorf(`Reading encrypted stream %v failed: %v`, o, err)
+		} else if !bytes.Equal(data, contents[i]) {
+			t.Errorf(`Encrypted stream of %d bytes read back as %d different bytes`, len(contents[i]), len(data))
+		}
+	}
+}
+
+func TestMemoryFile(t *testing.T) {
+	f := pdf.NewMemoryFile()
+	d := pdf.NewDictionary()
+	d.Add("Value", pdf.NewIntNumeric(42))
+	o := f.WriteObject(d).ObjectNumber(f)
+	for i:=0; i<20; i++ {
+		f.WriteObject(pdf.NewIntNumeric(i))
+	}
+	if object,_ := f.Object(o); object == nil {
+		t.Errorf(`Object() of written object failed`)
+	} else if v,_ := object.(pdf.Dictionary).GetInt("Value"); v != 42 {
+		t.Errorf(`Object() returned %v`, object)
+	}
+	catalog := pdf.NewDictionary()
+	catalog.Add("Type", pdf.NewName("Catalog"))
+	f.SetCatalog(catalog)
+	f.Close()
+
+	contents := f.Bytes()
+	if !bytes.HasPrefix(contents, []byte("%PDF-")) || !bytes.HasSuffix(contents, []byte("%%EOF\n")) {
+		t.Errorf(`Bytes() returned an incomplete PDF`)
+	}
+
+	g,err := pdf.NewMemoryFileFromBytes(contents)
+	if err != nil {
+		t.Fatalf(`NewMemoryFileFromBytes() failed: %v`, err)
+	}
+	o2 := g.WriteObject(pdf.NewIntNumeric(7)).ObjectNumber(g)
+	g.Close()
+	if updated := g.Bytes(); !bytes.HasPrefix(updated, contents) || len(updated) <= len(contents) {
+		t.Errorf(`Update was not appended`)
+	}
+
+	r,_ := pdf.NewFileFromReader(bytes.NewReader(g.Bytes()))
+	if object,_ := r.Object(o); object == nil {
+		t.Errorf(`Object() of original object failed`)
+	}
+	if object,_ := r.Object(o2); object == nil || object.(*pdf.IntNumeric).Value() != 7 {
+		t.Errorf(`Appended object read as %v`, object)
+	}
+	r.Close()
+}
+
+func TestFileID(t *testing.T) {
+	f := pdf.NewMemoryFile()
+	permanent,changing := f.ID()
+	if len(permanent) != 16 || !bytes.Equal(permanent, changing) {
+		t.Errorf(`New file has ID %x %x`, permanent, changing)
+	}
+	f.Close()
+
+	g,err := pdf.NewMemoryFileFromBytes(f.Bytes())
+	if err != nil {
+		t.Fatalf(`NewMemoryFileFromBytes() failed: %v`, err)
+	}
+	if p,c := g.ID(); !bytes.Equal(p, permanent) || !bytes.Equal(c, changing) {
+		t.Errorf(`ID read as %x %x; expected %x %x`, p, c, permanent, changing)
+	}
+	g.WriteObject(pdf.NewIntNumeric(7))
+	g.Close()
+
+	r,_ := pdf.NewFileFromReader(bytes.NewReader(g.Bytes()))
+	if p,c := r.ID(); !bytes.Equal(p, permanent) || len(c) != 16 || bytes.Equal(c, changing) {
+		t.Errorf(`ID after update is %x %x; expected %x and a new identifier`, p, c, permanent)
+	}
+	r.Close()
+}
+
+func TestVersion(t *testing.T) {
+	f := pdf.NewMemoryFile(pdf.WithVersion(pdf.PDF17))
+	if v := f.Version(); v != pdf.PDF17 {
+		t.Errorf(`Version() returned %v; expected 1.7`, v)
+	}
+	f.Close()
+	if !bytes.HasPrefix(f.Bytes(), []byte("%PDF-1.7\n%\xe2\xe3\xcf\xd3\n")) {
+		t.Errorf(`File begins %q`, f.Bytes()[:16])
+	}
+
+	// AES-256 encryption raises the version to 2.0.
+	encrypted := pdf.NewMemoryFile(pdf.WithEncryption("user", "owner", pdf.AllPermissions()))
+	encrypted.Close()
+	if !bytes.HasPrefix(encrypted.Bytes(), []byte("%PDF-2.0\n")) {
+		t.Errorf(`Encrypted file begins %q`, encrypted.Bytes()[:9])
+	}
+
+	// The version of a pre-existing file is raised in the catalog.
+	g,_ := pdf.NewMemoryFileFromBytes(f.Bytes(), pdf.WithVersion(pdf.PDF20))
+	g.WriteObject(pdf.NewIntNumeric(7))
+	g.Close()
+	r,_ := pdf.NewFileFromReader(bytes.NewReader(g.Bytes()))
+	if version,_ := r.Catalog().GetName("Version"); version != "2.0" || r.Version() != pdf.PDF20 {
+		t.Errorf(`Catalog /Version is %q and Version() returned %v; expected 2.0`, version, r.Version())
+	}
+	if !bytes.HasPrefix(g.Bytes(), []byte("%PDF-1.7\n")) {
+		t.Errorf(`Header of updated file was changed`)
+	}
+	r.Close()
+
+	// The version is recorded in a catalog that is itself
+	// updated, and is still waiting to be written, as the file is
+	// closed.
+	h,_ := pdf.NewMemoryFileFromBytes(f.Bytes(), pdf.WithVersion(pdf.PDF20))
+	catalog := h.Catalog().Unprotect().Clone().(pdf.Dictionary)
+	catalog.Add("Updated", pdf.NewBoolean(true))
+	h.SetCatalog(catalog)
+	h.Close()
+	r,err := pdf.NewFileFromReader(bytes.NewReader(h.Bytes()))
+	if err != nil {
+		t.Fatalf(`Updated file couldn't be read: %v`, err)
+	}
+	if r.Catalog() == nil {
+		t.Fatal(`Updated file has no catalog`)
+	}
+	updated,_ := r.Catalog().GetBoolean("Updated")
+	if version,_ := r.Catalog().GetName("Version"); version != "2.0" || !updated {
+		t.Errorf(`Updated catalog is %s`, toString(r.Catalog(), r))
+	}
+	r.Close()
+}
+
+type xrefLine struct {
+	offset, generation int
+	use byte
+}
+
+// lastXrefSection() parses the entries of the last xref section of a
+// PDF.
+func lastXrefSection(t *testing.T, contents []byte) map[int]xrefLine {
+	start := bytes.LastIndex(contents, []byte("\nxref\n"))
+	end := bytes.LastIndex(contents, []byte("trailer"))
+	if start < 0 || end < start {
+		t.Fatalf(`No xref section found`)
+	}
+	result := make(map[int]xrefLine)
+	lines := strings.Split(strings.TrimSpace(string(contents[start+len("\nxref\n"):end])), "\n")
+	for i:=0; i<len(lines); {
+		var first, count int
+		if n,_ := fmt.Sscanf(lines[i], "%d %d", &first, &count); n != 2 {
+			t.Fatalf(`Invalid subsection header %q`, lines[i])
+		}
+		for j:=0; j<count; j++ {
+			var line xrefLine
+			fmt.Sscanf(lines[i+1+j], "%d %d %c", &line.offset, &line.generation, &line.use)
+			result[first+j] = line
+		}
+		i += count + 1
+	}
+	return result
+}
+
+// newFreeListFile() returns a file with a catalog (object 1) and five
+// more objects (2 through 6), of which 3 and 5 have been deleted.
+func newFreeListFile(options ...pdf.FileOption) *pdf.MemoryFile {
+	f := pdf.NewMemoryFile(options...)
+	f.SetCatalog(pdf.NewDictionary())
+	var objects []pdf.Indirect
+	for i:=2; i<=6; i++ {
+		objects = append(objects, f.WriteObject(pdf.NewIntNumeric(i)))
+	}
+	f.DeleteObject(objects[1])
+	f.DeleteObject(objects[3])
+	return f
+}
+
+func TestFreeList(t *testing.T) {
+	f := newFreeListFile()
+	f.Close()
+	xref := lastXrefSection(t, f.Bytes())
+	if len(xref) != 7 {
+		t.Errorf(`xref has %d entries; expected 7`, len(xref))
+	}
+	expected := map[int]xrefLine{0: {3, 65535, 'f'}, 3: {5, 1, 'f'}, 5: {0, 1, 'f'}}
+	for n,line := range expected {
+		if xref[n] != line {
+			t.Errorf(`Entry %d is %v; expected %v`, n, xref[n], line)
+		}
+	}
+	for _,n := range []int{1, 2, 4, 6} {
+		if xref[n].use != 'n' || xref[n].generation != 0 {
+			t.Errorf(`Entry %d is %v; expected an object in use`, n, xref[n])
+		}
+	}
+
+	// An incremental update that deletes object 2 writes only the
+	// changed entries.
+	g,_ := pdf.NewMemoryFileFromBytes(f.Bytes())
+	g.DeleteObject(g.Indirect(pdf.NewObjectNumber(2, 0)))
+	g.Close()
+	xref = lastXrefSection(t, g.Bytes())
+	expected = map[int]xrefLine{0: {2, 65535, 'f'}, 2: {3, 1, 'f'}}
+	if len(xref) != len(expected) {
+		t.Errorf(`Update has %d xref entries; expected %d`, len(xref), len(expected))
+	}
+	for n,line := range expected {
+		if xref[n] != line {
+			t.Errorf(`Updated entry %d is %v; expected %v`, n, xref[n], line)
+		}
+	}
+	r,_ := pdf.NewFileFromReader(bytes.NewReader(g.Bytes()))
+	if object,_ := r.Object(pdf.NewObjectNumber(4, 0)); object == nil {
+		t.Errorf(`Object 4 was lost by the update`)
+	}
+	r.Close()
+}
+
+func TestReusePolicy(t *testing.T) {
+	for _,c := range []struct{
+		policy pdf.ReusePolicy
+		expected pdf.ObjectNumber
+	}{
+		// The most recently deleted number is reused first.
+		{pdf.ReuseObjectNumbers, pdf.NewObjectNumber(5, 1)},
+		{pdf.NeverReuseObjectNumbers, pdf.NewObjectNumber(7, 0)},
+	} {
+		f := newFreeListFile(pdf.WithReusePolicy(c.policy))
+		if o := pdf.NewIndirect(f).Write(pdf.NewIntNumeric(7)).ObjectNumber(f); o != c.expected {
+			t.Errorf(`Policy %v gave new object number %v; expected %v`, c.policy, o, c.expected)
+		}
+		f.Close()
+	}
+
+	// The policy also applies to entries freed before the file
+	// was opened, which Close() linked in increasing order.
+	f := newFreeListFile()
+	f.Close()
+	g,_ := pdf.NewMemoryFileFromBytes(f.Bytes())
+	if o := pdf.NewIndirect(g).Write(pdf.NewIntNumeric(7)).ObjectNumber(g); o != pdf.NewObjectNumber(3, 1) {
+		t.Errorf(`Update gave new object number %v; expected 3 1`, o)
+	}
+	g.Close()
+	if xref := lastXrefSection(t, g.Bytes()); xref[0] != (xrefLine{5, 65535, 'f'}) || xref[3].use != 'n' {
+		t.Errorf(`Free list not updated after reuse: %v`, xref)
+	}
+}
+
+func TestTruncateFreeEntries(t *testing.T) {
+	f := newFreeListFile()
+	f.DeleteObject(f.Indirect(pdf.NewObjectNumber(6, 0)))
+	f.Close()
+
+	xref := lastXrefSection(t, f.Bytes())
+	if len(xref) != 5 {
+		t.Errorf(`xref has %d entries; expected 5`, len(xref))
+	}
+	if xref[0] != (xrefLine{3, 65535, 'f'}) || xref[3] != (xrefLine{0, 1, 'f'}) {
+		t.Errorf(`Free list is %v and %v after truncation`, xref[0], xref[3])
+	}
+	r,_ := pdf.NewFileFromReader(bytes.NewReader(f.Bytes()))
+	if size,_ := r.Trailer().GetInt("Size"); size != 5 {
+		t.Errorf(`Trailer /Size is %d; expected 5`, size)
+	}
+	r.Close()
+
+	// Entries that older xref sections describe are kept.
+	g,_ := pdf.NewMemoryFileFromBytes(f.Bytes())
+	g.DeleteObject(g.Indirect(pdf.NewObjectNumber(4, 0)))
+	g.Close()
+	if xref := lastXrefSection(t, g.Bytes()); xref[4] != (xrefLine{0, 1, 'f'}) {
+		t.Errorf(`Entry 4 is %v after update`, xref[4])
+	}
+}
+
+func TestDeleteTwice(t *testing.T) {
+	f := pdf.NewMemoryFile()
+	object := f.WriteObject(pdf.NewIntNumeric(1))
+	f.DeleteObject(object)
+	defer func() {
+		if recover() == nil {
+			t.Errorf(`Deleting an object twice did not panic`)
+		}
+		f.Close()
+	}()
+	f.DeleteObject(f.Indirect(pdf.NewObjectNumber(1, 1)))
+}
+
+// startxref() returns the position in the last startxref line of a
+// PDF.
+func startxref(contents []byte) (position int) {
+	fmt.Sscanf(string(contents[bytes.LastIndex(contents, []byte("startxref")):]), "startxref\n%d\n%%%%EOF", &position)
+	return
+}
+
+// trailerEntry() returns the integer value of key in the last
+// trailer of a PDF, or -1 if there is none.
+func trailerEntry(contents []byte, key string) int {
+	trailer := contents[bytes.LastIndex(contents, []byte("trailer")):]
+	match := regexp.MustCompile(`/` + key + `\s+(\d+)`).FindSubmatch(trailer)
+	if match == nil {
+		return -1
+	}
+	value,_ := strconv.Atoi(string(match[1]))
+	return value
+}
+
+func TestTrailer(t *testing.T) {
+	f := pdf.NewMemoryFile()
+	f.SetInfo(pdf.NewDocumentInfo())
+	f.SetCatalog(pdf.NewDictionary())
+	// Deleting the info dictionary and the catalog leaves the
+	// trailer referring to objects that aren't in the file.
+	f.DeleteObject(f.Indirect(pdf.NewObjectNumber(1, 0)))
+	f.DeleteObject(f.Indirect(pdf.NewObjectNumber(2, 0)))
+	f.Close()
+
+	contents := f.Bytes()
+	if position := startxref(contents); position == 0 || !bytes.HasPrefix(contents[position:], []byte("xref\n")) {
+		t.Errorf(`startxref %d does not point to the xref`, position)
+	}
+	r,_ := pdf.NewFileFromReader(bytes.NewReader(contents))
+	if r.Trailer().Get("Info") != nil {
+		t.Errorf(`Trailer refers to deleted /Info`)
+	}
+	if r.Catalog() == nil {
+		t.Errorf(`Deleted catalog was not replaced`)
+	}
+	r.Close()
+	// The new catalog reuses number 2.
+	if size := trailerEntry(contents, "Size"); size != 3 {
+		t.Errorf(`Trailer /Size is %d; expected 3`, size)
+	}
+	if prev := trailerEntry(contents, "Prev"); prev != -1 {
+		t.Errorf(`Trailer of new file has /Prev %d`, prev)
+	}
+
+	g,_ := pdf.NewMemoryFileFromBytes(contents)
+	g.WriteObject(pdf.NewIntNumeric(7))
+	g.Close()
+	updated := g.Bytes()
+	if prev := trailerEntry(updated, "Prev"); prev != startxref(contents) {
+		t.Errorf(`Trailer /Prev is %d; expected %d`, prev, startxref(contents))
+	}
+	if size := trailerEntry(updated, "Size"); size != 3 {
+		t.Errorf(`Updated trailer /Size is %d; expected 3`, size)
+	}
+	if position := startxref(updated); position <= len(contents) || !bytes.HasSuffix(updated, []byte(fmt.Sprintf("startxref\n%d\n%%%%EOF\n", position))) {
+		t.Errorf(`Update is not terminated by startxref and %%%%EOF`)
+	}
+}
+
+// rawPDF() assembles a file from the given objects, numbered from 1,
+// with a correct xref.
+func rawPDF(objects ...string) []byte {
+	var b bytes.Buffer
+	b.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i,object := range objects {
+		offsets[i] = b.Len()
+		fmt.Fprintf(&b, "%d 0 obj\n%s\nendobj\n", i+1, object)
+	}
+	xref := b.Len()
+	fmt.Fprintf(&b, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _,offset := range offsets {
+		fmt.Fprintf(&b, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&b, "trailer\n<</Size %d /Root 1 0 R>>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+	return b.Bytes()
+}
+
+func TestLimits(t *testing.T) {
+	isLimitError := func(err error, limit string) bool {
+		var e *pdf.LimitError
+		return errors.Is(err, &pdf.LimitError{}) && errors.As(err, &e) && e.Limit == limit
+	}
+
+	// A stream that decodes to more than MaxStreamSize.
+	f := pdf.NewMemoryFile()
+	bomb := pdf.NewStream()
+	bomb.AddFilter(new(pdf.FlateFilter))
+	bomb.Write(make([]byte, 100000))
+	o := f.WriteObject(bomb).ObjectNumber(f)
+	nested := pdf.NewArray()
+	for i:=0; i<20; i++ {
+		outer := pdf.NewArray()
+		outer.Add(nested)
+		nested = outer
+	}
+	o2 := f.WriteObject(nested).ObjectNumber(f)
+	f.Close()
+	for i:=0; i<3; i++ {
+		update,_ := pdf.NewMemoryFileFromBytes(f.Bytes())
+		update.WriteObject(pdf.NewIntNumeric(i))
+		update.Close()
+		f = update
+	}
+	contents := f.Bytes()
+
+	r,err := pdf.NewFileFromReader(bytes.NewReader(contents), pdf.WithLimits(pdf.Limits{MaxStreamSize: 1000, MaxDepth: 10}))
+	if err != nil {
+		t.Fatalf(`NewFileFromReader() failed: %v`, err)
+	}
+	object,_ := r.Object(o)
+	if _,err := ioutil.ReadAll(object.(pdf.ProtectedStream).Reader()); !isLimitError(err, "MaxStreamSize") {
+		t.Errorf(`Reading a stream beyond MaxStreamSize returned %v`, err)
+	}
+	if _,err := r.Object(o2); !isLimitError(err, "MaxDepth") || !errors.Is(err, &pdf.SyntaxError{}) {
+		t.Errorf(`Reading an array nested beyond MaxDepth returned %v`, err)
+	}
+	r.Close()
+
+	r,_ = pdf.NewFileFromReader(bytes.NewReader(contents))
+	object,_ = r.Object(o)
+	if data,err := ioutil.ReadAll(object.(pdf.ProtectedStream).Reader()); err != nil || len(data) != 100000 {
+		t.Errorf(`Reading a stream with the default limits returned %d bytes and %v`, len(data), err)
+	}
+	r.Close()
+
+	if _,err := pdf.NewFileFromReader(bytes.NewReader(contents), pdf.WithLimits(pdf.Limits{MaxXrefSections: 3})); !isLimitError(err, "MaxXrefSections") || !errors.Is(err, &pdf.XrefError{}) {
+		t.Errorf(`Opening a file with 4 xref sections and MaxXrefSections 3 returned %v`, err)
+	}
+	if _,err := pdf.NewFileFromReader(bytes.NewReader(contents), pdf.WithLimits(pdf.Limits{MaxObjects: 3})); !isLimitError(err, "MaxObjects") {
+		t.Errorf(`Opening a file with 7 objects and MaxObjects 3 returned %v`, err)
+	}
+
+	// Each stream's /Length refers to the other stream, so reading
+	// either would never end.
+	cyclic := rawPDF("<</Type /Catalog>>",
+		"<</Length 3 0 R>>\nstream\nabc\nendstream",
+		"<</Length 2 0 R>>\nstream\nabc\nendstream")
+	r,err = pdf.NewFileFromReader(bytes.NewReader(cyclic))
+	if err != nil {
+		t.Fatalf(`NewFileFromReader() failed: %v`, err)
+	}
+	if _,err := r.Object(pdf.NewObjectNumber(2, 0)); !isLimitError(err, "MaxResolutionDepth") {
+		t.Errorf(`Reading a stream with a cyclic /Length returned %v`, err)
+	}
+	r.Close()
+}
+
+func TestCycles(t *testing.T) {
+	panics := func(f func()) (panicked bool) {
+		defer func() {
+			panicked = recover() != nil
+		}()
+		f()
+		return
+	}
+	page := "q /X Do Q"
+	form := "/X Do /X Do BT (hi) Tj ET"
+	cyclic := rawPDF("<</Type /Catalog /Pages 2 0 R /Names <</EmbeddedFiles 5 0 R>>>>",
+		"<</Type /Pages /Kids [3 0 R 10 0 R] /Count 2>>",
+		"<</Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] /Contents 4 0 R /Resources <</XObject <</X 6 0 R>>>>>>",
+		fmt.Sprintf("<</Length %d>>\nstream\n%s\nendstream", len(page), page),
+		"<</Kids [5 0 R 5 0 R] /Names [(a) 3 0 R]>>",
+		fmt.Sprintf("<</Type /XObject /Subtype /Form /BBox [0 0 10 10] /Length %d>>\nstream\n%s\nendstream", len(form), form),
+		"7 0 R",
+		"9 0 R",
+		"8 0 R",
+		"<</Type /Pages /Parent 2 0 R /Kids [10 0 R] /Count 1>>")
+	r,err := pdf.NewFileFromReader(bytes.NewReader(cyclic))
+	if err != nil {
+		t.Fatalf(`NewFileFromReader() failed: %v`, err)
+	}
+	for _,n := range []uint32{7, 8} {
+		object,_ := r.Object(pdf.NewObjectNumber(n, 0))
+		if !panics(func() { object.Dereference() }) {
+			t.Errorf(`Dereferencing a cycle of references through object %d did not panic`, n)
+		}
+	}
+
+	doc := pdf.NewDocumentFromFile(r)
+	defer doc.Discard()
+	if !panics(func() { doc.Page(1) }) {
+		t.Errorf(`Finding a page in a page tree node whose /Kids contain itself did not panic`)
+	}
+	if text := doc.Page(0).Text().String(); text != "hi" {
+		t.Errorf(`Text() of a page drawing a form that draws itself returned %q`, text)
+	}
+	if images := doc.Page(0).Images(); len(images) != 0 {
+		t.Errorf(`Images() of a page drawing a form that draws itself returned %d images`, len(images))
+	}
+	doc.Attachments()
+}
+
+func TestRevisions(t *testing.T) {
+	f := pdf.NewMemoryFile()
+	o := f.WriteObject(pdf.NewIntNumeric(0)).ObjectNumber(f)
+	f.Close()
+	if revisions := f.Revisions(); len(revisions) != 0 {
+		t.Errorf(`A new file has %d revisions`, len(revisions))
+	}
+	lengths := []int{len(f.Bytes())}
+	for i:=1; i<3; i++ {
+		update,_ := pdf.NewMemoryFileFromBytes(f.Bytes())
+		update.WriteObjectAt(o, pdf.NewIntNumeric(i))
+		update.Close()
+		f = update
+		lengths = append(lengths, len(f.Bytes()))
+	}
+
+	r,err := pdf.NewFileFromReader(bytes.NewReader(f.Bytes()))
+	if err != nil {
+		t.Fatalf(`NewFileFromReader() failed: %v`, err)
+	}
+	defer r.Close()
+	if object,_ := r.Object(o); object == nil || object.(*pdf.IntNumeric).Value() != 2 {
+		t.Errorf(`The latest revision of the object is %v; expected 2`, object)
+	}
+	revisions := r.Revisions()
+	if len(revisions) != 3 {
+		t.Fatalf(`File with two updates has %d revisions; expected 3`, len(revisions))
+	}
+	for i,revision := range revisions {
+		if revision.Length != int64(lengths[i]) {
+			t.Errorf(`Revision %d has length %d; expected %d`, i, revision.Length, lengths[i])
+		}
+		if _,ok := revision.Trailer.GetInt("Prev"); ok != (i > 0) {
+			t.Errorf(`Revision %d has the wrong trailer`, i)
+		}
+		old,err := revision.Open()
+		if err != nil {
+			t.Errorf(`Opening revision %d failed: %v`, i, err)
+			continue
+		}
+		if object,_ := old.Object(o); object == nil || object.(*pdf.IntNumeric).Value() != i {
+			t.Errorf(`Revision %d of the object is %v; expected %d`, i, object, i)
+		}
+		if len(old.Revisions()) != i+1 {
+			t.Errorf(`Revision %d has %d revisions of its own`, i, len(old.Revisions()))
+		}
+		old.Close()
+	}
+}
+
+func TestWrongStreamLength(t *testing.T) {
+	for _,length := range []string{"3", "500", "6 0 R", "7 0 R"} {
+		contents := rawPDF("<</Type /Catalog>>",
+			"<</Length "+length+">>\nstream\nabcde\nendstream",
+			"[2 0 R]", "null", "null", "3", "(not a length)")
+		r,err := pdf.NewFileFromReader(bytes.NewReader(contents), pdf.WithParsingMode(pdf.LenientParsing))
+		if err != nil {
+			t.Fatalf(`NewFileFromReader() failed: %v`, err)
+		}
+		object,err := r.Object(pdf.NewObjectNumber(2, 0))
+		stream,ok := object.(pdf.ProtectedStream)
+		if !ok {
+			t.Errorf(`Stream with /Length %s read as %v, %v`, length, object, err)
+		} else if data,_ := ioutil.ReadAll(stream.Reader()); string(data) != "abcde" {
+			t.Errorf(`Stream with /Length %s read as %q; expected "abcde"`, length, data)
+		}
+		// Reading the stream must not disturb the objects that follow.
+		if object,_ := r.Object(pdf.NewObjectNumber(3, 0)); object == nil {
+			t.Errorf(`Object following a stream with /Length %s couldn't be read`, length)
+		}
+		r.Close()
+	}
+}
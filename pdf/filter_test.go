@@ -2,10 +2,13 @@ package pdf_test
 
 import (
 	"github.com/mawicks/PDFiG/pdf"
-//	"fmt"
+	"fmt"
 	"io"
+	"io/ioutil"
 	"bytes"
+	"compress/gzip"
 	"math/rand"
+	"strings"
 	"testing" )
 
 func randomBytes(n int) []byte {
@@ -99,3 +102,108 @@ func TestFilters(t *testing.T) {
 	}
 }
 
+// TestFlateReuse checks that the compressors and decompressors that
+// FlateFilter reuses don't carry state from one stream to another.
+func TestFlateReuse(t *testing.T) {
+	fast,best := new(pdf.FlateFilter),new(pdf.FlateFilter)
+	fast.SetCompressionLevel(1)
+	best.SetCompressionLevel(9)
+	for i:=0; i<4; i++ {
+		data := [][]byte{randomBytes(1000*i), []byte(strings.Repeat("BT (Text) Tj ET\n", 100*i))}
+		buffers := []*pdf.BufferCloser{pdf.NewBufferCloser(), pdf.NewBufferCloser()}
+		// Interleave the encoders, closing them in alternating order.
+		encoders := []io.WriteCloser{fast.NewEncoder(buffers[0]), best.NewEncoder(buffers[1])}
+		for j,e := range encoders {
+			e.Write(data[j])
+		}
+		encoders[i%2].Close()
+		encoders[1-i%2].Close()
+
+		for j,b := range buffers {
+			decoder := fast.NewDecoder(bytes.NewReader(b.Bytes()))
+			decoded,err := ioutil.ReadAll(decoder)
+			if err != nil || !bytes.Equal(decoded, data[j]) {
+				t.Errorf(`Decoding stream %d of round %d failed: %v`, j, i, err)
+			}
+			if n,err := decoder.Read(make([]byte, 1)); n != 0 || err != io.EOF {
+				t.Errorf(`Read() after the end returned %d, %v; expected 0, EOF`, n, err)
+			}
+		}
+	}
+}
+
+// gzipCodec stands in for codecs such as Brotli that PDF doesn't
+// define.
+type gzipCodec struct{}
+
+func (gzipCodec) Name() string {
+	return "X-Gzip"
+}
+
+func (gzipCodec) NewEncoder(w io.WriteCloser) io.WriteCloser {
+	return gzipWriter{gzip.NewWriter(w), w}
+}
+
+// gzipWriter closes the writer underlying a gzip.Writer along with it.
+type gzipWriter struct {
+	*gzip.Writer
+	underlying io.WriteCloser
+}
+
+func (w gzipWriter) Close() error {
+	if err := w.Writer.Close(); err != nil {
+		return err
+	}
+	return w.underlying.Close()
+}
+
+func (gzipCodec) NewDecoder(r io.Reader) io.Reader {
+	decoder,err := gzip.NewReader(r)
+	if err != nil {
+		return strings.NewReader("")
+	}
+	return decoder
+}
+
+func TestCodec(t *testing.T) {
+	pdf.RegisterCodec(gzipCodec{})
+	contents := strings.Repeat("foo ", 100)
+
+	s := pdf.NewStream()
+	s.Add("Subtype", pdf.NewName("Form"))
+	fmt.Fprint(s, contents)
+	if _,err := pdf.EncodeWithCodec(s, "X-Unknown"); err == nil {
+		t.Error(`EncodeWithCodec() accepted an unregistered codec`)
+	}
+	encoded,err := pdf.EncodeWithCodec(s, "X-Gzip")
+	if err != nil {
+		t.Fatalf(`EncodeWithCodec() failed: %v`, err)
+	}
+	if name,_ := encoded.Dictionary().GetName("Filter"); name != "X-Gzip" {
+		t.Errorf(`EncodeWithCodec() set /Filter to %q`, name)
+	}
+	if decoded,_ := ioutil.ReadAll(encoded.Reader()); string(decoded) != contents {
+		t.Errorf(`Reader() of encoded stream returned %q`, decoded)
+	}
+
+	// Streams are written with FlateDecode unless the file keeps
+	// codecs.
+	f := pdf.NewMemoryFile()
+	defer f.Close()
+	written := toString(encoded, f)
+	if !strings.Contains(written, "/Filter /FlateDecode /Length ") || strings.Contains(written, "X-Gzip") {
+		t.Errorf(`Stream held with codec was written as %q`, written)
+	}
+	parsed,err := pdf.ParseObject([]byte(written))
+	if ps,ok := parsed.(pdf.ProtectedStream); !ok || err != nil {
+		t.Errorf(`Written stream could not be parsed: %v`, err)
+	} else if decoded,_ := ioutil.ReadAll(ps.Reader()); string(decoded) != contents {
+		t.Errorf(`Written stream decoded to %q`, decoded)
+	}
+
+	kept := pdf.NewMemoryFile(pdf.WithCodecOutput())
+	defer kept.Close()
+	if written := toString(encoded, kept); !strings.Contains(written, "/Filter /X-Gzip /Length ") {
+		t.Errorf(`Stream written with WithCodecOutput() was written as %q`, written)
+	}
+}
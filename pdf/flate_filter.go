@@ -3,17 +3,21 @@ package pdf
 import ( //"errors"
 	"compress/zlib"
 //	"fmt"
-	"io")
+	"io"
+	"io/ioutil")
 
 type FlateFilter struct {
 	compressionLevel int
+	// decodeParameters are the parameters read with a stream,
+	// which may name a predictor to be reversed when decoding.
+	decodeParameters ProtectedDictionary
 }
 
 const ( flateDecoderName = "FlateDecode" )
 
 func init () {
 	RegisterFilterFactoryFactory(flateDecoderName,
-		func(ProtectedDictionary) StreamFilterFactory { return new(FlateFilter) })
+		func(d ProtectedDictionary) StreamFilterFactory { return &FlateFilter{decodeParameters: d} })
 }
 
 func (filter *FlateFilter) Name() string {
@@ -25,13 +29,16 @@ func (filter *FlateFilter) SetCompressionLevel(level int) {
 }
 
 func (filter *FlateFilter) NewEncoder(writer io.WriteCloser) io.WriteCloser {
-	flateWriter,_ := zlib.NewWriterLevel(writer,filter.compressionLevel)
-	return &FlateWriter{flateWriter,writer}
+	flateWriter,_ := getFlateWriter(writer,filter.compressionLevel)
+	return &FlateWriter{flateWriter,writer,filter.compressionLevel}
 }
 
 func (filter *FlateFilter) NewDecoder(reader io.Reader) io.Reader {
-	flateReader,_ := zlib.NewReader(reader)
-	return &FlateReader{flateReader}
+	flateReader,err := getFlateReader(reader)
+	if err != nil {
+		return errorReader{err}
+	}
+	return newPredictorReader(&FlateReader{flateReader}, filter.decodeParameters)
 }
 
 func (filter *FlateFilter) DecodeParms(file ...File) Object {
@@ -41,10 +48,19 @@ func (filter *FlateFilter) DecodeParms(file ...File) Object {
 type FlateWriter struct {
 	io.WriteCloser
 	underlyingWriter io.WriteCloser
+	level int
 }
 
+// Close() flushes the compressed data and closes the underlying
+// writer.  The compressor is then returned to a pool for reuse, so
+// the FlateWriter must not be written to afterward.
 func (fw *FlateWriter) Close() error {
-	if err := fw.WriteCloser.Close(); err != nil {
+	err := fw.WriteCloser.Close()
+	if z,ok := fw.WriteCloser.(*zlib.Writer); ok {
+		putFlateWriter(z, fw.level)
+		fw.WriteCloser = nopWriteCloser{ioutil.Discard}
+	}
+	if err != nil {
 		return err
 	}
 	return fw.underlyingWriter.Close()
@@ -54,4 +70,18 @@ type FlateReader struct {
 	io.Reader
 }
 
+// Read() returns the decompressed data.  Once the end of the data or
+// an error is reached, the decompressor is returned to a pool for
+// reuse and later calls return the same error.
+func (fr *FlateReader) Read(p []byte) (int, error) {
+	n,err := fr.Reader.Read(p)
+	if err != nil {
+		if z,ok := fr.Reader.(io.ReadCloser); ok {
+			putFlateReader(z)
+			fr.Reader = errorReader{err}
+		}
+	}
+	return n,err
+}
+
 
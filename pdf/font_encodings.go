@@ -0,0 +1,169 @@
+package pdf
+
+import (
+	"fmt"
+	"sort" )
+
+// Tables mapping the single-byte codes of the standard simple-font
+// encodings (PDF Reference, Appendix D) to Unicode.  Codes that an
+// encoding leaves undefined map to 0.
+var (
+	standardEncoding [256]rune
+	winAnsiEncoding [256]rune
+	macRomanEncoding [256]rune )
+
+func init() {
+	for c:=0x20; c<0x7f; c++ {
+		standardEncoding[c] = rune(c)
+		winAnsiEncoding[c] = rune(c)
+		macRomanEncoding[c] = rune(c)
+	}
+
+	standardEncoding['\''] = '’'
+	standardEncoding['`'] = '‘'
+	for c,r := range map[byte]rune {
+		0xa1: '¡', 0xa2: '¢', 0xa3: '£', 0xa4: '⁄',
+		0xa5: '¥', 0xa6: 'ƒ', 0xa7: '§', 0xa8: '¤',
+		0xa9: '\'', 0xaa: '“', 0xab: '«', 0xac: '‹',
+		0xad: '›', 0xae: 'ﬁ', 0xaf: 'ﬂ', 0xb1: '–',
+		0xb2: '†', 0xb3: '‡', 0xb4: '·', 0xb6: '¶',
+		0xb7: '•', 0xb8: '‚', 0xb9: '„', 0xba: '”',
+		0xbb: '»', 0xbc: '…', 0xbd: '‰', 0xbf: '¿',
+		0xc1: '`', 0xc2: '´', 0xc3: 'ˆ', 0xc4: '˜',
+		0xc5: '¯', 0xc6: '˘', 0xc7: '˙', 0xc8: '¨',
+		0xca: '˚', 0xcb: '¸', 0xcd: '˝', 0xce: '˛',
+		0xcf: 'ˇ', 0xd0: '—', 0xe1: 'Æ', 0xe3: 'ª',
+		0xe8: 'Ł', 0xe9: 'Ø', 0xea: 'Œ', 0xeb: 'º',
+		0xf1: 'æ', 0xf5: 'ı', 0xf8: 'ł', 0xf9: 'ø',
+		0xfa: 'œ', 0xfb: 'ß' } {
+		standardEncoding[c] = r
+	}
+
+	// WinAnsiEncoding matches Latin-1 in its upper half apart
+	// from 0x80-0x9f.  Unused codes above 0x40 are displayed as a
+	// bullet.
+	for c:=0xa0; c<0x100; c++ {
+		winAnsiEncoding[c] = rune(c)
+	}
+	for c,r := range []rune {
+		'€', '•', '‚', 'ƒ', '„', '…', '†', '‡',
+		'ˆ', '‰', 'Š', '‹', 'Œ', '•', 'Ž', '•',
+		'•', '‘', '’', '“', '”', '•', '–', '—',
+		'˜', '™', 'š', '›', 'œ', '•', 'ž', 'Ÿ' } {
+		winAnsiEncoding[0x80+c] = r
+	}
+	winAnsiEncoding[0x7f] = '•'
+
+	for c,r := range []rune {
+		'Ä', 'Å', 'Ç', 'É', 'Ñ', 'Ö', 'Ü', 'á',
+		'à', 'â', 'ä', 'ã', 'å', 'ç', 'é', 'è',
+		'ê', 'ë', 'í', 'ì', 'î', 'ï', 'ñ', 'ó',
+		'ò', 'ô', 'ö', 'õ', 'ú', 'ù', 'û', 'ü',
+		'†', '°', '¢', '£', '§', '•', '¶', 'ß',
+		'®', '©', '™', '´', '¨', '≠', 'Æ', 'Ø',
+		'∞', '±', '≤', '≥', '¥', 'µ', '∂', '∑',
+		'∏', 'π', '∫', 'ª', 'º', 'Ω', 'æ', 'ø',
+		'¿', '¡', '¬', '√', 'ƒ', '≈', '∆', '«',
+		'»', '…', ' ', 'À', 'Ã', 'Õ', 'Œ', 'œ',
+		'–', '—', '“', '”', '‘', '’', '÷', '◊',
+		'ÿ', 'Ÿ', '⁄', '¤', '‹', '›', 'ﬁ', 'ﬂ',
+		'‡', '·', '‚', '„', '‰', 'Â', 'Ê', 'Á',
+		'Ë', 'È', 'Í', 'Î', 'Ï', 'Ì', 'Ó', 'Ô',
+		0, 'Ò', 'Ú', 'Û', 'Ù', 'ı', 'ˆ', '˜',
+		'¯', '˘', '˙', '˚', '¸', '˝', '˛', 'ˇ' } {
+		macRomanEncoding[0x80+c] = r
+	}
+}
+
+// namedEncoding() returns the table for the named standard encoding,
+// or nil if the name is not recognized.
+func namedEncoding(name string) *[256]rune {
+	switch name {
+	case "StandardEncoding":
+		return &standardEncoding
+	case "WinAnsiEncoding":
+		return &winAnsiEncoding
+	case "MacRomanEncoding":
+		return &macRomanEncoding
+	}
+	return nil
+}
+
+// NewEncodingDictionary() returns an /Encoding dictionary for a simple
+// font, such as an embedded subset, whose codes select the glyphs
+// named in glyphs.  baseEncoding is "StandardEncoding",
+// "WinAnsiEncoding", or "MacRomanEncoding", or "" for the font's
+// built-in encoding.  /Differences lists only the codes whose glyphs
+// differ from those of the base encoding; with no base encoding, it
+// lists every code in glyphs.
+func NewEncodingDictionary(baseEncoding string, glyphs map[byte]string) (Dictionary, error) {
+	var base *[256]rune
+	if baseEncoding != "" {
+		if base = namedEncoding(baseEncoding); base == nil {
+			return nil, fmt.Errorf(`Unknown base encoding "%s"`, baseEncoding)
+		}
+	}
+	for code,name := range glyphs {
+		if name == "" {
+			return nil, fmt.Errorf(`Code %d has no glyph name`, code)
+		}
+	}
+
+	dictionary := NewDictionary()
+	dictionary.Add("Type", NewName("Encoding"))
+	if baseEncoding != "" {
+		dictionary.Add("BaseEncoding", NewName(baseEncoding))
+	}
+	if differences := differencesArray(glyphs, base); differences.Size() > 0 {
+		dictionary.Add("Differences", differences)
+	}
+	return dictionary, nil
+}
+
+// differencesArray() returns a /Differences array giving the glyph
+// names of the codes in glyphs, omitting those whose text is that of
+// the code in base if base is not nil.  Each run of consecutive codes
+// is preceded by its first code.
+func differencesArray(glyphs map[byte]string, base *[256]rune) Array {
+	codes := make([]int, 0, len(glyphs))
+	for code,name := range glyphs {
+		if base != nil && base[code] != 0 && glyphText(name) == string(base[code]) {
+			continue
+		}
+		codes = append(codes, int(code))
+	}
+	sort.Ints(codes)
+
+	differences := NewArray()
+	previous := -2
+	for _,code := range codes {
+		if code != previous+1 {
+			differences.Add(NewIntNumeric(code))
+		}
+		differences.Add(NewName(glyphs[byte(code)]))
+		previous = code
+	}
+	return differences
+}
+
+// applyDifferences() returns the text for each code that a
+// /Differences array maps to a glyph.  Codes whose glyph names aren't
+// recognized map to "".
+func applyDifferences(differences ProtectedArray) map[uint32]string {
+	result := make(map[uint32]string)
+	code := -1
+	for i:=0; i<differences.Size(); i++ {
+		switch v := differences.At(i).Dereference().(type) {
+		case Name:
+			if code >= 0 && code < 256 {
+				result[uint32(code)] = glyphText(v.String())
+				code++
+			}
+		default:
+			if n,ok := numericValue(v); ok {
+				code = int(n)
+			}
+		}
+	}
+	return result
+}
@@ -0,0 +1,121 @@
+package pdf
+
+import (
+	"fmt" )
+
+// ReusePolicy determines whether the numbers of deleted objects are
+// reused for new objects.
+type ReusePolicy int
+
+const (
+	// ReuseObjectNumbers takes numbers for new objects from the
+	// free list, with an incremented generation, before adding
+	// new entries to the xref.  This is the default.
+	ReuseObjectNumbers ReusePolicy = iota
+
+	// NeverReuseObjectNumbers always gives new objects new
+	// numbers.  Since a number then refers to at most one object
+	// over the life of the file, a stale reference in an older
+	// revision can't silently resolve to an unrelated object after
+	// an incremental update.
+	NeverReuseObjectNumbers )
+
+// WithReusePolicy() returns a FileOption that sets the policy for
+// reusing the numbers of deleted objects.
+func WithReusePolicy(policy ReusePolicy) FileOption {
+	return func(f *file) {
+		f.reusePolicy = policy
+	}
+}
+
+// entry() returns the xref entry for object number n, or nil if
+// there is none.
+func (f *file) entry(n uint) *xrefEntry {
+	if n >= f.xref.Size() {
+		return nil
+	}
+	entry,_ := (*f.xref.At(n)).(*xrefEntry)
+	return entry
+}
+
+// takeFreeEntry() removes from the free list the first entry that may
+// be reused and returns its number, or 0 if there is none.  Entries
+// whose generation has reached 65535 remain on the list but are never
+// reused.  The caller must hold the semaphore.
+func (f *file) takeFreeEntry() uint32 {
+	if f.reusePolicy == NeverReuseObjectNumbers {
+		return 0
+	}
+	previous := f.entry(0)
+	// Stop after visiting every entry in case a damaged
+	// pre-existing list contains a loop.
+	for steps := f.xref.Size(); steps > 0 && previous.byteOffset != 0; steps-- {
+		n := uint32(previous.byteOffset)
+		entry := f.entry(uint(n))
+		if entry == nil || entry.inUse || entry.reserved {
+			// The rest of the list can't be trusted.
+			return 0
+		}
+		if entry.generation < 65535 {
+			previous.byteOffset = entry.byteOffset
+			previous.dirty = true
+			entry.byteOffset = 0
+			entry.dirty = true
+			return n
+		}
+		previous = entry
+	}
+	return 0
+}
+
+// freeEntry() marks the entry for object number n free, increments its
+// generation for the next use, and links it to the head of the free
+// list.  The caller must hold the semaphore.
+func (f *file) freeEntry(n uint32) {
+	freeHead := f.entry(0)
+	entry := f.entry(uint(n))
+	entry.clear(freeHead.byteOffset)
+	freeHead.byteOffset = uint64(n)
+	freeHead.dirty = true
+}
+
+// truncateFreeEntries() removes free entries from the end of the
+// xref.  Only entries added since the file was opened are removed, as
+// older xref sections still describe the others.
+func (f *file) truncateFreeEntries() {
+	for size := f.xref.Size(); size > f.originalXrefSize; size-- {
+		if entry := f.entry(size-1); entry == nil || entry.inUse || entry.reserved {
+			break
+		}
+		f.xref.SetSize(size-1)
+	}
+}
+
+// linkFreeList() rebuilds the free list so that it links every free
+// entry in increasing order of object number, ending with a link to
+// entry 0, as the PDF specification describes.  Only entries whose
+// links change are marked dirty.  Objects that were reserved but
+// never written are treated as free.
+func (f *file) linkFreeList() {
+	next := uint64(0)
+	for i := f.xref.Size()-1; i > 0; i-- {
+		entry := f.entry(i)
+		if entry == nil || entry.inUse {
+			continue
+		}
+		if entry.reserved {
+			fmt.Fprintf(logger, "Warning: Object %d reserved but never written\n", i)
+			entry.reserved = false
+		}
+		if entry.byteOffset != next {
+			entry.byteOffset = next
+			entry.dirty = true
+		}
+		next = uint64(i)
+	}
+	if freeHead := f.entry(0); freeHead.byteOffset != next || freeHead.generation != 65535 {
+		freeHead.byteOffset = next
+		freeHead.generation = 65535
+		freeHead.dirty = true
+	}
+}
@@ -0,0 +1,122 @@
+package pdf
+
+import (
+	"strconv"
+	"strings"
+	"unicode" )
+
+// glyphNames maps the names of the glyphs in the standard Latin
+// character set (PDF Reference, Appendix D), together with a few
+// other common names, to Unicode.  Single letters and the names
+// handled by glyphText() are not listed.
+var glyphNames = map[string]rune {
+	"AE": 'Æ', "Aacute": 'Á', "Acircumflex": 'Â', "Adieresis": 'Ä',
+	"Agrave": 'À', "Aring": 'Å', "Atilde": 'Ã', "Ccedilla": 'Ç',
+	"Delta": '∆', "Eacute": 'É', "Ecircumflex": 'Ê', "Edieresis": 'Ë',
+	"Egrave": 'È', "Eth": 'Ð', "Euro": '€', "Iacute": 'Í',
+	"Icircumflex": 'Î', "Idieresis": 'Ï', "Igrave": 'Ì', "Lslash": 'Ł',
+	"Ntilde": 'Ñ', "OE": 'Œ', "Oacute": 'Ó', "Ocircumflex": 'Ô',
+	"Odieresis": 'Ö', "Ograve": 'Ò', "Omega": 'Ω', "Oslash": 'Ø',
+	"Otilde": 'Õ', "Scaron": 'Š', "Thorn": 'Þ', "Uacute": 'Ú',
+	"Ucircumflex": 'Û', "Udieresis": 'Ü', "Ugrave": 'Ù', "Yacute": 'Ý',
+	"Ydieresis": 'Ÿ', "Zcaron": 'Ž',
+	"aacute": 'á', "acircumflex": 'â', "acute": '´', "adieresis": 'ä',
+	"ae": 'æ', "agrave": 'à', "ampersand": '&', "approxequal": '≈',
+	"aring": 'å', "asciicircum": '^', "asciitilde": '~', "asterisk": '*',
+	"at": '@', "atilde": 'ã', "backslash": '\\', "bar": '|',
+	"braceleft": '{', "braceright": '}', "bracketleft": '[', "bracketright": ']',
+	"breve": '˘', "brokenbar": '¦', "bullet": '•', "caron": 'ˇ',
+	"ccedilla": 'ç', "cedilla": '¸', "cent": '¢', "circumflex": 'ˆ',
+	"colon": ':', "comma": ',', "copyright": '©', "currency": '¤',
+	"dagger": '†', "daggerdbl": '‡', "degree": '°', "dieresis": '¨',
+	"divide": '÷', "dollar": '$', "dotaccent": '˙', "dotlessi": 'ı',
+	"eacute": 'é', "ecircumflex": 'ê', "edieresis": 'ë', "egrave": 'è',
+	"eight": '8', "ellipsis": '…', "emdash": '—', "endash": '–',
+	"equal": '=', "eth": 'ð', "exclam": '!', "exclamdown": '¡',
+	"ff": 'ﬀ', "ffi": 'ﬃ', "ffl": 'ﬄ', "fi": 'ﬁ',
+	"five": '5', "fl": 'ﬂ', "florin": 'ƒ', "four": '4',
+	"fraction": '⁄', "germandbls": 'ß', "grave": '`', "greater": '>',
+	"greaterequal": '≥', "guillemotleft": '«', "guillemotright": '»', "guilsinglleft": '‹',
+	"guilsinglright": '›', "hungarumlaut": '˝', "hyphen": '-', "iacute": 'í',
+	"icircumflex": 'î', "idieresis": 'ï', "igrave": 'ì', "infinity": '∞',
+	"integral": '∫', "less": '<', "lessequal": '≤', "logicalnot": '¬',
+	"lozenge": '◊', "lslash": 'ł', "macron": '¯', "minus": '−',
+	"mu": 'µ', "multiply": '×', "nbspace": '\u00a0', "nine": '9',
+	"notequal": '≠', "ntilde": 'ñ', "numbersign": '#', "oacute": 'ó',
+	"ocircumflex": 'ô', "odieresis": 'ö', "oe": 'œ', "ogonek": '˛',
+	"ograve": 'ò', "one": '1', "onehalf": '½', "onequarter": '¼',
+	"onesuperior": '¹', "ordfeminine": 'ª', "ordmasculine": 'º', "oslash": 'ø',
+	"otilde": 'õ', "paragraph": '¶', "parenleft": '(', "parenright": ')',
+	"partialdiff": '∂', "percent": '%', "period": '.', "periodcentered": '·',
+	"perthousand": '‰', "pi": 'π', "plus": '+', "plusminus": '±',
+	"product": '∏', "question": '?', "questiondown": '¿', "quotedbl": '"',
+	"quotedblbase": '„', "quotedblleft": '“', "quotedblright": '”', "quoteleft": '‘',
+	"quoteright": '’', "quotesinglbase": '‚', "quotesingle": '\'', "radical": '√',
+	"registered": '®', "ring": '˚', "scaron": 'š', "section": '§',
+	"semicolon": ';', "seven": '7', "sfthyphen": '\u00ad', "six": '6',
+	"slash": '/', "space": ' ', "sterling": '£', "summation": '∑',
+	"thorn": 'þ', "three": '3', "threequarters": '¾', "threesuperior": '³',
+	"tilde": '˜', "trademark": '™', "two": '2', "twosuperior": '²',
+	"uacute": 'ú', "ucircumflex": 'û', "udieresis": 'ü', "ugrave": 'ù',
+	"underscore": '_', "yacute": 'ý', "ydieresis": 'ÿ', "yen": '¥',
+	"zcaron": 'ž', "zero": '0' }
+
+// glyphText() returns the text represented by a glyph name, following
+// the rules of the Adobe Glyph List Specification: a suffix beginning
+// with a period is ignored, components separated by underscores are
+// ligatures, and names of the form "uniXXXX" and "uXXXX[XX]" give
+// Unicode values in hexadecimal.  It returns "" for names it doesn't
+// recognize.
+func glyphText(name string) string {
+	if i := strings.IndexByte(name, '.'); i >= 0 {
+		name = name[:i]
+	}
+	if strings.IndexByte(name, '_') >= 0 {
+		var text string
+		for _,component := range strings.Split(name, "_") {
+			t := glyphText(component)
+			if t == "" {
+				return ""
+			}
+			text += t
+		}
+		return text
+	}
+
+	if r,exists := glyphNames[name]; exists {
+		return string(r)
+	}
+	if len(name) == 1 && (name[0] >= 'A' && name[0] <= 'Z' || name[0] >= 'a' && name[0] <= 'z') {
+		return name
+	}
+	if strings.HasPrefix(name, "uni") && len(name) > 3 && (len(name)-3)%4 == 0 {
+		var text []rune
+		for i:=3; i<len(name); i+=4 {
+			r,ok := hexRune(name[i:i+4])
+			if !ok {
+				return ""
+			}
+			text = append(text, r)
+		}
+		return string(text)
+	}
+	if strings.HasPrefix(name, "u") && len(name) >= 5 && len(name) <= 7 {
+		if r,ok := hexRune(name[1:]); ok {
+			return string(r)
+		}
+	}
+	return ""
+}
+
+// hexRune() parses an uppercase hexadecimal Unicode value, rejecting
+// surrogates.
+func hexRune(s string) (rune, bool) {
+	if strings.ToUpper(s) != s {
+		return 0, false
+	}
+	v,err := strconv.ParseUint(s, 16, 32)
+	if err != nil || v > unicode.MaxRune || (v >= 0xd800 && v <= 0xdfff) {
+		return 0, false
+	}
+	return rune(v), true
+}
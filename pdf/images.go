@@ -0,0 +1,734 @@
+package pdf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"io/ioutil" )
+
+// A PageImage is an image drawn by a page's contents.
+type PageImage struct {
+	// Name is the resource name of an image XObject.  It is
+	// empty for inline images.
+	Name string
+	// Inline is true for images drawn with the BI operator.
+	Inline bool
+	// Matrix is the transformation in effect when the image was
+	// drawn.  It maps the unit square, in which every image is
+	// drawn, to default user space.
+	Matrix [6]float64
+	// Stream is the image XObject.  For inline images, it is
+	// constructed from the inline image parameters and data, with
+	// abbreviated names expanded.
+	Stream ProtectedStream
+
+	// resources is used to look up color spaces named by inline
+	// images.
+	resources ProtectedDictionary
+	// key identifies the image XObject if it was read from a
+	// file, in which case keyed is true.
+	key objectKey
+	keyed bool
+}
+
+var (
+	unsupportedImage = errors.New(`Unsupported image encoding or color space`)
+	truncatedImage = errors.New(`Image data is shorter than its dimensions require`) )
+
+// Images() returns the images drawn by the page's contents, including
+// those drawn by form XObjects, in the order in which they are drawn.
+// An image drawn more than once is listed each time.
+func (ep *ExistingPage) Images() []PageImage {
+	var images []PageImage
+	if reader := ep.Reader(); reader != nil {
+		images = findImages(reader, ep.GetDictionary("Resources"), identityMatrix, images, 0, make(map[objectKey]bool))
+	}
+	return images
+}
+
+// findImages() appends the images drawn by the content stream r to
+// images.  drawing holds the forms being searched, which are not
+// searched again from within themselves.
+func findImages(r io.Reader, resources ProtectedDictionary, ctm matrix, images []PageImage, depth int, drawing map[objectKey]bool) []PageImage {
+	var stack []matrix
+	parser := NewContentParser(r)
+	for {
+		op,err := parser.Next()
+		if err != nil {
+			return images
+		}
+		switch op.Operator {
+		case "q":
+			stack = append(stack, ctm)
+		case "Q":
+			if n := len(stack); n > 0 {
+				ctm = stack[n-1]
+				stack = stack[:n-1]
+			}
+		case "cm":
+			if m,ok := matrixFromOperands(op.Operands); ok {
+				ctm = m.multiply(ctm)
+			}
+		case "BI":
+			if len(op.Operands) == 2 {
+				parameters,ok1 := op.Operands[0].(Dictionary)
+				data,ok2 := op.Operands[1].(ProtectString)
+				if ok1 && ok2 {
+					images = append(images, PageImage{
+						Inline: true,
+						Matrix: ctm,
+						Stream: NewStreamFromContents(expandInlineImageParameters(parameters), data.Bytes(), nil),
+						resources: resources})
+				}
+			}
+		case "Do":
+			if len(op.Operands) != 1 {
+				continue
+			}
+			name,ok := op.Operands[0].(Name)
+			if !ok {
+				continue
+			}
+			xobject := lookupXObject(resources, name.String())
+			if xobject == nil {
+				continue
+			}
+			switch subtype,_ := xobject.Dictionary().GetName("Subtype"); subtype {
+			case "Image":
+				key,keyed := xobjectKey(resources, name.String())
+				images = append(images, PageImage{Name: name.String(), Matrix: ctm, Stream: xobject, key: key, keyed: keyed})
+			case "Form":
+				key,known := xobjectKey(resources, name.String())
+				if depth < maxFormDepth && !drawing[key] {
+					if known {
+						drawing[key] = true
+					}
+					formCTM, formResources := formContext(xobject, ctm, resources)
+					images = findImages(xobject.Reader(), formResources, formCTM, images, depth+1, drawing)
+					delete(drawing, key)
+				}
+			}
+		}
+	}
+}
+
+// lookupXObject() returns the named XObject in resources, or nil.
+func lookupXObject(resources ProtectedDictionary, name string) ProtectedStream {
+	if resources == nil {
+		return nil
+	}
+	if xobjects := resources.GetDictionary("XObject"); xobjects != nil {
+		return xobjects.GetStream(name)
+	}
+	return nil
+}
+
+// xobjectKey() returns the key of the named XObject in resources if
+// it is a reference read from a file.
+func xobjectKey(resources ProtectedDictionary, name string) (objectKey, bool) {
+	if resources == nil {
+		return objectKey{}, false
+	}
+	if xobjects := resources.GetDictionary("XObject"); xobjects != nil {
+		return sourceKey(xobjects.Get(name))
+	}
+	return objectKey{}, false
+}
+
+// formContext() returns the transformation and resources in effect
+// within a form XObject drawn with the passed transformation and
+// resources.  A form without resources uses those of the page.
+func formContext(form ProtectedStream, ctm matrix, resources ProtectedDictionary) (matrix, ProtectedDictionary) {
+	dictionary := form.Dictionary()
+	if array := dictionary.GetArray("Matrix"); array != nil {
+		operands := make([]Object, array.Size())
+		for i := range operands {
+			operands[i] = array.At(i)
+		}
+		if m,ok := matrixFromOperands(operands); ok {
+			ctm = m.multiply(ctm)
+		}
+	}
+	if formResources := dictionary.GetDictionary("Resources"); formResources != nil {
+		resources = formResources
+	}
+	return ctm, resources
+}
+
+// Abbreviations used in inline images
+var (
+	inlineImageKeys = map[string]string {
+		"BPC": "BitsPerComponent",
+		"CS": "ColorSpace",
+		"D": "Decode",
+		"DP": "DecodeParms",
+		"F": "Filter",
+		"H": "Height",
+		"IM": "ImageMask",
+		"I": "Interpolate",
+		"L": "Length",
+		"W": "Width" }
+	inlineImageNames = map[string]string {
+		"G": "DeviceGray",
+		"RGB": "DeviceRGB",
+		"CMYK": "DeviceCMYK",
+		"I": "Indexed",
+		"AHx": "ASCIIHexDecode",
+		"A85": "ASCII85Decode",
+		"LZW": "LZWDecode",
+		"Fl": "FlateDecode",
+		"RL": "RunLengthDecode",
+		"CCF": "CCITTFaxDecode",
+		"DCT": "DCTDecode" } )
+
+// expandInlineImageParameters() returns a copy of the parameters of
+// an inline image with abbreviated keys and names replaced by those
+// used in image XObjects.
+func expandInlineImageParameters(parameters ProtectedDictionary) Dictionary {
+	expandName := func(o Object) Object {
+		if n,ok := o.(Name); ok {
+			if full,exists := inlineImageNames[n.String()]; exists {
+				return NewName(full)
+			}
+		}
+		return o
+	}
+
+	result := NewDictionary()
+	result.Add("Type", NewName("XObject"))
+	result.Add("Subtype", NewName("Image"))
+	for _,key := range parameters.Keys() {
+		value := parameters.Get(key)
+		switch v := value.(type) {
+		case Name:
+			value = expandName(v)
+		case Array:
+			expanded := NewArray()
+			for i:=0; i<v.Size(); i++ {
+				expanded.Add(expandName(v.At(i)))
+			}
+			value = expanded
+		}
+		if full,exists := inlineImageKeys[key]; exists {
+			key = full
+		}
+		result.Add(key, value)
+	}
+	return result
+}
+
+// abbreviateInlineImageParameters() returns a copy of the parameters
+// of an image with keys, and the names of color spaces and filters,
+// replaced by their abbreviations, as written in inline images.
+// /Type and /Subtype are omitted.
+func abbreviateInlineImageParameters(parameters ProtectedDictionary) Dictionary {
+	abbreviateName := func(o Object) Object {
+		if n,ok := o.(Name); ok {
+			if abbreviation,exists := inlineImageNameAbbreviations[n.String()]; exists {
+				return NewName(abbreviation)
+			}
+		}
+		return o
+	}
+
+	result := NewDictionary()
+	for _,key := range parameters.Keys() {
+		if key == "Type" || key == "Subtype" {
+			continue
+		}
+		value := parameters.Get(key)
+		if abbreviation,exists := inlineImageKeyAbbreviations[key]; exists {
+			key = abbreviation
+		}
+		if key == "CS" || key == "F" {
+			switch v := value.(type) {
+			case Name:
+				value = abbreviateName(v)
+			case ProtectedArray:
+				abbreviated := NewArray()
+				for i:=0; i<v.Size(); i++ {
+					abbreviated.Add(abbreviateName(v.At(i)))
+				}
+				value = abbreviated
+			}
+		}
+		result.Add(key, value)
+	}
+	return result
+}
+
+var (
+	inlineImageKeyAbbreviations = reverseMap(inlineImageKeys)
+	inlineImageNameAbbreviations = reverseMap(inlineImageNames) )
+
+func reverseMap(m map[string]string) map[string]string {
+	result := make(map[string]string, len(m))
+	for k,v := range m {
+		result[v] = k
+	}
+	return result
+}
+
+// InlineImageToXObject() returns the image XObject equivalent to the
+// inline image op, an operation returned by ContentParser.Next() with
+// the operator "BI".  The image data is copied as it is, still
+// encoded by any filters the parameters name.  An inline image whose
+// color space is named in the page's resources produces an XObject
+// whose /ColorSpace must be replaced by the color space itself.
+func InlineImageToXObject(op Operation) (Stream, error) {
+	if op.Operator != "BI" || len(op.Operands) != 2 {
+		return nil, errors.New(`Operation is not an inline image`)
+	}
+	parameters,ok1 := op.Operands[0].(ProtectedDictionary)
+	data,ok2 := op.Operands[1].(ProtectString)
+	if !ok1 || !ok2 {
+		return nil, errors.New(`Operation is not an inline image`)
+	}
+	dictionary := expandInlineImageParameters(parameters)
+	dictionary.Remove("Length")
+	return NewStreamFromContents(dictionary, data.Bytes(), nil), nil
+}
+
+// XObjectToInlineImage() returns a "BI" operation, for use with
+// ContentSerializer, that draws the image XObject image.  The image
+// data is copied as it is, still encoded.  Images that use features
+// inline images can't represent, such as JPEG 2000 compression, soft
+// masks, or indirect objects such as ICC profiles, produce an error.
+// Inline images are intended for small images; large ones are better
+// left as XObjects.
+func XObjectToInlineImage(image ProtectedStream) (Operation, error) {
+	dictionary := image.Dictionary()
+	if subtype,_ := dictionary.GetName("Subtype"); subtype != "Image" {
+		return Operation{}, errors.New(`Stream is not an image XObject`)
+	}
+	for _,key := range []string{"SMask", "Mask", "SMaskInData"} {
+		if dictionary.Get(key) != nil {
+			return Operation{}, fmt.Errorf(`Inline image cannot have /%s`, key)
+		}
+	}
+	filters,_ := streamFilters(dictionary)
+	for _,filter := range filters {
+		if filter == "JPXDecode" || filter == "JBIG2Decode" {
+			return Operation{}, fmt.Errorf(`Inline image cannot use %s`, filter)
+		}
+	}
+
+	parameters := NewDictionary()
+	for _,key := range dictionary.Keys() {
+		if _,allowed := inlineImageKeyAbbreviations[key]; !allowed || key == "Length" {
+			continue
+		}
+		value := dictionary.Get(key)
+		if containsIndirect(value) {
+			return Operation{}, fmt.Errorf(`Inline image /%s cannot refer to an indirect object`, key)
+		}
+		parameters.Add(key, value)
+	}
+	data,err := encodedData(image)
+	if err != nil {
+		return Operation{}, err
+	}
+	return Operation{"BI", []Object{abbreviateInlineImageParameters(parameters), NewBinaryString(data)}}, nil
+}
+
+// containsIndirect() returns true if o is or contains an indirect
+// reference.
+func containsIndirect(o Object) bool {
+	switch v := o.(type) {
+	case ProtectedIndirect:
+		return true
+	case ProtectedArray:
+		for i:=0; i<v.Size(); i++ {
+			if containsIndirect(v.At(i)) {
+				return true
+			}
+		}
+	case ProtectedDictionary:
+		contains := false
+		v.ForEach(func(key string, value Object) {
+			contains = contains || containsIndirect(value)
+		})
+		return contains
+	}
+	return false
+}
+
+// Width() and Height() return the dimensions of the image in
+// samples.
+func (pi PageImage) Width() int {
+	width,_ := pi.Stream.Dictionary().GetInt("Width")
+	return width
+}
+
+func (pi PageImage) Height() int {
+	height,_ := pi.Stream.Dictionary().GetInt("Height")
+	return height
+}
+
+// Export() writes the image to w in the format that best preserves
+// it and returns the name of the format.  JPEG ("jpeg") and JPEG 2000
+// ("jp2") data are written as they are stored.  CCITT fax data is
+// wrapped in a TIFF file ("tiff").  Other images are decoded by
+// Image() and written as PNG ("png").
+func (pi PageImage) Export(w io.Writer) (string, error) {
+	filters,parameters := streamFilters(pi.Stream.Dictionary())
+	if n := len(filters); n > 0 {
+		var format string
+		switch filters[n-1] {
+		case "DCTDecode":
+			format = "jpeg"
+		case "JPXDecode":
+			format = "jp2"
+		case "CCITTFaxDecode":
+			format = "tiff"
+		}
+		if format != "" {
+			data,err := pi.decodeAllBut(filters, parameters, 1)
+			if err != nil {
+				return "", err
+			}
+			if format == "tiff" {
+				err = pi.writeCCITTAsTIFF(w, data, parameters[n-1])
+			} else {
+				_,err = w.Write(data)
+			}
+			return format, err
+		}
+	}
+
+	img,err := pi.Image()
+	if err != nil {
+		return "", err
+	}
+	return "png", png.Encode(w, img)
+}
+
+// decodeAllBut() applies all but the last n filters to the image
+// data.
+func (pi PageImage) decodeAllBut(filters []string, parameters []ProtectedDictionary, n int) ([]byte, error) {
+	k := len(filters) - n
+	data,err := encodedData(pi.Stream)
+	if err != nil {
+		return nil, err
+	}
+	r := limitDecoded(pi.Stream, decodeFilters(bytes.NewReader(data), filters[:k], parameters[:k]))
+	if r == nil {
+		return nil, unsupportedImage
+	}
+	return ioutil.ReadAll(r)
+}
+
+// Image() decodes the image.  Images encoded with DCTDecode are
+// decoded with image/jpeg.  Others must use filters for which
+// decoders are registered and must use a gray, RGB, CMYK, ICC-based,
+// or indexed color space with 1, 2, 4, 8, or 16 bits per component.
+// The /Decode array is applied, but soft masks and color key masking
+// are not.
+func (pi PageImage) Image() (image.Image, error) {
+	dictionary := pi.Stream.Dictionary()
+	filters,parameters := streamFilters(dictionary)
+	if n := len(filters); n > 0 && filters[n-1] == "DCTDecode" {
+		data,err := pi.decodeAllBut(filters, parameters, 1)
+		if err != nil {
+			return nil, err
+		}
+		return jpeg.Decode(bytes.NewReader(data))
+	}
+
+	data,err := pi.decodeAllBut(filters, parameters, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	width, height := pi.Width(), pi.Height()
+	bitsPerComponent,_ := dictionary.GetInt("BitsPerComponent")
+	var space *imageColorSpace
+	if mask,_ := dictionary.GetBoolean("ImageMask"); mask {
+		bitsPerComponent = 1
+		space = &imageColorSpace{components: 1}
+	} else {
+		space = pi.colorSpace(dictionary.Get("ColorSpace"), 0)
+	}
+	if space == nil || width <= 0 || height <= 0 {
+		return nil, unsupportedImage
+	}
+	switch bitsPerComponent {
+	case 1, 2, 4, 8, 16:
+	default:
+		return nil, unsupportedImage
+	}
+
+	rowBytes := (width*space.components*bitsPerComponent + 7) / 8
+	if len(data) < rowBytes*height {
+		return nil, truncatedImage
+	}
+
+	// Each component is mapped through the /Decode array to a
+	// value from 0 to 1, or to an index for indexed images.
+	maxSample := float64(uint32(1)<<uint(bitsPerComponent) - 1)
+	decode := make([]float64, 2*space.components)
+	for i:=0; i<space.components; i++ {
+		decode[2*i], decode[2*i+1] = 0, 1
+		if space.palette != nil {
+			decode[2*i+1] = maxSample
+		}
+	}
+	if array := dictionary.GetArray("Decode"); array != nil && array.Size() == len(decode) {
+		for i := range decode {
+			decode[i],_ = numericValue(array.At(i))
+		}
+	}
+	// An image mask paints where samples are 0 by default.
+	// Painted areas are shown in black.
+	if mask,_ := dictionary.GetBoolean("ImageMask"); mask {
+		decode[0], decode[1] = 1-decode[0], 1-decode[1]
+	}
+
+	bounds := image.Rect(0, 0, width, height)
+	var (
+		result image.Image
+		set func(x, y int, components []float64) )
+	switch {
+	case space.palette != nil:
+		img := image.NewPaletted(bounds, space.palette)
+		set = func(x, y int, c []float64) { img.SetColorIndex(x, y, uint8(c[0] + 0.5)) }
+		result = img
+	case space.components == 1:
+		img := image.NewGray(bounds)
+		set = func(x, y int, c []float64) { img.SetGray(x, y, color.Gray{unit8(c[0])}) }
+		result = img
+	case space.components == 3:
+		img := image.NewRGBA(bounds)
+		set = func(x, y int, c []float64) { img.SetRGBA(x, y, color.RGBA{unit8(c[0]), unit8(c[1]), unit8(c[2]), 0xff}) }
+		result = img
+	default:
+		img := image.NewCMYK(bounds)
+		set = func(x, y int, c []float64) { img.SetCMYK(x, y, color.CMYK{unit8(c[0]), unit8(c[1]), unit8(c[2]), unit8(c[3])}) }
+		result = img
+	}
+
+	components := make([]float64, space.components)
+	for y:=0; y<height; y++ {
+		row := data[y*rowBytes:(y+1)*rowBytes]
+		for x:=0; x<width; x++ {
+			for i := range components {
+				sample := float64(sampleAt(row, x*space.components+i, bitsPerComponent))
+				components[i] = decode[2*i] + sample*(decode[2*i+1] - decode[2*i])/maxSample
+			}
+			set(x, y, components)
+		}
+	}
+	return result, nil
+}
+
+// sampleAt() returns sample n of a row packed with the specified
+// number of bits per sample.
+func sampleAt(row []byte, n, bits int) uint32 {
+	switch bits {
+	case 8:
+		return uint32(row[n])
+	case 16:
+		return uint32(row[2*n])<<8 | uint32(row[2*n+1])
+	}
+	bit := n*bits
+	shift := uint(8 - bits - bit%8)
+	return uint32(row[bit/8]>>shift) & (1<<uint(bits) - 1)
+}
+
+// unit8() converts a value from 0 to 1 to a byte.
+func unit8(v float64) uint8 {
+	switch {
+	case v <= 0:
+		return 0
+	case v >= 1:
+		return 0xff
+	}
+	return uint8(v*0xff + 0.5)
+}
+
+// imageColorSpace describes how image samples are converted to
+// colors.  For indexed color spaces, palette is not nil and there is
+// one component, the index.
+type imageColorSpace struct {
+	components int
+	palette color.Palette
+}
+
+// colorSpace() interprets an image's /ColorSpace.  It returns nil for
+// unsupported color spaces.
+func (pi PageImage) colorSpace(o Object, depth int) *imageColorSpace {
+	if o == nil || depth > 2 {
+		return nil
+	}
+	switch cs := o.Dereference().(type) {
+	case Name:
+		switch cs.String() {
+		case "DeviceGray", "CalGray", "G":
+			return &imageColorSpace{components: 1}
+		case "DeviceRGB", "CalRGB", "RGB":
+			return &imageColorSpace{components: 3}
+		case "DeviceCMYK", "CMYK":
+			return &imageColorSpace{components: 4}
+		}
+		// Inline images may name color spaces in the resources.
+		if pi.resources != nil {
+			if spaces := pi.resources.GetDictionary("ColorSpace"); spaces != nil {
+				return pi.colorSpace(spaces.Get(cs.String()), depth+1)
+			}
+		}
+	case ProtectedArray:
+		if cs.Size() == 0 {
+			return nil
+		}
+		family,_ := cs.At(0).Dereference().(Name)
+		if family == nil {
+			return nil
+		}
+		switch family.String() {
+		case "CalGray", "CalRGB":
+			return pi.colorSpace(family, depth+1)
+		case "ICCBased":
+			if cs.Size() < 2 {
+				return nil
+			}
+			if profile,ok := cs.At(1).Dereference().(ProtectedStream); ok {
+				switch n,_ := profile.Dictionary().GetInt("N"); n {
+				case 1:
+					return pi.colorSpace(NewName("DeviceGray"), depth+1)
+				case 3:
+					return pi.colorSpace(NewName("DeviceRGB"), depth+1)
+				case 4:
+					return pi.colorSpace(NewName("DeviceCMYK"), depth+1)
+				}
+			}
+		case "Indexed", "I":
+			if cs.Size() == 4 {
+				return pi.indexedColorSpace(cs, depth)
+			}
+		}
+	}
+	return nil
+}
+
+// indexedColorSpace() interprets [/Indexed base hival lookup].
+func (pi PageImage) indexedColorSpace(cs ProtectedArray, depth int) *imageColorSpace {
+	base := pi.colorSpace(cs.At(1), depth+1)
+	hival,ok := numericValue(cs.At(2))
+	if base == nil || base.palette != nil || !ok || hival < 0 || hival > 255 {
+		return nil
+	}
+	var lookup []byte
+	switch table := cs.At(3).Dereference().(type) {
+	case ProtectString:
+		lookup = table.Bytes()
+	case ProtectedStream:
+		if r := table.Reader(); r != nil {
+			lookup,_ = ioutil.ReadAll(r)
+		}
+	}
+	entries := int(hival) + 1
+	if len(lookup) < entries*base.components {
+		return nil
+	}
+	palette := make(color.Palette, entries)
+	for i := range palette {
+		c := lookup[i*base.components:(i+1)*base.components]
+		switch base.components {
+		case 1:
+			palette[i] = color.Gray{c[0]}
+		case 3:
+			palette[i] = color.RGBA{c[0], c[1], c[2], 0xff}
+		default:
+			palette[i] = color.CMYK{c[0], c[1], c[2], c[3]}
+		}
+	}
+	return &imageColorSpace{components: 1, palette: palette}
+}
+
+// writeCCITTAsTIFF() writes CCITT fax data as a single-strip TIFF
+// file, which can hold it without decoding.
+func (pi PageImage) writeCCITTAsTIFF(w io.Writer, data []byte, parameters ProtectedDictionary) error {
+	k, columns, blackIs1 := 0, 1728, false
+	rows := pi.Height()
+	if parameters != nil {
+		if v,ok := parameters.GetInt("K"); ok {
+			k = v
+		}
+		if v,ok := parameters.GetInt("Columns"); ok {
+			columns = v
+		}
+		if v,ok := parameters.GetInt("Rows"); ok {
+			rows = v
+		}
+		blackIs1,_ = parameters.GetBoolean("BlackIs1")
+	}
+
+	// TIFF photometric interpretation: 0 means 0 is white.
+	photometric := 1
+	if blackIs1 {
+		photometric = 0
+	}
+	if decode := pi.Stream.Dictionary().GetArray("Decode"); decode != nil && decode.Size() == 2 {
+		if v,_ := numericValue(decode.At(0)); v == 1 {
+			photometric = 1 - photometric
+		}
+	}
+
+	type entry struct {
+		tag, kind uint16
+		value uint32
+	}
+	const (
+		short = 3
+		long = 4 )
+	entries := []entry{
+		{256, long, uint32(columns)},
+		{257, long, uint32(rows)},
+		{258, short, 1},
+		{259, short, 3},
+		{262, short, uint32(photometric)},
+		{273, long, 0},
+		{277, short, 1},
+		{278, long, uint32(rows)},
+		{279, long, uint32(len(data))}}
+	switch {
+	case k < 0:
+		entries[3].value = 4
+		entries = append(entries, entry{293, long, 0})
+	case k > 0:
+		entries = append(entries, entry{292, long, 1})
+	default:
+		entries = append(entries, entry{292, long, 0})
+	}
+	// The image data follows the header and directory.
+	dataOffset := 8 + 2 + 12*len(entries) + 4
+	entries[5].value = uint32(dataOffset)
+
+	var header bytes.Buffer
+	header.WriteString("II*\x00")
+	binary.Write(&header, binary.LittleEndian, uint32(8))
+	binary.Write(&header, binary.LittleEndian, uint16(len(entries)))
+	for _,e := range entries {
+		binary.Write(&header, binary.LittleEndian, e.tag)
+		binary.Write(&header, binary.LittleEndian, e.kind)
+		binary.Write(&header, binary.LittleEndian, uint32(1))
+		if e.kind == short {
+			binary.Write(&header, binary.LittleEndian, uint16(e.value))
+			binary.Write(&header, binary.LittleEndian, uint16(0))
+		} else {
+			binary.Write(&header, binary.LittleEndian, e.value)
+		}
+	}
+	binary.Write(&header, binary.LittleEndian, uint32(0))
+	if _,err := w.Write(header.Bytes()); err != nil {
+		return err
+	}
+	_,err := w.Write(data)
+	return err
+}
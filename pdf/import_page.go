@@ -0,0 +1,116 @@
+package pdf
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strconv" )
+
+// An ImportedPage is a page of another document that has been copied
+// into a document as a form XObject by ImportPageAsXObject().  It
+// can be drawn on any number of pages at any position and scale.
+type ImportedPage struct {
+	// Reference refers to the form XObject.
+	Reference Indirect
+	// Width and Height are the dimensions of the page as
+	// displayed, i.e., of its crop box after any /Rotate has been
+	// applied.
+	Width, Height float64
+}
+
+// copierFor() returns the ObjectCopier used to import objects from
+// src into d, so that objects shared by several imports (e.g.,
+// fonts) are copied only once.
+func (d *Document) copierFor(src File) *ObjectCopier {
+	if d.copiers == nil {
+		d.copiers = make(map[File]*ObjectCopier)
+	}
+	copier,exists := d.copiers[src]
+	if !exists {
+		copier = NewObjectCopier(d.file, src)
+		d.copiers[src] = copier
+	}
+	return copier
+}
+
+// ImportPageAsXObject() copies page n (numbered from 0) of src into d
+// as a form XObject.  The form contains the page's contents and
+// resources but not its annotations.  Its coordinate system has the
+// lower-left corner of the displayed page at the origin.  An error
+// reading the resources is returned by d.Close().
+func (d *Document) ImportPageAsXObject(src *Document, n uint) *ImportedPage {
+	page := src.Page(n)
+	if page == nil {
+		return nil
+	}
+
+	box,ok := page.CropBox()
+	if !ok {
+		box = mediaBox(page)
+	}
+	llx, lly := box[0], box[1]
+	width, height := box[2]-box[0], box[3]-box[1]
+
+	// Choose a matrix that moves the lower-left corner of the
+	// box to the origin and undoes the page's rotation.
+	rotate,_ := page.GetInt("Rotate")
+	var matrix [6]float64
+	switch ((rotate % 360) + 360) % 360 {
+	case 90:
+		matrix = [6]float64{0, -1, 1, 0, -lly, llx+width}
+		width, height = height, width
+	case 180:
+		matrix = [6]float64{-1, 0, 0, -1, llx+width, lly+height}
+	case 270:
+		matrix = [6]float64{0, 1, -1, 0, lly+height, -llx}
+		width, height = height, width
+	default:
+		matrix = [6]float64{1, 0, 0, 1, -llx, -lly}
+	}
+	matrixArray := NewArray()
+	for _,v := range matrix {
+		matrixArray.Add(NewNumeric(v))
+	}
+
+	form := d.streamFactory.New()
+	form.Add("Type", NewName("XObject"))
+	form.Add("Subtype", NewName("Form"))
+	form.Add("BBox", NewRectangle(box[0], box[1], box[2], box[3]))
+	form.Add("Matrix", matrixArray)
+	if resources := page.GetDictionary("Resources"); resources != nil {
+		copier := d.copierFor(src.file)
+		form.Add("Resources", copier.Copy(resources))
+		d.noteCopyError(copier)
+	}
+	if reader := page.Reader(); reader != nil {
+		contents,_ := ioutil.ReadAll(reader)
+		form.Write(contents)
+	}
+
+	return &ImportedPage{d.WriteObject(form), width, height}
+}
+
+// AddXObject() adds a reference to an XObject to the page's
+// resources and returns the name by which the page's contents may
+// refer to it.
+func (p *Page) AddXObject(reference Indirect) string {
+	if p.xobjectResources == nil {
+		p.xobjectResources = NewDictionary()
+		p.resources.Add("XObject", p.xobjectResources)
+		p.xobjectNames = make(map[Indirect]string)
+	}
+	name,exists := p.xobjectNames[reference]
+	if !exists {
+		name = "X" + strconv.Itoa(len(p.xobjectNames) + 1)
+		p.xobjectResources.Add(name, reference)
+		p.xobjectNames[reference] = name
+	}
+	return name
+}
+
+// DrawImportedPage() draws an imported page with its lower-left
+// corner at (x, y), scaled by the specified factor.
+func (p *Page) DrawImportedPage(imported *ImportedPage, x, y, scale float64) {
+	precision := realPrecision(p.fileList...)
+	fmt.Fprintf(p, " q %s 0 0 %s %s %s cm /%s Do Q ", FormatReal(scale, precision), FormatReal(scale, precision),
+		FormatReal(x, precision), FormatReal(y, precision), p.AddXObject(imported.Reference))
+}
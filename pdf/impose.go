@@ -0,0 +1,120 @@
+package pdf
+
+import (
+	"math"
+	"os" )
+
+// An ImpositionLayout specifies how source pages are arranged on the
+// sheets produced by Impose().
+type ImpositionLayout int
+
+const (
+	// TwoUp places pages side by side, two to a sheet, in order.
+	TwoUp ImpositionLayout = iota
+	// FourUp places pages in a 2x2 grid, four to a sheet, left to
+	// right and top to bottom.
+	FourUp
+	// Booklet places pages two to a sheet side in the order
+	// needed to fold and saddle-stitch the printed sheets into a
+	// booklet.  Consecutive sheets in the output are the front and
+	// back of a printed sheet.  Blank pages are added at the end as
+	// necessary to make the page count a multiple of four.
+	Booklet )
+
+// ImpositionOptions controls the output of Impose().
+type ImpositionOptions struct {
+	Layout ImpositionLayout
+	// SheetWidth and SheetHeight are the dimensions of the
+	// output sheets.  If either is zero, the sheets are sized to
+	// hold the first source page at full size.
+	SheetWidth, SheetHeight float64
+	// Margin is the space between the edge of a sheet and the
+	// pages.
+	Margin float64
+	// Gutter is the space between adjacent pages.
+	Gutter float64
+}
+
+// Impose() creates a new document named filename containing the
+// pages of src arranged on sheets according to options.  Each source
+// page is imported as a form XObject with ImportPageAsXObject(),
+// scaled to fit its cell without distortion, and centered in it.  The
+// new document is returned open so that the client may modify it
+// before calling Close().
+func Impose(filename string, src *Document, options ImpositionOptions) *Document {
+	src.finishCurrentPage()
+
+	columns, rows := 2, 1
+	if options.Layout == FourUp {
+		rows = 2
+	}
+
+	out := OpenDocument(filename, os.O_RDWR|os.O_CREATE|os.O_TRUNC)
+	if src.pageCount == 0 {
+		return out
+	}
+
+	imported := make([]*ImportedPage, src.pageCount)
+	for i := range imported {
+		imported[i] = out.ImportPageAsXObject(src, uint(i))
+	}
+
+	width, height := options.SheetWidth, options.SheetHeight
+	if width == 0 || height == 0 {
+		width = float64(columns)*imported[0].Width + float64(columns-1)*options.Gutter + 2*options.Margin
+		height = float64(rows)*imported[0].Height + float64(rows-1)*options.Gutter + 2*options.Margin
+	}
+	cellWidth := (width - 2*options.Margin - float64(columns-1)*options.Gutter) / float64(columns)
+	cellHeight := (height - 2*options.Margin - float64(rows-1)*options.Gutter) / float64(rows)
+
+	order := impositionOrder(options.Layout, len(imported))
+	perSheet := columns*rows
+	for first:=0; first<len(order); first+=perSheet {
+		sheet := out.NewPage()
+		sheet.SetMediaBox(0, 0, width, height)
+		for cell:=0; cell<perSheet && first+cell<len(order); cell++ {
+			n := order[first+cell]
+			if n < 0 || imported[n] == nil {
+				continue
+			}
+			page := imported[n]
+			column, row := cell % columns, rows - 1 - cell/columns
+			scale := math.Min(cellWidth/page.Width, cellHeight/page.Height)
+			x := options.Margin + float64(column)*(cellWidth + options.Gutter) + (cellWidth - scale*page.Width)/2
+			y := options.Margin + float64(row)*(cellHeight + options.Gutter) + (cellHeight - scale*page.Height)/2
+			sheet.DrawImportedPage(page, x, y, scale)
+		}
+	}
+	return out
+}
+
+// impositionOrder() returns the source page placed in each cell of
+// each sheet in turn.  Blank cells are represented by -1.
+func impositionOrder(layout ImpositionLayout, pageCount int) []int {
+	if layout != Booklet {
+		order := make([]int, pageCount)
+		for i := range order {
+			order[i] = i
+		}
+		return order
+	}
+
+	count := (pageCount + 3) / 4 * 4
+	order := make([]int, 0, count)
+	for side:=0; side<count/2; side++ {
+		// Fronts have the higher-numbered page on the left;
+		// backs have it on the right.
+		low, high := side, count-1-side
+		if side % 2 == 0 {
+			order = append(order, high, low)
+		} else {
+			order = append(order, low, high)
+		}
+	}
+	for i := range order {
+		if order[i] >= pageCount {
+			order[i] = -1
+		}
+	}
+	return order
+}
@@ -2,8 +2,9 @@ package pdf
 
 import (
 	"errors"
-	"fmt"
-	"strconv" )
+	"fmt" )
+
+var referenceCycle = errors.New(`Reference refers to itself through a chain of references`)
 
 // Implements:
 // 	pdf.Object
@@ -12,11 +13,13 @@ type ProtectedIndirect interface {
 	Object
 	ObjectNumber(f File) ObjectNumber
 	BoundToFile(f File) bool
+	Finalized() bool
 }
 
 type Indirect interface {
 	ProtectedIndirect
 	Write(o Object) Indirect
+	Finalize(o Object) Indirect
 }
 
 type indirect struct {
@@ -52,13 +55,18 @@ cannot be written to a buffer in its entirely and written as an atomic
 stream object.  Nonetheless, a pdf.Indirect object supports this
 programming style where object references are written to a file before
 the objects they refer to have been completely defined.  We do *not*
-support this model with streams, however, and *do* require streams to
-be completed in memory before any portion of the strean is written to
-a file.  A pdf.Indirect can be written before the direct object it
+support this model with streams constructed in memory, which must be
+completed before any portion of the stream is written to a file.  A
+stream constructed by NewStreamFromReader() is written as its contents
+are read, with an indirect /Length written after the stream.  A
+pdf.Indirect can be written before the direct object it
 references has been defined.  A pdf.Indirect obtains and reserves an
 object number whenever it is written to a file, whether or not the
 object being referenced has yet been specified.  Eventually, the
-Write() method must be called passing the object being referenced.  At
+Finalize() or Write() method must be called passing the object being
+referenced.  Finalize() differs from Write() only in refusing to
+replace an object that has already been written, which catches
+generators that finalize the same forward reference twice.  At
 that moment, the object being referenced is written to all files to
 which the pdf.Indirect was written.  If the pdf.Indirect is
 subsequently added to additional files, the Write()ed object must also
@@ -125,11 +133,23 @@ func NewIndirect(file... File) Indirect {
 func newIndirectWithNumber(objectNumber ObjectNumber, file File) Indirect {
 	result := new(indirect)
 	result.fileBindings = make(map[File]ObjectNumber,5)
+	file = bindingKey(file)
 	result.sourceFile = file
 	result.fileBindings[file] = objectNumber
 	return result
 }
 
+// bindingKey() returns the File under which bindings to f are
+// recorded.  A MemoryFile shares the bindings of the file it embeds,
+// which passes itself to objects it serializes, so that an object is
+// given only one number in it.
+func bindingKey(f File) File {
+	if b,ok := f.(interface{ bindingFile() File }); ok {
+		return b.bindingFile()
+	}
+	return f
+}
+
 func (i *indirect) Clone() Object {
 	// Return a reference since all indirect references to the
 	// same object should be the same.
@@ -141,12 +161,51 @@ func (i *indirect) Dereference() Object {
 		panic (errors.New(`Attempt to deference an object with no known source`))
 	}
 
-	object,err := i.sourceFile.Object(i.ObjectNumber(i.sourceFile))
-	if err != nil {
-		panic (errors.New(fmt.Sprintf(`Unable to read object at %v`, i.ObjectNumber(i.sourceFile))))
+	// An object may itself be a reference, which is followed in
+	// turn.  The references seen are only recorded once a chain is
+	// found so that the common case allocates nothing.
+	var visited map[objectKey]bool
+	for {
+		object,err := i.sourceFile.Object(i.ObjectNumber(i.sourceFile))
+		if err != nil {
+			panic (errors.New(fmt.Sprintf(`Unable to read object at %v`, i.ObjectNumber(i.sourceFile))))
+		}
+		next,ok := object.(*indirect)
+		if !ok || next.sourceFile == nil {
+			return object.Dereference()
+		}
+		if visited == nil {
+			visited = make(map[objectKey]bool)
+		}
+		key,_ := sourceKey(i)
+		visited[key] = true
+		if key,_ = sourceKey(next); visited[key] {
+			panic (referenceCycle)
+		}
+		i = next
 	}
-	// TODO:  Think about whether Dereference() is a good idea here.
-	return object.Dereference()
+}
+
+// objectKey identifies an object in a file from which it was read.
+type objectKey struct {
+	file File
+	number ObjectNumber
+}
+
+// sourceKey() returns the key of the object to which o refers if o is
+// a reference read from a file.  Unlike ObjectNumber(), it never binds
+// o to another file, so it may be used to detect cycles while walking
+// the object graph.
+func sourceKey(o Object) (objectKey, bool) {
+	switch r := o.(type) {
+	case *indirect:
+		if r.sourceFile != nil {
+			return objectKey{r.sourceFile, r.fileBindings[r.sourceFile]}, true
+		}
+	case protectedIndirect:
+		return sourceKey(r.i)
+	}
+	return objectKey{}, false
 }
 
 func (i *indirect) Protect() Object {
@@ -175,9 +234,9 @@ func (i *indirect) Serialize(w Writer, file ...File) {
 			panic("Attempt to Serialize to a closed file")
 		}
 		objectNumber := i.ObjectNumber(file[0])
-		w.WriteString(strconv.FormatInt(int64(objectNumber.number), 10))
+		writeInt(w, int64(objectNumber.number))
 		w.WriteByte(' ')
-		w.WriteString(strconv.FormatInt(int64(objectNumber.generation), 10))
+		writeInt(w, int64(objectNumber.generation))
 		w.WriteString(" R")
 	}
 }
@@ -215,6 +274,7 @@ func (i *indirect) Write(o Object) Indirect{
 // Indirect that may be used for backward references.  In the latter
 // case, the reference will only be tied to only one file.
 func (i *indirect) ObjectNumber(f File) ObjectNumber {
+	f = bindingKey(f)
 	destObjectNumber,exists := i.fileBindings[f]
 	if !exists {
 		destObjectNumber = f.ReserveObjectNumber(i)
@@ -232,8 +292,26 @@ func (i *indirect) ObjectNumber(f File) ObjectNumber {
 	return destObjectNumber
 }
 
+// Finalize() supplies the object to which a forward reference refers,
+// writing it to every file to which the Indirect has been bound, as
+// Write() does.  It panics if the object has already been written,
+// since a forward reference should be finalized only once.
+func (i *indirect) Finalize(o Object) Indirect {
+	if i.Finalized() {
+		panic(errors.New(`Indirect.Finalize() called on an object that has already been written`))
+	}
+	return i.Write(o)
+}
+
+// Finalized() returns true once the object to which the Indirect
+// refers is known: after Write() or Finalize() has been called, or if
+// the Indirect was read from a file.
+func (i *indirect) Finalized() bool {
+	return i.sourceFile != nil
+}
+
 func (i *indirect) BoundToFile(f File) bool {
-	_,exists := i.fileBindings[f]
+	_,exists := i.fileBindings[bindingKey(f)]
 	return exists
 }
 
@@ -242,12 +320,14 @@ type protectedIndirect struct {
 	i Indirect
 }
 
+// Return value of Clone() can safely be cast to Indirect.  As with
+// indirect.Clone(), the result refers to the same object.
 func (roi protectedIndirect) Clone() Object {
-	return roi
+	return roi.i.Clone()
 }
 
 func (roi protectedIndirect) Dereference() Object {
-	return roi.i
+	return roi.i.Dereference().Protect()
 }
 
 func (roi protectedIndirect) Serialize(w Writer, file... File) {
@@ -270,6 +350,10 @@ func (roi protectedIndirect) BoundToFile(f File) bool {
 	return roi.i.BoundToFile(f)
 }
 
+func (roi protectedIndirect) Finalized() bool {
+	return roi.i.Finalized()
+}
+
 
 
 
@@ -0,0 +1,418 @@
+package pdf
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings")
+
+var (
+	invalidJSONObject = errors.New(`JSON value doesn't represent a PDF object`)
+	invalidJSONDocument = errors.New(`JSON document must have objects and a trailer with /Root`)
+	referenceWithoutFile = errors.New(`References can be converted only for a File`))
+
+// The JSON representation of objects follows the one qpdf's --json
+// uses:
+//
+//	null, true, false	null and booleans
+//	12, 1.5		integers and reals; reals always contain a "."
+//	"/Name"		names
+//	"u:text"	strings of printable ASCII
+//	"b:0a1b"	other strings, in hexadecimal
+//	"12 0 R"	references
+//	[...]		arrays
+//	{"/Key": ...}	dictionaries
+//	{"stream": {"dict": {...}, "data": "..."}}
+//			streams, with their encoded contents in base64
+//			and without /Length, which is recomputed
+//
+// A document is an object with the members "version", "trailer", and
+// "objects", the last of which maps references such as "12 0 R" to
+// objects.
+
+// jsonStream is the representation of a stream's dictionary and
+// contents.
+type jsonStream struct {
+	Dict interface{} `json:"dict"`
+	Data []byte `json:"data"`
+}
+
+// jsonDocument is the representation of a document.
+type jsonDocument struct {
+	Version string `json:"version"`
+	Trailer interface{} `json:"trailer"`
+	Objects map[string]interface{} `json:"objects"`
+}
+
+// ObjectToJSON() returns the JSON representation of object.  File is
+// the File in which object's references refer, and is required only
+// if it contains references.  The objects to which it refers are not
+// included.
+func ObjectToJSON(object Object, file ...File) ([]byte, error) {
+	value,err := objectToJSON(object, file, nil)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(value)
+}
+
+// ObjectFromJSON() returns the object that data represents.  File is
+// the File in which its references refer, and is required only if it
+// contains references.
+func ObjectFromJSON(data []byte, file ...File) (Object, error) {
+	decoder := json.NewDecoder(strings.NewReader(string(data)))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	return objectFromJSON(value, func(o ObjectNumber) (Object, error) {
+		if len(file) == 0 {
+			return nil, referenceWithoutFile
+		}
+		return file[0].Indirect(o), nil
+	})
+}
+
+// objectToJSON() returns the value that encoding/json marshals as the
+// representation of object.  Each reference is passed to reference,
+// if it isn't nil.
+func objectToJSON(object Object, file []File, reference func(ObjectNumber)) (interface{}, error) {
+	switch t := object.(type) {
+	case nil, *Null:
+		return nil, nil
+	case Boolean:
+		return t.Value(), nil
+	case *IntNumeric:
+		return json.Number(strconv.Itoa(t.value)), nil
+	case *RealNumeric:
+		s := strconv.FormatFloat(float64(t.value), 'f', -1, 32)
+		if !strings.Contains(s, ".") {
+			s += ".0"
+		}
+		return json.Number(s), nil
+	case Name:
+		return "/" + t.String(), nil
+	case ProtectString:
+		return stringToJSON(t.Bytes()), nil
+	case ProtectedIndirect:
+		if len(file) == 0 {
+			return nil, referenceWithoutFile
+		}
+		o := t.ObjectNumber(file[0])
+		if reference != nil {
+			reference(o)
+		}
+		return o.String(), nil
+	case ProtectedStream:
+		s,ok := streamImpl(t)
+		if !ok {
+			return nil, invalidJSONObject
+		}
+		dictionary,contents,err := s.encode(file...)
+		if err != nil {
+			return nil, err
+		}
+		dictionary.Remove("Length")
+		d,err := objectToJSON(dictionary, file, reference)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"stream": jsonStream{d, contents}}, nil
+	case ProtectedArray:
+		result := make([]interface{}, t.Size())
+		for i := range result {
+			value,err := objectToJSON(t.At(i), file, reference)
+			if err != nil {
+				return nil, err
+			}
+			result[i] = value
+		}
+		return result, nil
+	case ProtectedDictionary:
+		result := make(map[string]interface{}, t.Size())
+		for _,key := range t.Keys() {
+			value,err := objectToJSON(t.Get(key), file, reference)
+			if err != nil {
+				return nil, err
+			}
+			result["/" + key] = value
+		}
+		return result, nil
+	}
+	return nil, invalidJSONObject
+}
+
+// streamImpl() returns the implementation of s.
+func streamImpl(s ProtectedStream) (*stream, bool) {
+	if p,ok := s.(protectedStream); ok {
+		s = p.s
+	}
+	impl,ok := s.(*stream)
+	return impl, ok
+}
+
+// stringToJSON() returns the representation of a string containing b.
+func stringToJSON(b []byte) string {
+	for _,c := range b {
+		if (c < ' ' || c > '~') && c != '\t' && c != '\n' && c != '\r' {
+			return "b:" + hex.EncodeToString(b)
+		}
+	}
+	return "u:" + string(b)
+}
+
+// objectFromJSON() returns the object that value, as decoded by
+// encoding/json with UseNumber(), represents.  Reference returns the
+// object to use for each reference.
+func objectFromJSON(value interface{}, reference func(ObjectNumber) (Object, error)) (Object, error) {
+	switch t := value.(type) {
+	case nil:
+		return NewNull(), nil
+	case bool:
+		return NewBoolean(t), nil
+	case json.Number:
+		if strings.ContainsAny(string(t), ".eE") {
+			f,err := strconv.ParseFloat(string(t), 64)
+			if err != nil {
+				return nil, invalidJSONObject
+			}
+			return NewRealNumeric(adjustRealRange(f)), nil
+		}
+		n,err := strconv.Atoi(string(t))
+		if err != nil {
+			return nil, invalidJSONObject
+		}
+		return NewIntNumeric(n), nil
+	case string:
+		switch {
+		case strings.HasPrefix(t, "/"):
+			return NewName(t[1:]), nil
+		case strings.HasPrefix(t, "u:"):
+			if stringToJSON([]byte(t[2:])) == t {
+				return NewBinaryString([]byte(t[2:])), nil
+			}
+			return NewTextString(t[2:]), nil
+		case strings.HasPrefix(t, "b:"):
+			b,err := hex.DecodeString(t[2:])
+			if err != nil {
+				return nil, invalidJSONObject
+			}
+			return NewBinaryString(b), nil
+		}
+		o,err := ParseObjectNumber(t)
+		if err != nil {
+			return nil, invalidJSONObject
+		}
+		return reference(o)
+	case []interface{}:
+		result := NewArray()
+		for _,element := range t {
+			object,err := objectFromJSON(element, reference)
+			if err != nil {
+				return nil, err
+			}
+			result.Add(object)
+		}
+		return result, nil
+	case map[string]interface{}:
+		if s,ok := t["stream"]; ok && len(t) == 1 {
+			return streamFromJSON(s, reference)
+		}
+		// Keys are added in sorted order, as encoding/json writes
+		// them, so that the result doesn't depend on the order of
+		// map iteration.
+		keys := make([]string, 0, len(t))
+		for key := range t {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		result := NewDictionary()
+		for _,key := range keys {
+			if !strings.HasPrefix(key, "/") {
+				return nil, invalidJSONObject
+			}
+			object,err := objectFromJSON(t[key], reference)
+			if err != nil {
+				return nil, err
+			}
+			result.Add(key[1:], object)
+		}
+		return result, nil
+	}
+	return nil, invalidJSONObject
+}
+
+// streamFromJSON() returns the stream that value, the member "stream"
+// of a stream's representation, represents.
+func streamFromJSON(value interface{}, reference func(ObjectNumber) (Object, error)) (Object, error) {
+	s,ok := value.(map[string]interface{})
+	if !ok {
+		return nil, invalidJSONObject
+	}
+	data,ok := s["data"].(string)
+	if !ok {
+		return nil, invalidJSONObject
+	}
+	contents,err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		return nil, invalidJSONObject
+	}
+	object,err := objectFromJSON(s["dict"], reference)
+	if err != nil {
+		return nil, err
+	}
+	dictionary,ok := object.(Dictionary)
+	if !ok {
+		return nil, invalidJSONObject
+	}
+	if _,isStream := object.(ProtectedStream); isStream {
+		return nil, invalidJSONObject
+	}
+	dictionary.Remove("Length")
+	return NewStreamFromContents(dictionary, contents, nil), nil
+}
+
+// ExportJSON() writes to w the JSON representation of the PDF file
+// named input: its version, its trailer, and the objects reachable
+// from the trailer, as qpdf's --json does.  Objects of encrypted
+// files are written decrypted, and /Encrypt is omitted.  Options are
+// passed to OpenFile() when reading input.
+func ExportJSON(input string, w io.Writer, options ...FileOption) error {
+	source,_,err := OpenFile(input, os.O_RDONLY, options...)
+	if err != nil {
+		return err
+	}
+	defer source.abandon()
+	return exportJSON(w, source)
+}
+
+func exportJSON(w io.Writer, source File) error {
+	var queue []ObjectNumber
+	seen := make(map[ObjectNumber]bool)
+	reference := func(o ObjectNumber) {
+		if !seen[o] {
+			seen[o] = true
+			queue = append(queue, o)
+		}
+	}
+	file := []File{source}
+
+	trailer := NewDictionary()
+	source.Trailer().ForEach(func(key string, value Object) {
+		switch key {
+		case "Size", "Prev", "Encrypt", "XRefStm":
+		default:
+			trailer.Add(key, value)
+		}
+	})
+	var err error
+	document := jsonDocument{Version: versionOf(source).String(), Objects: make(map[string]interface{})}
+	if document.Trailer,err = objectToJSON(trailer, file, reference); err != nil {
+		return err
+	}
+	for len(queue) > 0 {
+		o := queue[0]
+		queue = queue[1:]
+		object,err := source.Object(o)
+		if err != nil {
+			return err
+		}
+		if document.Objects[o.String()],err = objectToJSON(object, file, reference); err != nil {
+			return err
+		}
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", " ")
+	return encoder.Encode(document)
+}
+
+// ImportJSON() reads from r a document represented as ExportJSON()
+// writes it and writes it as a PDF file named output.  The objects are
+// renumbered, and references to objects the document doesn't contain
+// become null.  Options are passed to OpenFile() when writing output.
+func ImportJSON(r io.Reader, output string, options ...FileOption) error {
+	decoder := json.NewDecoder(r)
+	decoder.UseNumber()
+	var document jsonDocument
+	if err := decoder.Decode(&document); err != nil {
+		return err
+	}
+	destination,_,err := OpenFile(output, os.O_RDWR|os.O_CREATE|os.O_TRUNC, options...)
+	if err != nil {
+		return err
+	}
+	if err = importJSON(destination, &document); err != nil {
+		destination.abandon()
+		os.Remove(output)
+		return err
+	}
+	destination.Close()
+	return nil
+}
+
+func importJSON(destination *file, document *jsonDocument) error {
+	values := make(map[ObjectNumber]interface{}, len(document.Objects))
+	for key,value := range document.Objects {
+		o,err := ParseObjectNumber(key)
+		if err != nil {
+			return invalidJSONDocument
+		}
+		values[o] = value
+	}
+	indirects := make(map[ObjectNumber]Indirect)
+	reference := func(o ObjectNumber) (Object, error) {
+		if _,exists := values[o]; !exists {
+			return NewNull(), nil
+		}
+		if _,exists := indirects[o]; !exists {
+			indirects[o] = NewIndirect(destination)
+		}
+		return indirects[o], nil
+	}
+
+	if version,ok := parseVersion(document.Version); ok {
+		destination.requireVersion(version)
+	}
+	object,err := objectFromJSON(document.Trailer, reference)
+	if err != nil {
+		return err
+	}
+	trailer,ok := object.(Dictionary)
+	if _,isStream := object.(ProtectedStream); !ok || isStream {
+		return invalidJSONDocument
+	}
+	if _,ok := trailer.Get("Root").(Indirect); !ok {
+		return invalidJSONDocument
+	}
+
+	// Objects are written in order of their original numbers so
+	// that the output doesn't depend on the order of map
+	// iteration.
+	numbers := make([]ObjectNumber, 0, len(values))
+	for o := range values {
+		numbers = append(numbers, o)
+	}
+	sort.Slice(numbers, func(i, j int) bool {
+		return numbers[i].Less(numbers[j])
+	})
+	for _,o := range numbers {
+		object,err := objectFromJSON(values[o], reference)
+		if err != nil {
+			return err
+		}
+		indirect,_ := reference(o)
+		indirect.(Indirect).Write(object)
+	}
+	trailer.ForEach(func(key string, value Object) {
+		destination.trailerDictionary.Add(key, value)
+	})
+	return destination.canceled()
+}
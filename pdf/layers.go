@@ -0,0 +1,179 @@
+package pdf
+
+import "strconv"
+
+// A Layer is an optional content group: a collection of content that
+// a viewer may show or hide.  Layers are obtained from
+// Document.NewLayer() or Document.Layers().
+type Layer struct {
+	document *Document
+	reference ProtectedIndirect
+	name string
+	visible bool
+}
+
+// Name() returns the name of the layer displayed by viewers.
+func (l *Layer) Name() string {
+	return l.name
+}
+
+// Visible() returns whether the layer is shown when the document is
+// opened.
+func (l *Layer) Visible() bool {
+	return l.visible
+}
+
+// SetVisible() sets whether the layer is shown when the document is
+// opened.
+func (l *Layer) SetVisible(visible bool) {
+	if visible != l.visible {
+		l.visible = visible
+		l.document.layersChanged = true
+	}
+}
+
+// loadLayers() initializes d.layers from the catalog's
+// /OCProperties dictionary.
+func (d *Document) loadLayers() {
+	if d.layers != nil {
+		return
+	}
+	d.layers = make([]*Layer, 0, 4)
+
+	properties := d.catalog.GetDictionary("OCProperties")
+	if properties == nil {
+		return
+	}
+	groups := properties.GetArray("OCGs")
+	if groups == nil {
+		return
+	}
+
+	// Viewers start from /BaseState, then apply /ON and /OFF.
+	defaultVisible := true
+	state := make(map[ObjectNumber]bool)
+	if configuration := properties.GetDictionary("D"); configuration != nil {
+		defaultVisible = !configuration.CheckNameValue("BaseState", "OFF")
+		for _,key := range []string{"ON", "OFF"} {
+			if list := configuration.GetArray(key); list != nil {
+				for i:=0; i<list.Size(); i++ {
+					if reference,ok := list.At(i).(ProtectedIndirect); ok {
+						state[reference.ObjectNumber(d.file)] = key == "ON"
+					}
+				}
+			}
+		}
+	}
+
+	for i:=0; i<groups.Size(); i++ {
+		reference,ok := groups.At(i).(ProtectedIndirect)
+		if !ok {
+			continue
+		}
+		layer := &Layer{document: d, reference: reference, visible: defaultVisible}
+		if group,ok := reference.Dereference().(ProtectedDictionary); ok {
+			layer.name = textString(group, "Name")
+		}
+		if visible,ok := state[reference.ObjectNumber(d.file)]; ok {
+			layer.visible = visible
+		}
+		d.layers = append(d.layers, layer)
+	}
+}
+
+// Layers() returns the document's layers in the order in which they
+// appear in the /OCGs array.
+func (d *Document) Layers() []*Layer {
+	d.loadLayers()
+	result := make([]*Layer, len(d.layers))
+	copy(result, d.layers)
+	return result
+}
+
+// NewLayer() adds a layer to the document.  Content is placed in the
+// layer with Page.BeginLayer().
+func (d *Document) NewLayer(name string, visible bool) *Layer {
+	d.loadLayers()
+
+	group := NewDictionary()
+	group.Add("Type", NewName("OCG"))
+	group.Add("Name", NewTextString(name))
+
+	layer := &Layer{
+		document: d,
+		reference: d.WriteObject(group),
+		name: name,
+		visible: visible}
+	d.layers = append(d.layers, layer)
+	d.newLayers = append(d.newLayers, layer)
+	d.layersChanged = true
+	return layer
+}
+
+func (d *Document) finishLayers() {
+	if !d.layersChanged {
+		return
+	}
+	// Optional content was introduced in PDF 1.5.
+	d.requireVersion(PDF15)
+
+	groups := NewArray()
+	on := NewArray()
+	off := NewArray()
+	for _,layer := range d.layers {
+		groups.Add(layer.reference)
+		if layer.visible {
+			on.Add(layer.reference)
+		} else {
+			off.Add(layer.reference)
+		}
+	}
+
+	properties := d.catalogDictionary("OCProperties")
+	configuration := NewDictionary()
+	if existing := properties.GetDictionary("D"); existing != nil {
+		configuration = existing.Unprotect().(Dictionary)
+	}
+	configuration.Remove("BaseState")
+	configuration.Add("ON", on)
+	configuration.Add("OFF", off)
+
+	// Add new layers to the end of any existing presentation order.
+	order := NewArray()
+	if existing := configuration.GetArray("Order"); existing != nil {
+		order.Append(existing)
+		for _,layer := range d.newLayers {
+			order.Add(layer.reference)
+		}
+	} else {
+		order.Append(groups)
+	}
+	configuration.Add("Order", order)
+
+	properties.Add("OCGs", groups)
+	properties.Add("D", configuration)
+	d.newLayers = nil
+}
+
+// BeginLayer() begins a marked-content sequence on the page whose
+// content belongs to the layer.  Every call must be matched by a call
+// to EndMarkedContent().
+func (p *Page) BeginLayer(l *Layer) {
+	if p.contents == nil {
+		panic ("BeginLayer() called on closed page")
+	}
+	if p.layerNames == nil {
+		p.layerNames = make(map[*Layer]string)
+		p.properties = NewDictionary()
+		p.resources.Add("Properties", p.properties)
+	}
+
+	name,exists := p.layerNames[l]
+	if !exists {
+		name = "OC" + strconv.Itoa(len(p.layerNames) + 1)
+		p.properties.Add(name, l.reference)
+		p.layerNames[l] = name
+	}
+
+	p.Write([]byte(" /OC /" + name + " BDC "))
+}
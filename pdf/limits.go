@@ -0,0 +1,81 @@
+package pdf
+
+import "io"
+
+// Limits caps the resources spent reading a pre-existing file, as a
+// defense against malicious files, such as decompression bombs, and
+// against badly damaged ones.  Exceeding a limit produces a
+// LimitError, possibly wrapped in an XrefError or SyntaxError.  A
+// zero field selects the default given with it.
+type Limits struct {
+	// MaxObjects limits the number of entries in the xref
+	// (default 8,388,608, one more than the largest object number
+	// PDF allows).  Objects with larger numbers are ignored when
+	// a damaged xref is rebuilt.
+	MaxObjects int
+	// MaxDepth limits the nesting of arrays and dictionaries
+	// within an object (default 256).
+	MaxDepth int
+	// MaxStreamSize limits the decoded length of a stream
+	// (default 1 GiB).  Reading beyond it returns an error.
+	MaxStreamSize int64
+	// MaxXrefSections limits the length of the chain of xref
+	// sections linked by /Prev (default 4096).
+	MaxXrefSections int
+	// MaxResolutionDepth limits how many objects may be read to
+	// read one object, as when a stream's /Length is a reference
+	// to another stream whose /Length is a reference in turn
+	// (default 16).
+	MaxResolutionDepth int
+}
+
+var defaultLimits = Limits{
+	MaxObjects: maxXrefSize,
+	MaxDepth: 256,
+	MaxStreamSize: 1<<30,
+	MaxXrefSections: 4096,
+	MaxResolutionDepth: 16 }
+
+// withDefaults() returns the limits with zero fields replaced by the
+// defaults.
+func (l Limits) withDefaults() Limits {
+	if l.MaxObjects <= 0 {
+		l.MaxObjects = defaultLimits.MaxObjects
+	}
+	if l.MaxDepth <= 0 {
+		l.MaxDepth = defaultLimits.MaxDepth
+	}
+	if l.MaxStreamSize <= 0 {
+		l.MaxStreamSize = defaultLimits.MaxStreamSize
+	}
+	if l.MaxXrefSections <= 0 {
+		l.MaxXrefSections = defaultLimits.MaxXrefSections
+	}
+	if l.MaxResolutionDepth <= 0 {
+		l.MaxResolutionDepth = defaultLimits.MaxResolutionDepth
+	}
+	return l
+}
+
+// WithLimits() returns a FileOption that replaces the default Limits.
+func WithLimits(limits Limits) FileOption {
+	return func(f *file) {
+		f.limits = limits.withDefaults()
+	}
+}
+
+// sizeLimitReader reads from r until more than limit bytes have been
+// read, after which it returns a LimitError.
+type sizeLimitReader struct {
+	r io.Reader
+	n, limit int64
+}
+
+func (l *sizeLimitReader) Read(p []byte) (int, error) {
+	n,err := l.r.Read(p)
+	l.n += int64(n)
+	if l.n > l.limit {
+		return n - int(l.n-l.limit), &LimitError{"MaxStreamSize", l.limit}
+	}
+	return n, err
+}
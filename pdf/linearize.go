@@ -0,0 +1,603 @@
+package pdf
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort" )
+
+var (
+	cannotLinearizeEncrypted = errors.New(`Encrypted files cannot be linearized`)
+	cannotLinearizeWithoutPages = errors.New(`File has no catalog or no pages to linearize`)
+)
+
+// WithLinearization() returns a FileOption that rewrites a file in
+// linearized ("Fast Web View") form when it is closed after being
+// written.  Linearization is skipped, with a warning, if the file
+// cannot be linearized.  See Linearize().
+func WithLinearization() FileOption {
+	return func(f *file) {
+		f.linearize = true
+	}
+}
+
+// Linearize() reads the PDF file named input and writes it to output
+// in linearized form: the objects required to display the first page
+// come first, preceded by the linearization parameter dictionary and
+// followed by a hint stream that tells a viewer where to find each of
+// the remaining pages.  A viewer reading the file over HTTP can then
+// display the first page before the download is complete.  Objects
+// are renumbered, and objects not reachable from the trailer are
+// dropped.  If an object can't be read, the error is returned and
+// output is left untouched.  Input and output may name the same file.
+// Options are passed to OpenFile() when reading input.  The whole of
+// the output is assembled in memory before it is written.
+func Linearize(input, output string, options ...FileOption) error {
+	source,_,err := OpenFile(input, os.O_RDONLY, options...)
+	if err != nil {
+		return err
+	}
+	l := newLinearizer(source)
+	result,err := l.layout()
+	if problem := source.canceled(); problem != nil {
+		// The objects read may be incomplete.
+		err = problem
+	}
+	source.Close()
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(output, result, 0666)
+}
+
+// renumberingFile is a File used only as the argument to Serialize().
+// References to objects in source are written using the object numbers
+// in numbers.
+type renumberingFile struct {
+	File
+	source File
+	numbers map[ObjectNumber]uint32
+}
+
+func (rf *renumberingFile) ReserveObjectNumber(indirect Indirect) ObjectNumber {
+	return ObjectNumber{rf.numbers[indirect.ObjectNumber(rf.source)], 0}
+}
+
+// linearizer holds the state used to rewrite a file in linearized form.
+type linearizer struct {
+	source File
+	objects map[ObjectNumber]Object
+
+	catalog ObjectNumber
+	pages []ObjectNumber
+	// pageTree contains the nodes of the page tree, including the
+	// pages, along with the catalog.  These are not followed when
+	// collecting the objects belonging to a page.
+	pageTree map[ObjectNumber]bool
+
+	// Objects in file order.  The first page section starts with
+	// the first page; each entry of pageSections starts with the
+	// page it belongs to.
+	firstPage []ObjectNumber
+	pageSections [][]ObjectNumber
+	shared []ObjectNumber
+	other []ObjectNumber
+
+	// sharedReferences lists the shared objects used by each page
+	// as indices into firstPage followed by shared.
+	sharedReferences [][]int
+
+	bodies map[ObjectNumber][]byte
+	numbers map[ObjectNumber]uint32
+
+	// err is the first error reading an object.
+	err error
+}
+
+func newLinearizer(source File) *linearizer {
+	return &linearizer{
+		source: source,
+		objects: make(map[ObjectNumber]Object),
+		pageTree: make(map[ObjectNumber]bool),
+		bodies: make(map[ObjectNumber][]byte),
+		numbers: make(map[ObjectNumber]uint32)}
+}
+
+// object() returns the object numbered n in the source file, or a null
+// object if it cannot be read, in which case the error is recorded in
+// l.err.
+func (l *linearizer) object(n ObjectNumber) Object {
+	if o,exists := l.objects[n]; exists {
+		return o
+	}
+	o,err := l.source.Object(n)
+	if err != nil && l.err == nil {
+		l.err = err
+	}
+	if err != nil || o == nil {
+		o = NewNull()
+	}
+	l.objects[n] = o
+	return o
+}
+
+// reach() returns the object numbers of the objects reachable from
+// the objects in start, in depth-first order.  Objects in visited or
+// in exclude are skipped.  When skipParent is true, /Parent entries
+// are not followed.
+func (l *linearizer) reach(start []Object, visited map[ObjectNumber]bool, exclude map[ObjectNumber]bool, skipParent bool) (result []ObjectNumber) {
+	var visit func(o Object)
+	visit = func(o Object) {
+		switch t := o.Protect().(type) {
+		case ProtectedIndirect:
+			n := t.ObjectNumber(l.source)
+			if visited[n] || exclude[n] {
+				return
+			}
+			visited[n] = true
+			result = append(result, n)
+			visit(l.object(n))
+		case ProtectedStream:
+			visit(t.Dictionary())
+		case ProtectedDictionary:
+			// Keys are sorted so that the order is repeatable.
+			keys := t.Keys()
+			sort.Strings(keys)
+			for _,key := range keys {
+				if !(skipParent && key == "Parent") {
+					visit(t.Get(key))
+				}
+			}
+		case ProtectedArray:
+			for i:=0; i<t.Size(); i++ {
+				visit(t.At(i))
+			}
+		}
+	}
+	for _,o := range start {
+		visit(o)
+	}
+	return result
+}
+
+// partition() assigns each object reachable from the trailer to a
+// section of the linearized file.
+func (l *linearizer) partition() error {
+	trailer := l.source.Trailer()
+	if trailer.Get("Encrypt") != nil {
+		return cannotLinearizeEncrypted
+	}
+	root,ok := trailer.Get("Root").(ProtectedIndirect)
+	if !ok {
+		return cannotLinearizeWithoutPages
+	}
+	l.catalog = root.ObjectNumber(l.source)
+	catalog,ok := l.object(l.catalog).Protect().(ProtectedDictionary)
+	if !ok {
+		return cannotLinearizeWithoutPages
+	}
+	pagesRoot,ok := catalog.Get("Pages").(ProtectedIndirect)
+	if !ok {
+		return cannotLinearizeWithoutPages
+	}
+	l.pageTree[l.catalog] = true
+	l.pageTree[pagesRoot.ObjectNumber(l.source)] = true
+	for _,reference := range pageReferences(l.source, catalog.GetDictionary("Pages"), l.pageTree) {
+		l.pages = append(l.pages, reference.ObjectNumber(l.source))
+	}
+	if len(l.pages) == 0 {
+		return cannotLinearizeWithoutPages
+	}
+
+	// Find the objects used by each page and count the pages
+	// that use each object.
+	reached := make([][]ObjectNumber, len(l.pages))
+	users := make(map[ObjectNumber]int)
+	progress := progressOf(l.source)
+	for i,page := range l.pages {
+		visited := map[ObjectNumber]bool{page: true}
+		reached[i] = append([]ObjectNumber{page}, l.reach([]Object{l.object(page)}, visited, l.pageTree, true)...)
+		for _,n := range reached[i] {
+			users[n] += 1
+		}
+		progress.OnPageProcessed(uint(i+1), uint(len(l.pages)))
+	}
+
+	// All objects used by the first page are in the first page
+	// section.  Objects used by only one of the other pages are
+	// in the section for that page, and the rest are shared.
+	assigned := make(map[ObjectNumber]bool)
+	sharedIndex := make(map[ObjectNumber]int)
+	l.firstPage = reached[0]
+	for i,n := range l.firstPage {
+		assigned[n] = true
+		sharedIndex[n] = i
+	}
+	l.sharedReferences = make([][]int, len(l.pages))
+	for i:=1; i<len(l.pages); i++ {
+		var section []ObjectNumber
+		for _,n := range reached[i] {
+			if users[n] == 1 {
+				section = append(section, n)
+				assigned[n] = true
+			}
+		}
+		l.pageSections = append(l.pageSections, section)
+	}
+	for i:=1; i<len(l.pages); i++ {
+		for _,n := range reached[i] {
+			if users[n] == 1 {
+				continue
+			}
+			if !assigned[n] {
+				sharedIndex[n] = len(l.firstPage) + len(l.shared)
+				l.shared = append(l.shared, n)
+				assigned[n] = true
+			}
+			l.sharedReferences[i] = append(l.sharedReferences[i], sharedIndex[n])
+		}
+	}
+
+	// Everything else follows in the order it is reached from
+	// the catalog and the Info dictionary.  Objects that have
+	// been assigned are traversed again because /Parent entries
+	// were not followed earlier.
+	assigned[l.catalog] = true
+	visited := map[ObjectNumber]bool{l.catalog: true}
+	start := []Object{catalog}
+	if info := trailer.Get("Info"); info != nil {
+		start = append(start, info)
+	}
+	for _,n := range l.reach(start, visited, nil, false) {
+		if !assigned[n] {
+			l.other = append(l.other, n)
+			assigned[n] = true
+		}
+	}
+	return nil
+}
+
+// number() assigns object numbers in file order, except that the
+// objects in the first page section are numbered after all the others
+// as the PDF specification requires.  It returns the numbers of the
+// linearization parameter dictionary and of the hint stream.
+func (l *linearizer) number() (parameters, hints uint32) {
+	next := uint32(1)
+	assign := func(objects []ObjectNumber) {
+		for _,n := range objects {
+			l.numbers[n] = next
+			next += 1
+		}
+	}
+	for _,section := range l.pageSections {
+		assign(section)
+	}
+	assign(l.shared)
+	assign(l.other)
+
+	parameters = next
+	l.numbers[l.catalog] = next + 1
+	next += 2
+	assign(l.firstPage)
+	return parameters, next
+}
+
+// serialize() writes each object with its new number.
+func (l *linearizer) serialize() {
+	rf := &renumberingFile{NewMockFile(0, 0), l.source, l.numbers}
+	for n,number := range l.numbers {
+		var buffer bytes.Buffer
+		fmt.Fprintf(&buffer, "%d 0 obj\n", number)
+		l.object(n).Serialize(&buffer, rf)
+		buffer.WriteString("\nendobj\n")
+		l.bodies[n] = buffer.Bytes()
+	}
+}
+
+// bitWriter packs the unsigned integers of a hint table, most
+// significant bit first.
+type bitWriter struct {
+	buffer bytes.Buffer
+	current byte
+	count uint
+}
+
+func (bw *bitWriter) write(value uint64, bits int) {
+	for i:=bits-1; i>=0; i-- {
+		bw.current = bw.current<<1 | byte(value>>uint(i)&1)
+		bw.count += 1
+		if bw.count == 8 {
+			bw.buffer.WriteByte(bw.current)
+			bw.current, bw.count = 0, 0
+		}
+	}
+}
+
+// flush() pads the last byte with zeros.  Each item of a hint table
+// starts on a byte boundary.
+func (bw *bitWriter) flush() {
+	if bw.count > 0 {
+		bw.buffer.WriteByte(bw.current << (8-bw.count))
+		bw.current, bw.count = 0, 0
+	}
+}
+
+// bitsFor() returns the number of bits required to represent n.
+func bitsFor(n uint64) int {
+	bits := 0
+	for ; n > 0; n >>= 1 {
+		bits += 1
+	}
+	return bits
+}
+
+// minMax() returns the least and greatest of values.
+func minMax(values []uint64) (least, greatest uint64) {
+	least = values[0]
+	for _,v := range values {
+		if v < least {
+			least = v
+		}
+		if v > greatest {
+			greatest = v
+		}
+	}
+	return least, greatest
+}
+
+// hintStream() returns the contents of the primary hint stream and
+// the offset of its shared object hint table.  position contains the
+// offsets of the objects as if the hint stream were not present,
+// which is how the PDF specification defines the offsets in hint
+// tables.
+func (l *linearizer) hintStream(position map[ObjectNumber]uint64) (data []byte, sharedTable int) {
+	sectionLength := func(section []ObjectNumber) (length uint64) {
+		for _,n := range section {
+			length += uint64(len(l.bodies[n]))
+		}
+		return length
+	}
+
+	sections := append([][]ObjectNumber{l.firstPage}, l.pageSections...)
+	objectCounts := make([]uint64, len(sections))
+	lengths := make([]uint64, len(sections))
+	contentOffsets := make([]uint64, len(sections))
+	contentLengths := make([]uint64, len(sections))
+	greatestReferences := uint64(0)
+	for i,section := range sections {
+		objectCounts[i] = uint64(len(section))
+		lengths[i] = sectionLength(section)
+		if page,ok := l.object(section[0]).Protect().(ProtectedDictionary); ok {
+			if contents,ok := page.Get("Contents").(ProtectedIndirect); ok {
+				n := contents.ObjectNumber(l.source)
+				if p,exists := position[n]; exists && l.sectionOf(n) == i {
+					contentOffsets[i] = p - position[section[0]]
+					contentLengths[i] = uint64(len(l.bodies[n]))
+				}
+			}
+		}
+		if references := uint64(len(l.sharedReferences[i])); references > greatestReferences {
+			greatestReferences = references
+		}
+	}
+
+	var bw bitWriter
+	leastObjects,greatestObjects := minMax(objectCounts)
+	leastLength,greatestLength := minMax(lengths)
+	leastOffset,greatestOffset := minMax(contentOffsets)
+	leastContent,greatestContent := minMax(contentLengths)
+	objectBits := bitsFor(greatestObjects-leastObjects)
+	lengthBits := bitsFor(greatestLength-leastLength)
+	offsetBits := bitsFor(greatestOffset-leastOffset)
+	contentBits := bitsFor(greatestContent-leastContent)
+	referenceBits := bitsFor(greatestReferences)
+	identifierBits := bitsFor(uint64(len(l.firstPage)+len(l.shared)-1))
+
+	// Page offset hint table header
+	bw.write(leastObjects, 32)
+	bw.write(position[l.firstPage[0]], 32)
+	bw.write(uint64(objectBits), 16)
+	bw.write(leastLength, 32)
+	bw.write(uint64(lengthBits), 16)
+	bw.write(leastOffset, 32)
+	bw.write(uint64(offsetBits), 16)
+	bw.write(leastContent, 32)
+	bw.write(uint64(contentBits), 16)
+	bw.write(uint64(referenceBits), 16)
+	bw.write(uint64(identifierBits), 16)
+	// Shared objects always start at the beginning of the
+	// page, so the numerator has no bits and the denominator is 1.
+	bw.write(0, 16)
+	bw.write(1, 16)
+
+	// Page offset hint table entries, item by item
+	writeItem := func(values []uint64, least uint64, bits int) {
+		for _,v := range values {
+			bw.write(v-least, bits)
+		}
+		bw.flush()
+	}
+	writeItem(objectCounts, leastObjects, objectBits)
+	writeItem(lengths, leastLength, lengthBits)
+	for _,references := range l.sharedReferences {
+		bw.write(uint64(len(references)), referenceBits)
+	}
+	bw.flush()
+	for _,references := range l.sharedReferences {
+		for _,identifier := range references {
+			bw.write(uint64(identifier), identifierBits)
+		}
+	}
+	bw.flush()
+	writeItem(contentOffsets, leastOffset, offsetBits)
+	writeItem(contentLengths, leastContent, contentBits)
+
+	// Shared object hint table, in which each object is a group
+	sharedTable = bw.buffer.Len()
+	groups := append(append([]ObjectNumber{}, l.firstPage...), l.shared...)
+	groupLengths := make([]uint64, len(groups))
+	for i,n := range groups {
+		groupLengths[i] = uint64(len(l.bodies[n]))
+	}
+	leastGroup,greatestGroup := minMax(groupLengths)
+	groupBits := bitsFor(greatestGroup-leastGroup)
+	if len(l.shared) > 0 {
+		bw.write(uint64(l.numbers[l.shared[0]]), 32)
+		bw.write(position[l.shared[0]], 32)
+	} else {
+		bw.write(0, 32)
+		bw.write(0, 32)
+	}
+	bw.write(uint64(len(l.firstPage)), 32)
+	bw.write(uint64(len(groups)), 32)
+	bw.write(0, 16)
+	bw.write(leastGroup, 32)
+	bw.write(uint64(groupBits), 16)
+	writeItem(groupLengths, leastGroup, groupBits)
+	// No group has an MD5 signature, and each group has one
+	// object, so the object count item has no bits.
+	writeItem(make([]uint64, len(groups)), 0, 1)
+
+	return bw.buffer.Bytes(), sharedTable
+}
+
+// sectionOf() returns the index of the page section (with the first
+// page section at 0) containing n, or -1.
+func (l *linearizer) sectionOf(n ObjectNumber) int {
+	for _,m := range l.firstPage {
+		if m == n {
+			return 0
+		}
+	}
+	for i,section := range l.pageSections {
+		for _,m := range section {
+			if m == n {
+				return i+1
+			}
+		}
+	}
+	return -1
+}
+
+// linearizationPrefix holds the values written at the start of a
+// linearized file, ahead of the objects.
+type linearizationPrefix struct {
+	fileLength, hintPosition, hintLength, endOfFirstPage, mainXref, firstEntry uint64
+	// offsets of the objects in the first page xref: the
+	// catalog, the first page section, and the hint stream
+	offsets []uint64
+}
+
+// writePrefix() writes the header, the linearization parameter
+// dictionary, and the first page xref and trailer.  Values are padded
+// so the length of the prefix doesn't depend on them.  It returns the
+// position of the first page xref.
+func (l *linearizer) writePrefix(buffer *bytes.Buffer, parameters uint32, trailerEntries string, prefix linearizationPrefix) (xrefPosition int) {
+	buffer.WriteString(header(versionOf(l.source)))
+	parametersPosition := buffer.Len()
+	fmt.Fprintf(buffer, "%d 0 obj\n<< /Linearized 1 /L %10d /H [ %10d %10d ] /O %10d /E %10d /N %10d /T %10d >>\nendobj\n",
+		parameters, prefix.fileLength, prefix.hintPosition, prefix.hintLength, l.numbers[l.pages[0]],
+		prefix.endOfFirstPage, len(l.pages), prefix.firstEntry)
+
+	xrefPosition = buffer.Len()
+	fmt.Fprintf(buffer, "xref\n%d %d\n", parameters, len(prefix.offsets)+1)
+	fmt.Fprintf(buffer, "%010d 00000 n\r\n", parametersPosition)
+	for _,offset := range prefix.offsets {
+		fmt.Fprintf(buffer, "%010d 00000 n\r\n", offset)
+	}
+	fmt.Fprintf(buffer, "trailer\n<< %s /Prev %10d >>\nstartxref\n0\n%%%%EOF\n", trailerEntries, prefix.mainXref)
+	return xrefPosition
+}
+
+// layout() returns the linearized file.
+func (l *linearizer) layout() ([]byte, error) {
+	if err := l.partition(); err != nil {
+		return nil, err
+	}
+	parameters,hints := l.number()
+	l.serialize()
+	// Nothing is written if an object would be lost, since the
+	// output may replace the input.
+	if l.err != nil {
+		return nil, l.err
+	}
+
+	trailer := l.source.Trailer()
+	rf := &renumberingFile{NewMockFile(0, 0), l.source, l.numbers}
+	var trailerEntries bytes.Buffer
+	fmt.Fprintf(&trailerEntries, "/Size %d /Root %d 0 R", hints+1, l.numbers[l.catalog])
+	for _,key := range []string{"Info", "ID"} {
+		if value := trailer.Get(key); value != nil {
+			fmt.Fprintf(&trailerEntries, " /%s ", key)
+			value.Serialize(&trailerEntries, rf)
+		}
+	}
+
+	// The prefix is written once to find its length and again
+	// with the final values.
+	prefix := linearizationPrefix{offsets: make([]uint64, len(l.firstPage)+2)}
+	var buffer bytes.Buffer
+	firstXref := l.writePrefix(&buffer, parameters, trailerEntries.String(), prefix)
+	catalogPosition := uint64(buffer.Len())
+
+	var rest []ObjectNumber
+	for _,section := range l.pageSections {
+		rest = append(rest, section...)
+	}
+	rest = append(rest, l.shared...)
+	rest = append(rest, l.other...)
+	ordered := append(append([]ObjectNumber{l.catalog}, l.firstPage...), rest...)
+
+	// Offsets without the hint stream, for the hint tables
+	position := make(map[ObjectNumber]uint64, len(ordered))
+	end := catalogPosition
+	for _,n := range ordered {
+		position[n] = end
+		end += uint64(len(l.bodies[n]))
+	}
+	data,sharedTable := l.hintStream(position)
+	var hintObject bytes.Buffer
+	fmt.Fprintf(&hintObject, "%d 0 obj\n<< /Length %d /S %d >>\nstream\n", hints, len(data), sharedTable)
+	hintObject.Write(data)
+	hintObject.WriteString("\nendstream\nendobj\n")
+
+	prefix.hintPosition = catalogPosition + uint64(len(l.bodies[l.catalog]))
+	prefix.hintLength = uint64(hintObject.Len())
+	for _,n := range ordered[1:] {
+		position[n] += prefix.hintLength
+	}
+	prefix.offsets[0] = catalogPosition
+	for i,n := range l.firstPage {
+		prefix.offsets[i+1] = position[n]
+	}
+	prefix.offsets[len(prefix.offsets)-1] = prefix.hintPosition
+	last := l.firstPage[len(l.firstPage)-1]
+	prefix.endOfFirstPage = position[last] + uint64(len(l.bodies[last]))
+	prefix.mainXref = end + prefix.hintLength
+
+	var mainTable bytes.Buffer
+	fmt.Fprintf(&mainTable, "xref\n0 %d\n", parameters)
+	prefix.firstEntry = prefix.mainXref + uint64(mainTable.Len())
+	mainTable.WriteString("0000000000 65535 f\r\n")
+	for _,n := range rest {
+		fmt.Fprintf(&mainTable, "%010d 00000 n\r\n", position[n])
+	}
+	fmt.Fprintf(&mainTable, "trailer\n<< /Size %d >>\nstartxref\n%d\n%%%%EOF\n", parameters, firstXref)
+	prefix.fileLength = prefix.mainXref + uint64(mainTable.Len())
+
+	buffer.Reset()
+	buffer.Grow(int(prefix.fileLength))
+	l.writePrefix(&buffer, parameters, trailerEntries.String(), prefix)
+	buffer.Write(l.bodies[l.catalog])
+	buffer.Write(hintObject.Bytes())
+	for _,n := range ordered[1:] {
+		buffer.Write(l.bodies[n])
+	}
+	buffer.Write(mainTable.Bytes())
+	if uint64(buffer.Len()) != prefix.fileLength {
+		panic(fmt.Sprintf("Linearized file length %d differs from expected length %d", buffer.Len(), prefix.fileLength))
+	}
+	return buffer.Bytes(), nil
+}
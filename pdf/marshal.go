@@ -0,0 +1,357 @@
+package pdf
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings")
+
+var (
+	unsupportedType = errors.New(`Type can't be represented as a PDF object`)
+	typeMismatch = errors.New(`Object has the wrong type for the field`)
+	notStruct = errors.New(`Marshal() requires a struct or a map with string keys`)
+	notStructPointer = errors.New(`Unmarshal() requires a pointer to a struct`))
+
+// MarshalError reports a field that Marshal() or Unmarshal() could
+// not convert.
+type MarshalError struct {
+	// Key is the dictionary key of the field, with the keys of
+	// the enclosing fields, if any, separated by "/".
+	Key string
+	Err error
+}
+
+func (e *MarshalError) Error() string {
+	return fmt.Sprintf("Unable to convert /%s: %v", e.Key, e.Err)
+}
+
+func (e *MarshalError) Unwrap() error {
+	return e.Err
+}
+
+func (e *MarshalError) Is(target error) bool {
+	t,ok := target.(*MarshalError)
+	return ok && t.Err == nil
+}
+
+var objectType = reflect.TypeOf((*Object)(nil)).Elem()
+
+// fieldTag is the parsed `pdf:"..."` tag of a struct field.
+type fieldTag struct {
+	key string
+	// name is set by the "name" option, under which strings are
+	// names rather than text strings.
+	name bool
+	// omitEmpty is set by the "omitempty" option, under which
+	// zero values are omitted.
+	omitEmpty bool
+}
+
+// parseTag() returns the tag of field and false if the field is
+// unexported or tagged "-".
+func parseTag(field reflect.StructField) (fieldTag, bool) {
+	tag,tagged := field.Tag.Lookup("pdf")
+	if field.PkgPath != "" || tag == "-" {
+		return fieldTag{}, false
+	}
+	parts := strings.Split(tag, ",")
+	result := fieldTag{key: parts[0]}
+	if !tagged || result.key == "" {
+		result.key = field.Name
+	}
+	for _,option := range parts[1:] {
+		switch option {
+		case "name":
+			result.name = true
+		case "omitempty":
+			result.omitEmpty = true
+		}
+	}
+	return result, true
+}
+
+// Marshal() returns a dictionary containing the fields of v, which
+// must be a struct, a pointer to one, or a map with string keys.  Each
+// exported field is stored under the key given by its `pdf:"Key"`
+// tag, or under its name if it has no tag.  A tag of "-" omits the
+// field, and the options "name" and "omitempty", following the key
+// and a comma, store strings as names rather than text strings and
+// omit zero values.  Nil pointers, slices, maps, and interfaces are
+// always omitted.
+//
+// Booleans, numbers, and strings become the corresponding objects;
+// []byte becomes a binary string; slices and arrays become arrays;
+// structs and maps become dictionaries; and fields whose values are
+// Objects, such as Indirect references to fonts or pages, are stored
+// as they are.
+func Marshal(v interface{}) (Dictionary, error) {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct && (rv.Kind() != reflect.Map || rv.Type().Key().Kind() != reflect.String) {
+		return nil, notStruct
+	}
+	object,ok,err := marshalValue(rv, fieldTag{})
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return NewDictionary(), nil
+	}
+	return object.(Dictionary), nil
+}
+
+// marshalValue() returns the object representing rv.  The boolean
+// return value is false if rv should be omitted.
+func marshalValue(rv reflect.Value, tag fieldTag) (Object, bool, error) {
+	if tag.omitEmpty && rv.IsZero() {
+		return nil, false, nil
+	}
+	switch rv.Kind() {
+	case reflect.Ptr, reflect.Interface, reflect.Slice, reflect.Map:
+		if rv.IsNil() {
+			return nil, false, nil
+		}
+	}
+	if rv.Type().Implements(objectType) {
+		return rv.Interface().(Object), true, nil
+	}
+
+	switch rv.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		return marshalValue(rv.Elem(), tag)
+	case reflect.Bool:
+		return NewBoolean(rv.Bool()), true, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return NewIntNumeric(int(rv.Int())), true, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return NewIntNumeric(int(rv.Uint())), true, nil
+	case reflect.Float32, reflect.Float64:
+		return NewNumeric(rv.Float()), true, nil
+	case reflect.String:
+		if tag.name {
+			return NewName(rv.String()), true, nil
+		}
+		return NewTextString(rv.String()), true, nil
+	case reflect.Slice, reflect.Array:
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			b := make([]byte, rv.Len())
+			reflect.Copy(reflect.ValueOf(b), rv)
+			return NewBinaryString(b), true, nil
+		}
+		result := NewArray()
+		for i:=0; i<rv.Len(); i++ {
+			element,ok,err := marshalValue(rv.Index(i), fieldTag{name: tag.name})
+			if err != nil {
+				return nil, false, err
+			}
+			if !ok {
+				element = NewNull()
+			}
+			result.Add(element)
+		}
+		return result, true, nil
+	case reflect.Struct:
+		result := NewDictionary()
+		for i:=0; i<rv.NumField(); i++ {
+			fieldTag,ok := parseTag(rv.Type().Field(i))
+			if !ok {
+				continue
+			}
+			value,ok,err := marshalValue(rv.Field(i), fieldTag)
+			if err != nil {
+				return nil, false, nestedMarshalError(fieldTag.key, err)
+			}
+			if ok {
+				result.Add(fieldTag.key, value)
+			}
+		}
+		return result, true, nil
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String {
+			break
+		}
+		keys := make([]string, 0, rv.Len())
+		for _,key := range rv.MapKeys() {
+			keys = append(keys, key.String())
+		}
+		sort.Strings(keys)
+		result := NewDictionary()
+		for _,key := range keys {
+			value,ok,err := marshalValue(rv.MapIndex(reflect.ValueOf(key).Convert(rv.Type().Key())), fieldTag{name: tag.name})
+			if err != nil {
+				return nil, false, nestedMarshalError(key, err)
+			}
+			if ok {
+				result.Add(key, value)
+			}
+		}
+		return result, true, nil
+	}
+	return nil, false, unsupportedType
+}
+
+// nestedMarshalError() returns err, which occurred converting the
+// value under key, as a MarshalError whose Key includes key.
+func nestedMarshalError(key string, err error) error {
+	if e,ok := err.(*MarshalError); ok {
+		return &MarshalError{key + "/" + e.Key, e.Err}
+	}
+	return &MarshalError{key, err}
+}
+
+// Unmarshal() stores the entries of d in the fields of the struct to
+// which v points, as the inverse of Marshal().  Fields whose keys
+// aren't in d are left alone.  References are resolved, except for
+// fields whose types are Objects that the reference itself satisfies,
+// such as Indirect or Object, which receive the reference.  A field
+// that is a slice receives a single element if the entry isn't an
+// array, as PDF allows for entries such as /Filter, and a string
+// field receives either a name or a text string.
+func Unmarshal(d ProtectedDictionary, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return notStructPointer
+	}
+	return unmarshalStruct(d, rv.Elem())
+}
+
+func unmarshalStruct(d ProtectedDictionary, rv reflect.Value) error {
+	for i:=0; i<rv.NumField(); i++ {
+		tag,ok := parseTag(rv.Type().Field(i))
+		if !ok {
+			continue
+		}
+		if object := d.Get(tag.key); object != nil {
+			if err := unmarshalValue(object, rv.Field(i)); err != nil {
+				return nestedMarshalError(tag.key, err)
+			}
+		}
+	}
+	return nil
+}
+
+// unmarshalValue() stores the value of object in rv.
+func unmarshalValue(object Object, rv reflect.Value) error {
+	if value := reflect.ValueOf(object); value.Type().AssignableTo(rv.Type()) {
+		rv.Set(value)
+		return nil
+	}
+	object = object.Dereference()
+	if value := reflect.ValueOf(object); value.Type().AssignableTo(rv.Type()) {
+		rv.Set(value)
+		return nil
+	}
+
+	switch rv.Kind() {
+	case reflect.Interface:
+		return typeMismatch
+	case reflect.Ptr:
+		element := reflect.New(rv.Type().Elem())
+		if err := unmarshalValue(object, element.Elem()); err != nil {
+			return err
+		}
+		rv.Set(element)
+		return nil
+	case reflect.Bool:
+		if b,ok := object.(Boolean); ok {
+			rv.SetBool(b.Value())
+			return nil
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if f,ok := numericValue(object); ok && f == math.Trunc(f) && !rv.OverflowInt(int64(f)) {
+			rv.SetInt(int64(f))
+			return nil
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if f,ok := numericValue(object); ok && f == math.Trunc(f) && f >= 0 && !rv.OverflowUint(uint64(f)) {
+			rv.SetUint(uint64(f))
+			return nil
+		}
+	case reflect.Float32, reflect.Float64:
+		if f,ok := numericValue(object); ok {
+			rv.SetFloat(f)
+			return nil
+		}
+	case reflect.String:
+		switch t := object.(type) {
+		case Name:
+			rv.SetString(t.String())
+			return nil
+		case ProtectString:
+			rv.SetString(DecodeTextString(t.Bytes()))
+			return nil
+		}
+	case reflect.Slice:
+		if s,ok := object.(ProtectString); ok && rv.Type().Elem().Kind() == reflect.Uint8 {
+			rv.SetBytes(append([]byte(nil), s.Bytes()...))
+			return nil
+		}
+		a,ok := object.(ProtectedArray)
+		if !ok {
+			result := reflect.MakeSlice(rv.Type(), 1, 1)
+			if err := unmarshalValue(object, result.Index(0)); err != nil {
+				return err
+			}
+			rv.Set(result)
+			return nil
+		}
+		result := reflect.MakeSlice(rv.Type(), a.Size(), a.Size())
+		for i:=0; i<a.Size(); i++ {
+			if err := unmarshalValue(a.At(i), result.Index(i)); err != nil {
+				return err
+			}
+		}
+		rv.Set(result)
+		return nil
+	case reflect.Array:
+		if a,ok := object.(ProtectedArray); ok && a.Size() == rv.Len() {
+			for i:=0; i<a.Size(); i++ {
+				if err := unmarshalValue(a.At(i), rv.Index(i)); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+	case reflect.Struct:
+		if d,ok := dictionaryOf(object); ok {
+			return unmarshalStruct(d, rv)
+		}
+	case reflect.Map:
+		d,ok := dictionaryOf(object)
+		if !ok || rv.Type().Key().Kind() != reflect.String {
+			break
+		}
+		result := reflect.MakeMapWithSize(rv.Type(), d.Size())
+		var err error
+		d.ForEach(func(key string, value Object) {
+			element := reflect.New(rv.Type().Elem()).Elem()
+			if e := unmarshalValue(value, element); e != nil {
+				if err == nil {
+					err = nestedMarshalError(key, e)
+				}
+				return
+			}
+			result.SetMapIndex(reflect.ValueOf(key).Convert(rv.Type().Key()), element)
+		})
+		if err != nil {
+			return err
+		}
+		rv.Set(result)
+		return nil
+	}
+	return typeMismatch
+}
+
+// dictionaryOf() returns object if it is a dictionary or the
+// dictionary of object if it is a stream.
+func dictionaryOf(object Object) (ProtectedDictionary, bool) {
+	if s,ok := object.(ProtectedStream); ok {
+		return s.Dictionary(), true
+	}
+	d,ok := object.(ProtectedDictionary)
+	return d, ok
+}
@@ -0,0 +1,120 @@
+package pdf
+
+import (
+	"errors"
+	"io"
+	"os"
+	"sync" )
+
+// MemoryFile is a File that is written to a buffer in memory rather
+// than to disk, for example by a server generating a PDF for each
+// request.  Objects written to it can be read back with Object().
+// After Close(), Bytes() returns the complete PDF.
+type MemoryFile struct {
+	*file
+	storage *memoryStorage
+}
+
+// NewMemoryFile() constructs an empty MemoryFile.
+func NewMemoryFile(options ...FileOption) *MemoryFile {
+	storage := new(memoryStorage)
+	f,_,_ := openStorage(storage, options...)
+	return &MemoryFile{f, storage}
+}
+
+// NewMemoryFileFromBytes() constructs a MemoryFile from the
+// pre-existing PDF in contents, to which any changes are appended as
+// an incremental update.  contents is copied, so the caller may reuse
+// it.
+func NewMemoryFileFromBytes(contents []byte, options ...FileOption) (*MemoryFile, error) {
+	if len(contents) == 0 {
+		return nil, errors.New(`No PDF found in contents`)
+	}
+	storage := &memoryStorage{data: append([]byte(nil), contents...)}
+	f,_,err := openStorage(storage, options...)
+	if err != nil {
+		return nil, err
+	}
+	return &MemoryFile{f, storage}, nil
+}
+
+// Bytes() returns the contents of the file.  Until Close() is called,
+// they lack the xref and trailer and may lack recently written
+// objects.  The caller must not modify the returned slice.
+func (mf *MemoryFile) Bytes() []byte {
+	mf.storage.mutex.Lock()
+	defer mf.storage.mutex.Unlock()
+	return mf.storage.data
+}
+
+func (mf *MemoryFile) bindingFile() File {
+	return mf.file
+}
+
+// memoryStorage is a storage held in a byte slice.
+type memoryStorage struct {
+	mutex sync.Mutex
+	data []byte
+	position int64
+}
+
+func (s *memoryStorage) Read(p []byte) (int, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	n,err := s.readAt(p, s.position)
+	s.position += int64(n)
+	return n, err
+}
+
+func (s *memoryStorage) ReadAt(p []byte, offset int64) (int, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	n,err := s.readAt(p, offset)
+	if err == nil && n < len(p) {
+		err = io.EOF
+	}
+	return n, err
+}
+
+func (s *memoryStorage) readAt(p []byte, offset int64) (int, error) {
+	if offset >= int64(len(s.data)) {
+		return 0, io.EOF
+	}
+	return copy(p, s.data[offset:]), nil
+}
+
+func (s *memoryStorage) Write(p []byte) (int, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if end := s.position + int64(len(p)); end > int64(len(s.data)) {
+		if end > int64(cap(s.data)) {
+			data := make([]byte, len(s.data), 2*end)
+			copy(data, s.data)
+			s.data = data
+		}
+		s.data = s.data[:end]
+	}
+	n := copy(s.data[s.position:], p)
+	s.position += int64(n)
+	return n, nil
+}
+
+func (s *memoryStorage) Seek(offset int64, whence int) (int64, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	switch whence {
+	case os.SEEK_CUR:
+		offset += s.position
+	case os.SEEK_END:
+		offset += int64(len(s.data))
+	}
+	if offset < 0 {
+		return s.position, errors.New(`Seek to negative position`)
+	}
+	s.position = offset
+	return offset, nil
+}
+
+func (s *memoryStorage) Close() error {
+	return nil
+}
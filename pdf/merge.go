@@ -0,0 +1,337 @@
+package pdf
+
+import (
+	"os"
+	"strconv" )
+
+// outlineSection is a top-level outline item created by Append() for
+// one of the merged documents.  The outline of the document, if any,
+// is nested beneath it.
+type outlineSection struct {
+	reference Indirect
+	title string
+	page Indirect
+	first, last Object
+	count int
+}
+
+// Merge() creates a new document named filename containing the
+// pages of each of the sources in turn.  See Append().  The new
+// document is returned open so that the client may modify it before
+// calling Close().
+func Merge(filename string, sources ...*Document) *Document {
+	out := OpenDocument(filename, os.O_RDWR|os.O_CREATE|os.O_TRUNC)
+	for _,source := range sources {
+		out.Append(source)
+	}
+	return out
+}
+
+// Append() copies every page of other to the end of d, along with
+// the objects the pages refer to.  The name trees of other (named
+// destinations, attachments, etc.) are merged into those of d; when
+// both define the same name, the definition in d is kept.  Form
+// fields of other are added to the interactive form of d, and
+// top-level fields are renamed if their names collide with existing
+// ones.  The outline is rebuilt with one top-level item for each
+// merged document, beneath which its original outline appears.
+// Objects identical to ones already copied by Append(), such as
+// fonts used by several of the documents, are written only once.  An
+// error reading an object of other is returned by d.Close().
+func (d *Document) Append(other *Document) {
+	defer recoverCanceled(d, other)
+	if d.canceled() != nil || other.canceled() != nil {
+		return
+	}
+	d.finishCurrentPage()
+	other.finishCurrentPage()
+	if !d.readyForNewPages {
+		d.makeNewPageTree()
+	}
+
+	// The first merge places the existing contents of d in a
+	// section of its own.
+	if d.outlineSections == nil {
+		d.outlineSections = make([]outlineSection, 0, 2)
+		if d.pageCount > 0 {
+			d.addOwnOutlineSection()
+		}
+	}
+
+	copier := NewObjectCopier(d.file, other.file)
+	// Fonts and images shared by the merged documents are written
+	// once.
+	if d.digests == nil {
+		d.digests = make(map[objectDigest]Indirect)
+	}
+	copier.deduplicate(d.digests)
+	var otherCatalog ProtectedDictionary = NewDictionary()
+	if catalog := other.file.Catalog(); catalog != nil {
+		otherCatalog = catalog
+	}
+
+	section := outlineSection{
+		reference: NewIndirect(d.file),
+		title: other.Title}
+	if section.title == "" {
+		section.title = "Document " + strconv.Itoa(len(d.outlineSections) + 1)
+	}
+	outlines := otherCatalog.GetDictionary("Outlines")
+	if outlines != nil {
+		// Top-level items of the other outline become children
+		// of the new section.
+		if root := otherCatalog.GetIndirect("Outlines"); root != nil {
+			copier.Translate(root.ObjectNumber(other.file), section.reference)
+		}
+	}
+
+	renamed := d.renameFields(otherCatalog, copier)
+
+	firstPage := d.pages.Size()
+	pages := make([]uint, other.pageCount)
+	for i := range pages {
+		pages[i] = uint(i)
+	}
+	d.importPages(other, pages, copier)
+
+	if firstPage < d.pages.Size() {
+		section.page = d.pages.At(firstPage).(Indirect)
+		if outlines != nil {
+			section.first = copier.Copy(outlines.Get("First"))
+			section.last = copier.Copy(outlines.Get("Last"))
+			section.count,_ = outlines.GetInt("Count")
+		}
+		d.outlineSections = append(d.outlineSections, section)
+	}
+
+	d.mergeNameTrees(otherCatalog, copier)
+	d.mergeAcroForm(otherCatalog, copier, renamed)
+	d.noteCopyError(copier)
+}
+
+// addOwnOutlineSection() adds a section for the pages and outline
+// already present in d.  An existing outline root is rewritten as
+// the section item so that the /Parent entries of its children
+// remain valid.
+func (d *Document) addOwnOutlineSection() {
+	section := outlineSection{title: d.Title}
+	if section.title == "" {
+		section.title = "Document 1"
+	}
+	if page := d.Page(0); page != nil {
+		section.page = page.reference
+	}
+	if root := d.catalog.GetIndirect("Outlines"); root != nil {
+		section.reference = root.Unprotect().(Indirect)
+		if outlines := d.catalog.GetDictionary("Outlines"); outlines != nil {
+			section.first = outlines.Get("First")
+			section.last = outlines.Get("Last")
+			section.count,_ = outlines.GetInt("Count")
+		}
+	} else {
+		section.reference = NewIndirect(d.file)
+	}
+	d.outlineSections = append(d.outlineSections, section)
+}
+
+// mergeNameTrees() adds the entries of the name trees in the /Names
+// dictionary of catalog to those of d.
+func (d *Document) mergeNameTrees(catalog ProtectedDictionary, copier *ObjectCopier) {
+	names := catalog.GetDictionary("Names")
+	if names == nil {
+		return
+	}
+	for _,key := range names.Keys() {
+		tree := names.GetDictionary(key)
+		if tree == nil {
+			continue
+		}
+
+		// Attachments are managed separately by Attach().
+		if key == "EmbeddedFiles" {
+			d.loadEmbeddedFiles()
+			ReadNameTree(tree).ForEach(func(name string, value Object) {
+				if d.embeddedFiles.Get(name) == nil {
+					d.embeddedFiles.Add(name, copier.Copy(value))
+					d.embeddedFilesChanged = true
+				}
+			})
+			continue
+		}
+
+		ourNames := d.catalogDictionary("Names")
+		entries := ReadNameTree(ourNames.GetDictionary(key))
+		ReadNameTree(tree).ForEach(func(name string, value Object) {
+			if entries.Get(name) == nil {
+				entries.Add(name, copier.Copy(value))
+			}
+		})
+		ourNames.Add(key, entries.Build(d.file))
+	}
+}
+
+// renameFields() chooses new names for the form fields of the
+// interactive form dictionary in catalog whose names are already used
+// by the fields of d, adding a suffix such as "_2".  It must be called
+// before the pages are copied, since a field is often also the widget
+// annotation of a page: the copies of the renamed fields are reserved
+// with copier.Translate() so that the pages refer to them and they are
+// written once, with their new names, by mergeAcroForm().  It returns
+// the new names of the renamed fields.
+func (d *Document) renameFields(catalog ProtectedDictionary, copier *ObjectCopier) map[ObjectNumber]string {
+	fields := mergedFields(catalog)
+	if fields == nil {
+		return nil
+	}
+	used := make(map[string]bool)
+	if form := d.catalog.GetDictionary("AcroForm"); form != nil {
+		if ourFields := form.GetArray("Fields"); ourFields != nil {
+			for i:=0; i<ourFields.Size(); i++ {
+				if field,ok := ourFields.At(i).Dereference().(ProtectedDictionary); ok {
+					used[textString(field, "T")] = true
+				}
+			}
+		}
+	}
+
+	renamed := make(map[ObjectNumber]string)
+	for i:=0; i<fields.Size(); i++ {
+		reference,ok := fields.At(i).(ProtectedIndirect)
+		if !ok {
+			continue
+		}
+		field,ok := reference.Dereference().(ProtectedDictionary)
+		if !ok {
+			continue
+		}
+		name := textString(field, "T")
+		if used[name] {
+			base := name
+			for n:=2; used[name]; n++ {
+				name = base + "_" + strconv.Itoa(n)
+			}
+			o := reference.ObjectNumber(copier.src)
+			renamed[o] = name
+			copier.Translate(o, NewIndirect(d.file))
+		}
+		used[name] = true
+	}
+	return renamed
+}
+
+// mergedFields() returns the fields of the interactive form dictionary
+// in catalog, or nil if it has none.
+func mergedFields(catalog ProtectedDictionary) ProtectedArray {
+	if form := catalog.GetDictionary("AcroForm"); form != nil {
+		if fields := form.GetArray("Fields"); fields != nil && fields.Size() > 0 {
+			return fields
+		}
+	}
+	return nil
+}
+
+// mergeAcroForm() adds the form fields of the interactive form
+// dictionary in catalog to those of d, writing the fields that
+// renameFields() renamed with their new names.
+func (d *Document) mergeAcroForm(catalog ProtectedDictionary, copier *ObjectCopier, renamed map[ObjectNumber]string) {
+	fields := mergedFields(catalog)
+	if fields == nil {
+		return
+	}
+	form := catalog.GetDictionary("AcroForm")
+
+	ourForm := d.catalogDictionary("AcroForm")
+	ourFields,ok := ourForm.Get("Fields").(Array)
+	if !ok {
+		ourFields = NewArray()
+		if existing := ourForm.GetArray("Fields"); existing != nil {
+			ourFields.Append(existing)
+		}
+		ourForm.Add("Fields", ourFields)
+	}
+
+	for i:=0; i<fields.Size(); i++ {
+		if source,ok := fields.At(i).(ProtectedIndirect); ok {
+			o := source.ObjectNumber(copier.src)
+			if name,ok := renamed[o]; ok {
+				reference,_ := copier.Translation(o)
+				if field,ok := copier.Copy(source.Dereference()).(Dictionary); ok {
+					field.Add("T", NewTextString(name))
+					reference.Write(field)
+				}
+				ourFields.Add(reference)
+				continue
+			}
+		}
+		if reference,ok := copier.Copy(fields.At(i)).(Indirect); ok {
+			ourFields.Add(reference)
+		}
+	}
+
+	// Fonts and other resources used by field appearances
+	if resources := form.GetDictionary("DR"); resources != nil {
+		ourResources := NewDictionary()
+		if existing := ourForm.GetDictionary("DR"); existing != nil {
+			ourResources = existing.Unprotect().(Dictionary)
+		}
+		for _,category := range resources.Keys() {
+			if entries := resources.GetDictionary(category); entries != nil {
+				ourEntries := resourceCategory(ourResources, category)
+				for _,name := range entries.Keys() {
+					if ourEntries.Get(name) == nil {
+						ourEntries.Add(name, copier.Copy(entries.Get(name)))
+					}
+				}
+			}
+		}
+		ourForm.Add("DR", ourResources)
+	}
+}
+
+// finishOutline() writes the outline built by Append().
+func (d *Document) finishOutline() {
+	if len(d.outlineSections) == 0 {
+		return
+	}
+
+	root := NewIndirect(d.file)
+	count := 0
+	for i,section := range d.outlineSections {
+		item := NewDictionary()
+		item.Add("Title", NewTextString(section.title))
+		item.Add("Parent", root)
+		if section.page != nil {
+			destination := NewArray()
+			destination.Add(section.page)
+			destination.Add(NewName("Fit"))
+			item.Add("Dest", destination)
+		}
+		if i > 0 {
+			item.Add("Prev", d.outlineSections[i-1].reference)
+		}
+		if i < len(d.outlineSections)-1 {
+			item.Add("Next", d.outlineSections[i+1].reference)
+		}
+		if section.first != nil && section.last != nil {
+			item.Add("First", section.first)
+			item.Add("Last", section.last)
+			if section.count != 0 {
+				item.Add("Count", NewIntNumeric(section.count))
+			}
+		}
+		section.reference.Write(item)
+
+		count += 1
+		if section.count > 0 {
+			count += section.count
+		}
+	}
+
+	outlines := NewDictionary()
+	outlines.Add("Type", NewName("Outlines"))
+	outlines.Add("First", d.outlineSections[0].reference)
+	outlines.Add("Last", d.outlineSections[len(d.outlineSections)-1].reference)
+	outlines.Add("Count", NewIntNumeric(count))
+	d.catalog.Add("Outlines", root.Write(outlines))
+	d.outlineSections = nil
+}
@@ -23,6 +23,10 @@ func (f *mockFile) Closed() bool {
 	return f.closed
 }
 
+func (f *mockFile) Permissions() Permissions {
+	return AllPermissions()
+}
+
 // Implements WriteObject() in File interface
 func (f *mockFile) WriteObject(object Object) (reference Indirect) {
 	return NewIndirect(f).Write(object)
@@ -69,3 +73,11 @@ func (f *mockFile) Trailer() ProtectedDictionary {
 	return nil
 }
 
+func (f *mockFile) ID() (permanent, changing []byte) {
+	return nil, nil
+}
+
+func (f *mockFile) Revisions() []Revision {
+	return nil
+}
+
@@ -14,16 +14,6 @@ func NewName(s string) Name {
 	return &name{s}
 }
 
-func nameEscapeByte(b byte) (result []byte) {
-	switch {
-	case b != '#' && IsRegular(b):
-		result = []byte{b}
-	default:
-		result = []byte{'#', HexDigit(b / 16), HexDigit(b % 16)}
-	}
-	return result
-}
-
 func (n *name) Clone() Object {
 	// Names are intended to be immutable, so return a pointer
 	// to the same instance
@@ -50,11 +40,21 @@ func (n *name) Unprotect() Object {
 
 
 func (n *name) Serialize(w Writer, file ...File) {
+	writeName(w, n.name)
+}
+
+// writeName() writes the name s to w, escaping the bytes that must be.
+func writeName(w Writer, s string) {
 	w.WriteByte('/')
-	for _, b := range []byte(n.name) {
-		w.Write(nameEscapeByte(b))
+	for i:=0; i<len(s); i++ {
+		if b := s[i]; b != '#' && IsRegular(b) {
+			w.WriteByte(b)
+		} else {
+			w.WriteByte('#')
+			w.WriteByte(HexDigit(b / 16))
+			w.WriteByte(HexDigit(b % 16))
+		}
 	}
-	return
 }
 
 func (n *name) String() string {
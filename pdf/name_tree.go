@@ -0,0 +1,117 @@
+package pdf
+
+import "sort"
+
+// NameTree holds the entries of a name tree, such as the /Dests or
+// /EmbeddedFiles trees of the catalog's /Names dictionary, which map
+// strings to objects.  Keys are the raw bytes of the PDF strings,
+// which determine the order of the entries; use
+// NewTextString(s).Bytes() to obtain the key for text s.
+type NameTree struct {
+	entries map[string]Object
+}
+
+// NewNameTree() constructs an empty NameTree.
+func NewNameTree() *NameTree {
+	return &NameTree{make(map[string]Object)}
+}
+
+// ReadNameTree() reads the entries of the name tree rooted at root,
+// which may be nil, descending through /Kids as necessary.
+func ReadNameTree(root ProtectedDictionary) *NameTree {
+	t := NewNameTree()
+	if root != nil {
+		forEachNameTreeEntry(root, func(key string, value Object) {
+			t.entries[key] = value
+		})
+	}
+	return t
+}
+
+// Add() adds an entry to the tree, replacing any entry with the same
+// key.
+func (t *NameTree) Add(key string, value Object) {
+	t.entries[key] = value
+}
+
+// Get() returns the value of the entry with the passed key, or nil if
+// there is none.
+func (t *NameTree) Get(key string) Object {
+	return t.entries[key]
+}
+
+// Remove() removes the entry with the passed key, if there is one.
+func (t *NameTree) Remove(key string) {
+	delete(t.entries, key)
+}
+
+// Size() returns the number of entries in the tree.
+func (t *NameTree) Size() int {
+	return len(t.entries)
+}
+
+// Keys() returns the keys of the tree's entries in order.
+func (t *NameTree) Keys() []string {
+	keys := make([]string, 0, len(t.entries))
+	for key := range t.entries {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// ForEach() calls f with the key and value of each entry in order.
+func (t *NameTree) ForEach(f func(key string, value Object)) {
+	for _,key := range t.Keys() {
+		f(key, t.entries[key])
+	}
+}
+
+// Build() constructs the root node of a balanced name tree
+// containing the entries.  Small trees consist of the root alone;
+// the intermediate and leaf nodes of larger trees are written to
+// file as indirect objects, as the PDF specification requires.
+func (t *NameTree) Build(file File) Dictionary {
+	keys := t.Keys()
+	pairs := make([]Object, 0, 2*len(keys))
+	for _,key := range keys {
+		pairs = append(pairs, NewBinaryString([]byte(key)), t.entries[key])
+	}
+	return buildTree(file, "Names", pairs)
+}
+
+// forEachNameTreeEntry() calls f with the raw bytes of each key and
+// its value in the name tree rooted at node, in the order in which
+// they appear in the tree.
+func forEachNameTreeEntry(node ProtectedDictionary, f func(string, Object)) {
+	forEachNameTreeEntryAtDepth(node, f, 0, make(map[objectKey]bool))
+}
+
+// forEachNameTreeEntryAtDepth() skips kids in visited, the nodes
+// already walked, so that a node listed more than once in /Kids is
+// read once.
+func forEachNameTreeEntryAtDepth(node ProtectedDictionary, f func(string, Object), depth int, visited map[objectKey]bool) {
+	if depth > maxTreeDepth {
+		return
+	}
+	if kids := node.GetArray("Kids"); kids != nil {
+		for i:=0; i<kids.Size(); i++ {
+			if key,ok := sourceKey(kids.At(i)); ok {
+				if visited[key] {
+					continue
+				}
+				visited[key] = true
+			}
+			if kid,ok := kids.At(i).Dereference().(ProtectedDictionary); ok {
+				forEachNameTreeEntryAtDepth(kid, f, depth+1, visited)
+			}
+		}
+	}
+	if names := node.GetArray("Names"); names != nil {
+		for i:=0; i+1<names.Size(); i+=2 {
+			if key,ok := names.At(i).(ProtectString); ok {
+				f(string(key.Bytes()), names.At(i+1))
+			}
+		}
+	}
+}
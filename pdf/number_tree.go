@@ -0,0 +1,193 @@
+package pdf
+
+import "sort"
+
+// maxTreeDepth limits the recursion when reading name and number
+// trees so that malformed (e.g., circular) trees cannot exhaust the
+// stack.
+const maxTreeDepth = 32
+
+// treeNodeSize is the maximum number of entries in a leaf node, or
+// of kids in any other node, of the name and number trees built by
+// Build().
+const treeNodeSize = 64
+
+// NumberTree holds the entries of a number tree, such as the
+// catalog's /PageLabels or the structure tree's /ParentTree, which
+// map integers to objects.
+type NumberTree struct {
+	entries map[int]Object
+}
+
+// NewNumberTree() constructs an empty NumberTree.
+func NewNumberTree() *NumberTree {
+	return &NumberTree{make(map[int]Object)}
+}
+
+// ReadNumberTree() reads the entries of the number tree rooted at
+// root, which may be nil, descending through /Kids as necessary.
+func ReadNumberTree(root ProtectedDictionary) *NumberTree {
+	t := NewNumberTree()
+	if root != nil {
+		forEachNumberTreeEntry(root, func(key int, value Object) {
+			t.entries[key] = value
+		})
+	}
+	return t
+}
+
+// Add() adds an entry to the tree, replacing any entry with the same
+// key.
+func (t *NumberTree) Add(key int, value Object) {
+	t.entries[key] = value
+}
+
+// Get() returns the value of the entry with the passed key, or nil if
+// there is none.
+func (t *NumberTree) Get(key int) Object {
+	return t.entries[key]
+}
+
+// Remove() removes the entry with the passed key, if there is one.
+func (t *NumberTree) Remove(key int) {
+	delete(t.entries, key)
+}
+
+// Size() returns the number of entries in the tree.
+func (t *NumberTree) Size() int {
+	return len(t.entries)
+}
+
+// Keys() returns the keys of the tree's entries in increasing order.
+func (t *NumberTree) Keys() []int {
+	keys := make([]int, 0, len(t.entries))
+	for key := range t.entries {
+		keys = append(keys, key)
+	}
+	sort.Ints(keys)
+	return keys
+}
+
+// ForEach() calls f with the key and value of each entry in
+// increasing order of key.
+func (t *NumberTree) ForEach(f func(key int, value Object)) {
+	for _,key := range t.Keys() {
+		f(key, t.entries[key])
+	}
+}
+
+// Build() constructs the root node of a balanced number tree
+// containing the entries.  Small trees consist of the root alone;
+// the intermediate and leaf nodes of larger trees are written to
+// file as indirect objects, as the PDF specification requires.
+func (t *NumberTree) Build(file File) Dictionary {
+	keys := t.Keys()
+	pairs := make([]Object, 0, 2*len(keys))
+	for _,key := range keys {
+		pairs = append(pairs, NewIntNumeric(key), t.entries[key])
+	}
+	return buildTree(file, "Nums", pairs)
+}
+
+// buildTree() constructs a name or number tree from pairs, which
+// alternates keys in increasing order with their values.  entriesKey
+// is "Names" or "Nums".  Leaves hold up to treeNodeSize entries and
+// are grouped under intermediate nodes of up to treeNodeSize kids,
+// level by level, until the root's kids number no more than
+// treeNodeSize.
+func buildTree(file File, entriesKey string, pairs []Object) Dictionary {
+	root := NewDictionary()
+	if len(pairs) <= 2*treeNodeSize {
+		entries := NewArray()
+		for _,o := range pairs {
+			entries.Add(o)
+		}
+		root.Add(entriesKey, entries)
+		return root
+	}
+
+	type node struct {
+		first, last Object
+		reference Indirect
+	}
+	newNode := func(first, last Object, contentsKey string, contents Array) node {
+		dictionary := NewDictionary()
+		limits := NewArray()
+		limits.Add(first.Clone())
+		limits.Add(last.Clone())
+		dictionary.Add("Limits", limits)
+		dictionary.Add(contentsKey, contents)
+		return node{first, last, NewIndirect(file).Write(dictionary)}
+	}
+
+	var level []node
+	for i:=0; i<len(pairs); i+=2*treeNodeSize {
+		end := i + 2*treeNodeSize
+		if end > len(pairs) {
+			end = len(pairs)
+		}
+		entries := NewArray()
+		for _,o := range pairs[i:end] {
+			entries.Add(o)
+		}
+		level = append(level, newNode(pairs[i], pairs[end-2], entriesKey, entries))
+	}
+
+	for len(level) > treeNodeSize {
+		var next []node
+		for i:=0; i<len(level); i+=treeNodeSize {
+			end := i + treeNodeSize
+			if end > len(level) {
+				end = len(level)
+			}
+			kids := NewArray()
+			for _,kid := range level[i:end] {
+				kids.Add(kid.reference)
+			}
+			next = append(next, newNode(level[i].first, level[end-1].last, "Kids", kids))
+		}
+		level = next
+	}
+
+	kids := NewArray()
+	for _,kid := range level {
+		kids.Add(kid.reference)
+	}
+	root.Add("Kids", kids)
+	return root
+}
+
+// forEachNumberTreeEntry() calls f with each key and value in the
+// number tree rooted at node, in the order in which they appear in
+// the tree.
+func forEachNumberTreeEntry(node ProtectedDictionary, f func(int, Object)) {
+	forEachNumberTreeEntryAtDepth(node, f, 0, make(map[objectKey]bool))
+}
+
+// As with name trees, visited holds the nodes already walked so that
+// a /Kids array naming an ancestor doesn't walk it again.
+func forEachNumberTreeEntryAtDepth(node ProtectedDictionary, f func(int, Object), depth int, visited map[objectKey]bool) {
+	if depth > maxTreeDepth {
+		return
+	}
+	if kids := node.GetArray("Kids"); kids != nil {
+		for i:=0; i<kids.Size(); i++ {
+			if key,ok := sourceKey(kids.At(i)); ok {
+				if visited[key] {
+					continue
+				}
+				visited[key] = true
+			}
+			if kid,ok := kids.At(i).Dereference().(ProtectedDictionary); ok {
+				forEachNumberTreeEntryAtDepth(kid, f, depth+1, visited)
+			}
+		}
+	}
+	if nums := node.GetArray("Nums"); nums != nil {
+		for i:=0; i+1<nums.Size(); i+=2 {
+			if key,ok := nums.At(i).(*IntNumeric); ok {
+				f(key.Value(), nums.At(i+1))
+			}
+		}
+	}
+}
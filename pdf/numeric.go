@@ -1,5 +1,7 @@
 package pdf
 
+import "bytes"
+import "fmt"
 import "math"
 import "strconv"
 
@@ -48,7 +50,11 @@ func (n *RealNumeric) Unprotect() Object {
 }
 
 func (n *RealNumeric) Serialize(w Writer, file ...File) {
-	w.WriteString(strconv.FormatFloat(float64(n.value), 'f', -1, 32))
+	if precision := realPrecision(file...); precision >= 0 {
+		w.Write(appendReal(scratch(w), float64(n.value), precision))
+	} else {
+		w.Write(strconv.AppendFloat(scratch(w), float64(n.value), 'f', -1, 32))
+	}
 }
 
 func (n *RealNumeric) Value() float32 {
@@ -82,7 +88,7 @@ func (n *IntNumeric) Unprotect() Object {
 }
 
 func (n *IntNumeric) Serialize(w Writer, file ...File) {
-	w.WriteString(strconv.FormatInt(int64(n.value), 10))
+	writeInt(w, int64(n.value))
 }
 
 func (n *IntNumeric) Value() int {
@@ -91,6 +97,10 @@ func (n *IntNumeric) Value() int {
 
 func adjustRealRange(v float64) (float32Value float32) {
 	switch {
+	case math.IsNaN(v):
+		// PDF has no representation of NaN.
+		float32Value = 0.0
+
 	case v > math.MaxFloat32:
 		float32Value = math.MaxFloat32
 
@@ -115,7 +125,7 @@ func NewIntNumeric(v int) Object {
 }
 
 func NewRealNumeric(v float32) Object {
-	return &RealNumeric{v}
+	return &RealNumeric{adjustRealRange(float64(v))}
 }
 
 func NewNumeric(v float64) (result Object) {
@@ -131,3 +141,77 @@ func NewNumeric(v float64) (result Object) {
 
 	return result
 }
+
+// numericValue() returns the value of o, dereferencing as necessary,
+// as a float64.  The boolean return value is false if o is not a
+// number.
+func numericValue(o Object) (float64, bool) {
+	if o == nil {
+		return 0, false
+	}
+	switch n := o.Dereference().(type) {
+	case *IntNumeric:
+		return float64(n.Value()), true
+	case *RealNumeric:
+		return float64(n.Value()), true
+	}
+	return 0, false
+}
+
+// FormatReal() formats v as a PDF real number rounded to precision
+// digits after the decimal point, omitting trailing zeros.  If
+// precision is negative, as many digits as are needed to represent v
+// exactly are used.  Exponent notation, which PDF doesn't allow, is
+// never used.
+func FormatReal(v float64, precision int) string {
+	return string(appendReal(nil, v, precision))
+}
+
+// appendReal() appends v to dst formatted as by FormatReal().
+func appendReal(dst []byte, v float64, precision int) []byte {
+	start := len(dst)
+	dst = strconv.AppendFloat(dst, v, 'f', precision, 64)
+	if bytes.IndexByte(dst[start:], '.') >= 0 {
+		for dst[len(dst)-1] == '0' {
+			dst = dst[:len(dst)-1]
+		}
+		if dst[len(dst)-1] == '.' {
+			dst = dst[:len(dst)-1]
+		}
+	}
+	if string(dst[start:]) == "-0" {
+		dst = append(dst[:start], '0')
+	}
+	return dst
+}
+
+// WithRealPrecision() returns a FileOption that rounds real numbers
+// written to the file, whether in objects such as arrays or, through
+// FormatReal(), in the content streams this package generates, to the
+// specified number of digits after the decimal point.  Two to four
+// digits are usually enough for coordinates and make content streams
+// much smaller than the default, which writes every digit of a real.
+func WithRealPrecision(digits int) FileOption {
+	if digits < 0 {
+		panic(fmt.Sprintf("Invalid real precision %d", digits))
+	}
+	return func(f *file) {
+		f.realPrecision = digits
+	}
+}
+
+func (f *file) realDigits() int {
+	return f.realPrecision
+}
+
+// realPrecision() returns the number of digits after the decimal
+// point to which reals written to the first of the files are
+// rounded, or -1 if they aren't rounded.
+func realPrecision(file ...File) int {
+	if len(file) > 0 {
+		if p,ok := file[0].(interface{ realDigits() int }); ok {
+			return p.realDigits()
+		}
+	}
+	return -1
+}
@@ -0,0 +1,102 @@
+package pdf
+
+import (
+	"container/list" )
+
+// defaultObjectCacheBudget is the byte budget of the object cache
+// unless WithObjectCache() specifies another.
+const defaultObjectCacheBudget = 4 << 20
+
+// objectCache holds objects parsed by file.Object() so that objects
+// read repeatedly, such as the nodes of the page tree, are not
+// reparsed.  Objects are evicted in least-recently-used order when
+// the sizes of their serializations exceed the budget.  No single
+// object may use more than a quarter of the budget, so large image
+// streams are not cached.
+type objectCache struct {
+	budget, size int64
+	entries map[ObjectNumber]*list.Element
+	// order has the most recently used entry at the front.
+	order *list.List
+	statistics CacheStatistics
+}
+
+type cachedObject struct {
+	objectNumber ObjectNumber
+	object Object
+	size int64
+}
+
+// CacheStatistics describes the use of a file's object cache.
+type CacheStatistics struct {
+	Hits, Misses uint64
+	// Size is the total size of the cached objects in bytes.
+	Size int64
+}
+
+func newObjectCache(budget int64) *objectCache {
+	return &objectCache{
+		budget: budget,
+		entries: make(map[ObjectNumber]*list.Element),
+		order: list.New()}
+}
+
+// get() returns a copy of the cached object o, which the caller
+// owns, or false if o isn't cached.
+func (c *objectCache) get(o ObjectNumber) (Object, bool) {
+	element,exists := c.entries[o]
+	if !exists {
+		c.statistics.Misses += 1
+		return nil, false
+	}
+	c.statistics.Hits += 1
+	c.order.MoveToFront(element)
+	return element.Value.(*cachedObject).object.Clone(), true
+}
+
+// put() adds a copy of object to the cache.  size is the length of
+// its serialization.
+func (c *objectCache) put(o ObjectNumber, object Object, size int64) {
+	c.remove(o)
+	if size > c.budget/4 {
+		return
+	}
+	c.entries[o] = c.order.PushFront(&cachedObject{o, object.Clone(), size})
+	c.size += size
+	for c.size > c.budget {
+		c.remove(c.order.Back().Value.(*cachedObject).objectNumber)
+	}
+}
+
+// remove() evicts o, which must be done whenever o is rewritten.
+func (c *objectCache) remove(o ObjectNumber) {
+	if element,exists := c.entries[o]; exists {
+		c.size -= element.Value.(*cachedObject).size
+		c.order.Remove(element)
+		delete(c.entries, o)
+	}
+}
+
+// WithObjectCache() returns a FileOption that sets the byte budget of
+// the cache of objects read from a pre-existing file.  A budget of 0
+// disables the cache.
+func WithObjectCache(budget int64) FileOption {
+	return func(f *file) {
+		f.cache = nil
+		if budget > 0 {
+			f.cache = newObjectCache(budget)
+		}
+	}
+}
+
+// CacheStatistics() describes the use of the file's object cache.
+func (f *file) CacheStatistics() CacheStatistics {
+	if f.cache == nil {
+		return CacheStatistics{}
+	}
+	<-f.semaphore
+	defer func() { f.semaphore<-true }()
+	statistics := f.cache.statistics
+	statistics.Size = f.cache.size
+	return statistics
+}
@@ -3,6 +3,9 @@ package pdf
 import "bufio"
 import "bytes"
 
+// Writer is the destination of Object.Serialize().  Both
+// *bufio.Writer and *bytes.Buffer implement it.  SerializeTo()
+// serializes an object to any io.Writer.
 type Writer interface {
 	Write(p []byte) (nn int, err error)
 	WriteByte(c byte) error
@@ -10,6 +13,11 @@ type Writer interface {
 	WriteString(s string) (int, error)
 }
 
+var (
+	_ Writer = (*bufio.Writer)(nil)
+	_ Writer = (*bytes.Buffer)(nil))
+
+
 // All PDF objects implement the pdf.Object inteface
 type Object interface {
 	// Clone() copies the full state of the object so that future
@@ -78,8 +86,6 @@ type ObjectStringDecorator struct {
 
 func (o ObjectStringDecorator) String(file ...File) string {
 	var buffer bytes.Buffer
-	f := bufio.NewWriter(&buffer)
-	o.Serialize(f, file...)
-	f.Flush()
+	SerializeTo(&buffer, o.Object, file...)
 	return buffer.String()
 }
@@ -0,0 +1,77 @@
+package pdf
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings")
+
+var invalidObjectNumber = errors.New(`Object number must have the form "N G R"`)
+
+// ObjectNumber identifies an object in a File by its object and
+// generation numbers.  ObjectNumbers are comparable and may be used
+// as map keys.
+type ObjectNumber struct {
+	number     uint32
+	generation uint16
+}
+
+func NewObjectNumber(number uint32, generation uint16) ObjectNumber {
+	return ObjectNumber{number,generation}
+}
+
+// ParseObjectNumber() parses an object number written as a reference
+// is, for example "12 0 R".
+func ParseObjectNumber(s string) (ObjectNumber, error) {
+	fields := strings.Fields(s)
+	if len(fields) != 3 || fields[2] != "R" {
+		return ObjectNumber{},invalidObjectNumber
+	}
+	number,err := strconv.ParseUint(fields[0], 10, 32)
+	if err != nil {
+		return ObjectNumber{},invalidObjectNumber
+	}
+	generation,err := strconv.ParseUint(fields[1], 10, 16)
+	if err != nil {
+		return ObjectNumber{},invalidObjectNumber
+	}
+	return ObjectNumber{uint32(number),uint16(generation)},nil
+}
+
+// Number() returns the object number of o.
+func (o ObjectNumber) Number() uint32 {
+	return o.number
+}
+
+// Generation() returns the generation number of o.
+func (o ObjectNumber) Generation() uint16 {
+	return o.generation
+}
+
+// String() returns o in the form of a reference, for example "12 0 R".
+func (o ObjectNumber) String() string {
+	return fmt.Sprintf("%d %d R", o.number, o.generation)
+}
+
+// Compare() returns -1, 0, or 1 as o orders before, the same as, or
+// after other.  Object numbers are ordered by number, then by
+// generation.
+func (o ObjectNumber) Compare(other ObjectNumber) int {
+	switch {
+	case o.number < other.number:
+		return -1
+	case o.number > other.number:
+		return 1
+	case o.generation < other.generation:
+		return -1
+	case o.generation > other.generation:
+		return 1
+	}
+	return 0
+}
+
+// Less() returns true if o orders before other, as described for
+// Compare().
+func (o ObjectNumber) Less(other ObjectNumber) bool {
+	return o.Compare(other) < 0
+}
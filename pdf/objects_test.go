@@ -1,9 +1,20 @@
 package pdf_test
 
 import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"crypto/sha256"
+	"errors"
 	"fmt"
 	"github.com/mawicks/PDFiG/pdf"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"reflect"
 	"strconv"
+	"strings"
 	"testing"
 	)
 
@@ -55,6 +66,59 @@ func TestObjectDecorator(t *testing.T) {
 	checkObject(t, "ObjectStringDecorator", o, nil, "null")
 }
 
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, io.ErrShortWrite
+}
+
+func TestSerializeTo(t *testing.T) {
+	a := pdf.NewArray()
+	a.Add(pdf.NewIntNumeric(1))
+	a.Add(pdf.NewName("Name"))
+	hash := sha256.New()
+	if err := pdf.SerializeTo(hash, a); err != nil {
+		t.Errorf(`SerializeTo() failed: %v`, err)
+	}
+	if expected := sha256.Sum256([]byte(toString(a))); !bytes.Equal(hash.Sum(nil), expected[:]) {
+		t.Errorf(`SerializeTo() wrote something other than the serialization`)
+	}
+	if err := pdf.SerializeTo(failingWriter{}, a); err != io.ErrShortWrite {
+		t.Errorf(`SerializeTo() returned %v from a failing writer`, err)
+	}
+}
+
+func TestBytes(t *testing.T) {
+	d := pdf.NewDictionary()
+	d.Add("Type", pdf.NewName("Example"))
+	d.Add("Flag", pdf.NewBoolean(true))
+	d.Add("Nothing", pdf.NewNull())
+	d.Add("Number", pdf.NewIntNumeric(12345))
+	d.Add("Real", pdf.NewNumeric(1.5))
+	d.Add("Text", pdf.NewBinaryString([]byte("Some text")))
+	a := pdf.NewIntArray([]int{1, 2, 3, 100})
+	d.Add("Array", a)
+	s := pdf.NewStream()
+	s.Write(bytes.Repeat([]byte("0 0 m 100 100 l S\n"), 20))
+	s.Add("Resources", d)
+
+	for _,o := range []pdf.Object{pdf.NewNull(), pdf.NewIntNumeric(-7), a, d, s, d.Protect(), s.Protect()} {
+		expected := toString(o)
+		if b := pdf.Bytes(o); string(b) != expected {
+			t.Errorf(`Bytes() returned %q; expected %q`, b, expected)
+		}
+		if b := pdf.AppendBytes([]byte("prefix "), o); string(b) != "prefix " + expected {
+			t.Errorf(`AppendBytes() returned %q; expected %q`, b, "prefix " + expected)
+		}
+		if s := pdf.SerializedString(o); s != expected {
+			t.Errorf(`SerializedString() returned %q; expected %q`, s, expected)
+		}
+		if n := pdf.EstimatedSize(o); n < len(expected)/2 || n > 2*len(expected) {
+			t.Errorf(`EstimatedSize() of %q is %d`, expected, n)
+		}
+	}
+}
+
 func checkStringFromText(t *testing.T, testValue, expect string, serializer func(pdf.String, pdf.Writer)) {
 	s := pdf.NewTextString(testValue)
 	s.SetSerializer(serializer)
@@ -106,6 +170,43 @@ func TestNumeric(t *testing.T) {
 	checkObject(t, "NewNumeric(-1.175e-38)", pdf.NewNumeric(-1.175e-38), nil, "0")
 }
 
+func TestFormatReal(t *testing.T) {
+	for _,c := range []struct{ v float64; precision int; expect string } {
+		{ 1.23456, 2, "1.23" },
+		{ 1.235, 4, "1.235" },
+		{ 2.5, 0, "2" },
+		{ 10, 3, "10" },
+		{ -0.0001, 2, "0" },
+		{ 1e-7, -1, "0.0000001" },
+		{ 1e21, 2, "1000000000000000000000" },
+		{ 2.4000000000000004, 4, "2.4" } } {
+		if s := pdf.FormatReal(c.v, c.precision); s != c.expect {
+			t.Errorf("FormatReal(%v, %d) produced %q; expected %q", c.v, c.precision, s, c.expect)
+		}
+	}
+
+	f := pdf.NewMemoryFile(pdf.WithRealPrecision(2))
+	checkObject(t, "Array with precision 2", pdf.NewFloatArray([]float64{1.23456, 0.5, 1e-7, 612}), f, "[1.23 0.5 0 612]")
+	checkObject(t, "Array with default precision", pdf.NewFloatArray([]float64{1.25, 1e-7}), nil, "[1.25 0.0000001]")
+	f.Close()
+}
+
+func TestSerializeAllocations(t *testing.T) {
+	d := pdf.NewDictionary()
+	d.Add("Count", pdf.NewIntNumeric(12345))
+	d.Add("Scale", pdf.NewNumeric(1.5))
+	d.Add("Name", pdf.NewName("A#B"))
+	d.Add("Title", pdf.NewTextString("Escaped (parentheses)"))
+	d.Add("Box", pdf.NewFloatArray([]float64{0, 0, 612, 792}))
+	f := pdf.NewMockFile(1, 0)
+	w := bufio.NewWriter(ioutil.Discard)
+	// The only allocation is of the slice holding the variadic
+	// file argument.
+	if n := testing.AllocsPerRun(100, func() { d.Serialize(w, f) }); n > 1 {
+		t.Errorf(`Serialize() made %v allocations`, n)
+	}
+}
+
 func TestName(t *testing.T) {
 	checkObject(t, `NewName("foo")`, pdf.NewName("foo"), nil, "/foo")
 	checkObject(t, `NewName("résumé")`, pdf.NewName("résumé"), nil, "/résumé")
@@ -192,6 +293,61 @@ func TestArray(t *testing.T) {
 	checkObject(t, "Array test", c, nil, "[1 [2]]")
 }
 
+func TestArrayEditing(t *testing.T) {
+	a := pdf.NewIntArray([]int{1, 2, 3})
+	a.Insert(0, pdf.NewNumeric(0))
+	a.Insert(4, pdf.NewNumeric(4))
+	a.Insert(2, pdf.NewName("x"))
+	checkObject(t, "Insert()", a, nil, "[0 1 /x 2 3 4]")
+
+	a.Remove(2)
+	a.Remove(0)
+	a.Remove(3)
+	checkObject(t, "Remove()", a, nil, "[1 2 3]")
+
+	a.Set(1, pdf.NewNumeric(5))
+	checkObject(t, "Set()", a, nil, "[1 5 3]")
+
+	a.Append(pdf.NewFloatArray([]float64{0.5, 6}))
+	checkObject(t, "Append()", a, nil, "[1 5 3 0.5 6]")
+
+	for _,f := range []func(){ func() { a.Remove(5) }, func() { a.Insert(-1, pdf.NewNull()) } } {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Error("Index out of range did not panic")
+				}
+			}()
+			f()
+		}()
+	}
+}
+
+func TestArrayConversion(t *testing.T) {
+	floats,ok := pdf.NewFloatArray([]float64{0, 0, 612, 791.5}).Protect().(pdf.ProtectedArray).Floats()
+	if !ok || len(floats) != 4 || floats[2] != 612 || floats[3] != 791.5 {
+		t.Errorf("Floats() returned %v, %v", floats, ok)
+	}
+
+	ints,ok := pdf.NewIntArray([]int{3, 2}).Ints()
+	if !ok || len(ints) != 2 || ints[0] != 3 || ints[1] != 2 {
+		t.Errorf("Ints() returned %v, %v", ints, ok)
+	}
+	if _,ok := pdf.NewFloatArray([]float64{1.5}).Ints(); ok {
+		t.Error("Ints() accepted a real")
+	}
+
+	texts,ok := pdf.NewStringArray([]string{"a", "\u03c0"}).Strings()
+	if !ok || len(texts) != 2 || texts[0] != "a" || texts[1] != "\u03c0" {
+		t.Errorf("Strings() returned %q, %v", texts, ok)
+	}
+	mixed := pdf.NewStringArray([]string{"a"})
+	mixed.Add(pdf.NewNumeric(1))
+	if _,ok := mixed.Strings(); ok {
+		t.Error("Strings() accepted a number")
+	}
+}
+
 // Check for specific types stored under specific names.  This
 // function requires that the dictionary has been populated with
 // specific types under pre-defined keys.
@@ -351,6 +507,55 @@ func TestDictionaryOperations(t *testing.T) {
 	checkObject(t, "Dictionary.Remove() test", d, nil, "<<>>")
 }
 
+func TestDictionaryOrder(t *testing.T) {
+	d := pdf.NewDictionary()
+	for _,key := range []string{"Type", "Z", "A", "M"} {
+		d.Add(key, pdf.NewName(key))
+	}
+	checkObject(t, "Insertion order", d, nil, "<</Type /Type /Z /Z /A /A /M /M>>")
+
+	// Replacing a value keeps its position; a key that is
+	// removed and added again moves to the end.
+	d.Add("Z", pdf.NewIntNumeric(1))
+	d.Remove("A")
+	d.Add("A", pdf.NewIntNumeric(2))
+	checkObject(t, "Order after replacement", d, nil, "<</Type /Type /Z 1 /M /M /A 2>>")
+	checkObjectBasic(t, "Order of unprotected copy", d.Protect().Unprotect(), nil, "<</Type /Type /Z 1 /M /M /A 2>>")
+
+	var keys []string
+	d.Protect().(pdf.ProtectedDictionary).ForEach(func(key string, value pdf.Object) {
+		keys = append(keys, key)
+	})
+	if fmt.Sprint(keys) != fmt.Sprint(d.Keys()) || fmt.Sprint(keys) != "[Type Z M A]" {
+		t.Errorf(`ForEach() visited %v; Keys() returned %v`, keys, d.Keys())
+	}
+
+	// ForEach() of a protected dictionary passes protected values.
+	withArray := pdf.NewDictionary()
+	withArray.Add("Kids", pdf.NewArray())
+	withArray.Protect().(pdf.ProtectedDictionary).ForEach(func(key string, value pdf.Object) {
+		if _,ok := value.(pdf.Array); ok {
+			t.Errorf(`ForEach() of a protected dictionary passed a modifiable value`)
+		}
+	})
+
+	// ForEach() may change the dictionary.
+	d.ForEach(func(key string, value pdf.Object) {
+		d.Remove(key)
+	})
+	if d.Size() != 0 || len(d.Keys()) != 0 {
+		t.Errorf(`Dictionary has %d entries after removing all of them`, d.Size())
+	}
+
+	// Parsed dictionaries serialize in the order in which they
+	// were read.
+	source := "<</Type /Page /Parent 1 0 R /MediaBox [0 0 612 792] /Contents 2 0 R /Resources <</Font <</F2 3 0 R /F1 4 0 R>>>>>>"
+	parsed,_ := pdf.NewParser(strings.NewReader(source)).Scan(mockFile)
+	for i:=0; i<10; i++ {
+		checkObjectBasic(t, "Parsed dictionary", parsed, nil, "<</Type /Page /Parent ? ? R /MediaBox [0 0 612 792] /Contents ? ? R /Resources <</Font <</F2 ? ? R /F1 ? ? R>>>>>>")
+	}
+}
+
 func TestStream(t *testing.T) {
 	s := pdf.NewStream()
 	fmt.Fprint(s, "foo")
@@ -361,6 +566,37 @@ func TestStream(t *testing.T) {
 	checkObject(t, "NewStream", s, nil, "<</Length 3>>\nstream\nfoo\nendstream")
 }
 
+func TestCompression(t *testing.T) {
+	newStream := func(subtype string) pdf.Stream {
+		s := pdf.NewStream()
+		if subtype != "" {
+			s.Add("Subtype", pdf.NewName(subtype))
+		}
+		s.AddFilter(new(pdf.FlateFilter))
+		fmt.Fprint(s, strings.Repeat("foo ", 100))
+		return s
+	}
+
+	f := pdf.NewMemoryFile(pdf.WithCompression(pdf.ContentStreams, pdf.StoreOnly), pdf.WithCompression(pdf.ImageStreams, zlib.BestCompression))
+	defer f.Close()
+	checkObject(t, "Stored content stream", newStream(""), f, "<</Length 400>>\nstream\n" + strings.Repeat("foo ", 100) + "\nendstream")
+	image := toString(newStream("Image"), f)
+	if !strings.Contains(image, "/Filter /FlateDecode") || len(image) > 100 {
+		t.Errorf(`Image compressed at level 9 serialized as %q`, image)
+	}
+	// Without the option, the filter's own level is used.
+	if uncompressed := toString(newStream(""), pdf.NewMockFile(1, 0)); len(uncompressed) < 400 {
+		t.Errorf(`Stream compressed at level 0 serialized as %q`, uncompressed)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error(`WithCompression() accepted level 10`)
+		}
+	}()
+	pdf.WithCompression(pdf.ContentStreams, 10)
+}
+
 func TestIndirect(t *testing.T) {
 	// Two objects
 	i1 := pdf.NewIndirect()
@@ -393,3 +629,451 @@ func TestIndirect(t *testing.T) {
 func TestRectangle(t *testing.T) {
 	checkObject(t, "Rectangle test", pdf.NewRectangle(1, 2, 3, 4), nil, "[1 2 3 4]")
 }
+
+func TestFinalize(t *testing.T) {
+	filename := "/tmp/test-finalize.pdf"
+	f,_,_ := pdf.OpenFile(filename, os.O_RDWR|os.O_CREATE|os.O_TRUNC)
+
+	// Hand out a reference to the parent before it is known.
+	parent := pdf.NewIndirect()
+	child := pdf.NewDictionary()
+	child.Add("Parent", parent)
+	childReference := f.WriteObject(child)
+	if parent.Finalized() || !parent.BoundToFile(f) {
+		t.Errorf(`Forward reference should be bound but not finalized`)
+	}
+
+	kids := pdf.NewArray()
+	kids.Add(childReference)
+	node := pdf.NewDictionary()
+	node.Add("Kids", kids)
+	parent.Finalize(node)
+	if !parent.Finalized() || !parent.Protect().(pdf.ProtectedIndirect).Finalized() {
+		t.Errorf(`Finalize() did not finalize reference`)
+	}
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Errorf(`Second Finalize() did not panic`)
+			}
+		}()
+		parent.Finalize(node)
+	}()
+	o := parent.ObjectNumber(f)
+	f.Close()
+
+	f,_,_ = pdf.OpenFile(filename, os.O_RDONLY)
+	defer f.Close()
+	object,_ := f.Object(o)
+	if node,ok := object.(pdf.Dictionary); !ok || node.GetArray("Kids") == nil {
+		t.Errorf(`Finalized object read as %v`, object)
+	}
+	if !f.Indirect(o).Finalized() {
+		t.Errorf(`Indirect read from a file should be finalized`)
+	}
+}
+
+// checkProtection() requires that protected, the protected version
+// of an object, doesn't implement its unprotected interface, and that
+// Unprotect() and Clone() return copies that do.
+func checkProtection(t *testing.T, descr string, protected pdf.Object, isUnprotected func(pdf.Object) bool) {
+	if isUnprotected(protected) {
+		t.Errorf(`%s: Protect() returned a modifiable object`, descr)
+	}
+	if protected.Protect() != protected {
+		t.Errorf(`%s: Protect() of a protected object should return it`, descr)
+	}
+	if !isUnprotected(protected.Unprotect()) {
+		t.Errorf(`%s: Unprotect() did not return a modifiable object`, descr)
+	}
+	if !isUnprotected(protected.Clone()) {
+		t.Errorf(`%s: Clone() did not return a modifiable object`, descr)
+	}
+}
+
+func TestProtection(t *testing.T) {
+	inner := pdf.NewArray()
+	inner.Add(pdf.NewIntNumeric(1))
+	array := pdf.NewArray()
+	array.Add(inner)
+	dictionary := pdf.NewDictionary()
+	dictionary.Add("Inner", inner)
+	stream := pdf.NewStream()
+	stream.Add("Type", pdf.NewName("Test"))
+	stream.Write([]byte("contents"))
+	s := pdf.NewTextString("text")
+
+	checkProtection(t, "Array", array.Protect(), func(o pdf.Object) bool { _,ok := o.(pdf.Array); return ok })
+	checkProtection(t, "Dictionary", dictionary.Protect(), func(o pdf.Object) bool { _,ok := o.(pdf.Dictionary); return ok })
+	checkProtection(t, "Stream", stream.Protect(), func(o pdf.Object) bool { _,ok := o.(pdf.Stream); return ok })
+	checkProtection(t, "String", s.Protect(), func(o pdf.Object) bool { _,ok := o.(pdf.String); return ok })
+	checkProtection(t, "Indirect", pdf.NewIndirect().Protect(), func(o pdf.Object) bool { _,ok := o.(pdf.Indirect); return ok })
+
+	// Unprotect() is a no-op on unprotected objects.
+	if array.Unprotect() != pdf.Object(array) || dictionary.Unprotect() != pdf.Object(dictionary) {
+		t.Errorf(`Unprotect() of an unprotected object should return it`)
+	}
+
+	// Protection is deep: contained objects are protected.
+	protectedArray := array.Protect().(pdf.ProtectedArray)
+	if _,ok := protectedArray.At(0).(pdf.Array); ok {
+		t.Errorf(`Element of protected array is modifiable`)
+	}
+	protectedDictionary := dictionary.Protect().(pdf.ProtectedDictionary)
+	if _,ok := protectedDictionary.Get("Inner").(pdf.Array); ok {
+		t.Errorf(`Entry of protected dictionary is modifiable`)
+	}
+	if _,ok := stream.Protect().(pdf.ProtectedStream).Dictionary().Get("Type").(pdf.Name); !ok {
+		t.Errorf(`Stream dictionary entry lost`)
+	}
+
+	// Unprotect() is copy-on-write and shallow: changing the copy
+	// doesn't change the original, and contained objects remain
+	// protected.
+	arrayCopy := protectedArray.Unprotect().(pdf.Array)
+	arrayCopy.Add(pdf.NewIntNumeric(2))
+	if array.Size() != 1 || protectedArray.Size() != 1 {
+		t.Errorf(`Changing unprotected copy changed the original array`)
+	}
+	if _,ok := arrayCopy.At(0).(pdf.Array); ok {
+		t.Errorf(`Unprotect() of an array unprotected its elements`)
+	}
+	dictionaryCopy := protectedDictionary.Unprotect().(pdf.Dictionary)
+	dictionaryCopy.Add("Other", pdf.NewNull())
+	dictionaryCopy.Remove("Inner")
+	if dictionary.Get("Inner") == nil || dictionary.Get("Other") != nil {
+		t.Errorf(`Changing unprotected copy changed the original dictionary`)
+	}
+	streamCopy := stream.Protect().Unprotect().(pdf.Stream)
+	streamCopy.Add("Type", pdf.NewName("Copy"))
+	if name,_ := stream.Dictionary().GetName("Type"); name != "Test" {
+		t.Errorf(`Changing unprotected copy changed the original stream`)
+	}
+	stringCopy := s.Protect().Unprotect().(pdf.String)
+	stringCopy.SetSerializer(pdf.HexStringSerializer)
+	checkObjectBasic(t, "Original string", s, nil, "(text)")
+
+	// Clone() is deep.
+	clone := protectedArray.Clone().(pdf.Array)
+	if _,ok := clone.At(0).(pdf.Array); !ok {
+		t.Errorf(`Clone() of a protected array did not copy its elements`)
+	}
+	clone.At(0).(pdf.Array).Add(pdf.NewIntNumeric(3))
+	if inner.Size() != 1 {
+		t.Errorf(`Changing clone changed the original element`)
+	}
+
+	// Bytes() of a protected string returns a copy.
+	bytes := s.Protect().(pdf.ProtectString).Bytes()
+	bytes[0] = 'T'
+	checkObjectBasic(t, "Original string", s, nil, "(text)")
+}
+
+func TestTrees(t *testing.T) {
+	f := pdf.NewMemoryFile()
+	numbers := pdf.NewNumberTree()
+	for i:=0; i<5000; i++ {
+		numbers.Add(3*i, pdf.NewIntNumeric(i))
+	}
+	names := pdf.NewNameTree()
+	for i:=0; i<100; i++ {
+		names.Add(fmt.Sprintf("name%03d", i), pdf.NewIntNumeric(i))
+	}
+	small := pdf.NewNameTree()
+	small.Add("b", pdf.NewIntNumeric(2))
+	small.Add("a", pdf.NewIntNumeric(1))
+	checkObject(t, "Small name tree", small.Build(f), f, "<</Names [(a) 1 (b) 2]>>")
+
+	numbersReference := f.WriteObject(numbers.Build(f))
+	namesReference := f.WriteObject(names.Build(f))
+	numbersNumber := numbersReference.ObjectNumber(f)
+	namesNumber := namesReference.ObjectNumber(f)
+	f.Close()
+
+	r,err := pdf.NewMemoryFileFromBytes(f.Bytes())
+	if err != nil {
+		t.Fatalf("NewMemoryFileFromBytes() failed: %v", err)
+	}
+	defer r.Close()
+
+	o,_ := r.Object(numbersNumber)
+	root := o.(pdf.ProtectedDictionary)
+	if kids := root.GetArray("Kids"); kids == nil || kids.Size() != 2 || root.GetArray("Nums") != nil {
+		t.Errorf("Root of a large number tree is %v", root)
+	}
+	kid := root.GetArray("Kids").At(1).Dereference().(pdf.ProtectedDictionary)
+	if limits,_ := kid.GetArray("Limits").Ints(); len(limits) != 2 || limits[0] != 3*64*64 || limits[1] != 3*4999 {
+		t.Errorf("Limits of the second kid are %v", limits)
+	}
+
+	read := pdf.ReadNumberTree(root)
+	if read.Size() != 5000 {
+		t.Errorf("Number tree read with %d entries; expected 5000", read.Size())
+	}
+	for _,i := range []int{0, 63, 64, 4095, 4096, 4999} {
+		if v,ok := read.Get(3*i).(*pdf.IntNumeric); !ok || v.Value() != i {
+			t.Errorf("Number tree entry %d is %v", 3*i, read.Get(3*i))
+		}
+	}
+	if read.Get(1) != nil {
+		t.Error("Number tree has an entry that wasn't added")
+	}
+
+	o,_ = r.Object(namesNumber)
+	keys := pdf.ReadNameTree(o.(pdf.ProtectedDictionary)).Keys()
+	if len(keys) != 100 || keys[0] != "name000" || keys[99] != "name099" {
+		t.Errorf("Name tree read with keys %v", keys)
+	}
+}
+
+func TestEncodingDictionary(t *testing.T) {
+	// 'A' and 'B' are unchanged from WinAnsiEncoding; 0x27 is the
+	// straight quote in WinAnsiEncoding but not in
+	// StandardEncoding.
+	glyphs := map[byte]string{'A': "A", 'B': "B", 'C': "Euro", 'D': "f_f", 0x27: "quotesingle", 200: "uni2713"}
+	encoding,err := pdf.NewEncodingDictionary("WinAnsiEncoding", glyphs)
+	if err != nil {
+		t.Fatalf(`NewEncodingDictionary() failed: %v`, err)
+	}
+	expected := "<</Type /Encoding /BaseEncoding /WinAnsiEncoding /Differences [67 /Euro /f_f 200 /uni2713]>>"
+	if s := toString(encoding); s != expected {
+		t.Errorf(`NewEncodingDictionary() returned %s; expected %s`, s, expected)
+	}
+
+	encoding,_ = pdf.NewEncodingDictionary("StandardEncoding", glyphs)
+	expected = "<</Type /Encoding /BaseEncoding /StandardEncoding /Differences [39 /quotesingle 67 /Euro /f_f 200 /uni2713]>>"
+	if s := toString(encoding); s != expected {
+		t.Errorf(`NewEncodingDictionary() returned %s; expected %s`, s, expected)
+	}
+
+	if _,err := pdf.NewEncodingDictionary("SymbolEncoding", glyphs); err == nil {
+		t.Error(`NewEncodingDictionary() accepted an unknown base encoding`)
+	}
+}
+
+func TestFontMetrics(t *testing.T) {
+	afm := "StartFontMetrics 4.1\nComment A test font\nFontName Test-Bold\nFamilyName Test\n" +
+		"Weight Bold\nItalicAngle -12.5\nIsFixedPitch false\nFontBBox -10 -200 1000 900\n" +
+		"EncodingScheme AdobeStandardEncoding\nCapHeight 700\nXHeight 500\nAscender 720\n" +
+		"Descender -210\nStdVW 120\nStartCharMetrics 3\n" +
+		"C 65 ; WX 700 ; N A ; B 0 0 700 700 ;\nC 86 ; WX 650 ; N V ; B 0 0 650 700 ;\n" +
+		"C -1 ; WX 500 ; N Aacute ;\nEndCharMetrics\nStartKernData\nStartKernPairs 1\n" +
+		"KPX A V -80\nEndKernPairs\nEndKernData\nEndFontMetrics\n"
+	metrics,err := pdf.ParseAFM(strings.NewReader(afm))
+	if err != nil {
+		t.Fatalf(`ParseAFM() failed: %v`, err)
+	}
+	if metrics.FontName != "Test-Bold" || metrics.ItalicAngle != -12.5 || metrics.FontBBox != [4]float64{-10, -200, 1000, 900} {
+		t.Errorf(`ParseAFM() returned %+v`, metrics)
+	}
+	if width,_ := metrics.Width("Aacute"); width != 500 || metrics.Codes["V"] != 86 || len(metrics.Codes) != 2 {
+		t.Errorf(`Aacute has width %v; codes are %v`, width, metrics.Codes)
+	}
+	if kern := metrics.Kern("A", "V"); kern != -80 {
+		t.Errorf(`Kern("A", "V") returned %v`, kern)
+	}
+	if width := metrics.StringWidth("AVÁ", 10); math.Abs(width-18.5) > 1e-9 {
+		t.Errorf(`StringWidth() returned %v`, width)
+	}
+	expected := "<</Type /FontDescriptor /FontName /Test-Bold /FontFamily (Test) /Flags 96 " +
+		"/FontBBox [-10 -200 1000 900] /ItalicAngle -12.5 /Ascent 720 /Descent -210 " +
+		"/CapHeight 700 /XHeight 500 /StemV 120>>"
+	if s := toString(metrics.FontDescriptor()); s != expected {
+		t.Errorf(`FontDescriptor() returned %s; expected %s`, s, expected)
+	}
+
+	if _,err := pdf.ParseAFM(strings.NewReader("%!PS-AdobeFont-1.0")); err == nil {
+		t.Error(`ParseAFM() accepted a font program`)
+	}
+
+	helvetica := pdf.StandardFontMetrics(pdf.Helvetica)
+	if width := helvetica.StringWidth("Hello", 12); math.Abs(width-27.336) > 1e-9 {
+		t.Errorf(`Helvetica "Hello" has width %v`, width)
+	}
+	if width,_ := pdf.StandardFontMetrics(pdf.CourierBoldOblique).Width("W"); width != 600 {
+		t.Errorf(`Courier W has width %v`, width)
+	}
+	if code := pdf.StandardFontMetrics(pdf.Symbol).Codes["alpha"]; code != 'a' {
+		t.Errorf(`Symbol alpha has code %d`, code)
+	}
+}
+
+func TestShowText(t *testing.T) {
+	metrics,err := pdf.ParseAFM(strings.NewReader("StartFontMetrics 4.1\nFontName Test\n" +
+		"StartCharMetrics 3\nC 65 ; WX 700 ; N A ;\nC 86 ; WX 650 ; N V ;\nC -1 ; WX 500 ; N Aacute ;\n" +
+		"EndCharMetrics\nStartKernPairs 1\nKPX A V -80\nEndKernPairs\nEndFontMetrics\n"))
+	if err != nil {
+		t.Fatalf(`ParseAFM() failed: %v`, err)
+	}
+
+	show := func(text string, kerning bool) (string, float64, error) {
+		op,width,err := metrics.ShowText(text, 10, kerning)
+		if err != nil {
+			return "", 0, err
+		}
+		var b bytes.Buffer
+		pdf.NewContentSerializer(&b).Write(op)
+		return b.String(), width, nil
+	}
+	if s,width,_ := show("AVA", true); s != "[(A) 80 (VA)] TJ\n" || math.Abs(width-19.7) > 1e-9 {
+		t.Errorf(`Kerned text is %q with width %v`, s, width)
+	}
+	if s,width,_ := show("AVA", false); s != "(AVA) Tj\n" || math.Abs(width-20.5) > 1e-9 {
+		t.Errorf(`Unkerned text is %q with width %v`, s, width)
+	}
+	if s,_,_ := show("VA", true); s != "(VA) Tj\n" {
+		t.Errorf(`Text without kerned pairs is %q`, s)
+	}
+	if _,_,err := show("Á", true); err == nil {
+		t.Error(`ShowText() accepted an unencoded glyph`)
+	}
+}
+
+func TestObjectNumber(t *testing.T) {
+	o := pdf.NewObjectNumber(12, 3)
+	if o.Number() != 12 || o.Generation() != 3 {
+		t.Errorf(`NewObjectNumber(12, 3) has number %d and generation %d`, o.Number(), o.Generation())
+	}
+	if s := o.String(); s != "12 3 R" {
+		t.Errorf(`String() returned %q; expected "12 3 R"`, s)
+	}
+	if p,err := pdf.ParseObjectNumber(" 12  3 R\n"); err != nil || p != o {
+		t.Errorf(`ParseObjectNumber() returned %v, %v; expected %v`, p, err, o)
+	}
+	for _,s := range []string{"", "12 3", "12 3 obj", "-1 0 R", "1 65536 R", "4294967296 0 R", "a 0 R"} {
+		if _,err := pdf.ParseObjectNumber(s); err == nil {
+			t.Errorf(`ParseObjectNumber(%q) succeeded`, s)
+		}
+	}
+	ordered := []pdf.ObjectNumber{pdf.NewObjectNumber(1, 5), pdf.NewObjectNumber(2, 0), pdf.NewObjectNumber(2, 1), pdf.NewObjectNumber(10, 0)}
+	for i := range ordered {
+		for j := range ordered {
+			expected := 0
+			if i < j {
+				expected = -1
+			} else if i > j {
+				expected = 1
+			}
+			if c := ordered[i].Compare(ordered[j]); c != expected {
+				t.Errorf(`%v.Compare(%v) returned %d; expected %d`, ordered[i], ordered[j], c, expected)
+			}
+			if ordered[i].Less(ordered[j]) != (i < j) {
+				t.Errorf(`%v.Less(%v) returned %v`, ordered[i], ordered[j], !(i < j))
+			}
+		}
+	}
+}
+
+func TestObjectJSON(t *testing.T) {
+	file := pdf.NewMockFile(5, 0)
+	d := pdf.NewDictionary()
+	d.Add("Type", pdf.NewName("Example"))
+	d.Add("Flags", pdf.NewArray())
+	d.GetArray("Flags").(pdf.Array).Add(pdf.NewBoolean(true))
+	d.GetArray("Flags").(pdf.Array).Add(pdf.NewNull())
+	d.Add("Integer", pdf.NewIntNumeric(-12))
+	d.Add("Real", pdf.NewRealNumeric(2))
+	d.Add("Text", pdf.NewBinaryString([]byte("Some (text)")))
+	d.Add("Binary", pdf.NewBinaryString([]byte{0, 0xff}))
+	d.Add("Reference", file.Indirect(pdf.NewObjectNumber(3, 1)))
+	s := pdf.NewStream()
+	s.Add("Resources", d)
+	s.Write([]byte("0 0 m 10 10 l S"))
+
+	for _,o := range []pdf.Object{d, s} {
+		data,err := pdf.ObjectToJSON(o, file)
+		if err != nil {
+			t.Fatalf(`ObjectToJSON() failed: %v`, err)
+		}
+		result,err := pdf.ObjectFromJSON(data, file)
+		if err != nil {
+			t.Fatalf(`ObjectFromJSON(%s) failed: %v`, data, err)
+		}
+		if again,_ := pdf.ObjectToJSON(result, file); string(again) != string(data) {
+			t.Errorf(`ObjectFromJSON(%s) returned %s`, data, toString(result, file))
+		}
+	}
+	if data,_ := pdf.ObjectToJSON(d, file); !strings.Contains(string(data), `"/Real":2.0`) ||
+		!strings.Contains(string(data), `"/Reference":"3 1 R"`) || !strings.Contains(string(data), `"/Binary":"b:00ff"`) {
+		t.Errorf(`ObjectToJSON() returned %s`, data)
+	}
+	if _,err := pdf.ObjectToJSON(d); err == nil {
+		t.Errorf(`ObjectToJSON() of a reference without a File succeeded`)
+	}
+	for _,data := range []string{`"text"`, `{"Key": 1}`, `"b:0"`, `{"stream": {"dict": {}}}`, `[1,`} {
+		if _,err := pdf.ObjectFromJSON([]byte(data), file); err == nil {
+			t.Errorf(`ObjectFromJSON(%s) succeeded`, data)
+		}
+	}
+}
+
+type marshalBorder struct {
+	Width float64 `pdf:"W"`
+	Style string `pdf:"S,name"`
+}
+
+type marshalAnnotation struct {
+	Type string `pdf:",name"`
+	Subtype string `pdf:",name"`
+	Rect [4]float64
+	Contents string `pdf:",omitempty"`
+	Flags uint `pdf:"F"`
+	Border *marshalBorder `pdf:"BS"`
+	Colors []float64 `pdf:"C"`
+	Page pdf.Indirect `pdf:"P"`
+	Filters []string `pdf:"Filter,name"`
+	Extra map[string]int
+	Ignored int `pdf:"-"`
+	hidden int
+}
+
+func TestMarshal(t *testing.T) {
+	file := pdf.NewMockFile(5, 0)
+	page := file.Indirect(pdf.NewObjectNumber(2, 0))
+	annotation := marshalAnnotation{
+		Type: "Annot",
+		Subtype: "Square",
+		Rect: [4]float64{0, 0, 100, 50.5},
+		Flags: 4,
+		Border: &marshalBorder{2, "D"},
+		Page: page,
+		Filters: []string{"FlateDecode"},
+		Extra: map[string]int{"B": 2, "A": 1},
+		Ignored: 1,
+		hidden: 1 }
+	d,err := pdf.Marshal(&annotation)
+	if err != nil {
+		t.Fatalf(`Marshal() failed: %v`, err)
+	}
+	checkObject(t, "Marshal()", d, file,
+		"<</Type /Annot /Subtype /Square /Rect [0 0 100 50.5] /F 4 /BS <</W 2 /S /D>> /P 2 0 R /Filter [/FlateDecode] /Extra <</A 1 /B 2>>>>")
+
+	var result marshalAnnotation
+	if err := pdf.Unmarshal(d, &result); err != nil {
+		t.Fatalf(`Unmarshal() failed: %v`, err)
+	}
+	annotation.Ignored,annotation.hidden = 0,0
+	if !reflect.DeepEqual(result, annotation) {
+		t.Errorf(`Unmarshal() returned %+v; expected %+v`, result, annotation)
+	}
+
+	// A single name is accepted where an array is expected, and
+	// text strings are decoded.
+	d.Add("Filter", pdf.NewName("DCTDecode"))
+	d.Add("Contents", pdf.NewTextString("Café"))
+	if err := pdf.Unmarshal(d, &result); err != nil || len(result.Filters) != 1 || result.Filters[0] != "DCTDecode" || result.Contents != "Café" {
+		t.Errorf(`Unmarshal() returned %+v, %v`, result, err)
+	}
+
+	d.GetDictionary("BS").(pdf.Dictionary).Add("W", pdf.NewName("Wide"))
+	err = pdf.Unmarshal(d, &result)
+	if e,ok := err.(*pdf.MarshalError); !ok || e.Key != "BS/W" || !errors.Is(err, &pdf.MarshalError{}) {
+		t.Errorf(`Unmarshal() of a name into a number returned %v`, err)
+	}
+	if err := pdf.Unmarshal(d, result); err == nil {
+		t.Errorf(`Unmarshal() into a struct rather than a pointer succeeded`)
+	}
+	if _,err := pdf.Marshal(struct{ F func() }{func() {}}); err == nil {
+		t.Errorf(`Marshal() of a function succeeded`)
+	}
+}
@@ -0,0 +1,72 @@
+package pdf
+
+import "fmt"
+
+// openPage records the page and view requested by OpenAtPage(),
+// which are resolved when the document is closed so that the page
+// need not exist yet.
+type openPage struct {
+	n uint
+	fit DestinationFit
+}
+
+// SetOpenAction() sets the action performed when the document is
+// opened, replacing any set by OpenAtPage().  A GoToAction is
+// written as its destination alone, which every viewer understands.
+func (d *Document) SetOpenAction(action Action) error {
+	if goTo,ok := action.(GoToAction); ok {
+		return d.SetOpenDestination(goTo.Destination)
+	}
+	dictionary,err := NewActionDictionary(action)
+	if err != nil {
+		return err
+	}
+	d.openPage = nil
+	d.catalog.Add("OpenAction", dictionary)
+	return nil
+}
+
+// SetOpenDestination() sets the view displayed when the document is
+// opened, replacing any set by OpenAtPage().
+func (d *Document) SetOpenDestination(dest Destination) error {
+	destination,err := dest.object(false)
+	if err != nil {
+		return err
+	}
+	d.openPage = nil
+	d.catalog.Add("OpenAction", destination)
+	return nil
+}
+
+// OpenAtPage() opens the document at page n (numbered from 0),
+// displayed according to fit.  For example, OpenAtPage(0, FitWidth)
+// opens a document at the top of its first page, zoomed to fit the
+// page's width.  The coordinates that fit uses are taken from the
+// page's crop box, and the zoom of ExplicitZoom is left unchanged.
+// The page need not exist until the document is closed.
+func (d *Document) OpenAtPage(n uint, fit DestinationFit) {
+	d.openPage = &openPage{n, fit}
+}
+
+func (d *Document) finishOpenAction() {
+	if d.openPage == nil {
+		return
+	}
+	n := d.openPage.n
+	if n >= d.pageCount {
+		fmt.Fprintf(logger, "Warning: Open action refers to page %d of a %d page document\n", n, d.pageCount)
+		return
+	}
+	page := pageFromTree(d.pageTreeRoot, n)
+	box,ok := page.CropBox()
+	if !ok {
+		box = [4]float64{0, 0, 612, 792}
+	}
+	err := d.SetOpenDestination(Destination{
+		Page: page.reference,
+		Fit: d.openPage.fit,
+		Left: box[0], Bottom: box[1], Right: box[2], Top: box[3] })
+	if err != nil {
+		fmt.Fprintf(logger, "Warning: Unable to set open action: %v\n", err)
+	}
+}
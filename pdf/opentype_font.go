@@ -0,0 +1,314 @@
+package pdf
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/fnv"
+	"sort" )
+
+// OpenTypeFont is an OpenType font with CFF outlines (a .otf file),
+// embedded as a CIDFontType0 font whose font program is a FontFile3
+// stream with subtype /OpenType.  Text is shown with two-byte glyph
+// codes using the Identity-H encoding, so any glyph in the font can be
+// used.  Unless the font's license forbids it, only the glyphs
+// actually shown are embedded.  Embedding an OpenType font program
+// requires PDF 1.6.
+type OpenTypeFont struct {
+	fileBindings map[File]Indirect
+	font *sfnt
+	metrics *sfntMetrics
+	cff *cffFont
+	subset bool
+	// used maps the glyphs that have been shown to the text they
+	// represent.
+	used map[uint16]string
+}
+
+// Embedding permission bits in the OS/2 fsType field.
+const (
+	restrictedLicenseEmbedding = 0x0002
+	noSubsetting = 0x0100
+	bitmapEmbeddingOnly = 0x0200 )
+
+var (
+	noCFFOutlines = errors.New(`Font has no CFF outlines`)
+	embeddingRestricted = errors.New(`Font license does not permit embedding`) )
+
+// LoadOpenTypeFont() constructs an OpenTypeFont from the contents of
+// an .otf file or a WOFF file with CFF outlines.  WOFF2 files aren't
+// supported.  An error is returned if the font doesn't have CFF
+// outlines or its license doesn't permit embedding.
+func LoadOpenTypeFont(data []byte) (*OpenTypeFont, error) {
+	font,err := parseSfnt(data)
+	if err != nil {
+		return nil, err
+	}
+	if _,exists := font.tables["CFF "]; !exists {
+		return nil, noCFFOutlines
+	}
+	metrics,err := font.readMetrics()
+	if err != nil {
+		return nil, err
+	}
+	if metrics.fsType & (restrictedLicenseEmbedding | bitmapEmbeddingOnly) != 0 {
+		return nil, embeddingRestricted
+	}
+	cff,err := parseCFF(font.tables["CFF "])
+	if err != nil {
+		return nil, err
+	}
+	if len(cff.charStrings) != len(metrics.advances) {
+		return nil, invalidCFF
+	}
+	return &OpenTypeFont{
+		fileBindings: make(map[File]Indirect, 5),
+		font: font,
+		metrics: metrics,
+		cff: cff,
+		subset: metrics.fsType & noSubsetting == 0,
+		used: make(map[uint16]string) }, nil
+}
+
+// PostScriptName() returns the PostScript name of the font.
+func (font *OpenTypeFont) PostScriptName() string {
+	return font.metrics.postScriptName
+}
+
+// Encode() returns the codes that show text in the font.  Characters
+// the font has no glyph for are shown with the .notdef glyph.
+func (font *OpenTypeFont) Encode(text string) []byte {
+	codes := make([]byte, 0, 2*len(text))
+	for _,r := range text {
+		gid := font.metrics.cmap[r]
+		if _,exists := font.used[gid]; !exists && gid != 0 {
+			font.used[gid] = string(r)
+		}
+		var code [2]byte
+		binary.BigEndian.PutUint16(code[:], font.cff.cid(gid))
+		codes = append(codes, code[:]...)
+	}
+	return codes
+}
+
+// StringWidth() returns the width in text space of text set in the
+// font at the given size.
+func (font *OpenTypeFont) StringWidth(text string, size float64) float64 {
+	total := 0.0
+	for _,r := range text {
+		total += float64(font.metrics.advances[font.metrics.cmap[r]])
+	}
+	return total * size / font.metrics.unitsPerEm
+}
+
+// Ascent() returns the height of the font's ascenders above the
+// baseline in text space for the given size.
+func (font *OpenTypeFont) Ascent(size float64) float64 {
+	return font.metrics.ascent * size / font.metrics.unitsPerEm
+}
+
+// Descent() returns the depth of the font's descenders, which is
+// negative, in text space for the given size.
+func (font *OpenTypeFont) Descent(size float64) float64 {
+	return font.metrics.descent * size / font.metrics.unitsPerEm
+}
+
+// ShowText() returns a Tj operation that shows text in the font at the
+// given size, along with the width of the text in text space.
+func (font *OpenTypeFont) ShowText(text string, size float64) (Operation, float64) {
+	return Operation{"Tj", []Object{NewBinaryString(font.Encode(text))}}, font.StringWidth(text, size)
+}
+
+// Indirect() returns a reference to the font dictionary, which isn't
+// written until Finish() is called, since the embedded subset depends
+// on all of the text shown in the font.
+func (font *OpenTypeFont) Indirect(file File) Indirect {
+	file = bindingKey(file)
+	i,exists := font.fileBindings[file]
+	if !exists {
+		i = NewIndirect(file)
+		font.fileBindings[file] = i
+	}
+	return i
+}
+
+func (font *OpenTypeFont) Embedded() bool {
+	return true
+}
+
+// Finish() writes the font dictionary and the embedded font program to
+// each file the font has been used with.  Document.Close() calls it
+// for fonts used on the document's pages; other clients must call it
+// after all text has been encoded and before closing the file.  Text
+// encoded afterward may show glyphs that aren't embedded.
+func (font *OpenTypeFont) Finish() {
+	for file,i := range font.fileBindings {
+		if i.Finalized() {
+			continue
+		}
+		if r,ok := file.(versionRequirer); ok {
+			r.requireVersion(PDF16)
+		}
+		i.Finalize(font.dictionary(file))
+	}
+}
+
+// glyphs() returns the glyphs that have been shown, in order.
+func (font *OpenTypeFont) glyphs() []int {
+	gids := make([]int, 0, len(font.used))
+	for gid := range font.used {
+		gids = append(gids, int(gid))
+	}
+	sort.Ints(gids)
+	return gids
+}
+
+// baseFont() returns the PostScript name, prefixed with a tag derived
+// from the glyphs used if the font is subset.
+func (font *OpenTypeFont) baseFont() string {
+	name := font.metrics.postScriptName
+	if name == "" {
+		name = "OpenTypeFont"
+	}
+	if !font.subset {
+		return name
+	}
+	return subsetTag(font.glyphs()) + "+" + name
+}
+
+// subsetTag() returns the six uppercase letters that prefix the name
+// of a font subset containing the listed glyphs.
+func subsetTag(gids []int) string {
+	h := fnv.New32a()
+	for _,gid := range gids {
+		binary.Write(h, binary.BigEndian, uint16(gid))
+	}
+	sum := h.Sum32()
+	tag := make([]byte, 6)
+	for i := range tag {
+		tag[i] = byte('A' + sum%26)
+		sum /= 26
+	}
+	return string(tag)
+}
+
+// hasSubsetTag() returns true if name begins with a subset tag.
+func hasSubsetTag(name string) bool {
+	if len(name) < 7 || name[6] != '+' {
+		return false
+	}
+	for _,c := range name[:6] {
+		if c < 'A' || c > 'Z' {
+			return false
+		}
+	}
+	return true
+}
+
+// fontProgram() returns the OpenType font program to embed.
+func (font *OpenTypeFont) fontProgram() []byte {
+	cff := font.font.tables["CFF "]
+	if font.subset {
+		used := make(map[uint16]bool, len(font.used))
+		for gid := range font.used {
+			used[gid] = true
+		}
+		if subset,err := font.cff.subset(used); err == nil {
+			cff = subset
+		}
+	}
+
+	program := &sfnt{font.font.version, make(map[string][]byte)}
+	var tags []string
+	for _,tag := range []string{"CFF ", "OS/2", "cmap", "head", "hhea", "hmtx", "maxp", "name", "post"} {
+		if table,exists := font.font.tables[tag]; exists {
+			program.tables[tag] = table
+			tags = append(tags, tag)
+		}
+	}
+	program.tables["CFF "] = cff
+	return program.bytes(tags)
+}
+
+// dictionary() writes the descendant font, font descriptor, font
+// program, and ToUnicode CMap to file and returns the Type0 font
+// dictionary.
+func (font *OpenTypeFont) dictionary(file File) Dictionary {
+	m := font.metrics
+	scale := 1000 / m.unitsPerEm
+	baseFont := font.baseFont()
+
+	fontFile := defaultStreamFactory.New()
+	fontFile.Add("Subtype", NewName("OpenType"))
+	fontFile.Write(font.fontProgram())
+
+	const (
+		fixedPitchFlag = 1 << 0
+		symbolicFlag = 1 << 2
+		italicFlag = 1 << 6 )
+	flags := symbolicFlag
+	if m.fixedPitch {
+		flags |= fixedPitchFlag
+	}
+	if m.italicAngle != 0 {
+		flags |= italicFlag
+	}
+	bbox := make([]float64, 4)
+	for i,v := range m.bbox {
+		bbox[i] = v * scale
+	}
+	descriptor := NewDictionary()
+	descriptor.Add("Type", NewName("FontDescriptor"))
+	descriptor.Add("FontName", NewName(baseFont))
+	descriptor.Add("Flags", NewIntNumeric(flags))
+	descriptor.Add("FontBBox", NewFloatArray(bbox))
+	descriptor.Add("ItalicAngle", NewNumeric(m.italicAngle))
+	descriptor.Add("Ascent", NewNumeric(m.ascent * scale))
+	descriptor.Add("Descent", NewNumeric(m.descent * scale))
+	descriptor.Add("CapHeight", NewNumeric(m.capHeight * scale))
+	// The font doesn't record its stem width, so it is estimated
+	// from the weight class.
+	descriptor.Add("StemV", NewIntNumeric(10 + m.weightClass*m.weightClass/4400))
+	descriptor.Add("FontFile3", file.WriteObject(fontFile))
+
+	systemInfo := NewDictionary()
+	systemInfo.Add("Registry", NewTextString("Adobe"))
+	systemInfo.Add("Ordering", NewTextString("Identity"))
+	systemInfo.Add("Supplement", NewIntNumeric(0))
+
+	// Widths are listed for runs of consecutive CIDs.
+	widths := NewArray()
+	toUnicode := make(map[uint32]string, len(font.used))
+	var run Array
+	previous := -2
+	for _,gid := range font.glyphs() {
+		cid := int(font.cff.cid(uint16(gid)))
+		if cid != previous+1 {
+			run = NewArray()
+			widths.Add(NewIntNumeric(cid))
+			widths.Add(run)
+		}
+		run.Add(NewNumeric(float64(m.advances[gid]) * scale))
+		previous = cid
+		toUnicode[uint32(cid)] = font.used[uint16(gid)]
+	}
+
+	descendant := NewDictionary()
+	descendant.Add("Type", NewName("Font"))
+	descendant.Add("Subtype", NewName("CIDFontType0"))
+	descendant.Add("BaseFont", NewName(baseFont))
+	descendant.Add("CIDSystemInfo", systemInfo)
+	descendant.Add("FontDescriptor", file.WriteObject(descriptor))
+	descendant.Add("DW", NewNumeric(float64(m.advances[0]) * scale))
+	descendant.Add("W", widths)
+
+	dictionary := NewDictionary()
+	dictionary.Add("Type", NewName("Font"))
+	dictionary.Add("Subtype", NewName("Type0"))
+	dictionary.Add("BaseFont", NewName(baseFont))
+	dictionary.Add("Encoding", NewName("Identity-H"))
+	descendants := NewArray()
+	descendants.Add(file.WriteObject(descendant))
+	dictionary.Add("DescendantFonts", descendants)
+	dictionary.Add("ToUnicode", file.WriteObject(toUnicodeStream(toUnicode, 2)))
+	return dictionary
+}
@@ -0,0 +1,248 @@
+package pdf
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"math" )
+
+var cannotOptimizeEncrypted = errors.New(`Encrypted files cannot be optimized`)
+
+const (
+	defaultTargetDPI = 150
+	defaultDownsampleThreshold = 1.5
+	defaultJPEGQuality = 75 )
+
+// ImageOptimization holds the settings of OptimizeImages().  Zero
+// values select the defaults.
+type ImageOptimization struct {
+	// TargetDPI is the resolution, in pixels per inch of the
+	// largest placement on a page, to which images are reduced.
+	// The default is 150.
+	TargetDPI float64
+	// Threshold is the factor by which an image's resolution must
+	// exceed TargetDPI for it to be reduced, so that images only
+	// slightly above the target aren't recompressed.  The default
+	// is 1.5.
+	Threshold float64
+	// Quality is the JPEG quality, from 1 to 100, of reduced
+	// images.  The default is 75.
+	Quality int
+}
+
+func (s ImageOptimization) withDefaults() ImageOptimization {
+	if s.TargetDPI <= 0 {
+		s.TargetDPI = defaultTargetDPI
+	}
+	if s.Threshold < 1 {
+		s.Threshold = defaultDownsampleThreshold
+	}
+	if s.Quality <= 0 || s.Quality > 100 {
+		s.Quality = defaultJPEGQuality
+	}
+	return s
+}
+
+// OptimizeImages() reads the PDF file named input and writes to output
+// the objects reachable from its trailer, as Uncompress() and
+// Compact() do, with oversampled images reduced.  The resolution of an
+// image is the number of its pixels per inch where it is drawn
+// largest by the contents of the pages, including the forms they
+// draw.  Images whose resolution exceeds settings.TargetDPI by
+// settings.Threshold are downsampled to TargetDPI by averaging and
+// recompressed as JPEG, and references to them are rewritten to refer
+// to the reduced images.  An image is kept as it is if the reduced
+// image wouldn't be smaller, or if it is an image mask, has a soft
+// mask or a color key mask, uses CMYK or a color space that Image()
+// can't decode, or is drawn only by annotations or patterns.  Input
+// and output may name the same file.  Options are passed to
+// OpenFile() when reading input.
+func OptimizeImages(input, output string, settings ImageOptimization, options ...FileOption) error {
+	settings = settings.withDefaults()
+	return rewriteFile(input, output, options, nil, func(destination *file, source File) error {
+		return optimizeImages(destination, source, settings)
+	})
+}
+
+// placedImage is an image XObject with the lowest resolution at which
+// it is drawn.
+type placedImage struct {
+	image PageImage
+	dpi float64
+}
+
+func optimizeImages(destination *file, source File, settings ImageOptimization) error {
+	if source.Trailer().Get("Encrypt") != nil {
+		destination.abandon()
+		return cannotOptimizeEncrypted
+	}
+	if source.Catalog() == nil || source.Catalog().GetDictionary("Pages") == nil {
+		return copyReachable(destination, source, false, nil)
+	}
+
+	document := newDocument(source, true)
+	placed := make(map[ObjectNumber]*placedImage)
+	for n:=uint(0); n<document.PageCount(); n++ {
+		if err := destination.canceled(); err != nil {
+			destination.abandon()
+			return err
+		}
+		page := document.Page(n)
+		if page == nil {
+			break
+		}
+		for _,pi := range page.Images() {
+			if pi.Inline || !pi.keyed {
+				continue
+			}
+			dpi := placementDPI(pi)
+			if p,exists := placed[pi.key.number]; !exists || dpi < p.dpi {
+				placed[pi.key.number] = &placedImage{pi, dpi}
+			}
+		}
+	}
+
+	replacements := make(map[ObjectNumber]Object)
+	for o,p := range placed {
+		if p.dpi <= settings.TargetDPI*settings.Threshold {
+			continue
+		}
+		if reduced,ok := downsampleImage(p.image, settings.TargetDPI/p.dpi, settings.Quality); ok {
+			replacements[o] = reduced
+		}
+	}
+	return copyReachable(destination, source, false, replacements)
+}
+
+// placementDPI() returns the resolution of pi where it is drawn: the
+// lower of the number of pixels per inch across its width and across
+// its height.  It returns 0 for images drawn with no area.
+func placementDPI(pi PageImage) float64 {
+	m := pi.Matrix
+	width := math.Hypot(m[0], m[1]) / 72
+	height := math.Hypot(m[2], m[3]) / 72
+	if width == 0 || height == 0 {
+		return 0
+	}
+	return math.Min(float64(pi.Width())/width, float64(pi.Height())/height)
+}
+
+// downsampleImage() returns pi reduced by scale and encoded as JPEG
+// with the passed quality, and false if pi can't be reduced or the
+// result wouldn't be smaller.
+func downsampleImage(pi PageImage, scale float64, quality int) (Stream, bool) {
+	dictionary := pi.Stream.Dictionary()
+	if mask,_ := dictionary.GetBoolean("ImageMask"); mask {
+		return nil, false
+	}
+	for _,key := range []string{"SMask", "Mask", "SMaskInData"} {
+		if dictionary.Get(key) != nil {
+			return nil, false
+		}
+	}
+	original,err := pi.Image()
+	if err != nil {
+		return nil, false
+	}
+	gray := false
+	switch original.(type) {
+	case *image.CMYK:
+		return nil, false
+	case *image.Gray, *image.Gray16:
+		gray = true
+	}
+
+	width := int(math.Round(float64(pi.Width())*scale))
+	height := int(math.Round(float64(pi.Height())*scale))
+	if width < 1 {
+		width = 1
+	}
+	if height < 1 {
+		height = 1
+	}
+	var encoded bytes.Buffer
+	if err = jpeg.Encode(&encoded, downsample(original, width, height, gray), &jpeg.Options{Quality: quality}); err != nil {
+		return nil, false
+	}
+	if data,err := encodedData(pi.Stream); err != nil || encoded.Len() >= len(data) {
+		return nil, false
+	}
+
+	result := dictionary.Clone().(Dictionary)
+	for _,key := range []string{"Length", "Filter", "DecodeParms", "Decode"} {
+		result.Remove(key)
+	}
+	// A /Decode array is applied by Image() except to JPEG
+	// images, which keep theirs.
+	if filters,_ := streamFilters(dictionary); len(filters) > 0 && filters[len(filters)-1] == "DCTDecode" {
+		if decode := dictionary.Get("Decode"); decode != nil {
+			result.Add("Decode", decode.Clone())
+		}
+	}
+	components := 3
+	if gray {
+		components = 1
+	}
+	// The color space is kept unless it is indexed, since the
+	// samples are now colors rather than indices.
+	if space := pi.colorSpace(dictionary.Get("ColorSpace"), 0); space == nil || space.palette != nil || space.components != components {
+		if gray {
+			result.Add("ColorSpace", NewName("DeviceGray"))
+		} else {
+			result.Add("ColorSpace", NewName("DeviceRGB"))
+		}
+	}
+	result.Add("Width", NewIntNumeric(width))
+	result.Add("Height", NewIntNumeric(height))
+	result.Add("BitsPerComponent", NewIntNumeric(8))
+	result.Add("Filter", NewName("DCTDecode"))
+	return NewStreamFromContents(result, encoded.Bytes(), nil), true
+}
+
+// downsample() reduces img to width by height pixels, each the
+// average of the pixels of img that it covers.  The result is gray if
+// gray is true.
+func downsample(img image.Image, width, height int, gray bool) image.Image {
+	bounds := img.Bounds()
+	var (
+		grayResult *image.Gray
+		rgbResult *image.RGBA
+		result image.Image )
+	if gray {
+		grayResult = image.NewGray(image.Rect(0, 0, width, height))
+		result = grayResult
+	} else {
+		rgbResult = image.NewRGBA(image.Rect(0, 0, width, height))
+		result = rgbResult
+	}
+	for y:=0; y<height; y++ {
+		y0 := bounds.Min.Y + y*bounds.Dy()/height
+		y1 := bounds.Min.Y + (y+1)*bounds.Dy()/height
+		if y1 == y0 {
+			y1++
+		}
+		for x:=0; x<width; x++ {
+			x0 := bounds.Min.X + x*bounds.Dx()/width
+			x1 := bounds.Min.X + (x+1)*bounds.Dx()/width
+			if x1 == x0 {
+				x1++
+			}
+			var r, g, b, n uint64
+			for sy:=y0; sy<y1; sy++ {
+				for sx:=x0; sx<x1; sx++ {
+					cr,cg,cb,_ := img.At(sx, sy).RGBA()
+					r, g, b = r+uint64(cr), g+uint64(cg), b+uint64(cb)
+					n++
+				}
+			}
+			if gray {
+				grayResult.SetGray(x, y, color.Gray{uint8(r/n >> 8)})
+			} else {
+				rgbResult.SetRGBA(x, y, color.RGBA{uint8(r/n >> 8), uint8(g/n >> 8), uint8(b/n >> 8), 0xff})
+			}
+		}
+	}
+	return result
+}
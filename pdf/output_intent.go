@@ -0,0 +1,80 @@
+package pdf
+
+import "errors"
+
+// Output intent subtypes
+const (
+	OutputIntentPDFA = "GTS_PDFA1"
+	OutputIntentPDFX = "GTS_PDFX" )
+
+var invalidICCProfile = errors.New(`Invalid or unsupported ICC profile`)
+
+// OutputIntent describes the intended output device or production
+// condition of a document.  An output intent with an embedded ICC
+// profile is required by PDF/A and PDF/X.
+type OutputIntent struct {
+	// Subtype is OutputIntentPDFA, OutputIntentPDFX, or another
+	// registered subtype.
+	Subtype string
+	// OutputConditionIdentifier names the output condition,
+	// e.g., "sRGB IEC61966-2.1" or "FOGRA39".
+	OutputConditionIdentifier string
+	// OutputCondition is an optional human-readable description.
+	OutputCondition string
+	// RegistryName is an optional registry in which the
+	// identifier is defined, e.g., "http://www.color.org".
+	RegistryName string
+	// Info is optional additional information.
+	Info string
+	// Profile is the contents of the ICC profile to embed.
+	Profile []byte
+}
+
+// ICCComponents() returns the number of color components of an ICC
+// profile, determined from the color space signature in its header.
+func ICCComponents(profile []byte) (int, error) {
+	if len(profile) < 128 || string(profile[36:40]) != "acsp" {
+		return 0, invalidICCProfile
+	}
+	switch string(profile[16:20]) {
+	case "GRAY":
+		return 1, nil
+	case "RGB ":
+		return 3, nil
+	case "CMYK":
+		return 4, nil
+	}
+	return 0, invalidICCProfile
+}
+
+// AddOutputIntent() embeds the ICC profile of the passed output
+// intent and adds the intent to the /OutputIntents array in the
+// document catalog.
+func (d *Document) AddOutputIntent(intent OutputIntent) error {
+	components,err := ICCComponents(intent.Profile)
+	if err != nil {
+		return err
+	}
+
+	profile := d.streamFactory.New()
+	profile.Add("N", NewIntNumeric(components))
+	profile.Write(intent.Profile)
+
+	dictionary := NewDictionary()
+	dictionary.Add("Type", NewName("OutputIntent"))
+	dictionary.Add("S", NewName(intent.Subtype))
+	dictionary.Add("OutputConditionIdentifier", NewTextString(intent.OutputConditionIdentifier))
+	if intent.OutputCondition != "" {
+		dictionary.Add("OutputCondition", NewTextString(intent.OutputCondition))
+	}
+	if intent.RegistryName != "" {
+		dictionary.Add("RegistryName", NewTextString(intent.RegistryName))
+	}
+	if intent.Info != "" {
+		dictionary.Add("Info", NewTextString(intent.Info))
+	}
+	dictionary.Add("DestOutputProfile", d.WriteObject(profile))
+
+	d.catalogArray("OutputIntents").Add(dictionary)
+	return nil
+}
@@ -12,6 +12,34 @@ type Page struct {
 	resources, fontResources Dictionary
 
 	fontMap map[Font] string
+
+	// indirect is the reference to which the page dictionary is
+	// written by Finish().
+	indirect Indirect
+
+	// structParents holds the structure elements of the page's
+	// marked-content sequences, indexed by MCID.  It is nil
+	// unless BeginMarkedContent() has been called.
+	structParents Array
+
+	// annotations is nil unless annotations have been added.
+	annotations Array
+
+	// beads is nil unless AddBead() has been called.
+	beads Array
+
+	// layerNames and properties are nil unless BeginLayer() has
+	// been called.  properties is the /Properties resource
+	// dictionary.
+	layerNames map[*Layer]string
+	properties Dictionary
+
+	// xobjectResources and xobjectNames are nil unless
+	// AddXObject() has been called.
+	xobjectResources Dictionary
+	xobjectNames map[Indirect]string
+
+	streamFactory *StreamFactory
 }
 
 // There is no constructor here.  Pages are created by a PageFactory.New().
@@ -28,8 +56,19 @@ func (p *Page) Finish() Indirect {
 	p.dictionary.SetContents(NewIndirect(p.fileList...).Write(p.contents))
 	p.contents = nil
 
-	indirect := p.dictionary.Write(NewIndirect(p.fileList...))
+	if p.annotations != nil {
+		p.dictionary.dictionary.Add("Annots", p.annotations)
+		p.annotations = nil
+	}
+
+	if p.beads != nil {
+		p.dictionary.dictionary.Add("B", p.beads)
+		p.beads = nil
+	}
+
+	indirect := p.dictionary.Write(p.indirect)
 	p.dictionary = nil
+	p.structParents = nil
 
 	return indirect
 }
@@ -58,6 +97,29 @@ func (p *Page) AddFont (font Font) string {
 	return name
 }
 
+// AddAnnotation() adds an annotation dictionary to the page.  The
+// /Type and /P entries are set automatically.
+func (p *Page) AddAnnotation(annotation Dictionary) Indirect {
+	if p.dictionary == nil {
+		panic ("AddAnnotation() called on closed page")
+	}
+	if p.annotations == nil {
+		p.annotations = NewArray()
+	}
+	annotation.Add("Type", NewName("Annot"))
+	annotation.Add("P", p.indirect)
+	reference := NewIndirect(p.fileList...).Write(annotation)
+	p.annotations.Add(reference)
+	return reference
+}
+
+// Reference() returns the reference to which the page dictionary is
+// written, for use in destinations.  It is valid before the page is
+// finished.
+func (p *Page) Reference() Indirect {
+	return p.indirect
+}
+
 func (p *Page) SetParent(i Indirect) {
 	p.dictionary.SetParent(i)
 }
@@ -66,6 +128,13 @@ func (p *Page) setProcSet(i Indirect) {
 	p.resources.Add("ProcSet", i)
 }
 
+func (p *Page) SetRotate(degrees int) {
+	if p.dictionary == nil {
+		panic ("SetRotate() called on closed page")
+	}
+	p.dictionary.SetRotate(degrees)
+}
+
 func (p *Page) SetMediaBox(llx, lly, urx, ury float64) {
 	if p.dictionary == nil {
 		panic ("SetMediaBox() called on closed page")
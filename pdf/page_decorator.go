@@ -0,0 +1,73 @@
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"strconv" )
+
+// SetPageDecorator() sets a function that draws on each page created
+// by NewPage(), such as a header or footer, after the rest of the
+// page's contents.  It is called when the page is finished with the
+// page and its number, counting from 1.  The decorator must not
+// start a new page.  Pass nil to stop decorating pages.
+func (d *Document) SetPageDecorator(decorator func(page *Page, number uint)) {
+	d.pageDecorator = decorator
+}
+
+type pageCountFormKey struct {
+	font Font
+	size float64
+}
+
+// PageCountForm() returns a reference to a form XObject that shows the
+// number of pages in the document, which isn't known until the
+// document is closed, so that it can be drawn in headers and footers
+// as pages are created, e.g., "Page 3 of 10".  The number is shown in
+// the given font and size with its baseline starting at the origin of
+// the form's coordinates.  Draw the form with Page.AddXObject() and
+// the Do operator.  If font can encode text, as an OpenTypeFont can,
+// its encoding is used; otherwise the digits are shown as ASCII.
+func (d *Document) PageCountForm(font Font, size float64) Indirect {
+	key := pageCountFormKey{font, size}
+	if d.pageCountForms == nil {
+		d.pageCountForms = make(map[pageCountFormKey]Indirect)
+	}
+	i,exists := d.pageCountForms[key]
+	if !exists {
+		i = NewIndirect(d.file)
+		d.pageCountForms[key] = i
+		d.fonts[font] = true
+	}
+	return i
+}
+
+// finishPageCountForms() writes the forms returned by
+// PageCountForm() now that the number of pages is known.
+func (d *Document) finishPageCountForms() {
+	text := strconv.Itoa(int(d.pageCount))
+	for key,i := range d.pageCountForms {
+		code := []byte(text)
+		if encoder,ok := key.font.(interface{ Encode(string) []byte }); ok {
+			code = encoder.Encode(text)
+		}
+		var contents bytes.Buffer
+		precision := realPrecision(d.file)
+		fmt.Fprintf(&contents, "BT /F1 %s Tf ", FormatReal(key.size, precision))
+		NewContentSerializer(&contents).Write(Operation{"Tj", []Object{NewBinaryString(code)}})
+		contents.WriteString(" ET")
+
+		fonts := NewDictionary()
+		fonts.Add("F1", key.font.Indirect(d.file))
+		resources := NewDictionary()
+		resources.Add("Font", fonts)
+
+		form := d.streamFactory.New()
+		form.Add("Type", NewName("XObject"))
+		form.Add("Subtype", NewName("Form"))
+		// Digits are no wider than an em in any usual font.
+		form.Add("BBox", NewRectangle(0, -key.size, float64(len(text))*key.size, key.size))
+		form.Add("Resources", resources)
+		form.Write(contents.Bytes())
+		i.Finalize(form)
+	}
+}
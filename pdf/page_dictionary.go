@@ -3,6 +3,7 @@ package pdf
 import (
 	"bytes"
 	"errors"
+	"fmt"
 	"io"
 )
 
@@ -148,24 +149,164 @@ func (pd *PageDictionary) setBox (boxname string, llx, lly, urx, ury float64) {
 	pd.dictionary.Add(boxname, NewRectangle(llx, lly, urx, ury))
 }
 
+// setBoundaryBox() sets a box other than the media box after
+// checking that it lies within the page's media box, if the page
+// has one of its own.
+func (pd *PageDictionary) setBoundaryBox(boxname string, llx, lly, urx, ury float64) {
+	box := normalizeBox([4]float64{llx, lly, urx, ury})
+	if mediaBox,ok := pd.box("MediaBox"); ok {
+		checkBoxWithin(boxname, box, mediaBox)
+	}
+	pd.setBox(boxname, box[0], box[1], box[2], box[3])
+}
+
+// normalizeBox() orders the coordinates of box so that its lower-left
+// corner comes first, as readers are expected to do.
+func normalizeBox(box [4]float64) [4]float64 {
+	if box[0] > box[2] {
+		box[0], box[2] = box[2], box[0]
+	}
+	if box[1] > box[3] {
+		box[1], box[3] = box[3], box[1]
+	}
+	return box
+}
+
+// checkBoxWithin() panics if the normalized box named boxname doesn't
+// lie within mediaBox.
+func checkBoxWithin(boxname string, box, mediaBox [4]float64) {
+	mediaBox = normalizeBox(mediaBox)
+	if box[0] < mediaBox[0] || box[1] < mediaBox[1] || box[2] > mediaBox[2] || box[3] > mediaBox[3] {
+		panic (fmt.Sprintf("%s [%v %v %v %v] is not within the MediaBox [%v %v %v %v]", boxname,
+			box[0], box[1], box[2], box[3], mediaBox[0], mediaBox[1], mediaBox[2], mediaBox[3]))
+	}
+}
+
+// intersectBoxes() returns the intersection of two normalized boxes.
+func intersectBoxes(a, b [4]float64) [4]float64 {
+	for i:=0; i<2; i++ {
+		if b[i] > a[i] {
+			a[i] = b[i]
+		}
+		if b[i+2] < a[i+2] {
+			a[i+2] = b[i+2]
+		}
+		if a[i+2] < a[i] {
+			a[i+2] = a[i]
+		}
+	}
+	return a
+}
+
+// box() returns the coordinates of the named box (e.g., "MediaBox")
+// as llx, lly, urx, ury.  The boolean return value is false if the
+// box is missing or invalid.
+func (pd *PageDictionary) box(boxname string) (box [4]float64, ok bool) {
+	array := pd.dictionary.GetArray(boxname)
+	if array == nil || array.Size() != 4 {
+		return box, false
+	}
+	for i := range box {
+		if box[i],ok = numericValue(array.At(i)); !ok {
+			return box, false
+		}
+	}
+	return box, true
+}
+
+// MediaBox() returns the page's media box as llx, lly, urx, ury with
+// the lower-left corner first.  The boolean return value is false if
+// the page has no valid media box.  Pages of pre-existing documents
+// include the media box inherited from the page tree.
+func (pd *PageDictionary) MediaBox() ([4]float64, bool) {
+	box,ok := pd.box("MediaBox")
+	return normalizeBox(box), ok
+}
+
+// CropBox() returns the region to which the page is clipped when
+// displayed or printed: the page's crop box, which defaults to the
+// media box, intersected with the media box.
+func (pd *PageDictionary) CropBox() ([4]float64, bool) {
+	return pd.boundaryBox("CropBox", pd.MediaBox)
+}
+
+// BleedBox() returns the region to which the page is clipped in a
+// production environment.  It defaults to the crop box.
+func (pd *PageDictionary) BleedBox() ([4]float64, bool) {
+	return pd.boundaryBox("BleedBox", pd.CropBox)
+}
+
+// TrimBox() returns the intended dimensions of the finished page
+// after trimming.  It defaults to the crop box.
+func (pd *PageDictionary) TrimBox() ([4]float64, bool) {
+	return pd.boundaryBox("TrimBox", pd.CropBox)
+}
+
+// ArtBox() returns the extent of the page's meaningful content.  It
+// defaults to the crop box.
+func (pd *PageDictionary) ArtBox() ([4]float64, bool) {
+	return pd.boundaryBox("ArtBox", pd.CropBox)
+}
+
+// boundaryBox() returns the named box intersected with the media box,
+// or the box returned by defaultBox if the page has no valid box of
+// that name.
+func (pd *PageDictionary) boundaryBox(boxname string, defaultBox func() ([4]float64, bool)) ([4]float64, bool) {
+	mediaBox,ok := pd.MediaBox()
+	if !ok {
+		return mediaBox, false
+	}
+	if box,ok := pd.box(boxname); ok {
+		return intersectBoxes(normalizeBox(box), mediaBox), true
+	}
+	return defaultBox()
+}
+
 func (pd *PageDictionary) SetMediaBox(llx, lly, urx, ury float64) {
 	pd.setBox("MediaBox", llx, lly, urx, ury)
 }
 
+// SetCropBox(), SetBleedBox(), SetTrimBox(), and SetArtBox() panic
+// if the box doesn't lie within the media box.
 func (pd *PageDictionary) SetCropBox(llx, lly, urx, ury float64) {
-	pd.setBox("CropBox", llx, lly, urx, ury)
+	pd.setBoundaryBox("CropBox", llx, lly, urx, ury)
 }
 
 func (pd *PageDictionary) SetBleedBox(llx, lly, urx, ury float64) {
-	pd.setBox("BleedBox", llx, lly, urx, ury)
+	pd.setBoundaryBox("BleedBox", llx, lly, urx, ury)
 }
 
 func (pd *PageDictionary) SetTrimBox(llx, lly, urx, ury float64) {
-	pd.setBox("TrimBox", llx, lly, urx, ury)
+	pd.setBoundaryBox("TrimBox", llx, lly, urx, ury)
 }
 
 func (pd *PageDictionary) SetArtBox(llx, lly, urx, ury float64) {
-	pd.setBox("ArtBox", llx, lly, urx, ury)
+	pd.setBoundaryBox("ArtBox", llx, lly, urx, ury)
+}
+
+// SetRotate() sets the number of degrees by which the page is rotated
+// clockwise when displayed.  It must be a multiple of 90.
+func (pd *PageDictionary) SetRotate(degrees int) {
+	if degrees % 90 != 0 {
+		panic (fmt.Sprintf("Page rotation of %d degrees is not a multiple of 90", degrees))
+	}
+	pd.dictionary.Add("Rotate", NewIntNumeric(((degrees % 360) + 360) % 360))
+}
+
+// scaleBoxes() multiplies the coordinates of every boundary box
+// present in the page dictionary by factor.
+func (pd *PageDictionary) scaleBoxes(factor float64) {
+	for _,boxname := range []string{"MediaBox", "CropBox", "BleedBox", "TrimBox", "ArtBox"} {
+		if box,ok := pd.box(boxname); ok {
+			pd.setBox(boxname, factor*box[0], factor*box[1], factor*box[2], factor*box[3])
+		}
+	}
+}
+
+// CloneDictionary() returns an unprotected copy of the underlying
+// page dictionary.  Changes to the copy do not affect the page.
+func (pd *PageDictionary) CloneDictionary() Dictionary {
+	return pd.dictionary.Clone().(Dictionary)
 }
 
 func (pd *PageDictionary) Write(id Indirect) Indirect {
@@ -18,6 +18,7 @@ func (pf *PageFactory) New (file... File) *Page {
 
 	p.fileList = file
 	p.contents = pf.StreamFactory.New()
+	p.streamFactory = pf.StreamFactory
 
 	p.parent = nil
 	p.dictionary = NewPageDictionary()
@@ -25,6 +26,7 @@ func (pf *PageFactory) New (file... File) *Page {
 
 	p.fontResources = nil
 	p.fontMap = make(map[Font]string, 15)
+	p.indirect = NewIndirect(file...)
 
 	return p
 }
@@ -0,0 +1,156 @@
+package pdf
+
+import (
+	"strconv"
+	"strings" )
+
+// PageLabelStyle is the numbering style of a page label range.  Its
+// value is the /S name used in page label dictionaries.
+type PageLabelStyle string
+
+const (
+	// NoNumbering labels pages with the prefix alone.
+	NoNumbering PageLabelStyle = ""
+	Decimal PageLabelStyle = "D"
+	UpperRoman PageLabelStyle = "R"
+	LowerRoman PageLabelStyle = "r"
+	UpperAlpha PageLabelStyle = "A"
+	LowerAlpha PageLabelStyle = "a" )
+
+// A PageLabel describes the labels of a range of pages beginning at
+// some page and continuing until the page at which the next range
+// begins.  A page's label is Prefix followed by its number within
+// the range, starting at Start, formatted according to Style.
+type PageLabel struct {
+	Style PageLabelStyle
+	Prefix string
+	// Start is the number of the first page in the range.  A
+	// value less than 1 is treated as 1.
+	Start int
+}
+
+// Format() returns the label of the page at position offset (numbered
+// from 0) within the range.
+func (l PageLabel) Format(offset int) string {
+	n := offset + 1
+	if l.Start > 1 {
+		n = offset + l.Start
+	}
+
+	switch l.Style {
+	case Decimal:
+		return l.Prefix + strconv.Itoa(n)
+	case UpperRoman:
+		return l.Prefix + strings.ToUpper(roman(n))
+	case LowerRoman:
+		return l.Prefix + roman(n)
+	case UpperAlpha:
+		return l.Prefix + strings.ToUpper(alpha(n))
+	case LowerAlpha:
+		return l.Prefix + alpha(n)
+	}
+	return l.Prefix
+}
+
+// roman() returns n as a lower case roman numeral.
+func roman(n int) string {
+	values := []int{1000, 900, 500, 400, 100, 90, 50, 40, 10, 9, 5, 4, 1}
+	numerals := []string{"m", "cm", "d", "cd", "c", "xc", "l", "xl", "x", "ix", "v", "iv", "i"}
+	result := ""
+	for i,value := range values {
+		for n >= value {
+			result += numerals[i]
+			n -= value
+		}
+	}
+	return result
+}
+
+// alpha() returns n as a lower case letter label: a to z for the
+// first 26, aa to zz for the next 26, and so on.
+func alpha(n int) string {
+	if n < 1 {
+		return ""
+	}
+	return strings.Repeat(string(rune('a' + (n-1)%26)), (n-1)/26 + 1)
+}
+
+func (l PageLabel) dictionary() Dictionary {
+	d := NewDictionary()
+	if l.Style != NoNumbering {
+		d.Add("S", NewName(string(l.Style)))
+	}
+	if l.Prefix != "" {
+		d.Add("P", NewTextString(l.Prefix))
+	}
+	if l.Start > 1 {
+		d.Add("St", NewIntNumeric(l.Start))
+	}
+	return d
+}
+
+func parsePageLabel(d ProtectedDictionary) PageLabel {
+	var label PageLabel
+	if style,ok := d.GetName("S"); ok {
+		label.Style = PageLabelStyle(style)
+	}
+	label.Prefix = textString(d, "P")
+	label.Start,_ = d.GetInt("St")
+	return label
+}
+
+// loadPageLabels() initializes d.pageLabels from the catalog.
+func (d *Document) loadPageLabels() {
+	if d.pageLabels != nil {
+		return
+	}
+	d.pageLabels = make(map[uint]PageLabel)
+	ReadNumberTree(d.catalog.GetDictionary("PageLabels")).ForEach(func(key int, value Object) {
+		if label,ok := value.Dereference().(ProtectedDictionary); ok && key >= 0 {
+			d.pageLabels[uint(key)] = parsePageLabel(label)
+		}
+	})
+}
+
+// SetPageLabel() sets the label of the range of pages beginning at
+// page n (numbered from 0).  The range continues until the beginning
+// of the next range.
+func (d *Document) SetPageLabel(n uint, label PageLabel) {
+	d.loadPageLabels()
+	d.pageLabels[n] = label
+	d.pageLabelsChanged = true
+}
+
+// PageLabel() returns the label of page n (numbered from 0).  Pages
+// of documents without page labels are labeled with decimal numbers
+// starting at 1.
+func (d *Document) PageLabel(n uint) string {
+	d.loadPageLabels()
+	if len(d.pageLabels) == 0 {
+		return strconv.Itoa(int(n) + 1)
+	}
+
+	found := false
+	var start uint
+	for key := range d.pageLabels {
+		if key <= n && (!found || key > start) {
+			start = key
+			found = true
+		}
+	}
+	if !found {
+		// A valid page label tree has an entry for page 0.
+		return strconv.Itoa(int(n) + 1)
+	}
+	return d.pageLabels[start].Format(int(n - start))
+}
+
+func (d *Document) finishPageLabels() {
+	if d.pageLabelsChanged {
+		tree := NewNumberTree()
+		for key,label := range d.pageLabels {
+			tree.Add(int(key), label.dictionary())
+		}
+		d.catalog.Add("PageLabels", tree.Build(d.file))
+	}
+}
@@ -2,6 +2,8 @@ package pdf
 
 import ("errors")
 
+var pageTreeTooDeep = errors.New(`Page tree is too deep or contains a cycle`)
+
 type pageTree struct {
 	root Dictionary
 	rootReference Indirect
@@ -56,6 +58,12 @@ func copyDictionaryEntries(dst, src Dictionary, list []string) {
 // into the dictionary, so the dictionary returned does not exactly
 // match the one in the file.
 func pageFromTree (node Dictionary, n uint) *ExistingPage {
+	return pageFromTreeAtDepth(node, n, 0)
+}
+
+// pageFromTreeAtDepth() descends at most maxTreeDepth levels so that
+// a /Kids array leading back to an ancestor can't recurse forever.
+func pageFromTreeAtDepth (node Dictionary, n uint, depth int) *ExistingPage {
 	var (
 		kids ProtectedArray
 		ok bool )
@@ -88,12 +96,15 @@ func pageFromTree (node Dictionary, n uint) *ExistingPage {
 				panic (errors.New(`Page tree node missing /Count`))
 			}
 			if n < uint(count) {
-				return pageFromTree(kid,n)
+				if depth >= maxTreeDepth {
+					panic (pageTreeTooDeep)
+				}
+				return pageFromTreeAtDepth(kid,n,depth+1)
 			}
 			n -= uint(count)
 		case "Page":
 			if n == 0 {
-				return &ExistingPage{&PageDictionary{kid.Protect().(ProtectedDictionary), kid, true}, kidReference}
+				return &ExistingPage{&PageDictionary{kid.Protect().(ProtectedDictionary), kid, true}, kidReference, nil}
 			}
 			n -= 1
 		default:
@@ -101,4 +112,36 @@ func pageFromTree (node Dictionary, n uint) *ExistingPage {
 		}
 	}
 	return nil
-}
\ No newline at end of file
+}
+
+// pageReferences() returns references to the pages in the page tree
+// rooted at node, in page order.  visited contains the object numbers
+// of the nodes already encountered, which are skipped so that a
+// malformed tree cannot cause infinite recursion.
+func pageReferences(file File, node ProtectedDictionary, visited map[ObjectNumber]bool) (result []ProtectedIndirect) {
+	kids := node.GetArray("Kids")
+	if kids == nil {
+		return nil
+	}
+	for i:=0; i<kids.Size(); i++ {
+		reference,ok := kids.At(i).(ProtectedIndirect)
+		if !ok {
+			continue
+		}
+		objectNumber := reference.ObjectNumber(file)
+		if visited[objectNumber] {
+			continue
+		}
+		visited[objectNumber] = true
+		kid,ok := reference.Dereference().(ProtectedDictionary)
+		if !ok {
+			continue
+		}
+		if kid.CheckNameValue("Type", "Pages") {
+			result = append(result, pageReferences(file, kid, visited)...)
+		} else {
+			result = append(result, reference)
+		}
+	}
+	return result
+}
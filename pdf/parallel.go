@@ -0,0 +1,97 @@
+package pdf
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync" )
+
+// ForEachPage() calls fn for each page of the document, using up to
+// "workers" goroutines, so that work such as text extraction or
+// rasterization can proceed on several pages at once.  Page numbers
+// are handed to the workers in order, but each worker reads its page
+// itself, so pages are read and fn is called for them in any order
+// and concurrently.  Since pages are only read, fn needn't
+// synchronize access to the document's file, but it must synchronize
+// any changes it makes to the document or to other shared state.  The
+// first error returned by fn stops processing of further pages and is
+// returned once the pages already started are finished.  Cancelling
+// ctx has the same effect, and ctx.Err() is returned.  A panic while
+// processing a page is returned as an error.
+func (d *Document) ForEachPage(ctx context.Context, workers int, fn func(n uint, page *ExistingPage) error) error {
+	if workers < 1 {
+		workers = 1
+	}
+	ctx,cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg sync.WaitGroup
+		mutex sync.Mutex
+		once sync.Once
+		firstError error )
+	fail := func(err error) {
+		once.Do(func() {
+			firstError = err
+			cancel()
+		})
+	}
+
+	pageCount := d.pageCount
+	progress := progressOf(d.file)
+	done := uint(0)
+
+	pages := make(chan uint)
+	for i:=0; i<workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for n := range pages {
+				if err := d.processPage(n, fn); err != nil {
+					fail(err)
+					continue
+				}
+				mutex.Lock()
+				done += 1
+				progress.OnPageProcessed(done, pageCount)
+				mutex.Unlock()
+			}
+		}()
+	}
+
+feed:
+	for n:=uint(0); n<pageCount; n++ {
+		select {
+		case pages <- n:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(pages)
+	wg.Wait()
+
+	if firstError == nil && ctx.Err() != nil {
+		// The caller's context was cancelled.
+		firstError = ctx.Err()
+	}
+	return firstError
+}
+
+// processPage() reads page n and calls fn for it, converting a panic
+// into an error.
+func (d *Document) processPage(n uint, fn func(uint, *ExistingPage) error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			if e,ok := r.(error); ok {
+				err = fmt.Errorf("Page %d: %w", n, e)
+			} else {
+				err = errors.New(fmt.Sprintf("Page %d: %v", n, r))
+			}
+		}
+	}()
+	page := d.Page(n)
+	if page == nil {
+		return errors.New(fmt.Sprintf("Page %d not found", n))
+	}
+	return fn(n, page)
+}
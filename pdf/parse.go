@@ -0,0 +1,83 @@
+package pdf
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/mawicks/PDFiG/containers" )
+
+// The functions below parse the three kinds of syntax found in a PDF
+// file from byte slices.  They never panic, whatever the input, which
+// makes them suitable as fuzzing targets and for tools that examine
+// fragments of damaged files.
+
+var trailingInput = errors.New(`Input continues after the object`)
+
+// ParseObject() parses data, which must contain exactly one object,
+// optionally surrounded by white space and comments.  References,
+// such as "12 0 R", are bound to the optional file, as with
+// Parser.Scan(), and are an error if no file is given.  The default
+// ParserLimits apply.
+func ParseObject(data []byte, file ...File) (Object, error) {
+	parser := NewParser(bytes.NewReader(data))
+	object,err := parser.Scan(file...)
+	if err != nil {
+		return nil, err
+	}
+	// An integer followed by another is held by the parser in case
+	// it begins a reference.
+	if parser.queuedObject != nil {
+		return nil, &SyntaxError{Offset: parser.scanner.Position(), Err: trailingInput}
+	}
+	if _,err := nextNonWhiteByte(parser.scanner); err == nil {
+		return nil, &SyntaxError{Offset: parser.scanner.Position()-1, Err: trailingInput}
+	}
+	return object, nil
+}
+
+// ParseContentStream() parses the decoded content stream data as
+// ParseContent() does.  The default ParserLimits apply.
+func ParseContentStream(data []byte) ([]Operation, error) {
+	return ParseContent(bytes.NewReader(data))
+}
+
+// XrefEntry is an entry of a cross-reference section.
+type XrefEntry struct {
+	// Offset is the position of an object in use, or the number of
+	// the next free object for a free one.
+	Offset uint64
+	Generation uint16
+	InUse bool
+}
+
+// ParseXref() parses a cross-reference section, from the "xref"
+// keyword through the trailer dictionary, and returns its entries
+// by object number along with the trailer.  References in the trailer
+// are bound to the optional file.  If none is given, they are bound
+// to a placeholder that can't resolve them.  Errors are XrefErrors.
+func ParseXref(data []byte, file ...File) (entries map[uint32]XrefEntry, trailer Dictionary, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			e,ok := r.(error)
+			if !ok {
+				e = errors.New(fmt.Sprint(r))
+			}
+			entries,trailer,err = nil,nil,&XrefError{0, e}
+		}
+	}()
+
+	var f File = NewMockFile(0, 0)
+	if len(file) > 0 {
+		f = file[0]
+	}
+	xref := containers.NewDynamicArray(64)
+	trailer = scanXrefSection(bufio.NewReader(bytes.NewReader(data)), xref, DefaultParsing, defaultLimits, f)
+	entries = make(map[uint32]XrefEntry)
+	for i:=uint(0); i<xref.Size(); i++ {
+		if entry,ok := (*xref.At(i)).(*xrefEntry); ok {
+			entries[uint32(i)] = XrefEntry{entry.byteOffset, entry.generation, entry.inUse}
+		}
+	}
+	return entries, trailer, nil
+}
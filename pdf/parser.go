@@ -1,9 +1,13 @@
 package pdf
 
 import (
+	"bufio"
+	"bytes"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"errors"
+	"strings"
 	"github.com/mawicks/PDFiG/readers"
 	"strconv" )
 
@@ -16,13 +20,139 @@ type Scanner interface {
 type Parser struct {
 	scanner *readers.HistoryReader
 	queuedObject Object
+	mode ParsingMode
+	// offset is the position of the scanner's input in the file.
+	offset int64
+	// source, if not nil, is the file from which large stream
+	// contents are read when needed rather than held in memory.
+	source io.ReaderAt
+	limits ParserLimits
+	// depth is the nesting of the array or dictionary being
+	// scanned.
+	depth int
+	// resolve, if not nil, reads the object to which a stream's
+	// /Length refers in place of Dereference().
+	resolve func(Indirect) (Object, error)
+	// pooled, if not nil, is the reader taken from objectReaders
+	// from which the parser reads.
+	pooled *bufio.Reader
 }
 
+// largeStreamSize is the length above which the contents of a stream
+// are left in the parser's stream source.
+const largeStreamSize = 1<<20
+
+// ParserLimits bounds the work done by a Parser or ContentParser on
+// pathological input: deeply nested arrays, which would otherwise
+// exhaust the stack, and unterminated strings or runaway numbers,
+// which would otherwise be accumulated to the end of the input.
+// Input that exceeds a limit is reported as a SyntaxError wrapping a
+// LimitError.  A zero
+// field selects the default given with it.
+type ParserLimits struct {
+	// MaxDepth limits the nesting of arrays and dictionaries
+	// (default 256).
+	MaxDepth int
+	// MaxTokenLength limits the length in bytes of names, numbers,
+	// and keywords (default 1024).
+	MaxTokenLength int
+	// MaxStringLength limits the length in bytes of strings after
+	// escapes are decoded (default 16 MiB).
+	MaxStringLength int
+}
+
+var defaultParserLimits = ParserLimits{
+	MaxDepth: 256,
+	MaxTokenLength: 1024,
+	MaxStringLength: 1<<24 }
+
+// withDefaults() returns the limits with zero fields replaced by the
+// defaults.
+func (l ParserLimits) withDefaults() ParserLimits {
+	if l.MaxDepth <= 0 {
+		l.MaxDepth = defaultParserLimits.MaxDepth
+	}
+	if l.MaxTokenLength <= 0 {
+		l.MaxTokenLength = defaultParserLimits.MaxTokenLength
+	}
+	if l.MaxStringLength <= 0 {
+		l.MaxStringLength = defaultParserLimits.MaxStringLength
+	}
+	return l
+}
+
+// ParsingMode determines how a Parser treats input that deviates from
+// the PDF specification.
+type ParsingMode int
+
+const (
+	// DefaultParsing tolerates some deviations and rejects others.
+	DefaultParsing ParsingMode = iota
+	// StrictParsing rejects deviations from the specification
+	// with errors that describe them, which suits validators.
+	StrictParsing
+	// LenientParsing recovers from common deviations, such as
+	// missing white space around keywords, stream /Length values
+	// that don't match the data, a missing "endobj", and xref
+	// offsets that are off by a few bytes, which suits bulk
+	// processing of files from many sources.
+	LenientParsing
+)
+
 // NewParser constructs a new parser from the passed Scanner.
 // Typically Scanner will be the pdf.File's underlying os.File, but
 // this is not strictly necessary.
 func NewParser(scanner Scanner) *Parser {
-	return &Parser{readers.NewHistoryReader(scanner,64),nil}
+	return &Parser{readers.NewHistoryReader(scanner,64),nil,DefaultParsing,0,nil,defaultParserLimits,0,nil,nil}
+}
+
+// SetLimits() sets the limits on nesting and on the length of tokens.
+// The default is the zero ParserLimits.
+func (p *Parser) SetLimits(limits ParserLimits) {
+	p.limits = limits.withDefaults()
+}
+
+// SetOffset() sets the position in its file of the parser's input so
+// that the offsets reported in SyntaxErrors are relative to the file.
+func (p *Parser) SetOffset(offset int64) {
+	p.offset = offset
+}
+
+// SetStreamSource() sets the file that contains the parser's input.
+// Stream contents longer than largeStreamSize are then skipped and
+// read from r when they are used, so r must remain open as long as the
+// streams returned by the parser are in use.
+func (p *Parser) SetStreamSource(r io.ReaderAt) {
+	p.source = r
+}
+
+// release() returns the parser's reader to objectReaders, if it was
+// taken from there, once the parser is no longer needed.
+func (p *Parser) release() {
+	if p.pooled != nil {
+		putObjectReader(p.pooled)
+		p.pooled = nil
+	}
+}
+
+// syntaxError() converts the value of a panic raised while parsing to
+// a SyntaxError located at the current position.
+func (p *Parser) syntaxError(x interface{}) error {
+	e,ok := x.(*SyntaxError)
+	if !ok {
+		err,isError := x.(error)
+		if !isError {
+			err = errors.New(fmt.Sprint(x))
+		}
+		e = &SyntaxError{Err: err}
+	}
+	e.Offset = p.offset + p.scanner.Position()
+	return e
+}
+
+// SetMode() sets the parsing mode.  The default is DefaultParsing.
+func (p *Parser) SetMode(mode ParsingMode) {
+	p.mode = mode
 }
 
 var (
@@ -35,26 +165,53 @@ var (
 	unexpectedInput = errors.New(`Unexpected character or end of input`)
 	expectedGreaterThan = errors.New(`Expected ">"`)
 	expectingHexDigit = errors.New(`Expecting hex digit`)
-	expectingOctalDigit = errors.New(`Expecting octal digit`) )
+	expectingOctalDigit = errors.New(`Expecting octal digit`)
+	missingStreamLength = errors.New(`Stream dictionary has no valid /Length`)
+	streamLengthMismatch = errors.New(`Stream /Length doesn't match the position of "endstream"`)
+	missingEndstream = errors.New(`No "endstream" following stream data`)
+	invalidObjectHeader = errors.New(`Invalid object header`)
+	objectNumberMismatch = errors.New(`Object header doesn't match xref`)
+	missingEndobj = errors.New(`No "endobj" following object`)
+	duplicateKey = errors.New(`Duplicate dictionary key`)
+	unexpectedReference = errors.New(`Indirect reference where no file was given to resolve it`) )
+
+// checkLength() panics if a token or string being accumulated in
+// buffer has reached max bytes.  limit is the name of the limit.
+func checkLength(buffer []byte, max int, limit string) {
+	if len(buffer) >= max {
+		if len(buffer) > 16 {
+			buffer = append(buffer[:16:16], "..."...)
+		}
+		panic(&SyntaxError{Found: string(buffer), Err: &LimitError{limit, int64(max)}})
+	}
+}
 
-// Skip white space and return the byte following the white space or error.
+// Skip white space and comments and return the byte following them or error.
 // If err is non-nil, the value of b is undefined.
 func nextNonWhiteByte (scanner Scanner) (b byte,err error) {
 	b, err = scanner.ReadByte()
 	for ; err == nil && (IsWhiteSpace(b) || b == '%'); b,err=scanner.ReadByte() {
 		if b == '%' {
-			ReadLine(scanner)
+			skipComment(scanner)
 		}
 	}
 	return
 }
 
-func scanKeyword (scanner Scanner, b byte) (string,error) {
+// skipComment() reads the rest of a comment, which extends to the end
+// of the line, without retaining it, since a comment may be long.
+func skipComment(scanner Scanner) {
+	for b,err := scanner.ReadByte(); err == nil && b != '\r' && b != '\n'; b,err = scanner.ReadByte() {
+	}
+}
+
+func scanKeyword (scanner Scanner, b byte, maxLength int) (string,error) {
 	var buffer[]byte = make([]byte, 0, 5)
 	buffer = append(buffer, b)
 
 	b,err := scanner.ReadByte()
 	for ; err == nil && IsAlpha(b); b,err=scanner.ReadByte() {
+		checkLength(buffer, maxLength, "MaxTokenLength")
 		buffer = append(buffer, b)
 	}
 
@@ -67,8 +224,8 @@ func scanKeyword (scanner Scanner, b byte) (string,error) {
 	return string(buffer),err
 }
 
-func scanKeywordObject (scanner Scanner, b byte) Object {
-	keyword,err := scanKeyword(scanner, b)
+func scanKeywordObject (scanner Scanner, b byte, maxLength int) Object {
+	keyword,err := scanKeyword(scanner, b, maxLength)
 	if err == nil {
 		switch (keyword) {
 		case "null":
@@ -79,10 +236,10 @@ func scanKeywordObject (scanner Scanner, b byte) Object {
 			return NewBoolean(false)
 		}
 	}
-	panic(invalidKeyword)
+	panic(&SyntaxError{Expected: "true, false, or null", Found: keyword, Err: invalidKeyword})
 }
 
-func scanNumeric (scanner Scanner, b byte) Object {
+func scanNumeric (scanner Scanner, b byte, maxLength int) Object {
 	var buffer[]byte = make([]byte, 0, 5)
 	var err error
 
@@ -95,6 +252,7 @@ func scanNumeric (scanner Scanner, b byte) Object {
 	}
 
 	for ; err==nil && IsDigit(b); b,err=scanner.ReadByte() {
+		checkLength(buffer, maxLength, "MaxTokenLength")
 		hasAtLeastOneDigit = true
 		buffer = append(buffer,b)
 	}
@@ -106,6 +264,7 @@ func scanNumeric (scanner Scanner, b byte) Object {
 	}
 
 	for ; err==nil && IsDigit(b); b,err=scanner.ReadByte() {
+		checkLength(buffer, maxLength, "MaxTokenLength")
 		hasAtLeastOneDigit = true
 		buffer = append(buffer,b)
 	}
@@ -122,12 +281,16 @@ func scanNumeric (scanner Scanner, b byte) Object {
 		panic(expectingDigit)
 	}
 
-	if float {
-		number,_ := strconv.ParseFloat(string(buffer),32)
-		return NewRealNumeric(float32(number))
+	// Integers outside the range of 32-bit integers are read as
+	// reals, and reals outside the range of 32-bit reals are
+	// limited to the largest real, as the specification permits.
+	if !float {
+		if number,err := strconv.ParseInt(string(buffer),10,32); err == nil {
+			return NewIntNumeric(int(number))
+		}
 	}
-	number,_ := strconv.ParseInt(string(buffer),10,32)
-	return NewIntNumeric(int(number))
+	number,_ := strconv.ParseFloat(string(buffer),64)
+	return &RealNumeric{adjustRealRange(number)}
 }
 
 func (p *Parser) scanNumericOrIndirectRef(b byte, file... File) Object {
@@ -139,7 +302,7 @@ func (p *Parser) scanNumericOrIndirectRef(b byte, file... File) Object {
 		n1 = p.queuedObject
 		p.queuedObject = nil
 	} else {
-		n1 = scanNumeric(p.scanner, b)
+		n1 = scanNumeric(p.scanner, b, p.limits.MaxTokenLength)
 	}
 
 	if _,ok := n1.(*IntNumeric); !ok {
@@ -156,7 +319,7 @@ func (p *Parser) scanNumericOrIndirectRef(b byte, file... File) Object {
 		return n1
 	}
 
-	n2 := scanNumeric (p.scanner, b)
+	n2 := scanNumeric (p.scanner, b, p.limits.MaxTokenLength)
 	if _,ok := n2.(*IntNumeric); !ok {
 		if (p.queuedObject != nil) {
 			panic ("Queued object is not nil. This shouldn't happen")
@@ -165,17 +328,23 @@ func (p *Parser) scanNumericOrIndirectRef(b byte, file... File) Object {
 		return n1
 	}
 
+	// At the end of the input n2 is left queued without unreading
+	// anything, so that it isn't read a second time.
 	b,err = nextNonWhiteByte(p.scanner)
-	if b != 'R' {
+	if err == nil && b != 'R' {
 		p.scanner.UnreadByte()
+	}
+	if err != nil || b != 'R' {
 		p.queuedObject = n2
 		return n1
 	} else {
+		if len(file) == 0 {
+			panic(unexpectedReference)
+		}
 		number := uint32(n1.(*IntNumeric).Value())
 		generation := uint16(n2.(*IntNumeric).Value())
 		return file[0].Indirect(ObjectNumber{number,generation})
 	}
-	return nil
 }
 
 
@@ -195,10 +364,11 @@ func scanOctalDigit (scanner Scanner) byte {
 	return scanDigitWithBase (scanner, ParseOctalDigit)
 }
 
-func scanName (scanner Scanner) Object {
+func scanName (scanner Scanner, maxLength int) Object {
 	var buffer[]byte = make([]byte, 0, 8)
 	b,err := scanner.ReadByte()
 	for ; err == nil && IsRegular(b); b,err=scanner.ReadByte() {
+		checkLength(buffer, maxLength, "MaxTokenLength")
 		if (b != '#') {
 			buffer = append(buffer, b)
 		} else {
@@ -247,11 +417,12 @@ func scanEscape (scanner Scanner) (b byte) {
 	return
 }
 
-func scanNormalString (scanner Scanner) String {
+func scanNormalString (scanner Scanner, maxLength int) String {
 	var openCount = 0
 	var buffer[]byte = make([]byte, 0, 128)
 	b,err :=scanner.ReadByte()
 	for ; err == nil && (b!=')' || openCount != 0); b,err=scanner.ReadByte() {
+		checkLength(buffer, maxLength, "MaxStringLength")
 		switch b {
 		case '(':
 			openCount += 1;
@@ -272,10 +443,11 @@ func scanNormalString (scanner Scanner) String {
 	return NewBinaryString(buffer)
 }
 
-func scanHexString (scanner Scanner,b byte) String {
+func scanHexString (scanner Scanner, b byte, maxLength int) String {
 	var buffer[]byte = make([]byte, 0, 128)
 	var err error
 	for ; err == nil && b != '>'; b,err=scanner.ReadByte() {
+		checkLength(buffer, maxLength, "MaxStringLength")
 		scanner.UnreadByte()
 		r := byte(0)
 		for i:=0; i<2; i++ {
@@ -289,11 +461,27 @@ func scanHexString (scanner Scanner,b byte) String {
 	return NewBinaryString(buffer)
 }
 
+// enter() records the start of an array or dictionary, panicking if
+// the nesting is too deep.  leave() records its end.  Since a panic
+// ends the scan, the depth is reset by Scan() and ScanIndirect()
+// rather than by deferred calls to leave().
+func (p *Parser) enter() {
+	p.depth++
+	if p.depth > p.limits.MaxDepth {
+		panic(&SyntaxError{Err: &LimitError{"MaxDepth", int64(p.limits.MaxDepth)}})
+	}
+}
+
+func (p *Parser) leave() {
+	p.depth--
+}
+
 func (p *Parser) scanArray (file... File) Array {
 	var array Array = NewArray()
+	p.enter()
 
 	b,err := nextNonWhiteByte(p.scanner)
-	for ; p.queuedObject != nil || (err == nil && b != ']'); b,err=p.scanner.ReadByte() {
+	for ; err == nil && (p.queuedObject != nil || b != ']'); b,err=nextNonWhiteByte(p.scanner) {
 		p.scanner.UnreadByte()
 		nextElement := p.scanObject(file...)
 		array.Add(nextElement)
@@ -301,18 +489,23 @@ func (p *Parser) scanArray (file... File) Array {
 	if err != nil {
 		panic(unexpectedEnd)
 	}
+	p.leave()
 	return array
 }
 
 func (p *Parser) scanDictionary(file... File) Dictionary {
 	var d Dictionary = NewDictionary()
+	p.enter()
 
 	b,err := nextNonWhiteByte(p.scanner)
-	for ; err == nil && b != '>'; b,err=p.scanner.ReadByte() {
+	for ; err == nil && b != '>'; b,err=nextNonWhiteByte(p.scanner) {
 		p.scanner.UnreadByte()
 		name,ok := p.scanObject().(Name)
 		if (!ok) {
-			panic(expectingName)
+			panic(&SyntaxError{Expected: "name", Err: expectingName})
+		}
+		if p.mode == StrictParsing && d.Get(name.String()) != nil {
+			panic(&SyntaxError{Found: "/" + name.String(), Err: duplicateKey})
 		}
 		object := p.scanObject(file...)
 		d.Add(name.String(),object)
@@ -324,8 +517,9 @@ func (p *Parser) scanDictionary(file... File) Dictionary {
 
 	b,err = nextNonWhiteByte(p.scanner)
 	if (b != '>') {
-		panic(expectedGreaterThan)
+		panic(&SyntaxError{Expected: `">"`, Found: string(b), Err: expectedGreaterThan})
 	}
+	p.leave()
 	return d
 }
 
@@ -336,26 +530,37 @@ func (p *Parser) scanDictionaryOrStream (file... File) Object {
 
 	var b byte
 	b,err = nextNonWhiteByte(p.scanner)
-	p.scanner.UnreadByte()
+	if err == nil {
+		p.scanner.UnreadByte()
+	}
 
 	var s string
 	// Could be a "stream" line.
 	if b=='s' {
-		s,err = ReadLine (p.scanner)
+		s,err = readStreamLine(p.scanner)
+	}
+
+	if p.mode == LenientParsing {
+		s = strings.TrimRight(s, " \t")
 	}
 
 	var stream Object
 	if err == nil && s == "stream" {
-		v,ok := dictionary.Get("Length").(*IntNumeric)
-		if ok {
-			length := v.Value()
-			contents := make([]byte, length)
-			p.scanner.Read(contents)
-			nextNonWhiteByte(p.scanner)
-			p.scanner.UnreadByte()
-			s,err = ReadLine(p.scanner)
-			if err == nil && s == "endstream" {
-				stream = NewStreamFromContents (dictionary,contents,nil)
+		switch p.mode {
+		case StrictParsing:
+			stream = p.scanStrictStream(dictionary)
+		case LenientParsing:
+			stream = p.scanLenientStream(dictionary)
+		default:
+			length,ok := p.streamLength(dictionary)
+			if ok && length >= 0 {
+				contents := p.scanStreamData(dictionary, length)
+				nextNonWhiteByte(p.scanner)
+				p.scanner.UnreadByte()
+				s,err = readKeywordLine(p.scanner)
+				if contents != nil && err == nil && s == "endstream" {
+					stream = contents
+				}
 			}
 		}
 	}
@@ -365,6 +570,159 @@ func (p *Parser) scanDictionaryOrStream (file... File) Object {
 	return dictionary
 }
 
+// readStreamLine() reads the line containing the "stream" keyword.
+// Unlike ReadLine(), it ends the line at exactly CRLF or LF, as the
+// specification requires, so that stream data beginning with CR isn't
+// taken for part of the end of line.  A lone CR, which some writers
+// use, also ends the line.
+func readStreamLine(r io.ByteScanner) (string, error) {
+	var line []byte
+	b,err := r.ReadByte()
+	for ; err == nil && b != '\r' && b != '\n'; b,err = r.ReadByte() {
+		line = append(line, b)
+	}
+	if err == nil && b == '\r' {
+		if next,err := r.ReadByte(); err == nil && next != '\n' {
+			r.UnreadByte()
+		}
+	}
+	if err == io.EOF {
+		err = nil
+	}
+	return string(line), err
+}
+
+// scanStrictStream() reads stream data whose length must be given
+// exactly by /Length.
+func (p *Parser) scanStrictStream(dictionary Dictionary) Object {
+	length,ok := p.streamLength(dictionary)
+	if !ok || length < 0 {
+		panic(missingStreamLength)
+	}
+	stream := p.scanStreamData(dictionary, length)
+	if stream == nil {
+		panic(unexpectedEnd)
+	}
+	nextNonWhiteByte(p.scanner)
+	p.scanner.UnreadByte()
+	if s,_ := readKeywordLine(p.scanner); s != "endstream" {
+		panic(&SyntaxError{Expected: "endstream", Found: s, Err: streamLengthMismatch})
+	}
+	return stream
+}
+
+// streamLength() returns the value of /Length, which may be an
+// indirect reference to an object elsewhere in the file.  It panics
+// with a LimitError if reading that object would exceed a limit.
+func (p *Parser) streamLength(dictionary Dictionary) (length int64, ok bool) {
+	switch v := dictionary.Get("Length").(type) {
+	case *IntNumeric:
+		return int64(v.Value()), true
+	case Indirect:
+		var object Object
+		if p.resolve != nil {
+			var err error
+			if object,err = p.resolve(v); errors.Is(err, &LimitError{}) {
+				panic(err)
+			}
+		} else {
+			defer func() {
+				if recover() != nil {
+					length,ok = 0,false
+				}
+			}()
+			object = v.Dereference()
+		}
+		if n,isInt := object.(*IntNumeric); isInt {
+			return int64(n.Value()), true
+		}
+	}
+	return 0, false
+}
+
+// scanStreamData() reads length bytes of stream contents.  Large
+// contents are skipped and left in the parser's stream source, if it
+// has one.  It returns nil if the input ends first.
+func (p *Parser) scanStreamData(dictionary Dictionary, length int64) Stream {
+	if p.source != nil && length > largeStreamSize {
+		start := p.offset + p.scanner.Position()
+		if _,err := io.CopyN(ioutil.Discard, p.scanner, length); err != nil {
+			return nil
+		}
+		return newStreamFromRegion(dictionary, io.NewSectionReader(p.source, start, length))
+	}
+	contents := readUpTo(p.scanner, length)
+	if int64(len(contents)) != length {
+		return nil
+	}
+	return NewStreamFromContents(dictionary, contents, nil)
+}
+
+// readUpTo() reads up to n bytes from r.  Memory is allocated as the
+// data arrives rather than all at once so that a bogus length, such
+// as a huge /Length, can't exhaust memory.
+func readUpTo(r io.Reader, n int64) []byte {
+	var buffer bytes.Buffer
+	io.CopyN(&buffer, r, n)
+	return buffer.Bytes()
+}
+
+// scanLenientStream() reads stream data, using /Length if it is
+// consistent with the position of "endstream" and otherwise using the
+// data that precedes "endstream".  /Length is corrected to match, with
+// a warning, since the file is damaged.
+func (p *Parser) scanLenientStream(dictionary Dictionary) Object {
+	var contents []byte
+	start := p.offset + p.scanner.Position()
+	length,ok := p.streamLength(dictionary)
+	if ok && length > 0 {
+		contents = readUpTo(p.scanner, length)
+	}
+	if i := bytes.Index(contents, []byte("endstream")); i >= 0 {
+		// /Length is too long.
+		contents = trimEOL(contents[:i])
+	} else {
+		rest := p.scanToEndstream()
+		if len(bytes.TrimLeft(rest, whiteSpaceCharacters)) != 0 {
+			// /Length is too short or missing.
+			contents = trimEOL(append(contents, rest...))
+		}
+	}
+	if !ok {
+		fmt.Fprintf(logger, "Warning: %v; using %d\n", &SyntaxError{Offset: start, Err: missingStreamLength}, len(contents))
+	} else if int64(len(contents)) != length {
+		fmt.Fprintf(logger, "Warning: %v; using %d rather than %d\n", &SyntaxError{Offset: start, Err: streamLengthMismatch}, len(contents), length)
+	}
+	dictionary.Add("Length", NewIntNumeric(len(contents)))
+	return NewStreamFromContents(dictionary, contents, nil)
+}
+
+// scanToEndstream() reads through the next "endstream" keyword and
+// returns the bytes that precede it.
+func (p *Parser) scanToEndstream() []byte {
+	keyword := []byte("endstream")
+	var buffer []byte
+	for !bytes.HasSuffix(buffer, keyword) {
+		b,err := p.scanner.ReadByte()
+		if err != nil {
+			panic(missingEndstream)
+		}
+		buffer = append(buffer, b)
+	}
+	return buffer[:len(buffer)-len(keyword)]
+}
+
+// trimEOL() removes the end-of-line marker that precedes "endstream".
+func trimEOL(data []byte) []byte {
+	if bytes.HasSuffix(data, []byte("\r\n")) {
+		return data[:len(data)-2]
+	}
+	if bytes.HasSuffix(data, []byte("\n")) || bytes.HasSuffix(data, []byte("\r")) {
+		return data[:len(data)-1]
+	}
+	return data
+}
+
 func (p *Parser) scanObject(file ...File) Object {
 	// If there's a non-integer object left parsed during a previous
 	// call, go ahead and return it.
@@ -376,24 +734,29 @@ func (p *Parser) scanObject(file ...File) Object {
 		}
 	}
 	b,err := nextNonWhiteByte(p.scanner)
+	if err != nil && p.queuedObject != nil {
+		object := p.queuedObject
+		p.queuedObject = nil
+		return object
+	}
 	if err == nil {
 		switch  {
 		case IsAlpha(b):
-			return scanKeywordObject(p.scanner, b)
+			return scanKeywordObject(p.scanner, b, p.limits.MaxTokenLength)
 		case IsDigit(b),p.queuedObject != nil:
 			return p.scanNumericOrIndirectRef(b, file...)
 		case b=='.',b=='+',b=='-':
-			return scanNumeric(p.scanner, b)
+			return scanNumeric(p.scanner, b, p.limits.MaxTokenLength)
 		case b =='/':
-			return scanName (p.scanner)
+			return scanName (p.scanner, p.limits.MaxTokenLength)
 		case b=='(':
-			return scanNormalString(p.scanner)
+			return scanNormalString(p.scanner, p.limits.MaxStringLength)
 		case b=='<':
 			b,err = nextNonWhiteByte(p.scanner)
 			if b == '<' {
 				return p.scanDictionaryOrStream(file...)
 			} else {
-				return scanHexString(p.scanner, b)
+				return scanHexString(p.scanner, b, p.limits.MaxStringLength)
 			}
 		case b=='[':
 			return p.scanArray(file...)
@@ -412,10 +775,11 @@ func (p *Parser) scanObject(file ...File) Object {
 func (p *Parser) Scan(file... File) (o Object,err error) {
 	defer func() {
 		if x := recover(); x!= nil {
-			err,_ = x.(error)
+			err = p.syntaxError(x)
 		}
 	} ()
 
+	p.depth = 0
 	o = p.scanObject(file...)
 
 	return
@@ -429,37 +793,65 @@ func (p *Parser) Scan(file... File) (o Object,err error) {
 func (p *Parser) ScanIndirect(objectNumber ObjectNumber, file... File) (object Object,err error) {
 	defer func() {
 		if x := recover(); x!= nil {
-			err,_ = x.(error)
+			err = p.syntaxError(x)
 		}
 	} ()
 
-	header,_ := ReadLine(p.scanner)
 	var (
 		index uint32
 		generation uint16
 		obj string )
 
-	n,err := fmt.Sscanf (header, "%d %d %s", &index, &generation, &obj)
-	if err != nil || n != 3 {
-		panic(errors.New(fmt.Sprintf(`Object header expected but not found at position %p`, p)))
-	}
+	p.depth = 0
+	index,generation,obj = p.scanObjectHeader()
 	if (objectNumber.number != index || objectNumber.generation != generation) {
-		panic(errors.New(fmt.Sprintf(`Expected "%d %d obj" at location %d but found "%d %d %s"`,
-			objectNumber.number, objectNumber.generation,
-			index, generation, obj)))
+		panic(&SyntaxError{
+			Expected: fmt.Sprintf("%d %d obj", objectNumber.number, objectNumber.generation),
+			Found: fmt.Sprintf("%d %d %s", index, generation, obj),
+			Err: objectNumberMismatch})
 	}
 	object = p.scanObject(file...)
 	nextNonWhiteByte(p.scanner)
 	p.scanner.UnreadByte()
 
-	trailer,_ := ReadLine(p.scanner)
-	if trailer != "endobj" {
-		panic(errors.New(fmt.Sprintf(`No "endobj" following object "%d %d obj"`,
-			objectNumber.number, objectNumber.generation)))
+	var trailer string
+	if p.mode == DefaultParsing {
+		trailer,_ = readKeywordLine(p.scanner)
+	} else if b,err := nextNonWhiteByte(p.scanner); err == nil && IsAlpha(b) {
+		trailer,_ = scanKeyword(p.scanner, b, p.limits.MaxTokenLength)
+	}
+	if trailer != "endobj" && p.mode != LenientParsing {
+		panic(&SyntaxError{Expected: "endobj", Found: trailer, Err: missingEndobj})
 	}
 	return object,err
 }
 
+// scanObjectHeader() reads an "N G obj" header as a sequence of tokens
+// so that the object may follow "obj" on the same line.  In strict
+// mode the keyword must be "obj".
+func (p *Parser) scanObjectHeader() (index uint32, generation uint16, keyword string) {
+	var numbers [2]int
+	for i := range numbers {
+		b,err := nextNonWhiteByte(p.scanner)
+		if err != nil || !IsDigit(b) {
+			panic(&SyntaxError{Expected: "object header", Err: invalidObjectHeader})
+		}
+		n,ok := scanNumeric(p.scanner, b, p.limits.MaxTokenLength).(*IntNumeric)
+		if !ok {
+			panic(&SyntaxError{Expected: "integer", Err: invalidObjectHeader})
+		}
+		numbers[i] = n.Value()
+	}
+	b,err := nextNonWhiteByte(p.scanner)
+	if err == nil && IsAlpha(b) {
+		keyword,_ = scanKeyword(p.scanner, b, p.limits.MaxTokenLength)
+	}
+	if p.mode == StrictParsing && keyword != "obj" {
+		panic(&SyntaxError{Expected: "obj", Found: keyword, Err: invalidObjectHeader})
+	}
+	return uint32(numbers[0]), uint16(numbers[1]), keyword
+}
+
 
 func (p *Parser) GetContext() []byte {
 	return p.scanner.GetHistory()
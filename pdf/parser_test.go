@@ -2,8 +2,12 @@ package pdf_test
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
+	"io/ioutil"
+	"math"
 	"github.com/mawicks/PDFiG/pdf"
+	"reflect"
 	"strings"
 	"testing" )
 
@@ -65,6 +69,8 @@ func TestParser (t *testing.T) {
 	testParse ("[ 1 % Ignore me \r 2 ]", "[1 2]")
 	testParse ("[ 1 % Ignore me \n\r 2 ]", "[1 2]")
 	testParse ("[ 1 \n\r 2 ]", "[1 2]")
+	testParse ("[/a\n]", "[/a]")
+	testParse ("<<\n  /Type /Catalog\n  /Kids [/a ]\n>>", "<</Type /Catalog /Kids [/a]>>")
 
 	// Parsing sequences of integers is tricky because of the
 	// possibility that one of them ends in "R", e.g., "1 0 R".
@@ -101,3 +107,471 @@ func TestParser (t *testing.T) {
 
 }
 
+func TestParsingModes(t *testing.T) {
+	scan := func(mode pdf.ParsingMode, source string) (pdf.Object, error) {
+		parser := pdf.NewParser(strings.NewReader(source))
+		parser.SetMode(mode)
+		return parser.ScanIndirect(pdf.NewObjectNumber(4,0), mockFile)
+	}
+	testLenient := func(source string, expected string) {
+		o,err := scan(pdf.LenientParsing, source)
+		if err != nil {
+			t.Errorf(`Lenient ScanIndirect() of "%s" returned error: %v`, source, err)
+			return
+		}
+		checkObject(t, fmt.Sprintf(`Lenient scan of "%s"`, pdf.AsciiFromBytes([]byte(source))), o, mockFile, expected)
+	}
+	testStrictFail := func(source string) {
+		if _,err := scan(pdf.StrictParsing, source); err == nil {
+			t.Errorf(`Strict ScanIndirect() of "%s" did NOT return an error`, source)
+		}
+	}
+
+	valid := "4 0 obj\n<</Length 5>>\nstream\nabcde\nendstream\nendobj\n"
+	for _,mode := range []pdf.ParsingMode{pdf.DefaultParsing, pdf.StrictParsing, pdf.LenientParsing} {
+		if _,err := scan(mode, valid); err != nil {
+			t.Errorf(`ScanIndirect() in mode %d returned error: %v`, mode, err)
+		}
+	}
+
+	// Missing white space, no "endobj"
+	testLenient("4 0 obj<</A 1>>", "<</A 1>>")
+	testLenient("4 0 obj[1 2]endobj", "[1 2]")
+	// Wrong /Length
+	testLenient("4 0 obj\n<</Length 3>>\nstream\nabcde\nendstream\nendobj", "<</Length 5>>\nstream\nabcde\nendstream")
+	testLenient("4 0 obj\n<</Length 50>>\nstream\nabcde\nendstream\nendobj", "<</Length 5>>\nstream\nabcde\nendstream")
+	testLenient("4 0 obj\n<<>>\nstream\nabcde\nendstream\nendobj", "<</Length 5>>\nstream\nabcde\nendstream")
+
+	// Stream data beginning with CR, as encrypted data may, isn't
+	// part of the end of line that follows "stream".
+	for _,mode := range []pdf.ParsingMode{pdf.DefaultParsing, pdf.StrictParsing, pdf.LenientParsing} {
+		for _,eol := range []string{"\n", "\r\n"} {
+			source := "4 0 obj\n<</Length 3>>\nstream" + eol + "\rab\nendstream\nendobj\n"
+			o,err := scan(mode, source)
+			if err != nil {
+				t.Errorf(`ScanIndirect() of "%s" in mode %d returned error: %v`, pdf.AsciiFromBytes([]byte(source)), mode, err)
+				continue
+			}
+			if s,ok := o.(pdf.ProtectedStream); !ok {
+				t.Errorf(`ScanIndirect() of "%s" in mode %d returned %v; expected a stream`, pdf.AsciiFromBytes([]byte(source)), mode, o)
+			} else if contents,_ := ioutil.ReadAll(s.Reader()); string(contents) != "\rab" {
+				t.Errorf(`Stream "%s" in mode %d has contents "%s"; expected "\rab"`, pdf.AsciiFromBytes([]byte(source)), mode, pdf.AsciiFromBytes(contents))
+			}
+		}
+	}
+
+	testStrictFail("4 0 obj\n<</Length 3>>\nstream\nabcde\nendstream\nendobj")
+	testStrictFail("4 0 obj\n<<>>\nstream\nabcde\nendstream\nendobj")
+	testStrictFail("4 0 obj\n<</A 1 /A 2>>\nendobj")
+	testStrictFail("4 0 obj\n100\n")
+	testStrictFail("4 0 ob\n100\nendobj")
+}
+
+func TestErrors(t *testing.T) {
+	parser := pdf.NewParser(strings.NewReader("4 0 obj\n<</A 1 /A 2>>\nendobj"))
+	parser.SetMode(pdf.StrictParsing)
+	parser.SetOffset(100)
+	_,err := parser.ScanIndirect(pdf.NewObjectNumber(4,0), mockFile)
+	var syntaxError *pdf.SyntaxError
+	if !errors.As(err, &syntaxError) || !errors.Is(err, &pdf.SyntaxError{}) {
+		t.Fatalf(`Duplicate key returned %v rather than a SyntaxError`, err)
+	}
+	if syntaxError.Offset != 117 || syntaxError.Found != "/A" {
+		t.Errorf(`SyntaxError has offset %d and found "%s"; expected 117 and "/A"`, syntaxError.Offset, syntaxError.Found)
+	}
+	if errors.Is(err, &pdf.XrefError{}) {
+		t.Error(`SyntaxError matches XrefError`)
+	}
+
+	stream := pdf.NewStreamFromContents(pdf.NewDictionary(), []byte("not compressed"), nil)
+	stream.Add("Filter", pdf.NewName("FlateDecode"))
+	_,err = ioutil.ReadAll(stream.Reader())
+	var filterError *pdf.FilterError
+	if !errors.As(err, &filterError) || filterError.Filter != "FlateDecode" {
+		t.Errorf(`Corrupt stream returned %v rather than a FilterError`, err)
+	}
+
+	if !errors.Is(pdf.ErrIncorrectPassword, &pdf.EncryptionError{}) {
+		t.Error(`ErrIncorrectPassword isn't an EncryptionError`)
+	}
+}
+
+func TestContentParser(t *testing.T) {
+	content := "q 1 0 0 RG 0.5 0 0 0.5 72 72 cm /F1 12 Tf [(A)-250(B)] TJ " +
+		"BI /W 2 /H 1 /BPC 8 /CS /G ID \x00\xff\nEI Q % comment\nT* 1 2 3"
+	operations,err := pdf.ParseContent(strings.NewReader(content))
+	if err != nil {
+		t.Fatalf(`ParseContent() failed: %v`, err)
+	}
+	operators := make([]string, len(operations))
+	for i,op := range operations {
+		operators[i] = op.Operator
+	}
+	if s := strings.Join(operators, " "); s != "q RG cm Tf TJ BI Q T*" {
+		t.Errorf(`ParseContent() produced operators "%s"`, s)
+	}
+	if n := len(operations[1].Operands); n != 3 {
+		t.Errorf(`RG has %d operands; expected 3`, n)
+	}
+	if data,ok := operations[5].Operands[1].(pdf.ProtectString); !ok || !bytes.Equal(data.Bytes(), []byte{0, 0xff}) {
+		t.Errorf(`Inline image has operands %v`, operations[5].Operands)
+	}
+
+	// Drop the inline image and keep everything else.
+	var output bytes.Buffer
+	err = pdf.TransformContent(strings.NewReader(content), &output, func(op pdf.Operation) []pdf.Operation {
+		if op.Operator == "BI" {
+			return nil
+		}
+		return []pdf.Operation{op}
+	})
+	if err != nil {
+		t.Errorf(`TransformContent() failed: %v`, err)
+	}
+	expected := "q\n1 0 0 RG\n0.5 0 0 0.5 72 72 cm\n/F1 12 Tf\n[(A) -250 (B)] TJ\nQ\nT*\n"
+	if output.String() != expected {
+		t.Errorf(`TransformContent() produced "%s"; expected "%s"`, output.String(), expected)
+	}
+
+	reparsed,err := pdf.ParseContent(&output)
+	if err != nil || len(reparsed) != 7 {
+		t.Errorf(`Serialized content parsed as %v (err=%v)`, reparsed, err)
+	}
+}
+
+func TestInlineImages(t *testing.T) {
+	// The first image is unfiltered, so its length is known from
+	// its dimensions even though its data contains " EI ".  The
+	// second is filtered, and the EI inside its data is followed by
+	// binary data rather than operators.
+	content := "BI /W 4 /H 1 /BPC 8 /CS /G ID  EI  EI\n" +
+		"BI /W 8 /H 1 /BPC 8 /CS /G /F /Fl ID x\n EI \x00\x9c\x01\x02\nEI Q\n"
+	operations,err := pdf.ParseContent(strings.NewReader(content))
+	if err != nil || len(operations) != 3 {
+		t.Fatalf(`ParseContent() returned %v (err=%v)`, operations, err)
+	}
+	if data := operations[0].Operands[1].(pdf.ProtectString).Bytes(); string(data) != " EI " {
+		t.Errorf(`Unfiltered inline image has data %q`, data)
+	}
+	if data := operations[1].Operands[1].(pdf.ProtectString).Bytes(); string(data) != "x\n EI \x00\x9c\x01\x02" {
+		t.Errorf(`Filtered inline image has data %q`, data)
+	}
+	if operations[2].Operator != "Q" {
+		t.Errorf(`Inline images are followed by %q`, operations[2].Operator)
+	}
+
+	// Conversion to an XObject expands the abbreviations, and
+	// conversion back restores them.
+	xobject,err := pdf.InlineImageToXObject(operations[1])
+	if err != nil {
+		t.Fatalf(`InlineImageToXObject() failed: %v`, err)
+	}
+	dictionary := xobject.Dictionary()
+	if cs,_ := dictionary.GetName("ColorSpace"); cs != "DeviceGray" {
+		t.Errorf(`XObject has color space %q`, cs)
+	}
+	if filter,_ := dictionary.GetName("Filter"); filter != "FlateDecode" {
+		t.Errorf(`XObject has filter %q`, filter)
+	}
+	inline,err := pdf.XObjectToInlineImage(xobject)
+	if err != nil {
+		t.Fatalf(`XObjectToInlineImage() failed: %v`, err)
+	}
+
+	// The serializer gives the length of data that contains " EI ".
+	var output bytes.Buffer
+	serializer := pdf.NewContentSerializer(&output)
+	serializer.Write(inline)
+	serializer.Write(pdf.Operation{Operator: "Q"})
+	expected := "BI /W 8 /H 1 /BPC 8 /CS /G /F /Fl /L 10 ID x\n EI \x00\x9c\x01\x02\nEI\nQ\n"
+	if output.String() != expected {
+		t.Errorf(`Serialized inline image as %q; expected %q`, output.String(), expected)
+	}
+	reparsed,err := pdf.ParseContent(&output)
+	if err != nil || len(reparsed) != 2 || !bytes.Equal(reparsed[0].Operands[1].(pdf.ProtectString).Bytes(), []byte("x\n EI \x00\x9c\x01\x02")) {
+		t.Errorf(`Serialized inline image parsed as %v (err=%v)`, reparsed, err)
+	}
+
+	xobject.Add("SMask", pdf.NewNull())
+	if _,err := pdf.XObjectToInlineImage(xobject); err == nil {
+		t.Error(`XObjectToInlineImage() accepted an image with a soft mask`)
+	}
+}
+
+func TestCMap(t *testing.T) {
+	// Single-byte ASCII and two-byte codes, as in Shift-JIS.
+	cmap,err := pdf.ParseCMap(strings.NewReader(
+		"/CIDInit /ProcSet findresource begin 12 dict begin begincmap\n" +
+		"/CMapName /Test-H def /WMode 0 def\n" +
+		"2 begincodespacerange <00> <80> <8140> <9ffc> endcodespacerange\n" +
+		"1 begincidrange <20> <7e> 1 endcidrange\n" +
+		"1 begincidrange <8140> <817e> 633 endcidrange\n" +
+		"1 begincidchar <8150> 700 endcidchar\n" +
+		"endcmap CMapName currentdict /CMap defineresource pop end end"))
+	if err != nil {
+		t.Fatalf(`ParseCMap() failed: %v`, err)
+	}
+	if cmap.Name != "Test-H" || cmap.Vertical {
+		t.Errorf(`CMap is named "%s" (vertical=%v)`, cmap.Name, cmap.Vertical)
+	}
+	codes := cmap.Codes([]byte("A\x81\x41\x81\x50"))
+	expected := []pdf.CharCode{{Code: 0x41, Length: 1}, {Code: 0x8141, Length: 2}, {Code: 0x8150, Length: 2}}
+	if !reflect.DeepEqual(codes, expected) {
+		t.Fatalf(`Codes() returned %v; expected %v`, codes, expected)
+	}
+	for i,cid := range []uint32{34, 634, 700} {
+		if c := cmap.CID(codes[i]); c != cid {
+			t.Errorf(`CID(%v) returned %d; expected %d`, codes[i], c, cid)
+		}
+	}
+	if c := cmap.CID(pdf.CharCode{Code: 0x41, Length: 2}); c != 0 {
+		t.Errorf(`Two-byte code 0x0041 mapped to CID %d`, c)
+	}
+
+	// usecmap inherits the codespace and mappings of Identity-H.
+	cmap,err = pdf.ParseCMap(strings.NewReader("/Identity-H usecmap 1 begincidchar <0041> 7 endcidchar"))
+	if err != nil {
+		t.Fatalf(`ParseCMap() failed: %v`, err)
+	}
+	if c := cmap.CID(pdf.CharCode{Code: 0x41, Length: 2}); c != 7 {
+		t.Errorf(`CID(0x0041) returned %d`, c)
+	}
+	if codes := cmap.Codes([]byte{1, 2}); len(codes) != 1 || cmap.CID(codes[0]) != 0x102 {
+		t.Errorf(`Inherited mapping returned %v`, codes)
+	}
+
+	utf16 := pdf.PredefinedCMap("UniJIS-UTF16-H")
+	if utf16 == nil {
+		t.Fatal(`UniJIS-UTF16-H is not built in`)
+	}
+	var text string
+	for _,code := range utf16.Codes([]byte("\x30\x42\xd8\x3d\xde\x00")) {
+		s,_ := utf16.Unicode(code)
+		text += s
+	}
+	if text != "あ\U0001f600" {
+		t.Errorf(`UniJIS-UTF16-H decoded "%s"`, text)
+	}
+	if pdf.PredefinedCMap("90ms-RKSJ-H") != nil {
+		t.Error(`Unregistered CMap 90ms-RKSJ-H was found`)
+	}
+}
+
+func TestParserLimits(t *testing.T) {
+	for _,c := range []struct {
+		name, input string
+		// operand is true if the input is also invalid as a
+		// content stream operand.
+		operand bool
+	}{
+		{"deep nesting", strings.Repeat("[", 100000), true},
+		{"deep dictionaries", strings.Repeat("<</A ", 100000), true},
+		{"huge number", strings.Repeat("9", 100000), true},
+		{"long name", "/" + strings.Repeat("n", 100000), true},
+		{"unterminated string", "(" + strings.Repeat("(", 100000), true},
+		{"reference without a file", "[1 0 R]", false},
+		{"trailing input", "1 2", false} } {
+		if _,err := pdf.ParseObject([]byte(c.input)); !errors.Is(err, &pdf.SyntaxError{}) {
+			t.Errorf(`ParseObject() of %s returned %v rather than a SyntaxError`, c.name, err)
+		}
+		if _,err := pdf.ParseContentStream([]byte(c.input + " Tj")); c.operand && err == nil {
+			t.Errorf(`ParseContentStream() of %s succeeded`, c.name)
+		}
+	}
+
+	nested := strings.Repeat("[", 10) + strings.Repeat("]", 10)
+	if _,err := pdf.ParseObject([]byte(nested)); err != nil {
+		t.Errorf(`ParseObject() of %s failed: %v`, nested, err)
+	}
+	parser := pdf.NewParser(strings.NewReader(nested))
+	parser.SetLimits(pdf.ParserLimits{MaxDepth: 5})
+	if _,err := parser.Scan(); err == nil {
+		t.Errorf(`Scan() of %s succeeded with MaxDepth 5`, nested)
+	}
+	parser = pdf.NewParser(strings.NewReader("(" + strings.Repeat("x", 1000) + ")"))
+	parser.SetLimits(pdf.ParserLimits{MaxStringLength: 100})
+	if _,err := parser.Scan(); err == nil {
+		t.Errorf(`Scan() of a 1000-byte string succeeded with MaxStringLength 100`)
+	}
+
+	// A /Length far beyond the data must not be allocated.
+	if _,err := pdf.ParseObject([]byte("<</Length 2000000000>>\nstream\nabc\nendstream")); err != nil {
+		t.Errorf(`ParseObject() of a stream with a bogus /Length failed: %v`, err)
+	}
+
+	// Huge numbers are limited to values that can be written
+	// and read back rather than becoming +Inf or saturating.
+	for source,expected := range map[string]float64{
+		"700000000000000000000000000000000000000.": math.MaxFloat32,
+		"-700000000000000000000000000000000000000": -math.MaxFloat32,
+		"5000000000": 5000000000 } {
+		o,err := pdf.ParseObject([]byte(source))
+		if err != nil {
+			t.Errorf(`ParseObject("%s") failed: %v`, source, err)
+			continue
+		}
+		n,ok := o.(*pdf.RealNumeric)
+		if !ok || float64(n.Value()) != float64(float32(expected)) {
+			t.Errorf(`ParseObject("%s") returned %v; expected the real %v`, source, toString(o), float32(expected))
+			continue
+		}
+		if _,err := pdf.ParseObject([]byte(toString(o))); err != nil {
+			t.Errorf(`Serialization "%s" of "%s" couldn't be parsed: %v`, toString(o), source, err)
+		}
+	}
+	for _,v := range []float64{math.Inf(1), math.Inf(-1), math.NaN()} {
+		for _,o := range []pdf.Object{pdf.NewNumeric(v), pdf.NewRealNumeric(float32(v))} {
+			if _,err := pdf.ParseObject([]byte(toString(o))); err != nil {
+				t.Errorf(`Serialization "%s" of %v couldn't be parsed: %v`, toString(o), v, err)
+			}
+		}
+	}
+}
+
+func TestParseObject(t *testing.T) {
+	for source,expected := range map[string]string{
+		"[1 2]": "[1 2]",
+		"[1 2 0 R 3]": "[1 2 0 R 3]",
+		"<</A [1 2]>>": "<</A [1 2]>>" } {
+		object,err := pdf.ParseObject([]byte(source), mockFile)
+		if err != nil {
+			t.Errorf(`ParseObject(%q) failed: %v`, source, err)
+		} else if toString(object, mockFile) != expected {
+			t.Errorf(`ParseObject(%q) returned %q; expected %q`, source, toString(object, mockFile), expected)
+		}
+	}
+
+	// Input ending after two integers must be reported as
+	// unterminated rather than reading the second one again.
+	for _,source := range []string{"[0 0", "<</A [1 2", "[1 2 0"} {
+		if _,err := pdf.ParseObject([]byte(source), mockFile); !errors.Is(err, &pdf.SyntaxError{}) {
+			t.Errorf(`ParseObject(%q) returned %v rather than a SyntaxError`, source, err)
+		}
+	}
+}
+
+func TestComments(t *testing.T) {
+	for source,expected := range map[string]string{
+		"[1%c\n2]": "[1 2]",
+		"<</A%c\n(%not a comment)%c\r>>": "<</A (%not a comment)>>",
+		"[1%c\n0%c\nR]": "[1 0 R]",
+		"% leading\n[1] % trailing": "[1]",
+		"<</Length 1>> % c\nstream\na\nendstream": "<</Length 1>>\nstream\na\nendstream" } {
+		object,err := pdf.ParseObject([]byte(source), mockFile)
+		if err != nil {
+			t.Errorf(`ParseObject(%q) failed: %v`, source, err)
+			continue
+		}
+		checkObject(t, fmt.Sprintf(`ParseObject(%q)`, source), object, mockFile, expected)
+	}
+
+	for _,source := range []string{
+		"4 0 obj%c\n100\nendobj%c\n",
+		"4 %c\n0 obj 100 endobj",
+		"4 0 obj\n<</Length 1>>\nstream\na\nendstream % c\nendobj",
+		"4 0 obj\n<</Length 1>>\nstream\na\nendstream\n%c\nendobj % c" } {
+		for _,mode := range []pdf.ParsingMode{pdf.DefaultParsing, pdf.StrictParsing, pdf.LenientParsing} {
+			parser := pdf.NewParser(strings.NewReader(source))
+			parser.SetMode(mode)
+			if _,err := parser.ScanIndirect(pdf.NewObjectNumber(4,0), mockFile); err != nil {
+				t.Errorf(`ScanIndirect(%q) in mode %d failed: %v`, source, mode, err)
+			}
+		}
+	}
+
+	section := "xref % c\n% c\n0 1 % c\n0000000000 65535 f \n% c\ntrailer % c\n<</Size 1 % c\n>>\n"
+	if entries,_,err := pdf.ParseXref([]byte(section)); err != nil || len(entries) != 1 {
+		t.Errorf(`ParseXref() of a section with comments returned %v, %v`, entries, err)
+	}
+
+	content := "% first\nq 1 %among operands\n0 0 RG\n% before Q\r\nQ %last"
+	cp := pdf.NewContentParser(strings.NewReader(content))
+	cp.KeepComments()
+	var output bytes.Buffer
+	cs := pdf.NewContentSerializer(&output)
+	for {
+		op,err := cp.Next()
+		if err != nil {
+			break
+		}
+		cs.Write(op)
+	}
+	expected := "% first\nq\n1 0 0 RG\n%among operands\n% before Q\nQ\n%last\n"
+	if output.String() != expected {
+		t.Errorf(`Content with comments was written as %q; expected %q`, output.String(), expected)
+	}
+}
+
+func TestParseXref(t *testing.T) {
+	section := "xref\n0 3\n0000000000 65535 f \n0000000015 00000 n \n0000000074 00002 n \n" +
+		"trailer\n<</Size 3 /Root 1 0 R>>\n"
+	entries,trailer,err := pdf.ParseXref([]byte(section))
+	if err != nil {
+		t.Fatalf(`ParseXref() failed: %v`, err)
+	}
+	expected := map[uint32]pdf.XrefEntry{0: {0, 65535, false}, 1: {15, 0, true}, 2: {74, 2, true}}
+	if !reflect.DeepEqual(entries, expected) {
+		t.Errorf(`ParseXref() returned %v; expected %v`, entries, expected)
+	}
+	if size,_ := trailer.GetInt("Size"); size != 3 {
+		t.Errorf(`ParseXref() returned trailer %v`, trailer)
+	}
+
+	for _,damaged := range []string{
+		"",
+		"xref\n0 4000000000\n",
+		"xref\n4294967295 4294967295\n",
+		"xref\n0 1\n0000000000 65535 x \ntrailer\n<<>>\n",
+		"xref\n0 1\n0000000000 65535 f \ntrailer\n[]\n" } {
+		if _,_,err := pdf.ParseXref([]byte(damaged)); !errors.Is(err, &pdf.XrefError{}) {
+			t.Errorf(`ParseXref(%q) returned %v rather than an XrefError`, damaged, err)
+		}
+	}
+}
+
+// The fuzz targets below check only that parsing terminates without
+// panicking and, for objects, that what was parsed can be written and
+// read back.  Run them with, e.g., "go test -fuzz FuzzParseObject".
+
+func FuzzParseObject(f *testing.F) {
+	for _,seed := range []string{
+		"<</Type /Page /MediaBox [0 0 612 792] /Parent 3 0 R>>",
+		"(a (nested) string\\051 with \\n escapes)",
+		"<48656C6C6F>",
+		"[1 2.5 -.5 +3 true false null /N#20ame]",
+		"<</Length 5>>\nstream\nhello\nendstream",
+		"% comment\n42" } {
+		f.Add([]byte(seed))
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		object,err := pdf.ParseObject(data, mockFile)
+		if err != nil {
+			return
+		}
+		var b bytes.Buffer
+		object.Serialize(&b, mockFile)
+		if _,err := pdf.ParseObject(b.Bytes(), mockFile); err != nil {
+			t.Errorf(`%q parsed but its serialization %q didn't: %v`, data, b.Bytes(), err)
+		}
+	})
+}
+
+func FuzzParseContentStream(f *testing.F) {
+	for _,seed := range []string{
+		"q 1 0 0 RG 0.5 0 0 0.5 72 72 cm /F1 12 Tf [(A)-250(B)] TJ Q",
+		"BI /W 2 /H 1 /BPC 8 /CS /G ID \x00\xff\nEI",
+		"/Span <</MCID 0>> BDC (x) Tj EMC" } {
+		f.Add([]byte(seed))
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		pdf.ParseContentStream(data)
+	})
+}
+
+func FuzzParseXref(f *testing.F) {
+	f.Add([]byte("xref\n0 2\n0000000000 65535 f \n0000000015 00000 n \ntrailer\n<</Size 2 /Root 1 0 R>>\n"))
+	f.Add([]byte("xref\n0 1\n0000000000 65535 f\r\n3 1\n0000000200 00001 n\r\ntrailer <</Prev 100>>"))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		pdf.ParseXref(data)
+	})
+}
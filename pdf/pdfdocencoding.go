@@ -1,9 +1,16 @@
 package pdf
 
-import "fmt"
+import (
+	"fmt"
+	"unicode/utf16"
+	"unicode/utf8" )
 
 var unicodeToPDFDoc map[rune]byte
 
+// pdfDocToUnicode is the inverse of unicodeToPDFDoc.  Bytes that
+// PDFDocEncoding leaves undefined map to U+FFFD.
+var pdfDocToUnicode [256]rune
+
 func init() {
 	var mappings []struct { rune; byte } =  []struct {rune; byte}  {
 		{'\u0000', 0x00}, {'\u0001', 0x00}, {'\u0002', 0x00}, {'\u0003', 0x00},
@@ -39,6 +46,17 @@ func init() {
 			unicodeToPDFDoc[rune(v.byte)] = 0x00
 		}
 	}
+
+	for i := range pdfDocToUnicode {
+		pdfDocToUnicode[i] = rune(i)
+	}
+	for r,b := range unicodeToPDFDoc {
+		if b != 0x00 {
+			pdfDocToUnicode[b] = r
+		} else if r < 0x100 && pdfDocToUnicode[r] == r {
+			pdfDocToUnicode[r] = utf8.RuneError
+		}
+	}
 }
 
 func PDFDocEncoding (s []rune) ([]byte,bool) {
@@ -61,4 +79,24 @@ func PDFDocEncoding (s []rune) ([]byte,bool) {
 		}
 	}
 	return result,ok
-}
\ No newline at end of file
+}
+// DecodeTextString() converts the bytes of a PDF text string, which
+// are either PDFDocEncoding or UTF-16BE (or, in PDF 2.0, UTF-8)
+// preceded by a byte order mark, to a Go string.
+func DecodeTextString(b []byte) string {
+	switch {
+	case len(b) >= 2 && b[0] == 0xfe && b[1] == 0xff:
+		codes := make([]uint16, 0, len(b)/2-1)
+		for i:=2; i+1<len(b); i+=2 {
+			codes = append(codes, uint16(b[i])<<8 | uint16(b[i+1]))
+		}
+		return string(utf16.Decode(codes))
+	case len(b) >= 3 && b[0] == 0xef && b[1] == 0xbb && b[2] == 0xbf:
+		return string(b[3:])
+	}
+	runes := make([]rune, len(b))
+	for i,c := range b {
+		runes[i] = pdfDocToUnicode[c]
+	}
+	return string(runes)
+}
@@ -0,0 +1,88 @@
+package pdf
+
+// Permissions describes the operations that a user who has opened an
+// encrypted document with the user password is allowed to perform.
+// It is used both to specify the permissions of a file being
+// encrypted (see WithEncryption()) and to report the permissions of
+// an opened file (see File.Permissions()).  Enforcement is the
+// responsibility of the PDF reader.
+type Permissions struct {
+	// Print allows printing, possibly at degraded quality unless
+	// HighResolutionPrint is also set.
+	Print bool
+	// Modify allows changing the document other than by the
+	// operations controlled by Annotate, FillForms, and Assemble.
+	Modify bool
+	// Copy allows copying or otherwise extracting text and
+	// graphics.
+	Copy bool
+	// Annotate allows adding or modifying annotations and, if
+	// Modify is also set, creating or modifying form fields.
+	Annotate bool
+	// FillForms allows filling in existing form fields, even if
+	// Annotate is not set.
+	FillForms bool
+	// ExtractForAccessibility allows extraction of text and
+	// graphics in support of accessibility.
+	ExtractForAccessibility bool
+	// Assemble allows inserting, rotating, and deleting pages and
+	// creating bookmarks and thumbnails, even if Modify is not
+	// set.
+	Assemble bool
+	// HighResolutionPrint allows printing to a faithful
+	// representation of the document.
+	HighResolutionPrint bool
+}
+
+// Bit positions (numbered from 1 as in the PDF specification) of the
+// permission flags in the /P entry of an /Encrypt dictionary.
+const (
+	permissionPrint = 1 << (3-1)
+	permissionModify = 1 << (4-1)
+	permissionCopy = 1 << (5-1)
+	permissionAnnotate = 1 << (6-1)
+	permissionFillForms = 1 << (9-1)
+	permissionExtractForAccessibility = 1 << (10-1)
+	permissionAssemble = 1 << (11-1)
+	permissionHighResolutionPrint = 1 << (12-1)
+
+	// Bits 7, 8, and 13-32 are reserved and must be 1.
+	permissionReserved = ^int32(0) &^ 0xf3f )
+
+// AllPermissions() returns Permissions granting every operation.
+func AllPermissions() Permissions {
+	return Permissions{true, true, true, true, true, true, true, true}
+}
+
+// permissionsFromBits() decodes the /P entry of an /Encrypt dictionary.
+func permissionsFromBits(p int32) Permissions {
+	return Permissions{
+		Print: p & permissionPrint != 0,
+		Modify: p & permissionModify != 0,
+		Copy: p & permissionCopy != 0,
+		Annotate: p & permissionAnnotate != 0,
+		FillForms: p & permissionFillForms != 0,
+		ExtractForAccessibility: p & permissionExtractForAccessibility != 0,
+		Assemble: p & permissionAssemble != 0,
+		HighResolutionPrint: p & permissionHighResolutionPrint != 0}
+}
+
+// bits() encodes the permissions as the /P entry of an /Encrypt
+// dictionary.
+func (p Permissions) bits() int32 {
+	result := permissionReserved
+	set := func(allowed bool, bit int32) {
+		if allowed {
+			result |= bit
+		}
+	}
+	set(p.Print, permissionPrint)
+	set(p.Modify, permissionModify)
+	set(p.Copy, permissionCopy)
+	set(p.Annotate, permissionAnnotate)
+	set(p.FillForms, permissionFillForms)
+	set(p.ExtractForAccessibility, permissionExtractForAccessibility)
+	set(p.Assemble, permissionAssemble)
+	set(p.HighResolutionPrint, permissionHighResolutionPrint)
+	return result
+}
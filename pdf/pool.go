@@ -0,0 +1,98 @@
+package pdf
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"io"
+	"sync" )
+
+// The pools below hold the scratch state of the parser and the writer
+// so that servers generating or reading many documents reuse it
+// rather than allocating it once per object or per stream.  A
+// compressor alone allocates about a megabyte, so without the pools
+// the garbage produced grows with the number of streams in a
+// document.
+
+// flateWriters holds zlib writers for each compression level from
+// zlib.HuffmanOnly to zlib.BestCompression.
+var flateWriters [zlib.BestCompression-zlib.HuffmanOnly+1]sync.Pool
+
+// flateReaders holds zlib readers, which implement zlib.Resetter.
+var flateReaders sync.Pool
+
+// objectReaders holds the buffered readers from which objects are
+// parsed.
+var objectReaders = sync.Pool{New: func() interface{} { return bufio.NewReader(nil) }}
+
+// serializationBuffers holds buffers in which objects are serialized
+// while they wait to be written.
+var serializationBuffers = sync.Pool{New: func() interface{} { return new(bytes.Buffer) }}
+
+// maxPooledBuffer is the capacity above which a serialization buffer
+// is left to the garbage collector rather than kept in the pool, so
+// that one large object doesn't keep its memory forever.
+const maxPooledBuffer = 64<<10
+
+// getFlateWriter() returns a zlib writer at level that writes to w.
+func getFlateWriter(w io.Writer, level int) (*zlib.Writer, error) {
+	if level < zlib.HuffmanOnly || level > zlib.BestCompression {
+		return zlib.NewWriterLevel(w, level)
+	}
+	if z,ok := flateWriters[level-zlib.HuffmanOnly].Get().(*zlib.Writer); ok {
+		z.Reset(w)
+		return z, nil
+	}
+	return zlib.NewWriterLevel(w, level)
+}
+
+// putFlateWriter() returns a closed zlib writer created at level to
+// its pool.
+func putFlateWriter(z *zlib.Writer, level int) {
+	if level >= zlib.HuffmanOnly && level <= zlib.BestCompression {
+		z.Reset(nil)
+		flateWriters[level-zlib.HuffmanOnly].Put(z)
+	}
+}
+
+// getFlateReader() returns a zlib reader that reads from r.
+func getFlateReader(r io.Reader) (io.ReadCloser, error) {
+	if z,ok := flateReaders.Get().(io.ReadCloser); ok {
+		if err := z.(zlib.Resetter).Reset(r, nil); err != nil {
+			return nil, err
+		}
+		return z, nil
+	}
+	return zlib.NewReader(r)
+}
+
+func putFlateReader(z io.ReadCloser) {
+	flateReaders.Put(z)
+}
+
+// getObjectReader() returns a buffered reader that reads from r.
+func getObjectReader(r io.Reader) *bufio.Reader {
+	b := objectReaders.Get().(*bufio.Reader)
+	b.Reset(r)
+	return b
+}
+
+func putObjectReader(b *bufio.Reader) {
+	b.Reset(nil)
+	objectReaders.Put(b)
+}
+
+// getSerializationBuffer() returns an empty buffer.
+func getSerializationBuffer() *bytes.Buffer {
+	buffer := serializationBuffers.Get().(*bytes.Buffer)
+	buffer.Reset()
+	return buffer
+}
+
+// putSerialization() returns the memory of a serialization obtained
+// from getSerializationBuffer() to the pool once it has been written.
+func putSerialization(serialization []byte) {
+	if serialization != nil && cap(serialization) <= maxPooledBuffer {
+		serializationBuffers.Put(bytes.NewBuffer(serialization[:0]))
+	}
+}
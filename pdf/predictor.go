@@ -0,0 +1,141 @@
+package pdf
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil" )
+
+var invalidPredictedData = errors.New(`Data does not match predictor parameters`)
+
+// predictorReader reverses the PNG and TIFF predictors that may be
+// applied to data before it is compressed with FlateDecode or
+// LZWDecode.  The whole of the data is decoded on the first call to
+// Read().
+type predictorReader struct {
+	r io.Reader
+	predictor, colors, bitsPerComponent, columns int
+	decoded *bytes.Reader
+	err error
+}
+
+// newPredictorReader() returns a reader that reverses the predictor
+// named in the decode parameters, or r itself if there is none.
+func newPredictorReader(r io.Reader, parameters ProtectedDictionary) io.Reader {
+	if parameters == nil {
+		return r
+	}
+	predictor,_ := parameters.GetInt("Predictor")
+	if predictor <= 1 {
+		return r
+	}
+	pr := &predictorReader{r: r, predictor: predictor, colors: 1, bitsPerComponent: 8, columns: 1}
+	if colors,ok := parameters.GetInt("Colors"); ok && colors > 0 {
+		pr.colors = colors
+	}
+	if bitsPerComponent,ok := parameters.GetInt("BitsPerComponent"); ok && bitsPerComponent > 0 {
+		pr.bitsPerComponent = bitsPerComponent
+	}
+	if columns,ok := parameters.GetInt("Columns"); ok && columns > 0 {
+		pr.columns = columns
+	}
+	return pr
+}
+
+func (pr *predictorReader) Read(p []byte) (int, error) {
+	if pr.decoded == nil && pr.err == nil {
+		var data []byte
+		if data,pr.err = ioutil.ReadAll(pr.r); pr.err == nil {
+			if pr.predictor == 2 {
+				data,pr.err = pr.reverseTIFF(data)
+			} else {
+				data,pr.err = pr.reversePNG(data)
+			}
+		}
+		pr.decoded = bytes.NewReader(data)
+	}
+	if pr.err != nil {
+		return 0, pr.err
+	}
+	return pr.decoded.Read(p)
+}
+
+func (pr *predictorReader) rowBytes() int {
+	return (pr.columns*pr.colors*pr.bitsPerComponent + 7) / 8
+}
+
+// reversePNG() undoes PNG prediction, in which each row is preceded
+// by a byte giving the predictor used for that row.
+func (pr *predictorReader) reversePNG(data []byte) ([]byte, error) {
+	rowBytes := pr.rowBytes()
+	pixelBytes := (pr.colors*pr.bitsPerComponent + 7) / 8
+	result := make([]byte, 0, len(data))
+	previous := make([]byte, rowBytes)
+	for len(data) > 0 {
+		if len(data) < rowBytes+1 {
+			return result, invalidPredictedData
+		}
+		kind, row := data[0], data[1:rowBytes+1]
+		data = data[rowBytes+1:]
+		for i := range row {
+			var left, upperLeft byte
+			if i >= pixelBytes {
+				left = row[i-pixelBytes]
+				upperLeft = previous[i-pixelBytes]
+			}
+			up := previous[i]
+			switch kind {
+			case 0:
+			case 1:
+				row[i] += left
+			case 2:
+				row[i] += up
+			case 3:
+				row[i] += byte((int(left) + int(up)) / 2)
+			case 4:
+				row[i] += paeth(left, up, upperLeft)
+			default:
+				return result, invalidPredictedData
+			}
+		}
+		result = append(result, row...)
+		copy(previous, row)
+	}
+	return result, nil
+}
+
+func paeth(a, b, c byte) byte {
+	p := int(a) + int(b) - int(c)
+	pa, pb, pc := abs(p-int(a)), abs(p-int(b)), abs(p-int(c))
+	switch {
+	case pa <= pb && pa <= pc:
+		return a
+	case pb <= pc:
+		return b
+	}
+	return c
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// reverseTIFF() undoes TIFF predictor 2, in which each component is
+// the difference from the corresponding component of the pixel to
+// its left.  Only 8-bit components are supported.
+func (pr *predictorReader) reverseTIFF(data []byte) ([]byte, error) {
+	if pr.bitsPerComponent != 8 {
+		return data, invalidPredictedData
+	}
+	rowBytes := pr.rowBytes()
+	for start:=0; start+rowBytes<=len(data); start+=rowBytes {
+		row := data[start:start+rowBytes]
+		for i:=pr.colors; i<len(row); i++ {
+			row[i] += row[i-pr.colors]
+		}
+	}
+	return data, nil
+}
@@ -0,0 +1,71 @@
+package pdf
+
+import (
+	"sync/atomic" )
+
+// ProgressObserver receives reports of the progress of long
+// operations, such as parsing, merging, or linearizing huge
+// documents, so that a tool can show a progress bar.  Since objects
+// are written by a separate goroutine and pages may be processed
+// concurrently by Document.ForEachPage(), the methods may be called
+// from any goroutine, and concurrently.  Embed NoProgress in an
+// implementation to ignore some of the reports.
+type ProgressObserver interface {
+	// OnObjectWritten() is called after object o, whose
+	// serialization is size bytes long, is written to the file.
+	OnObjectWritten(o ObjectNumber, size int64)
+
+	// OnPageProcessed() is called after each page is copied by
+	// Document.Append() or Document.ExtractPages(), visited by
+	// Document.ForEachPage(), or placed by Linearize().  done is
+	// the number of pages processed so far by the operation, out
+	// of total.
+	OnPageProcessed(done, total uint)
+
+	// OnBytesRead() is called as a pre-existing file is read.
+	// done is the number of bytes read so far, which may exceed
+	// total, the size of the file, if parts of the file are read
+	// more than once.
+	OnBytesRead(done, total int64)
+}
+
+// NoProgress implements ProgressObserver by ignoring every report.
+type NoProgress struct {}
+
+func (NoProgress) OnObjectWritten(ObjectNumber, int64) {}
+func (NoProgress) OnPageProcessed(uint, uint) {}
+func (NoProgress) OnBytesRead(int64, int64) {}
+
+// WithProgress() returns a FileOption that reports the progress of
+// work on the file and on documents using it to observer.
+func WithProgress(observer ProgressObserver) FileOption {
+	return func(f *file) {
+		f.progress = observer
+	}
+}
+
+// progressReporter is implemented by Files that accept
+// WithProgress().
+type progressReporter interface {
+	progressObserver() ProgressObserver
+}
+
+func (f *file) progressObserver() ProgressObserver {
+	return f.progress
+}
+
+// progressOf() returns the observer of f's progress, which is a
+// NoProgress if no observer was given.
+func progressOf(f File) ProgressObserver {
+	if r,ok := f.(progressReporter); ok && r.progressObserver() != nil {
+		return r.progressObserver()
+	}
+	return NoProgress{}
+}
+
+// bytesRead() reports that n more bytes of the file have been read.
+func (f *file) bytesRead(n int64) {
+	if f.progress != nil {
+		f.progress.OnBytesRead(atomic.AddInt64(&f.totalBytesRead, n), f.originalSize)
+	}
+}
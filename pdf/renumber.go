@@ -0,0 +1,267 @@
+package pdf
+
+import "errors"
+
+var (
+	cannotRenumberEncrypted = errors.New(`Encrypted files cannot be renumbered`)
+	invalidObjectNumbers = errors.New(`Objects cannot be renumbered 0 or beyond Limits.MaxObjects or given the same number`) )
+
+// RenumberObjects() reads the PDF file named input and writes to
+// output the objects reachable from its trailer, as Compact() does,
+// with the numbers that downstream consumers or golden files require.
+// numbers maps the numbers of objects in input to their numbers in
+// output, where every generation is 0.  The remaining objects are
+// given the lowest numbers not in numbers: the catalog first, then the
+// pages in order, and then the other objects in the order in which
+// they are reached from the catalog and the document information
+// dictionary.  An empty mapping therefore numbers the catalog 1 and
+// the pages contiguously from 2.  Entries for objects that aren't
+// reachable are ignored, and numbers that no object is given are left
+// free.  A number of 0, or one beyond the largest object number PDF
+// allows or the Limits.MaxObjects of input, is an error.  If an object
+// can't be read, the error is returned and output is left untouched.
+// Input and output may name the same file.  Options are passed to
+// OpenFile() when reading input.
+func RenumberObjects(input, output string, numbers map[ObjectNumber]uint32, options ...FileOption) error {
+	for _,n := range numbers {
+		if n == 0 || n >= maxXrefSize {
+			return invalidObjectNumbers
+		}
+	}
+	return rewriteFile(input, output, options, nil, func(destination *file, source File) error {
+		return renumber(destination, source, numbers)
+	})
+}
+
+// renumberer collects the objects reachable from the trailer of a
+// file in the order in which RenumberObjects() numbers them.
+type renumberer struct {
+	source File
+	objects map[ObjectNumber]Object
+	visited map[ObjectNumber]bool
+	order []ObjectNumber
+	// err is the first error reading an object.
+	err error
+}
+
+// object() returns the object numbered n in the source file, or a null
+// object if it cannot be read, in which case the error is recorded in
+// r.err.
+func (r *renumberer) object(n ObjectNumber) Object {
+	if o,exists := r.objects[n]; exists {
+		return o
+	}
+	o,err := r.source.Object(n)
+	if err != nil && r.err == nil {
+		r.err = err
+	}
+	if err != nil || o == nil {
+		o = NewNull()
+	}
+	r.objects[n] = o
+	return o
+}
+
+// add() appends the object to which o refers to the order, if o is a
+// reference to an object not yet added, and returns its number.
+func (r *renumberer) add(o Object) (ObjectNumber, bool) {
+	reference,ok := o.(ProtectedIndirect)
+	if !ok || !reference.BoundToFile(r.source) {
+		return ObjectNumber{}, false
+	}
+	n := reference.ObjectNumber(r.source)
+	if r.visited[n] {
+		return n, false
+	}
+	r.visited[n] = true
+	r.order = append(r.order, n)
+	return n, true
+}
+
+// visit() adds the objects reachable from o depth-first.  The /Length
+// of streams isn't followed, since copies of streams are written with
+// their length.
+func (r *renumberer) visit(o Object) {
+	switch t := o.Protect().(type) {
+	case ProtectedIndirect:
+		if n,added := r.add(t); added {
+			r.visit(r.object(n))
+		}
+	case ProtectedStream:
+		d := t.Dictionary()
+		for _,key := range d.Keys() {
+			if key != "Length" {
+				r.visit(d.Get(key))
+			}
+		}
+	case ProtectedDictionary:
+		for _,key := range t.Keys() {
+			r.visit(t.Get(key))
+		}
+	case ProtectedArray:
+		for i:=0; i<t.Size(); i++ {
+			r.visit(t.At(i))
+		}
+	}
+}
+
+// addPages() adds the pages below the page tree node numbered n in
+// order, without visiting their contents.
+func (r *renumberer) addPages(n ObjectNumber, visited map[ObjectNumber]bool) {
+	if visited[n] {
+		return
+	}
+	visited[n] = true
+	node,ok := r.object(n).(ProtectedDictionary)
+	if !ok {
+		return
+	}
+	if node.CheckNameValue("Type", "Page") {
+		r.add(r.source.Indirect(n))
+		return
+	}
+	if kids := node.GetArray("Kids"); kids != nil {
+		for i:=0; i<kids.Size(); i++ {
+			if kid,ok := kids.At(i).(ProtectedIndirect); ok {
+				r.addPages(kid.ObjectNumber(r.source), visited)
+			}
+		}
+	}
+}
+
+// reach() returns the numbers of the objects reachable from root and
+// info, with root first and the pages next.
+func (r *renumberer) reach(root ProtectedIndirect, info Object) []ObjectNumber {
+	r.add(root)
+	if catalog,ok := r.object(root.ObjectNumber(r.source)).(ProtectedDictionary); ok {
+		if pages,ok := catalog.Get("Pages").(ProtectedIndirect); ok {
+			r.addPages(pages.ObjectNumber(r.source), make(map[ObjectNumber]bool))
+		}
+	}
+	// The catalog and pages have been added but their contents
+	// haven't been visited.
+	for _,n := range append([]ObjectNumber(nil), r.order...) {
+		r.visit(r.object(n))
+	}
+	if info != nil {
+		r.visit(info)
+	}
+	return r.order
+}
+
+// renumber() copies the objects reachable from the trailer of source
+// to destination, which must be new, with the numbers described for
+// RenumberObjects(), and closes destination.  destination is abandoned
+// if an error occurs.
+func renumber(destination *file, source File, numbers map[ObjectNumber]uint32) error {
+	trailer := source.Trailer()
+	if trailer.Get("Encrypt") != nil {
+		destination.abandon()
+		return cannotRenumberEncrypted
+	}
+	root,ok := trailer.Get("Root").(ProtectedIndirect)
+	if !ok {
+		destination.abandon()
+		return missingRoot
+	}
+	maxObjects := maxXrefSize
+	if f,ok := source.(*file); ok && f.limits.MaxObjects < maxObjects {
+		maxObjects = f.limits.MaxObjects
+	}
+	for _,n := range numbers {
+		if int64(n) >= int64(maxObjects) {
+			destination.abandon()
+			return invalidObjectNumbers
+		}
+	}
+	r := &renumberer{
+		source: source,
+		objects: make(map[ObjectNumber]Object),
+		visited: make(map[ObjectNumber]bool)}
+	order := r.reach(root, trailer.Get("Info"))
+	// The input may be replaced by the output, so nothing is
+	// written if an object would be lost.
+	if r.err != nil {
+		destination.abandon()
+		return r.err
+	}
+
+	// sources[n] is the object in source that is given number n.
+	// It has room for every object to be numbered below the
+	// largest requested number or after it, and is trimmed to the
+	// largest number given once all are assigned.
+	size := len(order) + 1
+	for _,o := range order {
+		if n,exists := numbers[o]; exists && int(n) >= size {
+			size = int(n) + 1
+		}
+	}
+	sources := make([]ObjectNumber, size)
+	assigned := make(map[ObjectNumber]bool)
+	assign := func(o ObjectNumber, n uint32) bool {
+		if sources[n] != (ObjectNumber{}) {
+			return false
+		}
+		sources[n] = o
+		assigned[o] = true
+		return true
+	}
+	for _,o := range order {
+		if n,exists := numbers[o]; exists && !assign(o, n) {
+			destination.abandon()
+			return invalidObjectNumbers
+		}
+	}
+	next := uint32(1)
+	for _,o := range order {
+		if assigned[o] {
+			continue
+		}
+		for int(next) < len(sources) && sources[next] != (ObjectNumber{}) {
+			next++
+		}
+		assign(o, next)
+	}
+	for len(sources) > 1 && sources[len(sources)-1] == (ObjectNumber{}) {
+		sources = sources[:len(sources)-1]
+	}
+
+	destination.requireVersion(versionOf(source))
+	copier := NewObjectCopier(destination, source)
+	indirects := make([]Indirect, len(sources))
+	for n,o := range sources {
+		if n > 0 && assigned[o] {
+			indirects[n] = newIndirectWithNumber(ObjectNumber{uint32(n), 0}, destination)
+			copier.Translate(o, indirects[n])
+		}
+	}
+	destination.reserveObjectNumbers(indirects)
+	for n,i := range indirects {
+		if i == nil {
+			continue
+		}
+		if err := destination.canceled(); err != nil {
+			destination.abandon()
+			return err
+		}
+		i.Write(copier.Copy(r.object(sources[n])))
+	}
+
+	destination.trailerDictionary.Add("Root", copier.CopyReference(root.ObjectNumber(source)))
+	if info,ok := trailer.Get("Info").(ProtectedIndirect); ok {
+		destination.trailerDictionary.Add("Info", copier.CopyReference(info.ObjectNumber(source)))
+	}
+	if id := trailer.Get("ID"); id != nil {
+		destination.trailerDictionary.Add("ID", id.Clone())
+	}
+	if err := destination.canceled(); err != nil {
+		destination.abandon()
+		return err
+	}
+	if err := copier.Err(); err != nil {
+		destination.abandon()
+		return err
+	}
+	destination.Close()
+	return nil
+}
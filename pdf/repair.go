@@ -0,0 +1,261 @@
+package pdf
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"strconv" )
+
+var (
+	missingStartxref = errors.New(`No startxref found`)
+	xrefLoop = errors.New(`Loop in /Prev chain`)
+	missingRoot = errors.New(`Trailer has no /Root`)
+	catalogNotInXref = errors.New(`Catalog isn't in the xref`)
+	catalogMisplaced = errors.New(`Catalog isn't at its xref offset`) )
+
+// maxXrefSize is one more than the largest object number PDF allows
+// (8,388,607; see Annex C of ISO 32000-1).
+const maxXrefSize = 1<<23
+
+// RepairReport describes a cross-reference table that was rebuilt
+// because the one in a damaged file could not be used.
+type RepairReport struct {
+	// Reason describes what was wrong with the original xref.
+	Reason string
+	// Objects is the number of objects found by scanning the file.
+	Objects int
+	// Duplicates lists the object numbers that were defined
+	// more than once.  The last definition in the file is used.
+	Duplicates []uint32
+	// TrailerFound is true if a trailer dictionary was recovered
+	// from the file.  Otherwise a trailer was constructed whose
+	// /Root is the last catalog found.
+	TrailerFound bool
+}
+
+// RepairReport() returns a description of the repairs made when the
+// document was opened, or nil if its xref was intact.
+func (d *Document) RepairReport() *RepairReport {
+	if f,ok := d.file.(*file); ok {
+		return f.repairReport
+	}
+	return nil
+}
+
+// readXref() reads the chain of xref sections and the trailer of a
+// pre-existing file.  If they are missing or damaged, it returns an
+// XrefError and leaves the xref empty.
+func (f *file) readXref() (problem error) {
+	location := int64(0)
+	defer func() {
+		if r := recover(); r != nil {
+			err,ok := r.(error)
+			if !ok {
+				err = errors.New(fmt.Sprint(r))
+			}
+			problem = err
+		}
+		if problem != nil {
+			problem = &XrefError{location, problem}
+			f.xref.SetSize(0)
+			f.trailerDictionary = nil
+			f.xrefLocation = 0
+			f.revisions = nil
+		}
+	}()
+
+	f.xrefLocation = findXrefLocation(f.file)
+	if f.xrefLocation == 0 {
+		return missingStartxref
+	}
+
+	visited := make(map[int64]bool)
+	var nextXref int
+	location = f.xrefLocation
+	nextXref,f.trailerDictionary = readOneXrefSection(f, f.xrefLocation)
+	// Sections are read newest first.  Since entries are never
+	// overwritten, each object takes its entry from the latest
+	// section that lists it.
+	sections := []xrefSection{{location, f.trailerDictionary}}
+	for ; nextXref != 0; {
+		if err := f.canceled(); err != nil {
+			return err
+		}
+		// A malformed /Prev chain could otherwise loop forever.
+		location = int64(nextXref)
+		if visited[location] {
+			return xrefLoop
+		}
+		if len(visited) >= f.limits.MaxXrefSections-1 {
+			return &LimitError{"MaxXrefSections", int64(f.limits.MaxXrefSections)}
+		}
+		visited[location] = true
+		var trailer Dictionary
+		nextXref,trailer = readOneXrefSection(f, location)
+		sections = append(sections, xrefSection{location, trailer})
+	}
+	location = 0
+	f.setRevisions(sections)
+
+	// The xref is considered intact if the catalog can be found
+	// where it says.
+	root,ok := f.trailerDictionary.Get("Root").(Indirect)
+	if !ok {
+		return missingRoot
+	}
+	o := root.ObjectNumber(f)
+	if uint(o.number) >= f.xref.Size() || *f.xref.At(uint(o.number)) == nil {
+		return catalogNotInXref
+	}
+	if !f.objectHeaderAt((*f.xref.At(uint(o.number))).(*xrefEntry).byteOffset, o) {
+		return catalogMisplaced
+	}
+	return nil
+}
+
+// objectHeaderAt() returns true if the "obj" header for o starts at
+// offset.
+func (f *file) objectHeaderAt(offset uint64, o ObjectNumber) bool {
+	if _,err := f.file.Seek(int64(offset), os.SEEK_SET); err != nil {
+		return false
+	}
+	header := make([]byte, 32)
+	n,_ := f.file.Read(header)
+	match := objectHeader.FindSubmatch(header[:n])
+	return match != nil && bytes.HasPrefix(header, match[0]) &&
+		string(match[1]) == strconv.Itoa(int(o.number)) &&
+		string(match[2]) == strconv.Itoa(int(o.generation))
+}
+
+// objectHeader matches an "N G obj" header.
+var objectHeader = regexp.MustCompile(`(\d{1,10})[\x00\t\n\f\r ]+(\d{1,5})[\x00\t\n\f\r ]+obj\b`)
+
+// reconstructXref() rebuilds the xref of a damaged file by scanning
+// the whole file for object headers.  The trailer is taken from the
+// last "trailer" keyword that is followed by a dictionary.  If there
+// is none, a trailer is constructed around the last catalog found.
+// Every entry is marked dirty so that a complete xref is written if
+// the file is later updated.
+func (f *file) reconstructXref(reason string) {
+	report := &RepairReport{Reason: reason}
+	f.repairReport = report
+	fmt.Fprintf(logger, "Warning: Rebuilding damaged xref (%s)\n", reason)
+
+	f.file.Seek(0, os.SEEK_SET)
+	data,_ := ioutil.ReadAll(f.file)
+	f.bytesRead(int64(len(data)))
+
+	f.xref.SetSize(0)
+	f.xref.PushBack(&xrefEntry{
+		byteOffset: 0,
+		generation: 65535,
+		inUse: false,
+		dirty: true})
+
+	var objects []ObjectNumber
+	duplicate := make(map[uint32]bool)
+	for _,match := range objectHeader.FindAllSubmatchIndex(data, -1) {
+		if f.canceled() != nil {
+			return
+		}
+		// The header must start a token.
+		if match[0] > 0 && !IsWhiteSpace(data[match[0]-1]) && !IsDelimiter(data[match[0]-1]) {
+			continue
+		}
+		number,err1 := strconv.ParseUint(string(data[match[2]:match[3]]), 10, 32)
+		generation,err2 := strconv.ParseUint(string(data[match[4]:match[5]]), 10, 16)
+		if err1 != nil || err2 != nil || number >= uint64(f.limits.MaxObjects) {
+			continue
+		}
+		for f.xref.Size() <= uint(number) {
+			// Entries for missing objects are never reused.
+			f.xref.PushBack(&xrefEntry{generation: 65535, dirty: true})
+		}
+		entry := (*f.xref.At(uint(number))).(*xrefEntry)
+		if entry.inUse && !duplicate[uint32(number)] {
+			duplicate[uint32(number)] = true
+			report.Duplicates = append(report.Duplicates, uint32(number))
+		}
+		if !entry.inUse {
+			report.Objects += 1
+		}
+		*entry = xrefEntry{
+			byteOffset: uint64(match[0]),
+			generation: uint16(generation),
+			inUse: true,
+			dirty: true}
+		objects = append(objects, ObjectNumber{uint32(number), uint16(generation)})
+	}
+
+	for position:=bytes.LastIndex(data, []byte("trailer")); position >= 0; position=bytes.LastIndex(data[:position], []byte("trailer")) {
+		object,err := NewParser(bytes.NewReader(data[position+len("trailer"):])).Scan(f)
+		if trailer,ok := object.(Dictionary); err == nil && ok && trailer.Get("Root") != nil {
+			// The old xref sections can't be trusted.
+			trailer.Remove("Prev")
+			trailer.Remove("XRefStm")
+			f.trailerDictionary = trailer
+			report.TrailerFound = true
+			break
+		}
+	}
+
+	if f.trailerDictionary == nil {
+		f.trailerDictionary = NewDictionary()
+		for i:=len(objects)-1; i>=0; i-- {
+			o := objects[i]
+			entry := (*f.xref.At(uint(o.number))).(*xrefEntry)
+			if entry.generation != o.generation {
+				continue
+			}
+			object,err := NewParser(bytes.NewReader(data[entry.byteOffset:])).ScanIndirect(o, f)
+			if catalog,ok := object.(Dictionary); err == nil && ok && catalog.CheckNameValue("Type", "Catalog") {
+				f.trailerDictionary.Add("Root", f.Indirect(o))
+				break
+			}
+		}
+	}
+	f.trailerDictionary.Add("Size", NewIntNumeric(int(f.xref.Size())))
+}
+
+// nearbyObjectWindow is how far from its xref offset
+// findNearbyObject() looks for an object.
+const nearbyObjectWindow = 256
+
+// findNearbyObject() returns the offset of the "obj" header for o
+// closest to offset, for xref entries that are off by a few bytes.
+func (f *file) findNearbyObject(o ObjectNumber, offset uint64) (uint64, bool) {
+	start := int64(offset) - nearbyObjectWindow
+	if start < 0 {
+		start = 0
+	}
+	data := make([]byte, 2*nearbyObjectWindow)
+	n,_ := io.ReadFull(io.NewSectionReader(f.file, start, int64(len(data))), data)
+	data = data[:n]
+
+	best,found := uint64(0), false
+	for _,match := range objectHeader.FindAllSubmatchIndex(data, -1) {
+		if match[0] > 0 && !IsWhiteSpace(data[match[0]-1]) && !IsDelimiter(data[match[0]-1]) {
+			continue
+		}
+		if string(data[match[2]:match[3]]) != strconv.Itoa(int(o.number)) ||
+			string(data[match[4]:match[5]]) != strconv.Itoa(int(o.generation)) {
+			continue
+		}
+		position := uint64(start) + uint64(match[0])
+		if !found || distance(position, offset) < distance(best, offset) {
+			best,found = position, true
+		}
+	}
+	return best, found
+}
+
+func distance(a, b uint64) uint64 {
+	if a > b {
+		return a - b
+	}
+	return b - a
+}
@@ -0,0 +1,143 @@
+package pdf
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"os" )
+
+var noSuchRevision = errors.New(`No such revision`)
+
+// Revision is one revision of a pre-existing file: the document as
+// originally written or as it stood after one of the incremental
+// updates appended to it.  Since an update only appends, a revision
+// is a prefix of the file.
+type Revision struct {
+	// XrefOffset is the position of the revision's xref section.
+	XrefOffset int64
+	// Length is the length of the prefix of the file that holds the
+	// revision, through its %%EOF marker.
+	Length int64
+	// Trailer is the trailer dictionary of the revision's xref
+	// section.
+	Trailer ProtectedDictionary
+
+	storage io.ReaderAt
+}
+
+// Reader() returns the contents of the file as they were at the
+// revision.  It may be used only until the file is closed.
+func (r Revision) Reader() *io.SectionReader {
+	return io.NewSectionReader(r.storage, 0, r.Length)
+}
+
+// Open() opens the revision as a read-only File, which presents the
+// objects as they were at the revision.  It may be used only until
+// the file from which the revision was obtained is closed.
+func (r Revision) Open(options ...FileOption) (File, error) {
+	f,err := NewFileFromReader(r.Reader(), options...)
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// xrefSection records the location and trailer of one xref section
+// read by readXref().
+type xrefSection struct {
+	location int64
+	trailer Dictionary
+}
+
+// Revisions() returns the revisions of a pre-existing file, oldest
+// first, as found by following the /Prev chain of its xref sections.
+// Changes made since the file was opened are not a revision until
+// they are written by Close().  New files and files whose xref had to
+// be rebuilt have no revisions.
+func (f *file) Revisions() []Revision {
+	return f.revisions
+}
+
+// setRevisions() records the revisions whose xref sections are
+// listed newest first.  An xref section that ends before the one
+// preceding it in the file's history, such as the first-page section
+// of a linearized file, is part of the same revision rather than a
+// new one.
+func (f *file) setRevisions(sections []xrefSection) {
+	f.revisions = nil
+	for i:=len(sections)-1; i>=0; i-- {
+		section := sections[i]
+		length := f.revisionEnd(section.location)
+		if n := len(f.revisions); n > 0 && length <= f.revisions[n-1].Length {
+			continue
+		}
+		f.revisions = append(f.revisions, Revision{
+			XrefOffset: section.location,
+			Length: length,
+			Trailer: section.trailer.Protect().(ProtectedDictionary),
+			storage: f.file})
+	}
+}
+
+// revisionEnd() returns the position just past the end-of-line that
+// follows the first %%EOF marker after the xref section at location,
+// or the end of the file if there is none.
+func (f *file) revisionEnd(location int64) int64 {
+	marker := []byte("%%EOF")
+	r := bufio.NewReader(io.NewSectionReader(f.file, location, f.originalSize-location))
+	position := location
+	matched := 0
+	for {
+		c,err := r.ReadByte()
+		if err != nil {
+			return f.originalSize
+		}
+		position++
+		if c == marker[matched] {
+			matched++
+		} else {
+			matched = 0
+			if c == marker[0] {
+				matched = 1
+			}
+		}
+		if matched == len(marker) {
+			break
+		}
+	}
+	if next,err := r.Peek(2); err == nil && bytes.Equal(next, []byte("\r\n")) {
+		return position + 2
+	}
+	if next,err := r.Peek(1); err == nil && (next[0] == '\r' || next[0] == '\n') {
+		return position + 1
+	}
+	return position
+}
+
+// Revisions() returns the revisions of the file from which the
+// document was read, oldest first.
+func (d *Document) Revisions() []Revision {
+	return d.file.Revisions()
+}
+
+// ExtractRevision() writes the file from which the document was read,
+// exactly as it was at revision n (numbered from 0, the original
+// document), to a new file named filename.  The revision's bytes are
+// copied unchanged, so signatures that covered that revision remain
+// valid.
+func (d *Document) ExtractRevision(filename string, n uint) error {
+	revisions := d.Revisions()
+	if n >= uint(len(revisions)) {
+		return noSuchRevision
+	}
+	out,err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	_,err = io.Copy(out, revisions[n].Reader())
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
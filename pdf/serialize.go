@@ -0,0 +1,115 @@
+package pdf
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strconv")
+
+// SerializeTo() serializes object to w, which may be any io.Writer:
+// a hash, a compressor, or a network connection, for example.  Since
+// Serialize() doesn't report errors, writes are buffered and the
+// first error writing to w is returned once the buffer is flushed.
+func SerializeTo(w io.Writer, object Object, file ...File) error {
+	buffered := bufio.NewWriter(w)
+	object.Serialize(buffered, file...)
+	return buffered.Flush()
+}
+
+// Bytes() returns the serialization of object, as it would be
+// written to file.
+func Bytes(object Object, file ...File) []byte {
+	return AppendBytes(make([]byte, 0, EstimatedSize(object)), object, file...)
+}
+
+// AppendBytes() appends the serialization of object, as it would be
+// written to file, to dst and returns the extended slice.
+func AppendBytes(dst []byte, object Object, file ...File) []byte {
+	buffer := bytes.NewBuffer(dst)
+	object.Serialize(buffer, file...)
+	return buffer.Bytes()
+}
+
+// SerializedString() returns the serialization of object, as it
+// would be written to file, as a string.
+func SerializedString(object Object, file ...File) string {
+	return string(Bytes(object, file...))
+}
+
+// EstimatedSize() returns an estimate of the length of the
+// serialization of object, for preallocating buffers.  The estimate
+// is computed without serializing object or resolving references;
+// it ignores escapes in strings and names and the effect of stream
+// filters, and stream contents not held in memory count as nothing.
+func EstimatedSize(object Object) int {
+	switch t := object.(type) {
+	case nil:
+		return 0
+	case *Null:
+		return 4
+	case Boolean:
+		return 5
+	case *IntNumeric:
+		return len(strconv.Itoa(t.value))
+	case Numeric:
+		return 8
+	case Name:
+		return len(t.String()) + 1
+	case ProtectString:
+		return len(t.Bytes()) + 2
+	case ProtectedIndirect:
+		return 12
+	case ProtectedArray:
+		size := 2
+		for i:=0; i<t.Size(); i++ {
+			size += EstimatedSize(t.At(i)) + 1
+		}
+		return size
+	case ProtectedStream:
+		return EstimatedSize(t.Dictionary()) + len("\nstream\n\nendstream") + estimatedStreamLength(t)
+	case ProtectedDictionary:
+		size := 4
+		t.ForEach(func(key string, value Object) {
+			size += len(key) + 2 + EstimatedSize(value) + 1
+		})
+		return size
+	}
+	return 16
+}
+
+// estimatedStreamLength() returns the length of the contents of s
+// held in memory.
+func estimatedStreamLength(s ProtectedStream) int {
+	if impl,ok := streamImpl(s); ok && impl.source == nil {
+		return impl.buffer.Len()
+	}
+	return 0
+}
+
+// scratch() returns an empty slice to which a short serialization,
+// such as a number, can be appended before it is written to w.  For
+// the bufio.Writer and bytes.Buffer to which objects are normally
+// serialized, it is the unused capacity of w's own buffer, so that
+// formatting numbers doesn't allocate.
+func scratch(w Writer) []byte {
+	if a,ok := w.(interface{ AvailableBuffer() []byte }); ok {
+		return a.AvailableBuffer()
+	}
+	return nil
+}
+
+// writeInt() writes v to w in decimal.
+func writeInt(w Writer, v int64) {
+	w.Write(strconv.AppendInt(scratch(w), v, 10))
+}
+
+// appendPadded() appends v to dst in decimal, padded with zeros to
+// width digits, as in the entries of an xref table.
+func appendPadded(dst []byte, v uint64, width int) []byte {
+	var digits [20]byte
+	formatted := strconv.AppendUint(digits[:0], v, 10)
+	for i:=len(formatted); i<width; i++ {
+		dst = append(dst, '0')
+	}
+	return append(dst, formatted...)
+}
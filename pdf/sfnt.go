@@ -0,0 +1,483 @@
+package pdf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"sort" )
+
+// sfnt is a font in the sfnt container format shared by TrueType and
+// OpenType fonts, split into its tables.
+type sfnt struct {
+	version uint32
+	tables map[string][]byte
+}
+
+var (
+	truncatedFont = errors.New(`Font data is truncated`)
+	notSfnt = errors.New(`Not a TrueType or OpenType font`) )
+
+// parseSfnt() splits an sfnt font, or a WOFF font wrapping one, into
+// its tables.
+func parseSfnt(data []byte) (*sfnt, error) {
+	if len(data) < 12 {
+		return nil, notSfnt
+	}
+	switch string(data[:4]) {
+	case "wOFF":
+		return parseWOFF(data)
+	case "wOF2":
+		return nil, woff2NotSupported
+	}
+	font := &sfnt{binary.BigEndian.Uint32(data), make(map[string][]byte)}
+	switch font.version {
+	case 0x00010000, 0x4f54544f, 0x74727565: // 1.0, "OTTO", "true"
+	default:
+		return nil, notSfnt
+	}
+	numTables := int(binary.BigEndian.Uint16(data[4:]))
+	if len(data) < 12+16*numTables {
+		return nil, truncatedFont
+	}
+	for i:=0; i<numTables; i++ {
+		record := data[12+16*i:]
+		tag := string(record[:4])
+		offset := binary.BigEndian.Uint32(record[8:])
+		length := binary.BigEndian.Uint32(record[12:])
+		if uint64(offset)+uint64(length) > uint64(len(data)) {
+			return nil, truncatedFont
+		}
+		font.tables[tag] = data[offset:offset+length]
+	}
+	return font, nil
+}
+
+// bytes() returns the font with the listed tables, which must be
+// present, in sfnt format, updating the checksum adjustment in the
+// head table.
+func (font *sfnt) bytes(tags []string) []byte {
+	tags = append([]string(nil), tags...)
+	sort.Strings(tags)
+
+	n := len(tags)
+	entrySelector := 0
+	for 1<<uint(entrySelector+1) <= n {
+		entrySelector++
+	}
+	searchRange := 16 << uint(entrySelector)
+
+	var b bytes.Buffer
+	binary.Write(&b, binary.BigEndian, font.version)
+	binary.Write(&b, binary.BigEndian, []uint16{uint16(n), uint16(searchRange), uint16(entrySelector), uint16(16*n-searchRange)})
+
+	offset := 12 + 16*n
+	padded := make([][]byte, n)
+	headOffset := -1
+	for i,tag := range tags {
+		table := font.tables[tag]
+		if tag == "head" && len(table) >= 12 {
+			table = append([]byte(nil), table...)
+			binary.BigEndian.PutUint32(table[8:], 0)
+			headOffset = offset
+		}
+		b.WriteString(tag)
+		binary.Write(&b, binary.BigEndian, []uint32{sfntChecksum(table), uint32(offset), uint32(len(table))})
+		padded[i] = append(table, make([]byte, (4-len(table)%4)%4)...)
+		offset += len(padded[i])
+	}
+	for _,table := range padded {
+		b.Write(table)
+	}
+
+	result := b.Bytes()
+	if headOffset >= 0 {
+		binary.BigEndian.PutUint32(result[headOffset+8:], 0xb1b0afba-sfntChecksum(result))
+	}
+	return result
+}
+
+// sfntChecksum() returns the sum of the big-endian 32-bit words of
+// table, padded with zeros.
+func sfntChecksum(table []byte) (sum uint32) {
+	for i:=0; i<len(table); i+=4 {
+		var word [4]byte
+		copy(word[:], table[i:])
+		sum += binary.BigEndian.Uint32(word[:])
+	}
+	return sum
+}
+
+// sfntMetrics holds the information from an sfnt font's tables that
+// PDF font dictionaries need, in glyph space units.
+type sfntMetrics struct {
+	unitsPerEm float64
+	bbox [4]float64
+	italicAngle float64
+	fixedPitch bool
+	ascent, descent, capHeight float64
+	weightClass int
+	// fsType holds the embedding permissions from the OS/2 table.
+	fsType uint16
+	postScriptName string
+	advances []uint16
+	cmap map[rune]uint16
+}
+
+// readMetrics() reads the head, hhea, hmtx, maxp, OS/2, post, name,
+// and cmap tables.
+func (font *sfnt) readMetrics() (*sfntMetrics, error) {
+	head := font.tables["head"]
+	hhea := font.tables["hhea"]
+	maxp := font.tables["maxp"]
+	if len(head) < 54 || len(hhea) < 36 || len(maxp) < 6 {
+		return nil, truncatedFont
+	}
+	m := &sfntMetrics{unitsPerEm: float64(binary.BigEndian.Uint16(head[18:]))}
+	if m.unitsPerEm == 0 {
+		return nil, errors.New(`Font has no units per em`)
+	}
+	for i := range m.bbox {
+		m.bbox[i] = float64(int16(binary.BigEndian.Uint16(head[36+2*i:])))
+	}
+	m.ascent = float64(int16(binary.BigEndian.Uint16(hhea[4:])))
+	m.descent = float64(int16(binary.BigEndian.Uint16(hhea[6:])))
+	m.capHeight = m.ascent
+	m.weightClass = 400
+
+	numGlyphs := int(binary.BigEndian.Uint16(maxp[4:]))
+	numHMetrics := int(binary.BigEndian.Uint16(hhea[34:]))
+	hmtx := font.tables["hmtx"]
+	if numHMetrics == 0 || numHMetrics > numGlyphs || len(hmtx) < 4*numHMetrics {
+		return nil, truncatedFont
+	}
+	m.advances = make([]uint16, numGlyphs)
+	for gid := range m.advances {
+		if gid < numHMetrics {
+			m.advances[gid] = binary.BigEndian.Uint16(hmtx[4*gid:])
+		} else {
+			m.advances[gid] = m.advances[numHMetrics-1]
+		}
+	}
+
+	if os2 := font.tables["OS/2"]; len(os2) >= 78 {
+		m.fsType = binary.BigEndian.Uint16(os2[8:])
+		m.weightClass = int(binary.BigEndian.Uint16(os2[4:]))
+		if version := binary.BigEndian.Uint16(os2); version >= 2 && len(os2) >= 90 {
+			m.capHeight = float64(int16(binary.BigEndian.Uint16(os2[88:])))
+		}
+	}
+	if post := font.tables["post"]; len(post) >= 16 {
+		m.italicAngle = float64(int32(binary.BigEndian.Uint32(post[4:]))) / 65536
+		m.fixedPitch = binary.BigEndian.Uint32(post[12:]) != 0
+	}
+	m.postScriptName = sfntPostScriptName(font.tables["name"])
+	m.cmap = parseSfntCmap(font.tables["cmap"])
+	return m, nil
+}
+
+// sfntPostScriptName() returns name ID 6 from a name table, preferring
+// the Macintosh Roman record, which is ASCII, to the UTF-16 Windows
+// record.
+func sfntPostScriptName(name []byte) string {
+	if len(name) < 6 {
+		return ""
+	}
+	count := int(binary.BigEndian.Uint16(name[2:]))
+	storage := int(binary.BigEndian.Uint16(name[4:]))
+	result := ""
+	for i:=0; i<count && 6+12*i+12<=len(name); i++ {
+		record := name[6+12*i:]
+		platform := binary.BigEndian.Uint16(record)
+		if binary.BigEndian.Uint16(record[6:]) != 6 {
+			continue
+		}
+		length := int(binary.BigEndian.Uint16(record[8:]))
+		offset := storage + int(binary.BigEndian.Uint16(record[10:]))
+		if offset+length > len(name) {
+			continue
+		}
+		value := name[offset:offset+length]
+		switch platform {
+		case 1:
+			return string(value)
+		case 0, 3:
+			result = decodeUTF16(value)
+		}
+	}
+	return result
+}
+
+// parseSfntCmap() returns the mapping from Unicode to glyph IDs given
+// by the best Unicode subtable of a cmap table, in format 4 or 12.
+func parseSfntCmap(cmap []byte) map[rune]uint16 {
+	result := make(map[rune]uint16)
+	if len(cmap) < 4 {
+		return result
+	}
+	var best []byte
+	bestRank := 0
+	count := int(binary.BigEndian.Uint16(cmap[2:]))
+	for i:=0; i<count && 4+8*i+8<=len(cmap); i++ {
+		record := cmap[4+8*i:]
+		platform := binary.BigEndian.Uint16(record)
+		encoding := binary.BigEndian.Uint16(record[2:])
+		offset := binary.BigEndian.Uint32(record[4:])
+		if uint64(offset)+4 > uint64(len(cmap)) {
+			continue
+		}
+		subtable := cmap[offset:]
+		format := binary.BigEndian.Uint16(subtable)
+		rank := 0
+		switch {
+		case format == 12 && (platform == 3 && encoding == 10 || platform == 0):
+			rank = 3
+		case format == 4 && platform == 3 && encoding == 1:
+			rank = 2
+		case format == 4 && platform == 0:
+			rank = 1
+		}
+		if rank > bestRank {
+			best, bestRank = subtable, rank
+		}
+	}
+	if best == nil {
+		return result
+	}
+	return parseCmapSubtable(best)
+}
+
+// sfntCmapSubtable() returns the mapping from codes to glyph IDs given
+// by the cmap subtable for the passed platform and encoding, or nil if
+// there is none.
+func sfntCmapSubtable(cmap []byte, platform, encoding uint16) map[rune]uint16 {
+	if len(cmap) < 4 {
+		return nil
+	}
+	count := int(binary.BigEndian.Uint16(cmap[2:]))
+	for i:=0; i<count && 4+8*i+8<=len(cmap); i++ {
+		record := cmap[4+8*i:]
+		offset := binary.BigEndian.Uint32(record[4:])
+		if binary.BigEndian.Uint16(record) == platform && binary.BigEndian.Uint16(record[2:]) == encoding && uint64(offset)+4 <= uint64(len(cmap)) {
+			return parseCmapSubtable(cmap[offset:])
+		}
+	}
+	return nil
+}
+
+// parseCmapSubtable() reads a cmap subtable in format 0, 4, 6, or 12.
+func parseCmapSubtable(subtable []byte) map[rune]uint16 {
+	result := make(map[rune]uint16)
+	switch binary.BigEndian.Uint16(subtable) {
+	case 0:
+		if len(subtable) < 6+256 {
+			return result
+		}
+		for c,gid := range subtable[6:6+256] {
+			if gid != 0 {
+				result[rune(c)] = uint16(gid)
+			}
+		}
+		return result
+	case 6:
+		if len(subtable) < 10 {
+			return result
+		}
+		first := int(binary.BigEndian.Uint16(subtable[6:]))
+		count := int(binary.BigEndian.Uint16(subtable[8:]))
+		for i:=0; i<count && 10+2*i+2<=len(subtable); i++ {
+			if gid := binary.BigEndian.Uint16(subtable[10+2*i:]); gid != 0 {
+				result[rune(first+i)] = gid
+			}
+		}
+		return result
+	case 4:
+	case 12:
+		if len(subtable) < 16 {
+			return result
+		}
+		groups := int(binary.BigEndian.Uint32(subtable[12:]))
+		for i:=0; i<groups && 16+12*i+12<=len(subtable); i++ {
+			group := subtable[16+12*i:]
+			first := binary.BigEndian.Uint32(group)
+			last := binary.BigEndian.Uint32(group[4:])
+			gid := binary.BigEndian.Uint32(group[8:])
+			for c:=first; c<=last && c<=0x10ffff && last-first < 0x10000; c++ {
+				result[rune(c)] = uint16(gid + c - first)
+			}
+		}
+		return result
+	default:
+		return result
+	}
+
+	if len(subtable) < 14 {
+		return result
+	}
+	segments := int(binary.BigEndian.Uint16(subtable[6:])) / 2
+	if len(subtable) < 16+8*segments {
+		return result
+	}
+	ends := subtable[14:]
+	starts := subtable[16+2*segments:]
+	deltas := subtable[16+4*segments:]
+	rangeOffsets := subtable[16+6*segments:]
+	for i:=0; i<segments; i++ {
+		end := binary.BigEndian.Uint16(ends[2*i:])
+		start := binary.BigEndian.Uint16(starts[2*i:])
+		delta := binary.BigEndian.Uint16(deltas[2*i:])
+		rangeOffset := int(binary.BigEndian.Uint16(rangeOffsets[2*i:]))
+		for c:=uint32(start); c<=uint32(end) && c != 0xffff; c++ {
+			var gid uint16
+			if rangeOffset == 0 {
+				gid = uint16(c) + delta
+			} else {
+				// The offset is relative to the
+				// idRangeOffset entry itself.
+				position := 16 + 6*segments + 2*i + rangeOffset + 2*int(c-uint32(start))
+				if position+2 > len(subtable) {
+					continue
+				}
+				if gid = binary.BigEndian.Uint16(subtable[position:]); gid != 0 {
+					gid += delta
+				}
+			}
+			if gid != 0 {
+				result[rune(c)] = gid
+			}
+		}
+	}
+	return result
+}
+
+// Flags of the components of composite TrueType glyphs.
+const (
+	argsAreWords = 0x0001
+	haveScale = 0x0008
+	moreComponents = 0x0020
+	haveXYScale = 0x0040
+	haveTwoByTwo = 0x0080 )
+
+var noTrueTypeOutlines = errors.New(`Font has no TrueType outlines`)
+
+// glyphs() splits the glyf table of a TrueType font into the data of
+// each glyph, using the loca table.
+func (font *sfnt) glyphs() ([][]byte, error) {
+	head, maxp := font.tables["head"], font.tables["maxp"]
+	loca, glyf := font.tables["loca"], font.tables["glyf"]
+	if loca == nil || glyf == nil {
+		return nil, noTrueTypeOutlines
+	}
+	if len(head) < 54 || len(maxp) < 6 {
+		return nil, truncatedFont
+	}
+	numGlyphs := int(binary.BigEndian.Uint16(maxp[4:]))
+	long := binary.BigEndian.Uint16(head[50:]) != 0
+	offset := func(i int) int {
+		if long {
+			return int(binary.BigEndian.Uint32(loca[4*i:]))
+		}
+		return 2*int(binary.BigEndian.Uint16(loca[2*i:]))
+	}
+	if long && len(loca) < 4*(numGlyphs+1) || !long && len(loca) < 2*(numGlyphs+1) {
+		return nil, truncatedFont
+	}
+	glyphs := make([][]byte, numGlyphs)
+	for i := range glyphs {
+		start, end := offset(i), offset(i+1)
+		if start > end || end > len(glyf) {
+			return nil, truncatedFont
+		}
+		glyphs[i] = glyf[start:end]
+	}
+	return glyphs, nil
+}
+
+// glyphComponents() returns the glyph IDs of the components of a
+// composite glyph, or nil for a simple glyph.
+func glyphComponents(glyph []byte) (components []uint16) {
+	if len(glyph) < 10 || int16(binary.BigEndian.Uint16(glyph)) >= 0 {
+		return nil
+	}
+	for i:=10; i+4<=len(glyph); {
+		flags := binary.BigEndian.Uint16(glyph[i:])
+		components = append(components, binary.BigEndian.Uint16(glyph[i+2:]))
+		i += 4
+		if flags & argsAreWords != 0 {
+			i += 4
+		} else {
+			i += 2
+		}
+		switch {
+		case flags & haveScale != 0:
+			i += 2
+		case flags & haveXYScale != 0:
+			i += 4
+		case flags & haveTwoByTwo != 0:
+			i += 8
+		}
+		if flags & moreComponents == 0 {
+			break
+		}
+	}
+	return components
+}
+
+// subsetGlyphs() returns a copy of a TrueType font in which the glyphs
+// not in used, or used by the composite glyphs in used, are empty.  As
+// with cffFont.subset(), glyph IDs are unchanged.  The loca table is
+// written in the long format.
+func (font *sfnt) subsetGlyphs(used map[uint16]bool) (*sfnt, error) {
+	glyphs,err := font.glyphs()
+	if err != nil {
+		return nil, err
+	}
+	keep := make(map[uint16]bool, len(used)+1)
+	pending := []uint16{0}
+	for gid := range used {
+		pending = append(pending, gid)
+	}
+	for len(pending) > 0 {
+		gid := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		if keep[gid] || int(gid) >= len(glyphs) {
+			continue
+		}
+		keep[gid] = true
+		pending = append(pending, glyphComponents(glyphs[gid])...)
+	}
+
+	var glyf bytes.Buffer
+	loca := make([]byte, 4*(len(glyphs)+1))
+	for i,glyph := range glyphs {
+		binary.BigEndian.PutUint32(loca[4*i:], uint32(glyf.Len()))
+		if keep[uint16(i)] {
+			glyf.Write(glyph)
+			// Glyphs are aligned on four-byte boundaries.
+			glyf.Write(make([]byte, (4-glyf.Len()%4)%4))
+		}
+	}
+	binary.BigEndian.PutUint32(loca[4*len(glyphs):], uint32(glyf.Len()))
+
+	result := &sfnt{font.version, make(map[string][]byte, len(font.tables))}
+	for tag,table := range font.tables {
+		// A digital signature would no longer match.
+		if tag != "DSIG" {
+			result.tables[tag] = table
+		}
+	}
+	head := append([]byte(nil), font.tables["head"]...)
+	binary.BigEndian.PutUint16(head[50:], 1)
+	result.tables["head"] = head
+	result.tables["loca"] = loca
+	result.tables["glyf"] = glyf.Bytes()
+	return result, nil
+}
+
+// tags() returns the tags of the font's tables.
+func (font *sfnt) tags() []string {
+	tags := make([]string, 0, len(font.tables))
+	for tag := range font.tables {
+		tags = append(tags, tag)
+	}
+	return tags
+}
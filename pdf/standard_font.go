@@ -2,6 +2,10 @@ package pdf
 
 type Font interface {
 	Indirect(f File) Indirect
+
+	// Embedded() returns true if the font program is embedded
+	// in the file.
+	Embedded() bool
 }
 
 type StandardFont uint8
@@ -59,6 +63,7 @@ func NewStandardFont(font StandardFont) Font {
 }
 
 func (font *standardFont) Indirect(file File) Indirect {
+	file = bindingKey(file)
 	i,exists := font.fileBindings[file]
 	if (!exists) {
 		i = file.WriteObject(font.dictionary)
@@ -67,3 +72,8 @@ func (font *standardFont) Indirect(file File) Indirect {
 	return i
 }
 
+
+// The standard fonts are never embedded.
+func (font *standardFont) Embedded() bool {
+	return false
+}
@@ -0,0 +1,216 @@
+package pdf
+
+import "sync"
+
+// builtinMetrics are the metrics of a standard 14 font from the Adobe
+// Core14 AFM files.  Only the glyphs with codes 32 through 126 in
+// each font's built-in encoding are included, and kerning pairs are
+// omitted; ParseAFM() can load the complete files.
+type builtinMetrics struct {
+	fontName, familyName, weight string
+	bbox [4]float64
+	italicAngle float64
+	fixedPitch, symbolic bool
+	capHeight, xHeight, ascender, descender, stemV float64
+	// glyphs are the names of the glyphs with codes 32 through 126.
+	glyphs []string
+	widths []float64
+}
+
+var (
+	standardFontMetricsCache = make(map[StandardFont]*FontMetrics)
+	standardFontMetricsMutex sync.Mutex )
+
+// StandardFontMetrics() returns the metrics of one of the standard 14
+// fonts.  They include the glyphs with codes 32 through 126 in the
+// font's built-in encoding, i.e., printable ASCII for the Latin fonts,
+// but no kerning pairs.  The returned FontMetrics is shared and must
+// not be modified.
+func StandardFontMetrics(font StandardFont) *FontMetrics {
+	standardFontMetricsMutex.Lock()
+	defer standardFontMetricsMutex.Unlock()
+	if metrics,exists := standardFontMetricsCache[font]; exists {
+		return metrics
+	}
+	b,exists := standardMetrics[font]
+	if !exists {
+		return nil
+	}
+	metrics := &FontMetrics{
+		FontName: b.fontName,
+		FamilyName: b.familyName,
+		Weight: b.weight,
+		FontBBox: b.bbox,
+		ItalicAngle: b.italicAngle,
+		IsFixedPitch: b.fixedPitch,
+		Symbolic: b.symbolic,
+		CapHeight: b.capHeight,
+		XHeight: b.xHeight,
+		Ascender: b.ascender,
+		Descender: b.descender,
+		StemV: b.stemV,
+		Widths: make(map[string]float64, len(b.glyphs)),
+		Codes: make(map[string]int, len(b.glyphs)),
+		Kerning: make(map[[2]string]float64) }
+	for i,name := range b.glyphs {
+		metrics.Widths[name] = b.widths[i]
+		metrics.Codes[name] = 32 + i
+	}
+	standardFontMetricsCache[font] = metrics
+	return metrics
+}
+
+var (
+	latinGlyphs = []string {
+		"space", "exclam", "quotedbl", "numbersign", "dollar", "percent", "ampersand", "quoteright",
+		"parenleft", "parenright", "asterisk", "plus", "comma", "hyphen", "period", "slash",
+		"zero", "one", "two", "three", "four", "five", "six", "seven",
+		"eight", "nine", "colon", "semicolon", "less", "equal", "greater", "question",
+		"at", "A", "B", "C", "D", "E", "F", "G",
+		"H", "I", "J", "K", "L", "M", "N", "O",
+		"P", "Q", "R", "S", "T", "U", "V", "W",
+		"X", "Y", "Z", "bracketleft", "backslash", "bracketright", "asciicircum", "underscore",
+		"quoteleft", "a", "b", "c", "d", "e", "f", "g",
+		"h", "i", "j", "k", "l", "m", "n", "o",
+		"p", "q", "r", "s", "t", "u", "v", "w",
+		"x", "y", "z", "braceleft", "bar", "braceright", "asciitilde" }
+	symbolGlyphs = []string {
+		"space", "exclam", "universal", "numbersign", "existential", "percent", "ampersand", "suchthat",
+		"parenleft", "parenright", "asteriskmath", "plus", "comma", "minus", "period", "slash",
+		"zero", "one", "two", "three", "four", "five", "six", "seven",
+		"eight", "nine", "colon", "semicolon", "less", "equal", "greater", "question",
+		"congruent", "Alpha", "Beta", "Chi", "Delta", "Epsilon", "Phi", "Gamma",
+		"Eta", "Iota", "theta1", "Kappa", "Lambda", "Mu", "Nu", "Omicron",
+		"Pi", "Theta", "Rho", "Sigma", "Tau", "Upsilon", "sigma1", "Omega",
+		"Xi", "Psi", "Zeta", "bracketleft", "therefore", "bracketright", "perpendicular", "underscore",
+		"radicalex", "alpha", "beta", "chi", "delta", "epsilon", "phi", "gamma",
+		"eta", "iota", "phi1", "kappa", "lambda", "mu", "nu", "omicron",
+		"pi", "theta", "rho", "sigma", "tau", "upsilon", "omega1", "omega",
+		"xi", "psi", "zeta", "braceleft", "bar", "braceright", "similar" }
+	dingbatGlyphs = []string {
+		"space", "a1", "a2", "a202", "a3", "a4", "a5", "a119",
+		"a118", "a117", "a11", "a12", "a13", "a14", "a15", "a16",
+		"a105", "a17", "a18", "a19", "a20", "a21", "a22", "a23",
+		"a24", "a25", "a26", "a27", "a28", "a6", "a7", "a8",
+		"a9", "a10", "a29", "a30", "a31", "a32", "a33", "a34",
+		"a35", "a36", "a37", "a38", "a39", "a40", "a41", "a42",
+		"a43", "a44", "a45", "a46", "a47", "a48", "a49", "a50",
+		"a51", "a52", "a53", "a54", "a55", "a56", "a57", "a58",
+		"a59", "a60", "a61", "a62", "a63", "a64", "a65", "a66",
+		"a67", "a68", "a69", "a70", "a71", "a72", "a73", "a74",
+		"a203", "a75", "a204", "a76", "a77", "a78", "a79", "a81",
+		"a82", "a83", "a84", "a97", "a98", "a99", "a100" } )
+
+var standardMetrics = map[StandardFont]builtinMetrics {
+	TimesRoman: {"Times-Roman", "Times", "Roman", [4]float64{-168, -218, 1000, 898}, 0, false, false, 662, 450, 683, -217, 84, latinGlyphs,
+		[]float64{
+			250, 333, 408, 500, 500, 833, 778, 333, 333, 333, 500, 564, 250, 333, 250, 278,
+			500, 500, 500, 500, 500, 500, 500, 500, 500, 500, 278, 278, 564, 564, 564, 444,
+			921, 722, 667, 667, 722, 611, 556, 722, 722, 333, 389, 722, 611, 889, 722, 722,
+			556, 722, 667, 556, 611, 722, 722, 944, 722, 722, 611, 333, 278, 333, 469, 500,
+			333, 444, 500, 444, 500, 444, 333, 500, 500, 278, 278, 500, 278, 778, 500, 500,
+			500, 500, 333, 389, 278, 500, 500, 722, 500, 500, 444, 480, 200, 480, 541 }},
+	Helvetica: {"Helvetica", "Helvetica", "Medium", [4]float64{-166, -225, 1000, 931}, 0, false, false, 718, 523, 718, -207, 88, latinGlyphs,
+		[]float64{
+			278, 278, 355, 556, 556, 889, 667, 222, 333, 333, 389, 584, 278, 333, 278, 278,
+			556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 278, 278, 584, 584, 584, 556,
+			1015, 667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, 722, 778,
+			667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 278, 278, 278, 469, 556,
+			222, 556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, 556, 556,
+			556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, 334, 260, 334, 584 }},
+	Courier: {"Courier", "Courier", "Medium", [4]float64{-23, -250, 715, 805}, 0, true, false, 562, 426, 629, -157, 51, latinGlyphs,
+		[]float64{
+			600, 600, 600, 600, 600, 600, 600, 600, 600, 600, 600, 600, 600, 600, 600, 600,
+			600, 600, 600, 600, 600, 600, 600, 600, 600, 600, 600, 600, 600, 600, 600, 600,
+			600, 600, 600, 600, 600, 600, 600, 600, 600, 600, 600, 600, 600, 600, 600, 600,
+			600, 600, 600, 600, 600, 600, 600, 600, 600, 600, 600, 600, 600, 600, 600, 600,
+			600, 600, 600, 600, 600, 600, 600, 600, 600, 600, 600, 600, 600, 600, 600, 600,
+			600, 600, 600, 600, 600, 600, 600, 600, 600, 600, 600, 600, 600, 600, 600 }},
+	Symbol: {"Symbol", "Symbol", "Medium", [4]float64{-180, -293, 1090, 1010}, 0, false, true, 0, 0, 0, 0, 85, symbolGlyphs,
+		[]float64{
+			250, 333, 713, 500, 549, 833, 778, 439, 333, 333, 500, 549, 250, 549, 250, 278,
+			500, 500, 500, 500, 500, 500, 500, 500, 500, 500, 278, 278, 549, 549, 549, 444,
+			549, 722, 667, 722, 612, 611, 763, 603, 722, 333, 631, 722, 686, 889, 722, 722,
+			768, 741, 556, 592, 611, 690, 439, 768, 645, 795, 611, 333, 863, 333, 658, 500,
+			500, 631, 549, 549, 494, 439, 521, 411, 603, 329, 603, 549, 549, 576, 521, 549,
+			549, 521, 549, 603, 439, 576, 713, 686, 493, 686, 494, 480, 200, 480, 549 }},
+	TimesBold: {"Times-Bold", "Times", "Bold", [4]float64{-168, -218, 1000, 935}, 0, false, false, 676, 461, 683, -217, 139, latinGlyphs,
+		[]float64{
+			250, 333, 555, 500, 500, 1000, 833, 333, 333, 333, 500, 570, 250, 333, 250, 278,
+			500, 500, 500, 500, 500, 500, 500, 500, 500, 500, 333, 333, 570, 570, 570, 500,
+			930, 722, 667, 722, 722, 667, 611, 778, 778, 389, 500, 778, 667, 944, 722, 778,
+			611, 778, 722, 556, 667, 722, 722, 1000, 722, 722, 667, 333, 278, 333, 581, 500,
+			333, 500, 556, 444, 556, 444, 333, 500, 556, 278, 333, 556, 278, 833, 556, 500,
+			556, 556, 444, 389, 333, 556, 500, 722, 500, 500, 444, 394, 220, 394, 520 }},
+	HelveticaBold: {"Helvetica-Bold", "Helvetica", "Bold", [4]float64{-170, -228, 1003, 962}, 0, false, false, 718, 532, 718, -207, 140, latinGlyphs,
+		[]float64{
+			278, 333, 474, 556, 556, 889, 722, 278, 333, 333, 389, 584, 278, 333, 278, 278,
+			556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 333, 333, 584, 584, 584, 611,
+			975, 722, 722, 722, 722, 667, 611, 778, 722, 278, 556, 722, 611, 833, 722, 778,
+			667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 333, 278, 333, 584, 556,
+			278, 556, 611, 556, 611, 556, 333, 611, 611, 278, 278, 556, 278, 889, 611, 611,
+			611, 611, 389, 556, 333, 611, 556, 778, 556, 556, 500, 389, 280, 389, 584 }},
+	CourierBold: {"Courier-Bold", "Courier", "Bold", [4]float64{-113, -250, 749, 801}, 0, true, false, 562, 439, 629, -157, 106, latinGlyphs,
+		[]float64{
+			600, 600, 600, 600, 600, 600, 600, 600, 600, 600, 600, 600, 600, 600, 600, 600,
+			600, 600, 600, 600, 600, 600, 600, 600, 600, 600, 600, 600, 600, 600, 600, 600,
+			600, 600, 600, 600, 600, 600, 600, 600, 600, 600, 600, 600, 600, 600, 600, 600,
+			600, 600, 600, 600, 600, 600, 600, 600, 600, 600, 600, 600, 600, 600, 600, 600,
+			600, 600, 600, 600, 600, 600, 600, 600, 600, 600, 600, 600, 600, 600, 600, 600,
+			600, 600, 600, 600, 600, 600, 600, 600, 600, 600, 600, 600, 600, 600, 600 }},
+	ZapfDingbats: {"ZapfDingbats", "ITC Zapf Dingbats", "Medium", [4]float64{-1, -143, 981, 820}, 0, false, true, 0, 0, 0, 0, 90, dingbatGlyphs,
+		[]float64{
+			278, 974, 961, 974, 980, 719, 789, 790, 791, 690, 960, 939, 549, 855, 911, 933,
+			911, 945, 974, 755, 846, 762, 761, 571, 677, 763, 760, 759, 754, 494, 552, 537,
+			577, 692, 786, 788, 788, 790, 793, 794, 816, 823, 789, 841, 823, 833, 816, 831,
+			923, 744, 723, 749, 790, 792, 695, 776, 768, 792, 759, 707, 708, 682, 701, 826,
+			815, 789, 789, 707, 687, 696, 689, 786, 787, 713, 791, 785, 791, 873, 761, 762,
+			762, 759, 759, 892, 892, 788, 784, 438, 138, 277, 415, 392, 392, 668, 668 }},
+	TimesItalic: {"Times-Italic", "Times", "Medium", [4]float64{-169, -217, 1010, 883}, -15.5, false, false, 653, 441, 683, -217, 76, latinGlyphs,
+		[]float64{
+			250, 333, 420, 500, 500, 833, 778, 333, 333, 333, 500, 675, 250, 333, 250, 278,
+			500, 500, 500, 500, 500, 500, 500, 500, 500, 500, 333, 333, 675, 675, 675, 500,
+			920, 611, 611, 667, 722, 611, 611, 722, 722, 333, 444, 667, 556, 833, 667, 722,
+			611, 722, 611, 500, 556, 722, 611, 833, 611, 556, 556, 389, 278, 389, 422, 500,
+			333, 500, 500, 444, 500, 444, 278, 500, 500, 278, 278, 444, 278, 722, 500, 500,
+			500, 500, 389, 389, 278, 500, 444, 667, 444, 444, 389, 400, 275, 400, 541 }},
+	HelveticaOblique: {"Helvetica-Oblique", "Helvetica", "Medium", [4]float64{-170, -225, 1116, 931}, -12, false, false, 718, 523, 718, -207, 88, latinGlyphs,
+		[]float64{
+			278, 278, 355, 556, 556, 889, 667, 222, 333, 333, 389, 584, 278, 333, 278, 278,
+			556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 278, 278, 584, 584, 584, 556,
+			1015, 667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, 722, 778,
+			667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 278, 278, 278, 469, 556,
+			222, 556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, 556, 556,
+			556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, 334, 260, 334, 584 }},
+	CourierOblique: {"Courier-Oblique", "Courier", "Medium", [4]float64{-27, -250, 849, 805}, -12, true, false, 562, 426, 629, -157, 51, latinGlyphs,
+		[]float64{
+			600, 600, 600, 600, 600, 600, 600, 600, 600, 600, 600, 600, 600, 600, 600, 600,
+			600, 600, 600, 600, 600, 600, 600, 600, 600, 600, 600, 600, 600, 600, 600, 600,
+			600, 600, 600, 600, 600, 600, 600, 600, 600, 600, 600, 600, 600, 600, 600, 600,
+			600, 600, 600, 600, 600, 600, 600, 600, 600, 600, 600, 600, 600, 600, 600, 600,
+			600, 600, 600, 600, 600, 600, 600, 600, 600, 600, 600, 600, 600, 600, 600, 600,
+			600, 600, 600, 600, 600, 600, 600, 600, 600, 600, 600, 600, 600, 600, 600 }},
+	TimesBoldItalic: {"Times-BoldItalic", "Times", "Bold", [4]float64{-200, -218, 996, 921}, -15, false, false, 669, 462, 683, -217, 121, latinGlyphs,
+		[]float64{
+			250, 389, 555, 500, 500, 833, 778, 333, 333, 333, 500, 570, 250, 333, 250, 278,
+			500, 500, 500, 500, 500, 500, 500, 500, 500, 500, 333, 333, 570, 570, 570, 500,
+			832, 667, 667, 667, 722, 667, 667, 722, 778, 389, 500, 667, 611, 889, 722, 722,
+			611, 722, 667, 556, 611, 722, 667, 889, 667, 611, 611, 333, 278, 333, 570, 500,
+			333, 500, 500, 444, 500, 444, 333, 500, 556, 278, 278, 500, 278, 778, 556, 500,
+			500, 500, 389, 389, 278, 556, 444, 667, 500, 444, 389, 348, 220, 348, 570 }},
+	HelveticaBoldOblique: {"Helvetica-BoldOblique", "Helvetica", "Bold", [4]float64{-174, -228, 1114, 962}, -12, false, false, 718, 532, 718, -207, 140, latinGlyphs,
+		[]float64{
+			278, 333, 474, 556, 556, 889, 722, 278, 333, 333, 389, 584, 278, 333, 278, 278,
+			556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 333, 333, 584, 584, 584, 611,
+			975, 722, 722, 722, 722, 667, 611, 778, 722, 278, 556, 722, 611, 833, 722, 778,
+			667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 333, 278, 333, 584, 556,
+			278, 556, 611, 556, 611, 556, 333, 611, 611, 278, 278, 556, 278, 889, 611, 611,
+			611, 611, 389, 556, 333, 611, 556, 778, 556, 556, 500, 389, 280, 389, 584 }},
+	CourierBoldOblique: {"Courier-BoldOblique", "Courier", "Bold", [4]float64{-57, -250, 869, 801}, -12, true, false, 562, 439, 629, -157, 106, latinGlyphs,
+		[]float64{
+			600, 600, 600, 600, 600, 600, 600, 600, 600, 600, 600, 600, 600, 600, 600, 600,
+			600, 600, 600, 600, 600, 600, 600, 600, 600, 600, 600, 600, 600, 600, 600, 600,
+			600, 600, 600, 600, 600, 600, 600, 600, 600, 600, 600, 600, 600, 600, 600, 600,
+			600, 600, 600, 600, 600, 600, 600, 600, 600, 600, 600, 600, 600, 600, 600, 600,
+			600, 600, 600, 600, 600, 600, 600, 600, 600, 600, 600, 600, 600, 600, 600, 600,
+			600, 600, 600, 600, 600, 600, 600, 600, 600, 600, 600, 600, 600, 600, 600 }} }
@@ -0,0 +1,716 @@
+package pdf
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/rc4"
+	"crypto/sha256"
+	"crypto/sha512"
+	"errors"
+	"fmt"
+	"hash"
+	"io" )
+
+// PasswordCallback is called when an encrypted file is opened and
+// the empty user password fails to authenticate.  It is called
+// repeatedly with an increasing attempt number (starting at 1) until
+// the returned password authenticates as either the user or the
+// owner password, or until it returns false, which abandons the
+// attempt to open the file.
+type PasswordCallback func(attempt int) (password string, ok bool)
+
+var (
+	ErrIncorrectPassword error = &EncryptionError{errors.New(`Incorrect password for encrypted PDF file`)}
+	unsupportedSecurityHandler error = &EncryptionError{errors.New(`Unsupported security handler`)}
+	invalidEncryptDictionary error = &EncryptionError{errors.New(`Invalid /Encrypt dictionary`)}
+	invalidCipherText error = &EncryptionError{errors.New(`Invalid encrypted data`)} )
+
+// passwordPadding is the padding string defined in the PDF
+// specification (Algorithm 2) for passwords used by revisions 2-4 of
+// the standard security handler.
+var passwordPadding = []byte{
+	0x28, 0xbf, 0x4e, 0x5e, 0x4e, 0x75, 0x8a, 0x41,
+	0x64, 0x00, 0x4e, 0x56, 0xff, 0xfa, 0x01, 0x08,
+	0x2e, 0x2e, 0x00, 0xb6, 0xd0, 0x68, 0x3e, 0x80,
+	0x2f, 0x0c, 0xa9, 0xfe, 0x64, 0x53, 0x69, 0x7a }
+
+// Crypt filter methods (the /CFM entry of a crypt filter dictionary)
+const (
+	cryptNone = iota
+	cryptRC4
+	cryptAESV2
+	cryptAESV3 )
+
+// standardSecurityHandler implements the "Standard" security handler
+// described in the PDF specification, covering revisions 2 through 6
+// (RC4, AES-128, and AES-256).
+type standardSecurityHandler struct {
+	v, r int
+	// keyLength is the length of the file encryption key in bytes.
+	keyLength int
+	o, u, oe, ue []byte
+	p int32
+	id0 []byte
+	encryptMetadata bool
+	stringMethod, streamMethod int
+
+	// key is the file encryption key.  It is nil until
+	// authenticate() succeeds.
+	key []byte
+	// ownerAuthenticated is true if the password supplied was
+	// the owner password.
+	ownerAuthenticated bool
+}
+
+func cryptFilterMethod(encrypt ProtectedDictionary, filterName string) (int, error) {
+	if filterName == "Identity" {
+		return cryptNone, nil
+	}
+	var filter ProtectedDictionary
+	if cf := encrypt.GetDictionary("CF"); cf != nil {
+		filter = cf.GetDictionary(filterName)
+	}
+	if filter == nil {
+		return cryptNone, invalidEncryptDictionary
+	}
+	cfm,_ := filter.GetName("CFM")
+	switch cfm {
+	case "None":
+		return cryptNone, nil
+	case "V2":
+		return cryptRC4, nil
+	case "AESV2":
+		return cryptAESV2, nil
+	case "AESV3":
+		return cryptAESV3, nil
+	}
+	return cryptNone, unsupportedSecurityHandler
+}
+
+// newStandardSecurityHandler() constructs a security handler from the
+// /Encrypt dictionary and the first element of the trailer /ID
+// array.  The handler cannot decrypt anything until authenticate()
+// succeeds.
+func newStandardSecurityHandler(encrypt ProtectedDictionary, id0 []byte) (*standardSecurityHandler, error) {
+	if !encrypt.CheckNameValue("Filter", "Standard") {
+		return nil, unsupportedSecurityHandler
+	}
+
+	h := new(standardSecurityHandler)
+	h.id0 = id0
+	h.encryptMetadata = true
+	if em,ok := encrypt.GetBoolean("EncryptMetadata"); ok {
+		h.encryptMetadata = em
+	}
+
+	var ok bool
+	if h.v,ok = encrypt.GetInt("V"); !ok {
+		return nil, invalidEncryptDictionary
+	}
+	if h.r,ok = encrypt.GetInt("R"); !ok {
+		return nil, invalidEncryptDictionary
+	}
+	p,ok := encrypt.GetInt("P")
+	if !ok {
+		return nil, invalidEncryptDictionary
+	}
+	h.p = int32(p)
+	h.o,_ = encrypt.GetString("O")
+	h.u,_ = encrypt.GetString("U")
+	if len(h.o) < 32 || len(h.u) < 32 {
+		return nil, invalidEncryptDictionary
+	}
+
+	switch h.v {
+	case 1:
+		h.keyLength = 5
+		h.stringMethod, h.streamMethod = cryptRC4, cryptRC4
+	case 2:
+		h.keyLength = 5
+		if length,ok := encrypt.GetInt("Length"); ok {
+			h.keyLength = length/8
+		}
+		h.stringMethod, h.streamMethod = cryptRC4, cryptRC4
+	case 4, 5:
+		stmF,ok := encrypt.GetName("StmF")
+		if !ok {
+			stmF = "Identity"
+		}
+		strF,ok := encrypt.GetName("StrF")
+		if !ok {
+			strF = "Identity"
+		}
+		var err error
+		if h.streamMethod,err = cryptFilterMethod(encrypt, stmF); err != nil {
+			return nil, err
+		}
+		if h.stringMethod,err = cryptFilterMethod(encrypt, strF); err != nil {
+			return nil, err
+		}
+		if h.v == 4 {
+			h.keyLength = 16
+		} else {
+			h.keyLength = 32
+			h.oe,_ = encrypt.GetString("OE")
+			h.ue,_ = encrypt.GetString("UE")
+			if len(h.oe) != 32 || len(h.ue) != 32 || len(h.o) < 48 || len(h.u) < 48 {
+				return nil, invalidEncryptDictionary
+			}
+		}
+	default:
+		return nil, unsupportedSecurityHandler
+	}
+	if h.keyLength < 5 || h.keyLength > 32 {
+		return nil, invalidEncryptDictionary
+	}
+	return h, nil
+}
+
+// authenticate() tries the password first as the owner password and
+// then as the user password.  If either succeeds, the file
+// encryption key is computed and authenticate() returns true.
+func (h *standardSecurityHandler) authenticate(password string) bool {
+	if h.r >= 5 {
+		return h.authenticateAES256(truncatePassword([]byte(password), 127))
+	}
+
+	padded := padPassword([]byte(password))
+	if key := h.computeKey(h.userPasswordFromOwner(padded)); h.checkUserKey(key) {
+		h.key = key
+		h.ownerAuthenticated = true
+		return true
+	}
+	if key := h.computeKey(padded); h.checkUserKey(key) {
+		h.key = key
+		return true
+	}
+	return false
+}
+
+func truncatePassword(password []byte, n int) []byte {
+	if len(password) > n {
+		return password[:n]
+	}
+	return password
+}
+
+func padPassword(password []byte) []byte {
+	padded := make([]byte, 0, 32)
+	padded = append(padded, truncatePassword(password, 32)...)
+	return append(padded, passwordPadding[:32-len(padded)]...)
+}
+
+// computeKey() implements Algorithm 2 of the PDF specification,
+// computing a file encryption key from a padded user password.
+func (h *standardSecurityHandler) computeKey(padded []byte) []byte {
+	digest := md5.New()
+	digest.Write(padded)
+	digest.Write(h.o[:32])
+	digest.Write([]byte{byte(h.p), byte(h.p>>8), byte(h.p>>16), byte(h.p>>24)})
+	digest.Write(h.id0)
+	if h.r >= 4 && !h.encryptMetadata {
+		digest.Write([]byte{0xff, 0xff, 0xff, 0xff})
+	}
+	key := digest.Sum(nil)
+	if h.r >= 3 {
+		for i:=0; i<50; i++ {
+			sum := md5.Sum(key[:h.keyLength])
+			key = sum[:]
+		}
+	}
+	return key[:h.keyLength]
+}
+
+// computeU() implements Algorithms 4 and 5 of the PDF specification,
+// computing the /U value corresponding to a file encryption key.
+// For revisions 3 and 4, only the first 16 bytes are significant.
+func (h *standardSecurityHandler) computeU(key []byte) []byte {
+	if h.r == 2 {
+		return rc4Crypt(key, passwordPadding)
+	}
+	digest := md5.New()
+	digest.Write(passwordPadding)
+	digest.Write(h.id0)
+	u := digest.Sum(nil)
+	for i:=0; i<20; i++ {
+		u = rc4Crypt(xorKey(key, byte(i)), u)
+	}
+	return u
+}
+
+func (h *standardSecurityHandler) checkUserKey(key []byte) bool {
+	u := h.computeU(key)
+	if h.r == 2 {
+		return bytes.Equal(u, h.u[:32])
+	}
+	return bytes.Equal(u[:16], h.u[:16])
+}
+
+// ownerKey() computes the RC4 key used to encrypt /O from a padded
+// owner password (Algorithm 3, steps a-d).  Unlike computeKey(), each
+// of the 50 rounds hashes the whole digest; only the result is
+// truncated to the key length.
+func (h *standardSecurityHandler) ownerKey(padded []byte) []byte {
+	sum := md5.Sum(padded)
+	key := sum[:]
+	if h.r >= 3 {
+		for i:=0; i<50; i++ {
+			sum = md5.Sum(key)
+			key = sum[:]
+		}
+	}
+	return key[:h.keyLength]
+}
+
+// userPasswordFromOwner() implements Algorithm 7 of the PDF
+// specification, recovering the padded user password from /O using a
+// padded owner password.
+func (h *standardSecurityHandler) userPasswordFromOwner(padded []byte) []byte {
+	key := h.ownerKey(padded)
+	if h.r == 2 {
+		return rc4Crypt(key, h.o[:32])
+	}
+	user := h.o[:32]
+	for i:=19; i>=0; i-- {
+		user = rc4Crypt(xorKey(key, byte(i)), user)
+	}
+	return user
+}
+
+func (h *standardSecurityHandler) authenticateAES256(password []byte) bool {
+	// Owner password: validation salt is O[32:40]; key salt is O[40:48].
+	if bytes.Equal(h.hash(password, h.o[32:40], h.u[:48]), h.o[:32]) {
+		if key,err := aesCBCNoPadding(h.hash(password, h.o[40:48], h.u[:48]), h.oe, false); err == nil {
+			h.key = key
+			h.ownerAuthenticated = true
+			return true
+		}
+	}
+	// User password: validation salt is U[32:40]; key salt is U[40:48].
+	if bytes.Equal(h.hash(password, h.u[32:40], nil), h.u[:32]) {
+		if key,err := aesCBCNoPadding(h.hash(password, h.u[40:48], nil), h.ue, false); err == nil {
+			h.key = key
+			return true
+		}
+	}
+	return false
+}
+
+// hash() computes the password hash used by revisions 5 and 6.
+// Revision 5 uses a single SHA-256 digest.  Revision 6 uses
+// Algorithm 2.B of ISO 32000-2.
+func (h *standardSecurityHandler) hash(password, salt, userKey []byte) []byte {
+	digest := sha256.New()
+	digest.Write(password)
+	digest.Write(salt)
+	digest.Write(userKey)
+	k := digest.Sum(nil)
+	if h.r == 5 {
+		return k
+	}
+
+	for i:=0; ; i++ {
+		k1 := make([]byte, 0, 64*(len(password)+64+len(userKey)))
+		for j:=0; j<64; j++ {
+			k1 = append(k1, password...)
+			k1 = append(k1, k...)
+			k1 = append(k1, userKey...)
+		}
+		block,_ := aes.NewCipher(k[:16])
+		e := make([]byte, len(k1))
+		cipher.NewCBCEncrypter(block, k[16:32]).CryptBlocks(e, k1)
+
+		sum := 0
+		for _,b := range e[:16] {
+			sum += int(b)
+		}
+		var next hash.Hash
+		switch sum % 3 {
+		case 0:
+			next = sha256.New()
+		case 1:
+			next = sha512.New384()
+		case 2:
+			next = sha512.New()
+		}
+		next.Write(e)
+		k = next.Sum(nil)
+
+		if i >= 63 && int(e[len(e)-1]) <= i-31 {
+			break
+		}
+	}
+	return k[:32]
+}
+
+func xorKey(key []byte, x byte) []byte {
+	result := make([]byte, len(key))
+	for i,b := range key {
+		result[i] = b ^ x
+	}
+	return result
+}
+
+func rc4Crypt(key, data []byte) []byte {
+	c,err := rc4.NewCipher(key)
+	if err != nil {
+		panic(err)
+	}
+	result := make([]byte, len(data))
+	c.XORKeyStream(result, data)
+	return result
+}
+
+// aesCBCNoPadding() encrypts or decrypts data whose length is a
+// multiple of the block size using a zero initialization vector.
+func aesCBCNoPadding(key, data []byte, encrypt bool) ([]byte, error) {
+	block,err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) % aes.BlockSize != 0 {
+		return nil, invalidCipherText
+	}
+	iv := make([]byte, aes.BlockSize)
+	result := make([]byte, len(data))
+	if encrypt {
+		cipher.NewCBCEncrypter(block, iv).CryptBlocks(result, data)
+	} else {
+		cipher.NewCBCDecrypter(block, iv).CryptBlocks(result, data)
+	}
+	return result, nil
+}
+
+// objectKey() implements Algorithm 1 of the PDF specification,
+// computing the key used for strings and streams within a particular
+// indirect object.  For AES-256 the file key is used directly.
+func (h *standardSecurityHandler) objectKey(o ObjectNumber, method int) []byte {
+	if method == cryptAESV3 {
+		return h.key
+	}
+	digest := md5.New()
+	digest.Write(h.key)
+	digest.Write([]byte{
+		byte(o.number), byte(o.number>>8), byte(o.number>>16),
+		byte(o.generation), byte(o.generation>>8)})
+	if method == cryptAESV2 {
+		digest.Write([]byte("sAlT"))
+	}
+	key := digest.Sum(nil)
+	n := len(h.key) + 5
+	if n > 16 {
+		n = 16
+	}
+	return key[:n]
+}
+
+func (h *standardSecurityHandler) decrypt(o ObjectNumber, method int, data []byte) ([]byte, error) {
+	switch method {
+	case cryptNone:
+		return data, nil
+	case cryptRC4:
+		return rc4Crypt(h.objectKey(o, method), data), nil
+	}
+
+	// AESV2 and AESV3: The first block is the initialization
+	// vector and the plain text has PKCS#5 padding.
+	if len(data) < 2*aes.BlockSize || len(data) % aes.BlockSize != 0 {
+		// Some writers encrypt empty strings as an IV with
+		// no data.
+		if len(data) == aes.BlockSize {
+			return []byte{}, nil
+		}
+		return nil, invalidCipherText
+	}
+	block,err := aes.NewCipher(h.objectKey(o, method))
+	if err != nil {
+		return nil, err
+	}
+	result := make([]byte, len(data)-aes.BlockSize)
+	cipher.NewCBCDecrypter(block, data[:aes.BlockSize]).CryptBlocks(result, data[aes.BlockSize:])
+	padding := int(result[len(result)-1])
+	if padding == 0 || padding > aes.BlockSize {
+		return nil, invalidCipherText
+	}
+	return result[:len(result)-padding], nil
+}
+
+// decryptObject() decrypts, in place, all strings and streams
+// contained in the direct object read from indirect object "o".
+func (h *standardSecurityHandler) decryptObject(o ObjectNumber, object Object) error {
+	switch t := object.(type) {
+	case *stringImpl:
+		plain,err := h.decrypt(o, h.stringMethod, t.value)
+		if err != nil {
+			return err
+		}
+		t.value = plain
+	case *array:
+		for i:=0; i<t.Size(); i++ {
+			if err := h.decryptObject(o, t.At(i)); err != nil {
+				return err
+			}
+		}
+	case *dictionary:
+		for _,value := range t.dictionary {
+			if err := h.decryptObject(o, value); err != nil {
+				return err
+			}
+		}
+	case *stream:
+		if err := h.decryptObject(o, t.dictionary); err != nil {
+			return err
+		}
+		if t.dictionary.CheckNameValue("Type", "XRef") {
+			return nil
+		}
+		if !h.encryptMetadata && t.dictionary.CheckNameValue("Type", "Metadata") {
+			return nil
+		}
+		plain,err := h.decrypt(o, h.streamMethod, t.buffer.Bytes())
+		if err != nil {
+			return err
+		}
+		t.buffer = *bytes.NewBuffer(plain)
+	}
+	return nil
+}
+
+func randomBytes(n int) []byte {
+	result := make([]byte, n)
+	if _,err := rand.Read(result); err != nil {
+		panic(err)
+	}
+	return result
+}
+
+// newAES256SecurityHandler() constructs a revision 6 (AES-256)
+// security handler for a new file and returns it along with the
+// corresponding /Encrypt dictionary.  If the owner password is
+// empty, the user password is used in its place.
+func newAES256SecurityHandler(userPassword, ownerPassword string, permissions Permissions) (*standardSecurityHandler, Dictionary) {
+	if ownerPassword == "" {
+		ownerPassword = userPassword
+	}
+	user := truncatePassword([]byte(userPassword), 127)
+	owner := truncatePassword([]byte(ownerPassword), 127)
+
+	h := &standardSecurityHandler{
+		v: 5,
+		r: 6,
+		keyLength: 32,
+		p: permissions.bits(),
+		encryptMetadata: true,
+		stringMethod: cryptAESV3,
+		streamMethod: cryptAESV3,
+		key: randomBytes(32),
+		ownerAuthenticated: true}
+
+	// Algorithm 8: compute /U and /UE
+	userSalts := randomBytes(16)
+	h.u = append(h.hash(user, userSalts[:8], nil), userSalts...)
+	h.ue,_ = aesCBCNoPadding(h.hash(user, userSalts[8:], nil), h.key, true)
+
+	// Algorithm 9: compute /O and /OE
+	ownerSalts := randomBytes(16)
+	h.o = append(h.hash(owner, ownerSalts[:8], h.u), ownerSalts...)
+	h.oe,_ = aesCBCNoPadding(h.hash(owner, ownerSalts[8:], h.u), h.key, true)
+
+	// Algorithm 10: compute /Perms
+	perms := make([]byte, 16)
+	for i:=0; i<4; i++ {
+		perms[i] = byte(h.p >> uint(8*i))
+		perms[i+4] = 0xff
+	}
+	copy(perms[8:12], "Tadb")
+	copy(perms[12:], randomBytes(4))
+	block,_ := aes.NewCipher(h.key)
+	block.Encrypt(perms, perms)
+
+	hexString := func(b []byte) String {
+		s := NewBinaryString(b)
+		s.SetSerializer(HexStringSerializer)
+		return s
+	}
+
+	filter := NewDictionary()
+	filter.Add("Type", NewName("CryptFilter"))
+	filter.Add("CFM", NewName("AESV3"))
+	filter.Add("AuthEvent", NewName("DocOpen"))
+	filter.Add("Length", NewIntNumeric(32))
+	cf := NewDictionary()
+	cf.Add("StdCF", filter)
+
+	encrypt := NewDictionary()
+	encrypt.Add("Filter", NewName("Standard"))
+	encrypt.Add("V", NewIntNumeric(5))
+	encrypt.Add("R", NewIntNumeric(6))
+	encrypt.Add("Length", NewIntNumeric(256))
+	encrypt.Add("CF", cf)
+	encrypt.Add("StmF", NewName("StdCF"))
+	encrypt.Add("StrF", NewName("StdCF"))
+	encrypt.Add("O", hexString(h.o))
+	encrypt.Add("U", hexString(h.u))
+	encrypt.Add("OE", hexString(h.oe))
+	encrypt.Add("UE", hexString(h.ue))
+	encrypt.Add("P", NewIntNumeric(int(h.p)))
+	encrypt.Add("Perms", hexString(perms))
+
+	return h, encrypt
+}
+
+// permissions() returns the permissions granted by the handler.  An
+// owner has every permission.
+func (h *standardSecurityHandler) permissions() Permissions {
+	if h.ownerAuthenticated {
+		return AllPermissions()
+	}
+	return permissionsFromBits(h.p)
+}
+
+func (h *standardSecurityHandler) encrypt(o ObjectNumber, method int, data []byte) []byte {
+	switch method {
+	case cryptNone:
+		return data
+	case cryptRC4:
+		return rc4Crypt(h.objectKey(o, method), data)
+	}
+
+	// AESV2 and AESV3: Prefix a random initialization vector and
+	// apply PKCS#5 padding.
+	block,err := aes.NewCipher(h.objectKey(o, method))
+	if err != nil {
+		panic(err)
+	}
+	padding := aes.BlockSize - len(data) % aes.BlockSize
+	plain := make([]byte, len(data), len(data)+padding)
+	copy(plain, data)
+	plain = append(plain, bytes.Repeat([]byte{byte(padding)}, padding)...)
+
+	result := make([]byte, aes.BlockSize+len(plain))
+	copy(result, randomBytes(aes.BlockSize))
+	cipher.NewCBCEncrypter(block, result[:aes.BlockSize]).CryptBlocks(result[aes.BlockSize:], plain)
+	return result
+}
+
+// encrypter() returns a writer that encrypts what is written to it
+// as encrypt() does, writing the result to w.  Its Close() writes any
+// padding and closes w.
+func (h *standardSecurityHandler) encrypter(o ObjectNumber, method int, w io.WriteCloser) io.WriteCloser {
+	switch method {
+	case cryptNone:
+		return w
+	case cryptRC4:
+		c,err := rc4.NewCipher(h.objectKey(o, method))
+		if err != nil {
+			panic(err)
+		}
+		return cipher.StreamWriter{S: c, W: w}
+	}
+	block,err := aes.NewCipher(h.objectKey(o, method))
+	if err != nil {
+		panic(err)
+	}
+	iv := randomBytes(aes.BlockSize)
+	return &cbcWriter{mode: cipher.NewCBCEncrypter(block, iv), w: w, iv: iv}
+}
+
+// streamEncrypter() returns the dictionary and encoder of a stream
+// whose contents come from a reader, written as indirect object o.
+// The contents are encrypted after the stream's filters encode them.
+func (h *standardSecurityHandler) streamEncrypter(o ObjectNumber, dictionary Dictionary, encoder func(io.WriteCloser) io.WriteCloser, file ...File) (Dictionary, func(io.WriteCloser) io.WriteCloser) {
+	dictionary = h.encryptDictionary(o, dictionary, file...)
+	if !h.encryptMetadata && dictionary.CheckNameValue("Type", "Metadata") {
+		return dictionary, encoder
+	}
+	return dictionary, func(w io.WriteCloser) io.WriteCloser {
+		return encoder(h.encrypter(o, h.streamMethod, w))
+	}
+}
+
+// cbcWriter encrypts with AES in CBC mode as it is written, preceded
+// by the initialization vector and followed by PKCS#5 padding.
+type cbcWriter struct {
+	mode cipher.BlockMode
+	w io.WriteCloser
+	// iv is written before the first block and is then nil.
+	iv []byte
+	// partial holds the bytes written since the last full block.
+	partial []byte
+}
+
+func (c *cbcWriter) Write(p []byte) (int, error) {
+	if c.iv != nil {
+		if _,err := c.w.Write(c.iv); err != nil {
+			return 0, err
+		}
+		c.iv = nil
+	}
+	data := append(c.partial, p...)
+	full := len(data) - len(data) % aes.BlockSize
+	if full > 0 {
+		encrypted := make([]byte, full)
+		c.mode.CryptBlocks(encrypted, data[:full])
+		if _,err := c.w.Write(encrypted); err != nil {
+			return 0, err
+		}
+	}
+	c.partial = append([]byte(nil), data[full:]...)
+	return len(p), nil
+}
+
+func (c *cbcWriter) Close() error {
+	padding := aes.BlockSize - len(c.partial)
+	if _,err := c.Write(bytes.Repeat([]byte{byte(padding)}, padding)); err != nil {
+		return err
+	}
+	return c.w.Close()
+}
+
+// encryptObject() returns a copy of the direct object to be written
+// as indirect object "o" in which all strings and streams have been
+// encrypted.  The original object is unchanged.
+func (h *standardSecurityHandler) encryptObject(o ObjectNumber, object Object, file ...File) Object {
+	switch t := object.(type) {
+	case ProtectedIndirect:
+		return object
+	case *stream:
+		dictionary, contents, err := t.encode(file...)
+		if err != nil {
+			panic(fmt.Sprintf("Unable to write contents of stream %d: %v", o.number, err))
+		}
+		dictionary = h.encryptDictionary(o, dictionary, file...)
+		if h.encryptMetadata || !dictionary.CheckNameValue("Type", "Metadata") {
+			contents = h.encrypt(o, h.streamMethod, contents)
+		}
+		return &stream{dictionary, *bytes.NewBuffer(contents), nil, nil, 0}
+	case protectedStream:
+		return h.encryptObject(o, t.s, file...)
+	case ProtectString:
+		result := NewBinaryString(h.encrypt(o, h.stringMethod, t.Bytes()))
+		// Encrypted strings are binary.  Hex avoids any end-of-line
+		// translation by readers.
+		result.SetSerializer(HexStringSerializer)
+		return result
+	case ProtectedArray:
+		result := NewArray()
+		for i:=0; i<t.Size(); i++ {
+			result.Add(h.encryptObject(o, t.At(i), file...))
+		}
+		return result
+	case ProtectedDictionary:
+		return h.encryptDictionary(o, t, file...)
+	}
+	return object
+}
+
+func (h *standardSecurityHandler) encryptDictionary(o ObjectNumber, d ProtectedDictionary, file ...File) Dictionary {
+	result := NewDictionary()
+	for _,key := range d.Keys() {
+		result.Add(key, h.encryptObject(o, d.Get(key), file...))
+	}
+	return result
+}
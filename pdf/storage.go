@@ -0,0 +1,208 @@
+package pdf
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"sync" )
+
+// storage is what a file is read from and written to.  *os.File
+// implements it.  The types below adapt the readers and writers
+// accepted by NewFileFromReader(), NewFileFromWriter(), and
+// NewFileFromReadWriteSeeker() to it.
+type storage interface {
+	io.ReadWriteSeeker
+	io.ReaderAt
+	io.Closer
+}
+
+var (
+	readOnlyFile = errors.New(`File was opened for reading only`)
+	writeOnlyFile = errors.New(`File was opened for writing only`)
+	cannotSeek = errors.New(`Cannot seek in a file opened for writing only`) )
+
+// streamStorage adapts an io.ReadWriteSeeker to storage.  If ra is
+// nil, ReadAt() is emulated by seeking, and a mutex serializes all
+// access so that reads don't disturb the position at which the file
+// is written.  Close() closes closer, if it is not nil, but not the
+// stream itself, which belongs to the caller.
+type streamStorage struct {
+	mutex sync.Mutex
+	rws io.ReadWriteSeeker
+	ra io.ReaderAt
+	closer io.Closer
+}
+
+func newStreamStorage(rws io.ReadWriteSeeker, ra io.ReaderAt, closer io.Closer) *streamStorage {
+	return &streamStorage{rws: rws, ra: ra, closer: closer}
+}
+
+func (s *streamStorage) Read(p []byte) (int, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.rws.Read(p)
+}
+
+func (s *streamStorage) Write(p []byte) (int, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.rws.Write(p)
+}
+
+func (s *streamStorage) Seek(offset int64, whence int) (int64, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.rws.Seek(offset, whence)
+}
+
+func (s *streamStorage) ReadAt(p []byte, offset int64) (n int, err error) {
+	if s.ra != nil {
+		return s.ra.ReadAt(p, offset)
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	save,err := s.rws.Seek(0, os.SEEK_CUR)
+	if err != nil {
+		return 0, err
+	}
+	defer s.rws.Seek(save, os.SEEK_SET)
+	if _,err = s.rws.Seek(offset, os.SEEK_SET); err != nil {
+		return 0, err
+	}
+	return io.ReadFull(s.rws, p)
+}
+
+func (s *streamStorage) Close() error {
+	if s.closer != nil {
+		return s.closer.Close()
+	}
+	return nil
+}
+
+// readOnly adds a Write() method that always fails to an
+// io.ReadSeeker.
+type readOnly struct {
+	io.ReadSeeker
+}
+
+func (readOnly) Write(p []byte) (int, error) {
+	return 0, readOnlyFile
+}
+
+// writeOnlyStorage adapts an io.Writer to storage.  It tracks the
+// number of bytes written so that Seek() can report the position,
+// but it can't move it.
+type writeOnlyStorage struct {
+	w io.Writer
+	position int64
+}
+
+func (s *writeOnlyStorage) Read(p []byte) (int, error) {
+	return 0, writeOnlyFile
+}
+
+func (s *writeOnlyStorage) ReadAt(p []byte, offset int64) (int, error) {
+	return 0, writeOnlyFile
+}
+
+func (s *writeOnlyStorage) Write(p []byte) (int, error) {
+	n,err := s.w.Write(p)
+	s.position += int64(n)
+	return n, err
+}
+
+// Seek() succeeds only if it leaves the position unchanged, as do
+// Seek(0, os.SEEK_CUR) and Seek(0, os.SEEK_END).
+func (s *writeOnlyStorage) Seek(offset int64, whence int) (int64, error) {
+	position := offset
+	switch whence {
+	case os.SEEK_CUR, os.SEEK_END:
+		position += s.position
+	}
+	if position != s.position {
+		return s.position, cannotSeek
+	}
+	return s.position, nil
+}
+
+func (s *writeOnlyStorage) Close() error {
+	return nil
+}
+
+// NewFileFromReader() constructs a read-only File from the
+// pre-existing PDF in r, such as a bytes.Reader or an HTTP range
+// reader.  If r implements io.ReaderAt, as bytes.Reader and
+// io.SectionReader do, objects are read with ReadAt(); otherwise r is
+// shared by seeking.  Nothing may be written to the File, and
+// Close() does not close r.
+func NewFileFromReader(r io.ReadSeeker, options ...FileOption) (*file, error) {
+	return newFileFromReader(r, nil, options...)
+}
+
+func newFileFromReader(r io.ReadSeeker, closer io.Closer, options ...FileOption) (*file, error) {
+	ra,_ := r.(io.ReaderAt)
+	f,exists,err := openStorage(newStreamStorage(readOnly{r}, ra, closer), options...)
+	if err == nil && !exists {
+		f.abandon()
+		return nil, errors.New(`No PDF found in reader`)
+	}
+	return f, err
+}
+
+// NewFileFromWriter() constructs a new File that is written
+// sequentially to w, such as an HTTP response, without seeking.
+// Objects can't be read back once they have been written, so
+// Object() fails for them.  Close() writes the xref and trailer but
+// does not close w.  With WithStreamingOutput(), streams aren't
+// buffered either.
+func NewFileFromWriter(w io.Writer, options ...FileOption) (*file, error) {
+	f,_,err := openStorage(&writeOnlyStorage{w: w}, options...)
+	return f, err
+}
+
+// WithStreamingOutput() returns a FileOption that writes every stream
+// the way streams constructed by NewStreamFromReader() are written:
+// its contents are encoded as they are copied to the file, rather than
+// into a buffer first, and /Length refers to an object written after
+// the stream.  Together with NewFileFromWriter(), which tracks byte
+// offsets by counting what is written and never seeks, it generates a
+// PDF directly into an HTTP response or a pipe using no more memory
+// for each stream than its unencoded contents.  It has no effect on
+// files written with WithInspectableOutput().
+func WithStreamingOutput() FileOption {
+	return func(f *file) {
+		f.streaming = true
+	}
+}
+
+// NewFileFromReadWriteSeeker() constructs a File from rws in the way
+// OpenFile() does from a filename: if rws is empty, a new PDF is
+// written to it; otherwise the PDF in it is read and any changes are
+// appended as an incremental update.  Close() does not close rws.
+func NewFileFromReadWriteSeeker(rws io.ReadWriteSeeker, options ...FileOption) (result *file, exists bool, err error) {
+	ra,_ := rws.(io.ReaderAt)
+	return openStorage(newStreamStorage(rws, ra, nil), options...)
+}
+
+// OpenFileFS() constructs a read-only File from the named file in
+// fsys, such as an embed.FS.  If the file isn't seekable, its
+// contents are read into memory.
+func OpenFileFS(fsys fs.FS, name string, options ...FileOption) (*file, error) {
+	f,err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	if rs,ok := f.(io.ReadSeeker); ok {
+		// f is closed along with the File, or on failure.
+		return newFileFromReader(rs, f, options...)
+	}
+	contents,err := ioutil.ReadAll(f)
+	f.Close()
+	if err != nil {
+		return nil, err
+	}
+	return NewFileFromReader(bytes.NewReader(contents), options...)
+}
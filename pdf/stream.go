@@ -3,7 +3,10 @@ package pdf
 import (
 	"bytes"
 	"container/list"
-	"io")
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil")
 
 // Implements:
 // 	pdf.Object
@@ -11,6 +14,8 @@ import (
 type ProtectedStream interface {
 	Object
 	Reader() (result io.Reader)
+	// Dictionary() returns the stream dictionary.
+	Dictionary() ProtectedDictionary
 }
 
 // Implements:
@@ -20,6 +25,10 @@ type Stream interface {
 	ProtectedStream
 	io.Writer
 	AddFilter(filter StreamFilterFactory)
+	// Add() stores an object under the specified key in the
+	// stream dictionary.  The /Length, /Filter, and /DecodeParms
+	// entries are managed by the stream.
+	Add(key string, object Object)
 	Remove(key string)
 }
 
@@ -33,15 +42,45 @@ type stream struct {
 	// same filters.  Therefore, filters encountered while reading
 	// are added to the filter list.
 	filterList *list.List
+	// source, if not nil, supplies the contents in place of
+	// buffer so that they need not be held in memory.
+	source func() io.Reader
+	// maxDecodedSize, if not 0, is the MaxStreamSize limit of the
+	// file from which the stream was read.
+	maxDecodedSize int64
 }
 
+var streamHasSource = errors.New(`Cannot write to a stream whose contents come from a reader`)
+
 // Constructor for standard implementation of Stream.
 func NewStream() Stream {
-	return &stream{NewDictionary(), bytes.Buffer{}, nil}
+	return &stream{NewDictionary(), bytes.Buffer{}, nil, nil, 0}
 }
 
 func NewStreamFromContents(dictionary Dictionary,b []byte, filterList *list.List) Stream {
-	return &stream{dictionary, *bytes.NewBuffer(b), filterList}
+	return &stream{dictionary, *bytes.NewBuffer(b), filterList, nil, 0}
+}
+
+// NewStreamFromReader() constructs a Stream whose contents are read
+// from r when the stream is written, rather than held in memory, so
+// that very large embedded files and images can be written with a
+// fixed amount of memory.  When such a stream is written to a File,
+// its contents are copied through any filters, and encrypted if the
+// File is, directly to the file in a single pass.  /Length is written
+// as a separate object following the stream.  Since r can be read
+// only once, the stream should be written to a single File, and
+// Reader() should not be called before it is written.  Write()
+// returns an error.  For a region of a file, r may be an
+// io.SectionReader.
+func NewStreamFromReader(r io.Reader) Stream {
+	return &stream{NewDictionary(), bytes.Buffer{}, nil, func() io.Reader { return r }, 0}
+}
+
+// newStreamFromRegion() constructs a stream whose encoded contents are
+// in region, which is read each time the contents are needed.
+func newStreamFromRegion(dictionary Dictionary, region *io.SectionReader) Stream {
+	return &stream{dictionary, bytes.Buffer{}, nil,
+		func() io.Reader { return io.NewSectionReader(region, 0, region.Size()) }, 0}
 }
 
 func (s *stream) AddFilter(filter StreamFilterFactory) {
@@ -51,15 +90,21 @@ func (s *stream) AddFilter(filter StreamFilterFactory) {
 	s.filterList.PushBack(filter)
 }
 
-func (s *stream) Clone() Object {
-	var newFilterList *list.List
-	if s.filterList != nil {
-		newFilterList = list.New()
-		for item:=s.filterList.Front(); item != nil; item = item.Next() {
-			newFilterList.PushBack(item.Value)
-		}
+// copyFilterList() returns a copy of filters, which may be nil.
+func copyFilterList(filters *list.List) *list.List {
+	if filters == nil {
+		return nil
+	}
+	result := list.New()
+	for item:=filters.Front(); item != nil; item = item.Next() {
+		result.PushBack(item.Value)
 	}
-	return NewStreamFromContents(s.dictionary,s.buffer.Bytes(), newFilterList)
+	return result
+}
+
+func (s *stream) Clone() Object {
+	contents := append([]byte(nil), s.buffer.Bytes()...)
+	return &stream{s.dictionary.Clone().(Dictionary), *bytes.NewBuffer(contents), copyFilterList(s.filterList), s.source, s.maxDecodedSize}
 }
 
 func (s *stream) Dereference() Object {
@@ -77,31 +122,94 @@ func (s *stream) Unprotect() Object {
 }
 
 func (s *stream) Reader() (result io.Reader) {
-	result = bytes.NewReader(s.buffer.Bytes())
-	if filters := s.dictionary.GetArray("Filter"); filters != nil {
-		parms := s.dictionary.GetArray("DecodeParms")
-		for i:=0; i<filters.Size(); i++ {
-			if n,ok := filters.At(i).(Name); ok {
-				var d Dictionary
+	filters,parameters := streamFilters(s.dictionary)
+	return limitDecoded(s, decodeFilters(s.contents(), filters, parameters))
+}
+
+// contents() returns a reader for the stream's contents without any
+// filters applied.
+func (s *stream) contents() io.Reader {
+	if s.source != nil {
+		return s.source()
+	}
+	return bytes.NewReader(s.buffer.Bytes())
+}
+
+// streamFilters() returns the names of the filters listed in a stream
+// dictionary, in the order in which they are to be applied when
+// decoding, along with their decode parameters.  Filters without
+// parameters have nil entries.
+func streamFilters(dictionary ProtectedDictionary) (filters []string, parameters []ProtectedDictionary) {
+	if array := dictionary.GetArray("Filter"); array != nil {
+		parms := dictionary.GetArray("DecodeParms")
+		for i:=0; i<array.Size(); i++ {
+			if n,ok := array.At(i).Dereference().(Name); ok {
+				var d ProtectedDictionary
 				if parms != nil && i < parms.Size() {
-					d,_ = parms.At(i).(Dictionary)
-				}
-				if sff := FilterFactory(n.String(),d); sff != nil {
-					result = sff.NewDecoder(result)
-				} else {
-					return nil
+					d,_ = parms.At(i).Dereference().(ProtectedDictionary)
 				}
+				filters = append(filters, n.String())
+				parameters = append(parameters, d)
 			}
 		}
-	} else if n,ok := s.dictionary.GetName("Filter"); ok {
-		d := s.dictionary.GetDictionary("DecodeParms")
-		if sff := FilterFactory(n,d); sff != nil {
-			result = sff.NewDecoder(result)
-		} else {
+	} else if n,ok := dictionary.GetName("Filter"); ok {
+		filters = []string{n}
+		parameters = []ProtectedDictionary{dictionary.GetDictionary("DecodeParms")}
+	}
+	return filters, parameters
+}
+
+var unsupportedFilter = errors.New(`Unsupported stream filter`)
+
+// decodeFilters() applies the decoders for the listed filters to r.
+// It returns nil if any of the filters is not supported.
+func decodeFilters(r io.Reader, filters []string, parameters []ProtectedDictionary) io.Reader {
+	for i,name := range filters {
+		sff := FilterFactory(name, parameters[i])
+		if sff == nil {
 			return nil
 		}
+		r = filterErrorReader{sff.NewDecoder(r), name}
 	}
-	return result
+	return r
+}
+
+// limitDecoded() applies the limit on the decoded size of ps, if it has
+// one, to r, which decodes ps and may be nil.
+func limitDecoded(ps ProtectedStream, r io.Reader) io.Reader {
+	switch s := ps.(type) {
+	case *stream:
+		if r != nil && s.maxDecodedSize > 0 {
+			return &sizeLimitReader{r: r, limit: s.maxDecodedSize}
+		}
+	case protectedStream:
+		return limitDecoded(s.s, r)
+	}
+	return r
+}
+
+// encodedData() returns the contents of a stream without any filters
+// applied.  An error is returned if they can't be read from the
+// stream's source.
+func encodedData(ps ProtectedStream) ([]byte, error) {
+	switch s := ps.(type) {
+	case *stream:
+		if s.source != nil {
+			return ioutil.ReadAll(s.source())
+		}
+		return s.buffer.Bytes(), nil
+	case protectedStream:
+		return encodedData(s.s)
+	}
+	return nil, nil
+}
+
+func (s *stream) Dictionary() ProtectedDictionary {
+	return s.dictionary.Protect().(ProtectedDictionary)
+}
+
+func (s *stream) Add(key string, object Object) {
+	s.dictionary.Add(key, object)
 }
 
 func (s *stream) Remove(key string) {
@@ -109,22 +217,83 @@ func (s *stream) Remove(key string) {
 }
 
 func (s *stream) Write(bytes []byte) (int, error) {
+	if s.source != nil {
+		return 0, streamHasSource
+	}
 	return s.buffer.Write(bytes)
 }
 
-func (s *stream) Serialize(w Writer, file ...File) {
+// encode() applies the stream's filters to its contents.  It returns
+// the encoded contents along with the dictionary that should
+// accompany them, which names the filters and their decode
+// parameters but does not contain a /Length entry.  An error is
+// returned if the contents can't be read or encoded.
+func (s *stream) encode(file ...File) (Dictionary, []byte, error) {
+	if forInspection(file...) && !s.dictionary.CheckNameValue("Subtype", "Image") {
+		if dictionary,contents,ok := s.decoded(); ok {
+			return dictionary, contents, nil
+		}
+	}
+	s = s.transcoded(file...)
+	dictionary,encoder := s.encoder(file...)
 	streamBuffer := NewBufferCloser()
+	streamWriter := encoder(streamBuffer)
+	_,err := io.Copy(streamWriter, s.contents())
+	if closeErr := streamWriter.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return dictionary, streamBuffer.Bytes(), nil
+}
+
+// decoded() returns the stream's contents with all of its filters
+// undone, along with its dictionary without /Filter, /DecodeParms, or
+// /Length, for files written with WithInspectableOutput().  The
+// boolean return value is false if a filter isn't supported or fails.
+func (s *stream) decoded() (Dictionary, []byte, bool) {
+	filters,parameters := streamFilters(s.dictionary)
+	reader := limitDecoded(s, decodeFilters(s.contents(), filters, parameters))
+	if reader == nil {
+		return nil, nil, false
+	}
+	contents,err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, nil, false
+	}
 	dictionary := s.dictionary.Clone().(Dictionary)
+	dictionary.Remove("Filter")
+	dictionary.Remove("DecodeParms")
+	dictionary.Remove("Length")
+	return dictionary, contents, true
+}
 
-	var streamWriter io.WriteCloser = streamBuffer
+// encoder() returns the dictionary that should accompany the encoded
+// contents, as described for encode(), along with a function that
+// applies the stream's filters to data written to a writer.
+func (s *stream) encoder(file ...File) (Dictionary, func(io.WriteCloser) io.WriteCloser) {
+	dictionary := s.dictionary.Clone().(Dictionary)
+	filterList := s.filterList
+	if level,ok := compressionLevel(streamCategory(s.dictionary), file...); ok {
+		filterList = withFlateLevel(filterList, level)
+	}
+	encoder := func(w io.WriteCloser) io.WriteCloser {
+		if filterList != nil {
+			for item:=filterList.Front(); item != nil; item = item.Next() {
+				w = item.Value.(StreamFilterFactory).NewEncoder(w)
+			}
+		}
+		return w
+	}
 
-	if s.filterList != nil && s.filterList.Front() != nil {
+	if filterList != nil && filterList.Front() != nil {
 		filters := NewArray()
 		decodeParameters := NewArray()
 		needDecodeParameters := false
 
-		for item:=s.filterList.Front(); item != nil; item = item.Next() {
-			streamWriter = item.Value.(StreamFilterFactory).NewEncoder(streamWriter)
+		for item:=filterList.Front(); item != nil; item = item.Next() {
 			filters.Add (NewName(item.Value.(StreamFilterFactory).Name()))
 			decodeParms := item.Value.(StreamFilterFactory).DecodeParms(file...)
 			decodeParameters.Add (decodeParms)
@@ -168,14 +337,20 @@ func (s *stream) Serialize(w Writer, file ...File) {
 		}
 	}
 
-	streamWriter.Write(s.buffer.Bytes())
-	streamWriter.Close()
+	return dictionary, encoder
+}
 
-	dictionary.Add("Length", NewIntNumeric(streamBuffer.Len()))
+func (s *stream) Serialize(w Writer, file ...File) {
+	dictionary, contents, err := s.encode(file...)
+	if err != nil {
+		panic(fmt.Sprintf("Unable to write contents of stream: %v", err))
+	}
+
+	dictionary.Add("Length", NewIntNumeric(len(contents)))
 	dictionary.Serialize(w, file...)
 
 	w.WriteString("\nstream\n")
-	w.Write(streamBuffer.Bytes())
+	w.Write(contents)
 	w.WriteString("\nendstream")
 }
 
@@ -208,6 +383,10 @@ func (ps protectedStream) Reader() io.Reader {
 	return ps.s.Reader()
 }
 
+func (ps protectedStream) Dictionary() ProtectedDictionary {
+	return ps.s.Dictionary()
+}
+
 func (ps protectedStream) Serialize(w Writer, file ...File) {
 	ps.s.Serialize(w, file...)
 }
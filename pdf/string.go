@@ -61,7 +61,7 @@ func (s *stringImpl) Dereference() Object {
 // Return value of Protect() can safely be cast to ProtectString
 // but not String.
 func (s *stringImpl) Protect() Object {
-	return &readOnlyString{s}
+	return readOnlyString{s}
 }
 
 // Return value of Unprotect() can safely be cast to String or
@@ -70,6 +70,15 @@ func (s *stringImpl) Unprotect() Object {
 	return s
 }
 
+// stringValue() returns the bytes of s, which the caller must not
+// modify, without copying them if s is a stringImpl.
+func stringValue(s String) []byte {
+	if impl,ok := s.(*stringImpl); ok {
+		return impl.value
+	}
+	return s.Bytes()
+}
+
 func (s *stringImpl) Serialize(w Writer, file ...File) {
 	s.serializer(s, w)
 }
@@ -82,8 +91,9 @@ func (ros readOnlyString) Bytes() []byte {
 	return ros.s.Bytes()
 }
 
+// Return value of Clone() can safely be cast to String.
 func (ros readOnlyString) Clone() Object {
-	return ros
+	return ros.s.Clone()
 }
 
 func (ros readOnlyString) Dereference() Object {
@@ -106,20 +116,14 @@ func (ros readOnlyString) Serialize(w Writer, file ...File) {
 	ros.s.Serialize(w, file...)
 }
 
-func stringMinimalEscapeByte(b byte) (result []byte) {
-	switch b {
-	case '(', ')', '\\':
-		result = []byte{'\\', b}
-	default:
-		result = []byte{b}
-	}
-	return result
-}
-
 func NormalStringSerializer(s String, w Writer) {
 	w.WriteByte('(')
-	for _, b := range s.Bytes() {
-		w.Write(stringMinimalEscapeByte(b))
+	for _, b := range stringValue(s) {
+		switch b {
+		case '(', ')', '\\':
+			w.WriteByte('\\')
+		}
+		w.WriteByte(b)
 	}
 	w.WriteByte(')')
 	return
@@ -187,8 +191,12 @@ func GeneralAsciiEscapeByte(b byte) (result []byte) {
 
 func AsciiStringSerializer(s String, w Writer) {
 	w.WriteByte('(')
-	for _, b := range s.Bytes() {
-		w.Write(stringAsciiEscapeByte(b))
+	for _, b := range stringValue(s) {
+		if b < 128 && b != '(' && b != ')' && b != '\\' && unicode.IsPrint(rune(b)) {
+			w.WriteByte(b)
+		} else {
+			w.Write(stringAsciiEscapeByte(b))
+		}
 	}
 	w.WriteByte(')')
 	return
@@ -196,7 +204,7 @@ func AsciiStringSerializer(s String, w Writer) {
 
 func HexStringSerializer(s String, w Writer) {
 	w.WriteByte('<')
-	for _, b := range s.Bytes() {
+	for _, b := range stringValue(s) {
 		w.WriteByte(HexDigit(b / 16))
 		w.WriteByte(HexDigit(b % 16))
 	}
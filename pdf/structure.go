@@ -0,0 +1,247 @@
+package pdf
+
+import "strconv"
+
+// Standard structure types.  Clients may use other types provided
+// they are mapped to standard types in the structure tree's role map.
+const (
+	TagDocument = "Document"
+	TagPart = "Part"
+	TagSect = "Sect"
+	TagDiv = "Div"
+	TagP = "P"
+	TagH1 = "H1"
+	TagH2 = "H2"
+	TagH3 = "H3"
+	TagH4 = "H4"
+	TagH5 = "H5"
+	TagH6 = "H6"
+	TagL = "L"
+	TagLI = "LI"
+	TagLbl = "Lbl"
+	TagLBody = "LBody"
+	TagSpan = "Span"
+	TagLink = "Link"
+	TagFigure = "Figure"
+	TagTable = "Table"
+	TagTR = "TR"
+	TagTH = "TH"
+	TagTD = "TD" )
+
+// A StructureTree is the logical structure of a tagged document.  It
+// is obtained from Document.StructureTree() and written when the
+// document is closed.
+type StructureTree struct {
+	file File
+	indirect Indirect
+	kids Array
+	roleMap Dictionary
+
+	// parentTree maps the /StructParents key of each page to an
+	// array of the structure elements containing each of its
+	// marked-content sequences, indexed by MCID.
+	parentTree []Array
+
+	// elements contains every element created so they can be
+	// written when the tree is finished.
+	elements []*StructureElement
+}
+
+// A StructureElement is a node in a StructureTree.  Content is
+// associated with an element by enclosing it in a marked-content
+// sequence with Page.BeginMarkedContent() and
+// Page.EndMarkedContent().
+type StructureElement struct {
+	tree *StructureTree
+	indirect Indirect
+	dictionary Dictionary
+	kids Array
+
+	// page is the page named by the element's /Pg entry.
+	page *Page
+}
+
+func newStructureTree(file File) *StructureTree {
+	return &StructureTree{
+		file: file,
+		indirect: NewIndirect(file),
+		kids: NewArray()}
+}
+
+func (t *StructureTree) newElement(tag string, parent Indirect) *StructureElement {
+	e := &StructureElement{
+		tree: t,
+		indirect: NewIndirect(t.file),
+		dictionary: NewDictionary(),
+		kids: NewArray()}
+
+	e.dictionary.Add("Type", NewName("StructElem"))
+	e.dictionary.Add("S", NewName(tag))
+	e.dictionary.Add("P", parent)
+	e.dictionary.Add("K", e.kids)
+
+	t.elements = append(t.elements, e)
+	return e
+}
+
+// NewElement() adds a top-level structure element of type tag to the
+// tree.  Normally, a tree has a single top-level element of type
+// TagDocument.
+func (t *StructureTree) NewElement(tag string) *StructureElement {
+	e := t.newElement(tag, t.indirect)
+	t.kids.Add(e.indirect)
+	return e
+}
+
+// MapRole() adds an entry to the role map mapping the non-standard
+// structure type tag to a standard structure type.
+func (t *StructureTree) MapRole(tag string, standard string) {
+	if t.roleMap == nil {
+		t.roleMap = NewDictionary()
+	}
+	t.roleMap.Add(tag, NewName(standard))
+}
+
+// newParentTreeEntry() reserves a /StructParents key for a page and
+// returns the key along with the array that will hold the page's
+// marked-content parents.
+func (t *StructureTree) newParentTreeEntry() (int, Array) {
+	parents := NewArray()
+	t.parentTree = append(t.parentTree, parents)
+	return len(t.parentTree)-1, parents
+}
+
+// finish() writes the tree's elements and returns a reference to the
+// structure tree root.
+func (t *StructureTree) finish() Indirect {
+	for _,e := range t.elements {
+		e.indirect.Write(e.dictionary)
+	}
+	t.elements = nil
+
+	parentTree := NewNumberTree()
+	for key,parents := range t.parentTree {
+		parentTree.Add(key, NewIndirect(t.file).Write(parents))
+	}
+
+	root := NewDictionary()
+	root.Add("Type", NewName("StructTreeRoot"))
+	root.Add("K", t.kids)
+	root.Add("ParentTree", NewIndirect(t.file).Write(parentTree.Build(t.file)))
+	root.Add("ParentTreeNextKey", NewIntNumeric(len(t.parentTree)))
+	if t.roleMap != nil {
+		root.Add("RoleMap", t.roleMap)
+	}
+	return t.indirect.Write(root)
+}
+
+// NewElement() adds a child structure element of type tag to e.
+func (e *StructureElement) NewElement(tag string) *StructureElement {
+	child := e.tree.newElement(tag, e.indirect)
+	e.kids.Add(child.indirect)
+	return child
+}
+
+// Tag() returns the structure type of e.
+func (e *StructureElement) Tag() string {
+	tag,_ := e.dictionary.GetName("S")
+	return tag
+}
+
+// SetAlt() sets the alternate description of e, which is required
+// for figures and formulas in accessible documents.
+func (e *StructureElement) SetAlt(text string) {
+	e.dictionary.Add("Alt", NewTextString(text))
+}
+
+// SetActualText() sets text that is an exact replacement for the
+// content of e, for example, the text represented by an image of a
+// drop cap.
+func (e *StructureElement) SetActualText(text string) {
+	e.dictionary.Add("ActualText", NewTextString(text))
+}
+
+// SetTitle() sets the title of e.
+func (e *StructureElement) SetTitle(text string) {
+	e.dictionary.Add("T", NewTextString(text))
+}
+
+// SetLanguage() sets the natural language of the content of e as a
+// language tag such as "en-US".
+func (e *StructureElement) SetLanguage(language string) {
+	e.dictionary.Add("Lang", NewTextString(language))
+}
+
+// addContent() records that the marked-content sequence with the
+// specified MCID on page p belongs to e.
+func (e *StructureElement) addContent(p *Page, mcid int) {
+	switch e.page {
+	case nil:
+		e.page = p
+		e.dictionary.Add("Pg", p.indirect)
+		fallthrough
+	case p:
+		e.kids.Add(NewIntNumeric(mcid))
+	default:
+		reference := NewDictionary()
+		reference.Add("Type", NewName("MCR"))
+		reference.Add("Pg", p.indirect)
+		reference.Add("MCID", NewIntNumeric(mcid))
+		e.kids.Add(reference)
+	}
+}
+
+// BeginMarkedContent() begins a marked-content sequence on the page
+// whose content belongs to the structure element e.  Every call must
+// be matched by a call to EndMarkedContent().
+func (p *Page) BeginMarkedContent(e *StructureElement) {
+	if p.contents == nil {
+		panic ("BeginMarkedContent() called on closed page")
+	}
+	if p.structParents == nil {
+		key,parents := e.tree.newParentTreeEntry()
+		p.dictionary.dictionary.Add("StructParents", NewIntNumeric(key))
+		p.structParents = parents
+	}
+
+	mcid := p.structParents.Size()
+	p.structParents.Add(e.indirect)
+	e.addContent(p, mcid)
+
+	p.Write([]byte(" /" + e.Tag() + " <</MCID " + strconv.Itoa(mcid) + ">> BDC "))
+}
+
+// BeginArtifact() begins a marked-content sequence containing content,
+// such as page numbers or decoration, that is not part of the logical
+// structure.  Every call must be matched by a call to
+// EndMarkedContent().
+func (p *Page) BeginArtifact() {
+	p.Write([]byte(" /Artifact BMC "))
+}
+
+// EndMarkedContent() ends the marked-content sequence begun by the
+// most recent call to BeginMarkedContent() or BeginArtifact().
+func (p *Page) EndMarkedContent() {
+	p.Write([]byte(" EMC "))
+}
+
+// StructureTree() returns the document's structure tree, creating it
+// if necessary.  A document with a structure tree is written as a
+// tagged document.  If a pre-existing document has a structure tree,
+// it is replaced.
+func (d *Document) StructureTree() *StructureTree {
+	if d.structureTree == nil {
+		d.structureTree = newStructureTree(d.file)
+	}
+	return d.structureTree
+}
+
+func (d *Document) finishStructureTree() {
+	if d.structureTree != nil {
+		d.catalog.Add("StructTreeRoot", d.structureTree.finish())
+		markInfo := NewDictionary()
+		markInfo.Add("Marked", NewBoolean(true))
+		d.catalog.Add("MarkInfo", markInfo)
+		d.structureTree = nil
+	}
+}
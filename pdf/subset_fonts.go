@@ -0,0 +1,648 @@
+package pdf
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"sort" )
+
+var cannotSubsetEncrypted = errors.New(`Fonts of encrypted files cannot be subset`)
+
+// SubsetFonts() reads the PDF file named input and writes to output
+// the objects reachable from its trailer, as Compact() does, with each
+// fully embedded font program replaced by a subset containing only the
+// glyphs the document shows.  The glyphs shown are found by
+// interpreting the contents of the pages, of the forms they draw, and
+// of the patterns, soft masks, Type3 glyphs, and annotation
+// appearances in their resources.  As with fonts embedded by this
+// package, the glyphs that aren't shown are emptied rather than
+// removed, so glyph IDs, and so the strings already shown, remain
+// valid.  The names of subset fonts are prefixed with a subset tag.
+//
+// TrueType programs (FontFile2) and OpenType and CFF programs
+// (FontFile3) are subset when used by TrueType fonts or by Type0 fonts
+// with a known CMap.  Programs that are already subset, whose license
+// forbids subsetting, that are used by fonts of the interactive form's
+// default resources, which viewers use to fill in fields, or by font
+// dictionaries that aren't indirect objects, and Type 1 programs are
+// kept as they are, as is any program the subset of which wouldn't be
+// smaller.  Input and output may name the same file.  Options are
+// passed to OpenFile() when reading input.
+func SubsetFonts(input, output string, options ...FileOption) error {
+	return rewriteFile(input, output, options, nil, subsetFonts)
+}
+
+func subsetFonts(destination *file, source File) error {
+	if source.Trailer().Get("Encrypt") != nil {
+		destination.abandon()
+		return cannotSubsetEncrypted
+	}
+	catalog := source.Catalog()
+	if catalog == nil || catalog.GetDictionary("Pages") == nil {
+		return copyReachable(destination, source, false, nil)
+	}
+
+	collector := newGlyphCollector(source)
+	if form := catalog.GetDictionary("AcroForm"); form != nil {
+		if resources := form.GetDictionary("DR"); resources != nil {
+			collector.exclude(resources.GetDictionary("Font"))
+		}
+	}
+	document := newDocument(source, true)
+	for n:=uint(0); n<document.PageCount(); n++ {
+		if err := destination.canceled(); err != nil {
+			destination.abandon()
+			return err
+		}
+		page := document.Page(n)
+		if page == nil {
+			break
+		}
+		resources := page.GetDictionary("Resources")
+		if reader := page.Reader(); reader != nil {
+			collector.interpret(reader, resources, nil, 0)
+		}
+		collector.annotations(page.GetArray("Annots"))
+	}
+	return copyReachable(destination, source, false, collector.replacements())
+}
+
+// shownFont is a font dictionary with the codes shown with it.
+type shownFont struct {
+	number ObjectNumber
+	dictionary ProtectedDictionary
+	decoder *textFont
+	codes map[CharCode]bool
+	// unknown is true if the codes of the strings shown can't be
+	// determined, as for a Type0 font whose CMap is unknown.
+	unknown bool
+}
+
+// glyphCollector finds the codes shown with each font.
+type glyphCollector struct {
+	file File
+	fonts map[ObjectNumber]*shownFont
+	// excluded holds the font dictionaries whose programs must not
+	// be subset.
+	excluded map[ObjectNumber]bool
+	// direct holds the font dictionaries that aren't indirect
+	// objects, whose programs must not be subset either.
+	direct []ProtectedDictionary
+	// visited holds the streams that have been interpreted other
+	// than forms, which are interpreted each time they are drawn
+	// with a different font.
+	visited map[objectKey]bool
+	drawn map[formWithFont]bool
+}
+
+// formWithFont is a form XObject drawn with a font already selected.
+type formWithFont struct {
+	form objectKey
+	font *shownFont
+}
+
+func newGlyphCollector(f File) *glyphCollector {
+	return &glyphCollector{
+		file: f,
+		fonts: make(map[ObjectNumber]*shownFont),
+		excluded: make(map[ObjectNumber]bool),
+		visited: make(map[objectKey]bool),
+		drawn: make(map[formWithFont]bool) }
+}
+
+// exclude() excludes the fonts of a font resource dictionary.
+func (c *glyphCollector) exclude(fonts ProtectedDictionary) {
+	if fonts == nil {
+		return
+	}
+	fonts.ForEach(func(name string, value Object) {
+		if reference,ok := value.(ProtectedIndirect); ok {
+			c.excluded[reference.ObjectNumber(c.file)] = true
+		} else if dictionary,ok := value.(ProtectedDictionary); ok {
+			c.direct = append(c.direct, dictionary)
+		}
+	})
+}
+
+// interpret() records the codes shown by a content stream with the
+// passed resources, in which font is initially selected.
+func (c *glyphCollector) interpret(r io.Reader, resources ProtectedDictionary, font *shownFont, depth int) {
+	c.visitResources(resources, depth)
+	var stack []*shownFont
+	parser := NewContentParser(r)
+	for {
+		op,err := parser.Next()
+		if err != nil {
+			return
+		}
+		operands := op.Operands
+		switch op.Operator {
+		case "q":
+			stack = append(stack, font)
+		case "Q":
+			if n := len(stack); n > 0 {
+				font = stack[n-1]
+				stack = stack[:n-1]
+			}
+		case "Tf":
+			if len(operands) == 2 {
+				if name,ok := operands[0].(Name); ok {
+					font = c.font(resources, name.String())
+				}
+			}
+		case "Tj", "'", "\"":
+			if n := len(operands); n > 0 {
+				if s,ok := operands[n-1].(String); ok {
+					c.show(font, s.Bytes())
+				}
+			}
+		case "TJ":
+			if len(operands) == 1 {
+				if array,ok := operands[0].(Array); ok {
+					for i:=0; i<array.Size(); i++ {
+						if s,ok := array.At(i).(String); ok {
+							c.show(font, s.Bytes())
+						}
+					}
+				}
+			}
+		case "Do":
+			if len(operands) == 1 && depth < maxFormDepth {
+				if name,ok := operands[0].(Name); ok {
+					c.drawForm(resources, name.String(), font, depth)
+				}
+			}
+		}
+	}
+}
+
+// drawForm() interprets the named form XObject, which inherits the
+// selected font.
+func (c *glyphCollector) drawForm(resources ProtectedDictionary, name string, font *shownFont, depth int) {
+	form := lookupXObject(resources, name)
+	if form == nil {
+		return
+	}
+	if subtype,_ := form.Dictionary().GetName("Subtype"); subtype != "Form" {
+		return
+	}
+	if key,known := xobjectKey(resources, name); known {
+		if c.drawn[formWithFont{key, font}] {
+			return
+		}
+		c.drawn[formWithFont{key, font}] = true
+	}
+	if formResources := form.Dictionary().GetDictionary("Resources"); formResources != nil {
+		resources = formResources
+	}
+	if r := form.Reader(); r != nil {
+		c.interpret(r, resources, font, depth+1)
+	}
+}
+
+// interpretOnce() interprets the stream stored under key in container
+// unless it has been interpreted already.  The stream's own
+// resources, if it has any, replace the passed ones.
+func (c *glyphCollector) interpretOnce(container ProtectedDictionary, key string, resources ProtectedDictionary, depth int) {
+	s := container.GetStream(key)
+	if s == nil || depth >= maxFormDepth {
+		return
+	}
+	if k,known := sourceKey(container.Get(key)); known {
+		if c.visited[k] {
+			return
+		}
+		c.visited[k] = true
+	}
+	if streamResources := s.Dictionary().GetDictionary("Resources"); streamResources != nil {
+		resources = streamResources
+	}
+	if r := s.Reader(); r != nil {
+		c.interpret(r, resources, nil, depth+1)
+	}
+}
+
+// visitResources() interprets the tiling patterns, soft masks, and
+// Type3 glyphs of resources, whether or not they are used.
+func (c *glyphCollector) visitResources(resources ProtectedDictionary, depth int) {
+	if resources == nil {
+		return
+	}
+	if patterns := resources.GetDictionary("Pattern"); patterns != nil {
+		for _,name := range patterns.Keys() {
+			c.interpretOnce(patterns, name, nil, depth)
+		}
+	}
+	if states := resources.GetDictionary("ExtGState"); states != nil {
+		for _,name := range states.Keys() {
+			if state := states.GetDictionary(name); state != nil {
+				if mask := state.GetDictionary("SMask"); mask != nil {
+					c.interpretOnce(mask, "G", nil, depth)
+				}
+			}
+		}
+	}
+	if fonts := resources.GetDictionary("Font"); fonts != nil {
+		for _,name := range fonts.Keys() {
+			font := fonts.GetDictionary(name)
+			if font == nil || !font.CheckNameValue("Subtype", "Type3") {
+				continue
+			}
+			if procedures := font.GetDictionary("CharProcs"); procedures != nil {
+				glyphResources := font.GetDictionary("Resources")
+				if glyphResources == nil {
+					glyphResources = resources
+				}
+				for _,glyph := range procedures.Keys() {
+					c.interpretOnce(procedures, glyph, glyphResources, depth)
+				}
+			}
+		}
+	}
+}
+
+// annotations() interprets the appearance streams of annotations.
+func (c *glyphCollector) annotations(annotations ProtectedArray) {
+	if annotations == nil {
+		return
+	}
+	for i:=0; i<annotations.Size(); i++ {
+		annotation,ok := annotations.At(i).Dereference().(ProtectedDictionary)
+		if !ok {
+			continue
+		}
+		appearances := annotation.GetDictionary("AP")
+		if appearances == nil {
+			continue
+		}
+		for _,kind := range []string{"N", "R", "D"} {
+			if states := appearances.GetDictionary(kind); states != nil {
+				if _,isStream := appearances.Get(kind).Dereference().(ProtectedStream); !isStream {
+					for _,state := range states.Keys() {
+						c.interpretOnce(states, state, nil, 0)
+					}
+					continue
+				}
+			}
+			c.interpretOnce(appearances, kind, nil, 0)
+		}
+	}
+}
+
+// font() returns the named font of resources.
+func (c *glyphCollector) font(resources ProtectedDictionary, name string) *shownFont {
+	if resources == nil {
+		return nil
+	}
+	fonts := resources.GetDictionary("Font")
+	if fonts == nil {
+		return nil
+	}
+	dictionary := fonts.GetDictionary(name)
+	if dictionary == nil {
+		return nil
+	}
+	reference,isIndirect := fonts.Get(name).(ProtectedIndirect)
+	if !isIndirect {
+		c.direct = append(c.direct, dictionary)
+		return nil
+	}
+	o := reference.ObjectNumber(c.file)
+	if font,exists := c.fonts[o]; exists {
+		return font
+	}
+	font := &shownFont{number: o, dictionary: dictionary, decoder: newTextFont(dictionary), codes: make(map[CharCode]bool)}
+	font.unknown = dictionary.CheckNameValue("Subtype", "Type0") && font.decoder.cmap == nil
+	c.fonts[o] = font
+	return font
+}
+
+// show() records the codes of s, shown with font.
+func (c *glyphCollector) show(font *shownFont, s []byte) {
+	if font == nil {
+		return
+	}
+	if font.decoder.cmap != nil {
+		for _,code := range font.decoder.cmap.Codes(s) {
+			font.codes[code] = true
+		}
+		return
+	}
+	for _,b := range s {
+		font.codes[CharCode{uint32(b), 1}] = true
+	}
+}
+
+// subsetProgram is an embedded font program with the fonts that use
+// it.
+type subsetProgram struct {
+	number ObjectNumber
+	key string
+	stream ProtectedStream
+	fonts []*shownFont
+	excluded bool
+}
+
+// replacements() returns the replacements for the font programs that
+// can be subset and for the dictionaries that name them.
+func (c *glyphCollector) replacements() map[ObjectNumber]Object {
+	programs := make(map[ObjectNumber]*subsetProgram)
+	program := func(dictionary ProtectedDictionary) *subsetProgram {
+		info := FontInfo{Dictionary: dictionary}
+		info.Subtype,_ = dictionary.GetName("Subtype")
+		descriptor := info.fontDescriptor()
+		if descriptor == nil {
+			return nil
+		}
+		for _,key := range []string{"FontFile2", "FontFile3"} {
+			reference,ok := descriptor.Get(key).(ProtectedIndirect)
+			if !ok {
+				continue
+			}
+			o := reference.ObjectNumber(c.file)
+			p,exists := programs[o]
+			if !exists {
+				stream,_ := reference.Dereference().(ProtectedStream)
+				p = &subsetProgram{number: o, key: key, stream: stream, excluded: stream == nil}
+				programs[o] = p
+			}
+			return p
+		}
+		return nil
+	}
+
+	numbers := make([]ObjectNumber, 0, len(c.fonts))
+	for o := range c.fonts {
+		numbers = append(numbers, o)
+	}
+	sort.Slice(numbers, func(i, j int) bool { return numbers[i].Less(numbers[j]) })
+	for _,o := range numbers {
+		font := c.fonts[o]
+		if p := program(font.dictionary); p != nil {
+			p.fonts = append(p.fonts, font)
+			baseFont,_ := font.dictionary.GetName("BaseFont")
+			if c.excluded[o] || font.unknown || hasSubsetTag(baseFont) {
+				p.excluded = true
+			}
+		}
+	}
+	for o := range c.excluded {
+		if object,err := c.file.Object(o); err == nil {
+			if d,isDictionary := object.(ProtectedDictionary); isDictionary {
+				if p := program(d); p != nil {
+					p.excluded = true
+				}
+			}
+		}
+	}
+	for _,dictionary := range c.direct {
+		if p := program(dictionary); p != nil {
+			p.excluded = true
+		}
+	}
+
+	replacements := make(map[ObjectNumber]Object)
+	for _,p := range programs {
+		if !p.excluded && len(p.fonts) > 0 {
+			p.subset(c.file, replacements)
+		}
+	}
+	return replacements
+}
+
+// subset() adds to replacements the subset of the program and the
+// font dictionaries and descriptors that use it, renamed with the
+// subset's tag.  Nothing is added if the program can't be subset.
+func (p *subsetProgram) subset(f File, replacements map[ObjectNumber]Object) {
+	r := p.stream.Reader()
+	if r == nil {
+		return
+	}
+	data,err := ioutil.ReadAll(r)
+	if err != nil {
+		return
+	}
+
+	var (
+		font *sfnt
+		cff *cffFont )
+	subtype,_ := p.stream.Dictionary().GetName("Subtype")
+	switch {
+	case p.key == "FontFile2", subtype == "OpenType":
+		if font,err = parseSfnt(data); err != nil {
+			return
+		}
+		if os2 := font.tables["OS/2"]; len(os2) >= 10 {
+			if fsType := uint16(os2[8])<<8 | uint16(os2[9]); fsType & noSubsetting != 0 {
+				return
+			}
+		}
+		if table,exists := font.tables["CFF "]; exists {
+			if cff,err = parseCFF(table); err != nil {
+				return
+			}
+		}
+	case subtype == "CIDFontType0C":
+		if cff,err = parseCFF(data); err != nil {
+			return
+		}
+	default:
+		return
+	}
+
+	used := map[uint16]bool{0: true}
+	for _,shown := range p.fonts {
+		if !shownGlyphs(shown, font, cff, used) {
+			return
+		}
+	}
+
+	var subset []byte
+	switch {
+	case cff != nil:
+		program,err := cff.subset(used)
+		if err != nil {
+			return
+		}
+		if font == nil {
+			subset = program
+			break
+		}
+		result := &sfnt{font.version, make(map[string][]byte, len(font.tables))}
+		for tag,table := range font.tables {
+			if tag != "DSIG" {
+				result.tables[tag] = table
+			}
+		}
+		result.tables["CFF "] = program
+		subset = result.bytes(result.tags())
+	default:
+		result,err := font.subsetGlyphs(used)
+		if err != nil {
+			return
+		}
+		subset = result.bytes(result.tags())
+	}
+	if len(subset) >= len(data) {
+		return
+	}
+
+	stream := defaultStreamFactory.New()
+	p.stream.Dictionary().ForEach(func(key string, value Object) {
+		switch key {
+		case "Length", "Filter", "DecodeParms", "DL", "Length1", "Length2", "Length3":
+		default:
+			stream.Add(key, value)
+		}
+	})
+	if p.key == "FontFile2" {
+		stream.Add("Length1", NewIntNumeric(len(subset)))
+	}
+	stream.Write(subset)
+	replacements[p.number] = stream
+
+	gids := make([]int, 0, len(used))
+	for gid := range used {
+		gids = append(gids, int(gid))
+	}
+	sort.Ints(gids)
+	tag := subsetTag(gids) + "+"
+	rename := func(reference Object, key string) {
+		i,ok := reference.(ProtectedIndirect)
+		if !ok {
+			return
+		}
+		o := i.ObjectNumber(f)
+		if _,done := replacements[o]; done {
+			return
+		}
+		if d,ok := i.Dereference().(ProtectedDictionary); ok {
+			if name,ok := d.GetName(key); ok {
+				renamed := d.Clone().(Dictionary)
+				renamed.Add(key, NewName(tag + name))
+				replacements[o] = renamed
+			}
+		}
+	}
+	for _,shown := range p.fonts {
+		if _,done := replacements[shown.number]; !done {
+			renamed := shown.dictionary.Clone().(Dictionary)
+			if name,ok := renamed.GetName("BaseFont"); ok {
+				renamed.Add("BaseFont", NewName(tag + name))
+			}
+			replacements[shown.number] = renamed
+		}
+		holder := shown.dictionary
+		if descendants := shown.dictionary.GetArray("DescendantFonts"); descendants != nil && descendants.Size() > 0 {
+			rename(descendants.At(0), "BaseFont")
+			if descendant,ok := descendants.At(0).Dereference().(ProtectedDictionary); ok {
+				holder = descendant
+			}
+		}
+		rename(holder.Get("FontDescriptor"), "FontName")
+	}
+}
+
+// shownGlyphs() adds to used the glyphs of font or cff that show the
+// codes shown with a font dictionary.  It returns false if they
+// can't be determined.
+func shownGlyphs(shown *shownFont, font *sfnt, cff *cffFont, used map[uint16]bool) bool {
+	dictionary := shown.dictionary
+	subtype,_ := dictionary.GetName("Subtype")
+	switch subtype {
+	case "Type0":
+		descendants := dictionary.GetArray("DescendantFonts")
+		if descendants == nil || descendants.Size() == 0 {
+			return false
+		}
+		descendant,ok := descendants.At(0).Dereference().(ProtectedDictionary)
+		if !ok {
+			return false
+		}
+		// CIDs select glyphs through /CIDToGIDMap in TrueType
+		// CIDFonts and through the charset of CID-keyed CFF
+		// fonts.  Otherwise they are glyph IDs.
+		var cidToGID []byte
+		if descendant.CheckNameValue("Subtype", "CIDFontType2") {
+			if m := descendant.GetStream("CIDToGIDMap"); m != nil {
+				r := m.Reader()
+				if r == nil {
+					return false
+				}
+				cidToGID,_ = ioutil.ReadAll(r)
+			}
+		}
+		var charset map[uint16]uint16
+		if cff != nil && cff.cidKeyed && cff.charset != nil {
+			charset = make(map[uint16]uint16, len(cff.charset))
+			for gid,cid := range cff.charset {
+				charset[cid] = uint16(gid)
+			}
+		}
+		for code := range shown.codes {
+			cid := shown.decoder.cmap.CID(code)
+			switch {
+			case cidToGID != nil:
+				if int(2*cid+1) < len(cidToGID) {
+					used[uint16(cidToGID[2*cid])<<8 | uint16(cidToGID[2*cid+1])] = true
+				}
+			case charset != nil:
+				if gid,exists := charset[uint16(cid)]; exists {
+					used[gid] = true
+				}
+			default:
+				used[uint16(cid)] = true
+			}
+		}
+		return true
+	case "TrueType":
+		if font == nil || cff != nil {
+			return false
+		}
+		return trueTypeGlyphs(shown, font, used)
+	}
+	return false
+}
+
+// trueTypeGlyphs() adds to used the glyphs of a simple TrueType font
+// that may show the codes shown with it.  Since viewers choose among
+// the font's cmap subtables in different ways, the glyphs given by
+// each of them are included.
+func trueTypeGlyphs(shown *shownFont, font *sfnt, used map[uint16]bool) bool {
+	cmap := font.tables["cmap"]
+	symbolic := sfntCmapSubtable(cmap, 3, 0)
+	macintosh := sfntCmapSubtable(cmap, 1, 0)
+	unicode := sfntCmapSubtable(cmap, 3, 1)
+	if symbolic == nil && macintosh == nil && unicode == nil {
+		return false
+	}
+	add := func(m map[rune]uint16, r rune) {
+		if gid,exists := m[r]; exists {
+			used[gid] = true
+		}
+	}
+	decoder := shown.decoder
+	for code := range shown.codes {
+		c := rune(code.Code)
+		for _,prefix := range []rune{0, 0xf000, 0xf100, 0xf200} {
+			add(symbolic, prefix + c)
+		}
+		add(macintosh, c)
+		var texts []string
+		if text,exists := decoder.differences[code.Code]; exists {
+			texts = append(texts, text)
+		}
+		if decoder.encoding != nil && code.Code < 256 {
+			texts = append(texts, string(decoder.encoding[code.Code]))
+		}
+		if text,exists := decoder.toUnicode[code.Code]; exists {
+			texts = append(texts, text)
+		}
+		for _,text := range texts {
+			for _,r := range text {
+				add(unicode, r)
+			}
+		}
+	}
+	return true
+}
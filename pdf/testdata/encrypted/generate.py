@@ -0,0 +1,227 @@
+#!/usr/bin/env python3
+"""Generates the encrypted files read by TestStandardSecurityHandler.
+
+The files are encrypted by this script, which implements the standard
+security handler from ISO 32000-2 (Algorithms 1-10, 2.A, 2.B) without
+reference to the Go implementation, so that the test checks the Go
+code against an independent implementation rather than against
+itself.  It needs only Python 3 and the openssl command.  Salts and
+initialization vectors are derived from fixed seeds so that running it
+again reproduces the same files:
+
+    cd pdf/testdata/encrypted && python3 generate.py
+"""
+
+import hashlib
+import struct
+import subprocess
+
+USER = b"user"
+OWNER = b"owner"
+TITLE = b"Known answer"
+CONTENTS = b"BT /F1 24 Tf 72 720 Td (Encrypted by an independent implementation) Tj ET"
+ID0 = bytes.fromhex("0f1e2d3c4b5a69788796a5b4c3d2e1f0")
+# Printing and copying for accessibility are allowed; modification is not.
+P = struct.unpack("<i", struct.pack("<I", 0xFFFFF0C0 | 4 | 512))[0]
+
+PADDING = bytes.fromhex(
+    "28bf4e5e4e758a4164004e56fffa0108"
+    "2e2e00b6d0683e802f0ca9fe6453697a")
+
+
+def seeded(seed, n):
+    """Returns n reproducible pseudo-random bytes."""
+    out = b""
+    counter = 0
+    while len(out) < n:
+        out += hashlib.sha256(seed + bytes([counter])).digest()
+        counter += 1
+    return out[:n]
+
+
+def rc4(key, data):
+    s = list(range(256))
+    j = 0
+    for i in range(256):
+        j = (j + s[i] + key[i % len(key)]) % 256
+        s[i], s[j] = s[j], s[i]
+    i = j = 0
+    out = bytearray()
+    for b in data:
+        i = (i + 1) % 256
+        j = (j + s[i]) % 256
+        s[i], s[j] = s[j], s[i]
+        out.append(b ^ s[(s[i] + s[j]) % 256])
+    return bytes(out)
+
+
+def openssl(cipher, key, iv, data, padding):
+    args = ["openssl", "enc", "-" + cipher, "-K", key.hex()]
+    if iv is not None:
+        args += ["-iv", iv.hex()]
+    if not padding:
+        args.append("-nopad")
+    return subprocess.run(args, input=data, stdout=subprocess.PIPE, check=True).stdout
+
+
+def aes_cbc(key, iv, data, padding=True):
+    return openssl("aes-%d-cbc" % (8 * len(key)), key, iv, data, padding)
+
+
+def pad_password(password):
+    return (password + PADDING)[:32]
+
+
+# Revisions 2-4
+
+def file_key(r, n, o, encrypt_metadata=True):
+    """Algorithm 2."""
+    h = hashlib.md5(pad_password(USER) + o + struct.pack("<i", P) + ID0)
+    if r >= 4 and not encrypt_metadata:
+        h.update(b"\xff\xff\xff\xff")
+    key = h.digest()
+    if r >= 3:
+        for _ in range(50):
+            key = hashlib.md5(key[:n]).digest()
+    return key[:n]
+
+
+def o_value(r, n):
+    """Algorithm 3."""
+    key = hashlib.md5(pad_password(OWNER)).digest()
+    if r >= 3:
+        for _ in range(50):
+            key = hashlib.md5(key).digest()
+    key = key[:n]
+    o = rc4(key, pad_password(USER))
+    if r >= 3:
+        for i in range(1, 20):
+            o = rc4(bytes(b ^ i for b in key), o)
+    return o
+
+
+def u_value(r, key):
+    """Algorithms 4 and 5."""
+    if r == 2:
+        return rc4(key, PADDING)
+    u = rc4(key, hashlib.md5(PADDING + ID0).digest())
+    for i in range(1, 20):
+        u = rc4(bytes(b ^ i for b in key), u)
+    return u + seeded(b"U", 16)
+
+
+def object_key(key, number, aes):
+    """Algorithm 1."""
+    h = hashlib.md5(key + struct.pack("<I", number)[:3] + b"\x00\x00")
+    if aes:
+        h.update(b"sAlT")
+    return h.digest()[:min(len(key) + 5, 16)]
+
+
+# Revision 6
+
+def hash_2b(password, salt, udata):
+    """Algorithm 2.B."""
+    k = hashlib.sha256(password + salt + udata).digest()
+    i = 0
+    while True:
+        k1 = (password + k + udata) * 64
+        e = aes_cbc(k[:16], k[16:32], k1, padding=False)
+        function = [hashlib.sha256, hashlib.sha384, hashlib.sha512][sum(e[:16]) % 3]
+        k = function(e).digest()
+        i += 1
+        if i >= 64 and e[-1] <= i - 32:
+            return k[:32]
+
+
+def r6_entries(key):
+    """Algorithms 8, 9, and 10."""
+    vsalt, ksalt = seeded(b"uvs", 8), seeded(b"uks", 8)
+    u = hash_2b(USER, vsalt, b"") + vsalt + ksalt
+    ue = aes_cbc(hash_2b(USER, ksalt, b""), bytes(16), key, padding=False)
+    ovsalt, oksalt = seeded(b"ovs", 8), seeded(b"oks", 8)
+    o = hash_2b(OWNER, ovsalt, u) + ovsalt + oksalt
+    oe = aes_cbc(hash_2b(OWNER, oksalt, u), bytes(16), key, padding=False)
+    perms = struct.pack("<i", P) + b"\xff\xff\xff\xffTadb" + seeded(b"perms", 4)
+    perms = openssl("aes-256-ecb", key, None, perms, False)
+    return o, u, oe, ue, perms
+
+
+def hexstring(b):
+    return b"<" + b.hex().encode() + b">"
+
+
+def write_pdf(filename, encrypt, encrypt_data):
+    """Writes a one-page file whose content stream and /Title are
+    encrypted with encrypt_data(object number, data)."""
+    contents = encrypt_data(4, CONTENTS)
+    title = encrypt_data(5, TITLE)
+    objects = [
+        b"<< /Type /Catalog /Pages 2 0 R >>",
+        b"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+        b"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] /Contents 4 0 R >>",
+        b"<< /Length %d >>\nstream\n" % len(contents) + contents + b"\nendstream",
+        b"<< /Title " + hexstring(title) + b" >>",
+        encrypt,
+    ]
+    out = b"%PDF-1.7\n%\xe2\xe3\xcf\xd3\n"
+    offsets = []
+    for i, body in enumerate(objects):
+        offsets.append(len(out))
+        out += b"%d 0 obj\n" % (i + 1) + body + b"\nendobj\n"
+    xref = len(out)
+    out += b"xref\n0 %d\n0000000000 65535 f \n" % (len(objects) + 1)
+    for offset in offsets:
+        out += b"%010d 00000 n \n" % offset
+    out += (b"trailer\n<< /Size %d /Root 1 0 R /Info 5 0 R /Encrypt 6 0 R /ID [" % (len(objects) + 1)
+            + hexstring(ID0) + hexstring(ID0) + b"] >>\nstartxref\n%d\n%%%%EOF\n" % xref)
+    with open(filename, "wb") as f:
+        f.write(out)
+
+
+def write_rc4_or_aesv2(filename, v, r, length, aes):
+    n = length // 8
+    o = o_value(r, n)
+    key = file_key(r, n, o)
+    u = u_value(r, key)
+
+    def encrypt_data(number, data):
+        if aes:
+            iv = seeded(b"iv%d" % number, 16)
+            return iv + aes_cbc(object_key(key, number, True), iv, data)
+        return rc4(object_key(key, number, False), data)
+
+    encrypt = b"<< /Filter /Standard /V %d /R %d /P %d" % (v, r, P)
+    if v == 2:
+        encrypt += b" /Length %d" % length
+    if v == 4:
+        method = b"/AESV2" if aes else b"/V2"
+        encrypt += (b" /Length 128 /CF << /StdCF << /CFM " + method
+                    + b" /AuthEvent /DocOpen /Length 16 >> >> /StmF /StdCF /StrF /StdCF")
+    encrypt += b" /O " + hexstring(o) + b" /U " + hexstring(u) + b" >>"
+    write_pdf(filename, encrypt, encrypt_data)
+
+
+def write_aesv3(filename):
+    key = seeded(b"file key", 32)
+    o, u, oe, ue, perms = r6_entries(key)
+
+    def encrypt_data(number, data):
+        iv = seeded(b"iv%d" % number, 16)
+        return iv + aes_cbc(key, iv, data)
+
+    encrypt = (b"<< /Filter /Standard /V 5 /R 6 /Length 256 /P %d" % P
+               + b" /CF << /StdCF << /CFM /AESV3 /AuthEvent /DocOpen /Length 32 >> >> /StmF /StdCF /StrF /StdCF"
+               + b" /O " + hexstring(o) + b" /U " + hexstring(u)
+               + b" /OE " + hexstring(oe) + b" /UE " + hexstring(ue)
+               + b" /Perms " + hexstring(perms) + b" >>")
+    write_pdf(filename, encrypt, encrypt_data)
+
+
+if __name__ == "__main__":
+    write_rc4_or_aesv2("r2-rc4-40.pdf", 1, 2, 40, False)
+    write_rc4_or_aesv2("r3-rc4-40.pdf", 2, 3, 40, False)
+    write_rc4_or_aesv2("r3-rc4-128.pdf", 2, 3, 128, False)
+    write_rc4_or_aesv2("r4-rc4-128.pdf", 4, 4, 128, False)
+    write_rc4_or_aesv2("r4-aesv2.pdf", 4, 4, 128, True)
+    write_aesv3("r6-aesv3.pdf")
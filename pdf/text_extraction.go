@@ -0,0 +1,665 @@
+package pdf
+
+import (
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+	"unicode/utf8" )
+
+// A TextWord is a run of characters on a line of extracted text
+// that is not interrupted by white space or by a gap.
+type TextWord struct {
+	Text string
+	// X and Y are the position of the start of the word's
+	// baseline in default user space.
+	X, Y float64
+	// Width is the distance from the start of the first
+	// character to the end of the last.
+	Width float64
+	// Size is the font size in default user space.
+	Size float64
+}
+
+// A TextLine is a sequence of words sharing a baseline, from left to
+// right.
+type TextLine struct {
+	Words []TextWord
+	// X and Y are the position of the start of the line's
+	// baseline in default user space.
+	X, Y float64
+}
+
+// String() returns the words of the line separated by spaces.
+func (l TextLine) String() string {
+	words := make([]string, len(l.Words))
+	for i,w := range l.Words {
+		words[i] = w.Text
+	}
+	return strings.Join(words, " ")
+}
+
+// PageText is the text extracted from a page by Text().
+type PageText struct {
+	// Lines holds the lines of text from the top of the page to
+	// the bottom.
+	Lines []TextLine
+}
+
+// String() returns the lines of text separated by newlines.
+func (pt *PageText) String() string {
+	lines := make([]string, len(pt.Lines))
+	for i,l := range pt.Lines {
+		lines[i] = l.String()
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Text() extracts the text shown by the page's contents, including
+// the contents of any form XObjects it draws.  Character codes are
+// converted to Unicode using each font's /ToUnicode CMap when there
+// is one, and otherwise using the font's encoding.  Characters are
+// grouped into lines by baseline and into words by the gaps between
+// them.  Lines are ordered from top to bottom and words from left to
+// right, which is the reading order of single-column, unrotated
+// text.  Text in other arrangements is extracted, but its order may
+// not match the order in which it would be read.
+func (ep *ExistingPage) Text() *PageText {
+	te := &textExtractor{fonts: make(map[ObjectNumber]*textFont), drawing: make(map[objectKey]bool)}
+	if ep.document != nil {
+		te.file = ep.document.file
+	}
+	state := textGraphicsState{ctm: identityMatrix}
+	state.text.scale = 1
+
+	if reader := ep.Reader(); reader != nil {
+		te.interpret(reader, ep.GetDictionary("Resources"), state, 0)
+	}
+	return groupText(te.glyphs)
+}
+
+// matrix is a PDF transformation matrix [a b c d e f].
+type matrix [6]float64
+
+var identityMatrix = matrix{1, 0, 0, 1, 0, 0}
+
+// multiply() returns the product m × n, which applies m and then n.
+func (m matrix) multiply(n matrix) matrix {
+	return matrix{
+		m[0]*n[0] + m[1]*n[2],
+		m[0]*n[1] + m[1]*n[3],
+		m[2]*n[0] + m[3]*n[2],
+		m[2]*n[1] + m[3]*n[3],
+		m[4]*n[0] + m[5]*n[2] + n[4],
+		m[4]*n[1] + m[5]*n[3] + n[5]}
+}
+
+func (m matrix) transform(x, y float64) (float64, float64) {
+	return m[0]*x + m[2]*y + m[4], m[1]*x + m[3]*y + m[5]
+}
+
+// matrixFromOperands() returns the matrix given by six numeric
+// operands, or false if the operands are not six numbers.
+func matrixFromOperands(operands []Object) (m matrix, ok bool) {
+	if len(operands) != 6 {
+		return m, false
+	}
+	for i,o := range operands {
+		if m[i],ok = numericValue(o); !ok {
+			return m, false
+		}
+	}
+	return m, true
+}
+
+// textState holds the text state parameters, which are part of the
+// graphics state.
+type textState struct {
+	font *textFont
+	size, charSpacing, wordSpacing, scale, leading, rise float64
+}
+
+type textGraphicsState struct {
+	ctm matrix
+	text textState
+}
+
+// extractedGlyph is a character shown on the page, positioned in
+// default user space.
+type extractedGlyph struct {
+	text string
+	x, y, width, size float64
+}
+
+type textExtractor struct {
+	file File
+	// fonts caches fonts by object number.
+	fonts map[ObjectNumber]*textFont
+	// drawing holds the forms being interpreted, which are not
+	// drawn again from within themselves.
+	drawing map[objectKey]bool
+	glyphs []extractedGlyph
+}
+
+// maxFormDepth limits the nesting of form XObjects.  Forms that draw
+// themselves are caught separately; this bounds chains of distinct
+// forms.
+const maxFormDepth = 16
+
+// interpret() executes the text operators in a content stream and the
+// operators that affect the positioning of text.  Others are ignored.
+func (te *textExtractor) interpret(r io.Reader, resources ProtectedDictionary, state textGraphicsState, depth int) {
+	var (
+		stack []textGraphicsState
+		textMatrix, lineMatrix matrix )
+
+	// nextLine() moves to the start of the next line, offset by
+	// (tx, ty) from the start of the current one.
+	nextLine := func(tx, ty float64) {
+		lineMatrix = matrix{1, 0, 0, 1, tx, ty}.multiply(lineMatrix)
+		textMatrix = lineMatrix
+	}
+
+	parser := NewContentParser(r)
+	for {
+		op,err := parser.Next()
+		if err != nil {
+			return
+		}
+		operands := op.Operands
+		number := func(i int) float64 {
+			if i < len(operands) {
+				v,_ := numericValue(operands[i])
+				return v
+			}
+			return 0
+		}
+
+		switch op.Operator {
+		case "q":
+			stack = append(stack, state)
+		case "Q":
+			if n := len(stack); n > 0 {
+				state = stack[n-1]
+				stack = stack[:n-1]
+			}
+		case "cm":
+			if m,ok := matrixFromOperands(operands); ok {
+				state.ctm = m.multiply(state.ctm)
+			}
+		case "BT":
+			textMatrix = identityMatrix
+			lineMatrix = identityMatrix
+		case "Tc":
+			state.text.charSpacing = number(0)
+		case "Tw":
+			state.text.wordSpacing = number(0)
+		case "Tz":
+			state.text.scale = number(0) / 100
+		case "TL":
+			state.text.leading = number(0)
+		case "Ts":
+			state.text.rise = number(0)
+		case "Tf":
+			if len(operands) == 2 {
+				if name,ok := operands[0].(Name); ok {
+					state.text.font = te.font(resources, name.String())
+				}
+				state.text.size = number(1)
+			}
+		case "Td":
+			nextLine(number(0), number(1))
+		case "TD":
+			state.text.leading = -number(1)
+			nextLine(number(0), number(1))
+		case "Tm":
+			if m,ok := matrixFromOperands(operands); ok {
+				textMatrix = m
+				lineMatrix = m
+			}
+		case "T*":
+			nextLine(0, -state.text.leading)
+		case "'", "\"":
+			if op.Operator == "\"" && len(operands) == 3 {
+				state.text.wordSpacing = number(0)
+				state.text.charSpacing = number(1)
+			}
+			nextLine(0, -state.text.leading)
+			fallthrough
+		case "Tj":
+			if n := len(operands); n > 0 {
+				if s,ok := operands[n-1].(String); ok {
+					te.show(s.Bytes(), &textMatrix, state)
+				}
+			}
+		case "TJ":
+			if len(operands) == 1 {
+				if array,ok := operands[0].(Array); ok {
+					for i:=0; i<array.Size(); i++ {
+						switch element := array.At(i).(type) {
+						case String:
+							te.show(element.Bytes(), &textMatrix, state)
+						default:
+							if adjustment,ok := numericValue(element); ok {
+								tx := -adjustment / 1000 * state.text.size * state.text.scale
+								textMatrix = matrix{1, 0, 0, 1, tx, 0}.multiply(textMatrix)
+							}
+						}
+					}
+				}
+			}
+		case "Do":
+			if len(operands) == 1 && depth < maxFormDepth {
+				if name,ok := operands[0].(Name); ok {
+					te.drawForm(resources, name.String(), state, depth)
+				}
+			}
+		}
+	}
+}
+
+// drawForm() interprets the contents of the named form XObject.
+func (te *textExtractor) drawForm(resources ProtectedDictionary, name string, state textGraphicsState, depth int) {
+	form := lookupXObject(resources, name)
+	if form == nil {
+		return
+	}
+	if subtype,_ := form.Dictionary().GetName("Subtype"); subtype != "Form" {
+		return
+	}
+	key,known := xobjectKey(resources, name)
+	if known {
+		if te.drawing[key] {
+			return
+		}
+		te.drawing[key] = true
+		defer delete(te.drawing, key)
+	}
+	state.ctm, resources = formContext(form, state.ctm, resources)
+	te.interpret(form.Reader(), resources, state, depth+1)
+}
+
+// show() records the glyphs of a string shown with the current text
+// state and advances the text matrix past them.
+func (te *textExtractor) show(s []byte, textMatrix *matrix, state textGraphicsState) {
+	font := state.text.font
+	if font == nil {
+		font = defaultTextFont
+	}
+	ts := state.text
+	sizeScale := 1.0
+	if font.sizeScale != 0 {
+		sizeScale = font.sizeScale
+	}
+	for _,g := range font.decode(s) {
+		trm := matrix{ts.size*ts.scale, 0, 0, ts.size, 0, ts.rise}.multiply(textMatrix.multiply(state.ctm))
+		x, y := trm.transform(0, 0)
+
+		tx := g.width*ts.size + ts.charSpacing
+		if g.isSpace {
+			tx += ts.wordSpacing
+		}
+		*textMatrix = matrix{1, 0, 0, 1, tx*ts.scale, 0}.multiply(*textMatrix)
+
+		endX, endY := textMatrix.multiply(state.ctm).transform(0, ts.rise)
+		te.glyphs = append(te.glyphs, extractedGlyph{
+			text: g.text,
+			x: x,
+			y: y,
+			width: math.Hypot(endX-x, endY-y),
+			size: math.Hypot(trm[2], trm[3]) * sizeScale})
+	}
+}
+
+// font() returns the font with the specified resource name.
+func (te *textExtractor) font(resources ProtectedDictionary, name string) *textFont {
+	if resources == nil {
+		return nil
+	}
+	fonts := resources.GetDictionary("Font")
+	if fonts == nil {
+		return nil
+	}
+	reference,isIndirect := fonts.Get(name).(ProtectedIndirect)
+	if isIndirect && te.file != nil {
+		if font,exists := te.fonts[reference.ObjectNumber(te.file)]; exists {
+			return font
+		}
+	}
+	dictionary := fonts.GetDictionary(name)
+	if dictionary == nil {
+		return nil
+	}
+	font := newTextFont(dictionary)
+	if isIndirect && te.file != nil {
+		te.fonts[reference.ObjectNumber(te.file)] = font
+	}
+	return font
+}
+
+// decodedGlyph is a character code decoded by a textFont.
+type decodedGlyph struct {
+	text string
+	// width is the glyph's advance in text space units for a
+	// font size of 1.
+	width float64
+	// isSpace is true for the single-byte code 32, to which word
+	// spacing applies.
+	isSpace bool
+}
+
+// textFont holds the information needed to decode the strings shown
+// with a font.
+type textFont struct {
+	codeLength int
+	// cmap, if not nil, splits the strings of a Type0 font into
+	// codes and maps them to CIDs, by which widths are indexed.
+	cmap *CMap
+	// cidToUnicode, if not nil, maps the CIDs of a Type0 font to
+	// text.  It is the registered CMap for the font's
+	// /CIDSystemInfo, e.g., Adobe-Japan1-UCS2.
+	cidToUnicode *CMap
+	toUnicode map[uint32]string
+	encoding *[256]rune
+	// differences holds the text of the glyphs named by the
+	// /Differences of a simple font's encoding, which override
+	// encoding.
+	differences map[uint32]string
+	widths map[uint32]float64
+	defaultWidth float64
+	// sizeScale, if not zero, is the height of the font's glyphs
+	// in text space, by which the font size is multiplied to
+	// give the size of its text.  It is used for Type3 fonts,
+	// whose /FontMatrix may scale glyph space arbitrarily.
+	sizeScale float64
+}
+
+// defaultTextFont is used to decode strings shown before any font has
+// been selected.
+var defaultTextFont = &textFont{codeLength: 1, encoding: &standardEncoding, defaultWidth: 0.5}
+
+func newTextFont(dictionary ProtectedDictionary) *textFont {
+	font := &textFont{codeLength: 1, widths: make(map[uint32]float64)}
+	subtype,_ := dictionary.GetName("Subtype")
+
+	if subtype == "Type0" {
+		// If the CMap is unknown, two-byte codes are assumed,
+		// as with the Identity-H encoding.
+		font.codeLength = 2
+		font.defaultWidth = 1
+		font.cmap = fontCMap(dictionary.Get("Encoding"), 0)
+		if descendants := dictionary.GetArray("DescendantFonts"); descendants != nil && descendants.Size() > 0 {
+			if descendant,ok := descendants.At(0).Dereference().(ProtectedDictionary); ok {
+				if info := descendant.GetDictionary("CIDSystemInfo"); info != nil {
+					registry,_ := info.GetString("Registry")
+					ordering,_ := info.GetString("Ordering")
+					if len(registry) > 0 && string(ordering) != "Identity" {
+						font.cidToUnicode = PredefinedCMap(string(registry) + "-" + string(ordering) + "-UCS2")
+					}
+				}
+				if width,ok := numericValue(descendant.Get("DW")); ok {
+					font.defaultWidth = width / 1000
+				}
+				if w := descendant.GetArray("W"); w != nil {
+					font.readCIDWidths(w)
+				}
+			}
+		}
+	} else {
+		// Width information is absent for the standard 14 fonts,
+		// so use a typical width.
+		font.defaultWidth = 0.5
+		// Widths are in glyph space, which for fonts other than
+		// Type3 fonts is 1000 units per em.
+		widthScale := 0.001
+		if subtype == "Type3" {
+			font.defaultWidth = 0
+			if array := dictionary.GetArray("FontMatrix"); array != nil {
+				if fontMatrix,ok := array.Floats(); ok && len(fontMatrix) == 6 {
+					widthScale = fontMatrix[0]
+					// Type3 fonts have no em square,
+					// so the height of the bounding
+					// box is used instead.
+					if array := dictionary.GetArray("FontBBox"); array != nil {
+						if bbox,ok := array.Floats(); ok && len(bbox) == 4 && bbox[3] > bbox[1] {
+							font.sizeScale = (bbox[3]-bbox[1]) * math.Hypot(fontMatrix[2], fontMatrix[3])
+						}
+					}
+				}
+			}
+		}
+		if widths := dictionary.GetArray("Widths"); widths != nil {
+			firstChar,_ := dictionary.GetInt("FirstChar")
+			for i:=0; i<widths.Size(); i++ {
+				if width,ok := numericValue(widths.At(i)); ok {
+					font.widths[uint32(firstChar+i)] = width * widthScale
+				}
+			}
+		}
+
+		if subtype == "TrueType" {
+			font.encoding = &winAnsiEncoding
+		} else {
+			font.encoding = &standardEncoding
+		}
+		if name,ok := dictionary.GetName("Encoding"); ok {
+			if encoding := namedEncoding(name); encoding != nil {
+				font.encoding = encoding
+			}
+		} else if encodingDictionary := dictionary.GetDictionary("Encoding"); encodingDictionary != nil {
+			if name,ok := encodingDictionary.GetName("BaseEncoding"); ok {
+				if encoding := namedEncoding(name); encoding != nil {
+					font.encoding = encoding
+				}
+			}
+			if differences := encodingDictionary.GetArray("Differences"); differences != nil {
+				font.differences = applyDifferences(differences)
+			}
+		}
+	}
+
+	if toUnicode := dictionary.GetStream("ToUnicode"); toUnicode != nil {
+		font.toUnicode = parseToUnicode(toUnicode.Reader())
+	}
+	return font
+}
+
+// readCIDWidths() reads a CIDFont /W array, whose elements are either
+// "c [w1 w2 ...]", giving widths for consecutive CIDs starting at c,
+// or "cfirst clast w", giving one width for a range of CIDs.
+func (font *textFont) readCIDWidths(w ProtectedArray) {
+	for i:=0; i+1<w.Size(); {
+		first,ok := numericValue(w.At(i))
+		if !ok {
+			return
+		}
+		if widths,ok := w.At(i+1).Dereference().(ProtectedArray); ok {
+			for j:=0; j<widths.Size(); j++ {
+				if width,ok := numericValue(widths.At(j)); ok {
+					font.widths[uint32(first)+uint32(j)] = width / 1000
+				}
+			}
+			i += 2
+			continue
+		}
+		if i+2 >= w.Size() {
+			return
+		}
+		last,ok1 := numericValue(w.At(i+1))
+		width,ok2 := numericValue(w.At(i+2))
+		if !ok1 || !ok2 || last < first || last-first > 0xffff {
+			return
+		}
+		for c:=uint32(first); c<=uint32(last); c++ {
+			font.widths[c] = width / 1000
+		}
+		i += 3
+	}
+}
+
+// decode() splits a string into character codes and returns the
+// glyph for each.
+func (font *textFont) decode(s []byte) []decodedGlyph {
+	var codes []CharCode
+	if font.cmap != nil {
+		codes = font.cmap.Codes(s)
+	} else {
+		codes = make([]CharCode, 0, len(s)/font.codeLength)
+		for i:=0; i+font.codeLength<=len(s); i+=font.codeLength {
+			codes = append(codes, CharCode{codeValue(s[i:i+font.codeLength]), font.codeLength})
+		}
+	}
+
+	glyphs := make([]decodedGlyph, 0, len(codes))
+	for _,c := range codes {
+		code := c.Code
+		// Widths are indexed by CID for Type0 fonts and by code
+		// for simple fonts.
+		cid := code
+		if font.cmap != nil {
+			cid = font.cmap.CID(c)
+		}
+
+		g := decodedGlyph{width: font.defaultWidth, isSpace: c.Length == 1 && code == 32}
+		if width,exists := font.widths[cid]; exists {
+			g.width = width
+		}
+		if text,exists := font.toUnicode[code]; exists {
+			g.text = text
+		} else if text,ok := font.cmapText(c, cid); ok {
+			g.text = text
+		} else if text,exists := font.differences[code]; exists {
+			if text == "" {
+				text = string(utf8.RuneError)
+			}
+			g.text = text
+		} else if font.encoding != nil {
+			if r := font.encoding[code]; r != 0 {
+				g.text = string(r)
+			} else {
+				g.text = string(utf8.RuneError)
+			}
+		} else {
+			g.text = string(utf8.RuneError)
+		}
+		glyphs = append(glyphs, g)
+	}
+	return glyphs
+}
+
+// cmapText() returns the text of a code of a Type0 font without a
+// /ToUnicode mapping for it, which is found either from a
+// Unicode-based CMap or from the CID.
+func (font *textFont) cmapText(code CharCode, cid uint32) (string, bool) {
+	if font.cmap != nil {
+		if text,ok := font.cmap.Unicode(code); ok {
+			return text, true
+		}
+	}
+	if font.cidToUnicode != nil {
+		return font.cidToUnicode.Unicode(CharCode{cid, 2})
+	}
+	return "", false
+}
+
+// parseToUnicode() reads the bfchar and bfrange mappings of a
+// /ToUnicode CMap.
+func parseToUnicode(r io.Reader) map[uint32]string {
+	if r == nil {
+		return make(map[uint32]string)
+	}
+	cmap,err := ParseCMap(r)
+	if err != nil {
+		return make(map[uint32]string)
+	}
+	return cmap.unicode
+}
+
+func codeValue(b []byte) (code uint32) {
+	for _,c := range b {
+		code = code<<8 | uint32(c)
+	}
+	return code
+}
+
+func utf16Units(b []byte) []uint16 {
+	units := make([]uint16, 0, len(b)/2)
+	for i:=0; i+1<len(b); i+=2 {
+		units = append(units, uint16(b[i])<<8 | uint16(b[i+1]))
+	}
+	return units
+}
+
+func decodeUTF16(b []byte) string {
+	return string(utf16.Decode(utf16Units(b)))
+}
+
+// groupText() groups glyphs into words and lines.
+func groupText(glyphs []extractedGlyph) *PageText {
+	type lineGlyphs struct {
+		y, size float64
+		glyphs []extractedGlyph
+	}
+
+	// Assign each glyph to a line with a nearby baseline.
+	var lines []*lineGlyphs
+glyphs:
+	for _,g := range glyphs {
+		for _,l := range lines {
+			if math.Abs(l.y - g.y) <= 0.4*math.Max(l.size, g.size) {
+				l.glyphs = append(l.glyphs, g)
+				continue glyphs
+			}
+		}
+		lines = append(lines, &lineGlyphs{g.y, g.size, []extractedGlyph{g}})
+	}
+	sort.SliceStable(lines, func(i, j int) bool {
+		return lines[i].y > lines[j].y
+	})
+
+	result := &PageText{Lines: make([]TextLine, 0, len(lines))}
+	for _,l := range lines {
+		sort.SliceStable(l.glyphs, func(i, j int) bool {
+			return l.glyphs[i].x < l.glyphs[j].x
+		})
+
+		var (
+			line TextLine
+			word *TextWord
+			text []string )
+		endWord := func() {
+			if word != nil {
+				word.Text = strings.Join(text, "")
+				line.Words = append(line.Words, *word)
+				word = nil
+				text = text[:0]
+			}
+		}
+		for _,g := range l.glyphs {
+			if strings.TrimFunc(g.text, unicode.IsSpace) == "" {
+				endWord()
+				continue
+			}
+			// A gap wider than a fraction of the font size
+			// separates words.
+			if word != nil && math.Abs(g.x - (word.X + word.Width)) > 0.15*g.size {
+				endWord()
+			}
+			if word == nil {
+				word = &TextWord{X: g.x, Y: g.y, Size: g.size}
+			}
+			text = append(text, g.text)
+			word.Width = g.x + g.width - word.X
+		}
+		endWord()
+
+		if len(line.Words) > 0 {
+			line.X = line.Words[0].X
+			line.Y = line.Words[0].Y
+			result.Lines = append(result.Lines, line)
+		}
+	}
+	return result
+}
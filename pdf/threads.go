@@ -0,0 +1,177 @@
+package pdf
+
+import "fmt"
+
+// maxBeads limits the number of beads followed when reading a
+// thread, whose beads form a circular list that may be damaged.
+const maxBeads = 1 << 16
+
+// A Thread is an article thread being added to the document: a
+// sequence of beads, each a rectangle on some page, that a reader
+// follows to read an article laid out in several columns or
+// continued on later pages.  Beads are added with Page.AddBead(), in
+// reading order, and the thread is written when the document is
+// closed.
+type Thread struct {
+	Title string
+	reference Indirect
+	beads []threadBead
+}
+
+type threadBead struct {
+	reference Indirect
+	page Indirect
+	rectangle *Rectangle
+}
+
+// NewThread() adds an article thread to the document.
+func (d *Document) NewThread(title string) *Thread {
+	t := &Thread{Title: title, reference: NewIndirect(d.file)}
+	d.threads = append(d.threads, t)
+	return t
+}
+
+// AddBead() appends to thread t a bead covering the rectangle of the
+// page with lower-left corner (llx, lly) and upper-right corner (urx,
+// ury).
+func (p *Page) AddBead(t *Thread, llx, lly, urx, ury float64) {
+	if p.dictionary == nil {
+		panic ("AddBead() called on closed page")
+	}
+	bead := threadBead{NewIndirect(p.fileList...), p.indirect, NewRectangle(llx, lly, urx, ury)}
+	t.beads = append(t.beads, bead)
+	if p.beads == nil {
+		p.beads = NewArray()
+	}
+	p.beads.Add(bead.reference)
+}
+
+// finish() writes the thread and its beads, which are linked in a
+// circular list.
+func (t *Thread) finish() {
+	n := len(t.beads)
+	for i,bead := range t.beads {
+		dictionary := NewDictionary()
+		dictionary.Add("Type", NewName("Bead"))
+		if i == 0 {
+			dictionary.Add("T", t.reference)
+		}
+		dictionary.Add("N", t.beads[(i+1)%n].reference)
+		dictionary.Add("V", t.beads[(i+n-1)%n].reference)
+		dictionary.Add("P", bead.page)
+		dictionary.Add("R", bead.rectangle)
+		bead.reference.Write(dictionary)
+	}
+
+	info := NewDictionary()
+	if t.Title != "" {
+		info.Add("Title", NewTextString(t.Title))
+	}
+	thread := NewDictionary()
+	thread.Add("Type", NewName("Thread"))
+	thread.Add("F", t.beads[0].reference)
+	thread.Add("I", info)
+	t.reference.Write(thread)
+}
+
+func (d *Document) finishThreads() {
+	if len(d.threads) == 0 {
+		return
+	}
+	threads := d.catalogArray("Threads")
+	for _,t := range d.threads {
+		if len(t.beads) == 0 {
+			fmt.Fprintf(logger, "Warning: Thread %q has no beads and was omitted\n", t.Title)
+			continue
+		}
+		t.finish()
+		threads.Add(t.reference)
+	}
+	d.threads = nil
+}
+
+// ExistingThread is an article thread read from the document.
+type ExistingThread struct {
+	Title string
+	Beads []ExistingBead
+}
+
+// ExistingBead is a bead of an ExistingThread.
+type ExistingBead struct {
+	// PageNumber is the number of the bead's page, numbered from
+	// 0, or -1 if the page isn't in the document's page tree.
+	PageNumber int
+	// Rectangle is the bead's rectangle as llx, lly, urx, ury.
+	Rectangle [4]float64
+}
+
+// Threads() returns the article threads of the document as it was
+// opened, following the beads of each from the first.
+func (d *Document) Threads() []ExistingThread {
+	threads := d.catalog.GetArray("Threads")
+	if threads == nil {
+		return nil
+	}
+
+	var pageNumbers map[ObjectNumber]int
+	pageNumber := func(page Object) int {
+		if pageNumbers == nil {
+			pageNumbers = d.existingPageNumbers()
+		}
+		if reference,ok := page.(ProtectedIndirect); ok && reference.BoundToFile(d.file) {
+			if n,ok := pageNumbers[reference.ObjectNumber(d.file)]; ok {
+				return n
+			}
+		}
+		return -1
+	}
+
+	result := make([]ExistingThread, 0, threads.Size())
+	for i:=0; i<threads.Size(); i++ {
+		thread,ok := threads.At(i).Dereference().(ProtectedDictionary)
+		if !ok {
+			continue
+		}
+		var t ExistingThread
+		if info := thread.GetDictionary("I"); info != nil {
+			t.Title = textString(info, "Title")
+		}
+		first,_ := thread.Get("F").(ProtectedIndirect)
+		bead := first
+		for count := 0; bead != nil && count < maxBeads; count++ {
+			dictionary,ok := bead.Dereference().(ProtectedDictionary)
+			if !ok {
+				break
+			}
+			var b ExistingBead
+			b.PageNumber = pageNumber(dictionary.Get("P"))
+			if r := dictionary.GetArray("R"); r != nil {
+				if coordinates,ok := r.Floats(); ok && len(coordinates) == 4 {
+					copy(b.Rectangle[:], coordinates)
+					b.Rectangle = normalizeBox(b.Rectangle)
+				}
+			}
+			t.Beads = append(t.Beads, b)
+
+			bead,_ = dictionary.Get("N").(ProtectedIndirect)
+			if bead != nil && first.ObjectNumber(d.file) == bead.ObjectNumber(d.file) {
+				break
+			}
+		}
+		result = append(result, t)
+	}
+	return result
+}
+
+// existingPageNumbers() maps the object number of each page of the
+// document as it was opened to its page number.
+func (d *Document) existingPageNumbers() map[ObjectNumber]int {
+	result := make(map[ObjectNumber]int)
+	tree := existingPageTree(d.file)
+	for n := uint(0); n < tree.pageCount; n++ {
+		if page := pageFromTree(tree.root, n); page != nil {
+			result[page.reference.ObjectNumber(d.file)] = int(n)
+		}
+	}
+	return result
+}
@@ -0,0 +1,176 @@
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"unicode/utf16" )
+
+// A Type3Glyph is a glyph of a Type3Font.
+type Type3Glyph struct {
+	// Name is the glyph name used in the font's encoding, e.g.,
+	// "stamp" or "a".  It must be unique within the font.
+	Name string
+	// Width is the horizontal advance in glyph space.
+	Width float64
+	// Procedure is the content stream that paints the glyph in
+	// glyph space.  It must begin with a d0 or d1 operator, e.g.,
+	// "100 0 0 0 100 100 d1".
+	Procedure []byte
+	// Unicode is the text the glyph represents for text
+	// extraction, or "" if there is none.
+	Unicode string
+}
+
+// Type3Font is a font whose glyphs are content streams, for custom
+// symbols and stamps that ordinary fonts don't provide.  Glyphs are
+// shown with single-byte codes.
+type Type3Font struct {
+	fileBindings map[File]Indirect
+	matrix [6]float64
+	bbox [4]float64
+	glyphs map[byte]Type3Glyph
+	resources Dictionary
+}
+
+// NewType3Font() constructs a Type3Font whose glyph space is mapped
+// to text space by matrix.  The usual matrix, {0.001, 0, 0, 0.001,
+// 0, 0}, gives glyph space 1000 units per em, as for other fonts.
+// bbox is the union of the glyphs' bounding boxes in glyph space; it
+// may be all zeros if it is not known.
+func NewType3Font(matrix [6]float64, bbox [4]float64) *Type3Font {
+	return &Type3Font{
+		fileBindings: make(map[File]Indirect, 5),
+		matrix: matrix,
+		bbox: bbox,
+		glyphs: make(map[byte]Type3Glyph) }
+}
+
+// AddGlyph() adds a glyph shown by code, replacing any glyph
+// previously added for code.  Glyphs must be added before the font
+// is used on a page.
+func (font *Type3Font) AddGlyph(code byte, glyph Type3Glyph) {
+	if len(font.fileBindings) != 0 {
+		panic("Glyph added to a Type3 font that has been written")
+	}
+	font.glyphs[code] = glyph
+}
+
+// SetResources() sets the resources, such as images or other fonts,
+// that the glyph procedures use.
+func (font *Type3Font) SetResources(resources Dictionary) {
+	font.resources = resources
+}
+
+func (font *Type3Font) Indirect(file File) Indirect {
+	file = bindingKey(file)
+	i,exists := font.fileBindings[file]
+	if !exists {
+		i = file.WriteObject(font.dictionary(file))
+		font.fileBindings[file] = i
+	}
+	return i
+}
+
+// Type3 fonts are always embedded since their glyphs are part of the
+// font dictionary.
+func (font *Type3Font) Embedded() bool {
+	return true
+}
+
+// dictionary() writes the glyph procedures to file and returns the
+// font dictionary.
+func (font *Type3Font) dictionary(file File) Dictionary {
+	codes := make([]int, 0, len(font.glyphs))
+	for code := range font.glyphs {
+		codes = append(codes, int(code))
+	}
+	sort.Ints(codes)
+
+	dictionary := NewDictionary()
+	dictionary.Add("Type", NewName("Font"))
+	dictionary.Add("Subtype", NewName("Type3"))
+	dictionary.Add("FontBBox", NewFloatArray(font.bbox[:]))
+	dictionary.Add("FontMatrix", NewFloatArray(font.matrix[:]))
+
+	charProcs := NewDictionary()
+	widths := NewArray()
+	toUnicode := make(map[uint32]string)
+	if len(codes) > 0 {
+		first,last := codes[0], codes[len(codes)-1]
+		dictionary.Add("FirstChar", NewIntNumeric(first))
+		dictionary.Add("LastChar", NewIntNumeric(last))
+		for code:=first; code<=last; code++ {
+			glyph,exists := font.glyphs[byte(code)]
+			if !exists {
+				widths.Add(NewIntNumeric(0))
+				continue
+			}
+			widths.Add(NewNumeric(glyph.Width))
+			if charProcs.Get(glyph.Name) == nil {
+				procedure := NewStream()
+				procedure.Write(glyph.Procedure)
+				charProcs.Add(glyph.Name, file.WriteObject(procedure))
+			}
+			if glyph.Unicode != "" {
+				toUnicode[uint32(code)] = glyph.Unicode
+			}
+		}
+	}
+	dictionary.Add("CharProcs", charProcs)
+	encoding := NewDictionary()
+	encoding.Add("Type", NewName("Encoding"))
+	names := make(map[byte]string, len(font.glyphs))
+	for code,glyph := range font.glyphs {
+		names[code] = glyph.Name
+	}
+	encoding.Add("Differences", differencesArray(names, nil))
+	dictionary.Add("Encoding", encoding)
+	dictionary.Add("Widths", widths)
+
+	if font.resources != nil {
+		dictionary.Add("Resources", font.resources)
+	}
+	if len(toUnicode) > 0 {
+		dictionary.Add("ToUnicode", file.WriteObject(toUnicodeStream(toUnicode, 1)))
+	}
+	return dictionary
+}
+
+// toUnicodeStream() returns a ToUnicode CMap stream mapping character
+// codes of codeLength bytes to text.
+func toUnicodeStream(mapping map[uint32]string, codeLength int) Stream {
+	codes := make([]int, 0, len(mapping))
+	for code := range mapping {
+		codes = append(codes, int(code))
+	}
+	sort.Ints(codes)
+
+	var b bytes.Buffer
+	b.WriteString("/CIDInit /ProcSet findresource begin\n12 dict begin\nbegincmap\n" +
+		"/CIDSystemInfo << /Registry (Adobe) /Ordering (UCS) /Supplement 0 >> def\n" +
+		"/CMapName /Adobe-Identity-UCS def\n/CMapType 2 def\n" +
+		"1 begincodespacerange\n")
+	fmt.Fprintf(&b, "<%0*x> <%0*x>\nendcodespacerange\n", 2*codeLength, 0, 2*codeLength, (1<<(8*uint(codeLength)))-1)
+	// A bfchar section may have at most 100 entries.
+	for i:=0; i<len(codes); i+=100 {
+		section := codes[i:]
+		if len(section) > 100 {
+			section = section[:100]
+		}
+		fmt.Fprintf(&b, "%d beginbfchar\n", len(section))
+		for _,code := range section {
+			fmt.Fprintf(&b, "<%0*x> <", 2*codeLength, code)
+			for _,unit := range utf16.Encode([]rune(mapping[uint32(code)])) {
+				fmt.Fprintf(&b, "%04x", unit)
+			}
+			b.WriteString(">\n")
+		}
+		b.WriteString("endbfchar\n")
+	}
+	b.WriteString("endcmap\nCMapName currentdict /CMap defineresource pop\nend\nend\n")
+
+	s := NewStream()
+	s.Write(b.Bytes())
+	return s
+}
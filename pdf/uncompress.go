@@ -0,0 +1,53 @@
+package pdf
+
+import "errors"
+
+var cannotUncompressEncrypted = errors.New(`Encrypted files cannot be uncompressed`)
+
+// WithInspectableOutput() returns a FileOption that writes the file
+// in a form meant to be read in a text editor or compared with diff:
+// streams other than images are written without their filters, so
+// that content streams appear as text, and dictionaries are written
+// with one key per line, indented by their nesting.  Image data, much
+// of which can't be decoded by this package anyway, keeps its
+// filters.  The output is a valid PDF file, if a large one.
+func WithInspectableOutput() FileOption {
+	return func(f *file) {
+		f.inspection = true
+	}
+}
+
+func (f *file) forInspection() bool {
+	return f.inspection
+}
+
+// forInspection() returns true if objects serialized for file should
+// be written as WithInspectableOutput() describes.
+func forInspection(file ...File) bool {
+	if len(file) == 1 {
+		if i,ok := file[0].(interface{ forInspection() bool }); ok {
+			return i.forInspection()
+		}
+	}
+	return false
+}
+
+// Uncompress() reads the PDF file named input and writes to output,
+// as qpdf's --qdf mode does, the objects reachable from its trailer
+// in the form described by WithInspectableOutput(), renumbered
+// densely from 1 with a single xref section.  Since this package
+// neither reads nor writes object streams, every object of the output
+// appears on its own.  Input and output may name the same file.
+// Options are passed to OpenFile() when reading input.
+func Uncompress(input, output string, options ...FileOption) error {
+	return rewriteFile(input, output, options, nil, uncompress)
+}
+
+func uncompress(destination *file, source File) error {
+	if source.Trailer().Get("Encrypt") != nil {
+		destination.abandon()
+		return cannotUncompressEncrypted
+	}
+	destination.inspection = true
+	return copyReachable(destination, source, false, nil)
+}
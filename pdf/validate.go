@@ -0,0 +1,687 @@
+package pdf
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"sort"
+	"strings" )
+
+// ValidationProfile selects the rules checked by Validate().
+type ValidationProfile int
+
+const (
+	// SyntacticProfile checks the structure of a file: its xref,
+	// the syntax of its objects and the lengths of its streams,
+	// its catalog and page tree, and its destinations.  Fonts
+	// that aren't embedded are reported as warnings.
+	SyntacticProfile ValidationProfile = iota
+	// PDFA2BProfile adds the rules of PDF/A-2b (ISO 19005-2)
+	// that can be checked without interpreting content streams.
+	PDFA2BProfile
+	// PDFX4Profile adds the rules of PDF/X-4 (ISO 15930-7) that
+	// can be checked without interpreting content streams.
+	PDFX4Profile )
+
+func (p ValidationProfile) String() string {
+	switch p {
+	case SyntacticProfile:
+		return "syntactic"
+	case PDFA2BProfile:
+		return "PDF/A-2b"
+	case PDFX4Profile:
+		return "PDF/X-4"
+	}
+	return fmt.Sprintf("ValidationProfile(%d)", int(p))
+}
+
+// ValidationCategory classifies the problems found by Validate().
+type ValidationCategory int
+
+const (
+	XrefCategory ValidationCategory = iota
+	SyntaxCategory
+	CatalogCategory
+	PageTreeCategory
+	FontCategory
+	DestinationCategory
+	// ConformanceCategory holds the violations of the rules
+	// specific to PDF/A or PDF/X.
+	ConformanceCategory )
+
+func (c ValidationCategory) String() string {
+	switch c {
+	case XrefCategory:
+		return "xref"
+	case SyntaxCategory:
+		return "syntax"
+	case CatalogCategory:
+		return "catalog"
+	case PageTreeCategory:
+		return "page tree"
+	case FontCategory:
+		return "fonts"
+	case DestinationCategory:
+		return "destinations"
+	case ConformanceCategory:
+		return "conformance"
+	}
+	return fmt.Sprintf("ValidationCategory(%d)", int(c))
+}
+
+// ValidationProblem is a problem found by Validate().
+type ValidationProblem struct {
+	Category ValidationCategory
+	// Warning is true if the problem doesn't make the file invalid
+	// under the profile used.
+	Warning bool
+	// Object is the number of the object in which the problem was
+	// found, or 0 if it isn't specific to one object.
+	Object uint32
+	Message string
+}
+
+func (p ValidationProblem) String() string {
+	severity := "error"
+	if p.Warning {
+		severity = "warning"
+	}
+	s := fmt.Sprintf("%s %s: ", p.Category, severity)
+	if p.Object != 0 {
+		s += fmt.Sprintf("object %d: ", p.Object)
+	}
+	return s + p.Message
+}
+
+// ValidationReport lists the problems found by Validate(), in the
+// order in which they were found.
+type ValidationReport struct {
+	Profile ValidationProfile
+	Problems []ValidationProblem
+}
+
+// Valid() returns true if the report contains no errors.  Warnings are
+// permitted.
+func (r *ValidationReport) Valid() bool {
+	for _,p := range r.Problems {
+		if !p.Warning {
+			return false
+		}
+	}
+	return true
+}
+
+// Category() returns the problems in category c.
+func (r *ValidationReport) Category(c ValidationCategory) []ValidationProblem {
+	var result []ValidationProblem
+	for _,p := range r.Problems {
+		if p.Category == c {
+			result = append(result, p)
+		}
+	}
+	return result
+}
+
+// String() returns the problems, one per line, grouped by category.
+func (r *ValidationReport) String() string {
+	problems := append([]ValidationProblem(nil), r.Problems...)
+	sort.SliceStable(problems, func(i, j int) bool {
+		return problems[i].Category < problems[j].Category
+	})
+	lines := make([]string, len(problems))
+	for i,p := range problems {
+		lines[i] = p.String()
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Validate() checks the PDF file named filename against profile and
+// returns a report of the problems found.  The file is only read.
+// An error is returned only if the file can't be opened at all;
+// damage such as a broken xref, which Validate() reports, is repaired
+// as when the file is opened normally so that the rest of the file
+// can be checked.  Options are passed to OpenFile(), but the parsing
+// mode is ignored since objects are always read strictly.
+func Validate(filename string, profile ValidationProfile, options ...FileOption) (*ValidationReport, error) {
+	f,_,err := OpenFile(filename, os.O_RDONLY, append(options, WithParsingMode(DefaultParsing))...)
+	if err != nil {
+		return nil, err
+	}
+	defer f.abandon()
+
+	v := &validator{
+		file: f,
+		report: &ValidationReport{Profile: profile},
+		broken: make(map[uint32]bool),
+		pages: make(map[ObjectNumber]bool) }
+	v.checkXref()
+	v.run(CatalogCategory, v.checkCatalog)
+	v.run(PageTreeCategory, v.checkPageTree)
+	v.run(FontCategory, v.checkFonts)
+	v.run(DestinationCategory, v.checkDestinations)
+	switch profile {
+	case PDFA2BProfile:
+		v.run(ConformanceCategory, v.checkPDFA)
+	case PDFX4Profile:
+		v.run(ConformanceCategory, v.checkPDFX)
+	}
+	return v.report, nil
+}
+
+type validator struct {
+	file *file
+	report *ValidationReport
+	// broken holds the numbers of the objects that couldn't be
+	// parsed, which aren't followed.
+	broken map[uint32]bool
+	// filters holds the names of the filters used by streams.
+	filters map[string]bool
+	// pageList holds the pages found in the page tree, in order,
+	// and pages holds their object numbers.
+	pageList []ProtectedDictionary
+	pages map[ObjectNumber]bool
+}
+
+func (v *validator) add(category ValidationCategory, warning bool, object uint32, format string, args ...interface{}) {
+	v.report.Problems = append(v.report.Problems,
+		ValidationProblem{category, warning, object, fmt.Sprintf(format, args...)})
+}
+
+func (v *validator) error(category ValidationCategory, object uint32, format string, args ...interface{}) {
+	v.add(category, false, object, format, args...)
+}
+
+// run() runs check, reporting a failure to read an object, which
+// panics, as an error in category.
+func (v *validator) run(category ValidationCategory, check func()) {
+	defer func() {
+		if r := recover(); r != nil {
+			v.error(category, 0, "%v", r)
+		}
+	}()
+	check()
+}
+
+// object() returns the object to which o refers, or nil if o refers to
+// an object that couldn't be parsed or isn't in the file.
+func (v *validator) object(o Object) Object {
+	if reference,ok := o.(ProtectedIndirect); ok {
+		n := reference.ObjectNumber(v.file)
+		if v.broken[n.number] || uint(n.number) >= v.file.xref.Size() {
+			return nil
+		}
+		object,err := v.file.Object(n)
+		if err != nil {
+			return nil
+		}
+		return object
+	}
+	return o
+}
+
+func (v *validator) dictionary(o Object) ProtectedDictionary {
+	switch t := v.object(o).(type) {
+	case ProtectedDictionary:
+		return t
+	case ProtectedStream:
+		return t.Dictionary()
+	}
+	return nil
+}
+
+// number() returns the object number of o if it is an indirect
+// reference and 0 otherwise.
+func (v *validator) number(o Object) uint32 {
+	if reference,ok := o.(ProtectedIndirect); ok {
+		return reference.ObjectNumber(v.file).number
+	}
+	return 0
+}
+
+// checkXref() reports a damaged xref and reads each object in use
+// strictly, which checks that it is at its xref offset, that its
+// syntax is correct, and that the /Length of a stream matches its
+// data.
+func (v *validator) checkXref() {
+	f := v.file
+	if f.repairReport != nil {
+		v.error(XrefCategory, 0, "Xref was rebuilt: %s", f.repairReport.Reason)
+	}
+	if f.xref.Size() == 0 {
+		v.error(XrefCategory, 0, "No objects were found")
+		return
+	}
+	if entry := (*f.xref.At(0)).(*xrefEntry); entry.inUse || entry.generation != 65535 {
+		v.error(XrefCategory, 0, "Xref entry 0 isn't the head of the free list with generation 65535")
+	}
+	if size,ok := f.trailerDictionary.GetInt("Size"); !ok || size != int(f.originalXrefSize) {
+		v.error(XrefCategory, 0, "Trailer /Size is %d but the xref has %d entries", size, f.originalXrefSize)
+	}
+
+	v.filters = make(map[string]bool)
+	for i:=uint(1); i<f.originalXrefSize; i++ {
+		entry := (*f.xref.At(i)).(*xrefEntry)
+		if !entry.inUse {
+			continue
+		}
+		parser := f.newObjectParser(entry.byteOffset, 0)
+		parser.SetMode(StrictParsing)
+		object,err := parser.ScanIndirect(ObjectNumber{uint32(i), entry.generation}, f)
+		parser.release()
+		if err != nil {
+			v.broken[uint32(i)] = true
+			if errors.Is(err, objectNumberMismatch) || errors.Is(err, invalidObjectHeader) {
+				v.error(XrefCategory, uint32(i), "Object isn't at its xref offset %d: %v", entry.byteOffset, err)
+			} else {
+				v.error(SyntaxCategory, uint32(i), "%v", err)
+			}
+			continue
+		}
+		if stream,ok := object.(ProtectedStream); ok {
+			for _,filter := range streamFilterNames(stream.Dictionary()) {
+				v.filters[filter] = true
+			}
+		}
+	}
+}
+
+// streamFilterNames() returns the names of the filters in a stream's
+// /Filter entry.
+func streamFilterNames(dictionary ProtectedDictionary) []string {
+	if name,ok := dictionary.GetName("Filter"); ok {
+		return []string{name}
+	}
+	var names []string
+	if filters := dictionary.GetArray("Filter"); filters != nil {
+		for i:=0; i<filters.Size(); i++ {
+			if name,ok := filters.At(i).(*name); ok {
+				names = append(names, name.String())
+			}
+		}
+	}
+	return names
+}
+
+func (v *validator) catalog() ProtectedDictionary {
+	return v.dictionary(v.file.trailerDictionary.Get("Root"))
+}
+
+func (v *validator) checkCatalog() {
+	root := v.file.trailerDictionary.Get("Root")
+	if root == nil {
+		v.error(CatalogCategory, 0, "Trailer has no /Root")
+		return
+	}
+	if _,ok := root.(ProtectedIndirect); !ok {
+		v.error(CatalogCategory, 0, "Trailer /Root isn't an indirect reference")
+	}
+	catalog := v.catalog()
+	if catalog == nil {
+		v.error(CatalogCategory, v.number(root), "Catalog isn't a readable dictionary")
+		return
+	}
+	if !catalog.CheckNameValue("Type", "Catalog") {
+		v.error(CatalogCategory, v.number(root), "Catalog /Type isn't /Catalog")
+	}
+	pages := catalog.Get("Pages")
+	if pages == nil {
+		v.error(CatalogCategory, v.number(root), "Catalog has no /Pages")
+	} else if _,ok := pages.(ProtectedIndirect); !ok {
+		v.error(CatalogCategory, v.number(root), "Catalog /Pages isn't an indirect reference")
+	}
+}
+
+// checkPageTree() walks the page tree, checking the /Type, /Kids,
+// /Count, and /Parent of each node and that each page has a media
+// box, and records the pages.
+func (v *validator) checkPageTree() {
+	catalog := v.catalog()
+	if catalog == nil || catalog.Get("Pages") == nil {
+		return
+	}
+	root := catalog.Get("Pages")
+	node := v.dictionary(root)
+	if node == nil {
+		v.error(PageTreeCategory, v.number(root), "Page tree root isn't a readable dictionary")
+		return
+	}
+	if !node.CheckNameValue("Type", "Pages") {
+		v.error(PageTreeCategory, v.number(root), "Page tree root /Type isn't /Pages")
+	}
+	visited := map[uint32]bool{v.number(root): true}
+	v.checkPageTreeNode(root, node, node.Get("MediaBox") != nil, visited, 0)
+}
+
+// checkPageTreeNode() checks the intermediate node at reference and
+// its descendants and returns the number of pages below it.
+func (v *validator) checkPageTreeNode(reference Object, node ProtectedDictionary, mediaBox bool, visited map[uint32]bool, depth int) int {
+	n := v.number(reference)
+	if depth > maxTreeDepth {
+		v.error(PageTreeCategory, n, "Page tree is nested more than %d levels deep", maxTreeDepth)
+		return 0
+	}
+	kids := node.GetArray("Kids")
+	if kids == nil {
+		v.error(PageTreeCategory, n, "Page tree node has no /Kids array")
+		return 0
+	}
+	count := 0
+	for i:=0; i<kids.Size(); i++ {
+		kid := kids.At(i)
+		k := v.number(kid)
+		if k == 0 {
+			v.error(PageTreeCategory, n, "Kid %d isn't an indirect reference", i)
+			continue
+		}
+		if visited[k] {
+			v.error(PageTreeCategory, k, "Page tree node appears more than once")
+			continue
+		}
+		visited[k] = true
+		dictionary := v.dictionary(kid)
+		if dictionary == nil {
+			v.error(PageTreeCategory, k, "Page tree node isn't a readable dictionary")
+			continue
+		}
+		if parent := dictionary.Get("Parent"); v.number(parent) != n {
+			v.error(PageTreeCategory, k, "/Parent doesn't refer to object %d", n)
+		}
+		hasMediaBox := mediaBox || dictionary.Get("MediaBox") != nil
+		switch {
+		case dictionary.CheckNameValue("Type", "Pages"):
+			count += v.checkPageTreeNode(kid, dictionary, hasMediaBox, visited, depth+1)
+		case dictionary.CheckNameValue("Type", "Page"):
+			if !hasMediaBox {
+				v.error(PageTreeCategory, k, "Page has no /MediaBox, nor inherits one")
+			}
+			v.pageList = append(v.pageList, dictionary)
+			v.pages[kid.(ProtectedIndirect).ObjectNumber(v.file)] = true
+			count++
+		default:
+			v.error(PageTreeCategory, k, "Page tree node /Type is neither /Pages nor /Page")
+		}
+	}
+	if declared,ok := node.GetInt("Count"); !ok {
+		v.error(PageTreeCategory, n, "Page tree node has no /Count")
+	} else if declared != count {
+		v.error(PageTreeCategory, n, "/Count is %d but the node has %d pages", declared, count)
+	}
+	return count
+}
+
+// pageResources() returns the resources of a page, which may be
+// inherited.
+func (v *validator) pageResources(page ProtectedDictionary) ProtectedDictionary {
+	for depth:=0; page != nil && depth <= maxTreeDepth; depth++ {
+		if resources := v.dictionary(page.Get("Resources")); resources != nil {
+			return resources
+		}
+		page = v.dictionary(page.Get("Parent"))
+	}
+	return nil
+}
+
+// checkFonts() checks that the fonts used by each page, and by the
+// forms it draws, are embedded.  Each font is reported once.
+func (v *validator) checkFonts() {
+	// Embedding is required by PDF/A and PDF/X.
+	warning := v.report.Profile == SyntacticProfile
+	reported := make(map[interface{}]bool)
+	var check func(resources ProtectedDictionary, visited map[uint32]bool, depth int)
+	check = func(resources ProtectedDictionary, visited map[uint32]bool, depth int) {
+		if resources == nil || depth > maxFormDepth {
+			return
+		}
+		if fonts := v.dictionary(resources.Get("Font")); fonts != nil {
+			for _,key := range fonts.Keys() {
+				dictionary := v.dictionary(fonts.Get(key))
+				if dictionary == nil {
+					continue
+				}
+				var identity interface{} = dictionary
+				if n := v.number(fonts.Get(key)); n != 0 {
+					identity = n
+				}
+				if reported[identity] {
+					continue
+				}
+				reported[identity] = true
+				info := newFontInfo(dictionary)
+				if !info.Embedded {
+					v.add(FontCategory, warning, v.number(fonts.Get(key)), "Font %s is not embedded", info.BaseFont)
+				}
+			}
+		}
+		if xobjects := v.dictionary(resources.Get("XObject")); xobjects != nil {
+			for _,key := range xobjects.Keys() {
+				n := v.number(xobjects.Get(key))
+				if n == 0 || visited[n] {
+					continue
+				}
+				visited[n] = true
+				if form,ok := v.object(xobjects.Get(key)).(ProtectedStream); ok && form.Dictionary().CheckNameValue("Subtype", "Form") {
+					check(v.dictionary(form.Dictionary().Get("Resources")), visited, depth+1)
+				}
+			}
+		}
+	}
+	for _,page := range v.pageList {
+		check(v.pageResources(page), make(map[uint32]bool), 0)
+	}
+}
+
+// checkDestinations() checks that the destinations of the open
+// action, outline items, and link annotations refer to pages of the
+// document or to named destinations that exist.
+func (v *validator) checkDestinations() {
+	catalog := v.catalog()
+	if catalog == nil {
+		return
+	}
+	named := make(map[string]Object)
+	if dests := v.dictionary(catalog.Get("Dests")); dests != nil {
+		for _,key := range dests.Keys() {
+			named[key] = dests.Get(key)
+		}
+	}
+	if names := v.dictionary(catalog.Get("Names")); names != nil {
+		if tree := v.dictionary(names.Get("Dests")); tree != nil {
+			forEachNameTreeEntry(tree, func(key string, value Object) {
+				named[key] = value
+			})
+		}
+	}
+	for name,value := range named {
+		if d := v.dictionary(value); d != nil {
+			value = d.Get("D")
+		}
+		v.checkDestination(0, v.object(value), nil, fmt.Sprintf("Named destination %q", name))
+	}
+
+	if open := catalog.Get("OpenAction"); open != nil {
+		v.checkAction(v.number(catalog.Get("OpenAction")), v.object(open), named, "Open action")
+	}
+
+	if outlines := v.dictionary(catalog.Get("Outlines")); outlines != nil {
+		visited := make(map[uint32]bool)
+		var walk func(first Object, depth int)
+		walk = func(item Object, depth int) {
+			for ; item != nil && depth <= maxTreeDepth; {
+				n := v.number(item)
+				if n == 0 || visited[n] {
+					if n != 0 {
+						v.error(DestinationCategory, n, "Outline item appears more than once")
+					}
+					return
+				}
+				visited[n] = true
+				dictionary := v.dictionary(item)
+				if dictionary == nil {
+					return
+				}
+				if dest := dictionary.Get("Dest"); dest != nil {
+					v.checkDestination(n, v.object(dest), named, "Outline item")
+				}
+				if action := dictionary.Get("A"); action != nil {
+					v.checkAction(n, v.object(action), named, "Outline item")
+				}
+				walk(dictionary.Get("First"), depth+1)
+				item = dictionary.Get("Next")
+			}
+		}
+		walk(outlines.Get("First"), 0)
+	}
+
+	for _,page := range v.pageList {
+		annotations,_ := v.object(page.Get("Annots")).(ProtectedArray)
+		if annotations == nil {
+			continue
+		}
+		for i:=0; i<annotations.Size(); i++ {
+			annotation := v.dictionary(annotations.At(i))
+			if annotation == nil || !annotation.CheckNameValue("Subtype", "Link") {
+				continue
+			}
+			n := v.number(annotations.At(i))
+			if dest := annotation.Get("Dest"); dest != nil {
+				v.checkDestination(n, v.object(dest), named, "Link")
+			}
+			if action := annotation.Get("A"); action != nil {
+				v.checkAction(n, v.object(action), named, "Link")
+			}
+		}
+	}
+}
+
+// checkAction() checks the destination of a GoTo action.  An open
+// action may also be a destination itself.
+func (v *validator) checkAction(n uint32, action Object, named map[string]Object, context string) {
+	switch t := action.(type) {
+	case ProtectedArray:
+		v.checkDestination(n, t, named, context)
+	case ProtectedDictionary:
+		if t.CheckNameValue("S", "GoTo") {
+			v.checkDestination(n, v.object(t.Get("D")), named, context)
+		}
+	}
+}
+
+// checkDestination() checks an explicit destination, whose page must
+// be in the page tree, or a named destination, which must be in named.
+// named is nil when checking the named destinations themselves.
+func (v *validator) checkDestination(n uint32, dest Object, named map[string]Object, context string) {
+	switch t := dest.(type) {
+	case ProtectString:
+		if _,ok := named[string(t.Bytes())]; !ok && named != nil {
+			v.error(DestinationCategory, n, "%s refers to undefined destination %q", context, t.Bytes())
+		}
+	case *name:
+		if _,ok := named[t.String()]; !ok && named != nil {
+			v.error(DestinationCategory, n, "%s refers to undefined destination /%s", context, t.String())
+		}
+	case ProtectedArray:
+		if t.Size() < 2 {
+			v.error(DestinationCategory, n, "%s has a destination array of %d elements", context, t.Size())
+			return
+		}
+		page,ok := t.At(0).(ProtectedIndirect)
+		if !ok || !v.pages[page.ObjectNumber(v.file)] {
+			v.error(DestinationCategory, n, "%s refers to a page that isn't in the page tree", context)
+		}
+	default:
+		v.error(DestinationCategory, n, "%s has an invalid destination", context)
+	}
+}
+
+// metadata() returns the contents of the catalog's XMP metadata
+// stream, or nil.
+func (v *validator) metadata() []byte {
+	catalog := v.catalog()
+	if catalog == nil {
+		return nil
+	}
+	stream,ok := v.object(catalog.Get("Metadata")).(ProtectedStream)
+	if !ok {
+		return nil
+	}
+	r := stream.Reader()
+	if r == nil {
+		return nil
+	}
+	contents,_ := ioutil.ReadAll(r)
+	return contents
+}
+
+// checkOutputIntent() reports an error unless the catalog has an
+// output intent of the given subtype with an ICC profile.
+func (v *validator) checkOutputIntent(subtype string) {
+	catalog := v.catalog()
+	if catalog == nil {
+		return
+	}
+	if intents,ok := v.object(catalog.Get("OutputIntents")).(ProtectedArray); ok {
+		for i:=0; i<intents.Size(); i++ {
+			if intent := v.dictionary(intents.At(i)); intent != nil && intent.CheckNameValue("S", subtype) && intent.Get("DestOutputProfile") != nil {
+				return
+			}
+		}
+	}
+	v.error(ConformanceCategory, 0, "No /OutputIntents entry with subtype /%s and an ICC profile", subtype)
+}
+
+// checkCommon() checks the rules shared by PDF/A and PDF/X.
+func (v *validator) checkCommon() {
+	trailer := v.file.trailerDictionary
+	if trailer.Get("Encrypt") != nil {
+		v.error(ConformanceCategory, 0, "File is encrypted")
+	}
+	if trailer.Get("ID") == nil {
+		v.error(ConformanceCategory, 0, "Trailer has no /ID")
+	}
+	if catalog := v.catalog(); catalog != nil {
+		javaScript := containsJavaScript(catalog, v.file)
+		if names := v.dictionary(catalog.Get("Names")); names != nil && names.Get("JavaScript") != nil {
+			javaScript = true
+		}
+		if javaScript {
+			v.error(ConformanceCategory, 0, "Document contains JavaScript")
+		}
+	}
+}
+
+var (
+	pdfaPartPattern = regexp.MustCompile(`pdfaid:part(="|>)2\b`)
+	pdfxVersionPattern = regexp.MustCompile(`pdfxid:GTS_PDFXVersion(="|>)PDF/X-4\b`) )
+
+func (v *validator) checkPDFA() {
+	v.checkCommon()
+	v.checkOutputIntent(OutputIntentPDFA)
+	if metadata := v.metadata(); metadata == nil {
+		v.error(ConformanceCategory, 0, "Catalog has no XMP /Metadata")
+	} else if !pdfaPartPattern.Match(metadata) {
+		v.error(ConformanceCategory, 0, "XMP metadata doesn't identify PDF/A-2")
+	}
+	if v.filters["LZWDecode"] {
+		v.error(ConformanceCategory, 0, "Streams use the LZWDecode filter")
+	}
+}
+
+func (v *validator) checkPDFX() {
+	v.checkCommon()
+	v.checkOutputIntent(OutputIntentPDFX)
+	if metadata := v.metadata(); metadata == nil {
+		v.error(ConformanceCategory, 0, "Catalog has no XMP /Metadata")
+	} else if !pdfxVersionPattern.Match(metadata) {
+		v.error(ConformanceCategory, 0, "XMP metadata doesn't identify PDF/X-4")
+	}
+	if v.file.Version() < PDF16 {
+		v.error(ConformanceCategory, 0, "PDF/X-4 requires PDF 1.6 or later, but the file is PDF %v", v.file.Version())
+	}
+	for i,page := range v.pageList {
+		if page.Get("TrimBox") == nil && page.Get("ArtBox") == nil {
+			v.error(ConformanceCategory, 0, "Page %d has neither a /TrimBox nor an /ArtBox", i+1)
+		}
+	}
+}
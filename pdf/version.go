@@ -0,0 +1,172 @@
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"regexp" )
+
+// Version identifies a version of the PDF specification: 14 for
+// PDF 1.4, 20 for PDF 2.0, and so on.
+type Version uint
+
+const (
+	PDF14 Version = 14
+	PDF15 Version = 15
+	PDF16 Version = 16
+	PDF17 Version = 17
+	PDF20 Version = 20 )
+
+// DefaultVersion is the version of new files for which WithVersion()
+// isn't used.
+const DefaultVersion = PDF14
+
+func (v Version) String() string {
+	return fmt.Sprintf("%d.%d", v/10, v%10)
+}
+
+var versionPattern = regexp.MustCompile(`^([12])\.(\d)$`)
+
+// parseVersion() parses a version such as "1.7" from a header or a
+// catalog /Version entry.
+func parseVersion(s string) (Version, bool) {
+	match := versionPattern.FindStringSubmatch(s)
+	if match == nil {
+		return 0, false
+	}
+	return Version(10*(match[1][0]-'0') + match[2][0]-'0'), true
+}
+
+// WithVersion() returns a FileOption that writes a new file as the
+// specified version, which must be one of PDF14 through PDF20.  For a
+// pre-existing file, the version is recorded in the catalog if it is
+// newer than the file's version.  In either case the version may be
+// raised further if features requiring a newer version are used.
+func WithVersion(version Version) FileOption {
+	if version < PDF14 || (version > PDF17 && version != PDF20) {
+		panic(fmt.Sprintf("Unsupported PDF version %v", version))
+	}
+	return func(f *file) {
+		f.pdfVersion = version
+	}
+}
+
+// Version() returns the version of the PDF specification to which the
+// file conforms: the version in its header, or the catalog's
+// /Version if that is newer, raised if necessary to the version
+// required by features used since the file was opened.
+func (f *file) Version() Version {
+	result := f.headerVersion
+	if f.pdfVersion > result {
+		result = f.pdfVersion
+	}
+	if v := f.catalogVersion(); v > result {
+		result = v
+	}
+	return result
+}
+
+// requireVersion() raises the version of the file to at least
+// version.  It is called when a feature requiring a newer version is
+// used.
+func (f *file) requireVersion(version Version) {
+	if version > f.pdfVersion {
+		f.pdfVersion = version
+	}
+}
+
+// versionRequirer is implemented by Files that keep track of the
+// version required by the features used.
+type versionRequirer interface {
+	requireVersion(Version)
+}
+
+// versionOf() returns the version of f, or DefaultVersion if f
+// doesn't keep track of its version.
+func versionOf(f File) Version {
+	if v,ok := f.(interface{ Version() Version }); ok {
+		return v.Version()
+	}
+	return DefaultVersion
+}
+
+// requireVersion() raises the version of the document's file to at
+// least version.
+func (d *Document) requireVersion(version Version) {
+	if r,ok := d.file.(versionRequirer); ok {
+		r.requireVersion(version)
+	}
+}
+
+func (f *file) catalogVersion() Version {
+	if catalog := f.Catalog(); catalog != nil {
+		if name,ok := catalog.GetName("Version"); ok {
+			if v,ok := parseVersion(name); ok {
+				return v
+			}
+		}
+	}
+	return 0
+}
+
+// readHeaderVersion() returns the version in the header of a
+// pre-existing file, which is found in its first kilobyte.  It
+// returns DefaultVersion if there is no valid header.
+func (f *file) readHeaderVersion() Version {
+	buffer := make([]byte, 1024)
+	n,_ := f.file.ReadAt(buffer, 0)
+	if i := bytes.Index(buffer[:n], []byte("%PDF-")); i >= 0 && i+8 <= n {
+		if v,ok := parseVersion(string(buffer[i+5:i+8])); ok {
+			return v
+		}
+	}
+	return DefaultVersion
+}
+
+// header() returns the header of a file of the specified version,
+// which includes a comment containing bytes above 127 so that tools
+// treat the file as binary.
+func header(version Version) string {
+	return fmt.Sprintf("%%PDF-%v\n%%\xe2\xe3\xcf\xd3\n", version)
+}
+
+// recordVersion() is called as the file is closed.  If features used
+// since the file was opened require a newer version than its header
+// declares, the catalog's /Version entry is set, unless the file is
+// new and its header can simply be rewritten, which is done by
+// rewriteHeader() once all objects have been written.
+func (f *file) recordVersion() {
+	if f.pdfVersion <= f.headerVersion || f.pdfVersion <= f.catalogVersion() || f.headerRewritable() {
+		return
+	}
+	root,ok := f.trailerDictionary.Get("Root").(Indirect)
+	catalog := f.Catalog()
+	if !ok || catalog == nil {
+		fmt.Fprintf(logger, "Warning: Unable to record PDF version %v in the catalog\n", f.pdfVersion)
+		return
+	}
+	updated := catalog.Unprotect().Clone().(Dictionary)
+	updated.Add("Version", NewName(f.pdfVersion.String()))
+	f.WriteObjectAt(root.ObjectNumber(f), updated)
+}
+
+// headerRewritable() returns true if the file is new and its header
+// can be overwritten.
+func (f *file) headerRewritable() bool {
+	_,writeOnly := f.file.(*writeOnlyStorage)
+	return f.originalSize == 0 && !writeOnly
+}
+
+// rewriteHeader() overwrites the header of a new file with that of
+// a newer version.  Every version has a header of the same length.
+func (f *file) rewriteHeader() {
+	if f.pdfVersion <= f.headerVersion || !f.headerRewritable() {
+		return
+	}
+	if _,err := f.Seek(0, os.SEEK_SET); err != nil {
+		panic("Unable to rewrite PDF header")
+	}
+	f.writer.WriteString(header(f.pdfVersion))
+	f.headerVersion = f.pdfVersion
+	f.Seek(0, os.SEEK_END)
+}
@@ -0,0 +1,186 @@
+package pdf
+
+// PageLayout is the arrangement of pages when the document is opened.
+// Its value is the /PageLayout name used in the catalog.
+type PageLayout string
+
+const (
+	// SinglePageLayout displays one page at a time.  This is
+	// the default.
+	SinglePageLayout PageLayout = "SinglePage"
+	// OneColumnLayout displays the pages in a column.
+	OneColumnLayout PageLayout = "OneColumn"
+	// TwoColumnLeftLayout and TwoColumnRightLayout display the
+	// pages in two columns, with odd-numbered pages on the left
+	// or right.
+	TwoColumnLeftLayout PageLayout = "TwoColumnLeft"
+	TwoColumnRightLayout PageLayout = "TwoColumnRight"
+	// TwoPageLeftLayout and TwoPageRightLayout display two pages
+	// at a time, with odd-numbered pages on the left or right.
+	// They require PDF 1.5.
+	TwoPageLeftLayout PageLayout = "TwoPageLeft"
+	TwoPageRightLayout PageLayout = "TwoPageRight" )
+
+// PageMode determines which panel, if any, is shown when the document
+// is opened.  Its value is the /PageMode name used in the catalog.
+type PageMode string
+
+const (
+	// UseNoneMode shows neither outlines nor thumbnails.  This is
+	// the default.
+	UseNoneMode PageMode = "UseNone"
+	UseOutlinesMode PageMode = "UseOutlines"
+	UseThumbsMode PageMode = "UseThumbs"
+	// FullScreenMode hides the menu bar, window controls, and
+	// every other window.
+	FullScreenMode PageMode = "FullScreen"
+	// UseOCMode shows the layers panel.  It requires PDF 1.5.
+	UseOCMode PageMode = "UseOC"
+	// UseAttachmentsMode shows the attachments panel.  It
+	// requires PDF 1.6.
+	UseAttachmentsMode PageMode = "UseAttachments" )
+
+// Duplex is the paper handling preselected in the print dialog.  Its
+// value is the /Duplex name used in viewer preferences.
+type Duplex string
+
+const (
+	// NoDuplex leaves the paper handling to the viewer.
+	NoDuplex Duplex = ""
+	Simplex Duplex = "Simplex"
+	// DuplexFlipShortEdge and DuplexFlipLongEdge print on both
+	// sides of the paper, flipping it on the short or long edge.
+	DuplexFlipShortEdge Duplex = "DuplexFlipShortEdge"
+	DuplexFlipLongEdge Duplex = "DuplexFlipLongEdge" )
+
+// PrintScaling is the page scaling preselected in the print dialog.
+// Its value is the /PrintScaling name used in viewer preferences.
+type PrintScaling string
+
+const (
+	// AppDefaultScaling uses the viewer's default scaling.  This
+	// is the default.
+	AppDefaultScaling PrintScaling = "AppDefault"
+	// NoScaling prints pages at their actual size, as forms and
+	// labels printed on preprinted stock require.
+	NoScaling PrintScaling = "None" )
+
+// ViewerPreferences describes how a viewer should present the
+// document, corresponding to the catalog's /ViewerPreferences
+// dictionary.  The zero value requests the viewer's defaults.
+type ViewerPreferences struct {
+	HideToolbar bool
+	HideMenubar bool
+	HideWindowUI bool
+	// FitWindow resizes the window to fit the first page.
+	FitWindow bool
+	CenterWindow bool
+	// DisplayDocTitle shows the document's title rather than its
+	// filename in the title bar.
+	DisplayDocTitle bool
+	// Duplex requires PDF 1.7.
+	Duplex Duplex
+	// PrintScaling requires PDF 1.6.
+	PrintScaling PrintScaling
+}
+
+// viewerPreferenceFlags lists the boolean viewer preferences with
+// pointers to their fields in p.
+func viewerPreferenceFlags(p *ViewerPreferences) []struct{ key string; value *bool } {
+	return []struct{ key string; value *bool } {
+		{"HideToolbar", &p.HideToolbar},
+		{"HideMenubar", &p.HideMenubar},
+		{"HideWindowUI", &p.HideWindowUI},
+		{"FitWindow", &p.FitWindow},
+		{"CenterWindow", &p.CenterWindow},
+		{"DisplayDocTitle", &p.DisplayDocTitle} }
+}
+
+// SetPageLayout() sets the arrangement of pages when the document is
+// opened.
+func (d *Document) SetPageLayout(layout PageLayout) {
+	if layout == TwoPageLeftLayout || layout == TwoPageRightLayout {
+		d.requireVersion(PDF15)
+	}
+	d.catalog.Add("PageLayout", NewName(string(layout)))
+}
+
+// PageLayout() returns the arrangement of pages when the document is
+// opened.
+func (d *Document) PageLayout() PageLayout {
+	if layout,ok := d.catalog.GetName("PageLayout"); ok {
+		return PageLayout(layout)
+	}
+	return SinglePageLayout
+}
+
+// SetPageMode() sets the panel shown when the document is opened.
+func (d *Document) SetPageMode(mode PageMode) {
+	switch mode {
+	case UseOCMode:
+		d.requireVersion(PDF15)
+	case UseAttachmentsMode:
+		d.requireVersion(PDF16)
+	}
+	d.catalog.Add("PageMode", NewName(string(mode)))
+}
+
+// PageMode() returns the panel shown when the document is opened.
+func (d *Document) PageMode() PageMode {
+	if mode,ok := d.catalog.GetName("PageMode"); ok {
+		return PageMode(mode)
+	}
+	return UseNoneMode
+}
+
+// SetViewerPreferences() replaces the document's viewer preferences.
+// Only preferences that differ from their defaults are written, and
+// entries of an existing /ViewerPreferences dictionary that
+// ViewerPreferences doesn't represent are kept.
+func (d *Document) SetViewerPreferences(preferences ViewerPreferences) {
+	dictionary := d.catalogDictionary("ViewerPreferences")
+	for _,flag := range viewerPreferenceFlags(&preferences) {
+		if *flag.value {
+			dictionary.Add(flag.key, NewBoolean(true))
+		} else {
+			dictionary.Remove(flag.key)
+		}
+	}
+
+	if preferences.Duplex != NoDuplex {
+		d.requireVersion(PDF17)
+		dictionary.Add("Duplex", NewName(string(preferences.Duplex)))
+	} else {
+		dictionary.Remove("Duplex")
+	}
+
+	if preferences.PrintScaling != "" && preferences.PrintScaling != AppDefaultScaling {
+		d.requireVersion(PDF16)
+		dictionary.Add("PrintScaling", NewName(string(preferences.PrintScaling)))
+	} else {
+		dictionary.Remove("PrintScaling")
+	}
+
+	if dictionary.Size() == 0 {
+		d.catalog.Remove("ViewerPreferences")
+	}
+}
+
+// ViewerPreferences() returns the document's viewer preferences.
+func (d *Document) ViewerPreferences() ViewerPreferences {
+	result := ViewerPreferences{PrintScaling: AppDefaultScaling}
+	dictionary := d.catalog.GetDictionary("ViewerPreferences")
+	if dictionary == nil {
+		return result
+	}
+	for _,flag := range viewerPreferenceFlags(&result) {
+		*flag.value,_ = dictionary.GetBoolean(flag.key)
+	}
+	if duplex,ok := dictionary.GetName("Duplex"); ok {
+		result.Duplex = Duplex(duplex)
+	}
+	if scaling,ok := dictionary.GetName("PrintScaling"); ok {
+		result.PrintScaling = PrintScaling(scaling)
+	}
+	return result
+}
@@ -0,0 +1,230 @@
+package pdf
+
+import (
+	"fmt"
+	"math"
+	"strconv" )
+
+// WatermarkOptions controls the placement of a watermark.
+type WatermarkOptions struct {
+	// Pages lists the pages (numbered from 0) to be stamped.  If
+	// it is nil, every page is stamped.
+	Pages []uint
+	// Under places the watermark beneath the existing page
+	// content.  Otherwise it is drawn over the content.
+	Under bool
+	// Opacity ranges from 0 to 1.  A value of 0 is treated as 1
+	// (opaque).
+	Opacity float64
+	// Rotation is the counterclockwise rotation of the watermark
+	// in degrees.
+	Rotation float64
+	// Scale multiplies the size of the watermark.  A value of 0
+	// is treated as 1.
+	Scale float64
+}
+
+// Resource names used by watermarks.  They are chosen to be unlikely
+// to collide with names already in use on a page.  A numeric suffix is
+// added to them if a page already uses them, as it does once it has
+// been watermarked.
+const (
+	watermarkFormName = "PDFiGWatermark"
+	watermarkStateName = "PDFiGWatermarkGS" )
+
+// TextWatermark() stamps pages with text set in Helvetica at the
+// specified size in 50% gray, centered on each page's media box.
+// The text is measured with Helvetica's metrics and kerned.  Text
+// that Helvetica's built-in encoding can't represent is written as a
+// text string, and its width is estimated from Helvetica's average
+// character width, so the centering is approximate.
+func (d *Document) TextWatermark(text string, fontSize float64, options WatermarkOptions) {
+	fontResources := NewDictionary()
+	fontResources.Add("F1", NewStandardFont(Helvetica).Indirect(d.file))
+	resources := NewDictionary()
+	resources.Add("Font", fontResources)
+
+	show,width,err := StandardFontMetrics(Helvetica).ShowText(text, fontSize, true)
+	if err != nil {
+		show = Operation{"Tj", []Object{NewTextString(text)}}
+		width = 0.55 * fontSize * float64(len([]rune(text)))
+	}
+	height := fontSize
+
+	form := d.newWatermarkForm(width, height, resources)
+	precision := realPrecision(d.file)
+	fmt.Fprintf(form, "0.5 g BT /F1 %s Tf 0 %s Td ", FormatReal(fontSize, precision), FormatReal(0.2*fontSize, precision))
+	NewContentSerializer(form).Write(show)
+	fmt.Fprint(form, "ET")
+	d.Watermark(form, width, height, options)
+}
+
+// ImageWatermark() stamps pages with an image XObject drawn with the
+// specified width and height, centered on each page's media box.
+func (d *Document) ImageWatermark(image Stream, width, height float64, options WatermarkOptions) {
+	imageResources := NewDictionary()
+	imageResources.Add("Im1", d.WriteObject(image))
+	resources := NewDictionary()
+	resources.Add("XObject", imageResources)
+
+	form := d.newWatermarkForm(width, height, resources)
+	precision := realPrecision(d.file)
+	fmt.Fprintf(form, "q %s 0 0 %s 0 0 cm /Im1 Do Q", FormatReal(width, precision), FormatReal(height, precision))
+	d.Watermark(form, width, height, options)
+}
+
+func (d *Document) newWatermarkForm(width, height float64, resources Dictionary) Stream {
+	form := d.streamFactory.New()
+	form.Add("Type", NewName("XObject"))
+	form.Add("Subtype", NewName("Form"))
+	form.Add("BBox", NewRectangle(0, 0, width, height))
+	form.Add("Resources", resources)
+	return form
+}
+
+// Watermark() stamps pages with a form XObject whose bounding box
+// has the specified width and height.  The form is centered on each
+// page's media box.  Any page being constructed with NewPage() is
+// finished first.
+func (d *Document) Watermark(form Stream, width, height float64, options WatermarkOptions) {
+	d.finishCurrentPage()
+
+	pages := options.Pages
+	if pages == nil {
+		pages = make([]uint, d.pageCount)
+		for i := range pages {
+			pages[i] = uint(i)
+		}
+	}
+
+	scale := options.Scale
+	if scale == 0 {
+		scale = 1
+	}
+	opacity := options.Opacity
+	if opacity == 0 {
+		opacity = 1
+	}
+
+	graphicsState := NewDictionary()
+	graphicsState.Add("Type", NewName("ExtGState"))
+	graphicsState.Add("CA", NewNumeric(opacity))
+	graphicsState.Add("ca", NewNumeric(opacity))
+
+	formReference := d.WriteObject(form)
+	graphicsStateReference := d.WriteObject(graphicsState)
+
+	// The content that wraps existing content when the
+	// watermark is drawn over it so that changes to the graphics
+	// state made by the existing content do not affect the
+	// watermark.
+	var save, restore Indirect
+	if !options.Under {
+		save = d.writeContents("q ")
+		restore = d.writeContents(" Q ")
+	}
+
+	var stamped []*ExistingPage
+	for _,n := range pages {
+		if page := d.Page(n); page != nil {
+			stamped = append(stamped, page)
+		}
+	}
+	formName,stateName := watermarkNames(stamped)
+
+	// Pages with the same media box share a content stream.
+	stamps := make(map[[4]float64]Indirect)
+
+	for _,page := range stamped {
+		box := mediaBox(page)
+		stamp,exists := stamps[box]
+		if !exists {
+			sin,cos := math.Sincos(options.Rotation * math.Pi / 180)
+			a, b, c, e := scale*cos, scale*sin, -scale*sin, scale*cos
+			x := (box[0] + box[2])/2 - (a*width + c*height)/2
+			y := (box[1] + box[3])/2 - (b*width + e*height)/2
+			stamp = d.writeContents(fmt.Sprintf(" q /%s gs %.4f %.4f %.4f %.4f %.4f %.4f cm /%s Do Q ",
+				stateName, a, b, c, e, x, y, formName))
+			stamps[box] = stamp
+		}
+
+		resources := pageResources(page.PageDictionary)
+		resourceCategory(resources, "XObject").Add(formName, formReference)
+		resourceCategory(resources, "ExtGState").Add(stateName, graphicsStateReference)
+		page.SetResources(d.WriteObject(resources))
+
+		if options.Under {
+			page.PrependContents(stamp)
+		} else {
+			page.PrependContents(save)
+			page.AppendContents(restore)
+			page.AppendContents(stamp)
+		}
+		page.Rewrite()
+	}
+}
+
+// watermarkNames() returns the names under which the form and the
+// graphics state of a watermark are added to the resources of pages,
+// choosing names that none of the pages already uses so that an
+// earlier watermark isn't replaced.
+func watermarkNames(pages []*ExistingPage) (form, state string) {
+	for i:=1; ; i++ {
+		form,state = watermarkFormName,watermarkStateName
+		if i > 1 {
+			form += strconv.Itoa(i)
+			state += strconv.Itoa(i)
+		}
+		used := false
+		for _,page := range pages {
+			resources := pageResources(page.PageDictionary)
+			if xobjects := resources.GetDictionary("XObject"); xobjects != nil && xobjects.Get(form) != nil {
+				used = true
+			}
+			if states := resources.GetDictionary("ExtGState"); states != nil && states.Get(state) != nil {
+				used = true
+			}
+		}
+		if !used {
+			return form,state
+		}
+	}
+}
+
+func (d *Document) writeContents(s string) Indirect {
+	stream := d.streamFactory.New()
+	stream.Write([]byte(s))
+	return d.WriteObject(stream)
+}
+
+// mediaBox() returns the media box of a page, or a US letter page if
+// the page has no valid media box.
+func mediaBox(page *ExistingPage) [4]float64 {
+	if box,ok := page.MediaBox(); ok {
+		return box
+	}
+	return [4]float64{0, 0, 612, 792}
+}
+
+// pageResources() returns a copy of a page's resource dictionary.
+// Changes to the copy take effect when it is written and installed
+// with SetResources(), so resource dictionaries shared with other
+// pages are not affected.
+func pageResources(pd *PageDictionary) Dictionary {
+	if existing := pd.GetDictionary("Resources"); existing != nil {
+		return existing.Unprotect().(Dictionary)
+	}
+	return NewDictionary()
+}
+
+// resourceCategory() returns the subdictionary of resources for the
+// category (e.g., "XObject"), replacing it with a direct copy that
+// may be modified.
+func resourceCategory(resources Dictionary, category string) Dictionary {
+	entries := NewDictionary()
+	if existing := resources.GetDictionary(category); existing != nil {
+		entries = existing.Unprotect().(Dictionary)
+	}
+	resources.Add(category, entries)
+	return entries
+}
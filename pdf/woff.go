@@ -0,0 +1,53 @@
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"io" )
+
+// WOFF2 compresses fonts with Brotli and transforms the glyf, loca,
+// and hmtx tables, neither of which is implemented.
+var woff2NotSupported = errors.New(`WOFF2 fonts are not supported; convert the font to WOFF or OpenType`)
+
+// parseWOFF() decompresses the tables of a WOFF 1.0 font.  The
+// metadata and private data blocks are ignored.
+func parseWOFF(data []byte) (*sfnt, error) {
+	const headerSize, entrySize = 44, 20
+	if len(data) < headerSize {
+		return nil, truncatedFont
+	}
+	font := &sfnt{binary.BigEndian.Uint32(data[4:]), make(map[string][]byte)}
+	numTables := int(binary.BigEndian.Uint16(data[12:]))
+	if len(data) < headerSize+entrySize*numTables {
+		return nil, truncatedFont
+	}
+	for i:=0; i<numTables; i++ {
+		entry := data[headerSize+entrySize*i:]
+		tag := string(entry[:4])
+		offset := binary.BigEndian.Uint32(entry[4:])
+		compressedLength := binary.BigEndian.Uint32(entry[8:])
+		length := binary.BigEndian.Uint32(entry[12:])
+		if uint64(offset)+uint64(compressedLength) > uint64(len(data)) || compressedLength > length {
+			return nil, truncatedFont
+		}
+		table := data[offset:offset+compressedLength]
+		if compressedLength < length {
+			// Compressed tables are zlib streams.
+			r,err := zlib.NewReader(bytes.NewReader(table))
+			if err != nil {
+				return nil, err
+			}
+			decompressed := make([]byte, length)
+			_,err = io.ReadFull(r, decompressed)
+			r.Close()
+			if err != nil {
+				return nil, err
+			}
+			table = decompressed
+		}
+		font.tables[tag] = table
+	}
+	return font, nil
+}
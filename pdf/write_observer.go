@@ -0,0 +1,50 @@
+package pdf
+
+// WriteObserver is notified as objects are written to a File, so that
+// features such as audit logging, statistics, or rewriting objects on
+// their way to the file can be added without changing the code that
+// writes them.  Since objects may be written from several goroutines,
+// the methods may be called concurrently.
+type WriteObserver interface {
+	// BeforeWrite() is called with each object written at o
+	// before it is serialized or encrypted.  It returns the object
+	// to write in its place: object itself, a replacement, or
+	// nil to veto the write, in which case null is written so
+	// that references to o remain valid.
+	BeforeWrite(o ObjectNumber, object Object) Object
+
+	// AfterWrite() is called once object, as returned by
+	// BeforeWrite(), has been serialized and queued to be written
+	// at o.  Objects left untouched by WithRoundTripFidelity() are
+	// not reported.
+	AfterWrite(o ObjectNumber, object Object)
+}
+
+// WithWriteObserver() returns a FileOption that notifies observer of
+// each object written to the file.  The option may be given more than
+// once; the observers are called in the order in which they were
+// given, and each receives the object returned by the one before it.
+func WithWriteObserver(observer WriteObserver) FileOption {
+	return func(f *file) {
+		f.writeObservers = append(f.writeObservers, observer)
+	}
+}
+
+// beforeWrite() returns the object to write at o in place of object,
+// as the observers of f decide.
+func (f *file) beforeWrite(o ObjectNumber, object Object) Object {
+	for _,observer := range f.writeObservers {
+		if object = observer.BeforeWrite(o, object); object == nil {
+			object = NewNull()
+		}
+	}
+	return object
+}
+
+// afterWrite() reports to the observers of f that object was written
+// at o.
+func (f *file) afterWrite(o ObjectNumber, object Object) {
+	for _,observer := range f.writeObservers {
+		observer.AfterWrite(o, object)
+	}
+}
@@ -0,0 +1,75 @@
+package pdf
+
+import (
+	"bytes"
+	"encoding/xml"
+	"time" )
+
+// xmpMetadata() generates an XMP metadata packet mirroring the
+// entries of the document information dictionary, as required by
+// PDF/A, along with the PDF/A identification schema.
+func xmpMetadata(info *DocumentInfo, conformance Conformance) []byte {
+	var buffer bytes.Buffer
+	escape := func(s string) {
+		xml.EscapeText(&buffer, []byte(s))
+	}
+	element := func(name, value string) {
+		if value != "" {
+			buffer.WriteString("<" + name + ">")
+			escape(value)
+			buffer.WriteString("</" + name + ">\n")
+		}
+	}
+	alternative := func(name, value string) {
+		if value != "" {
+			buffer.WriteString("<" + name + "><rdf:Alt><rdf:li xml:lang=\"x-default\">")
+			escape(value)
+			buffer.WriteString("</rdf:li></rdf:Alt></" + name + ">\n")
+		}
+	}
+	date := func(name string, t time.Time) {
+		if !t.IsZero() {
+			element(name, t.Format(time.RFC3339))
+		}
+	}
+	description := func(namespace, uri string) {
+		buffer.WriteString(`<rdf:Description rdf:about="" xmlns:` + namespace + `="` + uri + "\">\n")
+	}
+
+	buffer.WriteString("<?xpacket begin=\"\xef\xbb\xbf\" id=\"W5M0MpCehiHzreSzNTczkc9d\"?>\n")
+	buffer.WriteString("<x:xmpmeta xmlns:x=\"adobe:ns:meta/\">\n")
+	buffer.WriteString("<rdf:RDF xmlns:rdf=\"http://www.w3.org/1999/02/22-rdf-syntax-ns#\">\n")
+
+	if conformance == PDFA2B {
+		description("pdfaid", "http://www.aiim.org/pdfa/ns/id/")
+		element("pdfaid:part", "2")
+		element("pdfaid:conformance", "B")
+		buffer.WriteString("</rdf:Description>\n")
+	}
+
+	description("dc", "http://purl.org/dc/elements/1.1/")
+	element("dc:format", "application/pdf")
+	alternative("dc:title", info.Title)
+	if info.Author != "" {
+		buffer.WriteString("<dc:creator><rdf:Seq><rdf:li>")
+		escape(info.Author)
+		buffer.WriteString("</rdf:li></rdf:Seq></dc:creator>\n")
+	}
+	alternative("dc:description", info.Subject)
+	buffer.WriteString("</rdf:Description>\n")
+
+	description("pdf", "http://ns.adobe.com/pdf/1.3/")
+	element("pdf:Producer", info.Producer)
+	element("pdf:Keywords", info.Keywords)
+	buffer.WriteString("</rdf:Description>\n")
+
+	description("xmp", "http://ns.adobe.com/xap/1.0/")
+	element("xmp:CreatorTool", info.Creator)
+	date("xmp:CreateDate", info.CreationDate)
+	date("xmp:ModifyDate", info.ModDate)
+	buffer.WriteString("</rdf:Description>\n")
+
+	buffer.WriteString("</rdf:RDF>\n</x:xmpmeta>\n")
+	buffer.WriteString("<?xpacket end=\"w\"?>")
+	return buffer.Bytes()
+}
@@ -20,6 +20,7 @@ type HistoryReader struct {
 	buffer []byte
 	end, size uint
 	capacity uint
+	position int64
 }
 
 // NewHistoryReader() creates a new HistoryReader from a
@@ -45,6 +46,12 @@ func (d *HistoryReader) GetHistory() []byte {
 	return history
 }
 
+// Position() returns the number of bytes consumed from the
+// underlying reader, less those that have been unread.
+func (d *HistoryReader) Position() int64 {
+	return d.position
+}
+
 func (d *HistoryReader) Read(b []byte) (n int, err error) {
 	n,err = d.reader.Read(b)
 	for i:=0; i<n; i++ {
@@ -52,6 +59,7 @@ func (d *HistoryReader) Read(b []byte) (n int, err error) {
 		d.end = (d.end+1) % d.capacity
 	}
 	d.size += uint(n)
+	d.position += int64(n)
 	if (d.size > d.capacity) {
 		d.size = d.capacity
 	}
@@ -64,6 +72,7 @@ func (d *HistoryReader) ReadByte() (b byte, err error) {
 		d.buffer[d.end] = b
 		d.end = (d.end+1) % d.capacity
 		d.size += 1
+		d.position += 1
 		if (d.size > d.capacity) {
 			d.size = d.capacity
 		}
@@ -75,6 +84,7 @@ func (d *HistoryReader) UnreadByte() (err error) {
 	err = d.reader.UnreadByte()
 	if (err == nil) {
 		d.end = (d.end+d.capacity-1) % d.capacity
+		d.position -= 1
 		if (d.size > 0) {
 			d.size = d.size - 1
 		}
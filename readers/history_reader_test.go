@@ -33,5 +33,9 @@ func TestHistoryReader (t *testing.T) {
 	unreadAndCheck("cd")
 
 	b := make([]byte,4); reader.Read(b); check ("efgh")
+
+	if p := reader.Position(); p != 8 {
+		t.Errorf (`Expected position 8; got %d`, p)
+	}
 }
 
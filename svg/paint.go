@@ -0,0 +1,224 @@
+package svg
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"github.com/mawicks/PDFiG/pdf" )
+
+// Color is an RGB color with components from 0 to 1.
+type Color struct {
+	R, G, B float64
+}
+
+// Style holds the painting attributes of an SVG element.  A nil Fill
+// or Stroke paints nothing, as "none" does in SVG.
+type Style struct {
+	Fill *Color
+	Stroke *Color
+	StrokeWidth float64
+	// EvenOdd is true if fill-rule is evenodd rather than nonzero.
+	EvenOdd bool
+}
+
+// DefaultStyle() returns SVG's initial style: a black fill, no stroke,
+// and a stroke width of 1.
+func DefaultStyle() *Style {
+	return &Style{Fill: &Color{0, 0, 0}, StrokeWidth: 1}
+}
+
+// namedColors holds the basic SVG color keywords.
+var namedColors = map[string]Color{
+	"black": {0, 0, 0},
+	"silver": {0xc0/255.0, 0xc0/255.0, 0xc0/255.0},
+	"gray": {0x80/255.0, 0x80/255.0, 0x80/255.0},
+	"grey": {0x80/255.0, 0x80/255.0, 0x80/255.0},
+	"white": {1, 1, 1},
+	"maroon": {0x80/255.0, 0, 0},
+	"red": {1, 0, 0},
+	"purple": {0x80/255.0, 0, 0x80/255.0},
+	"fuchsia": {1, 0, 1},
+	"magenta": {1, 0, 1},
+	"green": {0, 0x80/255.0, 0},
+	"lime": {0, 1, 0},
+	"olive": {0x80/255.0, 0x80/255.0, 0},
+	"yellow": {1, 1, 0},
+	"navy": {0, 0, 0x80/255.0},
+	"blue": {0, 0, 1},
+	"teal": {0, 0x80/255.0, 0x80/255.0},
+	"aqua": {0, 1, 1},
+	"cyan": {0, 1, 1},
+	"orange": {1, 0xa5/255.0, 0} }
+
+// ParseColor() parses an SVG paint value: a color keyword, #rgb,
+// #rrggbb, rgb(r, g, b) with integer or percentage components, or
+// "none", for which it returns nil.
+func ParseColor(value string) (*Color, error) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if value == "none" {
+		return nil, nil
+	}
+	if c,ok := namedColors[value]; ok {
+		return &c, nil
+	}
+	bad := fmt.Errorf(`Unsupported SVG color "%s"`, value)
+	switch {
+	case strings.HasPrefix(value, "#"):
+		hex := value[1:]
+		if len(hex) == 3 {
+			hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+		}
+		if len(hex) != 6 {
+			return nil, bad
+		}
+		v,err := strconv.ParseUint(hex, 16, 32)
+		if err != nil {
+			return nil, bad
+		}
+		return &Color{float64(v>>16)/255, float64(v>>8&0xff)/255, float64(v&0xff)/255}, nil
+	case strings.HasPrefix(value, "rgb(") && strings.HasSuffix(value, ")"):
+		parts := strings.Split(value[4:len(value)-1], ",")
+		if len(parts) != 3 {
+			return nil, bad
+		}
+		var components [3]float64
+		for i,part := range parts {
+			part = strings.TrimSpace(part)
+			scale := 255.0
+			if strings.HasSuffix(part, "%") {
+				part, scale = part[:len(part)-1], 100
+			}
+			v,err := strconv.ParseFloat(part, 64)
+			if err != nil {
+				return nil, bad
+			}
+			components[i] = clamp(v / scale)
+		}
+		return &Color{components[0], components[1], components[2]}, nil
+	}
+	return nil, bad
+}
+
+func clamp(v float64) float64 {
+	if v < 0 {
+		return 0
+	}
+	if v > 1 {
+		return 1
+	}
+	return v
+}
+
+// Apply() updates the style from an element's attributes.  The fill,
+// stroke, stroke-width, and fill-rule presentation attributes are
+// read, followed by the same properties in the style attribute, which
+// take precedence.  Other attributes and properties are ignored.
+func (s *Style) Apply(attributes map[string]string) error {
+	for _,name := range []string{"fill", "stroke", "stroke-width", "fill-rule"} {
+		if value,ok := attributes[name]; ok {
+			if err := s.set(name, value); err != nil {
+				return err
+			}
+		}
+	}
+	for _,declaration := range strings.Split(attributes["style"], ";") {
+		colon := strings.IndexByte(declaration, ':')
+		if colon < 0 {
+			continue
+		}
+		name := strings.TrimSpace(declaration[:colon])
+		if err := s.set(name, strings.TrimSpace(declaration[colon+1:])); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Style) set(name, value string) (err error) {
+	switch name {
+	case "fill":
+		s.Fill,err = ParseColor(value)
+	case "stroke":
+		s.Stroke,err = ParseColor(value)
+	case "stroke-width":
+		// Lengths in user units, optionally with a px suffix, are
+		// accepted.
+		s.StrokeWidth,err = strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(value), "px"), 64)
+		if err != nil {
+			err = fmt.Errorf(`Unsupported SVG stroke width "%s"`, value)
+		}
+	case "fill-rule":
+		s.EvenOdd = strings.TrimSpace(value) == "evenodd"
+	}
+	return err
+}
+
+// Paint() returns the operations that paint path, which was returned
+// by ParsePath(), in the style: the color and line width settings,
+// the path, and the painting operator, enclosed in q and Q so that
+// the settings don't leak into the surrounding content.
+func (s *Style) Paint(path []pdf.Operation) []pdf.Operation {
+	ops := []pdf.Operation{{Operator: "q"}}
+	if s.Fill != nil {
+		ops = append(ops, colorOperation("rg", s.Fill))
+	}
+	if s.Stroke != nil {
+		ops = append(ops, colorOperation("RG", s.Stroke),
+			pdf.Operation{Operator: "w", Operands: []pdf.Object{pdf.NewNumeric(s.StrokeWidth)}})
+	}
+	ops = append(ops, path...)
+
+	var operator string
+	switch {
+	case s.Fill != nil && s.Stroke != nil:
+		operator = "B"
+	case s.Fill != nil:
+		operator = "f"
+	case s.Stroke != nil:
+		operator = "S"
+	default:
+		operator = "n"
+	}
+	if s.EvenOdd && s.Fill != nil {
+		operator += "*"
+	}
+	return append(ops, pdf.Operation{Operator: operator}, pdf.Operation{Operator: "Q"})
+}
+
+func colorOperation(operator string, c *Color) pdf.Operation {
+	return pdf.Operation{Operator: operator,
+		Operands: []pdf.Object{pdf.NewNumeric(c.R), pdf.NewNumeric(c.G), pdf.NewNumeric(c.B)}}
+}
+
+// Transform() returns a cm operation that maps an SVG viewBox, given
+// by its upper left corner and size in user space, onto the rectangle
+// with lower left corner (x, y) and the given width and height.  The
+// y axis, which points down in SVG, is flipped.
+func Transform(minX, minY, viewWidth, viewHeight, x, y, width, height float64) pdf.Operation {
+	sx, sy := width/viewWidth, height/viewHeight
+	return pdf.Operation{Operator: "cm", Operands: []pdf.Object{
+		pdf.NewNumeric(sx), pdf.NewNumeric(0), pdf.NewNumeric(0), pdf.NewNumeric(-sy),
+		pdf.NewNumeric(x - minX*sx), pdf.NewNumeric(y + height + minY*sy)}}
+}
+
+// DrawPath() writes the operations that paint the SVG path data d in
+// style to w, which is typically a Page or a Form XObject's Stream
+// whose coordinates have been set up by Transform().  If style is
+// nil, DefaultStyle() is used.
+func DrawPath(w io.Writer, d string, style *Style) error {
+	if style == nil {
+		style = DefaultStyle()
+	}
+	path,err := ParsePath(d)
+	if err != nil {
+		return err
+	}
+	cs := pdf.NewContentSerializer(w)
+	for _,op := range style.Paint(path) {
+		if err := cs.Write(op); err != nil {
+			return err
+		}
+	}
+	return nil
+}
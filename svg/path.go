@@ -0,0 +1,343 @@
+/*
+	Package svg converts SVG path data and painting attributes to
+	PDF content stream operations, so that vector artwork such as
+	logos can be drawn without rasterizing it.
+*/
+package svg
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"github.com/mawicks/PDFiG/pdf" )
+
+// ParsePath() converts SVG path data, the value of a path element's
+// d attribute, to path construction operations (m, l, c, and h).
+// Quadratic curves and elliptical arcs are converted to cubic
+// curves.  Coordinates are unchanged, so the y axis points down as in
+// SVG; see Transform().  Path data following an error is ignored, as
+// SVG requires, and the operations up to the error are returned with
+// the error.
+func ParsePath(d string) ([]pdf.Operation, error) {
+	p := &pathParser{data: d}
+	err := p.parse()
+	return p.ops, err
+}
+
+type pathParser struct {
+	data string
+	pos int
+	ops []pdf.Operation
+	// x and y are the current point, and startX and startY the
+	// start of the current subpath.
+	x, y, startX, startY float64
+	// controlX and controlY are the last control point of the
+	// previous curve, reflected by S and T commands.
+	controlX, controlY float64
+	lastCommand byte
+}
+
+func (p *pathParser) parse() error {
+	var command byte
+	for {
+		p.skipSeparators()
+		if p.pos >= len(p.data) {
+			return nil
+		}
+		c := p.data[p.pos]
+		switch {
+		case isCommand(c):
+			command = c
+			p.pos++
+		case command == 0:
+			return p.errorf("path data doesn't begin with a command")
+		case command == 'Z' || command == 'z':
+			return p.errorf("unexpected number after closepath")
+		case command == 'M':
+			// Coordinates following a moveto are linetos.
+			command = 'L'
+		case command == 'm':
+			command = 'l'
+		}
+		if err := p.segment(command); err != nil {
+			return err
+		}
+		p.lastCommand = command
+	}
+}
+
+func isCommand(c byte) bool {
+	switch c {
+	case 'M', 'm', 'L', 'l', 'H', 'h', 'V', 'v', 'C', 'c', 'S', 's', 'Q', 'q', 'T', 't', 'A', 'a', 'Z', 'z':
+		return true
+	}
+	return false
+}
+
+// segment() reads the arguments of one command and adds its
+// operations.
+func (p *pathParser) segment(command byte) error {
+	relative := command >= 'a'
+	var dx, dy float64
+	if relative {
+		dx, dy = p.x, p.y
+	}
+	numbers := func(n int) ([]float64, error) {
+		values := make([]float64, n)
+		for i := range values {
+			v,err := p.number()
+			if err != nil {
+				return nil, err
+			}
+			values[i] = v
+		}
+		return values, nil
+	}
+
+	switch command {
+	case 'Z', 'z':
+		p.add("h")
+		p.x, p.y = p.startX, p.startY
+	case 'M', 'm':
+		v,err := numbers(2)
+		if err != nil {
+			return err
+		}
+		p.x, p.y = v[0]+dx, v[1]+dy
+		p.startX, p.startY = p.x, p.y
+		p.add("m", p.x, p.y)
+	case 'L', 'l', 'H', 'h', 'V', 'v':
+		count := 1
+		if command == 'L' || command == 'l' {
+			count = 2
+		}
+		v,err := numbers(count)
+		if err != nil {
+			return err
+		}
+		switch command {
+		case 'L', 'l':
+			p.x, p.y = v[0]+dx, v[1]+dy
+		case 'H', 'h':
+			p.x = v[0] + dx
+		default:
+			p.y = v[0] + dy
+		}
+		p.add("l", p.x, p.y)
+	case 'C', 'c':
+		v,err := numbers(6)
+		if err != nil {
+			return err
+		}
+		p.curve(v[0]+dx, v[1]+dy, v[2]+dx, v[3]+dy, v[4]+dx, v[5]+dy)
+	case 'S', 's':
+		v,err := numbers(4)
+		if err != nil {
+			return err
+		}
+		x1, y1 := p.reflectedControl("CcSs")
+		p.curve(x1, y1, v[0]+dx, v[1]+dy, v[2]+dx, v[3]+dy)
+	case 'Q', 'q':
+		v,err := numbers(4)
+		if err != nil {
+			return err
+		}
+		p.quadratic(v[0]+dx, v[1]+dy, v[2]+dx, v[3]+dy)
+	case 'T', 't':
+		v,err := numbers(2)
+		if err != nil {
+			return err
+		}
+		x1, y1 := p.reflectedControl("QqTt")
+		p.quadratic(x1, y1, v[0]+dx, v[1]+dy)
+	case 'A', 'a':
+		v,err := numbers(3)
+		if err != nil {
+			return err
+		}
+		largeArc,err := p.flag()
+		if err != nil {
+			return err
+		}
+		sweep,err := p.flag()
+		if err != nil {
+			return err
+		}
+		end,err := numbers(2)
+		if err != nil {
+			return err
+		}
+		p.arc(v[0], v[1], v[2], largeArc, sweep, end[0]+dx, end[1]+dy)
+	}
+	return nil
+}
+
+// reflectedControl() returns the reflection of the previous control
+// point about the current point if the previous command is one of
+// commands, or the current point otherwise.
+func (p *pathParser) reflectedControl(commands string) (float64, float64) {
+	for i:=0; i<len(commands); i++ {
+		if p.lastCommand == commands[i] {
+			return 2*p.x - p.controlX, 2*p.y - p.controlY
+		}
+	}
+	return p.x, p.y
+}
+
+func (p *pathParser) curve(x1, y1, x2, y2, x, y float64) {
+	p.add("c", x1, y1, x2, y2, x, y)
+	p.controlX, p.controlY = x2, y2
+	p.x, p.y = x, y
+}
+
+// quadratic() adds the cubic curve equivalent to a quadratic curve
+// with control point (qx, qy).
+func (p *pathParser) quadratic(qx, qy, x, y float64) {
+	p.add("c", p.x + 2*(qx-p.x)/3, p.y + 2*(qy-p.y)/3, x + 2*(qx-x)/3, y + 2*(qy-y)/3, x, y)
+	p.controlX, p.controlY = qx, qy
+	p.x, p.y = x, y
+}
+
+// arc() adds cubic curves approximating an elliptical arc to (x, y),
+// following the conversion from endpoint to center parameterization
+// in Appendix F.6 of the SVG 1.1 specification.
+func (p *pathParser) arc(rx, ry, rotation float64, largeArc, sweep bool, x, y float64) {
+	x0, y0 := p.x, p.y
+	defer func() {
+		p.x, p.y = x, y
+		p.controlX, p.controlY = x, y
+	}()
+	if x0 == x && y0 == y {
+		return
+	}
+	rx, ry = math.Abs(rx), math.Abs(ry)
+	if rx == 0 || ry == 0 {
+		p.add("l", x, y)
+		return
+	}
+
+	phi := rotation * math.Pi / 180
+	cos, sin := math.Cos(phi), math.Sin(phi)
+	// The midpoint between the ends in the ellipse's coordinates.
+	mx := cos*(x0-x)/2 + sin*(y0-y)/2
+	my := -sin*(x0-x)/2 + cos*(y0-y)/2
+	// Radii too small to reach the end point are scaled up.
+	if lambda := mx*mx/(rx*rx) + my*my/(ry*ry); lambda > 1 {
+		rx, ry = rx*math.Sqrt(lambda), ry*math.Sqrt(lambda)
+	}
+	numerator := rx*rx*ry*ry - rx*rx*my*my - ry*ry*mx*mx
+	denominator := rx*rx*my*my + ry*ry*mx*mx
+	factor := math.Sqrt(math.Max(0, numerator/denominator))
+	if largeArc == sweep {
+		factor = -factor
+	}
+	cxp, cyp := factor*rx*my/ry, -factor*ry*mx/rx
+	cx := cos*cxp - sin*cyp + (x0+x)/2
+	cy := sin*cxp + cos*cyp + (y0+y)/2
+
+	angle := func(ux, uy, vx, vy float64) float64 {
+		return math.Atan2(ux*vy-uy*vx, ux*vx+uy*vy)
+	}
+	theta := angle(1, 0, (mx-cxp)/rx, (my-cyp)/ry)
+	delta := angle((mx-cxp)/rx, (my-cyp)/ry, (-mx-cxp)/rx, (-my-cyp)/ry)
+	if !sweep && delta > 0 {
+		delta -= 2*math.Pi
+	} else if sweep && delta < 0 {
+		delta += 2*math.Pi
+	}
+
+	// Each segment spans at most a quarter turn.
+	segments := int(math.Ceil(math.Abs(delta) / (math.Pi/2) - 1e-9))
+	step := delta / float64(segments)
+	k := 4.0 / 3 * math.Tan(step/4)
+	point := func(t float64) (float64, float64) {
+		ex, ey := rx*math.Cos(t), ry*math.Sin(t)
+		return cos*ex - sin*ey + cx, sin*ex + cos*ey + cy
+	}
+	derivative := func(t float64) (float64, float64) {
+		ex, ey := -rx*math.Sin(t), ry*math.Cos(t)
+		return cos*ex - sin*ey, sin*ex + cos*ey
+	}
+	for i:=0; i<segments; i++ {
+		t1 := theta + float64(i)*step
+		t2 := t1 + step
+		x1, y1 := point(t1)
+		dx1, dy1 := derivative(t1)
+		x2, y2 := point(t2)
+		dx2, dy2 := derivative(t2)
+		if i == segments-1 {
+			// Land exactly on the end point.
+			x2, y2 = x, y
+		}
+		p.add("c", x1+k*dx1, y1+k*dy1, x2-k*dx2, y2-k*dy2, x2, y2)
+	}
+}
+
+func (p *pathParser) add(operator string, operands ...float64) {
+	op := pdf.Operation{Operator: operator}
+	for _,v := range operands {
+		op.Operands = append(op.Operands, pdf.NewNumeric(v))
+	}
+	p.ops = append(p.ops, op)
+}
+
+func (p *pathParser) skipSeparators() {
+	for p.pos < len(p.data) {
+		switch p.data[p.pos] {
+		case ' ', '\t', '\n', '\r', '\f', ',':
+			p.pos++
+		default:
+			return
+		}
+	}
+}
+
+// number() reads a number, which may be adjacent to the previous one
+// when the two are distinguishable, as in "1-2" or "0.5.5".
+func (p *pathParser) number() (float64, error) {
+	p.skipSeparators()
+	start := p.pos
+	i := p.pos
+	if i < len(p.data) && (p.data[i] == '+' || p.data[i] == '-') {
+		i++
+	}
+	digits := false
+	for ; i < len(p.data) && p.data[i] >= '0' && p.data[i] <= '9'; i++ {
+		digits = true
+	}
+	if i < len(p.data) && p.data[i] == '.' {
+		for i++; i < len(p.data) && p.data[i] >= '0' && p.data[i] <= '9'; i++ {
+			digits = true
+		}
+	}
+	if digits && i < len(p.data) && (p.data[i] == 'e' || p.data[i] == 'E') {
+		j := i + 1
+		if j < len(p.data) && (p.data[j] == '+' || p.data[j] == '-') {
+			j++
+		}
+		if j < len(p.data) && p.data[j] >= '0' && p.data[j] <= '9' {
+			for i = j; i < len(p.data) && p.data[i] >= '0' && p.data[i] <= '9'; i++ {
+			}
+		}
+	}
+	if !digits {
+		return 0, p.errorf("expected a number")
+	}
+	p.pos = i
+	return strconv.ParseFloat(p.data[start:i], 64)
+}
+
+// flag() reads an arc flag, which is a single digit that needn't be
+// separated from what follows.
+func (p *pathParser) flag() (bool, error) {
+	p.skipSeparators()
+	if p.pos < len(p.data) && (p.data[p.pos] == '0' || p.data[p.pos] == '1') {
+		p.pos++
+		return p.data[p.pos-1] == '1', nil
+	}
+	return false, p.errorf("expected an arc flag")
+}
+
+func (p *pathParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf(`SVG path data error at offset %d: %s`, p.pos, fmt.Sprintf(format, args...))
+}
@@ -0,0 +1,201 @@
+package svg
+
+import (
+	"bytes"
+	"io/ioutil"
+	"math"
+	"os"
+	"strings"
+	"testing"
+	"github.com/mawicks/PDFiG/pdf" )
+
+// format() returns ops as they appear in a content stream, one per
+// line.
+func format(ops []pdf.Operation) string {
+	var b bytes.Buffer
+	cs := pdf.NewContentSerializer(&b)
+	for _,op := range ops {
+		cs.Write(op)
+	}
+	return strings.TrimSpace(b.String())
+}
+
+func TestParsePath(t *testing.T) {
+	tests := []struct{ d, expected string }{
+		{"M10 20 L30 40 Z", "10 20 m\n30 40 l\nh"},
+		// Implicit linetos after a moveto, relative commands,
+		// and numbers separated only by signs.
+		{"m10,20 5-5 h10v-3", "10 20 m\n15 15 l\n25 15 l\n25 12 l"},
+		{"M0 0H10V10H0z M5 5", "0 0 m\n10 0 l\n10 10 l\n0 10 l\nh\n5 5 m"},
+		{"M0 0C1 2 3 4 5 6S9 10 11 12", "0 0 m\n1 2 3 4 5 6 c\n7 8 9 10 11 12 c"},
+		// Smooth curves not following a curve use the current
+		// point as the first control point.
+		{"M0 0S3 4 5 6", "0 0 m\n0 0 3 4 5 6 c"},
+		{"M0 0Q3 6 6 0T12 0", "0 0 m\n2 4 4 4 6 0 c\n8 -4 10 -4 12 0 c"},
+		{"M.5.5l1e1 0", "0.5 0.5 m\n10.5 0.5 l"},
+	}
+	for _,test := range tests {
+		ops,err := ParsePath(test.d)
+		if err != nil {
+			t.Errorf(`ParsePath("%s") failed: %v`, test.d, err)
+			continue
+		}
+		if result := format(ops); result != test.expected {
+			t.Errorf(`ParsePath("%s") returned %q; expected %q`, test.d, result, test.expected)
+		}
+	}
+
+	for _,d := range []string{"10 10", "M10", "M0 0 L1 2 Z 3", "M0 0 A1 1 0 2 0 1 1", "M0 0 X"} {
+		if _,err := ParsePath(d); err == nil {
+			t.Errorf(`ParsePath("%s") succeeded but should have failed`, d)
+		}
+	}
+	// The operations before an error are returned.
+	if ops,_ := ParsePath("M1 2 L3"); len(ops) != 1 {
+		t.Errorf(`ParsePath() returned %d operations before an error; expected 1`, len(ops))
+	}
+}
+
+// operands() returns the numeric operands of op.
+func operands(t *testing.T, op pdf.Operation) []float64 {
+	var result []float64
+	for _,o := range op.Operands {
+		switch n := o.(type) {
+		case *pdf.IntNumeric:
+			result = append(result, float64(n.Value()))
+		case *pdf.RealNumeric:
+			result = append(result, float64(n.Value()))
+		default:
+			t.Fatalf(`Operand %v of %s is not a number`, o, op.Operator)
+		}
+	}
+	return result
+}
+
+func TestArc(t *testing.T) {
+	// A half circle of radius 10 centered at (10, 0), drawn with
+	// packed flags.
+	ops,err := ParsePath("M0 0A10 10 0 0120 0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A half turn is drawn as two quarter turn curves.
+	if len(ops) != 3 {
+		t.Fatalf(`Arc produced %d operations; expected 3`, len(ops))
+	}
+	end := operands(t, ops[2])
+	if math.Abs(end[4]-20) > 1e-4 || math.Abs(end[5]) > 1e-4 {
+		t.Errorf(`Arc ended at (%g, %g); expected (20, 0)`, end[4], end[5])
+	}
+	// Each curve's points lie close to the circle, and positive sweep
+	// (clockwise on screen) passes through the top, at y = -10.
+	middle := operands(t, ops[1])
+	if math.Abs(middle[4]-10) > 1e-4 || math.Abs(middle[5]+10) > 1e-4 {
+		t.Errorf(`Arc passed through (%g, %g); expected (10, -10)`, middle[4], middle[5])
+	}
+	x0, y0 := 0.0, 0.0
+	for _,op := range ops[1:] {
+		v := operands(t, op)
+		// The midpoint of the curve from its control points.
+		mx := (x0 + 3*v[0] + 3*v[2] + v[4]) / 8
+		my := (y0 + 3*v[1] + 3*v[3] + v[5]) / 8
+		if r := math.Hypot(mx-10, my); math.Abs(r-10) > 0.01 {
+			t.Errorf(`Arc curve midpoint (%g, %g) is %g from the center; expected 10`, mx, my, r)
+		}
+		x0, y0 = v[4], v[5]
+	}
+
+	// Radii too small to reach the end point are scaled up, and a
+	// zero radius draws a line.
+	if ops,_ := ParsePath("M0 0A1 1 0 0 0 20 0"); len(ops) != 3 {
+		t.Errorf(`Scaled arc produced %d operations; expected 3`, len(ops))
+	}
+	if ops,_ := ParsePath("M0 0A0 5 0 0 0 20 0"); format(ops) != "0 0 m\n20 0 l" {
+		t.Errorf(`Zero radius arc produced %q`, format(ops))
+	}
+}
+
+func TestStyle(t *testing.T) {
+	style := DefaultStyle()
+	err := style.Apply(map[string]string{
+		"fill": "#f00",
+		"stroke": "blue",
+		"stroke-width": "3",
+		"style": "fill: rgb(0, 128, 100%); fill-rule:evenodd; opacity: 0.5" })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if style.Fill == nil || *style.Fill != (Color{0, 128.0/255, 1}) {
+		t.Errorf(`Fill is %v; the style attribute should take precedence`, style.Fill)
+	}
+	if style.Stroke == nil || *style.Stroke != (Color{0, 0, 1}) || style.StrokeWidth != 3 || !style.EvenOdd {
+		t.Errorf(`Style is %+v`, style)
+	}
+	if c,_ := ParseColor("#336699"); c == nil || *c != (Color{0x33/255.0, 0x66/255.0, 0x99/255.0}) {
+		t.Errorf(`ParseColor("#336699") returned %v`, c)
+	}
+	for _,value := range []string{"#12", "rgb(1,2)", "chartreuse-ish"} {
+		if _,err := ParseColor(value); err == nil {
+			t.Errorf(`ParseColor("%s") succeeded but should have failed`, value)
+		}
+	}
+
+	painters := []struct {
+		attributes map[string]string
+		expected string
+	}{
+		{map[string]string{}, "f"},
+		{map[string]string{"fill-rule": "evenodd"}, "f*"},
+		{map[string]string{"stroke": "red"}, "B"},
+		{map[string]string{"fill": "none", "stroke": "red"}, "S"},
+		{map[string]string{"fill": "none", "fill-rule": "evenodd", "stroke": "red"}, "S"},
+		{map[string]string{"style": "fill:none"}, "n"},
+	}
+	for _,p := range painters {
+		style := DefaultStyle()
+		style.Apply(p.attributes)
+		ops := style.Paint(nil)
+		if ops[0].Operator != "q" || ops[len(ops)-1].Operator != "Q" || ops[len(ops)-2].Operator != p.expected {
+			t.Errorf(`Style %v painted with %q; expected %s`, p.attributes, format(ops), p.expected)
+		}
+	}
+}
+
+func TestDrawPath(t *testing.T) {
+	filename := "/tmp/test-svg.pdf"
+	os.Remove(filename)
+	doc := pdf.OpenDocument(filename, os.O_RDWR|os.O_CREATE)
+	page := doc.NewPage()
+	pdf.NewContentSerializer(page).Write(Transform(0, 0, 100, 50, 72, 72, 200, 100))
+	style := &Style{Stroke: &Color{1, 0, 0}, StrokeWidth: 2}
+	if err := DrawPath(page, "M10 10 L90 40", style); err != nil {
+		t.Fatal(err)
+	}
+	if err := DrawPath(page, "M10 10 L", nil); err == nil {
+		t.Errorf(`DrawPath() succeeded with bad path data`)
+	}
+	doc.Close()
+
+	doc = pdf.OpenDocument(filename, os.O_RDWR)
+	defer doc.Close()
+	contents,_ := ioutil.ReadAll(doc.Page(0).Reader())
+	expected := "2 0 0 -2 72 172 cm\nq\n1 0 0 RG\n2 w\n10 10 m\n90 40 l\nS\nQ\n"
+	if s := string(contents); s != expected {
+		t.Errorf(`Page contents are %q; expected %q`, s, expected)
+	}
+}
+
+func TestTransform(t *testing.T) {
+	// The viewBox's upper left corner maps to the rectangle's upper
+	// left corner and its lower right corner to the lower right.
+	m := operands(t, Transform(10, 20, 100, 50, 72, 72, 200, 100))
+	apply := func(x, y float64) (float64, float64) {
+		return m[0]*x + m[2]*y + m[4], m[1]*x + m[3]*y + m[5]
+	}
+	if x,y := apply(10, 20); x != 72 || y != 172 {
+		t.Errorf(`Upper left mapped to (%g, %g); expected (72, 172)`, x, y)
+	}
+	if x,y := apply(110, 70); x != 272 || y != 72 {
+		t.Errorf(`Lower right mapped to (%g, %g); expected (272, 72)`, x, y)
+	}
+}